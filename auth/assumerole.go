@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+const (
+	// DefaultSessionDuration is how long assumed-role credentials last when
+	// AssumeRole.Duration isn't set.
+	DefaultSessionDuration = time.Hour
+	// MinSessionDuration and MaxSessionDuration are the limits STS puts on
+	// a role session; a role's own maximum may be lower.
+	MinSessionDuration = 15 * time.Minute
+	MaxSessionDuration = 12 * time.Hour
+
+	defaultSessionName = "chat-cli"
+)
+
+// AssumeRole describes the role to assume and how.
+type AssumeRole struct {
+	RoleARN    string
+	ExternalID string
+	// MFASerial is the ARN or serial number of the MFA device the role's
+	// trust policy requires, if any.
+	MFASerial string
+	Duration  time.Duration
+	// SessionName shows up in CloudTrail; it defaults to chat-cli.
+	SessionName string
+	// Source names the credentials the role is assumed with, such as the
+	// AWS profile, so each has its own cache entry.
+	Source string
+}
+
+// cacheKey identifies the credentials this role produces.
+func (r AssumeRole) cacheKey() string {
+	return strings.Join([]string{r.Source, r.RoleARN, r.ExternalID, r.MFASerial, r.sessionName()}, "\x00")
+}
+
+func (r AssumeRole) sessionName() string {
+	if r.SessionName == "" {
+		return defaultSessionName
+	}
+	return r.SessionName
+}
+
+// Validate reports a role that STS would refuse.
+func (r AssumeRole) Validate() error {
+	if !strings.HasPrefix(r.RoleARN, "arn:") || !strings.Contains(r.RoleARN, ":role/") {
+		return fmt.Errorf("invalid role ARN %q: expected arn:aws:iam::<account>:role/<name>", r.RoleARN)
+	}
+	if r.Duration != 0 && (r.Duration < MinSessionDuration || r.Duration > MaxSessionDuration) {
+		return fmt.Errorf("invalid session duration %s: must be from %s to %s", r.Duration, MinSessionDuration, MaxSessionDuration)
+	}
+	return nil
+}
+
+// AssumeRoleProvider is an aws.CredentialsProvider that assumes a role
+// with STS, keeping the temporary credentials in a CredentialCache. Wrap
+// it in aws.NewCredentialsCache so they're also reused within a run.
+type AssumeRoleProvider struct {
+	client stscreds.AssumeRoleAPIClient
+	role   AssumeRole
+	cache  *CredentialCache
+	// mfaCode returns a code from the MFA device; it's only called when
+	// STS has to be asked for new credentials.
+	mfaCode func(serial string) (string, error)
+}
+
+// NewAssumeRoleProvider creates a provider that assumes role with client,
+// which is an STS client holding the credentials to assume it with. cache
+// may be nil to always call STS, and mfaCode may be nil when the role
+// doesn't need MFA.
+func NewAssumeRoleProvider(client stscreds.AssumeRoleAPIClient, role AssumeRole, cache *CredentialCache, mfaCode func(serial string) (string, error)) *AssumeRoleProvider {
+	return &AssumeRoleProvider{client: client, role: role, cache: cache, mfaCode: mfaCode}
+}
+
+// Retrieve returns cached credentials for the role while they're good, or
+// assumes it again, asking for an MFA code if the role needs one.
+func (p *AssumeRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	key := p.role.cacheKey()
+	if p.cache != nil {
+		if creds, ok := p.cache.Load(key); ok {
+			return creds, nil
+		}
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(p.client, p.role.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = p.role.sessionName()
+		o.Duration = p.role.Duration
+		if o.Duration == 0 {
+			o.Duration = DefaultSessionDuration
+		}
+		if p.role.ExternalID != "" {
+			o.ExternalID = aws.String(p.role.ExternalID)
+		}
+		if p.role.MFASerial != "" {
+			o.SerialNumber = aws.String(p.role.MFASerial)
+			if p.mfaCode != nil {
+				o.TokenProvider = func() (string, error) {
+					return p.mfaCode(p.role.MFASerial)
+				}
+			}
+		}
+	})
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("unable to assume role %s: %w", p.role.RoleARN, err)
+	}
+
+	if p.cache != nil {
+		// a cache that can't be written only costs another STS call next
+		// time, so the credentials are still used
+		_ = p.cache.Save(key, creds)
+	}
+	return creds, nil
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// fakeSTS records AssumeRole calls and answers them with credentials that
+// expire in an hour.
+type fakeSTS struct {
+	calls []*sts.AssumeRoleInput
+	err   error
+}
+
+func (f *fakeSTS) AssumeRole(_ context.Context, input *sts.AssumeRoleInput, _ ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	f.calls = append(f.calls, input)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("ASIAEXAMPLE"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+		AssumedRoleUser: &types.AssumedRoleUser{
+			Arn: aws.String("arn:aws:sts::123456789012:assumed-role/bedrock-user/chat-cli"),
+		},
+	}, nil
+}
+
+var testRole = AssumeRole{
+	RoleARN:    "arn:aws:iam::123456789012:role/bedrock-user",
+	ExternalID: "ext-123",
+	MFASerial:  "arn:aws:iam::123456789012:mfa/me",
+	Duration:   2 * time.Hour,
+}
+
+func TestAssumeRoleProvider_Retrieve(t *testing.T) {
+	client := &fakeSTS{}
+	cache := NewCredentialCache(t.TempDir())
+	var asked []string
+	mfaCode := func(serial string) (string, error) {
+		asked = append(asked, serial)
+		return "123456", nil
+	}
+
+	creds, err := NewAssumeRoleProvider(client, testRole, cache, mfaCode).Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "ASIAEXAMPLE" || creds.AccountID != "123456789012" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+	if len(client.calls) != 1 {
+		t.Fatalf("expected one AssumeRole call, got %d", len(client.calls))
+	}
+	input := client.calls[0]
+	if aws.ToString(input.RoleSessionName) != "chat-cli" || aws.ToString(input.ExternalId) != "ext-123" ||
+		aws.ToInt32(input.DurationSeconds) != 7200 || aws.ToString(input.SerialNumber) != testRole.MFASerial ||
+		aws.ToString(input.TokenCode) != "123456" {
+		t.Errorf("unexpected AssumeRole input %+v", input)
+	}
+	if len(asked) != 1 || asked[0] != testRole.MFASerial {
+		t.Errorf("expected the MFA code to be asked for once, got %v", asked)
+	}
+
+	// a second run is served from the cache, without another MFA prompt
+	again, err := NewAssumeRoleProvider(client, testRole, cache, mfaCode).Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.AccessKeyID != "ASIAEXAMPLE" || len(client.calls) != 1 || len(asked) != 1 {
+		t.Errorf("expected cached credentials, got %+v after %d calls", again, len(client.calls))
+	}
+
+	// another source of credentials has its own entry
+	other := testRole
+	other.Source = "personal"
+	if _, err := NewAssumeRoleProvider(client, other, cache, mfaCode).Retrieve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.calls) != 2 {
+		t.Errorf("expected a new AssumeRole call for another source, got %d calls", len(client.calls))
+	}
+}
+
+func TestAssumeRoleProvider_Errors(t *testing.T) {
+	t.Run("sts failure", func(t *testing.T) {
+		client := &fakeSTS{err: errors.New("AccessDenied")}
+		_, err := NewAssumeRoleProvider(client, AssumeRole{RoleARN: testRole.RoleARN}, nil, nil).Retrieve(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "unable to assume role "+testRole.RoleARN) {
+			t.Errorf("expected the role in the error, got %v", err)
+		}
+	})
+
+	t.Run("mfa code not given", func(t *testing.T) {
+		client := &fakeSTS{}
+		failed := func(string) (string, error) { return "", errors.New("no terminal") }
+		if _, err := NewAssumeRoleProvider(client, testRole, nil, failed).Retrieve(context.Background()); err == nil {
+			t.Error("expected an error")
+		}
+		if len(client.calls) != 0 {
+			t.Errorf("expected STS not to be called, got %d calls", len(client.calls))
+		}
+	})
+}
+
+func TestCredentialCache(t *testing.T) {
+	cache := NewCredentialCache(t.TempDir())
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	if _, ok := cache.Load("key"); ok {
+		t.Fatal("expected an empty cache")
+	}
+	if err := cache.Save("key", aws.Credentials{AccessKeyID: "AKIA", Expires: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if creds, ok := cache.Load("key"); !ok || creds.AccessKeyID != "AKIA" || !creds.CanExpire {
+		t.Errorf("expected the saved credentials, got %+v", creds)
+	}
+
+	now = now.Add(56 * time.Minute)
+	if _, ok := cache.Load("key"); ok {
+		t.Error("expected credentials about to expire to be ignored")
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(-time.Hour)
+	if _, ok := cache.Load("key"); ok {
+		t.Error("expected Clear to remove the credentials")
+	}
+}
+
+func TestAssumeRole_Validate(t *testing.T) {
+	if err := testRole.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, role := range []AssumeRole{
+		{RoleARN: "bedrock-user"},
+		{RoleARN: "arn:aws:iam::123456789012:user/me"},
+		{RoleARN: testRole.RoleARN, Duration: time.Minute},
+		{RoleARN: testRole.RoleARN, Duration: 24 * time.Hour},
+	} {
+		if err := role.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", role)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package auth gets the AWS credentials chat-cli calls Bedrock with when
+// they take more than the SDK's default chain, such as assuming a role.
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// expiryWindow is how long before they expire cached credentials stop
+// being used, so a request never starts with credentials about to lapse.
+const expiryWindow = 5 * time.Minute
+
+// CredentialCache keeps temporary credentials on disk, one file per key,
+// so each run doesn't call STS again - or ask for another MFA code - while
+// the last credentials are still good.
+type CredentialCache struct {
+	dir string
+	now func() time.Time
+}
+
+// cachedCredentials is a cache file's contents.
+type cachedCredentials struct {
+	AccessKeyID     string    `json:"access_key_id"`
+	SecretAccessKey string    `json:"secret_access_key"`
+	SessionToken    string    `json:"session_token"`
+	AccountID       string    `json:"account_id,omitempty"`
+	Expires         time.Time `json:"expires"`
+}
+
+// NewCredentialCache creates a cache that keeps its files in dir, which is
+// created when first needed.
+func NewCredentialCache(dir string) *CredentialCache {
+	return &CredentialCache{dir: dir, now: time.Now}
+}
+
+// Dir returns the directory the cache keeps its files in.
+func (c *CredentialCache) Dir() string {
+	return c.dir
+}
+
+func (c *CredentialCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// Load returns the credentials cached under key, if there are any that
+// won't expire within expiryWindow.
+func (c *CredentialCache) Load(key string) (aws.Credentials, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+	var cached cachedCredentials
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return aws.Credentials{}, false
+	}
+	if cached.AccessKeyID == "" || !cached.Expires.After(c.now().Add(expiryWindow)) {
+		return aws.Credentials{}, false
+	}
+	return aws.Credentials{
+		AccessKeyID:     cached.AccessKeyID,
+		SecretAccessKey: cached.SecretAccessKey,
+		SessionToken:    cached.SessionToken,
+		AccountID:       cached.AccountID,
+		Source:          "chat-cli credential cache",
+		CanExpire:       true,
+		Expires:         cached.Expires,
+	}, true
+}
+
+// Save caches creds under key. The file is readable only by the user.
+func (c *CredentialCache) Save(key string, creds aws.Credentials) error {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("unable to create credential cache: %w", err)
+	}
+	data, err := json.Marshal(cachedCredentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		AccountID:       creds.AccountID,
+		Expires:         creds.Expires,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path(key), data, 0o600); err != nil {
+		return fmt.Errorf("unable to cache credentials: %w", err)
+	}
+	return nil
+}
+
+// Clear removes every cached credential.
+func (c *CredentialCache) Clear() error {
+	if err := os.RemoveAll(c.dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to clear credential cache: %w", err)
+	}
+	return nil
+}
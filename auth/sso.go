@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // the SDK names SSO token cache files by SHA-1
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+const (
+	// deviceCodeGrant is the OAuth grant for the device authorization flow.
+	deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+	// accountAccessScope lets the token list accounts and get role
+	// credentials, and gets a refresh token with it.
+	accountAccessScope = "sso:account:access"
+	// defaultPollInterval is how often the token is asked for while the
+	// user signs in, when StartDeviceAuthorization doesn't say.
+	defaultPollInterval = 5 * time.Second
+	// slowDownStep is added to the interval when IAM Identity Center asks
+	// for polling to slow down.
+	slowDownStep = 5 * time.Second
+)
+
+// SSOOIDCClient is the part of the IAM Identity Center OIDC API SSOLogin
+// uses.
+type SSOOIDCClient interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// SSOToken is an IAM Identity Center access token, stored in the same
+// format as the AWS SDKs' SSO token cache so ssocreds can read and refresh
+// it.
+type SSOToken struct {
+	StartURL              string `json:"startUrl"`
+	Region                string `json:"region"`
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+}
+
+// Expires returns when the access token expires.
+func (t *SSOToken) Expires() time.Time {
+	expires, _ := time.Parse(time.RFC3339, t.ExpiresAt)
+	return expires
+}
+
+// DeviceAuthorization is what the user needs to approve a sign-in: a page
+// to open and the code it should show.
+type DeviceAuthorization struct {
+	UserCode string
+	// VerificationURI is the sign-in page; VerificationURIComplete is the
+	// same page with the code filled in.
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+}
+
+// SSOLogin signs in to the IAM Identity Center instance at startURL with
+// the device authorization flow: it registers chat-cli as a client, hands
+// the page and code for the user to approve to prompt, and polls until
+// they do. sleep waits between polls; nil uses a timer.
+func SSOLogin(ctx context.Context, client SSOOIDCClient, startURL, region string, prompt func(DeviceAuthorization), sleep func(context.Context, time.Duration) error) (*SSOToken, error) {
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	registration, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String("chat-cli"),
+		ClientType: aws.String("public"),
+		Scopes:     []string{accountAccessScope},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to register with IAM Identity Center: %w", err)
+	}
+
+	device, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     registration.ClientId,
+		ClientSecret: registration.ClientSecret,
+		StartUrl:     aws.String(startURL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to start sign-in at %s: %w", startURL, err)
+	}
+
+	expiresIn := time.Duration(device.ExpiresIn) * time.Second
+	prompt(DeviceAuthorization{
+		UserCode:                aws.ToString(device.UserCode),
+		VerificationURI:         aws.ToString(device.VerificationUri),
+		VerificationURIComplete: aws.ToString(device.VerificationUriComplete),
+		ExpiresIn:               expiresIn,
+	})
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	// without an expiry from the service, wait as long as the context lets
+	var waited time.Duration
+	for expiresIn <= 0 || waited < expiresIn {
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+		waited += interval
+
+		out, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     registration.ClientId,
+			ClientSecret: registration.ClientSecret,
+			GrantType:    aws.String(deviceCodeGrant),
+			DeviceCode:   device.DeviceCode,
+		})
+		var pending *ssooidctypes.AuthorizationPendingException
+		var slowDown *ssooidctypes.SlowDownException
+		var denied *ssooidctypes.AccessDeniedException
+		switch {
+		case errors.As(err, &pending):
+			continue
+		case errors.As(err, &slowDown):
+			interval += slowDownStep
+			continue
+		case errors.As(err, &denied):
+			return nil, errors.New("the sign-in request was denied")
+		case err != nil:
+			return nil, fmt.Errorf("unable to sign in: %w", err)
+		}
+
+		now := time.Now().UTC()
+		token := &SSOToken{
+			StartURL:     startURL,
+			Region:       region,
+			AccessToken:  aws.ToString(out.AccessToken),
+			ExpiresAt:    now.Add(time.Duration(out.ExpiresIn) * time.Second).Format(time.RFC3339),
+			RefreshToken: aws.ToString(out.RefreshToken),
+			ClientID:     aws.ToString(registration.ClientId),
+			ClientSecret: aws.ToString(registration.ClientSecret),
+		}
+		if registration.ClientSecretExpiresAt > 0 {
+			token.RegistrationExpiresAt = time.Unix(registration.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339)
+		}
+		return token, nil
+	}
+	return nil, errors.New("the sign-in request expired before it was approved")
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SSOTokenPath is where the token for startURL is kept in dir. Files are
+// named as the SDKs name them in ~/.aws/sso/cache.
+func SSOTokenPath(dir, startURL string) string {
+	sum := sha1.Sum([]byte(startURL)) //nolint:gosec // a file name, not a security boundary
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// SaveSSOToken writes token to path, readable only by the current user.
+func SaveSSOToken(path string, token *SSOToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to create SSO token cache: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to save SSO token: %w", err)
+	}
+	return nil
+}
+
+// LoadSSOToken reads the token saved at path.
+func LoadSSOToken(path string) (*SSOToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var token SSOToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("unable to read SSO token %s: %w", path, err)
+	}
+	return &token, nil
+}
+
+// SSOClient is the part of the IAM Identity Center portal API SSORoles
+// uses.
+type SSOClient interface {
+	sso.ListAccountsAPIClient
+	sso.ListAccountRolesAPIClient
+}
+
+// SSORole is a role the signed-in user can use in an account.
+type SSORole struct {
+	AccountID   string
+	AccountName string
+	RoleName    string
+}
+
+// SSORoles lists every role in every account accessToken can use, sorted
+// by account name and then role.
+func SSORoles(ctx context.Context, client SSOClient, accessToken string) ([]SSORole, error) {
+	var roles []SSORole
+	accounts := sso.NewListAccountsPaginator(client, &sso.ListAccountsInput{AccessToken: aws.String(accessToken)})
+	for accounts.HasMorePages() {
+		page, err := accounts.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list accounts: %w", err)
+		}
+		for _, account := range page.AccountList {
+			accountRoles := sso.NewListAccountRolesPaginator(client, &sso.ListAccountRolesInput{
+				AccessToken: aws.String(accessToken),
+				AccountId:   account.AccountId,
+			})
+			for accountRoles.HasMorePages() {
+				rolePage, err := accountRoles.NextPage(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("unable to list roles in account %s: %w", aws.ToString(account.AccountId), err)
+				}
+				for _, role := range rolePage.RoleList {
+					roles = append(roles, SSORole{
+						AccountID:   aws.ToString(account.AccountId),
+						AccountName: aws.ToString(account.AccountName),
+						RoleName:    aws.ToString(role.RoleName),
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].AccountName != roles[j].AccountName {
+			return roles[i].AccountName < roles[j].AccountName
+		}
+		return roles[i].RoleName < roles[j].RoleName
+	})
+	return roles, nil
+}
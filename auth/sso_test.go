@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+// fakeOIDC approves the sign-in after answering CreateToken with each of
+// pending in turn.
+type fakeOIDC struct {
+	pending []error
+	calls   int
+}
+
+func (f *fakeOIDC) RegisterClient(_ context.Context, _ *ssooidc.RegisterClientInput, _ ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	return &ssooidc.RegisterClientOutput{ClientId: aws.String("client"), ClientSecret: aws.String("secret"), ClientSecretExpiresAt: 1900000000}, nil
+}
+
+func (f *fakeOIDC) StartDeviceAuthorization(_ context.Context, _ *ssooidc.StartDeviceAuthorizationInput, _ ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:              aws.String("device"),
+		UserCode:                aws.String("ABCD-EFGH"),
+		VerificationUri:         aws.String("https://device.sso.us-east-1.amazonaws.com/"),
+		VerificationUriComplete: aws.String("https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH"),
+		ExpiresIn:               600,
+		Interval:                1,
+	}, nil
+}
+
+func (f *fakeOIDC) CreateToken(_ context.Context, in *ssooidc.CreateTokenInput, _ ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	f.calls++
+	if aws.ToString(in.GrantType) != deviceCodeGrant || aws.ToString(in.DeviceCode) != "device" {
+		return nil, &ssooidctypes.InvalidGrantException{}
+	}
+	if len(f.pending) > 0 {
+		err := f.pending[0]
+		f.pending = f.pending[1:]
+		return nil, err
+	}
+	return &ssooidc.CreateTokenOutput{AccessToken: aws.String("access"), RefreshToken: aws.String("refresh"), ExpiresIn: 3600}, nil
+}
+
+func TestSSOLogin(t *testing.T) {
+	client := &fakeOIDC{pending: []error{&ssooidctypes.AuthorizationPendingException{}, &ssooidctypes.SlowDownException{}}}
+	var waits []time.Duration
+	sleep := func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	var shown DeviceAuthorization
+
+	token, err := SSOLogin(t.Context(), client, "https://my-org.awsapps.com/start", "us-east-1", func(d DeviceAuthorization) { shown = d }, sleep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shown.UserCode != "ABCD-EFGH" || !strings.Contains(shown.VerificationURIComplete, "user_code=ABCD-EFGH") {
+		t.Errorf("expected the code and page shown, got %+v", shown)
+	}
+	// polling slows down when asked to
+	if client.calls != 3 || len(waits) != 3 || waits[2] != 6*time.Second {
+		t.Errorf("expected 3 polls with the last 6s apart, got %d calls, waits %v", client.calls, waits)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || token.ClientID != "client" || token.RegistrationExpiresAt == "" {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if until := time.Until(token.Expires()); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("expected the token to last an hour, got %v", until)
+	}
+}
+
+func TestSSOLoginDenied(t *testing.T) {
+	client := &fakeOIDC{pending: []error{&ssooidctypes.AccessDeniedException{}}}
+	noWait := func(context.Context, time.Duration) error { return nil }
+	if _, err := SSOLogin(t.Context(), client, "https://my-org.awsapps.com/start", "us-east-1", func(DeviceAuthorization) {}, noWait); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected a denied sign-in, got %v", err)
+	}
+}
+
+func TestSSOLoginExpires(t *testing.T) {
+	pending := make([]error, 700)
+	for i := range pending {
+		pending[i] = &ssooidctypes.AuthorizationPendingException{}
+	}
+	noWait := func(context.Context, time.Duration) error { return nil }
+	if _, err := SSOLogin(t.Context(), &fakeOIDC{pending: pending}, "https://my-org.awsapps.com/start", "us-east-1", func(DeviceAuthorization) {}, noWait); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected the request to expire, got %v", err)
+	}
+}
+
+type fakeRoleCredentials struct {
+	accessToken string
+}
+
+func (f *fakeRoleCredentials) GetRoleCredentials(_ context.Context, in *sso.GetRoleCredentialsInput, _ ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+	f.accessToken = aws.ToString(in.AccessToken)
+	return &sso.GetRoleCredentialsOutput{RoleCredentials: &ssotypes.RoleCredentials{
+		AccessKeyId:     aws.String("AKID"),
+		SecretAccessKey: aws.String("SECRET"),
+		SessionToken:    aws.String("SESSION"),
+		Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+	}}, nil
+}
+
+func TestSSOTokenReadBySDK(t *testing.T) {
+	startURL := "https://my-org.awsapps.com/start"
+	path := SSOTokenPath(t.TempDir(), startURL)
+	token := &SSOToken{StartURL: startURL, Region: "us-east-1", AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}
+	if err := SaveSSOToken(path, token); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a private token file, got %v, %v", info, err)
+	}
+	// named as the SDK would name it in ~/.aws/sso/cache
+	if got := filepath.Base(path); got != "acff06c7037450e5a3fddcacb0a34e921da42d68.json" {
+		t.Errorf("expected the file named by the start URL's SHA-1, got %s", got)
+	}
+
+	loaded, err := LoadSSOToken(path)
+	if err != nil || *loaded != *token {
+		t.Fatalf("LoadSSOToken = %+v, %v", loaded, err)
+	}
+
+	// the SDK's SSO provider takes credentials with the saved token
+	client := &fakeRoleCredentials{}
+	provider := ssocreds.New(client, "123456789012", "BedrockUser", startURL, func(o *ssocreds.Options) {
+		o.CachedTokenFilepath = path
+	})
+	creds, err := provider.Retrieve(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "AKID" || client.accessToken != "access" {
+		t.Errorf("expected credentials for the saved token, got %+v with token %q", creds, client.accessToken)
+	}
+}
+
+type fakeSSOPortal struct{}
+
+func (fakeSSOPortal) ListAccounts(_ context.Context, _ *sso.ListAccountsInput, _ ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+	return &sso.ListAccountsOutput{AccountList: []ssotypes.AccountInfo{
+		{AccountId: aws.String("222222222222"), AccountName: aws.String("Sandbox")},
+		{AccountId: aws.String("111111111111"), AccountName: aws.String("Production")},
+	}}, nil
+}
+
+func (fakeSSOPortal) ListAccountRoles(_ context.Context, in *sso.ListAccountRolesInput, _ ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	if aws.ToString(in.AccountId) == "111111111111" {
+		return &sso.ListAccountRolesOutput{RoleList: []ssotypes.RoleInfo{{RoleName: aws.String("ReadOnly")}}}, nil
+	}
+	return &sso.ListAccountRolesOutput{RoleList: []ssotypes.RoleInfo{{RoleName: aws.String("Developer")}, {RoleName: aws.String("Admin")}}}, nil
+}
+
+func TestSSORoles(t *testing.T) {
+	roles, err := SSORoles(t.Context(), fakeSSOPortal{}, "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SSORole{
+		{AccountID: "111111111111", AccountName: "Production", RoleName: "ReadOnly"},
+		{AccountID: "222222222222", AccountName: "Sandbox", RoleName: "Admin"},
+		{AccountID: "222222222222", AccountName: "Sandbox", RoleName: "Developer"},
+	}
+	if len(roles) != len(want) {
+		t.Fatalf("expected %d roles, got %+v", len(want), roles)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Errorf("role %d = %+v, want %+v", i, roles[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// defaultBatchConcurrency is how many requests a batch run sends at
+	// once until it's throttled.
+	defaultBatchConcurrency = 4
+	// maxThrottleRetries is how many times a throttled request is tried
+	// again before the run gives up on it.
+	maxThrottleRetries = 8
+	// throttleBackoff and maxThrottleBackoff bound the wait before a
+	// throttled request is retried, which doubles with each attempt.
+	throttleBackoff    = 500 * time.Millisecond
+	maxThrottleBackoff = 20 * time.Second
+)
+
+// isThrottlingError reports whether err is Bedrock refusing a request
+// because too many are being sent.
+func isThrottlingError(err error) bool {
+	var throttling *types.ThrottlingException
+	if errors.As(err, &throttling) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "TooManyRequestsException":
+			return true
+		}
+	}
+	return false
+}
+
+// adaptiveLimiter bounds how many requests are in flight, adjusting the
+// bound AIMD-style: each throttled request halves it, and each request that
+// succeeds raises it by 1/limit, so it grows by about one per round of
+// requests, up to max.
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    float64
+	max      int
+	inFlight int
+	// epoch counts decreases. Requests started before the latest one were
+	// sent at the old limit, so their throttles don't halve it again.
+	epoch int
+
+	minSeen   int
+	throttles int
+}
+
+func newAdaptiveLimiter(concurrency int) *adaptiveLimiter {
+	concurrency = max(concurrency, 1)
+	l := &adaptiveLimiter{limit: float64(concurrency), max: concurrency, minSeen: concurrency}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire waits for room under the limit and returns the epoch to pass to
+// release.
+func (l *adaptiveLimiter) acquire() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inFlight >= int(l.limit) {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	return l.epoch
+}
+
+// release ends a request started in epoch, adjusting the limit by whether
+// it was throttled.
+func (l *adaptiveLimiter) release(epoch int, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+
+	if throttled {
+		l.throttles++
+		if epoch == l.epoch {
+			l.limit = max(1, l.limit/2)
+			l.epoch++
+			l.minSeen = min(l.minSeen, int(l.limit))
+		}
+	} else {
+		l.limit = min(float64(l.max), l.limit+1/l.limit)
+	}
+	l.cond.Broadcast()
+}
+
+// abandon gives back a slot from acquire that wasn't used.
+func (l *adaptiveLimiter) abandon() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.cond.Broadcast()
+}
+
+// current returns the limit as a whole number of requests.
+func (l *adaptiveLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// batchReport describes how a batch run went.
+type batchReport struct {
+	requests    int
+	elapsed     time.Duration
+	throttles   int
+	concurrency int
+	minLimit    int
+	finalLimit  int
+}
+
+// summary describes the run's throughput, and how throttling limited it if
+// it did.
+func (r batchReport) summary() string {
+	rate := 0.0
+	if r.elapsed > 0 {
+		rate = float64(r.requests) / r.elapsed.Seconds()
+	}
+
+	s := fmt.Sprintf("%d request(s) in %s (%.1f/s)", r.requests, r.elapsed.Round(100*time.Millisecond), rate)
+	if r.throttles == 0 {
+		return s + fmt.Sprintf(", %d at a time", r.concurrency)
+	}
+	return s + fmt.Sprintf(", throttled %d time(s): concurrency fell to %d of %d and ended at %d", r.throttles, r.minLimit, r.concurrency, r.finalLimit)
+}
+
+// runAdaptive calls do for each of n requests, up to concurrency at a
+// time, narrowing and widening concurrency as requests are throttled. A
+// throttled request is retried after a backoff rather than failed. The
+// first other error stops the run and is returned once the requests in
+// flight finish.
+func runAdaptive(ctx context.Context, n, concurrency int, do func(ctx context.Context, i int) error) (batchReport, error) {
+	limiter := newAdaptiveLimiter(concurrency)
+	report := batchReport{requests: n, concurrency: limiter.max}
+	start := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < n; i++ {
+		epoch := limiter.acquire()
+		if ctx.Err() != nil {
+			limiter.abandon()
+			break
+		}
+
+		wg.Add(1)
+		go func(i, epoch int) {
+			defer wg.Done()
+			for attempt := 0; ; attempt++ {
+				err := do(ctx, i)
+				throttled := isThrottlingError(err)
+				if err != nil && (!throttled || attempt >= maxThrottleRetries) {
+					// stop the run before freeing the slot, so no more
+					// requests start
+					fail(err)
+					limiter.release(epoch, throttled)
+					return
+				}
+				limiter.release(epoch, throttled)
+				if err == nil {
+					return
+				}
+
+				select {
+				case <-time.After(retryDelay(attempt)):
+				case <-ctx.Done():
+					return
+				}
+				epoch = limiter.acquire()
+			}
+		}(i, epoch)
+	}
+	wg.Wait()
+
+	report.elapsed = time.Since(start)
+	report.throttles = limiter.throttles
+	report.minLimit = limiter.minSeen
+	report.finalLimit = limiter.current()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return report, firstErr
+}
+
+// retryDelay is throttleDelay, replaced in tests to keep them fast.
+var retryDelay = throttleDelay
+
+// throttleDelay is the wait before retry attempt+1 of a throttled request:
+// exponential backoff with full jitter.
+func throttleDelay(attempt int) time.Duration {
+	ceiling := min(throttleBackoff<<attempt, maxThrottleBackoff)
+	return time.Duration(rand.Int64N(int64(ceiling))) + time.Millisecond
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestIsThrottlingError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"typed", fmt.Errorf("error from Bedrock, %w", &types.ThrottlingException{Message: aws.String("Too many requests")}), true},
+		{"generic API error", &smithy.GenericAPIError{Code: "TooManyRequestsException"}, true},
+		{"other API error", &types.ValidationException{Message: aws.String("bad input")}, false},
+		{"plain error", errors.New("throttled"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isThrottlingError(tt.err); got != tt.want {
+				t.Errorf("isThrottlingError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(8)
+
+	// two throttles from the same round halve the limit once
+	a, b := l.acquire(), l.acquire()
+	l.release(a, true)
+	l.release(b, true)
+	if got := l.current(); got != 4 {
+		t.Fatalf("expected the limit to halve once to 4, got %d", got)
+	}
+
+	// a throttle after the decrease halves it again
+	l.release(l.acquire(), true)
+	if got := l.current(); got != 2 {
+		t.Fatalf("expected 2, got %d", got)
+	}
+
+	// successes raise it by about one per round, back up to the maximum
+	for i := 0; i < 3; i++ {
+		l.release(l.acquire(), false)
+	}
+	if got := l.current(); got != 3 {
+		t.Errorf("expected 3 after a round of successes, got %d", got)
+	}
+	for i := 0; i < 100; i++ {
+		l.release(l.acquire(), false)
+	}
+	if got := l.current(); got != 8 {
+		t.Errorf("expected the limit to stop at 8, got %d", got)
+	}
+	if l.throttles != 3 || l.minSeen != 2 {
+		t.Errorf("expected 3 throttles and a low of 2, got %d and %d", l.throttles, l.minSeen)
+	}
+
+	// it never drops below one
+	l = newAdaptiveLimiter(1)
+	l.release(l.acquire(), true)
+	if got := l.current(); got != 1 {
+		t.Errorf("expected a floor of 1, got %d", got)
+	}
+}
+
+func TestRunAdaptive(t *testing.T) {
+	saved := retryDelay
+	retryDelay = func(int) time.Duration { return time.Millisecond }
+	defer func() { retryDelay = saved }()
+
+	t.Run("retries throttled requests and backs off", func(t *testing.T) {
+		var mu sync.Mutex
+		attempts := map[int]int{}
+		var inFlight, peak int32
+
+		report, err := runAdaptive(context.Background(), 20, 6, func(ctx context.Context, i int) error {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			attempts[i]++
+			// the first try of every third request is throttled
+			if i%3 == 0 && attempts[i] == 1 {
+				return &types.ThrottlingException{Message: aws.String("slow down")}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(attempts) != 20 {
+			t.Errorf("expected every request to run, got %d", len(attempts))
+		}
+		for i := 0; i < 20; i += 3 {
+			if attempts[i] != 2 {
+				t.Errorf("expected request %d to be retried once, got %d attempts", i, attempts[i])
+			}
+		}
+		if peak > 6 {
+			t.Errorf("expected at most 6 requests at once, saw %d", peak)
+		}
+		if report.requests != 20 || report.throttles != 7 || report.minLimit >= 6 {
+			t.Errorf("unexpected report %+v", report)
+		}
+		if summary := report.summary(); !strings.Contains(summary, "throttled 7 time(s)") {
+			t.Errorf("unexpected summary %q", summary)
+		}
+	})
+
+	t.Run("stops on other errors", func(t *testing.T) {
+		var calls int32
+		_, err := runAdaptive(context.Background(), 50, 1, func(ctx context.Context, i int) error {
+			atomic.AddInt32(&calls, 1)
+			if i == 2 {
+				return errors.New("bad input")
+			}
+			return nil
+		})
+		if err == nil || err.Error() != "bad input" {
+			t.Errorf("expected the error to be returned, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected the run to stop after the failure, got %d calls", calls)
+		}
+	})
+
+	t.Run("gives up on a request that's always throttled", func(t *testing.T) {
+		var calls int32
+		_, err := runAdaptive(context.Background(), 1, 2, func(ctx context.Context, i int) error {
+			atomic.AddInt32(&calls, 1)
+			return &types.ThrottlingException{Message: aws.String("slow down")}
+		})
+		if !isThrottlingError(err) {
+			t.Errorf("expected the throttling error, got %v", err)
+		}
+		if calls != maxThrottleRetries+1 {
+			t.Errorf("expected %d attempts, got %d", maxThrottleRetries+1, calls)
+		}
+	})
+
+	t.Run("summary without throttling", func(t *testing.T) {
+		report := batchReport{requests: 10, elapsed: 2 * time.Second, concurrency: 4}
+		if got := report.summary(); got != "10 request(s) in 2s (5.0/s), 4 at a time" {
+			t.Errorf("unexpected summary %q", got)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+// agentInstructions is added to the system prompt for /agent runs, unless
+// the agent-prompt setting replaces it.
+const agentInstructions = `You are working autonomously on a single task handed over from an interactive chat. Use the available tools to investigate and make changes as needed, without asking the user questions. When you're done, reply with a concise summary of what you found or changed.`
+
+// runAgentCommand hands task to an autonomous tool-use run with a fresh
+// context, showing each tool step as it happens, then adds the task and the
+// agent's summary to the chat so the conversation can pick up from there.
+func runAgentCommand(s *chatSession, task string) error {
+	if task == "" {
+		return fmt.Errorf("usage: /agent <task>")
+	}
+	if s.runTurn == nil {
+		return fmt.Errorf("/agent is not available in this session")
+	}
+
+	systemPrompt, err := buildAgentInstructions(s.agentPrompt, s.agentPromptAppend, agentPromptVars(aws.ToString(s.input.ModelId)))
+	if err != nil {
+		return err
+	}
+	if s.systemPrompt != "" {
+		systemPrompt = s.systemPrompt + "\n\n" + systemPrompt
+	}
+
+	agentInput := &bedrockruntime.ConverseStreamInput{
+		ModelId:                      s.input.ModelId,
+		InferenceConfig:              s.input.InferenceConfig,
+		RequestMetadata:              s.input.RequestMetadata,
+		AdditionalModelRequestFields: s.input.AdditionalModelRequestFields,
+		System:                       withSystemCachePoint(buildSystemContentBlocks(systemPrompt)),
+		Messages:                     []types.Message{userTextMessage(task)},
+	}
+
+	fmt.Fprintf(s.out, "\033[90m[agent] working on: %s\033[0m\n", task)
+
+	// every step is recorded for agent export, including the reasoning
+	// that isn't shown
+	recorder := newAgentRecorder(s.chatId, aws.ToString(s.input.ModelId), task, time.Now())
+	var usageBefore tokenUsage
+	var costBefore float64
+	if s.usage != nil {
+		usageBefore, costBefore = s.usage.session, s.usage.cost
+	}
+
+	callbacks := turnCallbacks{
+		onText: func(ctx context.Context, part string) error {
+			fmt.Fprint(s.out, part)
+			return recorder.onText(ctx, part)
+		},
+		onReasoning: recorder.onReasoning,
+		onToolCall: func(call tools.ToolCall) {
+			fmt.Fprintf(s.out, "\033[90m[agent] %s\033[0m\n", toolCallLine(call))
+			recorder.onToolCall(call)
+		},
+		onToolResult: recorder.onToolResult,
+	}
+
+	result, err := s.runTurn(s.context(), agentInput, callbacks)
+
+	var used tokenUsage
+	var cost *float64
+	if s.usage != nil {
+		used = s.usage.session
+		used.sub(usageBefore)
+		if !s.usage.unpriced[aws.ToString(s.input.ModelId)] {
+			c := s.usage.cost - costBefore
+			cost = &c
+		}
+	}
+	s.saveAgentRun(recorder.finish(time.Now(), result, err, used, cost))
+
+	if err != nil {
+		return fmt.Errorf("agent run failed: %w", err)
+	}
+	fmt.Fprintln(s.out)
+
+	// the chat sees the task and its outcome, not the agent's tool steps
+	request := "[/agent] " + task
+	s.input.Messages = append(s.input.Messages,
+		userTextMessage(request),
+		types.Message{
+			Role:    types.ConversationRoleAssistant,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: result.Text}},
+		},
+	)
+	s.record("User", request)
+	s.recordReply(result.Text, result.StopReason, result.Resumes)
+
+	return nil
+}
+
+// saveAgentRun keeps run for agent export, telling the user its ID. A run
+// that can't be saved isn't an error; the chat still has its outcome.
+func (s *chatSession) saveAgentRun(run *agentRun) {
+	if s.agentRunsDir == "" {
+		return
+	}
+	if err := saveAgentRun(s.agentRunsDir, run); err != nil {
+		fmt.Fprintf(s.out, "\033[90m[agent] unable to save the run for export: %v\033[0m\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "\033[90m[agent] export with: chat-cli agent export --session-id %s\033[0m\n", run.ID)
+}
+
+func userTextMessage(text string) types.Message {
+	return types.Message{
+		Role:    types.ConversationRoleUser,
+		Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: text}},
+	}
+}
+
+func assistantTextMessage(text string) types.Message {
+	return types.Message{
+		Role:    types.ConversationRoleAssistant,
+		Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: text}},
+	}
+}
+
+// record persists one message of the session's conversation, logging
+// rather than failing if it can't be saved.
+func (s *chatSession) record(persona, message string) {
+	if s.chatRepo == nil {
+		return
+	}
+
+	chat := &repository.Chat{
+		ChatId:  s.chatId,
+		Persona: persona,
+		Message: message,
+	}
+	if err := s.chatRepo.Create(s.context(), chat); err != nil {
+		log.Printf("Failed to create chat: %v", err)
+	}
+}
+
+// recordReply persists an assistant reply along with why the model
+// stopped and how many times its stream was resumed, so truncated or
+// filtered replies can be found later.
+func (s *chatSession) recordReply(message string, stopReason types.StopReason, resumes int) {
+	if s.chatRepo == nil {
+		return
+	}
+
+	chat := &repository.Chat{
+		ChatId:     s.chatId,
+		Persona:    "Assistant",
+		Message:    message,
+		StopReason: string(stopReason),
+		Resumes:    resumes,
+	}
+	if err := s.chatRepo.Create(s.context(), chat); err != nil {
+		log.Printf("Failed to create chat: %v", err)
+		return
+	}
+	s.lastReplyID = chat.ID
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "agent",
+		usage:       "<task>",
+		description: "Hand a task to an autonomous tool-using run, then continue chatting",
+		run:         runAgentCommand,
+	})
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func TestRunAgentCommand(t *testing.T) {
+	t.Run("runs the task in a fresh context and merges the outcome", func(t *testing.T) {
+		s, out := newTestChatSession()
+		s.systemPrompt = "Be terse."
+
+		var agentInput *bedrockruntime.ConverseStreamInput
+		s.runTurn = func(_ context.Context, input *bedrockruntime.ConverseStreamInput, callbacks turnCallbacks) (chatTurnResult, error) {
+			agentInput = input
+			callbacks.onToolCall(tools.ToolCall{Name: "read_file", Input: json.RawMessage(`{"path": "go.mod"}`)})
+			_ = callbacks.onText(context.Background(), "The module is chat-cli.")
+			return chatTurnResult{Text: "The module is chat-cli."}, nil
+		}
+
+		if _, err := dispatchSlashCommand(s, "/agent find the module name"); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(agentInput.Messages) != 1 || messageText(agentInput.Messages[0]) != "find the module name" {
+			t.Errorf("expected the agent to see only the task, got %v", agentInput.Messages)
+		}
+		var system strings.Builder
+		for _, block := range agentInput.System {
+			if text, ok := block.(*types.SystemContentBlockMemberText); ok {
+				system.WriteString(text.Value)
+			}
+		}
+		if !strings.HasPrefix(system.String(), "Be terse.") || !strings.Contains(system.String(), agentInstructions) {
+			t.Errorf("expected session system prompt plus agent instructions, got %q", system.String())
+		}
+
+		if !strings.Contains(out.String(), `[agent] → read_file {"path": "go.mod"}`) {
+			t.Errorf("expected the tool step to be shown, got %q", out.String())
+		}
+
+		// two original messages plus the task and its outcome
+		if len(s.input.Messages) != 4 {
+			t.Fatalf("expected 4 messages in the chat, got %d", len(s.input.Messages))
+		}
+		if got := messageText(s.input.Messages[2]); got != "[/agent] find the module name" {
+			t.Errorf("unexpected merged task message %q", got)
+		}
+		if got := messageText(s.input.Messages[3]); got != "The module is chat-cli." {
+			t.Errorf("unexpected merged outcome %q", got)
+		}
+	})
+
+	t.Run("uses the configured agent prompt", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.agentPrompt = "You are a release engineer."
+		s.agentPromptAppend = "Today is {{date}}."
+
+		var agentInput *bedrockruntime.ConverseStreamInput
+		s.runTurn = func(_ context.Context, input *bedrockruntime.ConverseStreamInput, _ turnCallbacks) (chatTurnResult, error) {
+			agentInput = input
+			return chatTurnResult{Text: "Tagged."}, nil
+		}
+		if _, err := dispatchSlashCommand(s, "/agent tag the release"); err != nil {
+			t.Fatal(err)
+		}
+
+		system := agentInput.System[0].(*types.SystemContentBlockMemberText).Value
+		if strings.Contains(system, agentInstructions) || !strings.HasPrefix(system, "You are a release engineer.\n\nToday is 20") {
+			t.Errorf("expected the configured prompt with variables filled in, got %q", system)
+		}
+	})
+
+	t.Run("failed run leaves the chat untouched", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.runTurn = func(context.Context, *bedrockruntime.ConverseStreamInput, turnCallbacks) (chatTurnResult, error) {
+			return chatTurnResult{}, errors.New("throttled")
+		}
+
+		if _, err := dispatchSlashCommand(s, "/agent do it"); err == nil {
+			t.Error("expected the run's error to be returned")
+		}
+		if len(s.input.Messages) != 2 {
+			t.Errorf("expected the chat to be unchanged, got %d messages", len(s.input.Messages))
+		}
+	})
+
+	t.Run("runs are saved for export", func(t *testing.T) {
+		s, out := newTestChatSession()
+		s.agentRunsDir = t.TempDir()
+		s.runTurn = func(_ context.Context, _ *bedrockruntime.ConverseStreamInput, callbacks turnCallbacks) (chatTurnResult, error) {
+			_ = callbacks.onReasoning(context.Background(), "Start with go.mod.")
+			_ = callbacks.onText(context.Background(), "Done.")
+			return chatTurnResult{Text: "Done."}, nil
+		}
+
+		if _, err := dispatchSlashCommand(s, "/agent find the module name"); err != nil {
+			t.Fatal(err)
+		}
+		runs, err := listAgentRuns(s.agentRunsDir)
+		if err != nil || len(runs) != 1 {
+			t.Fatalf("saved runs = %v, %v", runs, err)
+		}
+		if runs[0].Task != "find the module name" || runs[0].Steps[0].Reasoning != "Start with go.mod." {
+			t.Errorf("saved run = %+v", runs[0])
+		}
+		if !strings.Contains(out.String(), "agent export --session-id "+runs[0].ID) {
+			t.Errorf("expected the export command to be shown, got %q", out.String())
+		}
+	})
+
+	t.Run("requires a task", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		if _, err := dispatchSlashCommand(s, "/agent"); err == nil {
+			t.Error("expected a usage error")
+		}
+	})
+}
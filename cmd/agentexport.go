@@ -0,0 +1,259 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter" //nolint:goimports // false positive from CI version diff
+	"time"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/utils"
+	"github.com/spf13/cobra"
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Review what /agent runs did",
+	Long: `Review what /agent runs in chat did. Each run is saved with its task, every
+step the model took (its reasoning, its replies, and each tool call with its
+input and result), how it ended, how long it took, and what it cost.
+
+> chat-cli agent list
+> chat-cli agent export --session-id <id> -o run.md`,
+}
+
+// agentListCmd represents the agent list command
+var agentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved /agent runs, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, err := listAgentRuns(agentRunsDir())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(runs) == 0 {
+			fmt.Println("No agent runs yet; start one in chat with /agent <task>")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTARTED\tDURATION\tSTEPS\tTASK")
+		for _, run := range runs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", run.ID, run.Started.Local().Format("2006-01-02 15:04"),
+				formatElapsed(run.duration()), len(run.Steps), truncateRunes(taskTitle(run.Task), 60))
+		}
+		_ = w.Flush()
+	},
+}
+
+// agentExportCmd represents the agent export command
+var agentExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write a report of an /agent run as Markdown or JSON",
+	Long: `Write a report of an /agent run for reviewing what it did: the task, each
+step with the model's reasoning and the tool calls it made (with their input
+and results), the final outcome, and the run's duration, tokens, and cost.
+
+Markdown is meant for reading or pasting into a code review; JSON has the same
+content for tooling. Find a run's ID with chat-cli agent list, or in the line
+printed when it finishes.
+
+> chat-cli agent export --session-id <id>
+> chat-cli agent export --session-id <id> --format json -o run.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := cmd.Flags().GetString("session-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		var write func(io.Writer, *agentRun) error
+		switch format {
+		case "markdown", "md":
+			write = writeAgentRunMarkdown
+		case "json":
+			write = writeAgentRunJSON
+		default:
+			log.Fatalf("--format must be markdown or json")
+		}
+
+		run, err := loadAgentRun(agentRunsDir(), id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if output == "" {
+			if err := write(os.Stdout, run); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		path, err := utils.ValidateOutputPath(output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(path) // #nosec G304 - the user chose where to export
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := write(f, run); err != nil {
+			_ = f.Close()
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported agent run %s to %s\n", run.ID, path)
+	},
+}
+
+func agentRunsDir() string {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return filepath.Join(fm.DataPath, agentRunsDirName)
+}
+
+// agentReport is the JSON form of agent export: the saved run with its
+// duration worked out.
+type agentReport struct {
+	*agentRun
+	DurationMS int64 `json:"duration_ms"`
+}
+
+func writeAgentRunJSON(w io.Writer, run *agentRun) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(agentReport{agentRun: run, DurationMS: run.duration().Milliseconds()})
+}
+
+// writeAgentRunMarkdown writes run as a report for review: a summary, then
+// each step, then how it ended.
+func writeAgentRunMarkdown(w io.Writer, run *agentRun) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Agent run: %s\n\n", taskTitle(run.Task))
+	if strings.Contains(run.Task, "\n") {
+		fmt.Fprintf(&b, "%s\n\n", quoteMarkdown(run.Task))
+	}
+	fmt.Fprintf(&b, "- **Session:** `%s`\n", run.ID)
+	if run.ChatID != "" {
+		fmt.Fprintf(&b, "- **Chat:** `%s`\n", run.ChatID)
+	}
+	fmt.Fprintf(&b, "- **Model:** `%s`\n", run.Model)
+	fmt.Fprintf(&b, "- **Started:** %s\n", run.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- **Duration:** %s\n", formatElapsed(run.duration()))
+	fmt.Fprintf(&b, "- **Tokens:** %d in / %d out\n", run.InputTokens, run.OutputTokens)
+	if run.CostUSD != nil {
+		fmt.Fprintf(&b, "- **Cost:** %s (estimated)\n", formatCost(*run.CostUSD))
+	} else {
+		b.WriteString("- **Cost:** unknown\n")
+	}
+	fmt.Fprintf(&b, "- **Outcome:** %s\n", agentRunStatus(run))
+
+	for i, step := range run.Steps {
+		fmt.Fprintf(&b, "\n## Step %d\n", i+1)
+		if reasoning := strings.TrimSpace(step.Reasoning); reasoning != "" {
+			fmt.Fprintf(&b, "\n<details>\n<summary>Reasoning</summary>\n\n%s\n\n</details>\n", reasoning)
+		}
+		if text := strings.TrimSpace(step.Text); text != "" {
+			fmt.Fprintf(&b, "\n%s\n", text)
+		}
+		for _, call := range step.ToolCalls {
+			fmt.Fprintf(&b, "\n### Tool call: `%s`\n\nInput:\n\n%s\n", call.Name, fencedBlock(prettyJSON(call.Input), "json"))
+			label := "Result"
+			if call.Error {
+				label = "Result (error)"
+			}
+			fmt.Fprintf(&b, "\n%s:\n\n%s\n", label, fencedBlock(call.Result, ""))
+		}
+	}
+
+	b.WriteString("\n## Outcome\n\n")
+	switch {
+	case run.Error != "":
+		fmt.Fprintf(&b, "The run failed: %s\n", run.Error)
+	case strings.TrimSpace(run.Outcome) != "":
+		fmt.Fprintf(&b, "%s\n", strings.TrimSpace(run.Outcome))
+	default:
+		b.WriteString("The agent finished without a final reply.\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func agentRunStatus(run *agentRun) string {
+	switch {
+	case run.Error != "":
+		return "failed"
+	case run.StopReason != "":
+		return fmt.Sprintf("finished (%s)", run.StopReason)
+	default:
+		return "finished"
+	}
+}
+
+// fencedBlock puts text in a code fence longer than any run of backticks
+// inside it, so a result that has its own fences can't close the block early.
+func fencedBlock(text, lang string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+func prettyJSON(data json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return b.String()
+}
+
+// taskTitle is the first line of an agent's task.
+func taskTitle(task string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(task), "\n")
+	return line
+}
+
+func quoteMarkdown(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentListCmd)
+	agentCmd.AddCommand(agentExportCmd)
+	agentExportCmd.Flags().String("session-id", "", "the ID of the /agent run to export")
+	agentExportCmd.Flags().String("format", "markdown", "report format: markdown or json")
+	agentExportCmd.Flags().StringP("output", "o", "", "write to this file instead of stdout")
+	_ = agentExportCmd.MarkFlagRequired("session-id")
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteAgentRunMarkdown(t *testing.T) {
+	run := recordTestRun(t)
+	var b bytes.Buffer
+	if err := writeAgentRunMarkdown(&b, run); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"# Agent run: Fix the build\n",
+		"- **Model:** `model-a`\n",
+		"- **Duration:** 1m30s\n",
+		"- **Tokens:** 120 in / 30 out\n",
+		"- **Cost:** $0.0125 (estimated)\n",
+		"- **Outcome:** finished (end_turn)\n",
+		"## Step 1\n\n<details>\n<summary>Reasoning</summary>\n\nLook at the error first.\n\n</details>\n",
+		"### Tool call: `run_command`\n\nInput:\n\n```json\n{\n  \"command\": \"go build ./...\"\n}\n```\n",
+		"Result (error):\n\n```\nexit status 1\n```\n",
+		"## Step 2\n\nThe build is fixed.\n",
+		"## Outcome\n\nThe build is fixed.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteAgentRunMarkdownFailedRun(t *testing.T) {
+	run := recordTestRun(t)
+	run.Error = "throttled"
+	run.CostUSD = nil
+	var b bytes.Buffer
+	if err := writeAgentRunMarkdown(&b, run); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"- **Cost:** unknown\n", "- **Outcome:** failed\n", "The run failed: throttled\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestWriteAgentRunJSON(t *testing.T) {
+	run := recordTestRun(t)
+	var b bytes.Buffer
+	if err := writeAgentRunJSON(&b, run); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Task       string `json:"task"`
+		DurationMS int64  `json:"duration_ms"`
+		Steps      []struct {
+			ToolCalls []struct {
+				Name  string          `json:"name"`
+				Input json.RawMessage `json:"input"`
+				Error bool            `json:"error"`
+			} `json:"tool_calls"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Task != "Fix the build" || report.DurationMS != 90000 || len(report.Steps) != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	call := report.Steps[0].ToolCalls[0]
+	if call.Name != "run_command" || !call.Error || !strings.Contains(string(call.Input), "go build") {
+		t.Errorf("tool call = %+v", call)
+	}
+}
+
+func TestFencedBlock(t *testing.T) {
+	got := fencedBlock("see:\n```go\nx := 1\n```\n", "")
+	if !strings.HasPrefix(got, "````\n") || !strings.HasSuffix(got, "\n````") {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chat-cli/chat-cli/templates"
+)
+
+// agentPromptVars returns the values of the {{name}} variables an
+// agent-prompt or agent-prompt-append setting can use. They're worked out
+// each time /agent runs, so {{model}} follows /model.
+func agentPromptVars(modelID string) map[string]string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" && runtime.GOOS == "windows" {
+		shell = "powershell"
+	}
+	return map[string]string{
+		"cwd":   cwd,
+		"os":    runtime.GOOS,
+		"arch":  runtime.GOARCH,
+		"shell": shell,
+		"date":  time.Now().Format("2006-01-02"),
+		"model": modelID,
+	}
+}
+
+// buildAgentInstructions returns the instructions added to the system
+// prompt for /agent runs: agentInstructions, or override in their place,
+// followed by extra, with variables filled in from vars. An unknown
+// variable is an error, so a typo doesn't reach the model as {{cwdd}}.
+func buildAgentInstructions(override, extra string, vars map[string]string) (string, error) {
+	instructions := agentInstructions
+	if override != "" {
+		instructions = override
+	}
+	if extra != "" {
+		instructions += "\n\n" + extra
+	}
+
+	used := map[string]string{}
+	for _, name := range templates.Variables(instructions) {
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("agent prompt: unknown variable {{%s}} (available: %s)", name, agentPromptVarNames(vars))
+		}
+		used[name] = value
+	}
+	return templates.Render(instructions, used)
+}
+
+func agentPromptVarNames(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, "{{"+name+"}}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildAgentInstructions(t *testing.T) {
+	vars := map[string]string{"cwd": "/src/app", "os": "linux", "model": "amazon.nova-pro-v1:0"}
+
+	tests := []struct {
+		name, override, extra, want string
+	}{
+		{"built in", "", "", agentInstructions},
+		{"extended", "", "Work in {{cwd}} on {{os}}.", agentInstructions + "\n\nWork in /src/app on linux."},
+		{"replaced", "You are {{ model }}. Fix the failing test.", "", "You are amazon.nova-pro-v1:0. Fix the failing test."},
+		{"replaced and extended", "Fix the failing test.", "Use tabs.", "Fix the failing test.\n\nUse tabs."},
+	}
+	for _, tc := range tests {
+		got, err := buildAgentInstructions(tc.override, tc.extra, vars)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	_, err := buildAgentInstructions("", "Work in {{cwdd}}.", vars)
+	if err == nil || !strings.Contains(err.Error(), "{{cwdd}}") || !strings.Contains(err.Error(), "{{cwd}}") {
+		t.Errorf("expected an unknown variable error listing the known ones, got %v", err)
+	}
+}
+
+func TestAgentPromptVars(t *testing.T) {
+	vars := agentPromptVars("amazon.nova-pro-v1:0")
+	for _, name := range []string{"cwd", "os", "arch", "shell", "date", "model"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("missing {{%s}}", name)
+		}
+	}
+	if vars["model"] != "amazon.nova-pro-v1:0" || vars["cwd"] == "" {
+		t.Errorf("unexpected vars %v", vars)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+	"github.com/chat-cli/chat-cli/utils"
+	uuid "github.com/satori/go.uuid" //nolint:goimports // false positive from CI version diff
+)
+
+// agentRunsDirName is the directory, under the data directory, each /agent
+// run is saved in as <id>.json for agent export.
+const agentRunsDirName = "agent-runs"
+
+// agentRunID is what an agent run's ID looks like; it's checked before
+// being used as a file name.
+var agentRunID = regexp.MustCompile(`^[0-9a-f-]{36}$`)
+
+// agentRun is the record of one /agent run: what it was asked, each step
+// it took, and how it ended.
+type agentRun struct {
+	ID       string      `json:"id"`
+	ChatID   string      `json:"chat_id,omitempty"`
+	Model    string      `json:"model"`
+	Task     string      `json:"task"`
+	Started  time.Time   `json:"started"`
+	Finished time.Time   `json:"finished"`
+	Steps    []agentStep `json:"steps"`
+	// Outcome is the agent's final reply, or Error if the run failed.
+	Outcome    string `json:"outcome,omitempty"`
+	StopReason string `json:"stop_reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// CostUSD is estimated from list prices; nil if the model has none.
+	CostUSD *float64 `json:"cost_usd"`
+}
+
+// agentStep is one response from the model during a run.
+type agentStep struct {
+	Reasoning string          `json:"reasoning,omitempty"`
+	Text      string          `json:"text,omitempty"`
+	ToolCalls []agentToolCall `json:"tool_calls,omitempty"`
+}
+
+type agentToolCall struct {
+	Name   string          `json:"name"`
+	Input  json.RawMessage `json:"input"`
+	Result string          `json:"result"`
+	Error  bool            `json:"error,omitempty"`
+
+	id string
+}
+
+func (r *agentRun) duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// turnCallbacks are what a tool-use turn reports as it streams.
+type turnCallbacks struct {
+	onText       utils.StreamingOutputHandler
+	onReasoning  utils.StreamingOutputHandler
+	onToolCall   func(tools.ToolCall)
+	onToolResult func(tools.ToolCall, types.ToolResultBlock)
+}
+
+// agentRecorder builds an agentRun from the callbacks of a tool-use turn.
+// A step ends once its tool calls have results, so the next text or tool
+// call starts another.
+type agentRecorder struct {
+	run agentRun
+	// stepDone is set once the current step's tools have run.
+	stepDone bool
+}
+
+func newAgentRecorder(chatID, model, task string, started time.Time) *agentRecorder {
+	return &agentRecorder{run: agentRun{
+		ID:      uuid.NewV4().String(),
+		ChatID:  chatID,
+		Model:   model,
+		Task:    task,
+		Started: started,
+	}}
+}
+
+func (a *agentRecorder) step() *agentStep {
+	if len(a.run.Steps) == 0 || a.stepDone {
+		a.run.Steps = append(a.run.Steps, agentStep{})
+		a.stepDone = false
+	}
+	return &a.run.Steps[len(a.run.Steps)-1]
+}
+
+func (a *agentRecorder) onText(_ context.Context, part string) error {
+	a.step().Text += part
+	return nil
+}
+
+func (a *agentRecorder) onReasoning(_ context.Context, part string) error {
+	a.step().Reasoning += part
+	return nil
+}
+
+func (a *agentRecorder) onToolCall(call tools.ToolCall) {
+	step := a.step()
+	input := call.Input
+	if !json.Valid(input) {
+		// input cut off mid-stream is kept as the string it was
+		input, _ = json.Marshal(string(call.Input))
+	}
+	step.ToolCalls = append(step.ToolCalls, agentToolCall{Name: call.Name, Input: input, id: call.ToolUseID})
+}
+
+func (a *agentRecorder) onToolResult(call tools.ToolCall, result types.ToolResultBlock) {
+	if len(a.run.Steps) == 0 {
+		return
+	}
+	step := &a.run.Steps[len(a.run.Steps)-1]
+	for i := range step.ToolCalls {
+		if step.ToolCalls[i].id == call.ToolUseID {
+			step.ToolCalls[i].Result = toolResultText(result)
+			step.ToolCalls[i].Error = result.Status == types.ToolResultStatusError
+		}
+	}
+	a.stepDone = true
+}
+
+// finish records how the run ended and what it used.
+func (a *agentRecorder) finish(finished time.Time, result chatTurnResult, err error, usage tokenUsage, cost *float64) *agentRun {
+	a.run.Finished = finished
+	a.run.Outcome = result.Text
+	a.run.StopReason = string(result.StopReason)
+	if err != nil {
+		a.run.Error = err.Error()
+	}
+	a.run.InputTokens = usage.input + usage.cacheRead + usage.cacheWrite
+	a.run.OutputTokens = usage.output
+	a.run.CostUSD = cost
+	return &a.run
+}
+
+// saveAgentRun writes run to dir as <id>.json.
+func saveAgentRun(dir string, run *agentRun) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("unable to create %s: %w", dir, err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, run.ID+".json"), data, 0o600)
+}
+
+// loadAgentRun reads the run with id from dir.
+func loadAgentRun(dir, id string) (*agentRun, error) {
+	if !agentRunID.MatchString(id) {
+		return nil, fmt.Errorf("invalid session id %q; chat-cli agent list shows them", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json")) // #nosec G304 - the id is checked against agentRunID
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no agent session %s; chat-cli agent list shows them", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var run agentRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("unable to read agent session %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+// listAgentRuns returns the runs saved in dir, newest first. Files that
+// can't be read are skipped.
+func listAgentRuns(dir string) ([]*agentRun, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*agentRun
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if run, err := loadAgentRun(dir, id); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.After(runs[j].Started) })
+	return runs, nil
+}
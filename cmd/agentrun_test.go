@@ -0,0 +1,116 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func toolResult(id, text string, status types.ToolResultStatus) types.ToolResultBlock {
+	return types.ToolResultBlock{
+		ToolUseId: &id,
+		Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: text}},
+		Status:    status,
+	}
+}
+
+// recordTestRun plays a two-step run into a recorder: a tool call that
+// fails, then a reply.
+func recordTestRun(t *testing.T) *agentRun {
+	t.Helper()
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := newAgentRecorder("chat-1", "model-a", "Fix the build", started)
+	ctx := t.Context()
+
+	_ = rec.onReasoning(ctx, "Look at the error ")
+	_ = rec.onReasoning(ctx, "first.")
+	_ = rec.onText(ctx, "Checking the build.")
+	call := tools.ToolCall{Name: "run_command", ToolUseID: "t1", Input: json.RawMessage(`{"command":"go build ./..."}`)}
+	rec.onToolCall(call)
+	rec.onToolResult(call, toolResult("t1", "exit status 1", types.ToolResultStatusError))
+	_ = rec.onText(ctx, "The build is fixed.")
+
+	usage := tokenUsage{input: 100, cacheRead: 20, output: 30}
+	cost := 0.0125
+	return rec.finish(started.Add(90*time.Second), chatTurnResult{Text: "The build is fixed.", StopReason: types.StopReasonEndTurn}, nil, usage, &cost)
+}
+
+func TestAgentRecorder(t *testing.T) {
+	run := recordTestRun(t)
+	if len(run.Steps) != 2 {
+		t.Fatalf("steps = %+v; want 2", run.Steps)
+	}
+	first := run.Steps[0]
+	if first.Reasoning != "Look at the error first." || first.Text != "Checking the build." {
+		t.Errorf("first step = %+v", first)
+	}
+	if len(first.ToolCalls) != 1 || first.ToolCalls[0].Result != "exit status 1" || !first.ToolCalls[0].Error {
+		t.Errorf("tool calls = %+v", first.ToolCalls)
+	}
+	if run.Steps[1].Text != "The build is fixed." || len(run.Steps[1].ToolCalls) != 0 {
+		t.Errorf("second step = %+v", run.Steps[1])
+	}
+	if run.InputTokens != 120 || run.OutputTokens != 30 || run.duration() != 90*time.Second {
+		t.Errorf("run = %+v", run)
+	}
+	if run.Outcome != "The build is fixed." || run.StopReason != "end_turn" || run.Error != "" {
+		t.Errorf("outcome = %q, %q, %q", run.Outcome, run.StopReason, run.Error)
+	}
+}
+
+func TestAgentRecorderKeepsTruncatedInput(t *testing.T) {
+	rec := newAgentRecorder("", "model-a", "task", time.Now())
+	rec.onToolCall(tools.ToolCall{Name: "write_file", ToolUseID: "t1", Input: json.RawMessage(`{"path":"a.go","con`)})
+	var input string
+	if err := json.Unmarshal(rec.run.Steps[0].ToolCalls[0].Input, &input); err != nil || input != `{"path":"a.go","con` {
+		t.Errorf("input = %s (%v)", rec.run.Steps[0].ToolCalls[0].Input, err)
+	}
+}
+
+func TestSaveAndLoadAgentRuns(t *testing.T) {
+	dir := t.TempDir()
+	older := recordTestRun(t)
+	newer := recordTestRun(t)
+	newer.Started = newer.Started.Add(time.Hour)
+	for _, run := range []*agentRun{older, newer} {
+		if err := saveAgentRun(dir, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := loadAgentRun(dir, older.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Task != older.Task || len(loaded.Steps) != 2 || *loaded.CostUSD != *older.CostUSD {
+		t.Errorf("loaded = %+v", loaded)
+	}
+
+	runs, err := listAgentRuns(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != newer.ID || runs[1].ID != older.ID {
+		t.Errorf("runs are not newest first: %+v", runs)
+	}
+}
+
+func TestLoadAgentRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadAgentRun(dir, "../../etc/passwd"); err == nil || !strings.Contains(err.Error(), "invalid session id") {
+		t.Errorf("expected an invalid id error, got %v", err)
+	}
+	if _, err := loadAgentRun(dir, "6f1c2e7a-0000-4000-8000-000000000000"); err == nil || !strings.Contains(err.Error(), "agent list") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if runs, err := listAgentRuns(dir + "/missing"); err != nil || len(runs) != 0 {
+		t.Errorf("listAgentRuns of a missing dir = %v, %v", runs, err)
+	}
+}
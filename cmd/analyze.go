@@ -0,0 +1,165 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+const analyzeInstruction = "You are a careful data analyst answering a question about the user's data file. " +
+	"You're given a profile of the file - its columns with their types and statistics, and a few sample rows - " +
+	"not the whole file. %s Answer concisely, say which numbers you computed and how, " +
+	"and point out anything in the data that makes the answer uncertain."
+
+// analyzeQueryHint is added to analyzeInstruction when the model can query
+// the data itself.
+const analyzeQueryHint = "Use the query_data tool to count, filter, or aggregate the full data exactly rather " +
+	"than estimating from the sample."
+
+// analyzeProfileHint is added instead with --no-query.
+const analyzeProfileHint = "Answer from the profile alone; if it can't answer the question exactly, say so and " +
+	"show a SQL query over a table named data that would."
+
+// analyzeCmd represents the analyze command
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze <file> [question]",
+	Short: "Ask a question about a CSV or JSON file without sending all of it",
+	Long: `Answer a question about a CSV, TSV, JSON (an array of objects) or JSON Lines
+file. The file is profiled locally - its columns, their types and basic
+statistics, and a few sample rows, plus rows that mention words from your
+question - and only that profile is sent to the model. The model then
+queries the full data locally with SQL to compute exact answers; those
+queries are read-only and run on a copy of the file held in memory.
+
+> chat-cli analyze sales.csv "which region had the highest revenue in March?"
+
+Without a question, the profile is printed and nothing is sent.`,
+	Args: cobra.RangeArgs(1, 2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		sampleRows, err := cmd.Flags().GetInt("sample-rows")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		noQuery, err := cmd.Flags().GetBool("no-query")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		data, err := loadDataset(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(args) == 1 {
+			fmt.Println(data.profile(sampleRows, ""))
+			return
+		}
+		question := args[1]
+
+		region, err := cmd.Parent().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelIdFlag, err := cmd.PersistentFlags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		customArnFlag, err := cmd.PersistentFlags().GetString("custom-arn")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+		customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string)
+		finalModelId := modelId
+		if customArn != "" {
+			finalModelId = customArn
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		modelIdString, err := resolveChatModelID(cmd.Context(), newModelCatalog(cfg), finalModelId, customArn != "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// the model gets the data only through query_data, which runs
+		// read-only SELECTs on an in-memory copy
+		registry := tools.NewRegistry()
+		hint := analyzeProfileHint
+		if !noQuery {
+			database, err := data.loadIntoSQLite(cmd.Context())
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer func() {
+				_ = database.Close()
+			}()
+			registry.Register(tools.NewDataQueryTool(database, data.schema()))
+			registry.Register(tools.NewCalculatorTool())
+			hint = analyzeQueryHint
+		}
+
+		svc := bedrockruntime.NewFromConfig(cfg)
+		send := newBedrockRetrier(fm).wrap(func(ctx context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+			out, streamErr := converseStreamWithFallbacks(ctx, svc, in)
+			if streamErr != nil {
+				return nil, streamErr
+			}
+			return out.GetStream().Events(), nil
+		})
+
+		input := &bedrockruntime.ConverseStreamInput{
+			ModelId:  aws.String(modelIdString),
+			System:   buildSystemContentBlocks(fmt.Sprintf(analyzeInstruction, hint)),
+			Messages: []types.Message{userTextMessage(data.profile(sampleRows, question) + "\n\nQuestion: " + question)},
+		}
+
+		renderer := newReplyRenderer(os.Stdout, stderr)
+		opts := chatTurnOptions{
+			OnToolCall:   renderer.onToolCall,
+			OnToolResult: renderer.onToolResult,
+		}
+		// no tool here asks for confirmation, so there's no permission gate
+		if _, err := runChatTurnWithTools(cmd.Context(), send, input, registry, nil, renderer.onText, renderer.onReasoning, opts); err != nil {
+			log.Fatalf("error from Bedrock, %v", err)
+		}
+		fmt.Println()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.PersistentFlags().StringP("model-id", "m", DefaultModelID, "set the model id or inference profile id")
+	analyzeCmd.PersistentFlags().String("custom-arn", "", "pass a custom arn from bedrock marketplace or cross-region inference")
+	analyzeCmd.Flags().Int("sample-rows", 5, "how many sample rows, and rows matching the question, to include in the profile")
+	analyzeCmd.Flags().Bool("no-query", false, "answer from the profile alone, without letting the model query the data")
+}
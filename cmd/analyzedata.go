@@ -0,0 +1,614 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columnKind is the type inferred for a dataset column from its values.
+type columnKind string
+
+const (
+	kindInteger columnKind = "integer"
+	kindNumber  columnKind = "number"
+	kindBoolean columnKind = "boolean"
+	kindDate    columnKind = "date"
+	kindText    columnKind = "text"
+)
+
+// dateLayouts are the date formats a column must use throughout to be
+// treated as dates.
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+const (
+	// maxProfileValueRunes shortens cells in the sample rows and top values
+	// sent to the model.
+	maxProfileValueRunes = 60
+	// topValueCount is how many of a text column's most common values the
+	// profile lists.
+	topValueCount = 5
+)
+
+// analyzeStopWords aren't used to find rows relevant to a question.
+var analyzeStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "what": true, "which": true, "who": true, "how": true,
+	"many": true, "much": true, "with": true, "from": true, "that": true, "this": true, "are": true,
+	"was": true, "were": true, "per": true, "each": true, "all": true, "any": true, "average": true,
+	"total": true, "count": true, "number": true, "most": true, "least": true, "top": true,
+	"show": true, "list": true, "give": true, "does": true, "did": true, "have": true, "has": true,
+	"there": true, "than": true, "more": true, "less": true, "between": true, "over": true, "under": true,
+	"rows": true, "row": true, "data": true, "file": true, "column": true, "columns": true, "when": true,
+}
+
+// dataset is a CSV or JSON file read into memory for chat-cli analyze.
+// Every value is kept as text; "" is an empty or null value.
+type dataset struct {
+	name    string
+	format  string
+	columns []string
+	kinds   []columnKind
+	rows    [][]string
+}
+
+// loadDataset reads a .csv, .tsv, .json (an array of objects), or .jsonl
+// file and infers each column's type.
+func loadDataset(path string) (*dataset, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - the user named this file
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	d := &dataset{name: filepath.Base(path)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		d.format = "CSV"
+		err = d.readCSV(data, ',')
+	case ".tsv":
+		d.format = "TSV"
+		err = d.readCSV(data, '\t')
+	case ".json":
+		d.format = "JSON"
+		err = d.readJSON(data)
+	case ".jsonl", ".ndjson":
+		d.format = "JSON Lines"
+		err = d.readJSONLines(data)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q: use a .csv, .tsv, .json or .jsonl file", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	if len(d.columns) == 0 {
+		return nil, fmt.Errorf("%s has no columns", path)
+	}
+
+	d.columns = uniqueColumnNames(d.columns)
+	d.kinds = make([]columnKind, len(d.columns))
+	for i := range d.columns {
+		d.kinds[i] = inferColumnKind(d.values(i))
+	}
+	return d, nil
+}
+
+func (d *dataset) readCSV(data []byte, comma rune) error {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	d.columns = records[0]
+	for _, record := range records[1:] {
+		row := make([]string, len(d.columns))
+		copy(row, record)
+		d.rows = append(d.rows, row)
+	}
+	return nil
+}
+
+func (d *dataset) readJSON(data []byte) error {
+	var objects []json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return errors.New("expected an array of objects")
+	}
+	return d.addObjects(objects)
+}
+
+func (d *dataset) readJSONLines(data []byte) error {
+	var objects []json.RawMessage
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			return fmt.Errorf("line %d isn't valid JSON", n+1)
+		}
+		objects = append(objects, json.RawMessage(line))
+	}
+	return d.addObjects(objects)
+}
+
+// addObjects adds a row for each JSON object, with a column for every key
+// seen, in the order they first appear.
+func (d *dataset) addObjects(objects []json.RawMessage) error {
+	index := map[string]int{}
+	var records []map[string]string
+	for n, raw := range objects {
+		keys, values, err := decodeJSONObject(raw)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n+1, err)
+		}
+		for _, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = len(d.columns)
+				d.columns = append(d.columns, key)
+			}
+		}
+		records = append(records, values)
+	}
+	for _, values := range records {
+		row := make([]string, len(d.columns))
+		for key, value := range values {
+			row[index[key]] = value
+		}
+		d.rows = append(d.rows, row)
+	}
+	return nil
+}
+
+// decodeJSONObject returns an object's keys in order and its values as
+// text. Nested objects and arrays are kept as compact JSON.
+func decodeJSONObject(raw json.RawMessage) ([]string, map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, errors.New("expected an object")
+	}
+
+	var keys []string
+	values := map[string]string{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = jsonValueText(value)
+	}
+	return keys, values, nil
+}
+
+func jsonValueText(value json.RawMessage) string {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return string(value)
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return string(value)
+	}
+	return compact.String()
+}
+
+// uniqueColumnNames names blank columns by position and numbers repeats,
+// so every column can be referred to in SQL.
+func uniqueColumnNames(columns []string) []string {
+	seen := map[string]int{}
+	names := make([]string, len(columns))
+	for i, name := range columns {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		key := strings.ToLower(name)
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// values returns column i's values that aren't empty.
+func (d *dataset) values(i int) []string {
+	var values []string
+	for _, row := range d.rows {
+		if row[i] != "" {
+			values = append(values, row[i])
+		}
+	}
+	return values
+}
+
+// inferColumnKind returns the narrowest kind all of values fit. A column
+// with no values is text.
+func inferColumnKind(values []string) columnKind {
+	if len(values) == 0 {
+		return kindText
+	}
+	fits := func(ok func(string) bool) bool {
+		for _, v := range values {
+			if !ok(v) {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case fits(func(v string) bool { _, err := strconv.ParseInt(v, 10, 64); return err == nil }):
+		return kindInteger
+	case fits(func(v string) bool { _, ok := parseFiniteFloat(v); return ok }):
+		return kindNumber
+	case fits(func(v string) bool { _, err := strconv.ParseBool(v); return err == nil && !isDigits(v) }):
+		return kindBoolean
+	case fits(func(v string) bool { _, ok := parseDateValue(v); return ok }):
+		return kindDate
+	}
+	return kindText
+}
+
+func parseFiniteFloat(v string) (float64, bool) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+func parseDateValue(v string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isDigits(v string) bool {
+	return strings.Trim(v, "0123456789") == ""
+}
+
+// sqlType is the SQLite column type a kind is stored as. Booleans are
+// stored as 1 and 0, and dates as their text, which sorts in order.
+func (k columnKind) sqlType() string {
+	switch k {
+	case kindInteger, kindBoolean:
+		return "INTEGER"
+	case kindNumber:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// sqlValue converts a value of kind k for storing, with "" as NULL.
+func (k columnKind) sqlValue(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	switch k {
+	case kindInteger:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	case kindNumber:
+		f, _ := parseFiniteFloat(v)
+		return f
+	case kindBoolean:
+		if b, _ := strconv.ParseBool(v); b {
+			return 1
+		}
+		return 0
+	}
+	return v
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// loadIntoSQLite copies the dataset into a table named data in a new
+// in-memory database, which is then made read-only so the model can query
+// but not change it.
+func (d *dataset) loadIntoSQLite(ctx context.Context) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create database: %w", err)
+	}
+	// each connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+
+	if err := d.insertRows(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to load data: %w", err)
+	}
+	return db, nil
+}
+
+func (d *dataset) insertRows(ctx context.Context, db *sql.DB) error {
+	definitions := make([]string, len(d.columns))
+	placeholders := make([]string, len(d.columns))
+	for i, name := range d.columns {
+		definitions[i] = quoteIdentifier(name) + " " + d.kinds[i].sqlType()
+		placeholders[i] = "?"
+	}
+	if _, err := db.ExecContext(ctx, "CREATE TABLE data ("+strings.Join(definitions, ", ")+")"); err != nil {
+		return fmt.Errorf("unable to load data: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to load data: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	insert, err := tx.PrepareContext(ctx, "INSERT INTO data VALUES ("+strings.Join(placeholders, ", ")+")") // #nosec G202 - only placeholders are joined
+	if err != nil {
+		return fmt.Errorf("unable to load data: %w", err)
+	}
+	defer func() {
+		_ = insert.Close()
+	}()
+
+	args := make([]interface{}, len(d.columns))
+	for _, row := range d.rows {
+		for i, v := range row {
+			args[i] = d.kinds[i].sqlValue(v)
+		}
+		if _, err := insert.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("unable to load data: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to load data: %w", err)
+	}
+	return nil
+}
+
+// schema describes the table loadIntoSQLite creates, for the query_data
+// tool's description.
+func (d *dataset) schema() string {
+	definitions := make([]string, len(d.columns))
+	for i, name := range d.columns {
+		definitions[i] = quoteIdentifier(name) + " " + d.kinds[i].sqlType()
+		switch d.kinds[i] {
+		case kindBoolean:
+			definitions[i] += " (boolean, 1 or 0)"
+		case kindDate:
+			definitions[i] += " (date text, e.g. " + d.values(i)[0] + ")"
+		}
+	}
+	return fmt.Sprintf("The table is named data and has %d rows with columns %s.", len(d.rows), strings.Join(definitions, ", "))
+}
+
+// profile summarizes the dataset for the model without including all of
+// it: its shape, each column's type and statistics, the first sampleRows
+// rows, and up to sampleRows more that mention words from question.
+func (d *dataset) profile(sampleRows int, question string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "File: %s (%s, %s, %s)\n\nColumns:\n", d.name, d.format, countOf(len(d.rows), "row"), countOf(len(d.columns), "column"))
+	for i, name := range d.columns {
+		fmt.Fprintf(&b, "- %s (%s): %s\n", name, d.kinds[i], d.columnStats(i))
+	}
+
+	if sampleRows <= 0 || len(d.rows) == 0 {
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+	sample := d.rows[:min(sampleRows, len(d.rows))]
+	fmt.Fprintf(&b, "\nFirst %s:\n", countOf(len(sample), "row"))
+	d.writeRows(&b, sample)
+
+	words := questionKeywords(question)
+	if matches := d.rowsMentioning(words, len(sample), sampleRows); len(matches) > 0 {
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = strconv.Quote(word)
+		}
+		fmt.Fprintf(&b, "\nOther rows mentioning %s:\n", strings.Join(quoted, ", "))
+		d.writeRows(&b, matches)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (d *dataset) writeRows(b *strings.Builder, rows [][]string) {
+	b.WriteString(strings.Join(d.columns, " | "))
+	b.WriteString("\n")
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = profileValue(v)
+		}
+		b.WriteString(strings.Join(cells, " | "))
+		b.WriteString("\n")
+	}
+}
+
+// profileValue shortens a value for the profile and keeps it on one line.
+func profileValue(v string) string {
+	v = strings.NewReplacer("\r\n", " ", "\n", " ", "|", "/").Replace(v)
+	return truncateRunes(v, maxProfileValueRunes)
+}
+
+// columnStats summarizes column i according to its kind.
+func (d *dataset) columnStats(i int) string {
+	values := d.values(i)
+	var parts []string
+	if len(values) == 0 {
+		return "always empty"
+	}
+
+	switch d.kinds[i] {
+	case kindInteger, kindNumber:
+		lo, hi, sum := math.Inf(1), math.Inf(-1), 0.0
+		for _, v := range values {
+			f, _ := parseFiniteFloat(v)
+			lo, hi, sum = math.Min(lo, f), math.Max(hi, f), sum+f
+		}
+		parts = append(parts, fmt.Sprintf("min %s, max %s, mean %s", formatStat(lo), formatStat(hi), formatStat(sum/float64(len(values)))))
+	case kindBoolean:
+		trues := 0
+		for _, v := range values {
+			if b, _ := strconv.ParseBool(v); b {
+				trues++
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d true, %d false", trues, len(values)-trues))
+	case kindDate:
+		first, last := values[0], values[0]
+		firstTime, _ := parseDateValue(first)
+		lastTime := firstTime
+		for _, v := range values[1:] {
+			t, _ := parseDateValue(v)
+			if t.Before(firstTime) {
+				first, firstTime = v, t
+			}
+			if t.After(lastTime) {
+				last, lastTime = v, t
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s to %s", first, last))
+	default:
+		parts = append(parts, textStats(values))
+	}
+
+	if empty := len(d.rows) - len(values); empty > 0 {
+		parts = append(parts, fmt.Sprintf("%d empty", empty))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// textStats gives a text column's distinct count and its most common
+// values, or a few examples when no value repeats.
+func textStats(values []string) string {
+	counts := map[string]int{}
+	for _, v := range values {
+		counts[v]++
+	}
+	distinct := make([]string, 0, len(counts))
+	for v := range counts {
+		distinct = append(distinct, v)
+	}
+	sort.Slice(distinct, func(a, b int) bool {
+		if counts[distinct[a]] != counts[distinct[b]] {
+			return counts[distinct[a]] > counts[distinct[b]]
+		}
+		return distinct[a] < distinct[b]
+	})
+
+	shown := distinct[:min(topValueCount, len(distinct))]
+	if counts[shown[0]] == 1 {
+		examples := make([]string, len(shown))
+		for i, v := range shown {
+			examples[i] = strconv.Quote(profileValue(v))
+		}
+		return fmt.Sprintf("%d distinct; e.g. %s", len(distinct), strings.Join(examples, ", "))
+	}
+	top := make([]string, len(shown))
+	for i, v := range shown {
+		top[i] = fmt.Sprintf("%q %d", profileValue(v), counts[v])
+	}
+	return fmt.Sprintf("%d distinct; most common %s", len(distinct), strings.Join(top, ", "))
+}
+
+// formatStat rounds a statistic to four decimal places.
+func formatStat(f float64) string {
+	if math.Abs(f) >= 1e15 {
+		return strconv.FormatFloat(f, 'g', 6, 64)
+	}
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
+
+// questionKeywords picks the words of question worth looking for in the
+// data: three letters or more and not a common word.
+func questionKeywords(question string) []string {
+	var words []string
+	seen := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !(r == '-' || r == '_' || r == '.' || r == '@' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 127)
+	}) {
+		word = strings.Trim(word, "-_.@")
+		if len([]rune(word)) < 3 || analyzeStopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	return words
+}
+
+// rowsMentioning returns up to limit rows after the first skip whose text
+// contains one of words.
+func (d *dataset) rowsMentioning(words []string, skip, limit int) [][]string {
+	if len(words) == 0 || limit <= 0 {
+		return nil
+	}
+	var matches [][]string
+	for _, row := range d.rows[min(skip, len(d.rows)):] {
+		text := strings.ToLower(strings.Join(row, "\x00"))
+		for _, word := range words {
+			if strings.Contains(text, word) {
+				matches = append(matches, row)
+				break
+			}
+		}
+		if len(matches) == limit {
+			break
+		}
+	}
+	return matches
+}
+
+// countOf gives n with noun, plural unless n is 1.
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
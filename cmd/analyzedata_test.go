@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func writeDataFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const salesCSV = "region,amount,date,paid,note\n" +
+	"West,10,2024-01-03,true,first order\n" +
+	"East,2.5,2024-02-01,false,\n" +
+	"West,7,2024-01-01,true,refund requested\n" +
+	",1,2024-03-09,false,hi\n"
+
+func TestLoadDataset(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		d, err := loadDataset(writeDataFile(t, "sales.csv", "\xef\xbb\xbf"+salesCSV))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(d.columns, []string{"region", "amount", "date", "paid", "note"}) {
+			t.Errorf("unexpected columns %v", d.columns)
+		}
+		want := []columnKind{kindText, kindNumber, kindDate, kindBoolean, kindText}
+		if !slices.Equal(d.kinds, want) {
+			t.Errorf("got kinds %v, want %v", d.kinds, want)
+		}
+		if len(d.rows) != 4 {
+			t.Errorf("expected 4 rows, got %d", len(d.rows))
+		}
+	})
+
+	t.Run("json keeps key order and flattens nesting", func(t *testing.T) {
+		d, err := loadDataset(writeDataFile(t, "people.json", `[
+			{"name": "Ada", "age": 36, "tags": ["math"]},
+			{"name": "Alan", "age": null, "city": "London"}
+		]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(d.columns, []string{"name", "age", "tags", "city"}) {
+			t.Errorf("unexpected columns %v", d.columns)
+		}
+		if d.kinds[1] != kindInteger {
+			t.Errorf("expected age to be an integer, got %s", d.kinds[1])
+		}
+		if d.rows[0][2] != `["math"]` || d.rows[1][1] != "" || d.rows[1][3] != "London" {
+			t.Errorf("unexpected rows %q", d.rows)
+		}
+	})
+
+	t.Run("json lines", func(t *testing.T) {
+		d, err := loadDataset(writeDataFile(t, "events.jsonl", "{\"id\": 1}\n\n{\"id\": 2}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d.rows) != 2 || d.kinds[0] != kindInteger {
+			t.Errorf("unexpected dataset %+v", d)
+		}
+	})
+
+	t.Run("duplicate and blank headers", func(t *testing.T) {
+		d, err := loadDataset(writeDataFile(t, "dup.csv", "id,,ID\n1,2,3\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(d.columns, []string{"id", "column_2", "ID_2"}) {
+			t.Errorf("unexpected columns %v", d.columns)
+		}
+	})
+
+	for name, content := range map[string]string{
+		"notes.txt": "hello",
+		"bad.json":  `{"not": "an array"}`,
+		"bad.jsonl": "{\"id\": 1}\nnope\n",
+		"empty.csv": "",
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := loadDataset(writeDataFile(t, name, content)); err == nil {
+				t.Errorf("expected %s to be rejected", name)
+			}
+		})
+	}
+}
+
+func TestDatasetProfile(t *testing.T) {
+	d, err := loadDataset(writeDataFile(t, "sales.csv", salesCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := d.profile(1, "Which orders asked for a refund?")
+
+	for _, want := range []string{
+		"File: sales.csv (CSV, 4 rows, 5 columns)",
+		`- region (text): 2 distinct; most common "West" 2, "East" 1; 1 empty`,
+		"- amount (number): min 1, max 10, mean 5.125",
+		"- date (date): 2024-01-01 to 2024-03-09",
+		"- paid (boolean): 2 true, 2 false",
+		"First 1 row:\nregion | amount | date | paid | note\nWest | 10 | 2024-01-03 | true | first order",
+		"Other rows mentioning \"orders\", \"asked\", \"refund\":\nregion | amount | date | paid | note\nWest | 7 | 2024-01-01 | true | refund requested",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the profile to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "| 2024-03-09 |") {
+		t.Errorf("expected rows beyond the sample and matches to be left out, got:\n%s", got)
+	}
+}
+
+func TestDatasetLoadIntoSQLite(t *testing.T) {
+	d, err := loadDataset(writeDataFile(t, "sales.csv", salesCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := d.loadIntoSQLite(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	tool := tools.NewDataQueryTool(db, d.schema())
+	if !strings.Contains(tool.Description(), `"paid" INTEGER (boolean, 1 or 0)`) {
+		t.Errorf("expected the schema in the description, got %q", tool.Description())
+	}
+
+	got, err := tool.Query(context.Background(), `SELECT region, SUM(amount) AS total FROM data WHERE paid = 1 GROUP BY region`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "region | total\nWest | 17\n(1 row)" {
+		t.Errorf("unexpected result %q", got)
+	}
+
+	if _, err := db.Exec(`DELETE FROM data`); err == nil {
+		t.Error("expected the loaded data to be read-only")
+	}
+}
+
+func TestQuestionKeywords(t *testing.T) {
+	got := questionKeywords("What's the total revenue for the West region in 2024?")
+	want := []string{"revenue", "west", "region", "2024"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
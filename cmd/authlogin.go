@@ -0,0 +1,234 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/chat-cli/chat-cli/auth"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Sign in to AWS",
+	Long:  `Sign in to AWS for chat-cli's calls to Bedrock.`,
+}
+
+// authLoginCmd represents the auth login command
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Sign in with AWS IAM Identity Center (SSO)",
+	Long: `Sign in with AWS IAM Identity Center (formerly AWS SSO). You approve the sign-in
+in a browser - on this machine or any other - then choose the account and role
+to use, and chat-cli checks that it can reach Bedrock with them.
+
+> chat-cli auth login --start-url https://my-org.awsapps.com/start --sso-region us-east-1
+
+The sign-in is saved (the auth.sso_* settings), and its token is cached in
+chat-cli's data directory and refreshed as it expires, so later commands use
+it without an AWS profile. Run auth login again, without flags, when the
+session ends. An AWS profile set with --aws-profile or aws-profile takes
+precedence over it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		// signing in talks to IAM Identity Center, not through
+		// loadAWSConfig, so offline mode is checked here
+		if offline, err := configuredOffline(fm); err != nil {
+			log.Fatal(err)
+		} else if offline {
+			log.Fatal(errOffline)
+		}
+
+		startURLFlag, err := cmd.Flags().GetString("start-url")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		ssoRegionFlag, err := cmd.Flags().GetString("sso-region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		accountIDFlag, err := cmd.Flags().GetString("account-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		roleNameFlag, err := cmd.Flags().GetString("role-name")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		login, err := resolveSSOLogin(configuredSSOLogin(fm), startURLFlag, ssoRegionFlag, accountIDFlag, roleNameFlag, region)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx := cmd.Context()
+		ssoCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(login.region), config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		token, err := auth.SSOLogin(ctx, ssooidc.NewFromConfig(ssoCfg), login.startURL, login.region, printDeviceAuthorization, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := auth.SaveSSOToken(login.tokenPath(fm), token); err != nil {
+			log.Fatal(err)
+		}
+
+		if login.accountID == "" || login.roleName == "" {
+			roles, err := auth.SSORoles(ctx, sso.NewFromConfig(ssoCfg), token.AccessToken)
+			if err != nil {
+				log.Fatal(err)
+			}
+			role, err := chooseSSORole(os.Stdin, stderr, roles)
+			if err != nil {
+				log.Fatal(err)
+			}
+			login.accountID, login.roleName = role.AccountID, role.RoleName
+		}
+
+		viper.Set(ssoStartURLKey, login.startURL)
+		viper.Set(ssoRegionKey, login.region)
+		viper.Set(ssoAccountIDKey, login.accountID)
+		viper.Set(ssoRoleNameKey, login.roleName)
+		if err := viper.WriteConfig(); err != nil {
+			log.Fatalf("unable to save the sign-in: %v", err)
+		}
+		fmt.Printf("Signed in to %s as %s in account %s (until %s)\n", login.startURL, login.roleName, login.accountID, token.Expires().Local().Format("15:04 Jan 2"))
+
+		if profile := configuredAWSProfile(fm); profile != "" {
+			fmt.Fprintf(stderr, "Note: AWS profile %s is set, so it's used instead of this sign-in; run chat-cli config unset aws-profile to use the sign-in.\n", profile)
+			return
+		}
+
+		cfg, err := loadAWSConfig(ctx, region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+		if err := verifyBedrockAccess(ctx, cfg, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// resolveSSOLogin combines the auth login flags with the saved sign-in.
+// The saved account and role are only kept for the same start URL, and
+// the region defaults to the one Bedrock is called in.
+func resolveSSOLogin(saved ssoLogin, startURL, ssoRegion, accountID, roleName, region string) (ssoLogin, error) {
+	login := saved
+	if startURL != "" && startURL != saved.startURL {
+		login = ssoLogin{startURL: startURL}
+	}
+	if login.startURL == "" {
+		return login, errors.New("--start-url is required to sign in for the first time: it's your AWS access portal URL, such as https://my-org.awsapps.com/start")
+	}
+	if ssoRegion != "" {
+		login.region = ssoRegion
+	}
+	if login.region == "" {
+		login.region = region
+	}
+	if accountID != "" {
+		login.accountID = accountID
+	}
+	if roleName != "" {
+		login.roleName = roleName
+	}
+	return login, nil
+}
+
+// printDeviceAuthorization tells the user how to approve the sign-in.
+func printDeviceAuthorization(device auth.DeviceAuthorization) {
+	fmt.Fprintf(stderr, "To sign in, open this page in a browser:\n\n  %s\n\n", device.VerificationURIComplete)
+	fmt.Fprintf(stderr, "and check that it shows the code %s. Waiting for approval...\n", device.UserCode)
+}
+
+// chooseSSORole picks the role to use: the only one there is, or the one
+// the user chooses from a numbered list.
+func chooseSSORole(in io.Reader, out io.Writer, roles []auth.SSORole) (auth.SSORole, error) {
+	switch len(roles) {
+	case 0:
+		return auth.SSORole{}, errors.New("you don't have access to any roles through IAM Identity Center")
+	case 1:
+		fmt.Fprintf(out, "Using role %s in %s (%s)\n", roles[0].RoleName, roles[0].AccountName, roles[0].AccountID)
+		return roles[0], nil
+	}
+
+	fmt.Fprintln(out, "Roles you can use:")
+	for i, role := range roles {
+		fmt.Fprintf(out, "  %d) %s in %s (%s)\n", i+1, role.RoleName, role.AccountName, role.AccountID)
+	}
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Choose a role [1-%d]: ", len(roles))
+		line, err := reader.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && n >= 1 && n <= len(roles) {
+			return roles[n-1], nil
+		}
+		if err != nil {
+			return auth.SSORole{}, errors.New("no role chosen; pass --account-id and --role-name to choose one without asking")
+		}
+	}
+}
+
+// verifyBedrockAccess checks that cfg's credentials work and can list
+// Bedrock's models, reporting who they belong to on out.
+func verifyBedrockAccess(ctx context.Context, cfg aws.Config, out io.Writer) error {
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("unable to use the new credentials: %w", err)
+	}
+	models, err := bedrock.NewFromConfig(cfg).ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+	if err != nil {
+		return fmt.Errorf("signed in as %s, but unable to reach Bedrock in %s: %w", aws.ToString(identity.Arn), cfg.Region, err)
+	}
+	fmt.Fprintf(out, "Bedrock is available in %s as %s (%s)\n", cfg.Region, aws.ToString(identity.Arn), countOf(len(models.ModelSummaries), "model"))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd)
+
+	authLoginCmd.Flags().String("start-url", "", "your AWS access portal URL, e.g. https://my-org.awsapps.com/start (remembered after the first sign-in)")
+	authLoginCmd.Flags().String("sso-region", "", "the region of your IAM Identity Center instance (default: --region)")
+	authLoginCmd.Flags().String("account-id", "", "the AWS account to use, instead of choosing from a list")
+	authLoginCmd.Flags().String("role-name", "", "the IAM Identity Center role (permission set) to use, instead of choosing from a list")
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/chat-cli/chat-cli/auth"
+)
+
+func TestResolveSSOLogin(t *testing.T) {
+	saved := ssoLogin{startURL: "https://a.awsapps.com/start", region: "eu-west-1", accountID: "111111111111", roleName: "Dev"}
+
+	// signing in again reuses everything
+	if got, err := resolveSSOLogin(saved, "", "", "", "", "us-east-1"); err != nil || got != saved {
+		t.Errorf("expected the saved sign-in, got %+v, %v", got, err)
+	}
+
+	// another instance starts over, in --region unless told otherwise
+	got, err := resolveSSOLogin(saved, "https://b.awsapps.com/start", "", "", "", "us-east-1")
+	if err != nil || got != (ssoLogin{startURL: "https://b.awsapps.com/start", region: "us-east-1"}) {
+		t.Errorf("expected a fresh sign-in, got %+v, %v", got, err)
+	}
+
+	got, _ = resolveSSOLogin(saved, "", "us-west-2", "222222222222", "Admin", "us-east-1")
+	if got.region != "us-west-2" || got.accountID != "222222222222" || got.roleName != "Admin" {
+		t.Errorf("expected flags to override the saved sign-in, got %+v", got)
+	}
+
+	if _, err := resolveSSOLogin(ssoLogin{}, "", "", "", "", "us-east-1"); err == nil || !strings.Contains(err.Error(), "--start-url") {
+		t.Errorf("expected --start-url to be asked for, got %v", err)
+	}
+}
+
+func TestChooseSSORole(t *testing.T) {
+	roles := []auth.SSORole{
+		{AccountID: "111111111111", AccountName: "Production", RoleName: "ReadOnly"},
+		{AccountID: "222222222222", AccountName: "Sandbox", RoleName: "Developer"},
+	}
+
+	var out bytes.Buffer
+	role, err := chooseSSORole(strings.NewReader("3\nx\n2\n"), &out, roles)
+	if err != nil || role != roles[1] {
+		t.Fatalf("expected the second role, got %+v, %v", role, err)
+	}
+	if !strings.Contains(out.String(), "2) Developer in Sandbox (222222222222)") || strings.Count(out.String(), "Choose a role") != 3 {
+		t.Errorf("expected the list and a prompt per answer, got %q", out.String())
+	}
+
+	if role, err := chooseSSORole(strings.NewReader(""), &out, roles[:1]); err != nil || role != roles[0] {
+		t.Errorf("expected the only role to be used, got %+v, %v", role, err)
+	}
+	if _, err := chooseSSORole(strings.NewReader(""), &out, roles); err == nil {
+		t.Error("expected an error when no role is chosen")
+	}
+	if _, err := chooseSSORole(strings.NewReader(""), &out, nil); err == nil {
+		t.Error("expected an error without roles")
+	}
+}
+
+func TestLoginHintProvider(t *testing.T) {
+	failing := loginHintProvider{aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("no credentials found")
+	}), "run chat-cli auth login"}
+	if _, err := failing.Retrieve(t.Context()); err == nil || err.Error() != "no credentials found (run chat-cli auth login)" {
+		t.Errorf("expected the hint added, got %v", err)
+	}
+
+	working := loginHintProvider{aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID"}, nil
+	}), "run chat-cli auth login"}
+	if creds, err := working.Retrieve(t.Context()); err != nil || creds.AccessKeyID != "AKID" {
+		t.Errorf("expected credentials passed through, got %+v, %v", creds, err)
+	}
+}
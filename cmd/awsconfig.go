@@ -0,0 +1,205 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/chat-cli/chat-cli/auth"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+const (
+	// awsProfileKey names the profile in the AWS shared config and
+	// credentials files that chat-cli takes credentials from.
+	awsProfileKey = "aws-profile"
+
+	// The auth settings have chat-cli assume a role with the credentials
+	// found through aws-profile or the SDK's default chain.
+	roleARNKey         = "auth.role_arn"
+	mfaSerialKey       = "auth.mfa_serial"
+	externalIDKey      = "auth.external_id"
+	sessionDurationKey = "auth.session_duration"
+
+	// The SSO settings are written by auth login: the IAM Identity Center
+	// instance signed in to, and the account and role to use.
+	ssoStartURLKey  = "auth.sso_start_url"
+	ssoRegionKey    = "auth.sso_region"
+	ssoAccountIDKey = "auth.sso_account_id"
+	ssoRoleNameKey  = "auth.sso_role_name"
+
+	// stsCacheDirName is the folder in the data directory assumed-role
+	// credentials are cached in, and ssoCacheDirName the one auth login
+	// keeps its tokens in.
+	stsCacheDirName = "sts-cache"
+	ssoCacheDirName = "sso-cache"
+)
+
+// awsProfileFlag is the value of --aws-profile, set before each command
+// runs.
+var awsProfileFlag string
+
+// readMFACode asks for a code from the MFA device serial on stderr and
+// reads it from stdin. Tests replace it.
+var readMFACode = func(serial string) (string, error) {
+	fmt.Fprintf(stderr, "MFA code for %s: ", serial)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("unable to read MFA code: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// configuredAWSProfile returns the AWS profile to use: --aws-profile, then
+// CHAT_CLI_AWS_PROFILE, then aws-profile in the active chat-cli profile or
+// the config file. "" leaves the choice to the SDK, which reads
+// AWS_PROFILE and otherwise uses the default profile.
+func configuredAWSProfile(fm *conf.FileManager) string {
+	value := fm.GetConfigValue(awsProfileKey, awsProfileFlag, "")
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// configuredAssumeRole reads the auth settings. The role's ARN is "" when
+// no role is configured.
+func configuredAssumeRole(fm *conf.FileManager) (auth.AssumeRole, error) {
+	role := auth.AssumeRole{
+		RoleARN:    configString(fm, roleARNKey),
+		ExternalID: configString(fm, externalIDKey),
+		MFASerial:  configString(fm, mfaSerialKey),
+		Source:     configuredAWSProfile(fm),
+	}
+	if role.RoleARN == "" {
+		if role.MFASerial != "" || role.ExternalID != "" {
+			return role, fmt.Errorf("%s and %s need %s to be set", mfaSerialKey, externalIDKey, roleARNKey)
+		}
+		return role, nil
+	}
+
+	if duration := configString(fm, sessionDurationKey); duration != "" {
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return role, fmt.Errorf("invalid %s %q: use a duration such as 1h or 45m", sessionDurationKey, duration)
+		}
+		role.Duration = d
+	}
+	if err := role.Validate(); err != nil {
+		return role, fmt.Errorf("%s: %w", roleARNKey, err)
+	}
+	return role, nil
+}
+
+// ssoLogin is the sign-in auth login saved.
+type ssoLogin struct {
+	startURL  string
+	region    string
+	accountID string
+	roleName  string
+}
+
+func configuredSSOLogin(fm *conf.FileManager) ssoLogin {
+	return ssoLogin{
+		startURL:  configString(fm, ssoStartURLKey),
+		region:    configString(fm, ssoRegionKey),
+		accountID: configString(fm, ssoAccountIDKey),
+		roleName:  configString(fm, ssoRoleNameKey),
+	}
+}
+
+// complete reports whether there's enough to get credentials with.
+func (l ssoLogin) complete() bool {
+	return l.startURL != "" && l.region != "" && l.accountID != "" && l.roleName != ""
+}
+
+// tokenPath is where auth login keeps the sign-in's token.
+func (l ssoLogin) tokenPath(fm *conf.FileManager) string {
+	return auth.SSOTokenPath(filepath.Join(fm.DataPath, ssoCacheDirName), l.startURL)
+}
+
+// credentials exchanges the saved token for the role's credentials,
+// refreshing the token when it expires.
+func (l ssoLogin) credentials(cfg aws.Config, fm *conf.FileManager) aws.CredentialsProvider {
+	ssoCfg := cfg.Copy()
+	ssoCfg.Region = l.region
+	path := l.tokenPath(fm)
+	provider := ssocreds.New(sso.NewFromConfig(ssoCfg), l.accountID, l.roleName, l.startURL, func(o *ssocreds.Options) {
+		o.CachedTokenFilepath = path
+		o.SSOTokenProvider = ssocreds.NewSSOTokenProvider(ssooidc.NewFromConfig(ssoCfg), path)
+	})
+	return aws.NewCredentialsCache(loginHintProvider{provider, "run chat-cli auth login to sign in again"})
+}
+
+// loginHintProvider adds a pointer to auth login to the error when
+// credentials can't be found.
+type loginHintProvider struct {
+	aws.CredentialsProvider
+	hint string
+}
+
+func (p loginHintProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.CredentialsProvider.Retrieve(ctx)
+	if err != nil {
+		return creds, fmt.Errorf("%w (%s)", err, p.hint)
+	}
+	return creds, nil
+}
+
+// loadAWSConfig loads the AWS SDK config for region with the credentials of
+// the configured AWS profile, or else of auth login's sign-in, assuming
+// auth.role_arn with them if it's set. Every command that calls AWS loads
+// its config here, so --aws-profile, --offline, and the auth settings
+// apply to all of them.
+func loadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if offline, err := configuredOffline(fm); err != nil || offline {
+		if err == nil {
+			err = errOffline
+		}
+		return aws.Config{}, err
+	}
+
+	options := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	profile := configuredAWSProfile(fm)
+	if profile != "" {
+		options = append(options, config.WithSharedConfigProfile(profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return cfg, err
+	}
+
+	// an AWS profile is an explicit choice, so it wins over auth login
+	if login := configuredSSOLogin(fm); profile == "" && login.complete() {
+		cfg.Credentials = login.credentials(cfg, fm)
+	} else if profile == "" && cfg.Credentials != nil {
+		cfg.Credentials = loginHintProvider{cfg.Credentials, "to sign in with IAM Identity Center, run chat-cli auth login"}
+	}
+
+	role, err := configuredAssumeRole(fm)
+	if err != nil || role.RoleARN == "" {
+		return cfg, err
+	}
+	cache := auth.NewCredentialCache(filepath.Join(fm.DataPath, stsCacheDirName))
+	provider := auth.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role, cache, readMFACode)
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	return cfg, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// testAWSConfig returns a config whose clients send to server with static
+// credentials, making one attempt per request.
+func testAWSConfig(server *httptest.Server) aws.Config {
+	return aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+		})),
+		BaseEndpoint:     aws.String(server.URL),
+		HTTPClient:       server.Client(),
+		RetryMaxAttempts: 1,
+	}
+}
+
+// withAWSProfileFlag sets --aws-profile for the rest of the test.
+func withAWSProfileFlag(t *testing.T, profile string) {
+	t.Helper()
+	saved := awsProfileFlag
+	awsProfileFlag = profile
+	t.Cleanup(func() { awsProfileFlag = saved })
+}
+
+func TestConfiguredAWSProfile(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	withAWSProfileFlag(t, "")
+	fm := &conf.FileManager{}
+
+	if got := configuredAWSProfile(fm); got != "" {
+		t.Errorf("expected no profile by default, got %q", got)
+	}
+
+	viper.Set("aws-profile", "from-config")
+	if got := configuredAWSProfile(fm); got != "from-config" {
+		t.Errorf("expected the config file's profile, got %q", got)
+	}
+
+	t.Setenv("CHAT_CLI_AWS_PROFILE", "from-env")
+	if got := configuredAWSProfile(fm); got != "from-env" {
+		t.Errorf("expected the environment's profile, got %q", got)
+	}
+
+	awsProfileFlag = "from-flag"
+	if got := configuredAWSProfile(fm); got != "from-flag" {
+		t.Errorf("expected the flag's profile, got %q", got)
+	}
+}
+
+func TestLoadAWSConfigUsesProfile(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(credentials, []byte(`[default]
+aws_access_key_id = DEFAULTKEY
+aws_secret_access_key = default-secret
+
+[work]
+aws_access_key_id = WORKKEY
+aws_secret_access_key = work-secret
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	accessKey := func() string {
+		t.Helper()
+		cfg, err := loadAWSConfig(context.Background(), "us-west-2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		creds, err := cfg.Credentials.Retrieve(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Region != "us-west-2" {
+			t.Errorf("expected the region to be kept, got %q", cfg.Region)
+		}
+		return creds.AccessKeyID
+	}
+
+	withAWSProfileFlag(t, "")
+	if got := accessKey(); got != "DEFAULTKEY" {
+		t.Errorf("expected the default profile's credentials, got %q", got)
+	}
+
+	awsProfileFlag = "work"
+	if got := accessKey(); got != "WORKKEY" {
+		t.Errorf("expected the work profile's credentials, got %q", got)
+	}
+
+	awsProfileFlag = "missing"
+	if _, err := loadAWSConfig(context.Background(), "us-west-2"); err == nil {
+		t.Error("expected an unknown AWS profile to be an error")
+	}
+}
+
+func TestConfiguredAssumeRole(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	withAWSProfileFlag(t, "work")
+	fm := &conf.FileManager{}
+
+	role, err := configuredAssumeRole(fm)
+	if err != nil || role.RoleARN != "" {
+		t.Fatalf("expected no role by default, got %+v (%v)", role, err)
+	}
+
+	viper.Set("auth.role_arn", "arn:aws:iam::123456789012:role/bedrock-user")
+	viper.Set("auth.mfa_serial", "arn:aws:iam::123456789012:mfa/me")
+	viper.Set("auth.session_duration", "2h")
+	role, err = configuredAssumeRole(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.MFASerial != "arn:aws:iam::123456789012:mfa/me" || role.Duration != 2*time.Hour || role.Source != "work" {
+		t.Errorf("unexpected role %+v", role)
+	}
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{"auth.session_duration", "forever", "use a duration"},
+		{"auth.session_duration", "5m", "must be from 15m0s to 12h0m0s"},
+		{"auth.role_arn", "bedrock-user", "invalid role ARN"},
+		{"auth.role_arn", "", "need auth.role_arn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			viper.Set("auth.role_arn", "arn:aws:iam::123456789012:role/bedrock-user")
+			viper.Set("auth.session_duration", "1h")
+			viper.Set(tt.key, tt.value)
+			if _, err := configuredAssumeRole(fm); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
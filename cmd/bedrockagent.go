@@ -0,0 +1,293 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	agentruntimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/chat-cli/chat-cli/utils"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cobra"
+)
+
+// agentEvents receives the events of an InvokeAgent response stream as
+// they arrive. Any handler may be nil.
+type agentEvents struct {
+	// OnChunk is called with each piece of the agent's answer.
+	OnChunk func(text string)
+	OnTrace func(agentruntimetypes.Trace)
+	// OnReturnControl is called when the agent asks the caller to run an
+	// action group itself, with the action it wants run.
+	OnReturnControl func(action string)
+}
+
+// bedrockAgentClient calls InvokeAgent on the Bedrock Agents runtime.
+type bedrockAgentClient struct {
+	runtime *bedrockagentruntime.Client
+}
+
+func newBedrockAgentClient(cfg aws.Config) *bedrockAgentClient {
+	return &bedrockAgentClient{bedrockagentruntime.NewFromConfig(cfg)}
+}
+
+// invokeAgent sends task to the agent alias in sessionID, delivering the
+// answer (and traces, if enableTrace) to events while the agent works.
+// Agents can call tools and models many times for one request, so only ctx
+// bounds it.
+func (c *bedrockAgentClient) invokeAgent(ctx context.Context, agentID, aliasID, sessionID, task string, enableTrace, endSession bool, events agentEvents) error {
+	out, err := c.runtime.InvokeAgent(ctx, &bedrockagentruntime.InvokeAgentInput{
+		AgentId:      aws.String(agentID),
+		AgentAliasId: aws.String(aliasID),
+		SessionId:    aws.String(sessionID),
+		InputText:    aws.String(task),
+		EnableTrace:  aws.Bool(enableTrace),
+		EndSession:   aws.Bool(endSession),
+	})
+	if err != nil {
+		return err
+	}
+
+	stream := out.GetStream()
+	defer func() {
+		_ = stream.Close()
+	}()
+	for event := range stream.Events() {
+		dispatchAgentEvent(event, events)
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("agent failed: %w", err)
+	}
+	return nil
+}
+
+func dispatchAgentEvent(event agentruntimetypes.ResponseStream, events agentEvents) {
+	switch e := event.(type) {
+	case *agentruntimetypes.ResponseStreamMemberChunk:
+		if events.OnChunk != nil {
+			events.OnChunk(string(e.Value.Bytes))
+		}
+	case *agentruntimetypes.ResponseStreamMemberTrace:
+		if events.OnTrace != nil && e.Value.Trace != nil {
+			events.OnTrace(e.Value.Trace)
+		}
+	case *agentruntimetypes.ResponseStreamMemberReturnControl:
+		if events.OnReturnControl == nil {
+			return
+		}
+		for _, in := range e.Value.InvocationInputs {
+			switch in := in.(type) {
+			case *agentruntimetypes.InvocationInputMemberMemberFunctionInvocationInput:
+				events.OnReturnControl(aws.ToString(in.Value.ActionGroup) + "." + aws.ToString(in.Value.Function))
+			case *agentruntimetypes.InvocationInputMemberMemberApiInvocationInput:
+				events.OnReturnControl(aws.ToString(in.Value.ActionGroup) + " " + aws.ToString(in.Value.HttpMethod) + " " + aws.ToString(in.Value.ApiPath))
+			}
+		}
+	}
+	// other event types (files, for instance) are safe to skip
+}
+
+// agentTraceLines renders a trace event as one line per rationale, action,
+// observation, guardrail decision, or failure.
+func agentTraceLines(trace agentruntimetypes.Trace) []string {
+	var lines []string
+	switch t := trace.(type) {
+	case *agentruntimetypes.TraceMemberPreProcessingTrace:
+		if out, ok := t.Value.(*agentruntimetypes.PreProcessingTraceMemberModelInvocationOutput); ok && out.Value.ParsedResponse != nil {
+			if text := aws.ToString(out.Value.ParsedResponse.Rationale); text != "" {
+				lines = append(lines, "~ "+oneLine(text))
+			}
+		}
+	case *agentruntimetypes.TraceMemberOrchestrationTrace:
+		switch step := t.Value.(type) {
+		case *agentruntimetypes.OrchestrationTraceMemberRationale:
+			if text := aws.ToString(step.Value.Text); text != "" {
+				lines = append(lines, "~ "+oneLine(text))
+			}
+		case *agentruntimetypes.OrchestrationTraceMemberInvocationInput:
+			if line := agentInvocationLine(step.Value); line != "" {
+				lines = append(lines, line)
+			}
+		case *agentruntimetypes.OrchestrationTraceMemberObservation:
+			if line := agentObservationLine(step.Value); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	case *agentruntimetypes.TraceMemberGuardrailTrace:
+		if t.Value.Action != "" {
+			lines = append(lines, "! guardrail "+strings.ToLower(string(t.Value.Action)))
+		}
+	case *agentruntimetypes.TraceMemberFailureTrace:
+		lines = append(lines, "! "+oneLine(aws.ToString(t.Value.FailureReason)))
+	}
+	return lines
+}
+
+// agentInvocationLine renders the action the agent is about to take, or ""
+// for kinds of action it doesn't show.
+func agentInvocationLine(in agentruntimetypes.InvocationInput) string {
+	switch {
+	case in.ActionGroupInvocationInput != nil:
+		a := in.ActionGroupInvocationInput
+		target := aws.ToString(a.Function)
+		if target == "" {
+			target = strings.TrimSpace(aws.ToString(a.Verb) + " " + aws.ToString(a.ApiPath))
+		}
+		return fmt.Sprintf("→ %s %s", aws.ToString(a.ActionGroupName), target)
+	case in.KnowledgeBaseLookupInput != nil:
+		kb := in.KnowledgeBaseLookupInput
+		return fmt.Sprintf("→ knowledge base %s: %s", aws.ToString(kb.KnowledgeBaseId), oneLine(aws.ToString(kb.Text)))
+	case in.CodeInterpreterInvocationInput != nil:
+		return "→ code interpreter: " + truncate(oneLine(aws.ToString(in.CodeInterpreterInvocationInput.Code)), 120)
+	case in.AgentCollaboratorInvocationInput != nil:
+		return "→ collaborator " + aws.ToString(in.AgentCollaboratorInvocationInput.AgentCollaboratorName)
+	}
+	return ""
+}
+
+// agentObservationLine renders what the agent got back from an action, or
+// "" for kinds of observation it doesn't show.
+func agentObservationLine(o agentruntimetypes.Observation) string {
+	switch {
+	case o.ActionGroupInvocationOutput != nil:
+		return "← " + truncate(oneLine(aws.ToString(o.ActionGroupInvocationOutput.Text)), 120)
+	case o.KnowledgeBaseLookupOutput != nil:
+		return fmt.Sprintf("← %d reference(s)", len(o.KnowledgeBaseLookupOutput.RetrievedReferences))
+	case o.CodeInterpreterInvocationOutput != nil:
+		out := o.CodeInterpreterInvocationOutput
+		text := aws.ToString(out.ExecutionOutput)
+		if out.ExecutionError != nil && *out.ExecutionError != "" {
+			text = "error: " + *out.ExecutionError
+		}
+		return "← " + truncate(oneLine(text), 120)
+	case o.RepromptResponse != nil:
+		return "← reprompt: " + truncate(oneLine(aws.ToString(o.RepromptResponse.Text)), 120)
+	}
+	return ""
+}
+
+// bedrockAgentCmd represents the bedrock-agent command
+var bedrockAgentCmd = &cobra.Command{
+	Use:   "bedrock-agent",
+	Short: "Run agents hosted in Amazon Bedrock Agents",
+	Long: `Run agents hosted in Amazon Bedrock Agents.
+
+These are agents configured in your AWS account, with their own action groups
+and knowledge bases. For chat-cli's own tool-using agent, use /agent in chat.`,
+}
+
+// bedrockAgentInvokeCmd represents the bedrock-agent invoke command
+var bedrockAgentInvokeCmd = &cobra.Command{
+	Use:   "invoke [task]",
+	Short: "Send a task to a Bedrock agent and stream its answer",
+	Long: `Send a task to a Bedrock agent alias and print its answer as it arrives.
+
+The task can be given as an argument or piped in. Each invocation starts a new
+session unless --session-id continues an earlier one; the session ID is printed
+to stderr when the agent finishes.
+
+> chat-cli bedrock-agent invoke --agent-id AGENT12345 --alias-id ALIAS12345 "Book a table for two at 7pm"
+> chat-cli bedrock-agent invoke --agent-id AGENT12345 --alias-id ALIAS12345 --session-id 3f1c... --trace "Make it 8pm"`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		agentID, err := cmd.Flags().GetString("agent-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		aliasID, err := cmd.Flags().GetString("alias-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		sessionID, err := cmd.Flags().GetString("session-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		trace, err := cmd.Flags().GetBool("trace")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		endSession, err := cmd.Flags().GetBool("end-session")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		var task string
+		if len(args) > 0 {
+			task = args[0]
+		} else if task, err = utils.ReadPipedStdin(); err != nil {
+			log.Fatalf("unable to read input: %v", err)
+		}
+		if strings.TrimSpace(task) == "" {
+			log.Fatal("no task given: pass it as an argument or pipe it in")
+		}
+
+		if sessionID == "" {
+			sessionID = uuid.NewV4().String()
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		var returned []string
+		events := agentEvents{
+			OnChunk: func(text string) {
+				fmt.Print(text)
+			},
+			OnReturnControl: func(action string) {
+				returned = append(returned, action)
+			},
+		}
+		if trace {
+			events.OnTrace = func(t agentruntimetypes.Trace) {
+				for _, line := range agentTraceLines(t) {
+					fmt.Fprintf(stderr, "\033[90m%s\033[0m\n", line)
+				}
+			}
+		}
+
+		if err := newBedrockAgentClient(cfg).invokeAgent(cmd.Context(), agentID, aliasID, sessionID, task, trace, endSession, events); err != nil {
+			log.Fatalf("error invoking agent: %v", err)
+		}
+		fmt.Println()
+
+		if len(returned) > 0 {
+			log.Fatalf("the agent returned control to run %s; actions that return control can't be run from the CLI yet", strings.Join(returned, ", "))
+		}
+		if !endSession {
+			fmt.Fprintf(stderr, "\033[90msession: %s\033[0m\n", sessionID)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bedrockAgentCmd)
+	bedrockAgentCmd.AddCommand(bedrockAgentInvokeCmd)
+
+	bedrockAgentInvokeCmd.Flags().String("agent-id", "", "ID of the agent to invoke")
+	bedrockAgentInvokeCmd.Flags().String("alias-id", "", "ID of the agent alias to invoke")
+	bedrockAgentInvokeCmd.Flags().String("session-id", "", "continue an earlier session (default: start a new one)")
+	bedrockAgentInvokeCmd.Flags().Bool("trace", false, "show the agent's reasoning, actions, and observations as it works")
+	bedrockAgentInvokeCmd.Flags().Bool("end-session", false, "end the session after this task")
+	for _, name := range []string{"agent-id", "alias-id"} {
+		if err := bedrockAgentInvokeCmd.MarkFlagRequired(name); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	agentruntimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+)
+
+func TestAgentStream(t *testing.T) {
+	invoke := func(t *testing.T, stream []byte, events agentEvents) error {
+		client := newBedrockAgentClient(newStreamTestConfig(t, stream))
+		return client.invokeAgent(t.Context(), "AGENT1", "ALIAS1", "session-1", "Book a table", true, false, events)
+	}
+
+	t.Run("delivers chunks, traces, and returned control", func(t *testing.T) {
+		stream := encodeFlowStream(t,
+			[2]string{"trace", `{"agentId":"AGENT1","trace":{"orchestrationTrace":{"rationale":{"text":"I should check\navailability."}}}}`},
+			[2]string{"trace", `{"trace":{"orchestrationTrace":{"invocationInput":{"invocationType":"ACTION_GROUP","actionGroupInvocationInput":{"actionGroupName":"Bookings","function":"check"}}}}}`},
+			[2]string{"trace", `{"trace":{"orchestrationTrace":{"observation":{"type":"ACTION_GROUP","actionGroupInvocationOutput":{"text":"7pm is free"}}}}}`},
+			// "Booked " and "for 7pm." in base64
+			[2]string{"chunk", `{"bytes":"Qm9va2VkIA=="}`},
+			[2]string{"chunk", `{"bytes":"Zm9yIDdwbS4="}`},
+			[2]string{"files", `{}`},
+			[2]string{"returnControl", `{"invocationId":"x","invocationInputs":[{"functionInvocationInput":{"actionGroup":"Payments","function":"charge"}}]}`},
+		)
+
+		var got []string
+		var answer strings.Builder
+		err := invoke(t, stream, agentEvents{
+			OnChunk: func(text string) { answer.WriteString(text) },
+			OnTrace: func(tr agentruntimetypes.Trace) { got = append(got, agentTraceLines(tr)...) },
+			OnReturnControl: func(action string) {
+				got = append(got, "return "+action)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"~ I should check availability.",
+			"→ Bookings check",
+			"← 7pm is free",
+			"return Payments.charge",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if answer.String() != "Booked for 7pm." {
+			t.Errorf("unexpected answer %q", answer.String())
+		}
+	})
+
+	t.Run("returns exceptions as errors", func(t *testing.T) {
+		stream := encodeFlowStream(t,
+			[2]string{"chunk", `{"bytes":"SGk="}`},
+			[2]string{"!accessDeniedException", `{"message":"not allowed"}`},
+		)
+
+		err := invoke(t, stream, agentEvents{})
+		if err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected the exception, got %v", err)
+		}
+	})
+}
+
+func TestInvokeAgent(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &gotBody)
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(encodeFlowStream(t, [2]string{"chunk", `{"bytes":"RG9uZS4="}`}))
+	}))
+	defer server.Close()
+
+	client := newBedrockAgentClient(testAWSConfig(server))
+	var answer string
+	err := client.invokeAgent(context.Background(), "AGENT1", "ALIAS1", "session-1", "Book a table", true, false, agentEvents{
+		OnChunk: func(text string) { answer += text },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/agents/AGENT1/agentAliases/ALIAS1/sessions/session-1/text" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if gotBody["inputText"] != "Book a table" || gotBody["enableTrace"] != true || gotBody["endSession"] != false {
+		t.Errorf("unexpected body %v", gotBody)
+	}
+	if answer != "Done." {
+		t.Errorf("unexpected answer %q", answer)
+	}
+}
+
+func TestAgentTraceLines(t *testing.T) {
+	traces := []agentruntimetypes.Trace{
+		&agentruntimetypes.TraceMemberPreProcessingTrace{Value: &agentruntimetypes.PreProcessingTraceMemberModelInvocationOutput{
+			Value: agentruntimetypes.PreProcessingModelInvocationOutput{ParsedResponse: &agentruntimetypes.PreProcessingParsedResponse{Rationale: aws.String("The input is valid.")}},
+		}},
+		&agentruntimetypes.TraceMemberOrchestrationTrace{Value: &agentruntimetypes.OrchestrationTraceMemberInvocationInput{
+			Value: agentruntimetypes.InvocationInput{KnowledgeBaseLookupInput: &agentruntimetypes.KnowledgeBaseLookupInput{KnowledgeBaseId: aws.String("KB1"), Text: aws.String("opening hours")}},
+		}},
+		&agentruntimetypes.TraceMemberOrchestrationTrace{Value: &agentruntimetypes.OrchestrationTraceMemberObservation{
+			Value: agentruntimetypes.Observation{KnowledgeBaseLookupOutput: &agentruntimetypes.KnowledgeBaseLookupOutput{RetrievedReferences: make([]agentruntimetypes.RetrievedReference, 2)}},
+		}},
+		&agentruntimetypes.TraceMemberGuardrailTrace{Value: agentruntimetypes.GuardrailTrace{Action: agentruntimetypes.GuardrailActionIntervened}},
+		&agentruntimetypes.TraceMemberFailureTrace{Value: agentruntimetypes.FailureTrace{FailureReason: aws.String("Lambda timed out")}},
+	}
+
+	var got []string
+	for _, trace := range traces {
+		got = append(got, agentTraceLines(trace)...)
+	}
+
+	want := []string{
+		"~ The input is valid.",
+		"→ knowledge base KB1: opening hours",
+		"← 2 reference(s)",
+		"! guardrail intervened",
+		"! Lambda timed out",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -4,20 +4,21 @@ Copyright © 2024 Micah Walter
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrock"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types" //nolint:goimports // false positive from CI version diff
-	"github.com/chat-cli/chat-cli/db"
-	"github.com/chat-cli/chat-cli/factory"
 	"github.com/chat-cli/chat-cli/repository"
 	"github.com/chat-cli/chat-cli/tools"
 	"github.com/chat-cli/chat-cli/utils"
@@ -37,9 +38,14 @@ Available subcommands:
   - list: View your recent chat conversations
 
 To start a new interactive chat session, run 'chat-cli' (without the 'chat' subcommand).
-To resume an existing conversation, use: chat-cli --chat-id <id>`,
+To resume an existing conversation, use: chat-cli --chat-id <id>
+To start or resume a conversation by name, use: chat-cli --chat-name <name>`,
+	Example: `  chat-cli chat list
+  chat-cli --chat-id <id>
+  chat-cli --chat-name <name>`,
 
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
 
 		fm, err := conf.NewFileManager("chat-cli")
 		if err != nil {
@@ -50,12 +56,6 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 			log.Fatal(initErr)
 		}
 
-		// Get SQLite database path
-		dbPath := fm.GetDBPath()
-
-		// Get DBDriver from config
-		driver := fm.GetDBDriver()
-
 		// get options - check if we have a parent (called as subcommand) or not (called from root)
 		var flagCmd *cobra.Command
 		if cmd.Parent() != nil {
@@ -79,6 +79,21 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
+		inferenceProfile, err := flagCmd.PersistentFlags().GetString("inference-profile")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if err := checkInferenceProfile(inferenceProfile); err != nil {
+			log.Fatal(err)
+		}
+
+		// --skip-validation applies to every model the session uses: the
+		// chat model, the worker model, and models picked with /model
+		skipValidation, err := flagCmd.PersistentFlags().GetBool("skip-validation")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
 		systemFlag, err := flagCmd.PersistentFlags().GetString("system")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
@@ -103,15 +118,190 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 			log.Fatal(err)
 		}
 
+		reasoningFlagValue, err := reasoningFlag(flagCmd.PersistentFlags())
+		if err != nil {
+			log.Fatal(err)
+		}
+		reasoningDisplay := fmt.Sprint(fm.GetConfigValue("reasoning", reasoningFlagValue, reasoningShow))
+		if err := validateReasoningDisplay(reasoningDisplay); err != nil {
+			log.Fatal(err)
+		}
+
 		noContextFile, err := flagCmd.PersistentFlags().GetBool("no-context-file")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
+		repoMapEnabled, err := flagCmd.PersistentFlags().GetBool("repo-map")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		repoMapTokens, err := flagCmd.PersistentFlags().GetInt("repo-map-tokens")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		files, err := flagCmd.PersistentFlags().GetStringArray("file")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		contextDir, err := flagCmd.PersistentFlags().GetString("context-dir")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		contextDirTokens, err := flagCmd.PersistentFlags().GetInt("context-dir-tokens")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		contextInclude, err := flagCmd.PersistentFlags().GetStringArray("context-include")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		kbName, err := flagCmd.PersistentFlags().GetString("kb")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		kbTopK, err := flagCmd.PersistentFlags().GetInt("kb-top-k")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		kbRerank, err := flagCmd.PersistentFlags().GetBool("kb-rerank")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		requireCitations, err := flagCmd.PersistentFlags().GetBool("require-citations")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if requireCitations && kbName == "" {
+			log.Fatal("--require-citations needs --kb")
+		}
+
+		enableTools, err := flagCmd.PersistentFlags().GetBool("enable-tools")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		workerModelFlag, err := flagCmd.PersistentFlags().GetString("worker-model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		verifyEnabled, err := flagCmd.PersistentFlags().GetBool("verify")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		verifyCommandFlag, err := flagCmd.PersistentFlags().GetString("verify-command")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		dryRun, err := flagCmd.PersistentFlags().GetBool("dry-run")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		verbose, err := flagCmd.PersistentFlags().GetBool("verbose")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		nonInteractive, err := flagCmd.PersistentFlags().GetBool("non-interactive")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		plain, err := flagCmd.PersistentFlags().GetBool("plain")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		speakStream, err := flagCmd.PersistentFlags().GetBool("speak-stream")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		// with --non-interactive only the replies go to stdout, so they can
+		// be piped; everything else is shown on stderr
+		display := stdout
+		if nonInteractive {
+			display = stderr
+		}
+
+		// in a terminal the chat runs in the full-screen chat view; what's
+		// shown while the chat is set up is kept for the top of it
+		useView := !nonInteractive && !plain && !dryRun && utils.ChatViewAvailable()
+		var setupOutput bytes.Buffer
+		if useView {
+			display = &setupOutput
+		}
+
+		pagerFlag, err := flagCmd.PersistentFlags().GetString("pager")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		injectionCheckFlag, err := flagCmd.PersistentFlags().GetString("injection-check")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		agentPromptFlag, err := flagCmd.PersistentFlags().GetString("agent-prompt")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		agentPromptAppendFlag, err := flagCmd.PersistentFlags().GetString("agent-prompt-append")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		guardrailIDFlag, err := flagCmd.PersistentFlags().GetString("guardrail-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		guardrailVersionFlag, err := flagCmd.PersistentFlags().GetString("guardrail-version")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
 		// Get configuration values with precedence order (flag -> config -> default)
 		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
 		customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string)
 		systemPrompt := fm.GetConfigValue("system-prompt", systemFlag, "").(string)
+		verifyCommand := fm.GetConfigValue("verify-command", verifyCommandFlag, "").(string)
+		workerModelId := fm.GetConfigValue("worker-model-id", workerModelFlag, "").(string)
+		pagerMode := fmt.Sprint(fm.GetConfigValue("pager", pagerFlag, pagerOff))
+		if err := validatePagerMode(pagerMode); err != nil {
+			log.Fatal(err)
+		}
+		guard := injectionGuard{
+			mode:  fmt.Sprint(fm.GetConfigValue("injection-check", injectionCheckFlag, injectionWarn)),
+			tools: enableTools,
+			out:   os.Stderr,
+		}
+		if err := validateInjectionCheck(guard.mode); err != nil {
+			log.Fatal(err)
+		}
+		agentPrompt := fm.GetConfigValue("agent-prompt", agentPromptFlag, "").(string)
+		agentPromptAppend := fm.GetConfigValue("agent-prompt-append", agentPromptAppendFlag, "").(string)
+		if _, err := buildAgentInstructions(agentPrompt, agentPromptAppend, agentPromptVars("")); err != nil {
+			log.Fatal(err)
+		}
+		guardrail := buildGuardrailStreamConfig(
+			fm.GetConfigValue("guardrail-id", guardrailIDFlag, "").(string),
+			fm.GetConfigValue("guardrail-version", guardrailVersionFlag, "").(string),
+		)
 
 		// #88: when no explicit system prompt was supplied (flag or config),
 		// automatically discover a project-context file (AGENTS.md/CLAUDE.md/
@@ -134,26 +324,96 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 							fmt.Fprintf(os.Stderr, "warning: project context file %s exceeds 32KB and was truncated\n", displayPath)
 						}
 						systemPrompt = content
-						fmt.Printf("\033[90mUsing project context: %s\033[0m\n", displayPath)
+						fmt.Fprintf(display, "\033[90mUsing project context: %s\033[0m\n", displayPath)
+					}
+				}
+			}
+		}
+
+		// --repo-map adds a compact tree + symbol outline of the current
+		// repository on top of whatever system prompt was resolved above.
+		var repoMap string
+		if repoMapEnabled {
+			cwd, cwdErr := os.Getwd()
+			if cwdErr != nil {
+				fmt.Fprintf(os.Stderr, "warning: unable to determine current directory for repo map: %v\n", cwdErr)
+			} else {
+				root := utils.FindGitBoundary(cwd)
+				if root == "" {
+					root = cwd
+				}
+
+				builtMap, truncated, mapErr := buildRepoMap(root, repoMapTokens)
+				if mapErr != nil {
+					fmt.Fprintf(os.Stderr, "warning: unable to build repo map: %v\n", mapErr)
+				} else {
+					repoMap = builtMap
+					if truncated {
+						fmt.Fprintf(os.Stderr, "warning: repo map exceeds %d tokens and was truncated\n", repoMapTokens)
 					}
+					systemPrompt = withRepoMap(systemPrompt, repoMap)
+					fmt.Fprintf(display, "\033[90mUsing repo map: %s (~%d tokens)\033[0m\n", root, estimateTokens(repoMap))
 				}
 			}
 		}
 
+		// --file and --context-dir text is added to the system prompt so it
+		// stays in view (and cached) for the whole session
+		fileChunks, fileTokens, err := loadFileChunks(files, guard)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(fileChunks) > 0 {
+			fmt.Fprintf(display, "\033[90mUsing files: %s (~%d tokens)\033[0m\n", strings.Join(files, ", "), fileTokens)
+		}
+		contextDoc, contextStats, err := loadContextDir(contextDir, contextDirTokens, contextInclude)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if contextDoc != "" {
+			fileChunks = append(fileChunks, contextDoc)
+			fmt.Fprintf(display, "\033[90mUsing context dir: %s (%d files, ~%d tokens)\033[0m\n", contextDir, contextStats.files, contextStats.tokens)
+		}
+		systemPrompt = withFileContext(systemPrompt, fileChunks)
+
 		// Ensure custom-arn takes precedence over model-id when both are set
-		// If custom-arn is set (from any source), use it; otherwise use model-id
+		// If custom-arn is set (from any source), use it; otherwise use model-id.
+		// --inference-profile overrides both.
 		var finalModelId string
-		if customArn != "" {
+		if inferenceProfile != "" {
+			finalModelId = inferenceProfile
+		} else if customArn != "" {
 			finalModelId = customArn
 		} else {
 			finalModelId = modelId
 		}
 
+		// fill in inference parameters the flags and profile left unset
+		if err := applyModelDefaults(flagCmd.PersistentFlags(), finalModelId); err != nil {
+			log.Fatal(err)
+		}
+
 		chatId, err := flagCmd.PersistentFlags().GetString("chat-id")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
+		chatName, err := flagCmd.PersistentFlags().GetString("chat-name")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		chatName = strings.TrimSpace(chatName)
+		if chatName != "" && chatId != "" {
+			log.Fatal("--chat-name and --chat-id can't be used together")
+		}
+
+		// the persona's settings were applied to the flags before Run; its
+		// name is kept with the chat
+		persona, err := flagCmd.PersistentFlags().GetString("persona")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
 		temperature, err := optionalFloat32Flag(flagCmd.PersistentFlags(), "temperature")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
@@ -170,45 +430,72 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 		}
 
 		// set up connection to AWS
-		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+		cfg, err := loadAWSConfig(ctx, region)
 		if err != nil {
 			log.Fatalf("unable to load AWS config: %v", err)
 		}
 
-		var modelIdString string
+		bedrockSvc := bedrock.NewFromConfig(cfg)
+		models := newModelCatalog(cfg)
 
-		if customArn == "" && !isInferenceProfileID(finalModelId) {
-			// Using a foundation model-id, validate with Bedrock
-			bedrockSvc := bedrock.NewFromConfig(cfg)
-
-			// get foundation model details
-			model, modelErr := bedrockSvc.GetFoundationModel(context.TODO(), &bedrock.GetFoundationModelInput{
-				ModelIdentifier: &finalModelId,
-			})
-			if modelErr != nil {
-				log.Fatalf("error: %v", modelErr)
-			}
-
-			// check if this is a text model
-			if !slices.Contains(model.ModelDetails.OutputModalities, "TEXT") {
-				log.Fatalf("model %s is not a text model, so it can't be used with the chat function", *model.ModelDetails.ModelId)
+		// a dry run never calls Bedrock, so the model isn't validated
+		modelIdString := finalModelId
+		if !dryRun {
+			modelIdString, err = resolveChatModelID(ctx, models, finalModelId, customArn != "" || skipValidation)
+			if err != nil {
+				log.Fatal(err)
 			}
+		}
 
-			// check if model supports streaming
-			if !*model.ModelDetails.ResponseStreamingSupported {
-				log.Fatalf("model %s does not support streaming so it can't be used with the chat function", *model.ModelDetails.ModelId)
+		// a worker model handles routine tool follow-ups; reasoning blocks
+		// are tied to the model that produced them, so it's skipped when
+		// thinking is on
+		var turnOpts chatTurnOptions
+		if workerModelId != "" && !dryRun {
+			if thinkingEnabled {
+				fmt.Fprintf(os.Stderr, "warning: --worker-model-id is ignored when --thinking is enabled\n")
+			} else {
+				turnOpts.WorkerModelID, err = resolveChatModelID(ctx, models, workerModelId, strings.HasPrefix(workerModelId, "arn:") || skipValidation)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Fprintf(display, "\033[90mUsing worker model for tool steps: %s\033[0m\n", turnOpts.WorkerModelID)
 			}
-
-			modelIdString = *model.ModelDetails.ModelId
-		} else {
-			// Inference profile or custom ARN — pass through to Converse directly
-			modelIdString = finalModelId
 		}
 
 		svc := bedrockruntime.NewFromConfig(cfg)
 
 		conf := buildInferenceConfiguration(maxTokens, temperature, topP)
 
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		// --chat-name resumes the chat with that name in this workspace, or
+		// starts one under it
+		if chatName != "" {
+			cwd, err := os.Getwd()
+			if err != nil {
+				log.Fatal(err)
+			}
+			var created bool
+			chatId, created, err = chatIdForName(ctx, repository.NewChatNameRepository(database), chatWorkspace(cwd), chatName, !dryRun)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !dryRun && created {
+				fmt.Fprintf(display, "\033[90mStarting chat %q\033[0m\n", chatName)
+			} else if !dryRun {
+				fmt.Fprintf(display, "\033[90mResuming chat %q\033[0m\n", chatName)
+			}
+		}
+
 		if chatId == "" {
 			chatSessionId := uuid.NewV4()
 			chatId = chatSessionId.String()
@@ -222,22 +509,66 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 			ModelId:                      aws.String(modelIdString),
 			InferenceConfig:              &conf,
 			RequestMetadata:              metadata,
+			GuardrailConfig:              guardrail,
 			System:                       withSystemCachePoint(buildSystemContentBlocks(systemPrompt)),
 			AdditionalModelRequestFields: buildReasoningConfig(modelIdString, thinkingEnabled, thinkingBudget, thinkingEffort),
 		}
 
-		// Tool use is always available - if a model/request rejects the
-		// ToolConfiguration field, converseStreamWithFallbacks retries once
-		// without it and this session continues with tools disabled.
+		// The built-in tools are offered in conversation only with
+		// --enable-tools (/agent always has them). If a model/request
+		// rejects the ToolConfiguration field, converseStreamWithFallbacks
+		// retries once without it and this session continues with tools
+		// disabled.
 		registry := tools.NewRegistry()
 		registry.Register(tools.NewReadFileTool())
+		registry.Register(tools.NewListFilesTool())
 		registry.Register(tools.NewWriteFileTool())
 		registry.Register(tools.NewRunShellTool())
 		registry.Register(tools.NewGitDiffTool())
+		registry.Register(tools.NewCalculatorTool())
+		registry.Register(tools.NewConvertUnitsTool())
+		registry.Register(tools.NewDateMathTool())
+		registry.Register(tools.NewRandomTool())
+
+		// sql_query reads the chat history through its own read-only
+		// handle, so a query can never change it
+		var sqlQuery *tools.SQLQueryTool
+		historyDB, err := openReadOnlyHistory(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if historyDB != nil {
+			defer func() {
+				_ = historyDB.Close()
+			}()
+			sqlQuery = tools.NewSQLQueryTool(historyDB)
+			registry.Register(sqlQuery)
+		}
+
+		// create_ticket is only offered once a tracker is configured
+		ticketTool, err := configuredTicketTool(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if ticketTool != nil {
+			registry.Register(ticketTool)
+		}
+
+		chatRegistry := tools.NewRegistry()
+		if enableTools {
+			chatRegistry = registry
+		}
+
+		// tool invocations and results are shown inline, dimmed
+		renderer := newReplyRenderer(os.Stdout, display)
+		renderer.hideReasoning = reasoningDisplay == reasoningHide
+		renderer.reasoningHint = "/reasoning to show"
+		turnOpts.OnToolCall = renderer.onToolCall
+		turnOpts.OnToolResult = renderer.onToolResult
 
 		// The permission gate is constructed unconditionally: it's inert
 		// unless a registered tool actually requires confirmation.
-		// write_file/run_shell do; read_file/git_diff don't.
+		// write_file/run_shell do; the read-only tools don't.
 		var repoRoot string
 		if toolCwd, cwdErr := os.Getwd(); cwdErr == nil {
 			repoRoot = utils.FindGitBoundary(toolCwd)
@@ -246,168 +577,479 @@ To resume an existing conversation, use: chat-cli --chat-id <id>`,
 		if approvalStoreErr != nil {
 			log.Fatalf("unable to initialize tool approval store: %v", approvalStoreErr)
 		}
+		// stdin holds the user's turns with --non-interactive, so there is no
+		// one to confirm an action and anything not already approved is denied
 		permissionGate := NewInteractivePermissionGate(approvalStore, os.Stdin, os.Stdout)
+		if nonInteractive {
+			permissionGate = NewInteractivePermissionGate(approvalStore, strings.NewReader(""), os.Stderr)
+		}
 
-		sendFn := func(ctx context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
-			out, streamErr := converseStreamWithFallbacks(ctx, svc, in)
+		// token usage is counted for the per-turn footer and /stats, and
+		// throttled requests are retried with a spinner showing the wait
+		usage := newUsageTracker()
+		retrier := newBedrockRetrier(fm)
+		retriedSvc := bedrockruntime.NewFromConfig(cfg, retrier.clientOptions)
+		sendFn := meteredSend(retrier.wrap(injectedFaults.wrap(func(ctx context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+			out, streamErr := converseStreamWithFallbacks(ctx, retriedSvc, in)
 			if streamErr != nil {
 				return nil, streamErr
 			}
 			return out.GetStream().Events(), nil
+		})), usage)
+		if !dryRun {
+			priceInferenceProfiles(ctx, bedrockSvc, usage, modelIdString, workerModelId)
 		}
 
 		// initial prompt
-		fmt.Println()
-		fmt.Printf("Hi there. You can ask me stuff!\n")
-		fmt.Println()
-
-		config := db.Config{
-			Driver: driver,
-			Name:   dbPath,
-		}
-
-		database, err := factory.CreateDatabase(&config)
-		if err != nil {
-			log.Fatalf("Failed to create database: %v", err)
-		}
-		defer func() {
-			if err := database.Close(); err != nil {
-				log.Printf("Warning: failed to close database: %v", err)
-			}
-		}()
-
-		// Run migrations to ensure tables exist
-		if err := database.Migrate(); err != nil {
-			log.Fatalf("Failed to migrate database: %v", err)
+		if !dryRun && !nonInteractive {
+			fmt.Fprintln(display)
+			fmt.Fprintf(display, "Hi there. You can ask me stuff!\n")
+			fmt.Fprintln(display)
 		}
 
 		// Create repositories
 		chatRepo := repository.NewChatRepository(database)
+		usage.save = usageRecorder(ctx, repository.NewUsageRepository(database), chatId)
+
+		// --kb looks up the chunks relevant to each message as it's sent
+		var retriever *kbRetriever
+		if kbName != "" {
+			retriever, err = loadKBRetriever(ctx, repository.NewKnowledgeBaseRepository(database), kbName, kbTopK, invokeModelWith(svc))
+			if err != nil {
+				log.Fatal(err)
+			}
+			retriever.reranker, err = configuredKBReranker(fm, cfg, kbRerank)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !dryRun {
+				fmt.Fprintf(display, "\033[90mUsing knowledge base: %s (%d chunks, top %d per message)\033[0m\n", kbName, len(retriever.chunks), kbTopK)
+			}
+		}
 
 		// load saved conversation
+		var lastReplyID int
+		inferenceDefaults := conf
+		chatSettings := repository.NewChatSettingsRepository(database)
+		var settings map[string]string
 		if chatId != "" {
-			if chats, err := chatRepo.GetMessages(chatId); err != nil {
+			if chats, err := chatRepo.GetMessages(ctx, chatId); err != nil {
 				log.Printf("Failed to load messages: %v", err)
 			} else {
-				for _, chat := range chats {
-					if chat.Persona == "User" {
-						fmt.Printf("[User]: %s\n", chat.Message)
-						userMsg := types.Message{
-							Role: types.ConversationRoleUser,
-							Content: []types.ContentBlock{
-								&types.ContentBlockMemberText{
-									Value: chat.Message,
-								},
-							},
+				if useView {
+					writeViewHistory(display, chats)
+				} else if !dryRun && !nonInteractive {
+					for _, chat := range chats {
+						if chat.Persona == "User" {
+							fmt.Printf("[User]: %s\n", chat.Message)
+						} else if chat.StopReason == string(stopReasonInterrupted) {
+							fmt.Printf("[Assistant]: %s \033[90m(cancelled)\033[0m\n", chat.Message)
+						} else if chat.Resumes > 0 {
+							fmt.Printf("[Assistant]: %s \033[90m(resumed after the stream broke off)\033[0m\n", chat.Message)
+						} else {
+							fmt.Printf("[Assistant]: %s\n", chat.Message)
 						}
-						converseStreamInput.Messages = append(converseStreamInput.Messages, userMsg)
-					} else {
-						fmt.Printf("[Assistant]: %s\n", chat.Message)
-						assistantMsg := types.Message{
-							Role: types.ConversationRoleAssistant,
-							Content: []types.ContentBlock{
-								&types.ContentBlockMemberText{
-									Value: chat.Message,
-								},
-							},
-						}
-						converseStreamInput.Messages = append(converseStreamInput.Messages, assistantMsg)
 					}
 				}
+
+				if n := len(chats); n > 0 && chats[n-1].Persona == "Assistant" {
+					lastReplyID = chats[n-1].ID
+				}
+
+				messages, warnings := historyMessages(chats)
+				for _, warning := range warnings {
+					fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+				}
+				converseStreamInput.Messages = append(converseStreamInput.Messages, messages...)
+			}
+
+			// parameters changed with /set are kept with the chat, as is
+			// the persona it was started with
+			saved, err := chatSettings.Get(ctx, chatId)
+			if err != nil {
+				log.Printf("Failed to load chat settings: %v", err)
+			}
+			if started, ok := saved[personaSettingKey]; ok {
+				delete(saved, personaSettingKey)
+				if persona == "" && !dryRun {
+					fmt.Fprintf(display, "\033[90mThis chat was started as persona %s; pass --persona %s to take it on again\033[0m\n", started, started)
+				}
+			}
+			if len(saved) > 0 {
+				var warnings []string
+				settings, warnings = restoreSettings(&conf, saved)
+				for _, warning := range warnings {
+					fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+				}
+				if len(settings) > 0 && !dryRun {
+					fmt.Fprintf(display, "\033[90mUsing this chat's settings: %s\033[0m\n", formatSettings(settings))
+				}
 			}
 		}
 
-		// tty-loop
-		for {
-			// Add a single newline for spacing
-			fmt.Println()
+		// --dry-run shows what the first turn would carry before any new
+		// message: the system prompt, a resumed chat's history, parameters,
+		// and tools
+		if dryRun {
+			converseStreamInput.ToolConfig = chatRegistry.ToolConfiguration()
+			printDryRun(os.Stdout, converseStreamInput)
+			return
+		}
 
-			// gets user input with fancy bubble input
-			prompt := utils.StringPrompt("")
+		// --speak-stream reads each reply aloud as it arrives
+		var speaker *streamSpeaker
+		if speakStream {
+			speaker, err = newStreamSpeaker(ctx, fm, cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 
-			// Print the user's input as plain text with gray color
-			fmt.Printf("\033[90m> %s\033[0m", strings.TrimSpace(prompt))
+		// from here the chat view has the terminal: everything the chat
+		// writes goes into its conversation, and permission prompts are
+		// answered in its input box
+		var view *utils.ChatView
+		closeView := func() {}
+		if useView {
+			view, err = utils.StartChatView(interrupts.interrupt)
+			if err != nil {
+				display = stdout
+			} else {
+				savedStdout, savedStderr, savedLog := stdout, stderr, log.Writer()
+				closeView = func() {
+					transcript, closeErr := view.Close()
+					stdout, stderr = savedStdout, savedStderr
+					log.SetOutput(savedLog)
+					// the conversation stays in the terminal's scrollback
+					fmt.Fprint(os.Stdout, transcript)
+					if closeErr != nil {
+						log.Printf("chat view: %v", closeErr)
+					}
+					closeView = func() {}
+				}
+				defer func() { closeView() }()
 
-			// check for special words
+				display, stdout, stderr = view, view, view
+				log.SetOutput(view)
+				renderer.out, renderer.display = view, view
+				retrier.out, retrier.animate = view, true
+				permissionGate = NewInteractivePermissionGate(approvalStore, view, view)
+			}
+			fmt.Fprint(display, setupOutput.String())
+		}
 
-			// quit the program
-			if prompt == "quit\n" || prompt == "/quit\n" {
-				os.Exit(0)
+		if persona != "" {
+			if err := chatSettings.Set(ctx, chatId, personaSettingKey, persona); err != nil {
+				log.Printf("Failed to save the chat's persona: %v", err)
 			}
+			fmt.Fprintf(display, "\033[90mUsing persona %s\033[0m\n", persona)
+		}
+
+		annotationRepo := repository.NewAnnotationRepository(database)
+		autoArchiveChats(ctx, fm, chatRepo, annotationRepo, chatId)
+
+		session := &chatSession{
+			input:             converseStreamInput,
+			chatId:            chatId,
+			chatRepo:          chatRepo,
+			annotations:       annotationRepo,
+			feedback:          repository.NewFeedbackRepository(database),
+			sqlQuery:          sqlQuery,
+			lastReplyID:       lastReplyID,
+			inferenceDefaults: inferenceDefaults,
+			settings:          settings,
+			chatSettings:      chatSettings,
+			scratchDir:        filepath.Join(fm.DataPath, "scratch"),
+			systemPrompt:      systemPrompt,
+			thinkingEnabled:   thinkingEnabled,
+			thinkingBudget:    thinkingBudget,
+			thinkingEffort:    thinkingEffort,
+			repoMap:           repoMap,
+			fileChunks:        fileChunks,
+			verifyCommand:     verifyCommand,
+			pager:             pagerMode,
+			agentPrompt:       agentPrompt,
+			renderer:          renderer,
+			agentPromptAppend: agentPromptAppend,
+			usage:             usage,
+			showStats:         true,
+			titles:            make(chan chatTitle, 1),
+			converse: func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+				return svc.Converse(ctx, input)
+			},
+			resolveModel: func(id string) (string, error) {
+				return resolveChatModelID(ctx, models, id, strings.HasPrefix(id, "arn:") || skipValidation)
+			},
+			runTurn: func(ctx context.Context, input *bedrockruntime.ConverseStreamInput, callbacks turnCallbacks) (chatTurnResult, error) {
+				opts := turnOpts
+				opts.OnToolCall = callbacks.onToolCall
+				opts.OnToolResult = callbacks.onToolResult
+				return runChatTurnWithTools(ctx, sendFn, input, registry, permissionGate, callbacks.onText, callbacks.onReasoning, opts)
+			},
+			agentRunsDir: filepath.Join(fm.DataPath, agentRunsDirName),
+			out:          display,
+			ctx:          ctx,
+		}
+
+		// a resumed chat keeps the title it already has
+		if title, err := chatRepo.GetTitle(ctx, chatId); err == nil && title != "" {
+			session.titled = true
+		}
 
-			userMsg := types.Message{
-				Role: types.ConversationRoleUser,
-				Content: []types.ContentBlock{
-					&types.ContentBlockMemberText{
-						Value: prompt,
-					},
-				},
+		// edits to config.yaml are picked up between turns
+		watcher := newConfigWatcher(fm, map[string]string{
+			"model-id":            modelIdFlag,
+			"custom-arn":          customArnFlag,
+			"system-prompt":       systemFlag,
+			"verify-command":      verifyCommandFlag,
+			"worker-model-id":     workerModelFlag,
+			"pager":               pagerFlag,
+			"agent-prompt":        agentPromptFlag,
+			"agent-prompt-append": agentPromptAppendFlag,
+		})
+		watcher.start()
+
+		// gets user input with fancy bubble input, or one line of stdin at a
+		// time with --non-interactive
+		nextTurn := func() (string, bool) { return utils.StringPrompt(""), true }
+		if nonInteractive {
+			nextTurn = newLineTurnReader(os.Stdin)
+		}
+		if view != nil {
+			nextTurn = view.NextInput
+		}
+
+		// kbMatches are the chunks retrieved for the latest message, kept
+		// for /retry, which sends it again as it is
+		var kbMatches []kbMatch
+
+		// tty-loop
+		for {
+			// Add a single newline for spacing
+			if !nonInteractive && view == nil {
+				fmt.Println()
 			}
 
-			converseStreamInput.Messages = append(converseStreamInput.Messages, userMsg)
+			applyConfigChanges(session, watcher, watcher.poll())
+			session.saveTitles()
+			if view != nil {
+				view.SetStatus(chatStatusLine(session))
+			}
 
-			// Use the repository without knowing the underlying database type
-			chat := &repository.Chat{
-				ChatId:  chatId,
-				Persona: "User",
-				Message: prompt,
+			prompt, ok := nextTurn()
+			if !ok {
+				return
 			}
 
-			if createErr := chatRepo.Create(chat); createErr != nil {
-				log.Printf("Failed to create chat: %v", createErr)
+			// Print the user's input as plain text with gray color, or under
+			// a header in the chat view
+			if view != nil {
+				fmt.Fprintf(view, "%s%s\n", chatMessageHeader("User", time.Now()), strings.TrimSpace(prompt))
+			} else if !nonInteractive {
+				fmt.Fprintf(stdout, "\033[90m> %s\033[0m", strings.TrimSpace(prompt))
 			}
 
-			// Add an extra line between user message and assistant response
-			fmt.Print("\n\n* ")
+			// the file may have been edited while the prompt was open
+			if changed := watcher.poll(); len(changed) > 0 {
+				fmt.Fprintln(display)
+				applyConfigChanges(session, watcher, changed)
+			}
 
-			reasoningActive := false
-			onText := func(ctx context.Context, part string) error {
-				if reasoningActive {
-					fmt.Print("\033[0m\n\n")
-					reasoningActive = false
+			// slash commands are handled locally and never sent to the model
+			handled, cmdErr := dispatchSlashCommand(session, prompt)
+			if errors.Is(cmdErr, errQuitChat) {
+				closeView()
+				os.Exit(0)
+			}
+			// /retry has already dropped the last reply, so the question
+			// it answered is sent again as it is
+			retry := errors.Is(cmdErr, errRetryTurn)
+			if handled && !retry {
+				fmt.Fprintln(display)
+				if cmdErr != nil {
+					fmt.Fprintf(stderr, "error: %v\n", cmdErr)
 				}
-				fmt.Print(part)
-				return nil
+				continue
 			}
 
-			onReasoning := func(ctx context.Context, part string) error {
-				if !reasoningActive {
-					fmt.Print("\033[90m[thinking] ")
-					reasoningActive = true
+			if !retry {
+				// images attached with /image go out with this message
+				images := session.takeImages()
+				userMsg := userMessageWithImages(images, prompt)
+				// as are snippets loaded with /scratch, which aren't saved
+				// in the chat history either
+				userMsg.Content = append(session.takeScratch(), userMsg.Content...)
+
+				// retrieved chunks go with this message only; they aren't
+				// saved in the chat history
+				if retriever != nil {
+					matches, kbErr := retriever.retrieve(ctx, prompt)
+					kbMatches = matches
+					if kbErr != nil {
+						fmt.Fprintf(stderr, "\nwarning: %v", kbErr)
+					} else if len(matches) > 0 {
+						userMsg.Content = append([]types.ContentBlock{kbContentBlock(kbName, matches, requireCitations)}, userMsg.Content...)
+						fmt.Fprintf(display, "\n\033[90m%s\033[0m", kbSourcesLine(matches))
+						if line := kbRerankLine(matches, kbTopK); verbose && line != "" {
+							fmt.Fprintf(display, "\n\033[90m%s\033[0m", line)
+						}
+					}
+				}
+				converseStreamInput.Messages = append(converseStreamInput.Messages, userMsg)
+
+				for _, img := range images {
+					session.recordImage(img)
 				}
-				fmt.Print(part)
-				return nil
+				session.record("User", prompt)
+			}
+
+			inferenceConfig := converseStreamInput.InferenceConfig
+			if retry && session.retryTemperature != nil {
+				converseStreamInput.InferenceConfig = withTemperature(inferenceConfig, *session.retryTemperature)
+				session.retryTemperature = nil
 			}
 
-			out, err := runChatTurnWithTools(context.Background(), sendFn, converseStreamInput, registry, permissionGate, onText, onReasoning)
-			if err != nil && hasSystemCachePoint(converseStreamInput.System) {
+			// Add an extra line between user message and assistant response
+			if view != nil {
+				fmt.Fprintf(view, "\n%s", chatMessageHeader("Assistant", time.Now()))
+			} else if !nonInteractive {
+				fmt.Print("\n\n* ")
+			}
+
+			renderer.startReply()
+
+			session.usage.startTurn()
+			turnStart := time.Now()
+
+			// Ctrl+C while the reply streams cancels turnCtx, not the chat
+			turnCtx, endTurn := interrupts.begin(ctx)
+			turnMessages := len(converseStreamInput.Messages)
+			if speaker != nil {
+				speaker.start(turnCtx)
+			}
+			onText := speakAlong(speaker, renderer.onText)
+			out, err := runChatTurnWithTools(turnCtx, sendFn, converseStreamInput, chatRegistry, permissionGate, onText, renderer.onReasoning, turnOpts)
+			if err != nil && turnCtx.Err() == nil && hasSystemCachePoint(converseStreamInput.System) {
 				log.Printf("prompt caching not supported for this request, retrying without it: %v", err)
 				converseStreamInput.System = stripSystemCachePoints(converseStreamInput.System)
-				out, err = runChatTurnWithTools(context.Background(), sendFn, converseStreamInput, registry, permissionGate, onText, onReasoning)
+				out, err = runChatTurnWithTools(turnCtx, sendFn, converseStreamInput, chatRegistry, permissionGate, onText, renderer.onReasoning, turnOpts)
 			}
+			cancelled := err != nil && turnCtx.Err() != nil
+			if speaker != nil && err != nil {
+				// a reply cut short isn't read out to the end
+				speaker.stop()
+			}
+			endTurn()
+			converseStreamInput.InferenceConfig = inferenceConfig
 
+			if err != nil && ctx.Err() != nil {
+				// a second Ctrl+C, or SIGTERM; end the chat rather than fail it
+				fmt.Fprintln(stderr, "\ninterrupted")
+				return
+			}
+			if cancelled {
+				// keep what arrived, marked as cut off, in place of any
+				// unfinished tool calls, and go back to the prompt
+				reply := interruptedReply(out.Text)
+				converseStreamInput.Messages = append(converseStreamInput.Messages[:turnMessages], assistantTextMessage(reply))
+				session.recordReply(reply, stopReasonInterrupted, out.Resumes)
+				renderer.closeReasoning("")
+				renderer.warn("Reply cancelled. Press Ctrl+C again to quit.")
+				if !nonInteractive {
+					fmt.Fprintln(stdout)
+				}
+				continue
+			}
 			if err != nil {
+				closeView()
 				log.Fatal("streaming output processing error: ", err)
 			}
 
-			chat = &repository.Chat{
-				ChatId:  chatId,
-				Persona: "Assistant",
-				Message: out,
+			session.recordReply(out.Text, out.StopReason, out.Resumes)
+			if out.Resumes > 0 {
+				renderer.note(resumedNote(out.Resumes))
+			}
+			if verbose {
+				renderer.note(stopReasonLine(out.StopReason))
+			}
+			renderer.warn(guardrailNotice(out.StopReason, guardrail))
+			if requireCitations && len(kbMatches) > 0 {
+				citations := checkKBCitations(out.Text, kbMatches)
+				renderer.note(kbSourcesSection(kbMatches, citations))
+				renderer.warn(citations.warning())
+			}
+
+			// name new chats after their first exchange
+			session.startTitle(prompt, out.Text)
+
+			// --verify: confirm files written this turn landed as intended,
+			// then run the configured check command
+			if verifyEnabled && len(out.Writes) > 0 {
+				report := verifyChatTurn(ctx, out.Writes, session.verifyCommand)
+				renderer.note(report.Summary())
+			}
+
+			if session.showStats {
+				renderer.note(session.usage.footer(time.Since(turnStart)))
+			}
+
+			// the reading can run on after the text is done; Ctrl+C stops
+			// it rather than ending the chat
+			if speaker != nil {
+				speakCtx, endSpeech := interrupts.begin(ctx)
+				if err := speaker.finish(speakCtx); err != nil {
+					renderer.warn("speech: " + err.Error())
+				}
+				endSpeech()
 			}
 
-			if err := chatRepo.Create(chat); err != nil {
-				log.Printf("Failed to create chat: %v", err)
+			// each reply ends with a newline when piped
+			if nonInteractive {
+				fmt.Println()
+				continue
 			}
 
 			// Add extra lines after response for better conversation readability
-			fmt.Println()
-			fmt.Println()
+			fmt.Fprintln(stdout)
+			fmt.Fprintln(stdout)
+
+			// the chat view scrolls back instead
+			if view == nil {
+				pageResponse(session.pager, out.Text)
+			}
 
 		}
 	},
 }
 
+// resolveChatModelID checks that modelID can be used for a streaming chat
+// and returns the identifier to send to Converse. Foundation model IDs are
+// validated against the model catalog (text output, streaming support);
+// inference profile IDs, and any ID when skipLookup is set, such as a
+// custom model ARN, are passed through unchanged.
+func resolveChatModelID(ctx context.Context, models *modelCatalog, modelID string, skipLookup bool) (string, error) {
+	if skipLookup || isInferenceProfileID(modelID) {
+		return modelID, nil
+	}
+
+	model, err := models.model(ctx, modelID)
+	if err != nil {
+		return "", fmt.Errorf("error: %w", err)
+	}
+
+	// check if this is a text model
+	if !slices.Contains(model.OutputModalities, "TEXT") {
+		return "", fmt.Errorf("model %s is not a text model, so it can't be used with the chat function", model.ID)
+	}
+
+	// check if model supports streaming
+	if !model.Streaming {
+		return "", fmt.Errorf("model %s does not support streaming so it can't be used with the chat function", model.ID)
+	}
+
+	return model.ID, nil
+}
+
 func init() {
 	rootCmd.AddCommand(chatCmd)
 }
@@ -0,0 +1,164 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+)
+
+// chatAnnotateCmd represents the chat annotate command
+var chatAnnotateCmd = &cobra.Command{
+	Use:   "annotate [note]",
+	Short: "Add a note to a saved chat or one of its messages",
+	Long: `Add a freeform note to a saved chat, or with --message to one message in it,
+counting both your messages and the model's from 1. Notes are kept with the
+chat and included when it's saved with /save or archived.
+
+Without a note, the chat's notes are listed.
+
+> chat-cli chat annotate --chat-id 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90 --message 5 "this answer was wrong"
+> chat-cli chat annotate --chat-id 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90`,
+	Args: cobra.MaximumNArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		chatId, err := cmd.Flags().GetString("chat-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if chatId == "" {
+			log.Fatal("pass the chat to annotate with --chat-id (see chat-cli chat list)")
+		}
+
+		message, err := cmd.Flags().GetInt("message")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if message < 0 {
+			log.Fatal("--message must be a message number, counting from 1")
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		chatRepo := repository.NewChatRepository(database)
+		annotationRepo := repository.NewAnnotationRepository(database)
+
+		messages, err := chatRepo.GetMessages(cmd.Context(), chatId)
+		if err != nil {
+			log.Fatalf("Failed to load chat: %v", err)
+		}
+		if len(messages) == 0 {
+			log.Fatalf("no chat found with id %s", chatId)
+		}
+
+		if len(args) == 0 {
+			annotations, err := annotationRepo.List(cmd.Context(), chatId)
+			if err != nil {
+				log.Fatalf("Failed to load notes: %v", err)
+			}
+			if len(annotations) == 0 {
+				fmt.Printf("Chat %s has no notes.\n", chatId)
+				return
+			}
+			for _, line := range annotationLines(messages, annotations) {
+				fmt.Println(line)
+			}
+			return
+		}
+
+		note := strings.TrimSpace(args[0])
+		if note == "" {
+			log.Fatal("note must not be empty")
+		}
+
+		annotation := repository.Annotation{ChatId: chatId, Note: note}
+		if message > 0 {
+			if message > len(messages) {
+				log.Fatalf("chat %s has %d messages, so there's no message %d", chatId, len(messages), message)
+			}
+			annotation.MessageID = messages[message-1].ID
+		}
+		if err := annotationRepo.Create(cmd.Context(), &annotation); err != nil {
+			log.Fatalf("Failed to save note: %v", err)
+		}
+
+		if message > 0 {
+			fmt.Printf("Added a note to message %d of chat %s\n", message, chatId)
+		} else {
+			fmt.Printf("Added a note to chat %s\n", chatId)
+		}
+	},
+}
+
+// chatNotes sorts a chat's annotations into the notes on the whole chat
+// and the notes on each message, by the message's id. Notes on messages
+// that are no longer in messages, such as a reply replaced with /retry,
+// are left out.
+func chatNotes(messages []repository.Chat, annotations []repository.Annotation) ([]string, map[int][]string) {
+	ids := make(map[int]bool, len(messages))
+	for _, m := range messages {
+		ids[m.ID] = true
+	}
+
+	var onChat []string
+	onMessage := map[int][]string{}
+	for _, a := range annotations {
+		switch {
+		case a.MessageID == 0:
+			onChat = append(onChat, a.Note)
+		case ids[a.MessageID]:
+			onMessage[a.MessageID] = append(onMessage[a.MessageID], a.Note)
+		}
+	}
+	return onChat, onMessage
+}
+
+// annotationLines describes each note for chat annotate's list, with the
+// number and author of the message it's on.
+func annotationLines(messages []repository.Chat, annotations []repository.Annotation) []string {
+	numbers := make(map[int]int, len(messages))
+	for i, m := range messages {
+		numbers[m.ID] = i + 1
+	}
+
+	var lines []string
+	for _, a := range annotations {
+		switch n, ok := numbers[a.MessageID]; {
+		case a.MessageID == 0:
+			lines = append(lines, fmt.Sprintf("%s  chat: %s", a.Created, a.Note))
+		case ok:
+			lines = append(lines, fmt.Sprintf("%s  message %d (%s): %s", a.Created, n, messages[n-1].Persona, a.Note))
+		default:
+			lines = append(lines, fmt.Sprintf("%s  a replaced message: %s", a.Created, a.Note))
+		}
+	}
+	return lines
+}
+
+func init() {
+	chatCmd.AddCommand(chatAnnotateCmd)
+	chatAnnotateCmd.Flags().Int("message", 0, "the number of the message to annotate, counting from 1; without it, the note is on the whole chat")
+}
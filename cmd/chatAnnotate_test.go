@@ -0,0 +1,40 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestChatNotes(t *testing.T) {
+	messages := []repository.Chat{
+		{ID: 4, Persona: "User", Message: "hello"},
+		{ID: 7, Persona: "Assistant", Message: "hi there"},
+	}
+	annotations := []repository.Annotation{
+		{ChatId: "c", Note: "good chat", Created: "2025-01-02 03:04:05"},
+		{ChatId: "c", MessageID: 7, Note: "too short", Created: "2025-01-02 03:04:06"},
+		{ChatId: "c", MessageID: 5, Note: "before the retry", Created: "2025-01-02 03:04:07"},
+	}
+
+	onChat, onMessage := chatNotes(messages, annotations)
+	if !reflect.DeepEqual(onChat, []string{"good chat"}) {
+		t.Errorf("unexpected chat notes %v", onChat)
+	}
+	if !reflect.DeepEqual(onMessage, map[int][]string{7: {"too short"}}) {
+		t.Errorf("expected only the note on a message still in the chat, got %v", onMessage)
+	}
+
+	want := []string{
+		"2025-01-02 03:04:05  chat: good chat",
+		"2025-01-02 03:04:06  message 2 (Assistant): too short",
+		"2025-01-02 03:04:07  a replaced message: before the retry",
+	}
+	if got := annotationLines(messages, annotations); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
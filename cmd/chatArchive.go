@@ -0,0 +1,269 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+)
+
+// archiveInterval is how often a chat session archives old chats on its own
+// when archive-after is set.
+const archiveInterval = 24 * time.Hour
+
+// archiveStampFile records when chats were last archived automatically.
+const archiveStampFile = ".last-run"
+
+// chatArchiveCmd represents the chat archive command
+var chatArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Move chats that haven't been used for a while out of the database",
+	Long: `Move every saved chat whose most recent message is older than --older-than into a
+gzip-compressed JSON file under the archive directory, then remove it from chat
+history.
+
+Without --older-than, the archive-after setting is used. When archive-after is set,
+chat sessions also archive old chats on their own, at most once a day.
+
+> chat-cli chat archive --older-than 90d
+> chat-cli config set archive-after 90d`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		olderThan, err := cmd.Flags().GetString("older-than")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		if olderThan == "" {
+			olderThan = configString(fm, "archive-after")
+		}
+		if olderThan == "" {
+			log.Fatal("pass --older-than or set archive-after (e.g. chat-cli config set archive-after 90d)")
+		}
+
+		age, err := parseAge(olderThan)
+		if err != nil {
+			log.Fatalf("invalid --older-than: %v", err)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		dir := archiveDir(fm)
+		archived, err := archiveChats(cmd.Context(), repository.NewChatRepository(database), repository.NewAnnotationRepository(database), dir, time.Now().Add(-age), "")
+		if archived > 0 {
+			fmt.Printf("Archived %d chat(s) to %s\n", archived, dir)
+		}
+		if err != nil {
+			log.Fatalf("Failed to archive chats: %v", err)
+		}
+		if archived == 0 {
+			fmt.Printf("No chats older than %s.\n", olderThan)
+		}
+	},
+}
+
+// archivedChat is the contents of an archive file.
+type archivedChat struct {
+	ChatID     string    `json:"chat_id"`
+	Title      string    `json:"title,omitempty"`
+	ArchivedAt time.Time `json:"archived_at"`
+	// Notes are the notes from chat annotate on the whole chat.
+	Notes    []string          `json:"notes,omitempty"`
+	Messages []archivedMessage `json:"messages"`
+}
+
+type archivedMessage struct {
+	Role       string `json:"role"`
+	Message    string `json:"message"`
+	CreatedAt  string `json:"created_at"`
+	ImagePath  string `json:"image_path,omitempty"`
+	ImageHash  string `json:"image_hash,omitempty"`
+	StopReason string `json:"stop_reason,omitempty"`
+	Resumes    int    `json:"resumes,omitempty"`
+	// Notes are the notes from chat annotate on this message.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// archiveDir is where archived chats are written.
+func archiveDir(fm *conf.FileManager) string {
+	return filepath.Join(fm.DataPath, "archive")
+}
+
+// archiveChats writes each chat last used before cutoff to its own file in
+// dir and then removes it from chat history, skipping the chat with id
+// keep. A chat is only removed once its file is written, so a failure part
+// way through loses nothing. Notes from notes go in the file with the chat.
+// It returns how many chats were archived.
+func archiveChats(ctx context.Context, repo *repository.ChatRepository, notes *repository.AnnotationRepository, dir string, cutoff time.Time, keep string) (int, error) {
+	chatIds, err := repo.ListOlderThan(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for _, chatId := range chatIds {
+		if chatId == keep {
+			continue
+		}
+
+		messages, err := repo.GetMessages(ctx, chatId)
+		if err != nil {
+			return archived, err
+		}
+		title, err := repo.GetTitle(ctx, chatId)
+		if err != nil {
+			return archived, err
+		}
+
+		annotations, err := notes.List(ctx, chatId)
+		if err != nil {
+			return archived, err
+		}
+		onChat, onMessage := chatNotes(messages, annotations)
+
+		chat := archivedChat{ChatID: chatId, Title: title, ArchivedAt: time.Now().UTC(), Notes: onChat}
+		for _, m := range messages {
+			chat.Messages = append(chat.Messages, archivedMessage{
+				Role:       m.Persona,
+				Message:    m.Message,
+				CreatedAt:  m.Created,
+				ImagePath:  m.ImagePath,
+				ImageHash:  m.ImageHash,
+				StopReason: m.StopReason,
+				Resumes:    m.Resumes,
+				Notes:      onMessage[m.ID],
+			})
+		}
+
+		if err := writeArchive(dir, &chat); err != nil {
+			return archived, fmt.Errorf("archiving chat %s: %w", chatId, err)
+		}
+		if _, err := repo.Delete(ctx, chatId); err != nil {
+			return archived, err
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// writeArchive writes chat to <chat-id>.json.gz in dir, adding the time to
+// the name if that file already exists. The file is written under a
+// temporary name first so a partial archive is never left behind.
+func writeArchive(dir string, chat *archivedChat) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	name := archiveFileName(chat.ChatID)
+	path := filepath.Join(dir, name+".json.gz")
+	if _, err := os.Stat(path); err == nil {
+		path = filepath.Join(dir, name+"-"+chat.ArchivedAt.Format("20060102T150405")+".json.gz")
+	}
+
+	tmp, err := os.CreateTemp(dir, ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	zw := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(zw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(chat); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// archiveFileName makes a chat id safe to use as a file name. Ids are
+// normally UUIDs, which are left as they are.
+func archiveFileName(chatId string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, chatId)
+}
+
+// autoArchiveChats archives old chats when archive-after is set and it
+// hasn't been done in the last archiveInterval. It runs as a chat session
+// starts, leaving the session's own chat alone; problems are only reported,
+// since they shouldn't stop the chat.
+func autoArchiveChats(ctx context.Context, fm *conf.FileManager, repo *repository.ChatRepository, notes *repository.AnnotationRepository, currentChatId string) {
+	olderThan := configString(fm, "archive-after")
+	if olderThan == "" {
+		return
+	}
+
+	age, err := parseAge(olderThan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: invalid archive-after: %v\n", err)
+		return
+	}
+
+	dir := archiveDir(fm)
+	stamp := filepath.Join(dir, archiveStampFile)
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < archiveInterval {
+		return
+	}
+
+	archived, err := archiveChats(ctx, repo, notes, dir, time.Now().Add(-age), currentChatId)
+	if archived > 0 {
+		fmt.Fprintf(os.Stderr, "Archived %d chat(s) older than %s to %s\n", archived, olderThan, dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to archive old chats: %v\n", err)
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0750); err == nil {
+		_ = os.WriteFile(stamp, nil, 0600)
+	}
+}
+
+func init() {
+	chatArchiveCmd.Flags().String("older-than", "", "archive chats with no messages newer than this age (e.g. 90d, 12w); defaults to archive-after")
+	chatCmd.AddCommand(chatArchiveCmd)
+}
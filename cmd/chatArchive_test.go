@@ -0,0 +1,156 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func newArchiveTestRepo(t *testing.T) (*repository.ChatRepository, *repository.AnnotationRepository) {
+	t.Helper()
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = database.Close()
+	})
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().UTC().AddDate(0, 0, -120).Format("2006-01-02 15:04:05")
+	rows := []struct{ chatId, persona, message, created string }{
+		{"old-chat", "User", "What is a monad?", old},
+		{"old-chat", "Assistant", "A monoid in the category of endofunctors.", old},
+		{"resumed-chat", "User", "Still here?", old},
+		{"new-chat", "User", "Hello", ""},
+	}
+	for _, row := range rows {
+		query := `INSERT INTO chats (chat_id, persona, message, created_at) VALUES ($1, $2, $3, $4)`
+		args := []interface{}{row.chatId, row.persona, row.message, row.created}
+		if row.created == "" {
+			query = `INSERT INTO chats (chat_id, persona, message) VALUES ($1, $2, $3)`
+			args = args[:3]
+		}
+		if _, err := database.GetDB().Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := repository.NewChatRepository(database)
+	if err := repo.SetTitle(t.Context(), "old-chat", "Monads"); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := repository.NewAnnotationRepository(database)
+	messages, err := repo.GetMessages(t.Context(), "old-chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range []repository.Annotation{
+		{ChatId: "old-chat", Note: "kept for the jokes"},
+		{ChatId: "old-chat", MessageID: messages[1].ID, Note: "this answer was wrong"},
+	} {
+		if err := notes.Create(t.Context(), &note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo, notes
+}
+
+func readArchive(t *testing.T, path string) archivedChat {
+	t.Helper()
+	f, err := os.Open(path) // #nosec G304 - test file in a temp dir
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chat archivedChat
+	if err := json.NewDecoder(zr).Decode(&chat); err != nil {
+		t.Fatal(err)
+	}
+	return chat
+}
+
+func TestArchiveChats(t *testing.T) {
+	repo, notes := newArchiveTestRepo(t)
+	dir := filepath.Join(t.TempDir(), "archive")
+
+	archived, err := archiveChats(t.Context(), repo, notes, dir, time.Now().AddDate(0, 0, -90), "resumed-chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived != 1 {
+		t.Fatalf("expected 1 chat archived, got %d", archived)
+	}
+
+	chat := readArchive(t, filepath.Join(dir, "old-chat.json.gz"))
+	if chat.ChatID != "old-chat" || chat.Title != "Monads" || len(chat.Messages) != 2 {
+		t.Errorf("unexpected archive: %+v", chat)
+	}
+	if chat.Messages[1].Role != "Assistant" || chat.Messages[1].CreatedAt == "" {
+		t.Errorf("unexpected message: %+v", chat.Messages[1])
+	}
+	if len(chat.Notes) != 1 || chat.Notes[0] != "kept for the jokes" || len(chat.Messages[0].Notes) != 0 {
+		t.Errorf("expected the chat's note on the chat, got %v and %v", chat.Notes, chat.Messages[0].Notes)
+	}
+	if len(chat.Messages[1].Notes) != 1 || chat.Messages[1].Notes[0] != "this answer was wrong" {
+		t.Errorf("expected the answer's note on it, got %v", chat.Messages[1].Notes)
+	}
+	if left, _ := notes.List(t.Context(), "old-chat"); len(left) != 0 {
+		t.Errorf("expected old-chat's notes to be removed from the database, got %d", len(left))
+	}
+
+	if messages, _ := repo.GetMessages(t.Context(), "old-chat"); len(messages) != 0 {
+		t.Errorf("expected old-chat to be removed from the database, got %d messages", len(messages))
+	}
+	if messages, _ := repo.GetMessages(t.Context(), "resumed-chat"); len(messages) != 1 {
+		t.Errorf("expected the kept chat to stay, got %d messages", len(messages))
+	}
+	if messages, _ := repo.GetMessages(t.Context(), "new-chat"); len(messages) != 1 {
+		t.Errorf("expected new-chat to stay, got %d messages", len(messages))
+	}
+
+	t.Run("existing archive files aren't overwritten", func(t *testing.T) {
+		chat := &archivedChat{ChatID: "old-chat", ArchivedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+		if err := writeArchive(dir, chat); err != nil {
+			t.Fatal(err)
+		}
+		if got := readArchive(t, filepath.Join(dir, "old-chat-20250102T030405.json.gz")); got.ChatID != "old-chat" {
+			t.Errorf("unexpected archive: %+v", got)
+		}
+		if got := readArchive(t, filepath.Join(dir, "old-chat.json.gz")); got.Title != "Monads" {
+			t.Errorf("expected the first archive to be kept, got %+v", got)
+		}
+	})
+}
+
+func TestArchiveFileName(t *testing.T) {
+	cases := map[string]string{
+		"9be2adda-5966-45c9-8a07-f7a7d486ca36": "9be2adda-5966-45c9-8a07-f7a7d486ca36",
+		"../etc/passwd":                        "___etc_passwd",
+	}
+	for id, want := range cases {
+		if got := archiveFileName(id); got != want {
+			t.Errorf("archiveFileName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+)
+
+// chatDeleteCmd represents the chat delete command
+var chatDeleteCmd = &cobra.Command{
+	Use:   "delete <chat-id>",
+	Short: "Delete a saved chat and all of its messages",
+	Args:  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		chatId := args[0]
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		chatRepo, closeDB := openChatRepository()
+		defer closeDB()
+
+		messages, err := chatRepo.GetMessages(cmd.Context(), chatId)
+		if err != nil {
+			log.Fatalf("Failed to load chat: %v", err)
+		}
+		if len(messages) == 0 {
+			log.Fatalf("no chat found with id %s", chatId)
+		}
+
+		if !force {
+			prompt := fmt.Sprintf("Delete chat %s (%d messages, starting %q)?", chatId, len(messages), truncate(messages[0].Message, 40))
+			if !confirmAction(os.Stdin, os.Stdout, prompt) {
+				fmt.Println("Aborted.")
+				return
+			}
+		}
+
+		deleted, err := chatRepo.Delete(cmd.Context(), chatId)
+		if errors.Is(err, repository.ErrChatNotFound) {
+			log.Fatalf("no chat found with id %s", chatId)
+		}
+		if err != nil {
+			log.Fatalf("Failed to delete chat: %v", err)
+		}
+
+		fmt.Printf("Deleted chat %s (%d messages)\n", chatId, deleted)
+	},
+}
+
+// chatPruneCmd represents the chat prune command
+var chatPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete saved chats that haven't been used for a while",
+	Long: `Delete every saved chat whose most recent message is older than --older-than.
+
+Ages are given as a number followed by a unit: m (minutes), h (hours), d (days), or w (weeks).
+
+> chat-cli chat prune --older-than 30d
+> chat-cli chat prune --older-than 2w --force`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		olderThan, err := cmd.Flags().GetString("older-than")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		age, err := parseAge(olderThan)
+		if err != nil {
+			log.Fatalf("invalid --older-than: %v", err)
+		}
+		cutoff := time.Now().Add(-age)
+
+		chatRepo, closeDB := openChatRepository()
+		defer closeDB()
+
+		count, err := chatRepo.CountOlderThan(cmd.Context(), cutoff)
+		if err != nil {
+			log.Fatalf("Failed to find chats to prune: %v", err)
+		}
+		if count == 0 {
+			fmt.Printf("No chats older than %s.\n", olderThan)
+			return
+		}
+
+		if !force {
+			prompt := fmt.Sprintf("Delete %d chat(s) with no messages in the last %s?", count, olderThan)
+			if !confirmAction(os.Stdin, os.Stdout, prompt) {
+				fmt.Println("Aborted.")
+				return
+			}
+		}
+
+		deleted, err := chatRepo.DeleteOlderThan(cmd.Context(), cutoff)
+		if err != nil {
+			log.Fatalf("Failed to prune chats: %v", err)
+		}
+
+		fmt.Printf("Deleted %d chat(s)\n", deleted)
+	},
+}
+
+// openChatRepository opens the configured database for the chat management
+// commands, exiting on failure. The returned func closes the database.
+func openChatRepository() (*repository.ChatRepository, func()) {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+
+	database, err := openDatabase(fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return repository.NewChatRepository(database), func() {
+		if err := database.Close(); err != nil {
+			log.Printf("Warning: failed to close database: %v", err)
+		}
+	}
+}
+
+// parseAge parses an age such as "30d" or "12h". On top of the units
+// time.ParseDuration understands, it accepts d (days) and w (weeks), which
+// are what people actually reach for when pruning history.
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("an age is required, e.g. 30d")
+	}
+
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	var age time.Duration
+	if unit != 0 {
+		n, err := strconv.Atoi(strings.TrimSpace(s[:len(s)-1]))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a valid age", s)
+		}
+		// a larger count would wrap around to a negative or short age
+		if int64(n) > math.MaxInt64/int64(unit) {
+			return 0, fmt.Errorf("%q is too long an age", s)
+		}
+		age = time.Duration(n) * unit
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a valid age (use e.g. 30d, 2w, or 12h)", s)
+		}
+		age = d
+	}
+
+	if age <= 0 {
+		return 0, fmt.Errorf("%q must be greater than zero", s)
+	}
+	return age, nil
+}
+
+// confirmAction asks a yes/no question and reports whether the answer was
+// yes. Anything else, including an empty line or EOF, is a no.
+func confirmAction(reader io.Reader, writer io.Writer, prompt string) bool {
+	fmt.Fprintf(writer, "%s [y/N]: ", prompt)
+
+	line, _ := bufio.NewReader(reader).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func init() {
+	chatDeleteCmd.Flags().BoolP("force", "f", false, "delete without asking for confirmation")
+	chatCmd.AddCommand(chatDeleteCmd)
+
+	chatPruneCmd.Flags().String("older-than", "", "delete chats with no messages newer than this age (e.g. 30d, 2w, 12h)")
+	chatPruneCmd.Flags().BoolP("force", "f", false, "delete without asking for confirmation")
+	if err := chatPruneCmd.MarkFlagRequired("older-than"); err != nil {
+		log.Fatal(err)
+	}
+	chatCmd.AddCommand(chatPruneCmd)
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	valid := map[string]time.Duration{
+		"30d":  30 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"12h":  12 * time.Hour,
+		"90m":  90 * time.Minute,
+		" 1d ": 24 * time.Hour,
+	}
+	for input, want := range valid {
+		got, err := parseAge(input)
+		if err != nil {
+			t.Errorf("parseAge(%q): unexpected error %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseAge(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "d", "thirty days", "0d", "-5d", "5y", "106752d", "15251w", "9223372036854775807d"} {
+		if _, err := parseAge(input); err == nil {
+			t.Errorf("parseAge(%q): expected an error", input)
+		}
+	}
+}
+
+func TestConfirmAction(t *testing.T) {
+	cases := map[string]bool{
+		"y\n":   true,
+		"YES\n": true,
+		"n\n":   false,
+		"\n":    false,
+		"":      false,
+		"sure":  false,
+	}
+	for input, want := range cases {
+		out := &bytes.Buffer{}
+		if got := confirmAction(strings.NewReader(input), out, "Delete it?"); got != want {
+			t.Errorf("confirmAction(%q) = %v, want %v", input, got, want)
+		}
+		if !strings.Contains(out.String(), "Delete it? [y/N]") {
+			t.Errorf("expected prompt to be shown, got %q", out.String())
+		}
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"text/tabwriter" //nolint:goimports // false positive from CI version diff
 	//nolint:goimports // false positive from CI version diff
 	conf "github.com/chat-cli/chat-cli/config"
-	"github.com/chat-cli/chat-cli/db"
-	"github.com/chat-cli/chat-cli/factory"
 	"github.com/chat-cli/chat-cli/repository"
 	"github.com/spf13/cobra" //nolint:goimports // false positive from CI version diff
 )
@@ -32,20 +30,9 @@ var chatListCmd = &cobra.Command{
 			log.Fatal(initErr)
 		}
 
-		// Get SQLite database path
-		dbPath := fm.GetDBPath()
-
-		// Get the database driver from the configuration
-		driver := fm.GetDBDriver()
-
-		config := db.Config{
-			Driver: driver,
-			Name:   dbPath,
-		}
-
-		database, err := factory.CreateDatabase(&config)
+		database, err := openDatabase(fm)
 		if err != nil {
-			log.Fatalf("Failed to create database: %v", err)
+			log.Fatal(err)
 		}
 		defer func() {
 			if err := database.Close(); err != nil {
@@ -53,15 +40,10 @@ var chatListCmd = &cobra.Command{
 			}
 		}()
 
-		// Run migrations to ensure tables exist
-		if err := database.Migrate(); err != nil {
-			log.Fatalf("Failed to migrate database: %v", err)
-		}
-
 		// Create repositories
 		chatRepo := repository.NewChatRepository(database)
 
-		if chats, err := chatRepo.List(); err != nil {
+		if chats, err := chatRepo.List(cmd.Context()); err != nil {
 			log.Printf("Failed to create chat: %v", err)
 		} else {
 			fmt.Println("")
@@ -79,7 +61,7 @@ var chatListCmd = &cobra.Command{
 			}
 
 			for _, chat := range chats {
-				if _, err := fmt.Fprintf(w, "%s\t %s\t %s\n", chat.Created, chat.ChatId, truncate(chat.Message, 40)); err != nil {
+				if _, err := fmt.Fprintf(w, "%s\t %s\t %s\n", chat.Created, chat.ChatId, chatListTitle(chat)); err != nil {
 					log.Printf("Error writing chat data: %v", err)
 				}
 			}
@@ -91,6 +73,15 @@ func init() {
 	chatCmd.AddCommand(chatListCmd)
 }
 
+// chatListTitle is the chat's title, or its opening message for chats
+// saved before titles existed.
+func chatListTitle(chat repository.Chat) string {
+	if chat.Title != "" {
+		return chat.Title
+	}
+	return truncateRunes(oneLine(chat.Message), 40)
+}
+
 func truncate(s string, length int) string {
 	if len(s) <= length {
 		return s
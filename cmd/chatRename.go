@@ -0,0 +1,51 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+)
+
+// chatRenameCmd represents the chat rename command
+var chatRenameCmd = &cobra.Command{
+	Use:   "rename <chat-id> <title>",
+	Short: "Give a saved chat a title",
+	Long: `Give a saved chat a title, shown by 'chat list' in place of its opening message.
+
+New chats are titled automatically after the first exchange; use rename to change it.
+
+> chat-cli chat rename 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90 "Log rotation on the web hosts"`,
+	Args: cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		chatId := args[0]
+		title := strings.TrimSpace(args[1])
+		if title == "" {
+			log.Fatal("title must not be empty")
+		}
+
+		chatRepo, closeDB := openChatRepository()
+		defer closeDB()
+
+		err := chatRepo.SetTitle(cmd.Context(), chatId, title)
+		if errors.Is(err, repository.ErrChatNotFound) {
+			log.Fatalf("no chat found with id %s", chatId)
+		}
+		if err != nil {
+			log.Fatalf("Failed to rename chat: %v", err)
+		}
+
+		fmt.Printf("Renamed chat %s to %q\n", chatId, title)
+	},
+}
+
+func init() {
+	chatCmd.AddCommand(chatRenameCmd)
+}
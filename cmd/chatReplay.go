@@ -0,0 +1,194 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+const (
+	// defaultReplaySpeed is how many characters a second --animate types.
+	defaultReplaySpeed = 80
+	// replayPause is how long --animate waits between messages.
+	replayPause = 800 * time.Millisecond
+)
+
+// chatReplayCmd represents the chat replay command
+var chatReplayCmd = &cobra.Command{
+	Use:   "replay <chat-id>",
+	Short: "Print a saved chat with the time of each message",
+	Long: `Print a saved chat, numbering each message and showing when it was sent,
+or with --animate play it back as if it were being typed.
+
+--from and --to limit it to part of the chat, given as message numbers,
+counting both your messages and the model's from 1, or as local times such as
+2024-05-01 or 2024-05-01T14:30, as /save takes them.
+
+> chat-cli chat replay 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90 --from 5 --to 8
+> chat-cli chat replay 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90 --from 2024-05-01T13:00 --animate`,
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		chatId := args[0]
+
+		from, err := cmd.Flags().GetString("from")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		to, err := cmd.Flags().GetString("to")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		animate, err := cmd.Flags().GetBool("animate")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		speed, err := cmd.Flags().GetInt("speed")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if speed < 1 {
+			log.Fatal("--speed must be at least 1 character a second")
+		}
+
+		chatRepo, closeDB := openChatRepository()
+		defer closeDB()
+
+		messages, err := replayMessages(cmd.Context(), chatRepo, chatId, from, to)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(messages) == 0 {
+			if from == "" && to == "" {
+				log.Fatalf("no chat found with id %s", chatId)
+			}
+			log.Fatalf("chat %s has no messages in that range", chatId)
+		}
+
+		opts := replayOptions{color: utils.ColorEnabled(os.Stdout)}
+		if animate {
+			opts.perChar = time.Second / time.Duration(speed)
+			opts.pause = replayPause
+		}
+		if err := replayChat(cmd.Context(), os.Stdout, messages, opts); err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatal(err)
+		}
+	},
+}
+
+// replayMessages loads the part of a chat between the --from and --to
+// values, which must both be message numbers or both be times.
+func replayMessages(ctx context.Context, chatRepo *repository.ChatRepository, chatId, from, to string) ([]repository.Chat, error) {
+	var start, end transcriptBound
+	if from != "" {
+		b, err := parseTranscriptBound(from, false)
+		if err != nil {
+			return nil, fmt.Errorf("--from: %w", err)
+		}
+		start = b
+	}
+	if to != "" {
+		b, err := parseTranscriptBound(to, true)
+		if err != nil {
+			return nil, fmt.Errorf("--to: %w", err)
+		}
+		end = b
+	}
+
+	byTime := !start.at.IsZero() || !end.at.IsZero()
+	if byTime && (start.index > 0 || end.index > 0) {
+		return nil, errors.New("--from and --to must both be message numbers or both be times")
+	}
+	if byTime {
+		if !start.at.IsZero() && !end.at.IsZero() && end.at.Before(start.at) {
+			return nil, errors.New("--to is before --from")
+		}
+		return chatRepo.GetMessagesBetween(ctx, chatId, start.at, end.at)
+	}
+
+	if end.index > 0 && end.index < start.index {
+		return nil, errors.New("--to is before --from")
+	}
+	return chatRepo.GetMessageRange(ctx, chatId, max(start.index, 1), end.index)
+}
+
+// replayOptions controls how replayChat prints a chat.
+type replayOptions struct {
+	// color dims each message's heading.
+	color bool
+	// perChar is how long to wait after each character, and pause how long
+	// to wait between messages; both are zero to print the chat at once.
+	perChar, pause time.Duration
+}
+
+// replayChat prints each message under a heading with its number and the
+// local time it was sent, typing it out a character at a time when
+// opts.perChar is set. It stops early if ctx is canceled.
+func replayChat(ctx context.Context, w io.Writer, messages []repository.Chat, opts replayOptions) error {
+	for i, m := range messages {
+		if i > 0 {
+			if err := sleepContext(ctx, opts.pause); err != nil {
+				return err
+			}
+		}
+
+		sent := m.Created
+		if at := parseStoredTime(m.Created); !at.IsZero() {
+			sent = at.Local().Format("2006-01-02 15:04:05")
+		}
+		heading := fmt.Sprintf("#%d · %s", m.Number, sent)
+		if opts.color {
+			heading = "\033[90m" + heading + "\033[0m"
+		}
+		fmt.Fprintf(w, "%s\n[%s]: ", heading, m.Persona)
+
+		if opts.perChar == 0 {
+			fmt.Fprint(w, m.Message)
+		} else {
+			for _, r := range m.Message {
+				fmt.Fprint(w, string(r))
+				if err := sleepContext(ctx, opts.perChar); err != nil {
+					fmt.Fprintln(w)
+					return err
+				}
+			}
+		}
+		fmt.Fprint(w, "\n\n")
+	}
+	return nil
+}
+
+// sleepContext waits for d, or until ctx is canceled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func init() {
+	chatCmd.AddCommand(chatReplayCmd)
+	chatReplayCmd.Flags().String("from", "", "start at this message number or local time")
+	chatReplayCmd.Flags().String("to", "", "stop after this message number or local time")
+	chatReplayCmd.Flags().Bool("animate", false, "type each message out instead of printing the chat at once")
+	chatReplayCmd.Flags().Int("speed", defaultReplaySpeed, "characters a second to type with --animate")
+}
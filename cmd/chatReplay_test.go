@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestReplayMessages(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	chatRepo := repository.NewChatRepository(database)
+	for _, message := range []string{"one", "two", "three"} {
+		if err := chatRepo.Create(t.Context(), &repository.Chat{ChatId: "chat", Persona: "User", Message: message}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, err := replayMessages(t.Context(), chatRepo, "chat", "2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Number != 2 || messages[1].Message != "three" {
+		t.Errorf("expected messages 2 and 3, got %+v", messages)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if messages, err := replayMessages(t.Context(), chatRepo, "chat", today, today); err != nil || len(messages) != 3 {
+		t.Errorf("expected today's messages, got %d, %v", len(messages), err)
+	}
+
+	for _, bounds := range [][2]string{{"2", today}, {"3", "2"}, {"soon", ""}} {
+		if _, err := replayMessages(t.Context(), chatRepo, "chat", bounds[0], bounds[1]); err == nil {
+			t.Errorf("expected --from %q --to %q to be rejected", bounds[0], bounds[1])
+		}
+	}
+}
+
+func TestReplayChat(t *testing.T) {
+	messages := []repository.Chat{
+		{Number: 3, Persona: "User", Message: "hello", Created: "2025-01-02 03:04:05"},
+		{Number: 4, Persona: "Assistant", Message: "hi there", Created: "2025-01-02 03:04:09"},
+	}
+	sent := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC).Local().Format("2006-01-02 15:04:05")
+
+	var out bytes.Buffer
+	if err := replayChat(t.Context(), &out, messages, replayOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "#3 · "+sent+"\n[User]: hello\n\n#4 · ") || !strings.HasSuffix(out.String(), "\n[Assistant]: hi there\n\n") {
+		t.Errorf("unexpected replay %q", out.String())
+	}
+
+	t.Run("animation stops when canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		var out bytes.Buffer
+		err := replayChat(ctx, &out, messages, replayOptions{perChar: time.Millisecond})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the replay to be canceled, got %v", err)
+		}
+		if strings.Contains(out.String(), "hello") {
+			t.Errorf("expected the replay to stop after the first character, got %q", out.String())
+		}
+	})
+}
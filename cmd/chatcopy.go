@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/atotto/clipboard"
+)
+
+// errNoClipboard is returned when there's no clipboard to copy to, as on a
+// Linux machine without a clipboard tool.
+var errNoClipboard = errors.New("no clipboard is available; on Linux, install xclip, xsel or wl-clipboard, or use /save <file> --last")
+
+// copyToClipboard writes text to the system clipboard: pbcopy on macOS,
+// the clipboard API on Windows, and xclip, xsel or wl-copy on Linux. Tests
+// replace it.
+var copyToClipboard = func(text string) error {
+	if clipboard.Unsupported {
+		return errNoClipboard
+	}
+	return clipboard.WriteAll(text)
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "copy",
+		description: "Copy the last reply to the clipboard",
+		run:         runCopyCommand,
+	})
+}
+
+func runCopyCommand(s *chatSession, _ string) error {
+	reply := lastReplyText(s.input.Messages)
+	if reply == "" {
+		return errors.New("there's no reply to copy yet")
+	}
+	if err := copyToClipboard(reply); errors.Is(err, errNoClipboard) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to copy: %w", err)
+	}
+	fmt.Fprintf(s.out, "Copied the last reply (%s).\n", formatByteSize(len(reply)))
+	return nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCopyCommand(t *testing.T) {
+	original := copyToClipboard
+	t.Cleanup(func() { copyToClipboard = original })
+
+	var copied string
+	copyToClipboard = func(text string) error {
+		copied = text
+		return nil
+	}
+
+	s, out := newTestChatSession()
+	if _, err := dispatchSlashCommand(s, "/copy"); err != nil {
+		t.Fatal(err)
+	}
+	if copied != "hi there" {
+		t.Errorf("expected the last reply to be copied, got %q", copied)
+	}
+	if !strings.Contains(out.String(), "Copied the last reply") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	copyToClipboard = func(string) error { return errNoClipboard }
+	if _, err := dispatchSlashCommand(s, "/copy"); !errors.Is(err, errNoClipboard) {
+		t.Errorf("expected the missing clipboard to be reported, got %v", err)
+	}
+
+	s.input.Messages = s.input.Messages[:1]
+	if _, err := dispatchSlashCommand(s, "/copy"); err == nil || !strings.Contains(err.Error(), "no reply to copy") {
+		t.Errorf("expected an error with no reply, got %v", err)
+	}
+}
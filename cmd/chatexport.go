@@ -0,0 +1,265 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// transcriptEntry is one message of a conversation being saved.
+type transcriptEntry struct {
+	// index is the message's position in the conversation, counting from 1.
+	index int
+	role  string
+	text  string
+	// created is when the message was stored; zero if it isn't known.
+	created time.Time
+	// notes are the notes from chat annotate on the message.
+	notes []string
+}
+
+// transcriptBound is one end of a --from/--to range: a message number or a
+// point in time.
+type transcriptBound struct {
+	index int
+	at    time.Time
+}
+
+// boundLayouts are the timestamp forms --from and --to accept, in local
+// time. Spaces aren't allowed since /save splits its arguments on them.
+var boundLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseTranscriptBound reads a --from or --to value. A date on its own
+// means the start of that day for --from and the end of it for --to.
+func parseTranscriptBound(value string, end bool) (transcriptBound, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 1 {
+			return transcriptBound{}, fmt.Errorf("message numbers start at 1, got %d", n)
+		}
+		return transcriptBound{index: n}, nil
+	}
+
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return transcriptBound{at: at}, nil
+	}
+	for _, layout := range boundLayouts {
+		at, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if end && layout == "2006-01-02" {
+			at = at.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return transcriptBound{at: at}, nil
+	}
+	return transcriptBound{}, fmt.Errorf("invalid message number or time %q (use e.g. 12, 2024-05-01, or 2024-05-01T14:30)", value)
+}
+
+// transcriptFilter selects the part of a conversation to save.
+type transcriptFilter struct {
+	from, to    *transcriptBound
+	role        string
+	stripSystem bool
+	// last saves only the text of the latest reply, not a transcript.
+	last bool
+}
+
+// errNoTimestamps is returned when a time range is used on messages whose
+// times aren't known.
+var errNoTimestamps = errors.New("message times aren't available for this conversation; use message numbers instead")
+
+// apply returns the entries the filter selects.
+func (f transcriptFilter) apply(entries []transcriptEntry) ([]transcriptEntry, error) {
+	var selected []transcriptEntry
+	for _, e := range entries {
+		if f.role != "" && !strings.EqualFold(e.role, f.role) {
+			continue
+		}
+
+		if f.from != nil {
+			cmp, err := f.from.compare(e)
+			if err != nil {
+				return nil, err
+			}
+			if cmp < 0 {
+				continue
+			}
+		}
+		if f.to != nil {
+			cmp, err := f.to.compare(e)
+			if err != nil {
+				return nil, err
+			}
+			if cmp > 0 {
+				continue
+			}
+		}
+
+		selected = append(selected, e)
+	}
+	return selected, nil
+}
+
+// compare reports whether e comes before (-1), at (0), or after (+1) the
+// bound.
+func (b transcriptBound) compare(e transcriptEntry) (int, error) {
+	if b.index > 0 {
+		switch {
+		case e.index < b.index:
+			return -1, nil
+		case e.index > b.index:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	if e.created.IsZero() {
+		return 0, errNoTimestamps
+	}
+	return e.created.Compare(b.at), nil
+}
+
+// parseSaveArgs splits /save's arguments into the file to write and the
+// filter options. Without any options the whole argument is the file name,
+// so names containing spaces still work.
+func parseSaveArgs(args string) (string, transcriptFilter, error) {
+	var filter transcriptFilter
+	if !strings.Contains(args, "--") {
+		return args, filter, nil
+	}
+
+	flags := pflag.NewFlagSet("save", pflag.ContinueOnError)
+	flags.Usage = func() {}
+	from := flags.String("from", "", "")
+	to := flags.String("to", "", "")
+	flags.StringVar(&filter.role, "role", "", "")
+	flags.BoolVar(&filter.stripSystem, "strip-system", false, "")
+	flags.BoolVar(&filter.last, "last", false, "")
+	if err := flags.Parse(strings.Fields(args)); err != nil {
+		return "", filter, err
+	}
+	if flags.NArg() != 1 {
+		return "", filter, errors.New(saveUsage)
+	}
+	if filter.last && (*from != "" || *to != "" || filter.role != "" || filter.stripSystem) {
+		return "", filter, errors.New("--last saves just the latest reply and can't be combined with other options")
+	}
+
+	filter.role = strings.ToLower(filter.role)
+	if filter.role != "" && filter.role != "user" && filter.role != "assistant" {
+		return "", filter, fmt.Errorf("invalid --role %q: use user or assistant", filter.role)
+	}
+	if *from != "" {
+		b, err := parseTranscriptBound(*from, false)
+		if err != nil {
+			return "", filter, fmt.Errorf("--from: %w", err)
+		}
+		filter.from = &b
+	}
+	if *to != "" {
+		b, err := parseTranscriptBound(*to, true)
+		if err != nil {
+			return "", filter, fmt.Errorf("--to: %w", err)
+		}
+		filter.to = &b
+	}
+
+	return flags.Arg(0), filter, nil
+}
+
+// sessionTranscript returns the session's conversation, and the notes on
+// the whole chat. Messages come from chat history when it's available,
+// since it records when each was sent and has the chat's notes; otherwise
+// from the session itself, without times or notes.
+func sessionTranscript(s *chatSession) ([]transcriptEntry, []string, error) {
+	if s.chatRepo == nil {
+		var entries []transcriptEntry
+		for i, msg := range transcriptMessages(s) {
+			entries = append(entries, transcriptEntry{
+				index: i + 1,
+				role:  roleLabel(msg.Role),
+				text:  strings.TrimSpace(messageText(msg)),
+			})
+		}
+		return entries, nil, nil
+	}
+
+	chats, err := s.chatRepo.GetMessages(s.context(), s.chatId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var onChat []string
+	var onMessage map[int][]string
+	if s.annotations != nil {
+		annotations, err := s.annotations.List(s.context(), s.chatId)
+		if err != nil {
+			return nil, nil, err
+		}
+		onChat, onMessage = chatNotes(chats, annotations)
+	}
+
+	entries := make([]transcriptEntry, 0, len(chats))
+	for i, chat := range chats {
+		entries = append(entries, transcriptEntry{
+			index:   i + 1,
+			role:    chat.Persona,
+			text:    strings.TrimSpace(chat.Message),
+			created: parseStoredTime(chat.Created),
+			notes:   onMessage[chat.ID],
+		})
+	}
+	return entries, onChat, nil
+}
+
+// storedTimeLayouts are the forms created_at comes back in: SQLite's
+// CURRENT_TIMESTAMP text, or a timestamp converted by the driver.
+var storedTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+}
+
+// parseStoredTime reads a created_at value, which is stored in UTC. It
+// returns the zero time for a value it can't read.
+func parseStoredTime(value string) time.Time {
+	for _, layout := range storedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// renderTranscript formats entries as Markdown, after the system prompt
+// unless it's empty. Notes on the chat come first and notes on a message
+// follow it, as quotes.
+func renderTranscript(systemPrompt string, notes []string, entries []transcriptEntry) string {
+	var b strings.Builder
+	writeNotes := func(notes []string) {
+		for _, note := range notes {
+			fmt.Fprintf(&b, "> **Note:** %s\n\n", strings.ReplaceAll(strings.TrimSpace(note), "\n", "\n> "))
+		}
+	}
+
+	writeNotes(notes)
+	if systemPrompt = strings.TrimSpace(systemPrompt); systemPrompt != "" {
+		fmt.Fprintf(&b, "## System\n\n%s\n\n", systemPrompt)
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", e.role, e.text)
+		writeNotes(e.notes)
+	}
+	return b.String()
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestParseTranscriptBound(t *testing.T) {
+	t.Run("message number", func(t *testing.T) {
+		b, err := parseTranscriptBound("12", false)
+		if err != nil || b.index != 12 {
+			t.Errorf("got %+v, %v", b, err)
+		}
+		if _, err := parseTranscriptBound("0", false); err == nil {
+			t.Error("expected an error for message 0")
+		}
+	})
+
+	t.Run("a date covers the whole day", func(t *testing.T) {
+		from, err := parseTranscriptBound("2024-05-01", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := parseTranscriptBound("2024-05-01", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !from.at.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)) {
+			t.Errorf("unexpected start %v", from.at)
+		}
+		if !to.at.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local).Add(-time.Nanosecond)) {
+			t.Errorf("unexpected end %v", to.at)
+		}
+	})
+
+	t.Run("times", func(t *testing.T) {
+		for _, value := range []string{"2024-05-01T14:30", "2024-05-01T14:30:00", "2024-05-01T14:30:00Z"} {
+			if b, err := parseTranscriptBound(value, false); err != nil || b.at.IsZero() {
+				t.Errorf("%s: got %+v, %v", value, b, err)
+			}
+		}
+		if _, err := parseTranscriptBound("yesterday", false); err == nil {
+			t.Error("expected an error for an unknown format")
+		}
+	})
+}
+
+func TestParseSaveArgs(t *testing.T) {
+	path, filter, err := parseSaveArgs("my notes.md")
+	if err != nil || path != "my notes.md" || filter.from != nil || filter.role != "" {
+		t.Errorf("expected a plain file name, got %q %+v %v", path, filter, err)
+	}
+
+	path, filter, err = parseSaveArgs("out.md --from 3 --to 2024-05-01 --role Assistant --strip-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "out.md" || filter.from == nil || filter.from.index != 3 || filter.to == nil || filter.to.at.IsZero() || filter.role != "assistant" || !filter.stripSystem {
+		t.Errorf("unexpected result %q %+v", path, filter)
+	}
+
+	for _, args := range []string{"--role user", "out.md --role system", "out.md --from soon", "out.md --colour red"} {
+		if _, _, err := parseSaveArgs(args); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestTranscriptFilter(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := []transcriptEntry{
+		{index: 1, role: "User", text: "one", created: base},
+		{index: 2, role: "Assistant", text: "two", created: base.Add(time.Hour)},
+		{index: 3, role: "User", text: "three", created: base.Add(2 * time.Hour)},
+		{index: 4, role: "Assistant", text: "four", created: base.Add(3 * time.Hour)},
+	}
+
+	texts := func(entries []transcriptEntry) string {
+		var parts []string
+		for _, e := range entries {
+			parts = append(parts, e.text)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	tests := []struct {
+		name   string
+		filter transcriptFilter
+		want   string
+	}{
+		{"everything", transcriptFilter{}, "one,two,three,four"},
+		{"message range", transcriptFilter{from: &transcriptBound{index: 2}, to: &transcriptBound{index: 3}}, "two,three"},
+		{"time range", transcriptFilter{from: &transcriptBound{at: base.Add(time.Hour)}}, "two,three,four"},
+		{"role", transcriptFilter{role: "assistant"}, "two,four"},
+		{"role within a range", transcriptFilter{role: "user", to: &transcriptBound{index: 2}}, "one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.apply(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if texts(got) != tt.want {
+				t.Errorf("got %s, want %s", texts(got), tt.want)
+			}
+		})
+	}
+
+	t.Run("time range without times", func(t *testing.T) {
+		filter := transcriptFilter{from: &transcriptBound{at: base}}
+		if _, err := filter.apply([]transcriptEntry{{index: 1, role: "User", text: "x"}}); !errors.Is(err, errNoTimestamps) {
+			t.Errorf("expected errNoTimestamps, got %v", err)
+		}
+	})
+}
+
+func TestSaveFilteredTranscript(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	chatRepo := repository.NewChatRepository(database)
+
+	s, _ := newTestChatSession()
+	s.chatRepo = chatRepo
+	s.systemPrompt = "Be terse."
+	for _, chat := range []repository.Chat{
+		{ChatId: s.chatId, Persona: "User", Message: "hello"},
+		{ChatId: s.chatId, Persona: "Assistant", Message: "hi there"},
+		{ChatId: s.chatId, Persona: "User", Message: "what time is it?"},
+		{ChatId: s.chatId, Persona: "Assistant", Message: "noon"},
+	} {
+		if err := chatRepo.Create(t.Context(), &chat); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("whole conversation with the system prompt", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chat.md")
+		if _, err := dispatchSlashCommand(s, "/save "+path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "## System\n\nBe terse.\n\n## User\n\nhello\n\n") || !strings.HasSuffix(string(data), "## Assistant\n\nnoon\n\n") {
+			t.Errorf("unexpected transcript %q", data)
+		}
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chat.md")
+		today := time.Now().Format("2006-01-02")
+		if _, err := dispatchSlashCommand(s, "/save "+path+" --from 2 --to "+today+" --role assistant --strip-system"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "## Assistant\n\nhi there\n\n## Assistant\n\nnoon\n\n"
+		if string(data) != want {
+			t.Errorf("expected %q, got %q", want, data)
+		}
+	})
+	t.Run("with notes", func(t *testing.T) {
+		notes := repository.NewAnnotationRepository(database)
+		s.annotations = notes
+		messages, err := chatRepo.GetMessages(t.Context(), s.chatId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, note := range []repository.Annotation{
+			{ChatId: s.chatId, Note: "asked for the demo"},
+			{ChatId: s.chatId, MessageID: messages[3].ID, Note: "it was\nmidnight"},
+		} {
+			if err := notes.Create(t.Context(), &note); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		path := filepath.Join(t.TempDir(), "chat.md")
+		if _, err := dispatchSlashCommand(s, "/save "+path+" --strip-system"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "> **Note:** asked for the demo\n\n## User\n\nhello\n\n") || !strings.HasSuffix(string(data), "## Assistant\n\nnoon\n\n> **Note:** it was\n> midnight\n\n") {
+			t.Errorf("unexpected transcript %q", data)
+		}
+	})
+}
@@ -0,0 +1,484 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+const (
+	// handoffVersion is the version of the bundle format chat handoff
+	// writes. chat receive refuses bundles from a newer chat-cli.
+	handoffVersion = 1
+	// handoffChatFile is the bundle entry holding the chat.
+	handoffChatFile = "chat.json"
+	// handoffScratchDir is the bundle directory holding scratch snippets.
+	handoffScratchDir = "scratch/"
+	// maxHandoffEntry caps how much of a bundle entry chat receive reads.
+	maxHandoffEntry = 64 << 20
+)
+
+// handoffBundle is the chat in a handoff bundle. Messages carry the path and
+// hash of any image they attached, but not the image itself.
+type handoffBundle struct {
+	Version     int               `json:"version"`
+	ChatID      string            `json:"chat_id"`
+	Title       string            `json:"title,omitempty"`
+	HandedOffAt time.Time         `json:"handed_off_at"`
+	Settings    map[string]string `json:"settings,omitempty"`
+	// Notes are the notes from chat annotate on the whole chat.
+	Notes    []string          `json:"notes,omitempty"`
+	Messages []archivedMessage `json:"messages"`
+}
+
+// buildHandoff collects the chat with id chatId, its settings and its notes
+// into a bundle.
+func buildHandoff(ctx context.Context, database db.Database, chatId string) (*handoffBundle, error) {
+	repo := repository.NewChatRepository(database)
+	messages, err := repo.GetMessages(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no chat with id %s", chatId)
+	}
+	title, err := repo.GetTitle(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := repository.NewChatSettingsRepository(database).Get(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := repository.NewAnnotationRepository(database).List(ctx, chatId)
+	if err != nil {
+		return nil, err
+	}
+	onChat, onMessage := chatNotes(messages, annotations)
+
+	bundle := &handoffBundle{
+		Version:     handoffVersion,
+		ChatID:      chatId,
+		Title:       title,
+		HandedOffAt: time.Now().UTC(),
+		Settings:    settings,
+		Notes:       onChat,
+	}
+	for _, m := range messages {
+		bundle.Messages = append(bundle.Messages, archivedMessage{
+			Role:       m.Persona,
+			Message:    m.Message,
+			CreatedAt:  m.Created,
+			ImagePath:  m.ImagePath,
+			ImageHash:  m.ImageHash,
+			StopReason: m.StopReason,
+			Resumes:    m.Resumes,
+			Notes:      onMessage[m.ID],
+		})
+	}
+	return bundle, nil
+}
+
+// readScratchSnippets returns the scratch snippets saved in dir, by name.
+// A missing dir has none.
+func readScratchSnippets(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snippets := map[string]string{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), scratchExt)
+		if !ok || !entry.Type().IsRegular() || !scratchName.MatchString(name) {
+			continue
+		}
+		text, err := readScratch(filepath.Join(dir, entry.Name()), name)
+		if err != nil {
+			return nil, err
+		}
+		snippets[name] = text
+	}
+	return snippets, nil
+}
+
+// writeHandoff writes bundle and snippets to w as a gzip-compressed tar:
+// chat.json, then each snippet as scratch/<name>.md.
+func writeHandoff(w io.Writer, bundle *handoffBundle, snippets map[string]string) error {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	add := func(name string, content []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: bundle.HandedOffAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+
+	if err := add(handoffChatFile, data); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(snippets))
+	for name := range snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := add(handoffScratchDir+name+scratchExt, []byte(snippets[name])); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// readHandoff reads a bundle written by writeHandoff. Entries it doesn't
+// know, including any path outside the bundle, are ignored, so nothing in
+// a bundle can write where it shouldn't.
+func readHandoff(r io.Reader) (*handoffBundle, map[string]string, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a chat-cli handoff bundle: %w", err)
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	var bundle *handoffBundle
+	snippets := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxHandoffEntry {
+			return nil, nil, fmt.Errorf("bundle entry %s is too large", header.Name)
+		}
+
+		name := path.Clean(header.Name)
+		snippet, isSnippet := strings.CutPrefix(name, handoffScratchDir)
+		snippet, hasExt := strings.CutSuffix(snippet, scratchExt)
+		switch {
+		case name == handoffChatFile:
+			bundle = &handoffBundle{}
+			if err := json.NewDecoder(io.LimitReader(tr, maxHandoffEntry)).Decode(bundle); err != nil {
+				return nil, nil, fmt.Errorf("error parsing %s in bundle: %w", handoffChatFile, err)
+			}
+		case isSnippet && hasExt && scratchName.MatchString(snippet):
+			text, err := io.ReadAll(io.LimitReader(tr, maxHandoffEntry))
+			if err != nil {
+				return nil, nil, fmt.Errorf("error reading bundle: %w", err)
+			}
+			snippets[snippet] = string(text)
+		}
+	}
+
+	if bundle == nil {
+		return nil, nil, fmt.Errorf("not a chat-cli handoff bundle: no %s", handoffChatFile)
+	}
+	if bundle.Version > handoffVersion {
+		return nil, nil, fmt.Errorf("the bundle was made by a newer chat-cli (format %d); upgrade to receive it", bundle.Version)
+	}
+	if bundle.ChatID == "" || len(bundle.Messages) == 0 {
+		return nil, nil, errors.New("the bundle has no chat in it")
+	}
+	return bundle, snippets, nil
+}
+
+// errChatExists is returned by importHandoff when the bundle's chat is
+// already in chat history and replace isn't set.
+var errChatExists = errors.New("chat already exists")
+
+// importHandoff saves the bundle's chat, with its title, settings and
+// notes, into chat history under its own id. A chat already saved under
+// that id, such as the one a bundle was made from when it is handed back,
+// is only replaced when replace is set, and is left as it was if the
+// import fails.
+func importHandoff(ctx context.Context, database db.Database, bundle *handoffBundle, replace bool) error {
+	repo := repository.NewChatRepository(database)
+	existing, err := repo.GetMessages(ctx, bundle.ChatID)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && !replace {
+		return fmt.Errorf("%w: %s has %s in chat history", errChatExists, bundle.ChatID, countOf(len(existing), "message"))
+	}
+
+	chat := &repository.ImportedChat{
+		ChatId:   bundle.ChatID,
+		Title:    bundle.Title,
+		Notes:    bundle.Notes,
+		Settings: bundle.Settings,
+	}
+	for _, m := range bundle.Messages {
+		chat.Messages = append(chat.Messages, repository.ImportedMessage{
+			Chat: repository.Chat{
+				Persona:    m.Role,
+				Message:    m.Message,
+				ImagePath:  m.ImagePath,
+				ImageHash:  m.ImageHash,
+				StopReason: m.StopReason,
+				Resumes:    m.Resumes,
+			},
+			Notes: m.Notes,
+		})
+	}
+	return repo.Replace(ctx, chat)
+}
+
+// saveScratchSnippets writes snippets to dir, leaving alone any snippet
+// already there with different text. It returns the names it saved and
+// those it kept, sorted.
+func saveScratchSnippets(dir string, snippets map[string]string) (saved, kept []string, err error) {
+	if len(snippets) == 0 {
+		return nil, nil, nil
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, nil, err
+	}
+	for name, text := range snippets {
+		file := filepath.Join(dir, name+scratchExt)
+		if current, err := readScratch(file, name); err == nil {
+			if current != text {
+				kept = append(kept, name)
+			}
+			continue
+		}
+		if err := os.WriteFile(file, []byte(text+"\n"), 0600); err != nil {
+			return saved, kept, err
+		}
+		saved = append(saved, name)
+	}
+	sort.Strings(saved)
+	sort.Strings(kept)
+	return saved, kept, nil
+}
+
+// chatHandoffCmd represents the chat handoff command
+var chatHandoffCmd = &cobra.Command{
+	Use:   "handoff",
+	Short: "Pack a chat into a bundle to continue it on another machine",
+	Long: `Write a chat to a single .tgz bundle: its messages, title, the settings changed in it
+with /set, its notes, the details of any images it attached, and your /scratch
+snippets. Copy the bundle to another machine and continue there with chat receive.
+
+Images themselves aren't included: a chat that attached one shows a warning where
+the image can't be found.
+
+> chat-cli chat handoff --chat-id <id>
+> chat-cli chat receive <id>.tgz`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		chatId, err := cmd.Flags().GetString("chat-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if chatId == "" {
+			log.Fatal("pass the chat to hand off with --chat-id (see chat-cli chat list)")
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if output == "" {
+			output = archiveFileName(chatId) + ".tgz"
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		bundle, err := buildHandoff(cmd.Context(), database, chatId)
+		if err != nil {
+			log.Fatal(err)
+		}
+		snippets, err := readScratchSnippets(filepath.Join(fm.DataPath, "scratch"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// write under a temporary name so a failure leaves no half bundle
+		tmp := output + ".tmp"
+		f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 - the user chose the output path
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeHandoff(f, bundle, snippets); err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+			log.Fatalf("error writing bundle: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			_ = os.Remove(tmp)
+			log.Fatal(err)
+		}
+		if err := os.Rename(tmp, output); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Wrote %s (%s", output, countOf(len(bundle.Messages), "message"))
+		if len(snippets) > 0 {
+			fmt.Printf(", %s", countOf(len(snippets), "scratch snippet"))
+		}
+		fmt.Println(")")
+		fmt.Printf("On the other machine, run: chat-cli chat receive %s\n", filepath.Base(output))
+	},
+}
+
+// chatReceiveCmd represents the chat receive command
+var chatReceiveCmd = &cobra.Command{
+	Use:   "receive <bundle.tgz>",
+	Short: "Import a chat handed off from another machine",
+	Long: `Import a bundle written by chat handoff into chat history, with the chat's title,
+settings, and notes, and add its /scratch snippets to yours. A snippet you already
+have with different text is kept as it is.
+
+The chat keeps its id, so handing it back to the machine it came from needs
+--replace to overwrite the older copy there. With --resume, the chat starts as
+soon as it's imported:
+
+> chat-cli chat receive handoff.tgz --resume`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		replace, err := cmd.Flags().GetBool("replace")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		resume, err := cmd.Flags().GetBool("resume")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		f, err := os.Open(args[0]) // #nosec G304 - the user chose the bundle
+		if err != nil {
+			log.Fatal(err)
+		}
+		bundle, snippets, err := readHandoff(f)
+		_ = f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = importHandoff(cmd.Context(), database, bundle, replace)
+		if closeErr := database.Close(); closeErr != nil {
+			log.Printf("Warning: failed to close database: %v", closeErr)
+		}
+		if errors.Is(err, errChatExists) {
+			log.Fatalf("%v; pass --replace to overwrite it with the bundle", err)
+		}
+		if err != nil {
+			log.Fatalf("error importing the chat: %v", err)
+		}
+
+		saved, kept, err := saveScratchSnippets(filepath.Join(fm.DataPath, "scratch"), snippets)
+		if err != nil {
+			log.Printf("Warning: failed to save scratch snippets: %v", err)
+		}
+
+		name := bundle.ChatID
+		if bundle.Title != "" {
+			name = fmt.Sprintf("%q (%s)", bundle.Title, bundle.ChatID)
+		}
+		fmt.Printf("Received chat %s: %s\n", name, countOf(len(bundle.Messages), "message"))
+		if len(saved) > 0 {
+			fmt.Printf("Added scratch snippets: %s\n", strings.Join(saved, ", "))
+		}
+		if len(kept) > 0 {
+			fmt.Printf("Kept your own scratch snippets: %s\n", strings.Join(kept, ", "))
+		}
+
+		if !resume {
+			fmt.Printf("Continue it with: chat-cli --chat-id %s\n", bundle.ChatID)
+			return
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatal(err)
+		}
+		// like ui, the chat runs as its own process so Ctrl+C reaches it
+		chat := exec.Command(exe, append([]string{"--chat-id", bundle.ChatID}, forwardedFlags(cmd)...)...) // #nosec G204 - runs chat-cli itself on the chat just imported
+		chat.Stdin, chat.Stdout, chat.Stderr = os.Stdin, os.Stdout, os.Stderr
+		var exitErr *exec.ExitError
+		if err := chat.Run(); errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		} else if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	chatCmd.AddCommand(chatHandoffCmd)
+	chatCmd.AddCommand(chatReceiveCmd)
+
+	chatHandoffCmd.Flags().StringP("output", "o", "", "where to write the bundle (default <chat-id>.tgz)")
+
+	chatReceiveCmd.Flags().Bool("replace", false, "overwrite a chat already saved under the bundle's chat id")
+	chatReceiveCmd.Flags().Bool("resume", false, "start the chat as soon as it's imported")
+}
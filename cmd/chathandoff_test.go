@@ -0,0 +1,257 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func newHandoffTestDB(t *testing.T) db.Database {
+	t.Helper()
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = database.Close()
+	})
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func TestHandoffRoundTrip(t *testing.T) {
+	ctx := t.Context()
+	source := newHandoffTestDB(t)
+	repo := repository.NewChatRepository(source)
+	for _, m := range []repository.Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "What's in this picture?", ImagePath: "/home/me/cat.png", ImageHash: "abc123"},
+		{ChatId: "chat-1", Persona: "Assistant", Message: "A cat.", StopReason: "end_turn"},
+	} {
+		if err := repo.Create(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.SetTitle(ctx, "chat-1", "Cat picture"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewChatSettingsRepository(source).Set(ctx, "chat-1", "temperature", "0.3"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := repo.GetMessages(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := repository.NewAnnotationRepository(source)
+	for _, note := range []repository.Annotation{
+		{ChatId: "chat-1", Note: "follow up on breeds"},
+		{ChatId: "chat-1", MessageID: messages[1].ID, Note: "too short"},
+	} {
+		if err := notes.Create(ctx, &note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := buildHandoff(ctx, source, "missing"); err == nil {
+		t.Error("expected an error handing off a chat that doesn't exist")
+	}
+	bundle, err := buildHandoff(ctx, source, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeHandoff(&buf, bundle, map[string]string{"plan": "1. pet cat"}); err != nil {
+		t.Fatal(err)
+	}
+
+	received, snippets, err := readHandoff(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snippets, map[string]string{"plan": "1. pet cat"}) {
+		t.Errorf("snippets = %v", snippets)
+	}
+
+	target := newHandoffTestDB(t)
+	if err := importHandoff(ctx, target, received, false); err != nil {
+		t.Fatal(err)
+	}
+
+	targetRepo := repository.NewChatRepository(target)
+	got, err := targetRepo.GetMessages(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Message != "What's in this picture?" || got[0].ImagePath != "/home/me/cat.png" || got[0].ImageHash != "abc123" || got[1].StopReason != "end_turn" {
+		t.Errorf("imported messages = %+v", got)
+	}
+	if title, _ := targetRepo.GetTitle(ctx, "chat-1"); title != "Cat picture" {
+		t.Errorf("title = %q", title)
+	}
+	if settings, _ := repository.NewChatSettingsRepository(target).Get(ctx, "chat-1"); settings["temperature"] != "0.3" {
+		t.Errorf("settings = %v", settings)
+	}
+	annotations, err := repository.NewAnnotationRepository(target).List(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	onChat, onMessage := chatNotes(got, annotations)
+	if !reflect.DeepEqual(onChat, []string{"follow up on breeds"}) || !reflect.DeepEqual(onMessage[got[1].ID], []string{"too short"}) {
+		t.Errorf("notes = %v, %v", onChat, onMessage)
+	}
+
+	// handing it back to a machine that has the chat already
+	if err := importHandoff(ctx, source, received, false); !errors.Is(err, errChatExists) {
+		t.Errorf("expected errChatExists, got %v", err)
+	}
+	received.Messages = append(received.Messages, archivedMessage{Role: "User", Message: "Which breed?"})
+	if err := importHandoff(ctx, source, received, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.GetMessages(ctx, "chat-1"); len(got) != 3 {
+		t.Errorf("expected the replaced chat to have 3 messages, got %d", len(got))
+	}
+	if annotations, _ := notes.List(ctx, "chat-1"); len(annotations) != 2 {
+		t.Errorf("expected the old copy's notes to be replaced, got %d", len(annotations))
+	}
+}
+
+func TestImportHandoffFailureKeepsChat(t *testing.T) {
+	ctx := t.Context()
+	database := newHandoffTestDB(t)
+	repo := repository.NewChatRepository(database)
+	for _, m := range []repository.Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "Hello"},
+		{ChatId: "chat-1", Persona: "Assistant", Message: "Hi there."},
+	} {
+		if err := repo.Create(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.SetTitle(ctx, "chat-1", "Greeting"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewChatSettingsRepository(database).Set(ctx, "chat-1", "temperature", "0.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// fail once the old copy is deleted and the new messages are in
+	if _, err := database.GetDB().ExecContext(ctx, `
+        CREATE TRIGGER fail_notes BEFORE INSERT ON annotations
+        BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatal(err)
+	}
+	bundle := &handoffBundle{
+		ChatID: "chat-1",
+		Title:  "Replaced",
+		Messages: []archivedMessage{
+			{Role: "User", Message: "Something else"},
+			{Role: "Assistant", Message: "Sure.", Notes: []string{"good answer"}},
+		},
+	}
+	if err := importHandoff(ctx, database, bundle, true); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected the import to fail, got %v", err)
+	}
+
+	got, err := repo.GetMessages(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Message != "Hello" || got[1].Message != "Hi there." {
+		t.Errorf("expected the original chat to survive, got %+v", got)
+	}
+	if title, _ := repo.GetTitle(ctx, "chat-1"); title != "Greeting" {
+		t.Errorf("title = %q", title)
+	}
+	if settings, _ := repository.NewChatSettingsRepository(database).Get(ctx, "chat-1"); settings["temperature"] != "0.3" {
+		t.Errorf("settings = %v", settings)
+	}
+}
+
+func TestReadHandoffRejects(t *testing.T) {
+	bundleWith := func(entries map[string]string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		for name, content := range entries {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = tw.Close()
+		_ = zw.Close()
+		return &buf
+	}
+	chat := `{"version": 1, "chat_id": "c", "messages": [{"role": "User", "message": "hi"}]}`
+
+	_, snippets, err := readHandoff(bundleWith(map[string]string{
+		"chat.json":             chat,
+		"scratch/../../evil.md": "x",
+		"scratch/.hidden.md":    "x",
+		"other.txt":             "x",
+		"scratch/ok.md":         "fine",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snippets, map[string]string{"ok": "fine"}) {
+		t.Errorf("expected only the valid snippet, got %v", snippets)
+	}
+
+	for name, input := range map[string]*bytes.Buffer{
+		"not gzip":     bytes.NewBufferString("hello"),
+		"no chat":      bundleWith(map[string]string{"scratch/ok.md": "fine"}),
+		"newer format": bundleWith(map[string]string{"chat.json": `{"version": 99, "chat_id": "c", "messages": [{"role": "User", "message": "hi"}]}`}),
+		"empty chat":   bundleWith(map[string]string{"chat.json": `{"version": 1, "chat_id": "c"}`}),
+	} {
+		if _, _, err := readHandoff(input); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSaveScratchSnippets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mine.md"), []byte("my version\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "same.md"), []byte("same\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, kept, err := saveScratchSnippets(dir, map[string]string{"mine": "their version", "same": "same", "new": "new text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(saved, []string{"new"}) || !reflect.DeepEqual(kept, []string{"mine"}) {
+		t.Errorf("saved %v, kept %v", saved, kept)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "mine.md")); !strings.HasPrefix(string(data), "my version") {
+		t.Errorf("expected the local snippet to be kept, got %q", data)
+	}
+
+	snippets, err := readScratchSnippets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snippets["new"] != "new text" || len(snippets) != 3 {
+		t.Errorf("readScratchSnippets = %v", snippets)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// chatImage is an image attached with /image, waiting to go out with the
+// next message.
+type chatImage struct {
+	path  string
+	hash  string
+	size  int
+	block types.ContentBlock
+}
+
+// loadChatImage reads the image at path for a chat message. The path is
+// kept in absolute form so a resumed chat can find the image again from
+// any directory.
+func loadChatImage(path string) (chatImage, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return chatImage{}, fmt.Errorf("unable to resolve %s: %w", path, err)
+	}
+
+	data, format, err := utils.ReadImage(absPath)
+	if err != nil {
+		return chatImage{}, fmt.Errorf("unable to read image %s: %w", path, err)
+	}
+	if len(data) > maxImageBytes {
+		return chatImage{}, fmt.Errorf("image %s is %s; images can be at most %s", path, formatByteSize(len(data)), formatByteSize(maxImageBytes))
+	}
+
+	sum := sha256.Sum256(data)
+	return chatImage{
+		path: absPath,
+		hash: hex.EncodeToString(sum[:]),
+		size: len(data),
+		block: &types.ContentBlockMemberImage{
+			Value: types.ImageBlock{
+				Format: types.ImageFormat(format),
+				Source: &types.ImageSourceMemberBytes{Value: data},
+			},
+		},
+	}, nil
+}
+
+// imageHistoryMessage is the text saved in place of an attached image.
+func imageHistoryMessage(path string) string {
+	return fmt.Sprintf("[image: %s]", path)
+}
+
+func runImageCommand(s *chatSession, args string) error {
+	switch args {
+	case "":
+		if len(s.pendingImages) == 0 {
+			fmt.Fprintln(s.out, "No images attached. Use /image <path> to attach one to your next message.")
+			return nil
+		}
+		fmt.Fprintln(s.out, "Attached to your next message:")
+		for _, img := range s.pendingImages {
+			fmt.Fprintf(s.out, "  %s (%s)\n", img.path, formatByteSize(img.size))
+		}
+		return nil
+	case "clear":
+		s.pendingImages = nil
+		fmt.Fprintln(s.out, "Attachments cleared.")
+		return nil
+	}
+
+	modelID := aws.ToString(s.input.ModelId)
+	if limit := maxImagesForModel(modelID); len(s.pendingImages)+1 > limit {
+		return fmt.Errorf("%s accepts at most %d images per message", modelID, limit)
+	}
+
+	img, err := loadChatImage(args)
+	if err != nil {
+		return err
+	}
+
+	total := img.size
+	for _, pending := range s.pendingImages {
+		total += pending.size
+	}
+	if total > maxImagePayloadBytes {
+		return fmt.Errorf("images total more than %s; send what's attached before adding more", formatByteSize(maxImagePayloadBytes))
+	}
+
+	s.pendingImages = append(s.pendingImages, img)
+	fmt.Fprintf(s.out, "Attached %s (%s); it will be sent with your next message.\n", filepath.Base(img.path), formatByteSize(img.size))
+	return nil
+}
+
+// takeImages returns the images attached since the last message and
+// clears them.
+func (s *chatSession) takeImages() []chatImage {
+	images := s.pendingImages
+	s.pendingImages = nil
+	return images
+}
+
+// userMessageWithImages builds a user message carrying images ahead of
+// text, the order models handle best.
+func userMessageWithImages(images []chatImage, text string) types.Message {
+	content := make([]types.ContentBlock, 0, len(images)+1)
+	for _, img := range images {
+		content = append(content, img.block)
+	}
+	content = append(content, &types.ContentBlockMemberText{Value: text})
+
+	return types.Message{Role: types.ConversationRoleUser, Content: content}
+}
+
+// recordImage saves a reference to an attached image in the chat history.
+func (s *chatSession) recordImage(img chatImage) {
+	if s.chatRepo == nil {
+		return
+	}
+
+	chat := &repository.Chat{
+		ChatId:    s.chatId,
+		Persona:   "User",
+		Message:   imageHistoryMessage(img.path),
+		ImagePath: img.path,
+		ImageHash: img.hash,
+	}
+	if err := s.chatRepo.Create(s.context(), chat); err != nil {
+		log.Printf("Failed to create chat: %v", err)
+	}
+}
+
+// historyMessages rebuilds a saved conversation. Image references are
+// re-read from disk and attached to the user message that followed them;
+// an image that has been moved or changed since is replaced by a note, and
+// a warning is returned for it.
+func historyMessages(chats []repository.Chat) ([]types.Message, []string) {
+	var messages []types.Message
+	var images []types.ContentBlock
+	var warnings []string
+
+	for _, chat := range chats {
+		if chat.ImagePath != "" {
+			block, warning := restoreChatImage(chat.ImagePath, chat.ImageHash)
+			images = append(images, block)
+			if warning != "" {
+				warnings = append(warnings, warning)
+			}
+			continue
+		}
+
+		role := types.ConversationRoleAssistant
+		if chat.Persona == "User" {
+			role = types.ConversationRoleUser
+		}
+
+		content := []types.ContentBlock{&types.ContentBlockMemberText{Value: chat.Message}}
+		if role == types.ConversationRoleUser && len(images) > 0 {
+			content = append(images, content...)
+			images = nil
+		}
+		messages = append(messages, types.Message{Role: role, Content: content})
+	}
+
+	// images attached to a message that was never saved
+	if len(images) > 0 {
+		messages = append(messages, types.Message{Role: types.ConversationRoleUser, Content: images})
+	}
+
+	return messages, warnings
+}
+
+// restoreChatImage reads a saved image reference back, returning a text
+// note in its place if the file is gone or no longer matches its hash.
+func restoreChatImage(path, hash string) (types.ContentBlock, string) {
+	img, err := loadChatImage(path)
+	if err != nil {
+		return &types.ContentBlockMemberText{Value: fmt.Sprintf("[image %s is no longer available]", path)},
+			fmt.Sprintf("image %s could not be reattached: %v", path, err)
+	}
+	if hash != "" && img.hash != hash {
+		return &types.ContentBlockMemberText{Value: fmt.Sprintf("[image %s has changed since it was attached]", path)},
+			fmt.Sprintf("image %s has changed since it was attached, so it was left out", path)
+	}
+	return img.block, ""
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "image",
+		usage:       "[path|clear]",
+		description: "Attach an image to your next message, or list attachments",
+		run:         runImageCommand,
+	})
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestRunImageCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("attaches an image for the next message", func(t *testing.T) {
+		s, out := newTestChatSession()
+		path := writeTestImage(t, dir, "diagram.png", 10)
+		if err := runImageCommand(s, path); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.pendingImages) != 1 || s.pendingImages[0].path != path {
+			t.Fatalf("expected %s to be pending, got %+v", path, s.pendingImages)
+		}
+		if !strings.Contains(out.String(), "diagram.png") {
+			t.Errorf("expected confirmation naming the image, got %q", out.String())
+		}
+
+		images := s.takeImages()
+		if len(images) != 1 || len(s.pendingImages) != 0 {
+			t.Errorf("expected takeImages to hand over and clear attachments")
+		}
+	})
+
+	t.Run("lists and clears attachments", func(t *testing.T) {
+		s, out := newTestChatSession()
+		path := writeTestImage(t, dir, "photo.jpg", 10)
+		if err := runImageCommand(s, path); err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if err := runImageCommand(s, ""); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), path) {
+			t.Errorf("expected the listing to include %s, got %q", path, out.String())
+		}
+		if err := runImageCommand(s, "clear"); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.pendingImages) != 0 {
+			t.Errorf("expected attachments to be cleared")
+		}
+	})
+
+	t.Run("respects the model's image limit", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.input.ModelId = aws.String("us.meta.llama3-2-90b-instruct-v1:0")
+		if err := runImageCommand(s, writeTestImage(t, dir, "one.png", 10)); err != nil {
+			t.Fatal(err)
+		}
+		if err := runImageCommand(s, writeTestImage(t, dir, "two.png", 10)); err == nil {
+			t.Error("expected a second image to be refused")
+		}
+	})
+
+	t.Run("rejects unsupported files", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		if err := runImageCommand(s, writeTestImage(t, dir, "notes.txt", 10)); err == nil {
+			t.Error("expected an error for a non-image file")
+		}
+	})
+
+	t.Run("clear command drops attachments", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		if err := runImageCommand(s, writeTestImage(t, dir, "pending.png", 10)); err != nil {
+			t.Fatal(err)
+		}
+		if err := runClearCommand(s, ""); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.pendingImages) != 0 {
+			t.Errorf("expected /clear to drop attachments")
+		}
+	})
+}
+
+func TestUserMessageWithImages(t *testing.T) {
+	img, err := loadChatImage(writeTestImage(t, t.TempDir(), "a.png", 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := userMessageWithImages([]chatImage{img}, "what is this?")
+	if len(msg.Content) != 2 {
+		t.Fatalf("expected image and text blocks, got %d", len(msg.Content))
+	}
+	if _, ok := msg.Content[0].(*types.ContentBlockMemberImage); !ok {
+		t.Errorf("expected the image first, got %T", msg.Content[0])
+	}
+	if messageText(msg) != "what is this?" {
+		t.Errorf("unexpected text %q", messageText(msg))
+	}
+}
+
+func TestHistoryMessages(t *testing.T) {
+	dir := t.TempDir()
+	kept := writeTestImage(t, dir, "kept.png", 10)
+	keptImage, err := loadChatImage(kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := writeTestImage(t, dir, "changed.png", 10)
+	changedImage, err := loadChatImage(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(changed, []byte("different"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	chats := []repository.Chat{
+		{Persona: "User", Message: imageHistoryMessage(kept), ImagePath: kept, ImageHash: keptImage.hash},
+		{Persona: "User", Message: imageHistoryMessage(changed), ImagePath: changed, ImageHash: changedImage.hash},
+		{Persona: "User", Message: imageHistoryMessage(dir + "/gone.png"), ImagePath: dir + "/gone.png", ImageHash: "abc"},
+		{Persona: "User", Message: "compare these"},
+		{Persona: "Assistant", Message: "They differ."},
+	}
+
+	messages, warnings := historyMessages(chats)
+	if len(messages) != 2 {
+		t.Fatalf("expected image rows to fold into the next user message, got %d messages", len(messages))
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected warnings for the changed and missing images, got %v", warnings)
+	}
+
+	content := messages[0].Content
+	if len(content) != 4 {
+		t.Fatalf("expected 3 image references and the text, got %d blocks", len(content))
+	}
+	if _, ok := content[0].(*types.ContentBlockMemberImage); !ok {
+		t.Errorf("expected the unchanged image to be reattached, got %T", content[0])
+	}
+	for _, block := range content[1:3] {
+		if _, ok := block.(*types.ContentBlockMemberText); !ok {
+			t.Errorf("expected a note in place of an unavailable image, got %T", block)
+		}
+	}
+	if messages[1].Role != types.ConversationRoleAssistant {
+		t.Errorf("expected the assistant reply second, got %s", messages[1].Role)
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+const (
+	// githubTokenKey is the config key holding the token chat to-issue
+	// creates issues with.
+	githubTokenKey = "github.token"
+	githubAPIURL   = "https://api.github.com"
+	// githubTimeout bounds each request to the GitHub API.
+	githubTimeout = 30 * time.Second
+	// maxIssueMessage caps how much of each message is summarized; the
+	// start of a long message is enough to say what it was about.
+	maxIssueMessage = 4000
+)
+
+const issueInstruction = "Summarize the conversation below as a GitHub issue for someone who wasn't part of it. " +
+	"Reply with a line starting \"Title: \" giving a short, specific issue title, then the issue body in Markdown " +
+	"with three sections: \"## Problem\" describing what the user was trying to do or what went wrong, " +
+	"\"## Discussion\" covering what was tried, found, or decided, and \"## Resolution\" giving the outcome " +
+	"or, if there wasn't one, what's still open. Keep commands, code, and error messages that matter in code blocks. " +
+	"Don't invent details that aren't in the conversation."
+
+// githubRepoPattern matches an owner/repo name.
+var githubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
+// redactToken hides all but the last four characters of a token so it
+// can be displayed.
+func redactToken(token string) string {
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+	return strings.Repeat("*", 8) + token[len(token)-4:]
+}
+
+// issueRequest asks modelID to summarize a saved chat as an issue.
+func issueRequest(modelID string, notes []string, entries []transcriptEntry) *bedrockruntime.ConverseInput {
+	for i := range entries {
+		entries[i].text = truncateRunes(entries[i].text, maxIssueMessage)
+	}
+	return &bedrockruntime.ConverseInput{
+		ModelId: aws.String(modelID),
+		System:  buildSystemContentBlocks(issueInstruction),
+		Messages: []types.Message{
+			userTextMessage("<conversation>\n" + strings.TrimSpace(renderTranscript("", notes, entries)) + "\n</conversation>"),
+		},
+	}
+}
+
+// issueDraft is a conversation summarized as an issue.
+type issueDraft struct {
+	title string
+	body  string
+}
+
+// parseIssueDraft splits the model's summary into the title on its
+// "Title:" line and the body after it.
+func parseIssueDraft(reply string) (issueDraft, error) {
+	reply = strings.TrimSpace(reply)
+	first, body, _ := strings.Cut(reply, "\n")
+	label, title, found := strings.Cut(first, ":")
+	if !found || !strings.EqualFold(strings.Trim(label, "*# "), "title") {
+		return issueDraft{}, errors.New("the summary has no title line")
+	}
+
+	draft := issueDraft{
+		title: strings.TrimSuffix(strings.Trim(title, " \"'`*"), "."),
+		body:  strings.TrimSpace(body),
+	}
+	if draft.title == "" || draft.body == "" {
+		return issueDraft{}, errors.New("the summary is missing its title or body")
+	}
+	return draft, nil
+}
+
+// githubClient creates issues through the GitHub REST API.
+type githubClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func newGitHubClient(token string) *githubClient {
+	return &githubClient{baseURL: githubAPIURL, token: token, httpClient: &http.Client{Timeout: githubTimeout}}
+}
+
+// githubIssue is the part of a created issue that's reported.
+type githubIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// createIssue opens an issue in repo, given as owner/repo.
+func (c *githubClient) createIssue(ctx context.Context, repo string, draft issueDraft) (*githubIssue, error) {
+	payload, err := json.Marshal(map[string]string{"title": draft.title, "body": draft.body})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/repos/"+repo+"/issues", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			return nil, fmt.Errorf("GitHub rejected the token (%s); check %s", apiErr.Message, githubTokenKey)
+		case http.StatusForbidden, http.StatusNotFound:
+			return nil, fmt.Errorf("unable to create an issue in %s (HTTP %d: %s): check the repository exists, has issues enabled, and the token can write to it", repo, resp.StatusCode, apiErr.Message)
+		}
+		return nil, fmt.Errorf("unable to create an issue in %s (HTTP %d): %s", repo, resp.StatusCode, apiErr.Message)
+	}
+
+	var issue githubIssue
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &issue, nil
+}
+
+// chatToIssueCmd represents the chat to-issue command
+var chatToIssueCmd = &cobra.Command{
+	Use:   "to-issue",
+	Short: "Summarize a saved chat as a GitHub issue",
+	Long: `Have the model summarize a saved chat as an issue - the problem, the discussion,
+and the resolution - and open it in a GitHub repository.
+
+Issues are created with the token in the github.token setting, which needs
+permission to write issues in the repository:
+
+> chat-cli config secret set github.token
+> chat-cli chat to-issue --chat-id 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90 --github my-org/my-repo
+
+With --dry-run the issue is printed instead of created.`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		chatId, err := cmd.Flags().GetString("chat-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if chatId == "" {
+			log.Fatal("pass the chat to summarize with --chat-id (see chat-cli chat list)")
+		}
+
+		repo, err := cmd.Flags().GetString("github")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if !githubRepoPattern.MatchString(repo) {
+			log.Fatal("pass the repository to open the issue in with --github owner/repo")
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelIdFlag, err := cmd.Flags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		customArnFlag, err := cmd.Flags().GetString("custom-arn")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		token, err := configSecret(fm, githubTokenKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if token == "" && !dryRun {
+			log.Fatalf("no GitHub token: store one that can write issues in %s with chat-cli config secret set %s", repo, githubTokenKey)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		chatRepo := repository.NewChatRepository(database)
+		messages, err := chatRepo.GetMessages(cmd.Context(), chatId)
+		if err != nil {
+			log.Fatalf("Failed to load chat: %v", err)
+		}
+		if len(messages) == 0 {
+			log.Fatalf("no chat found with id %s", chatId)
+		}
+		annotations, err := repository.NewAnnotationRepository(database).List(cmd.Context(), chatId)
+		if err != nil {
+			log.Fatalf("Failed to load notes: %v", err)
+		}
+		notes, onMessage := chatNotes(messages, annotations)
+
+		entries := make([]transcriptEntry, 0, len(messages))
+		for i, m := range messages {
+			entries = append(entries, transcriptEntry{index: i + 1, role: m.Persona, text: strings.TrimSpace(m.Message), notes: onMessage[m.ID]})
+		}
+
+		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+		customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string)
+		finalModelId := modelId
+		if customArn != "" {
+			finalModelId = customArn
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		modelIdString, err := resolveChatModelID(cmd.Context(), newModelCatalog(cfg), finalModelId, customArn != "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Fprintf(stderr, "\033[90mSummarizing %s...\033[0m\n", countOf(len(messages), "message"))
+		output, err := converseWithFallbacks(cmd.Context(), bedrockruntime.NewFromConfig(cfg), issueRequest(modelIdString, notes, entries))
+		if err != nil {
+			log.Fatalf("error from Bedrock, %v", err)
+		}
+		var reply string
+		if msg, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+			reply = messageText(msg.Value)
+		}
+		draft, err := parseIssueDraft(reply)
+		if err != nil {
+			log.Fatalf("unable to use the model's summary: %v", err)
+		}
+
+		if dryRun {
+			fmt.Printf("# %s\n\n%s\n", draft.title, draft.body)
+			return
+		}
+
+		issue, err := newGitHubClient(token).createIssue(cmd.Context(), repo, draft)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created issue #%d: %s\n", issue.Number, issue.HTMLURL)
+	},
+}
+
+func init() {
+	chatCmd.AddCommand(chatToIssueCmd)
+	chatToIssueCmd.Flags().String("github", "", "the repository to open the issue in, as owner/repo")
+	chatToIssueCmd.Flags().Bool("dry-run", false, "print the issue instead of creating it")
+}
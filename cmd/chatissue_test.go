@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestParseIssueDraft(t *testing.T) {
+	reply := "**Title:** \"Log rotation fills /var on the web hosts.\"\n\n## Problem\n\nDisk fills up.\n\n## Discussion\n\n...\n\n## Resolution\n\nFixed."
+	draft, err := parseIssueDraft(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.title != "Log rotation fills /var on the web hosts" {
+		t.Errorf("unexpected title %q", draft.title)
+	}
+	if !strings.HasPrefix(draft.body, "## Problem") || !strings.HasSuffix(draft.body, "Fixed.") {
+		t.Errorf("unexpected body %q", draft.body)
+	}
+
+	for _, bad := range []string{"## Problem\n\nDisk fills up.", "Title: Disk full", "Title:\n\n## Problem"} {
+		if _, err := parseIssueDraft(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestIssueRequest(t *testing.T) {
+	entries := []transcriptEntry{
+		{index: 1, role: "User", text: strings.Repeat("x", maxIssueMessage+100)},
+		{index: 2, role: "Assistant", text: "Try logrotate.", notes: []string{"this worked"}},
+	}
+	input := issueRequest("model", []string{"for the ops team"}, entries)
+	text := input.Messages[0].Content[0].(*types.ContentBlockMemberText).Value
+	for _, want := range []string{"<conversation>", "## Assistant\n\nTry logrotate.", "> **Note:** this worked", "> **Note:** for the ops team"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in %s", want, text)
+		}
+	}
+	if strings.Contains(text, strings.Repeat("x", maxIssueMessage+1)) {
+		t.Error("expected long messages to be cut down")
+	}
+}
+
+func TestCreateIssue(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/my-org/my-repo/issues" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("unexpected Authorization %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 42, "html_url": "https://github.com/my-org/my-repo/issues/42"}`))
+	}))
+	defer server.Close()
+
+	client := &githubClient{baseURL: server.URL, token: "secret", httpClient: server.Client()}
+	issue, err := client.createIssue(t.Context(), "my-org/my-repo", issueDraft{title: "Disk full", body: "## Problem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Number != 42 || issue.HTMLURL != "https://github.com/my-org/my-repo/issues/42" {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+	if got["title"] != "Disk full" || got["body"] != "## Problem" {
+		t.Errorf("unexpected request body %v", got)
+	}
+}
+
+func TestCreateIssueErrors(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusUnauthorized: "check github.token",
+		http.StatusNotFound:     "check the repository exists",
+		http.StatusGone:         "HTTP 410): Issues are disabled",
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"message": "Issues are disabled for this repo"}`))
+		}))
+		client := &githubClient{baseURL: server.URL, token: "secret", httpClient: server.Client()}
+		_, err := client.createIssue(t.Context(), "my-org/my-repo", issueDraft{title: "t", body: "b"})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("HTTP %d: expected an error containing %q, got %v", status, want, err)
+		}
+		server.Close()
+	}
+}
+
+func TestGitHubRepoPattern(t *testing.T) {
+	for repo, want := range map[string]bool{
+		"my-org/my-repo":    true,
+		"chat-cli/chat.cli": true,
+		"my-repo":           false,
+		"my-org/my-repo/x":  false,
+		"../issues":         false,
+	} {
+		if got := githubRepoPattern.MatchString(repo); got != want {
+			t.Errorf("githubRepoPattern.MatchString(%q) = %v, want %v", repo, got, want)
+		}
+	}
+}
+
+func TestRedactToken(t *testing.T) {
+	if got := redactToken("ghp_abcdefghijklmnop"); got != "********mnop" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := redactToken("short"); got != "*****" {
+		t.Errorf("unexpected %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/utils"
+	uuid "github.com/satori/go.uuid"
+)
+
+// chatWorkspace returns the directory chat names are scoped to: the git
+// repository containing cwd, or cwd itself outside one.
+func chatWorkspace(cwd string) string {
+	if root := utils.FindGitBoundary(cwd); root != "" {
+		return root
+	}
+	return filepath.Clean(cwd)
+}
+
+// chatIdForName returns the id of the chat called name in workspace. If
+// there's none, a new id is returned and, when save is set, the name is
+// recorded for it. created reports whether the chat is new.
+func chatIdForName(ctx context.Context, repo *repository.ChatNameRepository, workspace, name string, save bool) (chatId string, created bool, err error) {
+	chatId, err = repo.ChatId(ctx, workspace, name)
+	if err != nil || chatId != "" {
+		return chatId, false, err
+	}
+
+	chatId = uuid.NewV4().String()
+	if !save {
+		return chatId, true, nil
+	}
+
+	err = repo.Create(ctx, workspace, name, chatId)
+	if errors.Is(err, repository.ErrChatNameTaken) {
+		// another session claimed the name first; join that chat instead
+		chatId, err = repo.ChatId(ctx, workspace, name)
+		return chatId, false, err
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return chatId, true, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestChatWorkspace(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "modules", "vpc")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := chatWorkspace(sub); got != root {
+		t.Errorf("expected the repository root %q, got %q", root, got)
+	}
+
+	outside := t.TempDir()
+	if got := chatWorkspace(outside); got != outside {
+		t.Errorf("expected the directory itself outside a repository, got %q", got)
+	}
+}
+
+func TestChatIdForName(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = database.Close()
+	})
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewChatNameRepository(database)
+
+	// a dry run doesn't claim the name
+	dryId, created, err := chatIdForName(t.Context(), repo, "/src/infra", "tf-migration", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || dryId == "" {
+		t.Fatalf("expected a new chat id, got %q (created %v)", dryId, created)
+	}
+
+	first, created, err := chatIdForName(t.Context(), repo, "/src/infra", "tf-migration", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || first == dryId {
+		t.Fatalf("expected a new chat id, got %q (created %v)", first, created)
+	}
+
+	again, created, err := chatIdForName(t.Context(), repo, "/src/infra", "tf-migration", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || again != first {
+		t.Errorf("expected to resume %q, got %q (created %v)", first, again, created)
+	}
+
+	other, _, err := chatIdForName(t.Context(), repo, "/src/app", "tf-migration", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("expected the same name in another workspace to be a different chat")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// clipboardBackend is a platform tool that can write the image on the
+// clipboard out as a PNG.
+type clipboardBackend struct {
+	// command returns the command line that writes the image to path.
+	command func(path string) []string
+	// stdout is set when the tool writes the image to standard output
+	// rather than to path itself.
+	stdout bool
+}
+
+func (b clipboardBackend) name() string {
+	return b.command("")[0]
+}
+
+// clipboardBackends returns the tools to try, in order, for reading an
+// image from the clipboard on goos.
+func clipboardBackends(goos string, getenv func(string) string) []clipboardBackend {
+	switch goos {
+	case "darwin":
+		return []clipboardBackend{
+			{command: func(path string) []string { return []string{"pngpaste", path} }},
+			{command: func(path string) []string {
+				return []string{"osascript",
+					"-e", fmt.Sprintf("set f to open for access POSIX file %q with write permission", path),
+					"-e", "try",
+					"-e", "write (the clipboard as «class PNGf») to f",
+					"-e", "on error",
+					"-e", "close access f",
+					"-e", "error number 1",
+					"-e", "end try",
+					"-e", "close access f",
+				}
+			}},
+		}
+	case "windows":
+		return []clipboardBackend{
+			{command: func(path string) []string {
+				script := "Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; " +
+					"$img = [System.Windows.Forms.Clipboard]::GetImage(); if ($img -eq $null) { exit 1 }; " +
+					fmt.Sprintf("$img.Save('%s', [System.Drawing.Imaging.ImageFormat]::Png)", strings.ReplaceAll(path, "'", "''"))
+				return []string{"powershell", "-NoProfile", "-STA", "-Command", script}
+			}},
+		}
+	}
+
+	wayland := clipboardBackend{command: func(string) []string { return []string{"wl-paste", "--no-newline", "--type", "image/png"} }, stdout: true}
+	x11 := clipboardBackend{command: func(string) []string {
+		return []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"}
+	}, stdout: true}
+	if getenv("WAYLAND_DISPLAY") != "" {
+		return []clipboardBackend{wayland, x11}
+	}
+	return []clipboardBackend{x11, wayland}
+}
+
+// errNoClipboardImage is returned when the clipboard holds no image.
+var errNoClipboardImage = errors.New("the clipboard doesn't hold an image")
+
+// pasteClipboardImage saves the clipboard's image as a PNG in dir, using
+// the first of backends that's installed, and returns its path.
+func pasteClipboardImage(backends []clipboardBackend, dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("chat-cli-paste-%s.png", time.Now().Format("20060102-150405.000")))
+
+	var tried []string
+	for _, b := range backends {
+		args := b.command(path)
+		if _, err := exec.LookPath(args[0]); err != nil {
+			tried = append(tried, b.name())
+			continue
+		}
+
+		cmd := exec.Command(args[0], args[1:]...) // nolint:gosec // fixed tool and arguments
+		if b.stdout {
+			out, err := cmd.Output()
+			if err != nil || len(out) == 0 {
+				return "", errNoClipboardImage
+			}
+			if err := os.WriteFile(path, out, 0o600); err != nil {
+				return "", fmt.Errorf("unable to save pasted image: %w", err)
+			}
+		} else if err := cmd.Run(); err != nil {
+			_ = os.Remove(path)
+			return "", errNoClipboardImage
+		}
+
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			_ = os.Remove(path)
+			return "", errNoClipboardImage
+		}
+		return path, nil
+	}
+
+	return "", fmt.Errorf("no clipboard tool found; install one of: %s", strings.Join(tried, ", "))
+}
+
+// runPasteImageCommand attaches the clipboard's image to the next message,
+// as /image does for a file.
+func runPasteImageCommand(s *chatSession, args string) error {
+	if args != "" {
+		return fmt.Errorf("usage: /paste-image")
+	}
+
+	path, err := pasteClipboardImage(clipboardBackends(runtime.GOOS, os.Getenv), os.TempDir())
+	if err != nil {
+		return err
+	}
+	if err := runImageCommand(s, path); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "paste-image",
+		description: "Attach the image on the clipboard to your next message",
+		run:         runPasteImageCommand,
+	})
+}
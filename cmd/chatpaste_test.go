@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestClipboardBackends(t *testing.T) {
+	names := func(backends []clipboardBackend) string {
+		var n []string
+		for _, b := range backends {
+			n = append(n, b.name())
+		}
+		return strings.Join(n, ",")
+	}
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	cases := []struct {
+		goos string
+		env  map[string]string
+		want string
+	}{
+		{"darwin", nil, "pngpaste,osascript"},
+		{"windows", nil, "powershell"},
+		{"linux", nil, "xclip,wl-paste"},
+		{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, "wl-paste,xclip"},
+		{"freebsd", nil, "xclip,wl-paste"},
+	}
+	for _, c := range cases {
+		if got := names(clipboardBackends(c.goos, env(c.env))); got != c.want {
+			t.Errorf("%s %v: got %s, want %s", c.goos, c.env, got, c.want)
+		}
+	}
+}
+
+func TestPasteClipboardImage(t *testing.T) {
+	for _, tool := range []string{"cp", "cat", "false"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	src := writeTestImage(t, t.TempDir(), "clip.png", 10)
+	missing := clipboardBackend{command: func(string) []string { return []string{"no-such-clipboard-tool"} }}
+
+	t.Run("skips tools that aren't installed", func(t *testing.T) {
+		toFile := clipboardBackend{command: func(path string) []string { return []string{"cp", src, path} }}
+		path, err := pasteClipboardImage([]clipboardBackend{missing, toFile}, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(path, ".png") {
+			t.Errorf("expected a png, got %s", path)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != 10 {
+			t.Errorf("expected the image to be saved, got %v %v", info, err)
+		}
+	})
+
+	t.Run("saves a tool's standard output", func(t *testing.T) {
+		toStdout := clipboardBackend{command: func(string) []string { return []string{"cat", src} }, stdout: true}
+		path, err := pasteClipboardImage([]clipboardBackend{toStdout}, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != 10 {
+			t.Errorf("expected the image to be saved, got %v %v", info, err)
+		}
+	})
+
+	t.Run("reports an empty clipboard", func(t *testing.T) {
+		failing := clipboardBackend{command: func(string) []string { return []string{"false"} }, stdout: true}
+		if _, err := pasteClipboardImage([]clipboardBackend{failing}, t.TempDir()); !errors.Is(err, errNoClipboardImage) {
+			t.Errorf("expected errNoClipboardImage, got %v", err)
+		}
+	})
+
+	t.Run("names the tools to install when none is found", func(t *testing.T) {
+		_, err := pasteClipboardImage([]clipboardBackend{missing}, t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "no-such-clipboard-tool") {
+			t.Errorf("expected the missing tool to be named, got %v", err)
+		}
+	})
+}
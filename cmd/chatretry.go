@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// errRetryTurn is returned by /retry to have the chat loop ask the model
+// for the last reply again instead of reading a new message.
+var errRetryTurn = errors.New("retry turn")
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "retry",
+		usage:       "[temperature]",
+		description: "Discard the last reply and ask again, optionally at another temperature",
+		run:         runRetryCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "regenerate",
+		usage:       "[temperature]",
+		description: "Same as /retry",
+		run:         runRetryCommand,
+	})
+}
+
+func runRetryCommand(s *chatSession, args string) error {
+	var temperature *float32
+	if args != "" {
+		value, err := strconv.ParseFloat(args, 32)
+		if err != nil || value < 0 || value > 1 {
+			return fmt.Errorf("usage: /retry [temperature], with a temperature from 0 to 1")
+		}
+		t := float32(value)
+		temperature = &t
+	}
+
+	n := lastUserTurn(s.input.Messages)
+	if n < 0 || n == len(s.input.Messages)-1 {
+		return fmt.Errorf("there's no reply to retry yet")
+	}
+
+	// the reply and any tool round trips that led to it are dropped; the
+	// question stays and is sent again
+	s.input.Messages = s.input.Messages[:n+1]
+	if s.lastReplyID != 0 && s.chatRepo != nil {
+		if err := s.chatRepo.Supersede(s.context(), s.lastReplyID); err != nil {
+			fmt.Fprintf(s.out, "warning: unable to mark the last reply superseded: %v\n", err)
+		}
+	}
+	s.lastReplyID = 0
+	s.retryTemperature = temperature
+
+	return errRetryTurn
+}
+
+// lastUserTurn returns the index of the last message the user typed - not
+// a tool result sent on their behalf - or -1 if there isn't one.
+func lastUserTurn(messages []types.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != types.ConversationRoleUser {
+			continue
+		}
+		for _, block := range messages[i].Content {
+			if _, ok := block.(*types.ContentBlockMemberToolResult); !ok {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// withTemperature returns a copy of conf with temperature set, for a
+// single retried turn.
+func withTemperature(conf *types.InferenceConfiguration, temperature float32) *types.InferenceConfiguration {
+	var retry types.InferenceConfiguration
+	if conf != nil {
+		retry = *conf
+	}
+	retry.Temperature = &temperature
+	return &retry
+}
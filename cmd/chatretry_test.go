@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestRetryCommand(t *testing.T) {
+	t.Run("drops the reply and its tool round trips", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.input.Messages = append(s.input.Messages,
+			textMessage(types.ConversationRoleUser, "what's in main.go?"),
+			types.Message{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{&types.ContentBlockMemberToolUse{}}},
+			types.Message{Role: types.ConversationRoleUser, Content: []types.ContentBlock{&types.ContentBlockMemberToolResult{}}},
+			textMessage(types.ConversationRoleAssistant, "a main function"),
+		)
+
+		handled, err := dispatchSlashCommand(s, "/retry 0.9")
+		if !handled || !errors.Is(err, errRetryTurn) {
+			t.Fatalf("expected a retry, got handled=%v err=%v", handled, err)
+		}
+		if len(s.input.Messages) != 3 || messageText(s.input.Messages[2]) != "what's in main.go?" {
+			t.Errorf("expected the conversation to end at the question, got %d messages", len(s.input.Messages))
+		}
+		if s.retryTemperature == nil || *s.retryTemperature != 0.9 {
+			t.Errorf("expected temperature 0.9 for the retry, got %v", s.retryTemperature)
+		}
+	})
+
+	t.Run("needs a reply to retry", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.input.Messages = s.input.Messages[:1]
+		if _, err := dispatchSlashCommand(s, "/regenerate"); err == nil || errors.Is(err, errRetryTurn) {
+			t.Errorf("expected an error with no reply, got %v", err)
+		}
+	})
+
+	t.Run("rejects a temperature out of range", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		for _, arg := range []string{"hot", "1.5", "-1"} {
+			if _, err := dispatchSlashCommand(s, "/retry "+arg); err == nil || errors.Is(err, errRetryTurn) {
+				t.Errorf("/retry %s: expected a usage error, got %v", arg, err)
+			}
+		}
+		if len(s.input.Messages) != 2 {
+			t.Errorf("expected the conversation to be left alone, got %d messages", len(s.input.Messages))
+		}
+	})
+}
+
+func TestRetryCommandSupersedesSavedReply(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := newTestChatSession()
+	s.chatRepo = repository.NewChatRepository(database)
+	s.record("User", "hello")
+	s.recordReply("hi there", types.StopReasonEndTurn, 0)
+
+	if _, err := dispatchSlashCommand(s, "/retry"); !errors.Is(err, errRetryTurn) {
+		t.Fatalf("expected a retry, got %v", err)
+	}
+	if s.lastReplyID != 0 || s.retryTemperature != nil {
+		t.Errorf("expected the retry state to be reset, got id %d temperature %v", s.lastReplyID, s.retryTemperature)
+	}
+
+	messages, err := s.chatRepo.GetMessages(t.Context(), s.chatId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Persona != "User" {
+		t.Errorf("expected only the question in history, got %+v", messages)
+	}
+}
+
+func TestWithTemperature(t *testing.T) {
+	conf := &types.InferenceConfiguration{MaxTokens: aws.Int32(100), Temperature: aws.Float32(0.2)}
+	retry := withTemperature(conf, 0.8)
+	if *retry.Temperature != 0.8 || *retry.MaxTokens != 100 {
+		t.Errorf("unexpected retry config %+v", retry)
+	}
+	if *conf.Temperature != 0.2 {
+		t.Errorf("expected the original config to be unchanged, got %v", *conf.Temperature)
+	}
+	if got := withTemperature(nil, 0.5); *got.Temperature != 0.5 {
+		t.Errorf("expected a config with just the temperature, got %+v", got)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// scratchExt is the extension scratch snippets are saved with, so they
+// open as Markdown in an editor.
+const scratchExt = ".md"
+
+// scratchName is what a snippet can be called: it becomes a file name.
+var scratchName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// scratchSnippet is a saved snippet loaded for the next message.
+type scratchSnippet struct {
+	name string
+	text string
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "scratch",
+		usage:       "[list|save|load|show|delete] <name> [text]",
+		description: "Keep named snippets between sessions and load them into the conversation",
+		run:         runScratchCommand,
+	})
+}
+
+func runScratchCommand(s *chatSession, args string) error {
+	if s.scratchDir == "" {
+		return fmt.Errorf("scratch snippets aren't available in this session")
+	}
+
+	action, rest, _ := strings.Cut(args, " ")
+	name, text, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	text = strings.TrimSpace(text)
+
+	switch action {
+	case "", "list":
+		return listScratch(s)
+	case "save", "load", "show", "delete":
+	default:
+		return fmt.Errorf("usage: /scratch [list|save|load|show|delete] <name> [text]")
+	}
+
+	if !scratchName.MatchString(name) {
+		return fmt.Errorf("usage: /scratch %s <name>, where the name uses letters, digits, '.', '-' and '_'", action)
+	}
+	path := filepath.Join(s.scratchDir, name+scratchExt)
+
+	switch action {
+	case "save":
+		// without text, the last reply is saved
+		if text == "" {
+			text = lastReplyText(s.input.Messages)
+			if text == "" {
+				return fmt.Errorf("there's no reply to save yet; use /scratch save %s <text>", name)
+			}
+		}
+		if err := os.MkdirAll(s.scratchDir, 0o700); err != nil {
+			return fmt.Errorf("unable to create %s: %w", s.scratchDir, err)
+		}
+		if err := os.WriteFile(path, []byte(text+"\n"), 0o600); err != nil {
+			return fmt.Errorf("unable to save %s: %w", name, err)
+		}
+		fmt.Fprintf(s.out, "Saved %s (%s).\n", name, formatByteSize(len(text)))
+
+	case "load":
+		text, err := readScratch(path, name)
+		if err != nil {
+			return err
+		}
+		s.pendingScratch = append(s.pendingScratch, scratchSnippet{name: name, text: text})
+		fmt.Fprintf(s.out, "Loaded %s; it will be sent with your next message.\n", name)
+
+	case "show":
+		text, err := readScratch(path, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, text)
+
+	case "delete":
+		if err := os.Remove(path); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no scratch snippet named %s", name)
+		} else if err != nil {
+			return fmt.Errorf("unable to delete %s: %w", name, err)
+		}
+		fmt.Fprintf(s.out, "Deleted %s.\n", name)
+	}
+	return nil
+}
+
+func readScratch(path, name string) (string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - the name is checked against scratchName
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no scratch snippet named %s; /scratch lists them", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+func listScratch(s *chatSession) error {
+	entries, err := os.ReadDir(s.scratchDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unable to list scratch snippets: %w", err)
+	}
+
+	var names []string
+	sizes := map[string]int{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), scratchExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			sizes[name] = int(info.Size())
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		fmt.Fprintln(s.out, "No scratch snippets yet. Use /scratch save <name> to keep the last reply.")
+		return nil
+	}
+	fmt.Fprintf(s.out, "Scratch snippets in %s:\n", s.scratchDir)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "  %s (%s)\n", name, formatByteSize(sizes[name]))
+	}
+	return nil
+}
+
+// lastReplyText returns the text of the latest assistant message that has
+// any.
+func lastReplyText(messages []types.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != types.ConversationRoleAssistant {
+			continue
+		}
+		if text := strings.TrimSpace(messageText(messages[i])); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// takeScratch returns the snippets loaded since the last message, as
+// content blocks to go ahead of it, and clears them.
+func (s *chatSession) takeScratch() []types.ContentBlock {
+	var blocks []types.ContentBlock
+	for _, snippet := range s.pendingScratch {
+		blocks = append(blocks, &types.ContentBlockMemberText{
+			Value: fmt.Sprintf("<scratch name=%q>\nThe user loaded this saved snippet for context.\n%s\n</scratch>", snippet.name, snippet.text),
+		})
+	}
+	s.pendingScratch = nil
+	return blocks
+}
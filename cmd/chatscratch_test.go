@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestScratchCommand(t *testing.T) {
+	s, out := newTestChatSession()
+	s.scratchDir = filepath.Join(t.TempDir(), "scratch")
+
+	run := func(line string) error {
+		t.Helper()
+		out.Reset()
+		_, err := dispatchSlashCommand(s, line)
+		return err
+	}
+
+	if err := run("/scratch"); err != nil || !strings.Contains(out.String(), "No scratch snippets yet") {
+		t.Errorf("expected an empty list, got %q (%v)", out.String(), err)
+	}
+
+	// without text, the last reply is saved
+	if err := run("/scratch save greeting"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(s.scratchDir, "greeting.md")); err != nil || string(data) != "hi there\n" {
+		t.Errorf("unexpected saved snippet %q (%v)", data, err)
+	}
+
+	if err := run("/scratch save plan step one, then  step two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("/scratch list"); err != nil || !strings.Contains(out.String(), "greeting (") || !strings.Contains(out.String(), "plan (") {
+		t.Errorf("expected both snippets listed, got %q (%v)", out.String(), err)
+	}
+	if err := run("/scratch show plan"); err != nil || out.String() != "step one, then  step two\n" {
+		t.Errorf("unexpected snippet %q (%v)", out.String(), err)
+	}
+
+	if err := run("/scratch load plan"); err != nil {
+		t.Fatal(err)
+	}
+	blocks := s.takeScratch()
+	if len(blocks) != 1 {
+		t.Fatalf("expected one block for the next message, got %d", len(blocks))
+	}
+	text := blocks[0].(*types.ContentBlockMemberText).Value
+	if !strings.HasPrefix(text, `<scratch name="plan">`) || !strings.Contains(text, "step one, then  step two") {
+		t.Errorf("unexpected scratch block %q", text)
+	}
+	if len(s.takeScratch()) != 0 {
+		t.Error("expected loaded snippets to go with one message only")
+	}
+
+	if err := run("/scratch delete plan"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("/scratch load plan"); err == nil || !strings.Contains(err.Error(), "no scratch snippet named plan") {
+		t.Errorf("expected a missing snippet error, got %v", err)
+	}
+}
+
+func TestScratchCommandErrors(t *testing.T) {
+	s, _ := newTestChatSession()
+	s.scratchDir = t.TempDir()
+
+	for _, line := range []string{"/scratch save ../escape", "/scratch load", "/scratch rename a b", "/scratch show .hidden"} {
+		if _, err := dispatchSlashCommand(s, line); err == nil {
+			t.Errorf("%s: expected an error", line)
+		}
+	}
+
+	s.input.Messages = nil
+	if _, err := dispatchSlashCommand(s, "/scratch save empty"); err == nil || !strings.Contains(err.Error(), "no reply to save") {
+		t.Errorf("expected an error with no reply, got %v", err)
+	}
+}
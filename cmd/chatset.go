@@ -0,0 +1,204 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+const setUsage = "usage: /set [temperature|top-p|max-tokens] [value|default]"
+
+// Names of the inference parameters /set changes.
+const (
+	settingTemperature = "temperature"
+	settingTopP        = "top-p"
+	settingMaxTokens   = "max-tokens"
+)
+
+// settingNames lists the /set names in the order /set shows them.
+var settingNames = []string{settingTemperature, settingTopP, settingMaxTokens}
+
+// settingName returns the /set name for name, accepting the topP spelling
+// of the flag.
+func settingName(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case settingTemperature:
+		return settingTemperature, true
+	case settingTopP, "topp":
+		return settingTopP, true
+	case settingMaxTokens:
+		return settingMaxTokens, true
+	}
+	return "", false
+}
+
+// applySetting validates value for the named setting and sets it in conf,
+// returning the value as it's saved.
+func applySetting(conf *types.InferenceConfiguration, name, value string) (string, error) {
+	switch name {
+	case settingTemperature, settingTopP:
+		parsed, err := strconv.ParseFloat(value, 32)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return "", fmt.Errorf("%s must be a number from 0 to 1", name)
+		}
+		v := float32(parsed)
+		if name == settingTemperature {
+			conf.Temperature = &v
+		} else {
+			conf.TopP = &v
+		}
+		return strconv.FormatFloat(parsed, 'g', -1, 32), nil
+	case settingMaxTokens:
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || parsed < 1 {
+			return "", fmt.Errorf("%s must be a whole number above 0", name)
+		}
+		v := int32(parsed)
+		conf.MaxTokens = &v
+		return strconv.FormatInt(parsed, 10), nil
+	}
+	return "", fmt.Errorf("unknown setting %q", name)
+}
+
+// resetSetting puts the named setting in conf back to its value in
+// defaults.
+func resetSetting(conf *types.InferenceConfiguration, defaults types.InferenceConfiguration, name string) {
+	switch name {
+	case settingTemperature:
+		conf.Temperature = defaults.Temperature
+	case settingTopP:
+		conf.TopP = defaults.TopP
+	case settingMaxTokens:
+		conf.MaxTokens = defaults.MaxTokens
+	}
+}
+
+// settingValue describes the named setting's current value in conf.
+func settingValue(conf *types.InferenceConfiguration, name string) string {
+	switch {
+	case name == settingTemperature && conf.Temperature != nil:
+		return strconv.FormatFloat(float64(*conf.Temperature), 'g', -1, 32)
+	case name == settingTopP && conf.TopP != nil:
+		return strconv.FormatFloat(float64(*conf.TopP), 'g', -1, 32)
+	case name == settingMaxTokens && conf.MaxTokens != nil:
+		return strconv.FormatInt(int64(*conf.MaxTokens), 10)
+	}
+	return "model default"
+}
+
+// restoreSettings applies the settings saved with a resumed chat to conf,
+// returning a warning for each one that can no longer be used.
+func restoreSettings(conf *types.InferenceConfiguration, saved map[string]string) (map[string]string, []string) {
+	restored := map[string]string{}
+	var warnings []string
+	for key, value := range saved {
+		name, ok := settingName(key)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("ignoring unknown chat setting %q", key))
+			continue
+		}
+		if _, err := applySetting(conf, name, value); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignoring saved %s %q: %v", name, value, err))
+			continue
+		}
+		restored[name] = value
+	}
+	sort.Strings(warnings)
+	return restored, warnings
+}
+
+// formatSettings lists settings as name=value, in settingNames order.
+func formatSettings(settings map[string]string) string {
+	var parts []string
+	for _, name := range settingNames {
+		if value, ok := settings[name]; ok {
+			parts = append(parts, name+"="+value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runSetCommand(s *chatSession, args string) error {
+	if s.input.InferenceConfig == nil {
+		s.input.InferenceConfig = &types.InferenceConfiguration{}
+	}
+	conf := s.input.InferenceConfig
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		for _, name := range settingNames {
+			line := fmt.Sprintf("%-12s %s", name, settingValue(conf, name))
+			if _, ok := s.settings[name]; ok {
+				line += " (set for this chat)"
+			}
+			fmt.Fprintln(s.out, line)
+		}
+		return nil
+	}
+	if len(fields) != 2 {
+		return errors.New(setUsage)
+	}
+
+	name, ok := settingName(fields[0])
+	if !ok {
+		return fmt.Errorf("unknown setting %q; %s", fields[0], setUsage)
+	}
+
+	if fields[1] == "default" {
+		resetSetting(conf, s.inferenceDefaults, name)
+		delete(s.settings, name)
+		if s.chatSettings != nil {
+			if err := s.chatSettings.Unset(s.context(), s.chatId, name); err != nil {
+				fmt.Fprintf(s.out, "warning: %v\n", err)
+			}
+		}
+		fmt.Fprintf(s.out, "%s is back to %s.\n", name, settingValue(conf, name))
+		return nil
+	}
+
+	value, err := applySetting(conf, name, fields[1])
+	if err != nil {
+		return err
+	}
+	if s.settings == nil {
+		s.settings = map[string]string{}
+	}
+	s.settings[name] = value
+	if s.chatSettings != nil {
+		if err := s.chatSettings.Set(s.context(), s.chatId, name, value); err != nil {
+			fmt.Fprintf(s.out, "warning: %v\n", err)
+		}
+	}
+	fmt.Fprintf(s.out, "%s set to %s for the rest of this chat.\n", name, value)
+	return nil
+}
+
+// saveSettings saves the session's /set values with its current chat, for
+// a chat started afresh with /clear.
+func (s *chatSession) saveSettings() {
+	if s.chatSettings == nil {
+		return
+	}
+	for name, value := range s.settings {
+		if err := s.chatSettings.Set(s.context(), s.chatId, name, value); err != nil {
+			fmt.Fprintf(s.out, "warning: %v\n", err)
+			return
+		}
+	}
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "set",
+		usage:       "[name value|default]",
+		description: "Change temperature, top-p, or max-tokens for the rest of the chat; /set alone shows them",
+		run:         runSetCommand,
+	})
+}
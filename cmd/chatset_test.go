@@ -0,0 +1,108 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestSetCommand(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, out := newTestChatSession()
+	defaults := buildInferenceConfiguration(4096, nil, aws.Float32(0.9))
+	conf := defaults
+	s.input.InferenceConfig = &conf
+	s.inferenceDefaults = defaults
+	s.chatSettings = repository.NewChatSettingsRepository(database)
+
+	for _, line := range []string{"/set temperature 0.2", "/set max-tokens 2000", "/set topP 0.5"} {
+		if _, err := dispatchSlashCommand(s, line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	if aws.ToFloat32(conf.Temperature) != 0.2 || aws.ToInt32(conf.MaxTokens) != 2000 || aws.ToFloat32(conf.TopP) != 0.5 {
+		t.Errorf("inference config = %+v", conf)
+	}
+
+	if _, err := dispatchSlashCommand(s, "/set top-p default"); err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToFloat32(conf.TopP) != 0.9 {
+		t.Errorf("top-p = %v; want the session's starting 0.9", aws.ToFloat32(conf.TopP))
+	}
+
+	saved, err := s.chatSettings.Get(t.Context(), s.chatId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved["temperature"] != "0.2" || saved["max-tokens"] != "2000" {
+		t.Errorf("saved settings = %v", saved)
+	}
+
+	out.Reset()
+	if _, err := dispatchSlashCommand(s, "/set"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"temperature  0.2 (set for this chat)", "top-p        0.9\n", "max-tokens   2000 (set for this chat)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("/set output is missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// a resumed chat gets them back
+	restored := defaults
+	settings, warnings := restoreSettings(&restored, saved)
+	if len(warnings) != 0 || aws.ToFloat32(restored.Temperature) != 0.2 || aws.ToInt32(restored.MaxTokens) != 2000 {
+		t.Errorf("restored = %+v, %v", restored, warnings)
+	}
+	if got := formatSettings(settings); got != "temperature=0.2, max-tokens=2000" {
+		t.Errorf("formatSettings = %q", got)
+	}
+
+	// and /clear carries them over to the new chat
+	if _, err := dispatchSlashCommand(s, "/clear"); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := s.chatSettings.Get(t.Context(), s.chatId); err != nil || len(saved) != 2 {
+		t.Errorf("settings of the cleared chat = %v, %v", saved, err)
+	}
+}
+
+func TestSetCommandErrors(t *testing.T) {
+	s, _ := newTestChatSession()
+	for _, line := range []string{"/set temperature", "/set temperature 1.5", "/set max-tokens 0", "/set max-tokens lots", "/set top-k 5"} {
+		if _, err := dispatchSlashCommand(s, line); err == nil {
+			t.Errorf("%s: expected an error", line)
+		}
+	}
+	if s.input.InferenceConfig.Temperature != nil || s.input.InferenceConfig.MaxTokens != nil {
+		t.Errorf("invalid values changed the config: %+v", s.input.InferenceConfig)
+	}
+}
+
+func TestRestoreSettingsWarns(t *testing.T) {
+	var conf types.InferenceConfiguration
+	settings, warnings := restoreSettings(&conf, map[string]string{"temperature": "hot", "seed": "1", "max-tokens": "512"})
+	if len(warnings) != 2 || len(settings) != 1 || aws.ToInt32(conf.MaxTokens) != 512 {
+		t.Errorf("settings = %v, warnings = %v", settings, warnings)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "sql",
+		usage:       "/sql [query]",
+		description: "Run a read-only SELECT on your chat history, or list its tables",
+		run:         runSQLCommand,
+	})
+}
+
+func runSQLCommand(s *chatSession, args string) error {
+	if s.sqlQuery == nil {
+		return errors.New("/sql needs chat history stored in SQLite (db_driver: sqlite)")
+	}
+
+	query := strings.TrimSpace(args)
+	if query == "" {
+		schema, err := s.sqlQuery.Schema(s.context())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, schema)
+		return nil
+	}
+
+	result, err := s.sqlQuery.Query(s.context(), query, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, result)
+	return nil
+}
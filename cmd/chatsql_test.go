@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func TestSQLCommand(t *testing.T) {
+	s, out := newTestChatSession()
+	if _, err := dispatchSlashCommand(s, "/sql SELECT 1"); err == nil || !strings.Contains(err.Error(), "needs chat history stored in SQLite") {
+		t.Fatalf("expected an error without a SQLite history, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.db")
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.Exec(`CREATE TABLE chats (chat_id TEXT, message TEXT); INSERT INTO chats VALUES ('a', 'hello');`); err != nil {
+		t.Fatal(err)
+	}
+	_ = rw.Close()
+	ro, err := sqlite.OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ro.Close() })
+	s.sqlQuery = tools.NewSQLQueryTool(ro)
+
+	if _, err := dispatchSlashCommand(s, "/sql"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "chats (chat_id, message)") {
+		t.Errorf("expected the tables to be listed, got %q", out.String())
+	}
+
+	out.Reset()
+	if _, err := dispatchSlashCommand(s, "/sql SELECT message FROM chats"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "message\nhello\n(1 row)\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if _, err := dispatchSlashCommand(s, "/sql DELETE FROM chats"); err == nil || !strings.Contains(err.Error(), "only SELECT") {
+		t.Errorf("expected a write to be refused, got %v", err)
+	}
+}
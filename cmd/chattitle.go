@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// maxTitleLength caps generated titles so they fit a chat list row.
+const maxTitleLength = 60
+
+// titleTimeout bounds the background request that names a new chat.
+const titleTimeout = 30 * time.Second
+
+const titleInstruction = "Write a title of at most six words for the conversation below. " +
+	"Reply with only the title: no quotes, no trailing punctuation."
+
+// chatTitle is a generated title waiting to be saved by the chat loop.
+type chatTitle struct {
+	chatId string
+	title  string
+}
+
+// titleRequest asks modelID to name a conversation from its first
+// exchange. Long messages are cut down, since the opening is enough.
+func titleRequest(modelID, userText, assistantText string) *bedrockruntime.ConverseInput {
+	conversation := fmt.Sprintf("User: %s\n\nAssistant: %s", truncateRunes(userText, 2000), truncateRunes(assistantText, 2000))
+
+	return &bedrockruntime.ConverseInput{
+		ModelId: aws.String(modelID),
+		Messages: []types.Message{
+			userTextMessage(titleInstruction + "\n\n<conversation>\n" + conversation + "\n</conversation>"),
+		},
+		InferenceConfig: &types.InferenceConfiguration{MaxTokens: aws.Int32(32)},
+	}
+}
+
+// cleanTitle turns a model's reply into a title: the first non-empty line,
+// without a "Title:" label, wrapping quotes, or a trailing full stop.
+func cleanTitle(reply string) string {
+	var title string
+	for _, line := range strings.Split(reply, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			title = line
+			break
+		}
+	}
+
+	title = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(title, "Title:"), "title:"))
+	title = strings.Trim(title, "\"'`*# ")
+	title = strings.TrimSuffix(title, ".")
+	return truncateRunes(title, maxTitleLength)
+}
+
+// truncateRunes shortens s to at most n runes without splitting a
+// multi-byte character.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n]))
+}
+
+// startTitle names the chat from its first exchange in the background.
+// The result is handed to the chat loop, which saves it between turns so
+// the database is only written from one goroutine.
+func (s *chatSession) startTitle(userText, assistantText string) {
+	if s.titled || s.converse == nil || strings.TrimSpace(assistantText) == "" {
+		return
+	}
+	s.titled = true
+
+	chatId := s.chatId
+	input := titleRequest(aws.ToString(s.input.ModelId), userText, assistantText)
+	go func() {
+		ctx, cancel := context.WithTimeout(s.context(), titleTimeout)
+		defer cancel()
+
+		output, err := s.converse(ctx, input)
+		if err != nil {
+			return
+		}
+		msg, ok := output.Output.(*types.ConverseOutputMemberMessage)
+		if !ok {
+			return
+		}
+		title := cleanTitle(messageText(msg.Value))
+		if title == "" {
+			return
+		}
+
+		select {
+		case s.titles <- chatTitle{chatId: chatId, title: title}:
+		default:
+		}
+	}()
+}
+
+// saveTitles stores any titles generated since the last call.
+func (s *chatSession) saveTitles() {
+	for {
+		select {
+		case t := <-s.titles:
+			if s.chatRepo == nil {
+				continue
+			}
+			if err := s.chatRepo.SetTitle(s.context(), t.chatId, t.title); err != nil {
+				continue
+			}
+			if t.chatId == s.chatId {
+				fmt.Fprintf(s.out, "\033[90mChat titled %q (rename with: chat-cli chat rename %s \"new title\")\033[0m\n", t.title, t.chatId)
+			}
+		default:
+			return
+		}
+	}
+}
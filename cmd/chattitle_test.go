@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestCleanTitle(t *testing.T) {
+	cases := map[string]string{
+		"Rotating Logs With logrotate":         "Rotating Logs With logrotate",
+		"\n\"Rotating logs.\"\n":               "Rotating logs",
+		"Title: Debugging a flaky test\nExtra": "Debugging a flaky test",
+		"**Kubernetes pod restarts**":          "Kubernetes pod restarts",
+		strings.Repeat("word ", 30):            strings.TrimSpace(strings.Repeat("word ", 12)),
+		"":                                     "",
+	}
+	for reply, want := range cases {
+		if got := cleanTitle(reply); got != want {
+			t.Errorf("cleanTitle(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestTitleRequest(t *testing.T) {
+	input := titleRequest("amazon.nova-lite-v1:0", "How do I rotate logs?", "Use logrotate.")
+	if aws.ToString(input.ModelId) != "amazon.nova-lite-v1:0" {
+		t.Errorf("unexpected model %q", aws.ToString(input.ModelId))
+	}
+	if len(input.Messages) != 1 || !strings.Contains(messageText(input.Messages[0]), "User: How do I rotate logs?\n\nAssistant: Use logrotate.") {
+		t.Errorf("expected the exchange in the request, got %+v", input.Messages)
+	}
+}
+
+func TestStartTitle(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	chatRepo := repository.NewChatRepository(database)
+
+	var requests int
+	var out bytes.Buffer
+	s := &chatSession{
+		input:    &bedrockruntime.ConverseStreamInput{ModelId: aws.String("amazon.nova-pro-v1:0")},
+		chatId:   "chat-1",
+		chatRepo: chatRepo,
+		titles:   make(chan chatTitle, 1),
+		converse: func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+			requests++
+			return &bedrockruntime.ConverseOutput{
+				Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+					Role:    types.ConversationRoleAssistant,
+					Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "\"Log rotation.\""}},
+				}},
+			}, nil
+		},
+		out: &out,
+	}
+	s.record("User", "How do I rotate logs?")
+	s.record("Assistant", "Use logrotate.")
+
+	s.startTitle("How do I rotate logs?", "Use logrotate.")
+	s.startTitle("Second question", "Second answer")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.titles) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.saveTitles()
+
+	if requests != 1 {
+		t.Errorf("expected one title request, got %d", requests)
+	}
+	if title, _ := chatRepo.GetTitle(t.Context(), "chat-1"); title != "Log rotation" {
+		t.Errorf("expected the title to be saved, got %q", title)
+	}
+	if !strings.Contains(out.String(), `Chat titled "Log rotation"`) {
+		t.Errorf("expected the user to be told, got %q", out.String())
+	}
+}
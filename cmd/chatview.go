@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+// chatMessageHeader is the line above each message in the chat view: who
+// sent it, and when.
+func chatMessageHeader(role string, at time.Time) string {
+	name := "You"
+	if role == "Assistant" {
+		name = "Assistant"
+	}
+	if at.IsZero() {
+		return fmt.Sprintf("\033[1m%s\033[0m\n", name)
+	}
+	return fmt.Sprintf("\033[1m%s\033[0m \033[90m%s\033[0m\n", name, at.Local().Format("15:04"))
+}
+
+// writeViewHistory shows a resumed chat's messages in the chat view, each
+// under a header with the time it was saved.
+func writeViewHistory(w io.Writer, chats []repository.Chat) {
+	for _, chat := range chats {
+		fmt.Fprint(w, chatMessageHeader(chat.Persona, parseStoredTime(chat.Created)))
+		switch {
+		case chat.Persona == "User":
+			fmt.Fprintf(w, "%s\n\n", chat.Message)
+		case chat.StopReason == string(stopReasonInterrupted):
+			fmt.Fprintf(w, "%s \033[90m(cancelled)\033[0m\n\n", chat.Message)
+		case chat.Resumes > 0:
+			fmt.Fprintf(w, "%s \033[90m(resumed after the stream broke off)\033[0m\n\n", chat.Message)
+		default:
+			fmt.Fprintf(w, "%s\n\n", chat.Message)
+		}
+	}
+}
+
+// chatStatusLine is the chat view's status bar: the model, the chat, and
+// the tokens and cost of the session so far.
+func chatStatusLine(s *chatSession) string {
+	parts := []string{aws.ToString(s.input.ModelId)}
+	if s.chatId != "" {
+		parts = append(parts, "chat "+s.chatId)
+	}
+	if s.usage != nil {
+		parts = append(parts, formatTokenCounts(s.usage.session), s.usage.costText())
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,54 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestChatMessageHeader(t *testing.T) {
+	at := time.Date(2025, 3, 1, 14, 5, 0, 0, time.Local)
+	if got := chatMessageHeader("User", at); got != "\033[1mYou\033[0m \033[90m14:05\033[0m\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := chatMessageHeader("Assistant", time.Time{}); got != "\033[1mAssistant\033[0m\n" {
+		t.Errorf("expected no time when it's unknown, got %q", got)
+	}
+}
+
+func TestWriteViewHistory(t *testing.T) {
+	var buf bytes.Buffer
+	writeViewHistory(&buf, []repository.Chat{
+		{Persona: "User", Message: "Hello", Created: "2025-03-01 14:05:00"},
+		{Persona: "Assistant", Message: "Hi", StopReason: string(stopReasonInterrupted)},
+	})
+	got := buf.String()
+	for _, want := range []string{"You\033[0m \033[90m", "Hello\n\n", "Assistant\033[0m\nHi \033[90m(cancelled)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in\n%q", want, got)
+		}
+	}
+}
+
+func TestChatStatusLine(t *testing.T) {
+	usage := newUsageTracker()
+	usage.record("anthropic.claude-3-haiku-20240307-v1:0", tokenUsage{input: 1200, output: 300})
+	s := &chatSession{
+		input:  &bedrockruntime.ConverseStreamInput{ModelId: aws.String("anthropic.claude-3-haiku-20240307-v1:0")},
+		chatId: "abc",
+		usage:  usage,
+	}
+	got := chatStatusLine(s)
+	if !strings.HasPrefix(got, "anthropic.claude-3-haiku-20240307-v1:0 · chat abc · 1200 in / 300 out · ") {
+		t.Errorf("got %q", got)
+	}
+}
@@ -4,12 +4,19 @@ Copyright © 2024 Micah Walter
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 
@@ -26,18 +33,67 @@ var configCmd = &cobra.Command{
 // supportedConfigKeys is the single source of truth for which keys the
 // config set/unset commands accept.
 var supportedConfigKeys = map[string]bool{
-	"custom-arn":    true,
-	"model-id":      true,
-	"system-prompt": true,
-	"context-files": true,
+	"aws-profile":           true,
+	"offline":               true,
+	"custom-arn":            true,
+	"model-id":              true,
+	"system-prompt":         true,
+	"context-files":         true,
+	"verify-command":        true,
+	"worker-model-id":       true,
+	"guardrail-id":          true,
+	"guardrail-version":     true,
+	"pager":                 true,
+	"injection-check":       true,
+	"reasoning":             true,
+	"agent-prompt":          true,
+	"agent-prompt-append":   true,
+	"archive-after":         true,
+	"db_driver":             true,
+	"db_url":                true,
+	"error.retry_attempts":  true,
+	"auth.role_arn":         true,
+	"auth.mfa_serial":       true,
+	"auth.external_id":      true,
+	"auth.session_duration": true,
+	"auth.sso_start_url":    true,
+	"auth.sso_region":       true,
+	"auth.sso_account_id":   true,
+	"auth.sso_role_name":    true,
+	"kb.rerank":             true,
+	"kb.rerank_model":       true,
+	"github.token":          true,
+	"tickets.provider":      true,
+	"tickets.endpoint":      true,
+	"tickets.token":         true,
+	"tickets.user":          true,
+	"tickets.project":       true,
+	"secrets.backend":       true,
+	"speech.voice":          true,
+	"speech.engine":         true,
+	"speech.player":         true,
+}
+
+// supportedConfigKeyList returns the supported keys, sorted, for help and
+// error messages.
+func supportedConfigKeyList() string {
+	keys := make([]string, 0, len(supportedConfigKeys))
+	for key := range supportedConfigKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
 }
 
 // configSetCmd represents the config set command
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set a configuration value",
-	Long:  `Set a configuration value. Supported keys: custom-arn, model-id, system-prompt, context-files`,
-	Args:  cobra.ExactArgs(2),
+	Long:  "Set a configuration value. Supported keys: " + supportedConfigKeyList(),
+	Example: `  chat-cli config set model-id amazon.nova-pro-v1:0
+  chat-cli config set system-prompt "You are a terse assistant."
+  chat-cli config set error.retry_attempts 5`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Initialize configuration
 		fm, err := conf.NewFileManager("chat-cli")
@@ -52,10 +108,9 @@ var configSetCmd = &cobra.Command{
 		key := args[0]
 		value := args[1]
 
-		// Validate supported keys
-		if !supportedConfigKeys[key] {
-			fmt.Printf("Error: unsupported configuration key '%s'\n", key)
-			fmt.Println("Supported keys: custom-arn, model-id, system-prompt, context-files")
+		// Validate the key and value before writing them
+		if err := checkConfigSetting(key, value); err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -72,12 +127,64 @@ var configSetCmd = &cobra.Command{
 	},
 }
 
+// checkConfigSetting returns why config set can't store value under key:
+// the key isn't supported, in which case the key that was probably meant
+// is suggested, or the value is the wrong type or out of range.
+func checkConfigSetting(key, value string) error {
+	if !supportedConfigKeys[key] {
+		msg := fmt.Sprintf("unsupported configuration key '%s'", key)
+		if suggestion := conf.SuggestKey(key); supportedConfigKeys[suggestion] {
+			msg += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+		}
+		return errors.New(msg + "\nSupported keys: " + supportedConfigKeyList())
+	}
+	return conf.ValidateValue(key, value)
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for unknown keys and invalid values",
+	Long: `Check config.yaml, including every profile in it, against the settings chat-cli knows
+about. Unknown keys, values of the wrong type, and values out of range (such as a
+temperature above 1 or an unknown db_driver) are listed, and the command exits with
+status 1, so it can gate scripts and CI:
+
+> chat-cli config validate`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Report problems here rather than as warnings while loading
+		conf.StrictValidation = true
+		err = fm.InitializeViper()
+		var validationErr *conf.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Printf("%s has %s:\n", validationErr.File, countOf(len(validationErr.Issues), "problem"))
+			for _, issue := range validationErr.Issues {
+				fmt.Printf("  %s\n", issue)
+			}
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%s is valid\n", filepath.Join(fm.ConfigPath, fm.ConfigFile))
+	},
+}
+
 // configUnsetCmd represents the config unset command
 var configUnsetCmd = &cobra.Command{
 	Use:   "unset <key>",
 	Short: "Unset a configuration value",
-	Long:  `Unset (remove) a configuration value. Supported keys: custom-arn, model-id, system-prompt, context-files`,
-	Args:  cobra.ExactArgs(1),
+	Long:  "Unset (remove) a configuration value. Supported keys: " + supportedConfigKeyList(),
+	Example: `  chat-cli config unset model-id
+  chat-cli config unset custom-arn`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Initialize configuration
 		fm, err := conf.NewFileManager("chat-cli")
@@ -94,7 +201,7 @@ var configUnsetCmd = &cobra.Command{
 		// Validate supported keys
 		if !supportedConfigKeys[key] {
 			fmt.Printf("Error: unsupported configuration key '%s'\n", key)
-			fmt.Println("Supported keys: custom-arn, model-id, system-prompt, context-files")
+			fmt.Println("Supported keys: " + supportedConfigKeyList())
 			os.Exit(1)
 		}
 
@@ -119,8 +226,8 @@ var configUnsetCmd = &cobra.Command{
 			configData = make(map[string]interface{})
 		}
 
-		// Remove the key
-		delete(configData, key)
+		// Remove the key, and the section it was in if that's left empty
+		deleteConfigKey(configData, strings.Split(key, "."))
 
 		// Write back to file
 		yamlData, err := yaml.Marshal(configData)
@@ -138,11 +245,98 @@ var configUnsetCmd = &cobra.Command{
 	},
 }
 
+// deleteConfigKey removes the setting at path, such as error.retry_attempts
+// split on its dots, from data decoded from config.yaml. A section left
+// empty is removed too.
+func deleteConfigKey(data map[string]interface{}, path []string) {
+	if len(path) == 1 {
+		delete(data, path[0])
+		return
+	}
+	section, ok := data[path[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	deleteConfigKey(section, path[1:])
+	if len(section) == 0 {
+		delete(data, path[0])
+	}
+}
+
+// configKeyFlags maps each config key to the flag that overrides it, for
+// the keys that have one.
+var configKeyFlags = map[string]string{
+	"custom-arn":          "custom-arn",
+	"model-id":            "model-id",
+	"system-prompt":       "system",
+	"verify-command":      "verify-command",
+	"worker-model-id":     "worker-model-id",
+	"guardrail-id":        "guardrail-id",
+	"guardrail-version":   "guardrail-version",
+	"pager":               "pager",
+	"injection-check":     "injection-check",
+	"agent-prompt":        "agent-prompt",
+	"agent-prompt-append": "agent-prompt-append",
+}
+
+// configKeyDefaults holds the built-in default for keys that have one.
+var configKeyDefaults = map[string]string{
+	"model-id":        DefaultModelID,
+	"db_driver":       "sqlite",
+	"pager":           pagerOff,
+	"injection-check": injectionWarn,
+	"reasoning":       reasoningShow,
+	retryAttemptsKey:  strconv.Itoa(defaultRetryAttempts),
+}
+
+// configSetting is one effective setting as shown by config list.
+type configSetting struct {
+	Key    string      `json:"key"`
+	Value  string      `json:"value"`
+	Source conf.Source `json:"source"`
+}
+
+// effectiveConfigSettings resolves every supported key the same way the
+// commands that use it do, using flags for any overrides given on the
+// command line.
+func effectiveConfigSettings(fm *conf.FileManager, flags *pflag.FlagSet) ([]configSetting, error) {
+	keys := strings.Split(supportedConfigKeyList(), ", ")
+
+	settings := make([]configSetting, 0, len(keys))
+	for _, key := range keys {
+		var flagValue string
+		if name, ok := configKeyFlags[key]; ok && flags.Lookup(name) != nil {
+			var err error
+			if flagValue, err = flags.GetString(name); err != nil {
+				return nil, err
+			}
+		}
+
+		value, source := fm.ResolveConfigValue(key, flagValue, configKeyDefaults[key])
+		display := fmt.Sprint(value)
+		switch key {
+		case "db_url":
+			display = redactConnectionString(display)
+		case githubTokenKey, ticketTokenKey:
+			display = redactToken(display)
+		}
+		settings = append(settings, configSetting{Key: key, Value: display, Source: source})
+	}
+	return settings, nil
+}
+
 // configListCmd represents the config list command
 var configListCmd = &cobra.Command{
 	Use:   "list",
-	Short: "List all configuration values",
-	Long:  `List all current configuration values.`,
+	Short: "List effective configuration values and where they come from",
+	Long: `List the effective value of every configuration setting, and whether it came from a
+flag, an environment variable (CHAT_CLI_<KEY>, e.g. CHAT_CLI_MODEL_ID), the active
+profile, the config file, or the built-in default.
+
+Flags are resolved too, so to see why a setting isn't being honored, pass the same flags
+you use elsewhere:
+
+> chat-cli config list --model-id amazon.nova-pro-v1:0`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Initialize configuration
 		fm, err := conf.NewFileManager("chat-cli")
@@ -154,21 +348,44 @@ var configListCmd = &cobra.Command{
 			log.Fatal(initErr)
 		}
 
-		fmt.Println("Current configuration:")
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
 
-		// Define the keys we care about
-		configKeys := []string{"custom-arn", "model-id", "system-prompt", "context-files"}
+		settings, err := effectiveConfigSettings(fm, cmd.Flags())
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
 
-		hasConfig := false
-		for _, key := range configKeys {
-			if viper.IsSet(key) {
-				fmt.Printf("  %s = %s\n", key, viper.GetString(key))
-				hasConfig = true
+		if asJSON {
+			data, err := json.MarshalIndent(settings, "", "  ")
+			if err != nil {
+				log.Fatal(err)
 			}
+			fmt.Println(string(data))
+			return
 		}
 
-		if !hasConfig {
-			fmt.Println("  No configuration values set")
+		if profile := fm.ActiveProfile(); profile != "" {
+			fmt.Printf("Profile: %s\n\n", profile)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if _, err := fmt.Fprintln(w, "KEY\tVALUE\tSOURCE"); err != nil {
+			log.Printf("Error writing header: %v", err)
+		}
+		for _, setting := range settings {
+			value := oneLine(setting.Value)
+			if value == "" {
+				value = "(not set)"
+			}
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, value, setting.Source); err != nil {
+				log.Printf("Error writing setting: %v", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing writer: %v", err)
 		}
 	},
 }
@@ -178,4 +395,7 @@ func init() {
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configUnsetCmd)
 	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configListCmd.Flags().Bool("json", false, "print the settings as JSON")
 }
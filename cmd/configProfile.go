@@ -0,0 +1,256 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// configProfileCmd represents the config profile command
+var configProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named configuration profiles",
+	Long: `Manage named configuration profiles. A profile holds its own model, region, AWS
+profile, and inference parameters, which are used in place of the rest of the config file while
+it is active.
+
+The active profile is chosen by --profile, then CHAT_CLI_PROFILE, then the profile
+set with "chat-cli config profile use". Flags and CHAT_CLI_<KEY> environment
+variables still override the profile's settings.
+
+> chat-cli config profile create work --model-id anthropic.claude-sonnet-4-5 --region us-west-2
+> chat-cli config profile use work
+> chat-cli --profile personal prompt "Plan a weekend in Lisbon"`,
+}
+
+// configProfileCreateCmd represents the config profile create command
+var configProfileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a configuration profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		fm := profileFileManager()
+		settings := profileSettingsFromFlags(cmd.Flags())
+		if err := fm.CreateProfile(args[0], settings, force); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Created profile %s", args[0])
+		if saved, err := fm.ProfileSettings(args[0]); err == nil && len(saved) > 0 {
+			fmt.Printf(" (%s)", formatProfileSettings(saved))
+		}
+		fmt.Println()
+		if fm.ActiveProfile() != args[0] {
+			fmt.Printf("Use it with --profile %s, or make it the default with: chat-cli config profile use %s\n", args[0], args[0])
+		}
+	},
+}
+
+// configProfileUseCmd represents the config profile use command
+var configProfileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a profile the default",
+	Long: `Make a profile the default for future runs. --profile and CHAT_CLI_PROFILE still
+take precedence. Use --none to stop using a profile by default.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		none, err := cmd.Flags().GetBool("none")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if none == (len(args) == 1) {
+			log.Fatal("pass a profile name or --none")
+		}
+
+		name := ""
+		if !none {
+			name = args[0]
+		}
+
+		fm := profileFileManager()
+		if err := fm.UseProfile(name); err != nil {
+			if errors.Is(err, conf.ErrProfileNotFound) {
+				log.Fatalf("no profile named %s (see chat-cli config profile list)", name)
+			}
+			log.Fatal(err)
+		}
+
+		if name == "" {
+			fmt.Println("No longer using a profile by default")
+			return
+		}
+		fmt.Printf("Now using profile %s\n", name)
+	},
+}
+
+// configProfileListCmd represents the config profile list command
+var configProfileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configuration profiles",
+	Long:  "List configuration profiles and their settings. The active profile is marked with *.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fm := profileFileManager()
+
+		names := fm.Profiles()
+		if len(names) == 0 {
+			fmt.Println("No profiles yet. Create one with: chat-cli config profile create <name> --model-id <model>")
+			return
+		}
+
+		active := fm.ActiveProfile()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACTIVE\tNAME\tSETTINGS")
+		for _, name := range names {
+			settings, err := fm.ProfileSettings(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			marker := ""
+			if name == active {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", marker, name, formatProfileSettings(settings))
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing output: %v", err)
+		}
+	},
+}
+
+// profileFileManager loads config for the profile commands.
+func profileFileManager() *conf.FileManager {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+	return fm
+}
+
+// profileSettingsFromFlags collects the profile settings given as flags to
+// config profile create.
+func profileSettingsFromFlags(flags *pflag.FlagSet) map[string]string {
+	settings := make(map[string]string)
+	for key := range conf.ProfileSchema {
+		if f := flags.Lookup(key); f != nil && f.Changed {
+			settings[key] = f.Value.String()
+		}
+	}
+	return settings
+}
+
+// formatProfileSettings lists settings as key=value pairs, sorted by key.
+func formatProfileSettings(settings map[string]interface{}) string {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, oneLine(fmt.Sprint(settings[key]))))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// profileFlags maps the profile settings that have no config key of their
+// own to the flags they provide a default for. Model settings are resolved
+// with the rest of the config instead (see conf.FileManager.ProfileValue).
+var profileFlags = map[string]string{
+	"region":      "region",
+	"temperature": "temperature",
+	"topP":        "topP",
+	"max-tokens":  "max-tokens",
+}
+
+// applyActiveProfile defaults flags from the active profile. Flags given on
+// the command line are left alone.
+func applyActiveProfile(flags *pflag.FlagSet) error {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		return err
+	}
+
+	name, settings, err := fm.ReadActiveProfile()
+	if errors.Is(err, conf.ErrProfileNotFound) {
+		return fmt.Errorf("no profile named %s (see chat-cli config profile list)", fm.ActiveProfile())
+	}
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+	return applyProfileSettings(flags, settings)
+}
+
+// applyProfileSettings sets each flag in profileFlags that wasn't given on
+// the command line to the profile's value for it. The flag is marked as
+// changed so optional parameters like temperature are sent.
+func applyProfileSettings(flags *pflag.FlagSet, settings map[string]interface{}) error {
+	for key, flagName := range profileFlags {
+		value, ok := settings[key]
+		if !ok {
+			continue
+		}
+
+		f := flags.Lookup(flagName)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("profile setting %s: %w", key, err)
+		}
+		f.Changed = true
+	}
+	return nil
+}
+
+// isProfileCommand reports whether cmd is one of the config profile
+// commands, which manage profiles rather than run under one.
+func isProfileCommand(cmd *cobra.Command) bool {
+	return cmd == configProfileCmd || cmd.Parent() == configProfileCmd
+}
+
+func init() {
+	configCmd.AddCommand(configProfileCmd)
+	configProfileCmd.AddCommand(configProfileCreateCmd)
+	configProfileCmd.AddCommand(configProfileUseCmd)
+	configProfileCmd.AddCommand(configProfileListCmd)
+
+	configProfileCreateCmd.Flags().String("model-id", "", "the model id or inference profile id to use")
+	configProfileCreateCmd.Flags().String("custom-arn", "", "a custom arn from bedrock marketplace or cross-region inference")
+	configProfileCreateCmd.Flags().String("system-prompt", "", "the system prompt to use")
+	configProfileCreateCmd.Flags().String("worker-model-id", "", "the model for follow-up tool-use steps")
+	configProfileCreateCmd.Flags().String("region", "", "the AWS region")
+	configProfileCreateCmd.Flags().String("aws-profile", "", "the AWS shared config profile to take credentials from")
+	configProfileCreateCmd.Flags().Float32("temperature", 0, "the temperature (0-1)")
+	configProfileCreateCmd.Flags().Float32("topP", 0, "the top-P (0-1)")
+	configProfileCreateCmd.Flags().Int32("max-tokens", 0, "the max tokens")
+	configProfileCreateCmd.Flags().BoolP("force", "f", false, "replace an existing profile with the same name")
+
+	configProfileUseCmd.Flags().Bool("none", false, "stop using a profile by default")
+}
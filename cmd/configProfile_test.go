@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyProfileSettings(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("region", "us-east-1", "")
+	flags.Float32("temperature", 1.0, "")
+	flags.Int32("max-tokens", 4096, "")
+	flags.String("model-id", "", "")
+	if err := flags.Parse([]string{"--max-tokens", "100"}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := map[string]interface{}{
+		"region":      "us-west-2",
+		"temperature": "0.2",
+		"max-tokens":  2048,
+		"model-id":    "profile-model",
+		"topP":        0.9,
+	}
+	if err := applyProfileSettings(flags, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if region, _ := flags.GetString("region"); region != "us-west-2" {
+		t.Errorf("region = %q", region)
+	}
+
+	temperature, err := optionalFloat32Flag(flags, "temperature")
+	if err != nil || temperature == nil || *temperature != 0.2 {
+		t.Errorf("expected the profile's temperature to be sent, got %v, %v", temperature, err)
+	}
+
+	if maxTokens, _ := flags.GetInt32("max-tokens"); maxTokens != 100 {
+		t.Errorf("expected the command line to win over the profile, got max-tokens %d", maxTokens)
+	}
+
+	if modelID, _ := flags.GetString("model-id"); modelID != "" {
+		t.Errorf("expected model-id to be left to config resolution, got %q", modelID)
+	}
+
+	t.Run("bad values", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Float32("temperature", 1.0, "")
+		if err := applyProfileSettings(flags, map[string]interface{}{"temperature": "warm"}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestFormatProfileSettings(t *testing.T) {
+	got := formatProfileSettings(map[string]interface{}{"region": "us-west-2", "max-tokens": 100, "model-id": "m"})
+	if want := "max-tokens=100, model-id=m, region=us-west-2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+const (
+	// secretsBackendKey chooses where config secret keeps secrets.
+	secretsBackendKey = "secrets.backend"
+	// secretsPassphraseEnv holds the passphrase for the secrets file, for
+	// scripts and CI where it can't be typed.
+	secretsPassphraseEnv = "CHAT_CLI_SECRETS_PASSPHRASE"
+)
+
+// openSecrets returns the secrets store chosen by secrets.backend.
+func openSecrets(fm *conf.FileManager) (*conf.SecretsManager, error) {
+	return conf.NewSecretsManager(fm, configString(fm, secretsBackendKey), secretsPassphrase)
+}
+
+// secretsPassphrase returns the passphrase for the secrets file from
+// CHAT_CLI_SECRETS_PASSPHRASE, or asks for it on the terminal, twice when
+// the file is being created.
+func secretsPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(secretsPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("the secrets file needs a passphrase: set %s", secretsPassphraseEnv)
+	}
+
+	passphrase, err := readHidden("Secrets passphrase: ")
+	if err != nil || !confirm {
+		return passphrase, err
+	}
+	again, err := readHidden("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New("the passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// readHidden prompts on stderr and reads a line from the terminal without
+// echoing it.
+func readHidden(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(line), err
+}
+
+// configSecret returns a sensitive setting such as github.token: from the
+// environment or config file like any other, or else from the secrets
+// store. A secrets file that hasn't been created isn't opened, so nobody
+// is asked for a passphrase they never set.
+func configSecret(fm *conf.FileManager, key string) (string, error) {
+	if value := configString(fm, key); value != "" {
+		return value, nil
+	}
+	secrets, err := openSecrets(fm)
+	if err != nil {
+		return "", err
+	}
+	if !secrets.Exists() {
+		return "", nil
+	}
+	value, err := secrets.Get(key)
+	if errors.Is(err, conf.ErrSecretNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %s from the secrets store: %w", key, err)
+	}
+	return value, nil
+}
+
+// readSecretValue reads the value for config secret set from piped input,
+// dropping the newline that echo and most files end with.
+func readSecretValue(in io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(in, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// secretsFileManager loads the config for the config secret commands.
+func secretsFileManager() *conf.FileManager {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+	return fm
+}
+
+// configSecretCmd represents the config secret command
+var configSecretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Store sensitive settings encrypted, outside config.yaml",
+	Long: `Keep API tokens and passwords out of config.yaml. Secrets are stored in the OS
+keychain (the macOS Keychain, or the Secret Service through secret-tool on Linux),
+or, where there isn't one or secrets.backend is file, in secrets.enc in the config
+directory, encrypted with a key derived from a passphrase.
+
+A secret named after a setting, such as github.token, tickets.token, or
+db_password, is used when that setting isn't in config.yaml or the environment:
+
+> chat-cli config secret set github.token
+> chat-cli config secret get github.token
+> chat-cli config secret unset github.token`,
+}
+
+// configSecretSetCmd represents the config secret set command
+var configSecretSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Store a secret",
+	Long: `Store a secret, replacing any with the same name. Without a value, it's read
+from the terminal without echoing, or from piped input, so it doesn't end up in
+your shell history.
+
+The secrets file's passphrase is asked for on the terminal, or taken from
+CHAT_CLI_SECRETS_PASSPHRASE.`,
+	Example: `  chat-cli config secret set github.token
+  op read op://dev/jira/token | chat-cli config secret set tickets.token
+  chat-cli config set secrets.backend file`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if err := conf.ValidateSecretName(name); err != nil {
+			log.Fatal(err)
+		}
+
+		fm := secretsFileManager()
+		secrets, err := openSecrets(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var value string
+		switch {
+		case len(args) == 2:
+			value = args[1]
+		case term.IsTerminal(int(os.Stdin.Fd())):
+			if value, err = readHidden(fmt.Sprintf("Value for %s: ", name)); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			if value, err = readSecretValue(os.Stdin); err != nil {
+				log.Fatalf("error reading the secret: %v", err)
+			}
+		}
+		if value == "" {
+			log.Fatal("the secret is empty")
+		}
+
+		if err := secrets.Set(name, value); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Secret %s stored in the %s\n", name, secretsBackendName(secrets))
+		if viper.IsSet(name) {
+			fmt.Printf("Note: %s is also set in the config file or environment, which takes precedence; remove it with: chat-cli config unset %s\n", name, name)
+		}
+	},
+}
+
+// configSecretGetCmd represents the config secret get command
+var configSecretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		secrets, err := openSecrets(secretsFileManager())
+		if err != nil {
+			log.Fatal(err)
+		}
+		value, err := secrets.Get(args[0])
+		if errors.Is(err, conf.ErrSecretNotFound) {
+			fmt.Fprintf(os.Stderr, "Secret %s is not set\n", args[0])
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(value)
+	},
+}
+
+// configSecretUnsetCmd represents the config secret unset command
+var configSecretUnsetCmd = &cobra.Command{
+	Use:   "unset <name>",
+	Short: "Remove a secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		secrets, err := openSecrets(secretsFileManager())
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = secrets.Unset(args[0])
+		if errors.Is(err, conf.ErrSecretNotFound) {
+			fmt.Printf("Secret %s is not set\n", args[0])
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Secret %s removed\n", args[0])
+	},
+}
+
+// secretsBackendName describes where secrets are kept, for messages.
+func secretsBackendName(secrets *conf.SecretsManager) string {
+	if secrets.Backend() == conf.SecretBackendKeychain {
+		return "OS keychain"
+	}
+	return "encrypted secrets file"
+}
+
+func init() {
+	configCmd.AddCommand(configSecretCmd)
+	configSecretCmd.AddCommand(configSecretSetCmd)
+	configSecretCmd.AddCommand(configSecretGetCmd)
+	configSecretCmd.AddCommand(configSecretUnsetCmd)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestConfigSecret(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	fm := &conf.FileManager{ConfigPath: t.TempDir()}
+	viper.Set(secretsBackendKey, conf.SecretBackendFile)
+	t.Setenv(secretsPassphraseEnv, "pass")
+
+	if token, err := configSecret(fm, githubTokenKey); token != "" || err != nil {
+		t.Errorf("expected no token and no passphrase prompt before any secrets, got %q, %v", token, err)
+	}
+
+	secrets, err := openSecrets(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Set(githubTokenKey, "from-store"); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := configSecret(fm, githubTokenKey); token != "from-store" || err != nil {
+		t.Errorf("expected the stored secret, got %q, %v", token, err)
+	}
+	if password, err := configSecret(fm, "db_password"); password != "" || err != nil {
+		t.Errorf("expected an unstored secret to be empty, got %q, %v", password, err)
+	}
+
+	viper.Set(githubTokenKey, "from-config")
+	if token, err := configSecret(fm, githubTokenKey); token != "from-config" || err != nil {
+		t.Errorf("expected the config file to take precedence, got %q, %v", token, err)
+	}
+
+	viper.Set(githubTokenKey, "")
+	t.Setenv(secretsPassphraseEnv, "wrong")
+	if _, err := configSecret(fm, githubTokenKey); err == nil {
+		t.Error("expected a wrong passphrase to be reported")
+	}
+}
+
+func TestReadSecretValue(t *testing.T) {
+	got, err := readSecretValue(strings.NewReader("s3cret\r\n"))
+	if err != nil || got != "s3cret" {
+		t.Errorf("readSecretValue = %q, %v", got, err)
+	}
+	if got, _ := readSecretValue(strings.NewReader("  spaced  \n")); got != "  spaced  " {
+		t.Errorf("expected only the line ending to be dropped, got %q", got)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestEffectiveConfigSettings(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("model-id", "config-model")
+	viper.Set("custom-arn", "config-arn")
+	t.Setenv("CHAT_CLI_CUSTOM_ARN", "env-arn")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("model-id", DefaultModelID, "")
+	flags.String("system", "", "")
+	if err := flags.Parse([]string{"--system", "Be terse."}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := effectiveConfigSettings(&conf.FileManager{}, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != len(supportedConfigKeys) {
+		t.Fatalf("expected every supported key, got %+v", settings)
+	}
+
+	got := make(map[string]configSetting)
+	for _, s := range settings {
+		got[s.Key] = s
+	}
+
+	want := map[string]configSetting{
+		"model-id":        {Key: "model-id", Value: "config-model", Source: conf.SourceConfigFile},
+		"custom-arn":      {Key: "custom-arn", Value: "env-arn", Source: conf.SourceEnv},
+		"system-prompt":   {Key: "system-prompt", Value: "Be terse.", Source: conf.SourceFlag},
+		"worker-model-id": {Key: "worker-model-id", Value: "", Source: conf.SourceDefault},
+	}
+	for key, w := range want {
+		if got[key] != w {
+			t.Errorf("%s: got %+v, want %+v", key, got[key], w)
+		}
+	}
+}
+
+func TestSupportedConfigKeysInSchema(t *testing.T) {
+	for key := range supportedConfigKeys {
+		if _, ok := conf.Schema[key]; !ok {
+			t.Errorf("%s can be set but is missing from the config schema, so it would be reported as unknown", key)
+		}
+	}
+}
+
+func TestCheckConfigSetting(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"model-id", "amazon.nova-pro-v1:0", ""},
+		{"error.retry_attempts", "5", ""},
+		{"model_id", "amazon.nova-pro-v1:0", "did you mean 'model-id'?"},
+		{"colour", "blue", "Supported keys: "},
+		{"error.retry_attempts", "50", "must be from 0 to 10"},
+		{"db_driver", "mysql", "use sqlite, postgres"},
+	}
+	for _, tt := range tests {
+		err := checkConfigSetting(tt.key, tt.value)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s %s: unexpected error %v", tt.key, tt.value, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s %s: expected an error containing %q, got %v", tt.key, tt.value, tt.wantErr, err)
+		}
+	}
+}
+
+func TestConfigKeyDefaultsAreValid(t *testing.T) {
+	for key, value := range configKeyDefaults {
+		if err := conf.ValidateValue(key, value); err != nil {
+			t.Errorf("the default for %s is rejected: %v", key, err)
+		}
+	}
+}
+
+func TestDeleteConfigKey(t *testing.T) {
+	data := map[string]interface{}{
+		"model-id": "m",
+		"error":    map[string]interface{}{"retry_attempts": 5},
+	}
+
+	deleteConfigKey(data, []string{"error", "retry_attempts"})
+	if _, ok := data["error"]; ok {
+		t.Errorf("expected the emptied error section to be removed, got %v", data)
+	}
+
+	deleteConfigKey(data, []string{"missing", "key"})
+	deleteConfigKey(data, []string{"model-id"})
+	if len(data) != 0 {
+		t.Errorf("expected nothing left, got %v", data)
+	}
+}
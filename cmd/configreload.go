@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// liveConfigKeys are the settings a running chat applies as soon as
+// config.yaml changes. Changes to any other key are reported as needing a
+// new session.
+var liveConfigKeys = map[string]bool{
+	"system-prompt":       true,
+	"verify-command":      true,
+	"pager":               true,
+	"agent-prompt":        true,
+	"agent-prompt-append": true,
+}
+
+// configWatcher notices edits to config.yaml during a chat session. viper
+// re-reads the file on its own goroutine; the chat loop calls poll between
+// turns so settings only ever change while no request is in flight.
+type configWatcher struct {
+	fm *conf.FileManager
+
+	// flags holds the flag value given for each key, so a setting passed on
+	// the command line keeps winning over the file.
+	flags   map[string]string
+	values  map[string]string
+	changed chan struct{}
+}
+
+func newConfigWatcher(fm *conf.FileManager, flags map[string]string) *configWatcher {
+	w := &configWatcher{
+		fm:      fm,
+		flags:   flags,
+		changed: make(chan struct{}, 1),
+	}
+	w.values = w.snapshot()
+	return w
+}
+
+// start begins watching the config file.
+func (w *configWatcher) start() {
+	viper.OnConfigChange(func(fsnotify.Event) {
+		w.notify()
+	})
+	viper.WatchConfig()
+}
+
+// notify records that the file changed; repeated events before the next
+// poll collapse into one.
+func (w *configWatcher) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot resolves the effective value of every supported key.
+func (w *configWatcher) snapshot() map[string]string {
+	values := make(map[string]string, len(supportedConfigKeys))
+	for key := range supportedConfigKeys {
+		value := w.fm.GetConfigValue(key, w.flags[key], configKeyDefaults[key])
+		if value != nil {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
+
+// poll returns the keys whose effective value changed since the last call,
+// sorted, or nil if the file hasn't been modified.
+func (w *configWatcher) poll() []string {
+	select {
+	case <-w.changed:
+	default:
+		return nil
+	}
+
+	next := w.snapshot()
+	var changed []string
+	for key := range supportedConfigKeys {
+		if next[key] != w.values[key] {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	w.values = next
+	return changed
+}
+
+// value returns the effective value of key as of the last poll.
+func (w *configWatcher) value(key string) string {
+	return w.values[key]
+}
+
+// applyConfigChanges updates the session for each changed key that can be
+// applied live and tells the user what happened to each.
+func applyConfigChanges(s *chatSession, w *configWatcher, changed []string) {
+	for _, key := range changed {
+		if !liveConfigKeys[key] {
+			hint := "start a new session to use it"
+			if key == "model-id" || key == "custom-arn" {
+				hint += ", or use /model to switch now"
+			}
+			fmt.Fprintf(s.out, "\033[90mconfig: %s changed; %s\033[0m\n", key, hint)
+			continue
+		}
+
+		value := w.value(key)
+		switch key {
+		case "system-prompt":
+			s.systemPrompt = value
+			if s.repoMap != "" {
+				s.systemPrompt = withRepoMap(value, s.repoMap)
+			}
+			s.systemPrompt = withFileContext(s.systemPrompt, s.fileChunks)
+			s.input.System = withSystemCachePoint(buildSystemContentBlocks(s.systemPrompt))
+		case "verify-command":
+			s.verifyCommand = value
+		case "pager":
+			if err := validatePagerMode(value); err != nil {
+				fmt.Fprintf(s.out, "\033[90mconfig: %v; keeping %s\033[0m\n", err, s.pager)
+				continue
+			}
+			s.pager = value
+		case "agent-prompt", "agent-prompt-append":
+			prompt, appended := s.agentPrompt, s.agentPromptAppend
+			if key == "agent-prompt" {
+				prompt = value
+			} else {
+				appended = value
+			}
+			if _, err := buildAgentInstructions(prompt, appended, agentPromptVars("")); err != nil {
+				fmt.Fprintf(s.out, "\033[90mconfig: %v; keeping the previous %s\033[0m\n", err, key)
+				continue
+			}
+			s.agentPrompt, s.agentPromptAppend = prompt, appended
+		}
+
+		if value == "" {
+			fmt.Fprintf(s.out, "\033[90mconfig: %s cleared\033[0m\n", key)
+		} else {
+			fmt.Fprintf(s.out, "\033[90mconfig: %s updated\033[0m\n", key)
+		}
+	}
+}
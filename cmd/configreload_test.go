@@ -0,0 +1,133 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestConfigWatcherPoll(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("system-prompt", "Be terse.")
+	viper.Set("model-id", "config-model")
+
+	w := newConfigWatcher(&conf.FileManager{}, map[string]string{"model-id": "flag-model"})
+
+	if changed := w.poll(); changed != nil {
+		t.Errorf("expected nothing before the file changes, got %v", changed)
+	}
+
+	viper.Set("system-prompt", "Be thorough.")
+	viper.Set("model-id", "other-model") // overridden by the flag
+	viper.Set("verify-command", "go build ./...")
+	w.notify()
+	w.notify()
+
+	want := []string{"system-prompt", "verify-command"}
+	if got := w.poll(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if w.value("system-prompt") != "Be thorough." {
+		t.Errorf("unexpected value %q", w.value("system-prompt"))
+	}
+	if changed := w.poll(); changed != nil {
+		t.Errorf("expected repeated events to collapse into one, got %v", changed)
+	}
+}
+
+func TestApplyConfigChanges(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	w := newConfigWatcher(&conf.FileManager{}, nil)
+
+	viper.Set("system-prompt", "Be thorough.")
+	viper.Set("verify-command", "make test")
+	viper.Set("worker-model-id", "amazon.nova-micro-v1:0")
+	viper.Set("pager", "auto")
+	w.notify()
+
+	var out bytes.Buffer
+	s := &chatSession{
+		input:      &bedrockruntime.ConverseStreamInput{ModelId: aws.String("amazon.nova-pro-v1:0")},
+		repoMap:    "main.go\n",
+		fileChunks: []string{"<file name=\"notes.md\" part=\"1 of 1\">\nnotes\n</file>"},
+		out:        &out,
+	}
+	applyConfigChanges(s, w, w.poll())
+
+	if !strings.HasPrefix(s.systemPrompt, "Be thorough.") || !strings.Contains(s.systemPrompt, "<repository_map>") {
+		t.Errorf("expected the new prompt with the repo map kept, got %q", s.systemPrompt)
+	}
+	if !strings.Contains(s.systemPrompt, `<file name="notes.md"`) {
+		t.Errorf("expected the attached files to be kept, got %q", s.systemPrompt)
+	}
+	if len(s.input.System) == 0 {
+		t.Error("expected the request's system prompt to be rebuilt")
+	}
+	if s.verifyCommand != "make test" {
+		t.Errorf("unexpected verify command %q", s.verifyCommand)
+	}
+	if s.pager != pagerAuto {
+		t.Errorf("unexpected pager setting %q", s.pager)
+	}
+
+	for _, want := range []string{"system-prompt updated", "verify-command updated", "pager updated", "worker-model-id changed; start a new session"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestApplyConfigChangesInvalidPager(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	w := newConfigWatcher(&conf.FileManager{}, nil)
+
+	viper.Set("pager", "sometimes")
+	w.notify()
+
+	var out bytes.Buffer
+	s := &chatSession{pager: pagerOff, out: &out}
+	applyConfigChanges(s, w, w.poll())
+
+	if s.pager != pagerOff {
+		t.Errorf("expected the pager setting to be kept, got %q", s.pager)
+	}
+	if !strings.Contains(out.String(), `invalid pager setting "sometimes"`) {
+		t.Errorf("expected a warning, got:\n%s", out.String())
+	}
+}
+
+func TestApplyConfigChangesAgentPrompt(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	w := newConfigWatcher(&conf.FileManager{}, nil)
+
+	viper.Set("agent-prompt-append", "Run {{tests}} first.")
+	w.notify()
+
+	var out bytes.Buffer
+	s := &chatSession{out: &out}
+	applyConfigChanges(s, w, w.poll())
+	if s.agentPromptAppend != "" || !strings.Contains(out.String(), "unknown variable {{tests}}") {
+		t.Errorf("expected the bad prompt to be rejected, got %q and:\n%s", s.agentPromptAppend, out.String())
+	}
+
+	viper.Set("agent-prompt-append", "Use {{os}} commands.")
+	w.notify()
+	applyConfigChanges(s, w, w.poll())
+	if s.agentPromptAppend != "Use {{os}} commands." || !strings.Contains(out.String(), "agent-prompt-append updated") {
+		t.Errorf("expected the prompt to be applied, got %q and:\n%s", s.agentPromptAppend, out.String())
+	}
+}
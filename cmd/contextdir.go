@@ -0,0 +1,190 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// defaultContextDirTokens is the default --context-dir-tokens budget.
+const defaultContextDirTokens = 50_000
+
+// maxContextDirFileSize bounds which files --context-dir reads; larger files
+// are usually generated or data rather than code worth reading.
+const maxContextDirFileSize = 256 * 1024
+
+// contextDirStats summarizes what buildContextDir put in the document.
+type contextDirStats struct {
+	files   int
+	omitted int
+	tokens  int
+}
+
+// buildContextDir walks dir (respecting the .gitignore at the root of its
+// repository, if any) and concatenates the contents of its text files, each
+// under a header naming its path, into one document of at most
+// tokenBudget tokens. If include is non-empty, only files matching one of
+// its glob patterns, by relative path or by name, are read. Files are
+// added in walk order until the budget is used up; truncated reports
+// whether any were left out because of it.
+func buildContextDir(dir string, tokenBudget int, include []string) (doc string, stats contextDirStats, truncated bool, err error) {
+	for _, pattern := range include {
+		if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+			return "", stats, false, fmt.Errorf("invalid --context-include pattern %q: %w", pattern, matchErr)
+		}
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", stats, false, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", stats, false, err
+	}
+	if !info.IsDir() {
+		return "", stats, false, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	// .gitignore rules are relative to the repository root, which may be
+	// above dir
+	root := utils.FindGitBoundary(dir)
+	if root == "" {
+		root = dir
+	}
+	ignore := utils.LoadIgnoreMatcher(root, ".gitignore")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<context_dir path=%q>\n", dir)
+
+	walkErr := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable entries are skipped rather than failing the walk
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rootRel, relErr := filepath.Rel(root, p)
+		if relErr != nil || p == dir {
+			return nil
+		}
+		if ignore.Ignored(rootRel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(dir, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !matchesContextInclude(include, rel) {
+			return nil
+		}
+
+		content, ok := readContextFile(p, d)
+		if !ok {
+			return nil
+		}
+
+		if truncated {
+			stats.omitted++
+			return nil
+		}
+		section := fmt.Sprintf("<file path=%q>\n%s\n</file>\n", rel, strings.TrimRight(content, "\n"))
+		cost := estimateTokens(section)
+		if stats.tokens+cost > tokenBudget {
+			truncated = true
+			stats.omitted++
+			return nil
+		}
+
+		b.WriteString(section)
+		stats.tokens += cost
+		stats.files++
+		return nil
+	})
+	if walkErr != nil {
+		return "", stats, false, walkErr
+	}
+
+	if truncated {
+		fmt.Fprintf(&b, "... (%d more files omitted to stay within the context token budget)\n", stats.omitted)
+	}
+	b.WriteString("</context_dir>")
+
+	return b.String(), stats, truncated, nil
+}
+
+// matchesContextInclude reports whether rel is selected by the include
+// patterns; no patterns selects everything.
+func matchesContextInclude(include []string, rel string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readContextFile returns the contents of a text file small enough to
+// include, or false for large, unreadable, or binary files.
+func readContextFile(p string, d fs.DirEntry) (string, bool) {
+	info, err := d.Info()
+	if err != nil || info.Size() > maxContextDirFileSize {
+		return "", false
+	}
+
+	data, err := os.ReadFile(p) // #nosec G304 - path comes from walking the directory the user named
+	if err != nil || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", false
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// loadContextDir builds the --context-dir document, warning on stderr when
+// files had to be left out to stay within the budget. It returns "" when
+// no directory was given.
+func loadContextDir(dir string, tokenBudget int, include []string) (string, contextDirStats, error) {
+	if dir == "" {
+		return "", contextDirStats{}, nil
+	}
+
+	doc, stats, truncated, err := buildContextDir(dir, tokenBudget, include)
+	if err != nil {
+		return "", stats, fmt.Errorf("unable to read --context-dir: %w", err)
+	}
+	if stats.files == 0 {
+		return "", stats, fmt.Errorf("--context-dir %s has no text files to include", dir)
+	}
+	if truncated {
+		fmt.Fprintf(os.Stderr, "warning: --context-dir exceeds %d tokens; %d files were left out (raise --context-dir-tokens or narrow it with --context-include)\n", tokenBudget, stats.omitted)
+	}
+	return doc, stats, nil
+}
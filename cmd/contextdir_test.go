@@ -0,0 +1,120 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeContextTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBuildContextDir(t *testing.T) {
+	root := writeContextTree(t, map[string]string{
+		".gitignore":       "build/\n*.log\n",
+		"README.md":        "# Demo\n",
+		"main.go":          "package main\n\nfunc main() {}\n",
+		"build/output.txt": "generated\n",
+		"debug.log":        "noise\n",
+		"logo.png":         "\x89PNG\x00\x00",
+		"empty.txt":        "  \n",
+		"pkg/util.go":      "package pkg\n",
+	})
+
+	t.Run("includes text files with path headers", func(t *testing.T) {
+		doc, stats, truncated, err := buildContextDir(root, 10_000, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if truncated || stats.files != 4 {
+			t.Errorf("expected 4 files without truncation, got %+v truncated=%v", stats, truncated)
+		}
+		for _, want := range []string{"<file path=\"README.md\">\n# Demo\n</file>", "<file path=\"pkg/util.go\">", "<file path=\".gitignore\">"} {
+			if !strings.Contains(doc, want) {
+				t.Errorf("expected %q in:\n%s", want, doc)
+			}
+		}
+		for _, unwanted := range []string{"build/output.txt", "debug.log", "logo.png", "empty.txt"} {
+			if strings.Contains(doc, unwanted) {
+				t.Errorf("expected %s to be skipped:\n%s", unwanted, doc)
+			}
+		}
+	})
+
+	t.Run("filters with include patterns", func(t *testing.T) {
+		doc, stats, _, err := buildContextDir(root, 10_000, []string{"*.go"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.files != 2 || strings.Contains(doc, "README.md") {
+			t.Errorf("expected only the Go files, got %+v:\n%s", stats, doc)
+		}
+	})
+
+	t.Run("stops at the token budget", func(t *testing.T) {
+		doc, stats, truncated, err := buildContextDir(root, 20, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !truncated || stats.omitted == 0 || stats.tokens > 20 {
+			t.Errorf("expected truncation within budget, got %+v truncated=%v", stats, truncated)
+		}
+		if !strings.Contains(doc, "more files omitted") {
+			t.Errorf("expected an omission note in:\n%s", doc)
+		}
+	})
+
+	t.Run("applies the repository's .gitignore to a subdirectory", func(t *testing.T) {
+		repo := writeContextTree(t, map[string]string{
+			".gitignore":     "*.gen.go\n",
+			"app/handler.go": "package app\n",
+			"app/api.gen.go": "package app\n",
+		})
+		if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		doc, stats, _, err := buildContextDir(filepath.Join(repo, "app"), 10_000, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.files != 1 || strings.Contains(doc, "api.gen.go") || !strings.Contains(doc, `<file path="handler.go">`) {
+			t.Errorf("expected only handler.go, got %+v:\n%s", stats, doc)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, _, _, err := buildContextDir(root, 100, []string{"[bad"}); err == nil {
+			t.Error("expected an invalid pattern error")
+		}
+		if _, _, _, err := buildContextDir(filepath.Join(root, "main.go"), 100, nil); err == nil {
+			t.Error("expected an error for a file")
+		}
+		if _, _, err := loadContextDir(root, 100, []string{"*.rs"}); err == nil || !strings.Contains(err.Error(), "no text files") {
+			t.Errorf("expected a no-files error, got %v", err)
+		}
+	})
+
+	t.Run("no directory", func(t *testing.T) {
+		doc, _, err := loadContextDir("", 100, nil)
+		if doc != "" || err != nil {
+			t.Errorf("expected nothing, got %q, %v", doc, err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/factory"
+)
+
+// openDatabase connects to the configured database and runs migrations so
+// all tables exist. Callers are responsible for closing it.
+func openDatabase(fm *conf.FileManager) (db.Database, error) {
+	config, err := databaseConfig(fm)
+	if err != nil {
+		return nil, err
+	}
+
+	database, err := factory.CreateDatabase(&config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database: %w", err)
+	}
+
+	if err := database.Migrate(); err != nil {
+		_ = database.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return database, nil
+}
+
+// openReadOnlyHistory opens the SQLite history database without write
+// access, for the sql_query tool. It returns nil when history is stored in
+// PostgreSQL, which the tool doesn't support.
+func openReadOnlyHistory(fm *conf.FileManager) (*sql.DB, error) {
+	if fm.GetDBDriver() == "postgres" {
+		return nil, nil
+	}
+	return sqlite.OpenReadOnly(fm.GetDBPath())
+}
+
+// databaseConfig builds the connection settings for the configured
+// db_driver. SQLite only needs its file path; PostgreSQL takes either a
+// db_url connection string or the individual db_host, db_port, db_name,
+// db_user, and db_password keys, any of which can also come from the
+// environment (e.g. CHAT_CLI_DB_PASSWORD). db_password can also be kept
+// in the secrets store.
+func databaseConfig(fm *conf.FileManager) (db.Config, error) {
+	config := db.Config{
+		Driver: fm.GetDBDriver(),
+		Name:   fm.GetDBPath(),
+	}
+	if config.Driver != "postgres" {
+		return config, nil
+	}
+
+	config.URL = configString(fm, "db_url")
+	config.Host = configString(fm, "db_host")
+	config.Name = configString(fm, "db_name")
+	config.Username = configString(fm, "db_user")
+	password, err := configSecret(fm, "db_password")
+	if err != nil {
+		return config, err
+	}
+	config.Password = password
+
+	if port := configString(fm, "db_port"); port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return config, fmt.Errorf("invalid db_port %q: must be a number", port)
+		}
+		config.Port = n
+	}
+
+	return config, nil
+}
+
+// configString returns a config value from the environment or config file
+// as a string, whatever type the YAML holds.
+func configString(fm *conf.FileManager, key string) string {
+	value := fm.GetConfigValue(key, "", "")
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// redactConnectionString hides the password in a connection URL so it can
+// be displayed.
+func redactConnectionString(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	return u.Redacted()
+}
@@ -3,7 +3,10 @@ Copyright © 2024 Micah Walter
 */
 package cmd
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 // DefaultModelID is the built-in default Bedrock model when neither --model-id
 // nor --custom-arn (or persisted config) is set. Sonnet 5 is invoked via its
@@ -18,10 +21,20 @@ const DefaultModelID = "us.anthropic.claude-sonnet-5"
 func isInferenceProfileID(id string) bool {
 	switch {
 	case strings.HasPrefix(id, "arn:aws:bedrock:"):
-		return strings.Contains(id, ":inference-profile/")
-	case strings.HasPrefix(id, "us."), strings.HasPrefix(id, "global."), strings.HasPrefix(id, "eu."):
+		return strings.Contains(id, ":inference-profile/") || strings.Contains(id, ":application-inference-profile/")
+	case strings.HasPrefix(id, "us."), strings.HasPrefix(id, "global."), strings.HasPrefix(id, "eu."), strings.HasPrefix(id, "apac."):
 		return true
 	default:
 		return false
 	}
 }
+
+// checkInferenceProfile rejects an --inference-profile value that isn't an
+// inference profile, which would otherwise fail at the first request with
+// a less helpful error from Bedrock.
+func checkInferenceProfile(id string) error {
+	if id != "" && !isInferenceProfileID(id) {
+		return fmt.Errorf("--inference-profile %q isn't an inference profile ID or ARN, e.g. us.anthropic.claude-3-7-sonnet-20250219-v1:0", id)
+	}
+	return nil
+}
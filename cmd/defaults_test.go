@@ -13,6 +13,8 @@ func TestIsInferenceProfileID(t *testing.T) {
 		{"us.anthropic.claude-sonnet-5", true},
 		{"global.anthropic.claude-sonnet-5", true},
 		{"arn:aws:bedrock:us-east-1:123:inference-profile/us.anthropic.claude-sonnet-5", true},
+		{"arn:aws:bedrock:us-east-1:123:application-inference-profile/a1b2c3", true},
+		{"apac.anthropic.claude-sonnet-4-20250514-v1:0", true},
 		{"anthropic.claude-sonnet-4-20250514-v1:0", false},
 		{"anthropic.claude-3-5-sonnet-20240620-v1:0", false},
 	}
@@ -25,3 +27,14 @@ func TestIsInferenceProfileID(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckInferenceProfile(t *testing.T) {
+	for _, id := range []string{"", "us.anthropic.claude-3-7-sonnet-20250219-v1:0"} {
+		if err := checkInferenceProfile(id); err != nil {
+			t.Errorf("checkInferenceProfile(%q): %v", id, err)
+		}
+	}
+	if err := checkInferenceProfile("anthropic.claude-3-7-sonnet-20250219-v1:0"); err == nil {
+		t.Error("expected a foundation model ID to be rejected")
+	}
+}
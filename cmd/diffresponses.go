@@ -0,0 +1,200 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// diffResponsesCmd represents the diff-responses command
+var diffResponsesCmd = &cobra.Command{
+	Use:   "diff-responses <file-a> [file-b]",
+	Short: "Show the word-by-word differences between two model answers",
+	Long: `Show the word-by-word differences between two model answers, such as the
+same prompt sent to two models, or a reply before and after /retry.
+
+> chat-cli diff-responses haiku.md sonnet.md
+
+Or compare an answer with a message from a saved chat, counting both your
+messages and the model's from 1, as /save --from does:
+
+> chat-cli diff-responses answer.md --against 3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90:4
+
+Use - to read an answer from stdin. Words only in the first answer are shown
+in red, and words only in the second in green; when output isn't a terminal,
+they're marked [-like this-] and {+like this+} instead.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		against, _ := cmd.Flags().GetString("against")
+		if against != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		against, err := cmd.Flags().GetString("against")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		var before, after string
+		if against != "" {
+			// the saved message is the one being compared against
+			chatId, index, err := parseMessageRef(against)
+			if err != nil {
+				log.Fatal(err)
+			}
+			chatRepo, closeDB := openChatRepository()
+			defer closeDB()
+			if before, err = savedMessageText(cmd, chatRepo, chatId, index); err != nil {
+				log.Fatal(err)
+			}
+			if after, err = readResponseFile(args[0]); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			if args[0] == "-" && args[1] == "-" {
+				log.Fatal("only one answer can be read from stdin")
+			}
+			if before, err = readResponseFile(args[0]); err != nil {
+				log.Fatal(err)
+			}
+			if after, err = readResponseFile(args[1]); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		spans := diffWordSpans(diffWords(before), diffWords(after))
+		writeWordDiff(os.Stdout, spans, utils.ColorEnabled(os.Stdout))
+	},
+}
+
+// parseMessageRef reads a <chat-id>:<n> reference to a saved message.
+func parseMessageRef(ref string) (string, int, error) {
+	sep := strings.LastIndex(ref, ":")
+	if sep < 0 {
+		sep = len(ref)
+	}
+	chatId, number := ref[:sep], strings.TrimPrefix(ref[sep:], ":")
+	index, err := strconv.Atoi(number)
+	if chatId == "" || err != nil || index < 1 {
+		return "", 0, fmt.Errorf("invalid --against %q: use <chat-id>:<message number>, e.g. %s:4", ref, "3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90")
+	}
+	return chatId, index, nil
+}
+
+// savedMessageText returns the text of message index, counting from 1, of
+// a saved chat.
+func savedMessageText(cmd *cobra.Command, chatRepo *repository.ChatRepository, chatId string, index int) (string, error) {
+	chats, err := chatRepo.GetMessages(cmd.Context(), chatId)
+	if err != nil {
+		return "", fmt.Errorf("unable to load chat %s: %w", chatId, err)
+	}
+	if len(chats) == 0 {
+		return "", fmt.Errorf("no chat found with id %s", chatId)
+	}
+	if index > len(chats) {
+		return "", fmt.Errorf("chat %s has %d messages, so there's no message %d", chatId, len(chats), index)
+	}
+	return chats[index-1].Message, nil
+}
+
+// readResponseFile reads an answer from the file the user named, or from
+// stdin for -.
+func readResponseFile(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path) // #nosec G304 - the user named the file to compare
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("file does not exist: %s", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// writeWordDiff prints a word diff in the layout of the second text, with
+// the words only in one text colored, or marked in git's --word-diff=plain
+// style when color is false.
+func writeWordDiff(w io.Writer, spans []diffSpan, color bool) {
+	open := map[diffOp]string{diffDelete: "[-", diffInsert: "{+"}
+	closing := map[diffOp]string{diffDelete: "-]", diffInsert: "+}"}
+	if color {
+		open = map[diffOp]string{diffDelete: "\033[31m", diffInsert: "\033[32m"}
+		closing = map[diffOp]string{diffDelete: "\033[0m", diffInsert: "\033[0m"}
+	}
+
+	var b strings.Builder
+	atLineStart := true
+	for _, span := range spans {
+		words := span.words
+		if span.op == diffDelete {
+			// the layout follows the second text, so removed line breaks go
+			words = dropLineBreaks(words)
+		}
+
+		// line breaks are written as they are, outside the marks, so a
+		// run of changed words is marked a line at a time
+		marked := false
+		for _, word := range words {
+			if word == "\n" {
+				if marked {
+					b.WriteString(closing[span.op])
+					marked = false
+				}
+				b.WriteString("\n")
+				atLineStart = true
+				continue
+			}
+			if !atLineStart && !marked {
+				b.WriteString(" ")
+			}
+			if span.op != diffEqual && !marked {
+				b.WriteString(open[span.op])
+				marked = true
+			} else if marked {
+				b.WriteString(" ")
+			}
+			b.WriteString(word)
+			atLineStart = false
+		}
+		if marked {
+			b.WriteString(closing[span.op])
+		}
+	}
+	if !atLineStart {
+		b.WriteString("\n")
+	}
+	fmt.Fprint(w, b.String())
+}
+
+func dropLineBreaks(words []string) []string {
+	var kept []string
+	for _, word := range words {
+		if word != "\n" {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
+
+func init() {
+	rootCmd.AddCommand(diffResponsesCmd)
+	diffResponsesCmd.Flags().String("against", "", "compare the file with a saved chat message, given as <chat-id>:<message number>")
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package cmd
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to the current user on the
+// filesystem holding path.
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil //nolint:unconvert // Bsize's type varies by platform
+}
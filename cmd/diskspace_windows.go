@@ -0,0 +1,8 @@
+//go:build windows
+
+package cmd
+
+// freeDiskSpace isn't measured on Windows; doctor skips the check.
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errDiskSpaceUnsupported
+}
@@ -0,0 +1,235 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// doInstruction asks for a single command in a fenced block, which
+// parseCommandSuggestion relies on.
+const doInstruction = "You turn requests into a single shell command for %s, run with sh from %s. " +
+	"Reply with exactly one command in a ```sh code block, followed by one or two sentences explaining what it does. " +
+	"Prefer tools that are installed by default. If the request can't be done with one command, " +
+	"or would destroy data the user didn't ask to remove, explain why without a code block."
+
+// errNoCommand is returned when the model's reply has no command in it,
+// typically because it declined or asked for clarification.
+var errNoCommand = errors.New("no command suggested")
+
+// parseCommandSuggestion splits a reply into the command in its first
+// fenced code block and the explanation around it.
+func parseCommandSuggestion(reply string) (string, string, error) {
+	before, rest, found := strings.Cut(reply, "```")
+	if !found {
+		return "", "", errNoCommand
+	}
+	block, after, found := strings.Cut(rest, "```")
+	if !found {
+		return "", "", errNoCommand
+	}
+
+	// drop the language tag on the opening fence, if any
+	if tag, body, ok := strings.Cut(block, "\n"); ok && !strings.ContainsAny(strings.TrimSpace(tag), " \t") {
+		block = body
+	}
+	command := strings.TrimSpace(block)
+	if command == "" {
+		return "", "", errNoCommand
+	}
+
+	explanation := strings.TrimSpace(strings.TrimSpace(before) + "\n\n" + strings.TrimSpace(after))
+	return command, explanation, nil
+}
+
+// runShellCommand runs command with sh, connected to the given streams,
+// and returns its exit status.
+func runShellCommand(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	c := exec.CommandContext(ctx, "sh", "-c", command) // #nosec G204 - the user reviewed and confirmed this command
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+
+	err := c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("unable to run command: %w", err)
+	}
+	return 0, nil
+}
+
+// doOutcome describes what happened to a suggested command, for the chat
+// history.
+func doOutcome(ran bool, exitStatus int) string {
+	if !ran {
+		return "(The command was not run.)"
+	}
+	return fmt.Sprintf("(The command was run and exited with status %d.)", exitStatus)
+}
+
+// doCmd represents the do command
+var doCmd = &cobra.Command{
+	Use:   "do <request>",
+	Short: "Turn a request into a shell command and run it after you confirm",
+	Long: `Ask the model for a shell command that does what you describe, for example:
+
+> chat-cli do "find all files over 100MB"
+
+The command is shown with an explanation and only runs once you confirm it.
+The exchange is saved to your chat history, so you can pick it up with
+chat-cli --chat-id <id> if the command needs adjusting.`,
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		request := args[0]
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		region, err := cmd.Parent().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelIdFlag, err := cmd.PersistentFlags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		customArnFlag, err := cmd.PersistentFlags().GetString("custom-arn")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+		customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string)
+		finalModelId := modelId
+		if customArn != "" {
+			finalModelId = customArn
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		modelIdString, err := resolveChatModelID(cmd.Context(), newModelCatalog(cfg), finalModelId, customArn != "")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		workingDir, err := os.Getwd()
+		if err != nil {
+			log.Fatalf("unable to determine current directory: %v", err)
+		}
+
+		svc := bedrockruntime.NewFromConfig(cfg)
+		output, err := converseWithFallbacks(cmd.Context(), svc, &bedrockruntime.ConverseInput{
+			ModelId:  aws.String(modelIdString),
+			System:   buildSystemContentBlocks(fmt.Sprintf(doInstruction, runtime.GOOS, workingDir)),
+			Messages: []types.Message{userTextMessage(request)},
+		})
+		if err != nil {
+			log.Fatalf("error from Bedrock, %v", err)
+		}
+
+		var reply string
+		if msg, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+			reply = strings.TrimSpace(messageText(msg.Value))
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		chatRepo := repository.NewChatRepository(database)
+		chatId := uuid.NewV4().String()
+
+		// saves the exchange, noting whether the command was run
+		record := func(outcome string) {
+			message := reply
+			if outcome != "" {
+				message += "\n\n" + outcome
+			}
+			for _, chat := range []*repository.Chat{
+				{ChatId: chatId, Persona: "User", Message: request},
+				{ChatId: chatId, Persona: "Assistant", Message: message},
+			} {
+				if err := chatRepo.Create(cmd.Context(), chat); err != nil {
+					log.Printf("Failed to create chat: %v", err)
+					return
+				}
+			}
+			if err := chatRepo.SetTitle(cmd.Context(), chatId, truncateRunes("do: "+request, maxTitleLength)); err != nil {
+				log.Printf("Failed to title chat: %v", err)
+			}
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}
+
+		command, explanation, err := parseCommandSuggestion(reply)
+		if err != nil {
+			// the model declined or needs more detail; show what it said
+			fmt.Fprintln(stdout, reply)
+			record("")
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(stdout, "\n  \033[1m%s\033[0m\n\n", strings.ReplaceAll(command, "\n", "\n  "))
+		if explanation != "" {
+			fmt.Fprintf(stdout, "%s\n\n", explanation)
+		}
+
+		if !confirmAction(os.Stdin, stdout, "Run this command?") {
+			fmt.Fprintln(stdout, "Not run.")
+			record(doOutcome(false, 0))
+			return
+		}
+
+		exitStatus, err := runShellCommand(cmd.Context(), command, os.Stdin, stdout, stderr)
+		if err != nil {
+			record(doOutcome(false, 0))
+			log.Fatal(err)
+		}
+		record(doOutcome(true, exitStatus))
+
+		if exitStatus != 0 {
+			fmt.Fprintf(stderr, "\033[90mexit status %d (continue with: chat-cli --chat-id %s)\033[0m\n", exitStatus, chatId)
+			os.Exit(exitStatus)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doCmd)
+	doCmd.PersistentFlags().StringP("model-id", "m", DefaultModelID, "set the model id or inference profile id")
+	doCmd.PersistentFlags().String("custom-arn", "", "pass a custom arn from bedrock marketplace or cross-region inference")
+}
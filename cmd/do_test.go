@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandSuggestion(t *testing.T) {
+	tests := []struct {
+		name        string
+		reply       string
+		command     string
+		explanation string
+	}{
+		{
+			name:        "tagged block with explanation after",
+			reply:       "```sh\nfind . -type f -size +100M\n```\nLists files over 100 MB below the current directory.",
+			command:     "find . -type f -size +100M",
+			explanation: "Lists files over 100 MB below the current directory.",
+		},
+		{
+			name:        "untagged block with text on both sides",
+			reply:       "Here you go:\n```\ndu -sh * | sort -h\n```\nSorted by size.",
+			command:     "du -sh * | sort -h",
+			explanation: "Here you go:\n\nSorted by size.",
+		},
+		{
+			name:    "inline block",
+			reply:   "```ls -la```",
+			command: "ls -la",
+		},
+		{
+			name:    "multi-line command",
+			reply:   "```bash\nfor f in *.log; do\n  gzip \"$f\"\ndone\n```",
+			command: "for f in *.log; do\n  gzip \"$f\"\ndone",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, explanation, err := parseCommandSuggestion(tt.reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if command != tt.command {
+				t.Errorf("command = %q, want %q", command, tt.command)
+			}
+			if explanation != tt.explanation {
+				t.Errorf("explanation = %q, want %q", explanation, tt.explanation)
+			}
+		})
+	}
+
+	t.Run("no command", func(t *testing.T) {
+		for _, reply := range []string{"I can't do that in one command.", "```sh\n```", "```unterminated"} {
+			if _, _, err := parseCommandSuggestion(reply); !errors.Is(err, errNoCommand) {
+				t.Errorf("%q: expected errNoCommand, got %v", reply, err)
+			}
+		}
+	})
+}
+
+func TestRunShellCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status, err := runShellCommand(t.Context(), "echo out; echo err >&2; exit 3", strings.NewReader(""), &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 3 {
+		t.Errorf("expected exit status 3, got %d", status)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("unexpected output %q / %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestDoOutcome(t *testing.T) {
+	if got := doOutcome(false, 0); !strings.Contains(got, "not run") {
+		t.Errorf("unexpected outcome %q", got)
+	}
+	if got := doOutcome(true, 2); !strings.Contains(got, "status 2") {
+		t.Errorf("unexpected outcome %q", got)
+	}
+}
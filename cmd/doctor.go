@@ -0,0 +1,372 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// The result of a doctor check. Only failures make doctor --ci exit
+// non-zero; a warning is something that works now but may not for long.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// errDiskSpaceUnsupported is returned by freeDiskSpace where free space
+// can't be measured.
+var errDiskSpaceUnsupported = errors.New("free space isn't measured on this platform")
+
+// minFreeDisk is the free space below which the data directory is
+// reported: chat history and caches grow there.
+const minFreeDisk = 100 << 20
+
+// doctorCheck is the outcome of one of doctor's checks.
+type doctorCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// failedCheck reports err, with a suggestion for it from errorSuggestion
+// or else the given one.
+func failedCheck(name string, err error, suggestion string) doctorCheck {
+	if s := errorSuggestion(err); s != "" {
+		suggestion = s
+	}
+	return doctorCheck{Name: name, Status: checkFail, Detail: err.Error(), Suggestion: suggestion}
+}
+
+// errorSuggestion says how to fix the common causes of AWS and network
+// errors, or returns "" if err isn't one it recognizes.
+func errorSuggestion(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "ExpiredTokenException", "RequestExpired":
+			return "Your AWS session has expired: sign in again, with chat-cli auth login or aws sso login."
+		case "InvalidClientTokenId", "UnrecognizedClientException", "SignatureDoesNotMatch":
+			return "AWS doesn't recognize the access key: check the credentials in your AWS profile or environment."
+		case "AccessDeniedException", "AccessDenied":
+			return "The credentials work but lack permission: the IAM identity needs Bedrock access, such as the AmazonBedrockFullAccess policy, and the model needs access granted in the Bedrock console."
+		case "ResourceNotFoundException":
+			return "The model isn't available in this region: choose another with --model-id or --region."
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return "AWS couldn't be reached: check your network connection and proxy settings, or use --offline for local features."
+	}
+	if strings.Contains(err.Error(), "failed to retrieve credentials") || strings.Contains(err.Error(), "no EC2 IMDS role found") {
+		return "No AWS credentials were found: run chat-cli auth login, run aws configure, or set aws-profile to a profile that has them."
+	}
+	return ""
+}
+
+// configCheck reports the problems ValidateConfig found.
+func configCheck(issues []string) doctorCheck {
+	if len(issues) == 0 {
+		return doctorCheck{Name: "config", Status: checkPass, Detail: "config.yaml is valid"}
+	}
+	return doctorCheck{
+		Name:       "config",
+		Status:     checkFail,
+		Detail:     strings.Join(issues, "; "),
+		Suggestion: "Correct or remove these keys with chat-cli config set and config unset, or edit config.yaml.",
+	}
+}
+
+// databaseCheck connects to chat history and runs its migrations, as every
+// command that saves history does.
+func databaseCheck(fm *conf.FileManager) doctorCheck {
+	database, err := openDatabase(fm)
+	if err != nil {
+		suggestion := "Check that " + fm.GetDBPath() + " is writable and not in use by another program."
+		if fm.GetDBDriver() == "postgres" {
+			suggestion = "Check db_url (or db_host, db_port, db_name, db_user, and db_password) and that the server accepts connections."
+		}
+		return failedCheck("database", err, suggestion)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.GetDB().Ping(); err != nil {
+		return failedCheck("database", err, "")
+	}
+
+	detail := "SQLite at " + fm.GetDBPath()
+	if fm.GetDBDriver() == "postgres" {
+		detail = "PostgreSQL"
+	}
+	return doctorCheck{Name: "database", Status: checkPass, Detail: detail}
+}
+
+// diskCheck reports the free space where the data directory is, using
+// free to measure it.
+func diskCheck(dir string, free func(string) (uint64, error)) doctorCheck {
+	available, err := free(dir)
+	if errors.Is(err, errDiskSpaceUnsupported) {
+		return doctorCheck{Name: "disk space", Status: checkSkip, Detail: err.Error()}
+	}
+	if err != nil {
+		return failedCheck("disk space", err, "")
+	}
+
+	detail := fmt.Sprintf("%s free in %s", formatBytes(available), dir)
+	if available < minFreeDisk {
+		return doctorCheck{Name: "disk space", Status: checkWarn, Detail: detail, Suggestion: "Free up space: chat history and caches can't be written to a full disk. chat-cli chat archive and chat prune shrink the history."}
+	}
+	return doctorCheck{Name: "disk space", Status: checkPass, Detail: detail}
+}
+
+// formatBytes shows n in the largest unit that keeps it at least 1.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// awsDoctor runs the checks that need AWS. Its calls are functions so
+// tests can stand in for AWS.
+type awsDoctor struct {
+	region string
+	// identity returns the ARN the credentials belong to.
+	identity func(ctx context.Context) (string, error)
+	// listModels returns how many foundation models the region has.
+	listModels func(ctx context.Context) (int, error)
+	probe      accessProbeFunc
+}
+
+func newAWSDoctor(cfg aws.Config) *awsDoctor {
+	stsClient := sts.NewFromConfig(cfg)
+	bedrockClient := bedrock.NewFromConfig(cfg)
+	return &awsDoctor{
+		region: cfg.Region,
+		identity: func(ctx context.Context) (string, error) {
+			out, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				return "", err
+			}
+			return aws.ToString(out.Arn), nil
+		},
+		listModels: func(ctx context.Context) (int, error) {
+			out, err := bedrockClient.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+			if err != nil {
+				return 0, err
+			}
+			return len(out.ModelSummaries), nil
+		},
+		probe: converseProbe(bedrockruntime.NewFromConfig(cfg)),
+	}
+}
+
+// checks validates the credentials, then that Bedrock answers in the
+// region, then that modelID can be used. Each check after a failure is
+// skipped, since it would fail for the same reason.
+func (d *awsDoctor) checks(ctx context.Context, modelID string) []doctorCheck {
+	arn, err := d.identity(ctx)
+	if err != nil {
+		return []doctorCheck{
+			failedCheck("aws credentials", err, "Check your AWS credentials: chat-cli auth login, aws configure, or aws-profile."),
+			{Name: "bedrock", Status: checkSkip, Detail: "needs working AWS credentials"},
+			{Name: "model access", Status: checkSkip, Detail: "needs working AWS credentials"},
+		}
+	}
+	results := []doctorCheck{{Name: "aws credentials", Status: checkPass, Detail: arn}}
+
+	count, err := d.listModels(ctx)
+	if err != nil {
+		return append(results,
+			failedCheck("bedrock", err, "Check that Bedrock is available in "+d.region+", or choose another region with --region."),
+			doctorCheck{Name: "model access", Status: checkSkip, Detail: "needs Bedrock"},
+		)
+	}
+	results = append(results, doctorCheck{Name: "bedrock", Status: checkPass, Detail: fmt.Sprintf("%s in %s", countOf(count, "foundation model"), d.region)})
+
+	probeErr := d.probe(ctx, modelID)
+	switch accessStatus(probeErr) {
+	case accessGranted:
+		results = append(results, doctorCheck{Name: "model access", Status: checkPass, Detail: modelID})
+	case accessDenied:
+		results = append(results, doctorCheck{
+			Name:       "model access",
+			Status:     checkFail,
+			Detail:     modelID + ": no access",
+			Suggestion: accessHelp(d.region, []string{accessDenied}),
+		})
+	case accessProfileOnly:
+		results = append(results, doctorCheck{
+			Name:       "model access",
+			Status:     checkFail,
+			Detail:     modelID + ": " + accessProfileOnly,
+			Suggestion: accessHelp(d.region, []string{accessProfileOnly}),
+		})
+	default:
+		results = append(results, failedCheck("model access", fmt.Errorf("%s: %w", modelID, probeErr), "Check the model ID with chat-cli models list."))
+	}
+	return results
+}
+
+// doctorFailed reports whether any check failed.
+func doctorFailed(checks []doctorCheck) bool {
+	for _, c := range checks {
+		if c.Status == checkFail {
+			return true
+		}
+	}
+	return false
+}
+
+// printDoctorTable prints a row per check, then the suggestions for those
+// that didn't pass.
+func printDoctorTable(w io.Writer, checks []doctorCheck) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, c := range checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, strings.ToUpper(c.Status), c.Detail)
+	}
+	_ = tw.Flush()
+
+	var suggestions []string
+	for _, c := range checks {
+		if c.Suggestion != "" {
+			suggestions = append(suggestions, fmt.Sprintf("  %s: %s", c.Name, strings.ReplaceAll(c.Suggestion, "\n", "\n    ")))
+		}
+	}
+	if len(suggestions) > 0 {
+		fmt.Fprintf(w, "\nSuggestions:\n%s\n", strings.Join(suggestions, "\n"))
+	}
+}
+
+// doctorReport is doctor's output with --ci.
+type doctorReport struct {
+	OK     bool          `json:"ok"`
+	Checks []doctorCheck `json:"checks"`
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that chat-cli is set up to work",
+	Long: `Check chat-cli's setup and print a table of what passed and failed, with
+suggestions for fixing each failure:
+
+  config           config.yaml only has known keys with the right types of values
+  database         chat history can be opened and migrated
+  disk space       there's room in chat-cli's data directory
+  aws credentials  AWS credentials are found and accepted (sts:GetCallerIdentity)
+  bedrock          Bedrock answers in --region
+  model access     the configured model answers a one-token request
+
+With --offline, the AWS checks are skipped. With --ci, the results are
+printed as JSON and doctor exits with status 1 if any check failed.`,
+	Example: `  chat-cli doctor
+  chat-cli doctor --offline
+  chat-cli doctor --ci`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		ci, err := cmd.Flags().GetBool("ci")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelIdFlag, err := cmd.Flags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		customArnFlag, err := cmd.Flags().GetString("custom-arn")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// a config file doctor can't read is one of the things it reports
+		var checks []doctorCheck
+		var validation *conf.ValidationError
+		if initErr := fm.InitializeViper(); errors.As(initErr, &validation) {
+			checks = append(checks, configCheck(validation.Issues))
+		} else if initErr != nil {
+			checks = append(checks, failedCheck("config", initErr, "Check that config.yaml is valid YAML."))
+		} else {
+			checks = append(checks, configCheck(conf.ValidateConfig()))
+		}
+
+		checks = append(checks, databaseCheck(fm), diskCheck(fm.DataPath, freeDiskSpace))
+
+		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+		if customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string); customArn != "" {
+			modelId = customArn
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		switch {
+		case errors.Is(err, errOffline):
+			for _, name := range []string{"aws credentials", "bedrock", "model access"} {
+				checks = append(checks, doctorCheck{Name: name, Status: checkSkip, Detail: "offline"})
+			}
+		case err != nil:
+			checks = append(checks, failedCheck("aws credentials", err, "Check your AWS config files and the offline setting."))
+		default:
+			checks = append(checks, newAWSDoctor(cfg).checks(cmd.Context(), modelId)...)
+		}
+
+		if ci {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(doctorReport{OK: !doctorFailed(checks), Checks: checks}); err != nil {
+				log.Fatal(err)
+			}
+			if doctorFailed(checks) {
+				os.Exit(1)
+			}
+			return
+		}
+		printDoctorTable(os.Stdout, checks)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("ci", false, "print the results as JSON and exit with status 1 if any check failed")
+}
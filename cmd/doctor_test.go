@@ -0,0 +1,165 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestErrorSuggestion(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&smithy.GenericAPIError{Code: "ExpiredTokenException"}, "session has expired"},
+		{fmt.Errorf("operation error: %w", &smithy.GenericAPIError{Code: "AccessDeniedException"}), "lack permission"},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "couldn't be reached"},
+		{errors.New("get identity: failed to retrieve credentials"), "No AWS credentials were found"},
+		{errors.New("something else"), ""},
+	}
+	for _, tt := range tests {
+		got := errorSuggestion(tt.err)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("errorSuggestion(%v) = %q, want it to contain %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	if c := configCheck(nil); c.Status != checkPass {
+		t.Errorf("expected a valid config to pass, got %+v", c)
+	}
+	c := configCheck([]string{`unknown key "modle-id"`, `"db_port" should be an integer`})
+	if c.Status != checkFail || !strings.Contains(c.Detail, "modle-id") || c.Suggestion == "" {
+		t.Errorf("expected the issues reported, got %+v", c)
+	}
+}
+
+func TestDatabaseCheck(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("db_driver", "sqlite")
+
+	fm := &conf.FileManager{DataPath: t.TempDir(), DBFile: "data.db"}
+	if c := databaseCheck(fm); c.Status != checkPass || !strings.Contains(c.Detail, "data.db") {
+		t.Errorf("expected a new SQLite database to pass, got %+v", c)
+	}
+
+	fm = &conf.FileManager{DataPath: filepath.Join(t.TempDir(), "missing"), DBFile: "data.db"}
+	if c := databaseCheck(fm); c.Status != checkFail || !strings.Contains(c.Suggestion, "writable") {
+		t.Errorf("expected a database in a missing folder to fail, got %+v", c)
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	free := func(n uint64, err error) func(string) (uint64, error) {
+		return func(string) (uint64, error) { return n, err }
+	}
+
+	if c := diskCheck("/data", free(5<<30, nil)); c.Status != checkPass || c.Detail != "5.0 GiB free in /data" {
+		t.Errorf("unexpected %+v", c)
+	}
+	if c := diskCheck("/data", free(10<<20, nil)); c.Status != checkWarn || c.Suggestion == "" {
+		t.Errorf("expected low space to warn, got %+v", c)
+	}
+	if c := diskCheck("/data", free(0, errDiskSpaceUnsupported)); c.Status != checkSkip {
+		t.Errorf("expected the check skipped, got %+v", c)
+	}
+	if c := diskCheck("/data", free(0, errors.New("no such file"))); c.Status != checkFail {
+		t.Errorf("expected a failure, got %+v", c)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{512: "512 B", 1536: "1.5 KiB", 100 << 20: "100.0 MiB", 3 << 40: "3.0 TiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// fakeAWSDoctor answers each check with the given error, or success.
+func fakeAWSDoctor(identityErr, listErr, probeErr error) *awsDoctor {
+	return &awsDoctor{
+		region: "us-east-1",
+		identity: func(context.Context) (string, error) {
+			return "arn:aws:sts::123456789012:assumed-role/dev/jane", identityErr
+		},
+		listModels: func(context.Context) (int, error) { return 42, listErr },
+		probe:      func(context.Context, string) error { return probeErr },
+	}
+}
+
+func TestAWSDoctorChecks(t *testing.T) {
+	statuses := func(checks []doctorCheck) string {
+		var s []string
+		for _, c := range checks {
+			s = append(s, c.Name+"="+c.Status)
+		}
+		return strings.Join(s, ", ")
+	}
+
+	tests := []struct {
+		name   string
+		doctor *awsDoctor
+		want   string
+	}{
+		{"all pass", fakeAWSDoctor(nil, nil, nil), "aws credentials=pass, bedrock=pass, model access=pass"},
+		{"no credentials", fakeAWSDoctor(errors.New("failed to retrieve credentials"), nil, nil), "aws credentials=fail, bedrock=skip, model access=skip"},
+		{"no bedrock", fakeAWSDoctor(nil, &smithy.GenericAPIError{Code: "AccessDeniedException"}, nil), "aws credentials=pass, bedrock=fail, model access=skip"},
+		{"no model access", fakeAWSDoctor(nil, nil, &smithy.GenericAPIError{Code: "AccessDeniedException"}), "aws credentials=pass, bedrock=pass, model access=fail"},
+		{"unknown model", fakeAWSDoctor(nil, nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException"}), "aws credentials=pass, bedrock=pass, model access=fail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := tt.doctor.checks(t.Context(), "amazon.nova-pro-v1:0")
+			if got := statuses(checks); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			for _, c := range checks {
+				if c.Status == checkFail && c.Suggestion == "" {
+					t.Errorf("expected a suggestion for %+v", c)
+				}
+			}
+		})
+	}
+
+	checks := fakeAWSDoctor(nil, nil, &smithy.GenericAPIError{Code: "AccessDeniedException"}).checks(t.Context(), "amazon.nova-pro-v1:0")
+	if !strings.Contains(checks[2].Suggestion, "modelaccess") {
+		t.Errorf("expected a pointer to the model access page, got %q", checks[2].Suggestion)
+	}
+}
+
+func TestPrintDoctorTable(t *testing.T) {
+	checks := []doctorCheck{
+		{Name: "config", Status: checkPass, Detail: "config.yaml is valid"},
+		{Name: "disk space", Status: checkWarn, Detail: "10.0 MiB free in /data", Suggestion: "Free up space."},
+	}
+	var out bytes.Buffer
+	printDoctorTable(&out, checks)
+	for _, want := range []string{"CHECK", "config      PASS", "disk space  WARN", "Suggestions:\n  disk space: Free up space."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in\n%s", want, out.String())
+		}
+	}
+
+	if doctorFailed(checks) {
+		t.Error("expected warnings not to count as failures")
+	}
+	if !doctorFailed(append(checks, doctorCheck{Status: checkFail})) {
+		t.Error("expected a failure to be reported")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// printDryRun writes a readable rendering of the request --dry-run would
+// otherwise have sent, ending with a rough input token estimate. Images and
+// documents are listed by size but can't be counted without the model, so
+// they're left out of the estimate.
+func printDryRun(w io.Writer, input *bedrockruntime.ConverseStreamInput) {
+	var text strings.Builder
+	var attachments bool
+
+	fmt.Fprintf(w, "Model: %s\n", aws.ToString(input.ModelId))
+	if params := dryRunParameters(input.InferenceConfig); params != "" {
+		fmt.Fprintf(w, "Parameters: %s\n", params)
+	}
+	if fields := documentJSON(input.AdditionalModelRequestFields); fields != "" {
+		fmt.Fprintf(w, "Additional model fields: %s\n", fields)
+	}
+	if g := input.GuardrailConfig; g != nil {
+		fmt.Fprintf(w, "Guardrail: %s (version %s)\n", aws.ToString(g.GuardrailIdentifier), aws.ToString(g.GuardrailVersion))
+	}
+
+	if len(input.PromptVariables) > 0 {
+		fmt.Fprintln(w, "\nPrompt variables:")
+		names := make([]string, 0, len(input.PromptVariables))
+		for name := range input.PromptVariables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := input.PromptVariables[name].(*types.PromptVariableValuesMemberText); ok {
+				fmt.Fprintf(w, "  %s = %s\n", name, v.Value)
+				text.WriteString(v.Value)
+			}
+		}
+	}
+
+	if len(input.System) > 0 {
+		fmt.Fprintln(w, "\nSystem:")
+		for _, block := range input.System {
+			switch b := block.(type) {
+			case *types.SystemContentBlockMemberText:
+				fmt.Fprintln(w, indentLines(b.Value))
+				text.WriteString(b.Value)
+			case *types.SystemContentBlockMemberCachePoint:
+				fmt.Fprintln(w, "  [cache point]")
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\nMessages:")
+	if len(input.Messages) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, msg := range input.Messages {
+		fmt.Fprintf(w, "[%s]\n", roleLabel(msg.Role))
+		for _, block := range msg.Content {
+			line, blockText, isAttachment := describeContentBlock(block)
+			fmt.Fprintln(w, line)
+			text.WriteString(blockText)
+			attachments = attachments || isAttachment
+		}
+	}
+
+	if input.ToolConfig != nil && len(input.ToolConfig.Tools) > 0 {
+		fmt.Fprintln(w, "\nTools:")
+		for _, tool := range input.ToolConfig.Tools {
+			spec, ok := tool.(*types.ToolMemberToolSpec)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "  %s\n", aws.ToString(spec.Value.Name))
+			text.WriteString(aws.ToString(spec.Value.Description))
+			if schema, ok := spec.Value.InputSchema.(*types.ToolInputSchemaMemberJson); ok {
+				text.WriteString(documentJSON(schema.Value))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nEstimated input tokens: ~%d", estimateTokens(text.String()))
+	if attachments {
+		fmt.Fprint(w, " (plus images and documents)")
+	}
+	fmt.Fprintln(w)
+}
+
+// dryRunParameters formats the inference parameters that are set.
+func dryRunParameters(conf *types.InferenceConfiguration) string {
+	if conf == nil {
+		return ""
+	}
+
+	var params []string
+	if conf.MaxTokens != nil {
+		params = append(params, fmt.Sprintf("maxTokens=%d", *conf.MaxTokens))
+	}
+	if conf.Temperature != nil {
+		params = append(params, fmt.Sprintf("temperature=%g", *conf.Temperature))
+	}
+	if conf.TopP != nil {
+		params = append(params, fmt.Sprintf("topP=%g", *conf.TopP))
+	}
+	if len(conf.StopSequences) > 0 {
+		params = append(params, fmt.Sprintf("stopSequences=%q", conf.StopSequences))
+	}
+	return strings.Join(params, " ")
+}
+
+// describeContentBlock renders one message block for the dry run, returning
+// its display line, the text it contributes to the token estimate, and
+// whether it's an attachment the estimate leaves out.
+func describeContentBlock(block types.ContentBlock) (string, string, bool) {
+	switch b := block.(type) {
+	case *types.ContentBlockMemberText:
+		return indentLines(b.Value), b.Value, false
+	case *types.ContentBlockMemberCachePoint:
+		return "  [cache point]", "", false
+	case *types.ContentBlockMemberImage:
+		size := 0
+		if src, ok := b.Value.Source.(*types.ImageSourceMemberBytes); ok {
+			size = len(src.Value)
+		}
+		return fmt.Sprintf("  [image: %s, %s]", b.Value.Format, formatByteSize(size)), "", true
+	case *types.ContentBlockMemberDocument:
+		size := 0
+		if src, ok := b.Value.Source.(*types.DocumentSourceMemberBytes); ok {
+			size = len(src.Value)
+		}
+		return fmt.Sprintf("  [document: %s (%s, %s)]", aws.ToString(b.Value.Name), b.Value.Format, formatByteSize(size)), "", true
+	case *types.ContentBlockMemberToolUse:
+		input := documentJSON(b.Value.Input)
+		return fmt.Sprintf("  [tool call: %s %s]", aws.ToString(b.Value.Name), input), input, false
+	case *types.ContentBlockMemberToolResult:
+		var result strings.Builder
+		for _, content := range b.Value.Content {
+			if t, ok := content.(*types.ToolResultContentBlockMemberText); ok {
+				result.WriteString(t.Value)
+			}
+		}
+		return fmt.Sprintf("  [tool result: %s]", oneLine(result.String())), result.String(), false
+	case *types.ContentBlockMemberReasoningContent:
+		return "  [reasoning]", "", false
+	}
+	return fmt.Sprintf("  [%T]", block), "", false
+}
+
+// documentJSON renders a Smithy document as JSON, or "" if there isn't one.
+func documentJSON(doc document.Interface) string {
+	if doc == nil {
+		return ""
+	}
+	data, err := doc.MarshalSmithyDocument()
+	if err != nil {
+		return fmt.Sprintf("(unable to render: %v)", err)
+	}
+	return string(data)
+}
+
+// indentLines indents every line of s by two spaces.
+func indentLines(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
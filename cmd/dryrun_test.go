@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func TestPrintDryRun(t *testing.T) {
+	conf := buildInferenceConfiguration(512, aws.Float32(0.2), nil)
+	msg := types.Message{
+		Role:    types.ConversationRoleUser,
+		Content: buildQuestionContent("piped notes", "Summarize this"),
+	}
+	msg.Content = append(msg.Content, buildDocumentContentBlock([]byte("%PDF-1.4"), "pdf", "report"))
+
+	registry := tools.NewRegistry()
+	registry.Register(tools.NewReadFileTool())
+
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:                      aws.String("amazon.nova-pro-v1:0"),
+		InferenceConfig:              &conf,
+		System:                       withSystemCachePoint(buildSystemContentBlocks("Be terse.")),
+		AdditionalModelRequestFields: buildReasoningConfig("anthropic.claude-3-7-sonnet-20250219-v1:0", true, 2048, ""),
+		Messages:                     []types.Message{msg},
+		ToolConfig:                   registry.ToolConfiguration(),
+		GuardrailConfig:              buildGuardrailStreamConfig("gr-123", ""),
+	}
+
+	var out bytes.Buffer
+	printDryRun(&out, input)
+	got := out.String()
+
+	for _, want := range []string{
+		"Model: amazon.nova-pro-v1:0",
+		"Guardrail: gr-123 (version DRAFT)",
+		"Parameters: maxTokens=512 temperature=0.2",
+		`"budget_tokens":2048`,
+		"System:\n  Be terse.\n  [cache point]",
+		"[User]\n  piped notes\n  [cache point]\n  Summarize this",
+		"[document: report (pdf, 8 bytes)]",
+		"Tools:\n  read_file",
+		"(plus images and documents)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in dry run output:\n%s", want, got)
+		}
+	}
+	if !strings.Contains(got, "Estimated input tokens: ~") || strings.Contains(got, "~0") {
+		t.Errorf("expected a token estimate:\n%s", got)
+	}
+}
+
+func TestPrintDryRunManagedPrompt(t *testing.T) {
+	values, err := parsePromptVariables([]string{"topic=lighthouses"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	printDryRun(&out, &bedrockruntime.ConverseStreamInput{
+		ModelId:         aws.String(testPromptARN),
+		PromptVariables: values,
+	})
+
+	if got := out.String(); !strings.Contains(got, "Prompt variables:\n  topic = lighthouses") || !strings.Contains(got, "Messages:\n  (none)") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
@@ -0,0 +1,375 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/chat-cli/chat-cli/documents"
+	"github.com/chat-cli/chat-cli/utils"
+	"github.com/spf13/cobra"
+)
+
+// defaultEmbedModelID is the embedding model used when --model-id isn't
+// given.
+const defaultEmbedModelID = "amazon.titan-embed-text-v2:0"
+
+// cohereEmbedBatchSize is the most texts Cohere's embed models accept in
+// one request.
+const cohereEmbedBatchSize = 96
+
+// Output formats for embed.
+const (
+	embedFormatJSON = "json"
+	embedFormatCSV  = "csv"
+)
+
+// invokeModelFunc sends one InvokeModel request. It's a seam for tests, in
+// the same way as converseStreamFunc.
+type invokeModelFunc func(ctx context.Context, input *bedrockruntime.InvokeModelInput) (*bedrockruntime.InvokeModelOutput, error)
+
+// invokeModelWith sends InvokeModel requests with svc.
+func invokeModelWith(svc *bedrockruntime.Client) invokeModelFunc {
+	return func(ctx context.Context, input *bedrockruntime.InvokeModelInput) (*bedrockruntime.InvokeModelOutput, error) {
+		return svc.InvokeModel(ctx, input)
+	}
+}
+
+// embedOptions are the model settings for an embed run.
+type embedOptions struct {
+	modelID string
+	// dimensions is the length of the vectors to return, for models that
+	// let you choose; 0 leaves it to the model.
+	dimensions int
+	// normalize asks Titan v2 for unit-length vectors.
+	normalize bool
+	// inputType tells Cohere what the embeddings are for.
+	inputType string
+	// concurrency is the most requests to send at once; 0 sends them one
+	// at a time.
+	concurrency int
+}
+
+// embedCmd represents the embed command
+var embedCmd = &cobra.Command{
+	Use:   "embed [text]",
+	Short: "Turn text into embedding vectors",
+	Long: `Turn text into embedding vectors with an Amazon Titan or Cohere embedding model on
+Amazon Bedrock. Each input gives one vector, printed on its own line as a JSON array
+or, with --format csv, as a CSV row, in the same order as the inputs.
+
+Input is the text argument, each --file, or, when neither is given, stdin with one
+text per line (blank lines are skipped).
+
+> chat-cli embed "The quick brown fox"
+> chat-cli embed --file notes.md --format csv
+> cat sentences.txt | chat-cli embed --model-id cohere.embed-english-v3 > vectors.jsonl`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts embedOptions
+		var err error
+
+		if opts.modelID, err = cmd.Flags().GetString("model-id"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if opts.dimensions, err = cmd.Flags().GetInt("dimensions"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if opts.normalize, err = cmd.Flags().GetBool("normalize"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if opts.inputType, err = cmd.Flags().GetString("input-type"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if opts.concurrency, err = cmd.Flags().GetInt("concurrency"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if opts.concurrency < 1 {
+			log.Fatal("--concurrency must be at least 1")
+		}
+
+		files, err := cmd.Flags().GetStringArray("file")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if format != embedFormatJSON && format != embedFormatCSV {
+			log.Fatalf("invalid --format %q: use %s or %s", format, embedFormatJSON, embedFormatCSV)
+		}
+
+		texts, err := embedInputs(args, files)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		region, err := cmd.Parent().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+		vectors, report, err := embedBatch(cmd.Context(), invokeModelWith(bedrockruntime.NewFromConfig(cfg)), opts, texts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := writeEmbeddings(os.Stdout, format, vectors); err != nil {
+			log.Fatal(err)
+		}
+
+		// stdout holds only the vectors, so the summary goes to stderr
+		if report.requests > 1 {
+			fmt.Fprintf(os.Stderr, "Embedded %d text(s): %s\n", len(texts), report.summary())
+		}
+	},
+}
+
+// embedInputs gathers the texts to embed: the argument, then each file,
+// or else the lines piped to stdin.
+func embedInputs(args, files []string) ([]string, error) {
+	var texts []string
+	if len(args) == 1 {
+		texts = append(texts, args[0])
+	}
+	for _, path := range files {
+		doc, err := documents.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, doc.Text)
+	}
+
+	if len(texts) == 0 {
+		stdin, err := utils.ReadPipedStdin()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read stdin: %v", err)
+		}
+		texts = splitEmbedLines(stdin)
+	}
+
+	for _, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("nothing to embed: an input is empty")
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("nothing to embed: pass text, --file, or pipe lines to stdin")
+	}
+	return texts, nil
+}
+
+// splitEmbedLines returns each non-blank line of input.
+func splitEmbedLines(input string) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// embeddingProvider identifies the request format a model uses from its
+// id, which may be an inference profile or ARN.
+func embeddingProvider(modelID string) (string, error) {
+	switch {
+	case strings.Contains(modelID, "amazon.titan-embed"):
+		return "titan", nil
+	case strings.Contains(modelID, "cohere.embed"):
+		return "cohere", nil
+	}
+	return "", fmt.Errorf("%s is not a supported embedding model: use an Amazon Titan (amazon.titan-embed-*) or Cohere (cohere.embed-*) model", modelID)
+}
+
+type titanEmbedRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	Normalize  *bool  `json:"normalize,omitempty"`
+}
+
+type titanEmbedResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+type cohereEmbedRequest struct {
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+}
+
+type cohereEmbedResponse struct {
+	// Embeddings is a list of vectors, or, for models that can return
+	// several types, an object with one list per type.
+	Embeddings json.RawMessage `json:"embeddings"`
+}
+
+// embedTexts returns one vector per text, in order. Titan models take one
+// text per request; Cohere models take them in batches.
+func embedTexts(ctx context.Context, invoke invokeModelFunc, opts embedOptions, texts []string) ([][]float64, error) {
+	vectors, _, err := embedBatch(ctx, invoke, opts, texts)
+	return vectors, err
+}
+
+// embedBatch is embedTexts, also reporting how the requests went. Up to
+// opts.concurrency requests are sent at once, fewer while Bedrock is
+// throttling them.
+func embedBatch(ctx context.Context, invoke invokeModelFunc, opts embedOptions, texts []string) ([][]float64, batchReport, error) {
+	provider, err := embeddingProvider(opts.modelID)
+	if err != nil {
+		return nil, batchReport{}, err
+	}
+
+	vectors := make([][]float64, len(texts))
+	if provider == "titan" {
+		// only v2 lets you choose the size or normalization
+		titanV2 := strings.Contains(opts.modelID, "-v2")
+		if opts.dimensions != 0 && !titanV2 {
+			return nil, batchReport{}, fmt.Errorf("%s doesn't support --dimensions", opts.modelID)
+		}
+
+		report, err := runAdaptive(ctx, len(texts), opts.concurrency, func(ctx context.Context, i int) error {
+			req := titanEmbedRequest{InputText: texts[i]}
+			if titanV2 {
+				req.Dimensions = opts.dimensions
+				req.Normalize = aws.Bool(opts.normalize)
+			}
+
+			var resp titanEmbedResponse
+			if err := invokeEmbedModel(ctx, invoke, opts.modelID, req, &resp); err != nil {
+				return err
+			}
+			vectors[i] = resp.Embedding
+			return nil
+		})
+		if err != nil {
+			return nil, report, err
+		}
+		return vectors, report, nil
+	}
+
+	batches := (len(texts) + cohereEmbedBatchSize - 1) / cohereEmbedBatchSize
+	report, err := runAdaptive(ctx, batches, opts.concurrency, func(ctx context.Context, i int) error {
+		start := i * cohereEmbedBatchSize
+		batch := texts[start:min(start+cohereEmbedBatchSize, len(texts))]
+		req := cohereEmbedRequest{Texts: batch, InputType: opts.inputType, OutputDimension: opts.dimensions}
+
+		var resp cohereEmbedResponse
+		if err := invokeEmbedModel(ctx, invoke, opts.modelID, req, &resp); err != nil {
+			return err
+		}
+		embeddings, err := cohereVectors(resp.Embeddings)
+		if err != nil {
+			return err
+		}
+		if len(embeddings) != len(batch) {
+			return fmt.Errorf("expected %d embeddings from %s, got %d", len(batch), opts.modelID, len(embeddings))
+		}
+		copy(vectors[start:], embeddings)
+		return nil
+	})
+	if err != nil {
+		return nil, report, err
+	}
+	return vectors, report, nil
+}
+
+// cohereVectors reads the float vectors out of a Cohere response in either
+// of its shapes.
+func cohereVectors(raw json.RawMessage) ([][]float64, error) {
+	var vectors [][]float64
+	if err := json.Unmarshal(raw, &vectors); err == nil {
+		return vectors, nil
+	}
+
+	var byType map[string][][]float64
+	if err := json.Unmarshal(raw, &byType); err != nil {
+		return nil, fmt.Errorf("unable to read embeddings: %v", err)
+	}
+	vectors, ok := byType["float"]
+	if !ok {
+		return nil, fmt.Errorf("response has no float embeddings")
+	}
+	return vectors, nil
+}
+
+// invokeEmbedModel sends body to modelID and decodes the response into
+// out.
+func invokeEmbedModel(ctx context.Context, invoke invokeModelFunc, modelID string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("unable to marshal body: %v", err)
+	}
+
+	resp, err := invoke(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("error from Bedrock, %w", err)
+	}
+
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("unable to read response from %s: %v", modelID, err)
+	}
+	return nil
+}
+
+// writeEmbeddings prints each vector on its own line, as a JSON array or a
+// CSV row.
+func writeEmbeddings(w io.Writer, format string, vectors [][]float64) error {
+	if format == embedFormatCSV {
+		cw := csv.NewWriter(w)
+		for _, vector := range vectors {
+			row := make([]string, len(vector))
+			for i, v := range vector {
+				row[i] = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	enc := json.NewEncoder(w)
+	for _, vector := range vectors {
+		if err := enc.Encode(vector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(embedCmd)
+
+	embedCmd.Flags().StringP("model-id", "m", defaultEmbedModelID, "the embedding model id (amazon.titan-embed-* or cohere.embed-*)")
+	embedCmd.Flags().StringArray("file", nil, "embed the text of a pdf, docx, html, txt, md, or csv file (repeatable, one vector per file)")
+	embedCmd.Flags().String("format", embedFormatJSON, "output format: json (one array per line) or csv (one row per vector)")
+	embedCmd.Flags().Int("dimensions", 0, "vector length, for models that support a choice (e.g. 256, 512, or 1024 for Titan v2)")
+	embedCmd.Flags().Bool("normalize", true, "return unit-length vectors (Titan v2)")
+	embedCmd.Flags().Int("concurrency", defaultBatchConcurrency, "most requests to send at once; lowered automatically while Bedrock is throttling")
+	embedCmd.Flags().String("input-type", "search_document", "what the vectors are for (Cohere): search_document, search_query, classification, or clustering")
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// fakeEmbedModel answers embedding requests with vectors derived from the
+// length of each text, recording the request bodies it saw.
+func fakeEmbedModel(response func(body map[string]interface{}) string) (invokeModelFunc, *[]map[string]interface{}) {
+	var requests []map[string]interface{}
+	invoke := func(ctx context.Context, input *bedrockruntime.InvokeModelInput) (*bedrockruntime.InvokeModelOutput, error) {
+		var body map[string]interface{}
+		if err := json.Unmarshal(input.Body, &body); err != nil {
+			return nil, err
+		}
+		body["modelId"] = aws.ToString(input.ModelId)
+		requests = append(requests, body)
+		return &bedrockruntime.InvokeModelOutput{Body: []byte(response(body))}, nil
+	}
+	return invoke, &requests
+}
+
+func TestEmbedTextsTitan(t *testing.T) {
+	invoke, requests := fakeEmbedModel(func(body map[string]interface{}) string {
+		return fmt.Sprintf(`{"embedding": [%d, 0.5], "inputTextTokenCount": 2}`, len(body["inputText"].(string)))
+	})
+
+	opts := embedOptions{modelID: "amazon.titan-embed-text-v2:0", dimensions: 256, normalize: true}
+	vectors, err := embedTexts(context.Background(), invoke, opts, []string{"a", "bcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 3 {
+		t.Errorf("unexpected vectors %v", vectors)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("expected one request per text, got %d", len(*requests))
+	}
+	if got := (*requests)[0]; got["dimensions"] != float64(256) || got["normalize"] != true {
+		t.Errorf("unexpected request %v", got)
+	}
+
+	t.Run("v1 takes only the text", func(t *testing.T) {
+		invoke, requests := fakeEmbedModel(func(map[string]interface{}) string { return `{"embedding": [1]}` })
+		if _, err := embedTexts(context.Background(), invoke, embedOptions{modelID: "amazon.titan-embed-text-v1", normalize: true}, []string{"a"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := (*requests)[0]["normalize"]; ok {
+			t.Errorf("expected no normalize setting, got %v", (*requests)[0])
+		}
+
+		if _, err := embedTexts(context.Background(), invoke, embedOptions{modelID: "amazon.titan-embed-text-v1", dimensions: 256}, []string{"a"}); err == nil {
+			t.Error("expected an error for --dimensions on v1")
+		}
+	})
+}
+
+func TestEmbedTextsCohere(t *testing.T) {
+	vectorsFor := func(body map[string]interface{}) []string {
+		var vectors []string
+		for _, text := range body["texts"].([]interface{}) {
+			vectors = append(vectors, fmt.Sprintf("[%d]", len(text.(string))))
+		}
+		return vectors
+	}
+
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+
+	invoke, requests := fakeEmbedModel(func(body map[string]interface{}) string {
+		return `{"embeddings": [` + strings.Join(vectorsFor(body), ",") + `]}`
+	})
+	vectors, err := embedTexts(context.Background(), invoke, embedOptions{modelID: "us.cohere.embed-english-v3", inputType: "search_query"}, texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("expected 100 texts to be sent in 2 batches, got %d", len(*requests))
+	}
+	if (*requests)[0]["input_type"] != "search_query" {
+		t.Errorf("unexpected request %v", (*requests)[0])
+	}
+	if len(vectors) != 100 || vectors[99][0] != 100 {
+		t.Errorf("expected vectors in input order, got %d vectors ending %v", len(vectors), vectors[len(vectors)-1])
+	}
+
+	t.Run("embeddings by type", func(t *testing.T) {
+		invoke, _ := fakeEmbedModel(func(body map[string]interface{}) string {
+			return `{"embeddings": {"float": [` + strings.Join(vectorsFor(body), ",") + `]}}`
+		})
+		vectors, err := embedTexts(context.Background(), invoke, embedOptions{modelID: "cohere.embed-v4:0"}, []string{"ab"})
+		if err != nil || len(vectors) != 1 || vectors[0][0] != 2 {
+			t.Errorf("got %v, %v", vectors, err)
+		}
+	})
+}
+
+func TestEmbeddingProvider(t *testing.T) {
+	if _, err := embeddingProvider("amazon.nova-pro-v1:0"); err == nil {
+		t.Error("expected an error for a chat model")
+	}
+	if p, err := embeddingProvider("arn:aws:bedrock:us-east-1::foundation-model/amazon.titan-embed-text-v2:0"); p != "titan" || err != nil {
+		t.Errorf("got %q, %v", p, err)
+	}
+}
+
+func TestSplitEmbedLines(t *testing.T) {
+	got := splitEmbedLines("first\r\n\n  \nsecond\n")
+	if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriteEmbeddings(t *testing.T) {
+	vectors := [][]float64{{0.25, -1}, {3, 0.125}}
+
+	var out bytes.Buffer
+	if err := writeEmbeddings(&out, embedFormatJSON, vectors); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[0.25,-1]\n[3,0.125]\n"; out.String() != want {
+		t.Errorf("json: got %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := writeEmbeddings(&out, embedFormatCSV, vectors); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0.25,-1\n3,0.125\n"; out.String() != want {
+		t.Errorf("csv: got %q, want %q", out.String(), want)
+	}
+}
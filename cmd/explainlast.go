@@ -0,0 +1,257 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/utils"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// maxCapturedStderr caps how much of a command's stderr is sent. The end
+// is kept, since that's usually where the error is.
+const maxCapturedStderr = 8 * 1024
+
+const explainInstruction = "You help someone working at a shell prompt. " +
+	"Explain briefly what happened with their last command and, if it failed, " +
+	"the most likely cause and a concrete fix, such as a corrected command. " +
+	"Don't repeat their output back to them."
+
+// lastCommand is the previous shell command as recorded by the shell
+// integration.
+type lastCommand struct {
+	command    string
+	exitStatus int
+	stderr     string
+}
+
+// readLastCommand reads the capture file the shell integration writes
+// after every command: the exit status on the first line, then the
+// command. The command's stderr, if it was captured, is in a file beside
+// it with a .stderr suffix.
+func readLastCommand(path string) (lastCommand, error) {
+	if path == "" {
+		return lastCommand{}, errors.New(`no command has been captured: load the shell integration first, e.g. eval "$(chat-cli shell-init zsh)"`)
+	}
+
+	if err := checkCaptureFile(path); errors.Is(err, fs.ErrNotExist) {
+		return lastCommand{}, errors.New("no command has been captured in this shell yet")
+	} else if err != nil {
+		return lastCommand{}, err
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path is the shell integration's capture file
+	if err != nil {
+		return lastCommand{}, fmt.Errorf("unable to read captured command: %w", err)
+	}
+
+	status, command, _ := strings.Cut(string(data), "\n")
+	exitStatus, err := strconv.Atoi(strings.TrimSpace(status))
+	if err != nil {
+		return lastCommand{}, fmt.Errorf("capture file %s is malformed: %q is not an exit status", path, status)
+	}
+	last := lastCommand{
+		command:    strings.TrimRight(command, "\n"),
+		exitStatus: exitStatus,
+	}
+	if last.command == "" {
+		return lastCommand{}, fmt.Errorf("capture file %s is malformed: no command", path)
+	}
+
+	if checkCaptureFile(path+".stderr") == nil {
+		stderr, err := os.ReadFile(path + ".stderr") // #nosec G304 - written alongside the capture file
+		if err == nil {
+			last.stderr = tailBytes(string(stderr), maxCapturedStderr)
+		}
+	}
+
+	return last, nil
+}
+
+// checkCaptureFile refuses a capture file that anyone but the user could
+// have written: one that isn't a regular file they own, or that's in a
+// directory someone else owns or can write to. Whatever is in it goes
+// into the prompt.
+func checkCaptureFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || !ownedByUser(info) {
+		return fmt.Errorf("refusing to read capture file %s: it isn't a regular file you own", path)
+	}
+
+	dir, err := os.Lstat(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("unable to read captured command: %w", err)
+	}
+	if !dir.IsDir() || !ownedByUser(dir) || (runtime.GOOS != "windows" && dir.Mode().Perm()&0o022 != 0) {
+		return fmt.Errorf("refusing to read capture file %s: other users can write to %s", path, filepath.Dir(path))
+	}
+	return nil
+}
+
+// tailBytes returns the last n bytes of s, marking that the start was
+// dropped and never splitting a multi-byte character.
+func tailBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "...(earlier output omitted)\n" + strings.ToValidUTF8(s[len(s)-n:], "")
+}
+
+// explainPrompt asks about last, with the context needed to diagnose it.
+func explainPrompt(last lastCommand, workingDir string) string {
+	var b strings.Builder
+	if last.exitStatus == 0 {
+		b.WriteString("My last shell command succeeded. Explain what it did and point out anything in its output worth attention.\n\n")
+	} else {
+		fmt.Fprintf(&b, "My last shell command failed with exit status %d. Explain why and how to fix it.\n\n", last.exitStatus)
+	}
+
+	fmt.Fprintf(&b, "Operating system: %s\n", runtime.GOOS)
+	if workingDir != "" {
+		fmt.Fprintf(&b, "Working directory: %s\n", workingDir)
+	}
+	fmt.Fprintf(&b, "\n<command>\n%s\n</command>\n", last.command)
+
+	if strings.TrimSpace(last.stderr) != "" {
+		fmt.Fprintf(&b, "\n<stderr>\n%s\n</stderr>\n", strings.TrimRight(last.stderr, "\n"))
+	} else {
+		b.WriteString("\nIts stderr wasn't captured.\n")
+	}
+	return b.String()
+}
+
+// explainLastCmd represents the explain-last command
+var explainLastCmd = &cobra.Command{
+	Use:   "explain-last",
+	Short: "Explain why your last shell command failed",
+	Long: `Ask the model to explain the last command you ran and, if it failed, suggest
+a fix. The command and its exit status are recorded by the shell integration:
+
+  eval "$(chat-cli shell-init zsh)"
+
+To include the command's stderr as well, set CHAT_CLI_CAPTURE_STDERR=1 before
+loading the integration (zsh and bash only).`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		region, err := cmd.Parent().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		modelIdFlag, err := cmd.PersistentFlags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		customArnFlag, err := cmd.PersistentFlags().GetString("custom-arn")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		captureFile, err := cmd.PersistentFlags().GetString("capture-file")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if captureFile == "" {
+			captureFile = os.Getenv("CHAT_CLI_CAPTURE_FILE")
+		}
+
+		dryRun, err := cmd.PersistentFlags().GetBool("dry-run")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		last, err := readLastCommand(captureFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+		customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string)
+		finalModelId := modelId
+		if customArn != "" {
+			finalModelId = customArn
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		modelIdString := finalModelId
+		if !dryRun {
+			modelIdString, err = resolveChatModelID(cmd.Context(), newModelCatalog(cfg), finalModelId, customArn != "")
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		workingDir, _ := os.Getwd()
+		converseStreamInput := &bedrockruntime.ConverseStreamInput{
+			ModelId:  aws.String(modelIdString),
+			System:   buildSystemContentBlocks(explainInstruction),
+			Messages: []types.Message{userTextMessage(explainPrompt(last, workingDir))},
+		}
+
+		if dryRun {
+			printDryRun(os.Stdout, converseStreamInput)
+			return
+		}
+
+		fmt.Fprintf(stdout, "\033[90mExplaining: %s (exit status %d)\033[0m\n\n", oneLine(last.command), last.exitStatus)
+
+		svc := bedrockruntime.NewFromConfig(cfg)
+		output, err := converseStreamWithFallbacks(cmd.Context(), svc, converseStreamInput)
+		if err != nil {
+			log.Fatalf("error from Bedrock, %v", err)
+		}
+
+		onText := func(ctx context.Context, part string) error {
+			fmt.Print(part)
+			return nil
+		}
+		discardReasoning := func(context.Context, string) error { return nil }
+		if _, err := utils.ProcessStreamingOutput(cmd.Context(), output, onText, discardReasoning); err != nil {
+			log.Fatal("streaming output processing error: ", err)
+		}
+
+		fmt.Println()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainLastCmd)
+	explainLastCmd.PersistentFlags().StringP("model-id", "m", DefaultModelID, "set the model id or inference profile id")
+	explainLastCmd.PersistentFlags().String("custom-arn", "", "pass a custom arn from bedrock marketplace or cross-region inference")
+	explainLastCmd.PersistentFlags().String("capture-file", "", "read the last command from this file instead of $CHAT_CLI_CAPTURE_FILE")
+	explainLastCmd.PersistentFlags().Bool("dry-run", false, "print the assembled request instead of sending it")
+}
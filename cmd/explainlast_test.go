@@ -0,0 +1,124 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadLastCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("reads status, command, and stderr", func(t *testing.T) {
+		path := filepath.Join(dir, "last-1")
+		if err := os.WriteFile(path, []byte("2\nls /nonexistent\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".stderr", []byte("ls: cannot access '/nonexistent'\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		last, err := readLastCommand(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.exitStatus != 2 || last.command != "ls /nonexistent" || !strings.Contains(last.stderr, "cannot access") {
+			t.Errorf("unexpected capture %+v", last)
+		}
+	})
+
+	t.Run("keeps multi-line commands and works without stderr", func(t *testing.T) {
+		path := filepath.Join(dir, "last-2")
+		if err := os.WriteFile(path, []byte("1\nfor f in *; do\n  cat $f\ndone\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		last, err := readLastCommand(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.command != "for f in *; do\n  cat $f\ndone" || last.stderr != "" {
+			t.Errorf("unexpected capture %+v", last)
+		}
+	})
+
+	t.Run("explains how to set up capture", func(t *testing.T) {
+		if _, err := readLastCommand(""); err == nil || !strings.Contains(err.Error(), "shell-init") {
+			t.Errorf("expected a pointer to shell-init, got %v", err)
+		}
+		if _, err := readLastCommand(filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "no command") {
+			t.Errorf("expected a no-command error, got %v", err)
+		}
+	})
+
+	t.Run("refuses a capture file others could have written", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("the shell integration doesn't run on Windows")
+		}
+		shared := filepath.Join(t.TempDir(), "shared")
+		if err := os.Mkdir(shared, 0700); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(shared, "last-4")
+		if err := os.WriteFile(path, []byte("0\nls\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(shared, 0777); err != nil { // #nosec G302 - a directory shared with other users, as the test needs
+			t.Fatal(err)
+		}
+		if _, err := readLastCommand(path); err == nil || !strings.Contains(err.Error(), "other users can write") {
+			t.Errorf("expected a world-writable directory to be refused, got %v", err)
+		}
+
+		link := filepath.Join(dir, "last-5")
+		if err := os.Symlink(path, link); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readLastCommand(link); err == nil || !strings.Contains(err.Error(), "isn't a regular file you own") {
+			t.Errorf("expected a symlink to be refused, got %v", err)
+		}
+	})
+
+	t.Run("rejects a malformed file", func(t *testing.T) {
+		path := filepath.Join(dir, "last-3")
+		if err := os.WriteFile(path, []byte("make test\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readLastCommand(path); err == nil || !strings.Contains(err.Error(), "malformed") {
+			t.Errorf("expected a malformed error, got %v", err)
+		}
+	})
+}
+
+func TestTailBytes(t *testing.T) {
+	if got := tailBytes("short", 10); got != "short" {
+		t.Errorf("expected short input unchanged, got %q", got)
+	}
+
+	got := tailBytes("aaaa\nerror: héllo", 6)
+	if !strings.HasPrefix(got, "...(earlier output omitted)\n") || !strings.HasSuffix(got, "llo") {
+		t.Errorf("expected the end to be kept, got %q", got)
+	}
+	if strings.ContainsRune(got, '�') {
+		t.Errorf("expected no broken characters, got %q", got)
+	}
+}
+
+func TestExplainPrompt(t *testing.T) {
+	failed := explainPrompt(lastCommand{command: "go test ./...", exitStatus: 1, stderr: "FAIL\n"}, "/src/app")
+	for _, want := range []string{"exit status 1", "/src/app", "<command>\ngo test ./...\n</command>", "<stderr>\nFAIL\n</stderr>"} {
+		if !strings.Contains(failed, want) {
+			t.Errorf("expected %q in prompt:\n%s", want, failed)
+		}
+	}
+
+	succeeded := explainPrompt(lastCommand{command: "git status"}, "")
+	if !strings.Contains(succeeded, "succeeded") || !strings.Contains(succeeded, "wasn't captured") {
+		t.Errorf("unexpected prompt for a successful command:\n%s", succeeded)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// faultKind is a failure the hidden --inject-fault flag simulates in
+// Bedrock's responses, to check how chat and prompt cope with it without
+// waiting for it to happen for real.
+type faultKind string
+
+const (
+	// faultThrottle fails a request with a ThrottlingException.
+	faultThrottle faultKind = "throttle"
+	// faultDisconnect ends a response stream partway through, without its
+	// stop reason, as a dropped connection does.
+	faultDisconnect faultKind = "disconnect"
+	// faultSlow pauses before each event of a response.
+	faultSlow faultKind = "slow"
+)
+
+const (
+	// defaultFaultRate is the share of requests a fault is injected into.
+	defaultFaultRate = 0.5
+	// defaultFaultDelay bounds each pause with slow.
+	defaultFaultDelay = 500 * time.Millisecond
+	// maxEventsBeforeDisconnect bounds how far into a response a
+	// disconnect comes.
+	maxEventsBeforeDisconnect = 12
+)
+
+// injectedFaults is set from --inject-fault; nil means no faults.
+var injectedFaults *faultInjector
+
+// faultInjector wraps a converseStreamFunc, injecting its fault into a
+// random share of the requests sent through it. It's safe to share between
+// the goroutines of prompt --compare.
+type faultInjector struct {
+	kind     faultKind
+	rate     float64
+	maxDelay time.Duration
+	// log is told about each fault injected.
+	log io.Writer
+
+	// mu guards rng, which isn't safe for concurrent use, and log.
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// parseFault returns the injector for an --inject-fault value, or nil for
+// an empty one.
+func parseFault(value string) (*faultInjector, error) {
+	kind := faultKind(strings.ToLower(strings.TrimSpace(value)))
+	switch kind {
+	case "":
+		return nil, nil
+	case faultThrottle, faultDisconnect, faultSlow:
+	default:
+		return nil, fmt.Errorf("invalid --inject-fault %q: use throttle, disconnect or slow", value)
+	}
+	return &faultInjector{
+		kind:     kind,
+		rate:     defaultFaultRate,
+		maxDelay: defaultFaultDelay,
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())), // #nosec G404 - not security sensitive
+		log:      stderr,
+	}, nil
+}
+
+// wrap returns send with faults injected. A nil injector returns send
+// unchanged.
+func (f *faultInjector) wrap(send converseStreamFunc) converseStreamFunc {
+	if f == nil {
+		return send
+	}
+	return func(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		if f.float64() >= f.rate {
+			return send(ctx, input)
+		}
+
+		if f.kind == faultThrottle {
+			f.logf("throttled the request")
+			return nil, &types.ThrottlingException{Message: aws.String("Too many requests, please wait before trying again. (injected by --inject-fault)")}
+		}
+
+		events, err := send(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		cutAfter := -1
+		if f.kind == faultDisconnect {
+			cutAfter = 1 + f.intN(maxEventsBeforeDisconnect)
+		} else {
+			f.logf("slowing the response")
+		}
+
+		out := make(chan types.ConverseStreamOutput)
+		go func() {
+			// once out is closed, drain what's left so the sender isn't left
+			// blocked
+			defer func() {
+				for range events {
+				}
+			}()
+			defer close(out)
+
+			sent := 0
+			for event := range events {
+				if sent == cutAfter {
+					f.logf("dropped the connection after %d events", sent)
+					return
+				}
+				if f.kind == faultSlow {
+					select {
+					case <-time.After(time.Duration(f.int64N(int64(f.maxDelay) + 1))):
+					case <-ctx.Done():
+						return
+					}
+				}
+				select {
+				case out <- event:
+					sent++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+func (f *faultInjector) float64() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64()
+}
+
+func (f *faultInjector) intN(n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.IntN(n)
+}
+
+func (f *faultInjector) int64N(n int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Int64N(n)
+}
+
+func (f *faultInjector) logf(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintf(f.log, "\033[33m[inject-fault] "+format+"\033[0m\n", args...)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// testFaults returns an injector that injects kind into every request.
+func testFaults(kind faultKind) (*faultInjector, *bytes.Buffer) {
+	var log bytes.Buffer
+	return &faultInjector{kind: kind, rate: 1, maxDelay: time.Millisecond, rng: rand.New(rand.NewPCG(1, 2)), log: &log}, &log
+}
+
+func cannedSend(recorded []fixtureEvent) converseStreamFunc {
+	return func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		return streamEvents(recorded), nil
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	if f, err := parseFault(""); f != nil || err != nil {
+		t.Errorf("expected no injector without a fault, got %v (%v)", f, err)
+	}
+	if f, err := parseFault("Throttle"); err != nil || f.kind != faultThrottle {
+		t.Errorf("expected a throttle injector, got %v (%v)", f, err)
+	}
+	if _, err := parseFault("explode"); err == nil {
+		t.Error("expected an unknown fault to be rejected")
+	}
+}
+
+func TestFaultInjectorThrottle(t *testing.T) {
+	f, log := testFaults(faultThrottle)
+	called := false
+	send := f.wrap(func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		called = true
+		return nil, nil
+	})
+
+	if _, err := send(t.Context(), &bedrockruntime.ConverseStreamInput{}); !isThrottlingError(err) {
+		t.Errorf("expected a throttling error, got %v", err)
+	}
+	if called {
+		t.Error("expected the throttled request not to be sent")
+	}
+	if !strings.Contains(log.String(), "throttled") {
+		t.Errorf("expected the fault to be logged, got %q", log.String())
+	}
+}
+
+func TestFaultInjectorDisconnect(t *testing.T) {
+	var recorded []fixtureEvent
+	for range 2 * maxEventsBeforeDisconnect {
+		recorded = append(recorded, fixtureEvent{Text: "word "})
+	}
+	recorded = append(recorded, fixtureEvent{Stop: "end_turn"})
+
+	f, log := testFaults(faultDisconnect)
+	events, err := f.wrap(cannedSend(recorded))(t.Context(), &bedrockruntime.ConverseStreamInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _, stopReason, err := accumulateStream(t.Context(), events, discardStream, discardStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopReason != "" {
+		t.Errorf("expected the stop reason to be lost, got %q", stopReason)
+	}
+	if text := messageText(msg); len(text) >= len(recorded)*len("word ") {
+		t.Errorf("expected a partial reply, got %q", text)
+	}
+	if !strings.Contains(log.String(), "dropped the connection") {
+		t.Errorf("expected the fault to be logged, got %q", log.String())
+	}
+}
+
+func TestFaultInjectorSlow(t *testing.T) {
+	f, _ := testFaults(faultSlow)
+	events, err := f.wrap(cannedSend([]fixtureEvent{{Text: "all "}, {Text: "there"}, {Stop: "end_turn"}}))(t.Context(), &bedrockruntime.ConverseStreamInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _, stopReason, err := accumulateStream(t.Context(), events, discardStream, discardStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageText(msg) != "all there" || stopReason != types.StopReasonEndTurn {
+		t.Errorf("expected the whole reply, got %q (%s)", messageText(msg), stopReason)
+	}
+}
+
+func TestFaultInjectorNil(t *testing.T) {
+	var f *faultInjector
+	events, err := f.wrap(cannedSend([]fixtureEvent{{Text: "untouched"}}))(t.Context(), &bedrockruntime.ConverseStreamInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _, _, err := accumulateStream(t.Context(), events, discardStream, discardStream)
+	if err != nil || messageText(msg) != "untouched" {
+		t.Errorf("expected the reply to pass through, got %q (%v)", messageText(msg), err)
+	}
+}
+
+// TestFaultInjectorCompare shares one injector between the requests of
+// prompt --compare, as --inject-fault does; run with -race.
+func TestFaultInjectorCompare(t *testing.T) {
+	var recorded []fixtureEvent
+	for range 2 * maxEventsBeforeDisconnect {
+		recorded = append(recorded, fixtureEvent{Text: "word "})
+	}
+	recorded = append(recorded, fixtureEvent{Stop: "end_turn"})
+
+	var models []string
+	for i := range 8 {
+		models = append(models, fmt.Sprintf("model-%d", i))
+	}
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:  aws.String(models[0]),
+		Messages: []types.Message{userTextMessage("which is best?")},
+	}
+
+	for _, kind := range []faultKind{faultDisconnect, faultSlow} {
+		f, log := testFaults(kind)
+		results := compareModels(t.Context(), f.wrap(cannedSend(recorded)), input, models, reasoningFields(false, 0, ""))
+		if len(results) != len(models) {
+			t.Fatalf("%s: expected a result per model, got %d", kind, len(results))
+		}
+		for _, r := range results {
+			if r.err != nil {
+				t.Errorf("%s: unexpected error for %s: %v", kind, r.model, r.err)
+			}
+		}
+		if log.Len() == 0 {
+			t.Errorf("%s: expected the faults to be logged", kind)
+		}
+	}
+}
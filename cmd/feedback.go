@@ -0,0 +1,194 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+const thumbsUsage = "usage: /thumbs up|down [comment]"
+
+// feedbackCmd represents the feedback command
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback",
+	Short: "Work with the ratings given to replies with /thumbs",
+	Long: `Work with the ratings given to model replies in chat with /thumbs up or
+/thumbs down. Each rating is kept with the prompt and the reply it rates, so
+they can be exported to build evaluation sets or fine-tuning data.
+
+> chat-cli feedback export -o feedback.jsonl
+> chat-cli feedback export --rating down`,
+}
+
+// feedbackExportCmd represents the feedback export command
+var feedbackExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write every rated reply as JSON Lines",
+	Long: `Write every rated reply as JSON Lines, one object per rating with the prompt,
+the response, the rating (up or down), and the comment given with it, along
+with the model and chat it came from.
+
+> chat-cli feedback export -o feedback.jsonl`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		rating, err := cmd.Flags().GetString("rating")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if rating != "" && rating != repository.RatingUp && rating != repository.RatingDown {
+			log.Fatalf("--rating must be %s or %s", repository.RatingUp, repository.RatingDown)
+		}
+
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		repo, closeDB := openFeedbackRepository()
+		defer closeDB()
+
+		feedback, err := repo.List(cmd.Context(), rating)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if output == "" {
+			if err := writeFeedbackJSONL(os.Stdout, feedback); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		path, err := utils.ValidateOutputPath(output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(path) // #nosec G304 - the user chose where to export
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeFeedbackJSONL(f, feedback); err != nil {
+			_ = f.Close()
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d rated replies to %s\n", len(feedback), path)
+	},
+}
+
+// feedbackRecord is one line of feedback export.
+type feedbackRecord struct {
+	Prompt    string `json:"prompt"`
+	Response  string `json:"response"`
+	Rating    string `json:"rating"`
+	Comment   string `json:"comment,omitempty"`
+	Model     string `json:"model,omitempty"`
+	ChatID    string `json:"chat_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// writeFeedbackJSONL writes each rating as a line of JSON.
+func writeFeedbackJSONL(w io.Writer, feedback []repository.Feedback) error {
+	enc := json.NewEncoder(w)
+	for _, f := range feedback {
+		err := enc.Encode(feedbackRecord{
+			Prompt:    f.Prompt,
+			Response:  f.Response,
+			Rating:    f.Rating,
+			Comment:   f.Comment,
+			Model:     f.ModelID,
+			ChatID:    f.ChatId,
+			CreatedAt: f.Created,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func openFeedbackRepository() (*repository.FeedbackRepository, func()) {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+
+	database, err := openDatabase(fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return repository.NewFeedbackRepository(database), func() {
+		if err := database.Close(); err != nil {
+			log.Printf("Warning: failed to close database: %v", err)
+		}
+	}
+}
+
+// runThumbsCommand rates the latest reply, saving it with the message that
+// prompted it for feedback export.
+func runThumbsCommand(s *chatSession, args string) error {
+	rating, comment, _ := strings.Cut(args, " ")
+	rating = strings.ToLower(rating)
+	if rating != repository.RatingUp && rating != repository.RatingDown {
+		return errors.New(thumbsUsage)
+	}
+	if s.feedback == nil {
+		return errors.New("feedback is saved in chat history, which this session doesn't have")
+	}
+
+	n := lastUserTurn(s.input.Messages)
+	response := lastReplyText(s.input.Messages)
+	if n < 0 || n == len(s.input.Messages)-1 || response == "" {
+		return errors.New("there's no reply to rate yet")
+	}
+
+	feedback := repository.Feedback{
+		ChatId:    s.chatId,
+		MessageID: s.lastReplyID,
+		ModelID:   aws.ToString(s.input.ModelId),
+		Rating:    rating,
+		Comment:   strings.TrimSpace(comment),
+		Prompt:    strings.TrimSpace(messageText(s.input.Messages[n])),
+		Response:  response,
+	}
+	if err := s.feedback.Create(s.context(), &feedback); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "\033[90mSaved thumbs %s for the last reply\033[0m\n", rating)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(feedbackCmd)
+	feedbackCmd.AddCommand(feedbackExportCmd)
+	feedbackExportCmd.Flags().StringP("output", "o", "", "write to this file instead of stdout")
+	feedbackExportCmd.Flags().String("rating", "", "only export replies rated up or down")
+
+	registerSlashCommand(&slashCommand{
+		name:        "thumbs",
+		usage:       "up|down [comment]",
+		description: "Rate the last reply, with an optional comment, for chat-cli feedback export",
+		run:         runThumbsCommand,
+	})
+}
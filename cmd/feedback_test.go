@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestThumbsCommand(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := newTestChatSession()
+	s.input.ModelId = aws.String("model-a")
+	s.chatRepo = repository.NewChatRepository(database)
+	s.feedback = repository.NewFeedbackRepository(database)
+	s.record("User", "hello")
+	s.recordReply("hi there", types.StopReasonEndTurn, 0)
+
+	for _, args := range []string{"", "sideways", "UP"} {
+		if _, err := dispatchSlashCommand(s, "/thumbs "+args); (err == nil) != (args == "UP") {
+			t.Errorf("/thumbs %s: unexpected error %v", args, err)
+		}
+	}
+	if _, err := dispatchSlashCommand(s, "/thumbs down too  chirpy"); err != nil {
+		t.Fatal(err)
+	}
+
+	feedback, err := s.feedback.List(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feedback) != 2 {
+		t.Fatalf("expected 2 ratings, got %+v", feedback)
+	}
+	down := feedback[1]
+	if down.Rating != "down" || down.Comment != "too  chirpy" || down.Prompt != "hello" || down.Response != "hi there" || down.ModelID != "model-a" || down.MessageID != s.lastReplyID {
+		t.Errorf("unexpected rating %+v", down)
+	}
+
+	var out bytes.Buffer
+	if err := writeFeedbackJSONL(&out, feedback); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a line per rating, got %q", out.String())
+	}
+	var record feedbackRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Rating != "up" || record.Prompt != "hello" || record.Response != "hi there" || record.ChatID != s.chatId || strings.Contains(lines[0], `"comment"`) {
+		t.Errorf("unexpected record %s", lines[0])
+	}
+
+	t.Run("needs a reply", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.feedback = repository.NewFeedbackRepository(database)
+		s.input.Messages = s.input.Messages[:1]
+		if _, err := dispatchSlashCommand(s, "/thumbs up"); err == nil {
+			t.Error("expected an error with no reply")
+		}
+	})
+}
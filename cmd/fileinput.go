@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/documents"
+)
+
+const (
+	// fileChunkTokens is the size --file text is split into, so each part
+	// of a long document is labelled and can be referred to on its own.
+	fileChunkTokens = 2000
+	// maxFileTokens caps the text --file adds to a request.
+	maxFileTokens = 100_000
+)
+
+// loadFileChunks extracts the text of each --file, screens it with guard,
+// and splits it into chunks, each wrapped in a <file> tag naming the file
+// and part.
+func loadFileChunks(paths []string, guard injectionGuard) ([]string, int, error) {
+	var chunks []string
+	tokens := 0
+	for _, path := range paths {
+		doc, err := documents.Load(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		doc.Text = guard.screen(doc.Path, doc.Text)
+		tokens += estimateTokens(doc.Text)
+
+		for _, c := range doc.Chunks(fileChunkTokens) {
+			chunks = append(chunks, fmt.Sprintf("<file name=%q part=\"%d of %d\">\n%s\n</file>", filepath.Base(doc.Path), c.Part, c.Parts, c.Text))
+		}
+	}
+
+	if tokens > maxFileTokens {
+		return nil, 0, fmt.Errorf("files add about %d tokens, more than the %d allowed; attach fewer or shorter files", tokens, maxFileTokens)
+	}
+	return chunks, tokens, nil
+}
+
+// buildFileContentBlocks puts each chunk in its own text block, followed
+// by a cache point so a follow-up about the same files can reuse them.
+func buildFileContentBlocks(chunks []string) []types.ContentBlock {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	blocks := make([]types.ContentBlock, 0, len(chunks)+1)
+	for _, chunk := range chunks {
+		blocks = append(blocks, &types.ContentBlockMemberText{Value: chunk})
+	}
+	return append(blocks, &types.ContentBlockMemberCachePoint{Value: types.CachePointBlock{Type: types.CachePointTypeDefault}})
+}
+
+// withFileContext appends the attached files to systemPrompt, so they stay
+// in view for the whole chat.
+func withFileContext(systemPrompt string, chunks []string) string {
+	if len(chunks) == 0 {
+		return systemPrompt
+	}
+
+	section := "The user has attached the following files:\n\n" + strings.Join(chunks, "\n\n")
+	if systemPrompt == "" {
+		return section
+	}
+	return systemPrompt + "\n\n" + section
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestLoadFileChunks(t *testing.T) {
+	dir := t.TempDir()
+	short := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(short, []byte("Ship on Friday.\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	long := filepath.Join(dir, "spec.txt")
+	if err := os.WriteFile(long, []byte(strings.Repeat(strings.Repeat("spec ", 400)+"\n\n", 10)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("labels each chunk with its file and part", func(t *testing.T) {
+		chunks, tokens, err := loadFileChunks([]string{short, long}, injectionGuard{mode: injectionOff})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunks[0] != "<file name=\"notes.md\" part=\"1 of 1\">\nShip on Friday.\n</file>" {
+			t.Errorf("unexpected first chunk %q", chunks[0])
+		}
+		if len(chunks) < 3 || !strings.HasPrefix(chunks[1], `<file name="spec.txt" part="1 of `) {
+			t.Errorf("expected spec.txt to be split into labelled parts, got %d chunks", len(chunks))
+		}
+		if tokens < 5000 {
+			t.Errorf("expected a token estimate covering both files, got %d", tokens)
+		}
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		chunks, _, err := loadFileChunks(nil, injectionGuard{mode: injectionOff})
+		if err != nil || chunks != nil {
+			t.Errorf("expected nothing, got %v, %v", chunks, err)
+		}
+	})
+
+	t.Run("too much text", func(t *testing.T) {
+		huge := filepath.Join(dir, "huge.txt")
+		if err := os.WriteFile(huge, []byte(strings.Repeat("x", maxFileTokens*4+100)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := loadFileChunks([]string{huge}, injectionGuard{mode: injectionOff}); err == nil || !strings.Contains(err.Error(), "tokens") {
+			t.Errorf("expected a size error, got %v", err)
+		}
+	})
+
+	t.Run("unsupported file", func(t *testing.T) {
+		if _, _, err := loadFileChunks([]string{filepath.Join(dir, "missing.xlsx")}, injectionGuard{mode: injectionOff}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestBuildFileContentBlocks(t *testing.T) {
+	if blocks := buildFileContentBlocks(nil); blocks != nil {
+		t.Errorf("expected no blocks, got %d", len(blocks))
+	}
+
+	blocks := buildFileContentBlocks([]string{"a", "b"})
+	if len(blocks) != 3 {
+		t.Fatalf("expected two chunks and a cache point, got %d blocks", len(blocks))
+	}
+	if _, ok := blocks[2].(*types.ContentBlockMemberCachePoint); !ok {
+		t.Errorf("expected a trailing cache point, got %T", blocks[2])
+	}
+}
+
+func TestWithFileContext(t *testing.T) {
+	if got := withFileContext("Be brief.", nil); got != "Be brief." {
+		t.Errorf("expected the prompt unchanged, got %q", got)
+	}
+	got := withFileContext("Be brief.", []string{"<file>a</file>", "<file>b</file>"})
+	if !strings.HasPrefix(got, "Be brief.\n\nThe user has attached") || !strings.HasSuffix(got, "<file>a</file>\n\n<file>b</file>") {
+		t.Errorf("unexpected prompt %q", got)
+	}
+	if got := withFileContext("", []string{"<file>a</file>"}); !strings.HasPrefix(got, "The user has attached") {
+		t.Errorf("unexpected prompt %q", got)
+	}
+}
@@ -0,0 +1,379 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	agentdocument "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/document"
+	agentruntimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/chat-cli/chat-cli/utils"
+	"github.com/spf13/cobra"
+)
+
+// flowDocument is the content of a flow input or output.
+type flowDocument struct {
+	Document json.RawMessage `json:"document"`
+}
+
+type flowInput struct {
+	NodeName       string       `json:"nodeName"`
+	NodeOutputName string       `json:"nodeOutputName"`
+	Content        flowDocument `json:"content"`
+}
+
+// flowOutputEvent is emitted each time an output node produces a value.
+type flowOutputEvent struct {
+	NodeName string       `json:"nodeName"`
+	NodeType string       `json:"nodeType"`
+	Content  flowDocument `json:"content"`
+}
+
+type flowTraceField struct {
+	NodeInputName  string       `json:"nodeInputName,omitempty"`
+	NodeOutputName string       `json:"nodeOutputName,omitempty"`
+	Content        flowDocument `json:"content"`
+}
+
+type flowNodeTrace struct {
+	NodeName string           `json:"nodeName"`
+	Fields   []flowTraceField `json:"fields"`
+}
+
+type flowConditionTrace struct {
+	NodeName            string `json:"nodeName"`
+	SatisfiedConditions []struct {
+		ConditionName string `json:"conditionName"`
+	} `json:"satisfiedConditions"`
+}
+
+// flowTrace is one step of a flow's execution, reported with --trace.
+type flowTrace struct {
+	NodeInputTrace           *flowNodeTrace      `json:"nodeInputTrace,omitempty"`
+	NodeOutputTrace          *flowNodeTrace      `json:"nodeOutputTrace,omitempty"`
+	ConditionNodeResultTrace *flowConditionTrace `json:"conditionNodeResultTrace,omitempty"`
+}
+
+// flowEvents receives the events of an InvokeFlow response stream as they
+// arrive. Any handler may be nil.
+type flowEvents struct {
+	OnOutput     func(flowOutputEvent)
+	OnTrace      func(flowTrace)
+	OnCompletion func(reason string)
+	// OnInputRequest is called when a multi-turn flow asks for more input.
+	OnInputRequest func(flowOutputEvent)
+}
+
+// flowClient calls InvokeFlow on the Bedrock Agents runtime.
+type flowClient struct {
+	runtime *bedrockagentruntime.Client
+}
+
+func newFlowClient(cfg aws.Config) *flowClient {
+	return &flowClient{bedrockagentruntime.NewFromConfig(cfg)}
+}
+
+// invokeFlow runs the flow alias with input sent to inputNode's output
+// named inputName, delivering node outputs (and traces, if enableTrace) to
+// events while the flow runs. Flows can run for a long time, so only ctx
+// bounds the request.
+func (c *flowClient) invokeFlow(ctx context.Context, flowID, aliasID, inputNode, inputName string, input json.RawMessage, enableTrace bool, events flowEvents) error {
+	var value any
+	if err := json.Unmarshal(input, &value); err != nil {
+		return fmt.Errorf("error encoding flow input: %w", err)
+	}
+
+	out, err := c.runtime.InvokeFlow(ctx, &bedrockagentruntime.InvokeFlowInput{
+		FlowIdentifier:      aws.String(flowID),
+		FlowAliasIdentifier: aws.String(aliasID),
+		Inputs: []agentruntimetypes.FlowInput{{
+			NodeName:       aws.String(inputNode),
+			NodeOutputName: aws.String(inputName),
+			Content:        &agentruntimetypes.FlowInputContentMemberDocument{Value: agentdocument.NewLazyDocument(value)},
+		}},
+		EnableTrace: aws.Bool(enableTrace),
+	})
+	if err != nil {
+		return err
+	}
+
+	stream := out.GetStream()
+	defer func() {
+		_ = stream.Close()
+	}()
+	for event := range stream.Events() {
+		dispatchFlowEvent(event, events)
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("flow failed: %w", err)
+	}
+	return nil
+}
+
+func dispatchFlowEvent(event agentruntimetypes.FlowResponseStream, events flowEvents) {
+	switch e := event.(type) {
+	case *agentruntimetypes.FlowResponseStreamMemberFlowOutputEvent:
+		if events.OnOutput != nil {
+			var doc json.RawMessage
+			if content, ok := e.Value.Content.(*agentruntimetypes.FlowOutputContentMemberDocument); ok {
+				doc = agentDocumentJSON(content.Value)
+			}
+			events.OnOutput(flowOutputEvent{NodeName: aws.ToString(e.Value.NodeName), NodeType: string(e.Value.NodeType), Content: flowDocument{Document: doc}})
+		}
+	case *agentruntimetypes.FlowResponseStreamMemberFlowMultiTurnInputRequestEvent:
+		if events.OnInputRequest != nil {
+			var doc json.RawMessage
+			if content, ok := e.Value.Content.(*agentruntimetypes.FlowMultiTurnInputContentMemberDocument); ok {
+				doc = agentDocumentJSON(content.Value)
+			}
+			events.OnInputRequest(flowOutputEvent{NodeName: aws.ToString(e.Value.NodeName), NodeType: string(e.Value.NodeType), Content: flowDocument{Document: doc}})
+		}
+	case *agentruntimetypes.FlowResponseStreamMemberFlowTraceEvent:
+		if events.OnTrace != nil {
+			events.OnTrace(flowTraceOf(e.Value.Trace))
+		}
+	case *agentruntimetypes.FlowResponseStreamMemberFlowCompletionEvent:
+		if events.OnCompletion != nil {
+			events.OnCompletion(string(e.Value.CompletionReason))
+		}
+	}
+	// other event types are informational and safe to skip
+}
+
+// flowTraceOf converts the trace steps --trace shows; others are left
+// empty.
+func flowTraceOf(trace agentruntimetypes.FlowTrace) flowTrace {
+	var converted flowTrace
+	switch t := trace.(type) {
+	case *agentruntimetypes.FlowTraceMemberNodeInputTrace:
+		node := &flowNodeTrace{NodeName: aws.ToString(t.Value.NodeName)}
+		for _, f := range t.Value.Fields {
+			var doc json.RawMessage
+			if content, ok := f.Content.(*agentruntimetypes.FlowTraceNodeInputContentMemberDocument); ok {
+				doc = agentDocumentJSON(content.Value)
+			}
+			node.Fields = append(node.Fields, flowTraceField{NodeInputName: aws.ToString(f.NodeInputName), Content: flowDocument{Document: doc}})
+		}
+		converted.NodeInputTrace = node
+	case *agentruntimetypes.FlowTraceMemberNodeOutputTrace:
+		node := &flowNodeTrace{NodeName: aws.ToString(t.Value.NodeName)}
+		for _, f := range t.Value.Fields {
+			var doc json.RawMessage
+			if content, ok := f.Content.(*agentruntimetypes.FlowTraceNodeOutputContentMemberDocument); ok {
+				doc = agentDocumentJSON(content.Value)
+			}
+			node.Fields = append(node.Fields, flowTraceField{NodeOutputName: aws.ToString(f.NodeOutputName), Content: flowDocument{Document: doc}})
+		}
+		converted.NodeOutputTrace = node
+	case *agentruntimetypes.FlowTraceMemberConditionNodeResultTrace:
+		condition := &flowConditionTrace{NodeName: aws.ToString(t.Value.NodeName)}
+		for _, c := range t.Value.SatisfiedConditions {
+			condition.SatisfiedConditions = append(condition.SatisfiedConditions, struct {
+				ConditionName string `json:"conditionName"`
+			}{aws.ToString(c.ConditionName)})
+		}
+		converted.ConditionNodeResultTrace = condition
+	}
+	return converted
+}
+
+// agentDocumentJSON returns a document from a Bedrock Agents response as JSON,
+// or nil if it has none.
+func agentDocumentJSON(doc agentdocument.Interface) json.RawMessage {
+	if doc == nil {
+		return nil
+	}
+	data, err := doc.MarshalSmithyDocument()
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// flowDocumentText renders a document for display: strings as-is, other
+// values as indented JSON.
+func flowDocumentText(doc json.RawMessage) string {
+	var s string
+	if json.Unmarshal(doc, &s) == nil {
+		return s
+	}
+
+	var v any
+	if json.Unmarshal(doc, &v) != nil {
+		return string(doc)
+	}
+	pretty, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(doc)
+	}
+	return string(pretty)
+}
+
+// flowTraceLines renders a trace step as one line per node input, output,
+// or condition result.
+func flowTraceLines(trace flowTrace) []string {
+	var lines []string
+	if t := trace.NodeInputTrace; t != nil {
+		for _, f := range t.Fields {
+			lines = append(lines, fmt.Sprintf("→ %s.%s %s", t.NodeName, f.NodeInputName, oneLine(flowDocumentText(f.Content.Document))))
+		}
+	}
+	if t := trace.NodeOutputTrace; t != nil {
+		for _, f := range t.Fields {
+			lines = append(lines, fmt.Sprintf("← %s.%s %s", t.NodeName, f.NodeOutputName, oneLine(flowDocumentText(f.Content.Document))))
+		}
+	}
+	if t := trace.ConditionNodeResultTrace; t != nil {
+		names := make([]string, 0, len(t.SatisfiedConditions))
+		for _, c := range t.SatisfiedConditions {
+			names = append(names, c.ConditionName)
+		}
+		lines = append(lines, fmt.Sprintf("? %s matched %s", t.NodeName, strings.Join(names, ", ")))
+	}
+	return lines
+}
+
+// flowInputDocument encodes the input for a flow: as a JSON string, or,
+// with asJSON, as the JSON value it already is.
+func flowInputDocument(input string, asJSON bool) (json.RawMessage, error) {
+	if asJSON {
+		if !json.Valid([]byte(input)) {
+			return nil, fmt.Errorf("--json-input is set but the input is not valid JSON")
+		}
+		return json.RawMessage(input), nil
+	}
+	return json.Marshal(input)
+}
+
+// flowCmd represents the flow command
+var flowCmd = &cobra.Command{
+	Use:   "flow",
+	Short: "Run Amazon Bedrock Flows",
+}
+
+// flowInvokeCmd represents the flow invoke command
+var flowInvokeCmd = &cobra.Command{
+	Use:   "invoke [input]",
+	Short: "Invoke a flow and stream its node outputs",
+	Long: `Invoke a Bedrock Flow alias with an input and print each output node's result as it arrives.
+
+The input can be given as an argument or piped in. It's sent as a string unless --json-input is set.
+
+> chat-cli flow invoke --flow-id FLOW12345 --alias ALIAS12345 "Summarize our Q3 results"
+> cat order.json | chat-cli flow invoke --flow-id FLOW12345 --alias ALIAS12345 --json-input --trace`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		flowID, err := cmd.Flags().GetString("flow-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		aliasID, err := cmd.Flags().GetString("alias")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		inputNode, err := cmd.Flags().GetString("input-node")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		inputName, err := cmd.Flags().GetString("input-name")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		jsonInput, err := cmd.Flags().GetBool("json-input")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		trace, err := cmd.Flags().GetBool("trace")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		var input string
+		if len(args) > 0 {
+			input = args[0]
+		} else if input, err = utils.ReadPipedStdin(); err != nil {
+			log.Fatalf("unable to read input: %v", err)
+		}
+		if strings.TrimSpace(input) == "" {
+			log.Fatal("no input given: pass it as an argument or pipe it in")
+		}
+
+		document, err := flowInputDocument(input, jsonInput)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		var completion string
+		events := flowEvents{
+			OnOutput: func(output flowOutputEvent) {
+				// node names go to stderr so stdout stays just the results
+				fmt.Fprintf(stderr, "\033[90m[%s]\033[0m\n", output.NodeName)
+				fmt.Println(flowDocumentText(output.Content.Document))
+			},
+			OnCompletion: func(reason string) {
+				completion = reason
+			},
+			OnInputRequest: func(request flowOutputEvent) {
+				fmt.Fprintf(stderr, "\033[90m[%s asks]\033[0m %s\n", request.NodeName, flowDocumentText(request.Content.Document))
+			},
+		}
+		if trace {
+			events.OnTrace = func(t flowTrace) {
+				for _, line := range flowTraceLines(t) {
+					fmt.Fprintf(stderr, "\033[90m%s\033[0m\n", line)
+				}
+			}
+		}
+
+		if err := newFlowClient(cfg).invokeFlow(cmd.Context(), flowID, aliasID, inputNode, inputName, document, trace, events); err != nil {
+			log.Fatalf("error invoking flow: %v", err)
+		}
+
+		switch completion {
+		case "SUCCESS", "":
+		case "INPUT_REQUIRED":
+			log.Fatal("the flow is waiting for more input; multi-turn flows can't be continued from the CLI yet")
+		default:
+			log.Fatalf("flow finished with status %s", completion)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(flowCmd)
+	flowCmd.AddCommand(flowInvokeCmd)
+
+	flowInvokeCmd.Flags().String("flow-id", "", "ID or ARN of the flow to invoke")
+	flowInvokeCmd.Flags().String("alias", "", "ID or ARN of the flow alias to invoke")
+	flowInvokeCmd.Flags().String("input-node", "FlowInputNode", "name of the flow's input node")
+	flowInvokeCmd.Flags().String("input-name", "document", "name of the input node's output to send the input to")
+	flowInvokeCmd.Flags().Bool("json-input", false, "send the input as a JSON value instead of a string")
+	flowInvokeCmd.Flags().Bool("trace", false, "show each node's inputs and outputs as the flow runs")
+	for _, name := range []string{"flow-id", "alias"} {
+		if err := flowInvokeCmd.MarkFlagRequired(name); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+)
+
+// encodeFlowStream builds an InvokeFlow response body from (event type,
+// payload) pairs; an event type starting with "!" is sent as an exception.
+func encodeFlowStream(t *testing.T, events ...[2]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	encoder := eventstream.NewEncoder()
+	for _, e := range events {
+		var msg eventstream.Message
+		if name, ok := strings.CutPrefix(e[0], "!"); ok {
+			msg.Headers.Set(":message-type", eventstream.StringValue("exception"))
+			msg.Headers.Set(":exception-type", eventstream.StringValue(name))
+		} else {
+			msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+			msg.Headers.Set(":event-type", eventstream.StringValue(e[0]))
+		}
+		msg.Payload = []byte(e[1])
+		if err := encoder.Encode(&buf, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// newStreamTestConfig returns a config whose clients get body back as an
+// event stream for every request.
+func newStreamTestConfig(t *testing.T, body []byte) aws.Config {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return testAWSConfig(server)
+}
+
+func TestFlowStream(t *testing.T) {
+	invoke := func(t *testing.T, stream []byte, events flowEvents) error {
+		client := newFlowClient(newStreamTestConfig(t, stream))
+		return client.invokeFlow(t.Context(), "FLOW1", "ALIAS1", "FlowInputNode", "document", json.RawMessage(`"Q3"`), true, events)
+	}
+
+	t.Run("delivers outputs, traces, and completion in order", func(t *testing.T) {
+		stream := encodeFlowStream(t,
+			[2]string{"flowTraceEvent", `{"trace":{"nodeInputTrace":{"nodeName":"Prompt_1","fields":[{"nodeInputName":"topic","content":{"document":"Q3"}}]}}}`},
+			[2]string{"flowOutputEvent", `{"nodeName":"FlowOutputNode","nodeType":"FlowOutputNode","content":{"document":"Revenue grew."}}`},
+			[2]string{"someFutureEvent", `{}`},
+			[2]string{"flowCompletionEvent", `{"completionReason":"SUCCESS"}`},
+		)
+
+		var got []string
+		err := invoke(t, stream, flowEvents{
+			OnOutput: func(o flowOutputEvent) {
+				got = append(got, "output "+o.NodeName+" "+flowDocumentText(o.Content.Document))
+			},
+			OnTrace: func(tr flowTrace) {
+				got = append(got, flowTraceLines(tr)...)
+			},
+			OnCompletion: func(reason string) {
+				got = append(got, "done "+reason)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"→ Prompt_1.topic Q3", "output FlowOutputNode Revenue grew.", "done SUCCESS"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("surfaces exceptions", func(t *testing.T) {
+		stream := encodeFlowStream(t, [2]string{"!validationException", `{"message":"Flow alias not found"}`})
+		err := invoke(t, stream, flowEvents{})
+		if err == nil || !strings.Contains(err.Error(), "Flow alias not found") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("nil handlers are skipped", func(t *testing.T) {
+		stream := encodeFlowStream(t, [2]string{"flowOutputEvent", `{"nodeName":"Out","content":{"document":1}}`})
+		if err := invoke(t, stream, flowEvents{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestInvokeFlow(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &gotBody)
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(encodeFlowStream(t,
+			[2]string{"flowOutputEvent", `{"nodeName":"FlowOutputNode","content":{"document":{"total":3}}}`},
+			[2]string{"flowCompletionEvent", `{"completionReason":"SUCCESS"}`},
+		))
+	}))
+	defer server.Close()
+
+	client := newFlowClient(testAWSConfig(server))
+	document, err := flowInputDocument(`{"items":[1,2]}`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outputs []string
+	err = client.invokeFlow(context.Background(), "FLOW1", "ALIAS1", "FlowInputNode", "document", document, true, flowEvents{
+		OnOutput: func(o flowOutputEvent) { outputs = append(outputs, flowDocumentText(o.Content.Document)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/flows/FLOW1/aliases/ALIAS1" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if gotBody["enableTrace"] != true {
+		t.Errorf("expected trace to be requested, got %v", gotBody)
+	}
+	inputs, _ := gotBody["inputs"].([]any)
+	if len(inputs) != 1 || !strings.Contains(mustJSON(t, inputs[0]), `"document":{"items":[1,2]}`) {
+		t.Errorf("expected the JSON input to be sent as-is, got %v", gotBody["inputs"])
+	}
+	if len(outputs) != 1 || outputs[0] != "{\n  \"total\": 3\n}" {
+		t.Errorf("unexpected outputs %q", outputs)
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFlowInputDocument(t *testing.T) {
+	doc, err := flowInputDocument(`say "hi"`, false)
+	if err != nil || string(doc) != `"say \"hi\""` {
+		t.Errorf("expected a JSON string, got %s, %v", doc, err)
+	}
+
+	if _, err := flowInputDocument("not json", true); err == nil {
+		t.Error("expected invalid JSON to be rejected with --json-input")
+	}
+}
+
+func TestFlowTraceLines(t *testing.T) {
+	var trace flowTrace
+	if err := json.Unmarshal([]byte(`{
+		"nodeOutputTrace": {"nodeName":"Prompt_1","fields":[{"nodeOutputName":"modelCompletion","content":{"document":"All good"}}]},
+		"conditionNodeResultTrace": {"nodeName":"Check","satisfiedConditions":[{"conditionName":"isPositive"}]}
+	}`), &trace); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"← Prompt_1.modelCompletion All good", "? Check matched isPositive"}
+	if got := flowTraceLines(trace); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// draftGuardrailVersion is the working version of a guardrail, used when
+// --guardrail-id is given without --guardrail-version.
+const draftGuardrailVersion = "DRAFT"
+
+// buildGuardrailStreamConfig returns the guardrail to apply to
+// ConverseStream requests, or nil if no guardrail is set.
+func buildGuardrailStreamConfig(id, version string) *types.GuardrailStreamConfiguration {
+	if id == "" {
+		return nil
+	}
+	if version == "" {
+		version = draftGuardrailVersion
+	}
+	return &types.GuardrailStreamConfiguration{
+		GuardrailIdentifier: aws.String(id),
+		GuardrailVersion:    aws.String(version),
+	}
+}
+
+// guardrailConfigForConverse converts a streaming guardrail configuration
+// for a Converse request.
+func guardrailConfigForConverse(c *types.GuardrailStreamConfiguration) *types.GuardrailConfiguration {
+	if c == nil {
+		return nil
+	}
+	return &types.GuardrailConfiguration{
+		GuardrailIdentifier: c.GuardrailIdentifier,
+		GuardrailVersion:    c.GuardrailVersion,
+	}
+}
+
+// guardrailNotice explains a response the guardrail stepped in on, or
+// returns "" if it didn't. When a guardrail intervenes, Bedrock replaces the
+// model's output with the guardrail's blocked message, which would
+// otherwise read as the model's own answer.
+func guardrailNotice(stopReason types.StopReason, c *types.GuardrailStreamConfiguration) string {
+	if stopReason != types.StopReasonGuardrailIntervened {
+		return ""
+	}
+	id := "the guardrail"
+	if c != nil {
+		id = fmt.Sprintf("guardrail %s (version %s)", aws.ToString(c.GuardrailIdentifier), aws.ToString(c.GuardrailVersion))
+	}
+	return fmt.Sprintf("Blocked by %s: the message above is the guardrail's, not the model's.", id)
+}
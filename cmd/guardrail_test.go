@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestBuildGuardrailStreamConfig(t *testing.T) {
+	if c := buildGuardrailStreamConfig("", "3"); c != nil {
+		t.Errorf("expected no guardrail without an id, got %+v", c)
+	}
+
+	c := buildGuardrailStreamConfig("gr-123", "")
+	if aws.ToString(c.GuardrailIdentifier) != "gr-123" || aws.ToString(c.GuardrailVersion) != "DRAFT" {
+		t.Errorf("expected gr-123 at DRAFT, got %+v", c)
+	}
+
+	converse := guardrailConfigForConverse(buildGuardrailStreamConfig("gr-123", "2"))
+	if aws.ToString(converse.GuardrailIdentifier) != "gr-123" || aws.ToString(converse.GuardrailVersion) != "2" {
+		t.Errorf("expected gr-123 version 2, got %+v", converse)
+	}
+	if guardrailConfigForConverse(nil) != nil {
+		t.Error("expected no Converse guardrail without one set")
+	}
+}
+
+func TestGuardrailNotice(t *testing.T) {
+	c := buildGuardrailStreamConfig("gr-123", "2")
+
+	if notice := guardrailNotice(types.StopReasonEndTurn, c); notice != "" {
+		t.Errorf("expected no notice for a normal response, got %q", notice)
+	}
+
+	notice := guardrailNotice(types.StopReasonGuardrailIntervened, c)
+	if !strings.Contains(notice, "guardrail gr-123 (version 2)") {
+		t.Errorf("expected the guardrail to be named, got %q", notice)
+	}
+}
+
+func TestRAGRequestGuardrail(t *testing.T) {
+	req := newRAGRequest("KB1", "arn:model", "q", 5, ragTextInferenceConfig{})
+	if strings.Contains(mustJSON(t, req), "guardrailConfiguration") {
+		t.Error("expected no guardrail in the request by default")
+	}
+
+	req.setGuardrail(buildGuardrailStreamConfig("gr-123", "2"))
+	if !strings.Contains(mustJSON(t, req), `"guardrailConfiguration":{"guardrailId":"gr-123","guardrailVersion":"2"}`) {
+		t.Errorf("expected the guardrail in the request, got %s", mustJSON(t, req))
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// stdout and stderr are where commands write output that has color in
+// it. setupOutput makes them strip the color when it can't be shown.
+var stdout, stderr io.Writer = os.Stdout, os.Stderr
+
+// setupOutput turns color off for output that isn't to a terminal, or
+// when NO_COLOR is set or chat-cli is headless. Headless runs also log
+// each line with a UTC timestamp, so log collectors can order and parse
+// them.
+func setupOutput() {
+	if !utils.ColorEnabled(os.Stdout) {
+		stdout = utils.NewPlainWriter(os.Stdout)
+	}
+	if !utils.ColorEnabled(os.Stderr) {
+		stderr = utils.NewPlainWriter(os.Stderr)
+	}
+	if utils.Headless() {
+		log.SetFlags(0)
+		log.SetOutput(timestampWriter{w: stderr, now: time.Now})
+	}
+}
+
+// timestampWriter starts each write with the time in RFC 3339, in UTC.
+// log makes a single write for each line.
+type timestampWriter struct {
+	w   io.Writer
+	now func() time.Time
+}
+
+func (t timestampWriter) Write(b []byte) (int, error) {
+	line := t.now().UTC().Format(time.RFC3339) + " " + string(b)
+	if _, err := io.WriteString(t.w, line); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
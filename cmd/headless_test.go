@@ -0,0 +1,22 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestTimestampWriter(t *testing.T) {
+	var buf bytes.Buffer
+	at := time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+	logger := log.New(timestampWriter{w: &buf, now: func() time.Time { return at }}, "", 0)
+
+	logger.Print("unable to load AWS config")
+	if got, want := buf.String(), "2024-05-01T21:30:00Z unable to load AWS config\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+//go:embed helptopics/*.md
+var helpTopicFiles embed.FS
+
+// helpTopicNames lists the guides in help topics, in the order they're
+// listed: roughly the order someone new to chat-cli needs them.
+var helpTopicNames = []string{"getting-started", "sessions", "agents", "rag", "config"}
+
+// errUnknownTopic is returned by loadHelpTopic for a name that isn't in
+// helpTopicNames.
+var errUnknownTopic = errors.New("unknown help topic")
+
+// loadHelpTopic returns the markdown of the named guide and its title,
+// taken from its first heading.
+func loadHelpTopic(name string) (string, string, error) {
+	data, err := helpTopicFiles.ReadFile("helptopics/" + name + ".md")
+	if err != nil {
+		return "", "", fmt.Errorf("%w %q: choose one of %s", errUnknownTopic, name, strings.Join(helpTopicNames, ", "))
+	}
+	text := string(data)
+	firstLine, _, _ := strings.Cut(text, "\n")
+	return text, strings.TrimPrefix(firstLine, "# "), nil
+}
+
+// printHelpTopics lists the guides with their titles.
+func printHelpTopics(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "TOPIC\tGUIDE"); err != nil {
+		return err
+	}
+	for _, name := range helpTopicNames {
+		_, title, err := loadHelpTopic(name)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", name, title); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, "\nRead one with: chat-cli help topics <topic>")
+	return err
+}
+
+// commandExamples returns the runnable examples for cmd: its Example, or
+// otherwise the "> chat-cli ..." lines in its long description.
+func commandExamples(cmd *cobra.Command) []string {
+	var examples []string
+	if cmd.Example != "" {
+		for _, line := range strings.Split(cmd.Example, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				examples = append(examples, line)
+			}
+		}
+		return examples
+	}
+	for _, line := range strings.Split(cmd.Long, "\n") {
+		if example, ok := strings.CutPrefix(strings.TrimSpace(line), "> "); ok && strings.HasPrefix(example, "chat-cli") {
+			examples = append(examples, example)
+		}
+	}
+	return examples
+}
+
+// examplesCommand returns the command args run when --examples is among
+// them, so its examples can be printed before cobra checks the command's
+// arguments and required flags, which an --examples request won't have.
+// The flags are only scanned, not parsed: parsing them here would parse
+// repeatable flags twice.
+func examplesCommand(root *cobra.Command, args []string) (*cobra.Command, bool) {
+	cmd, rest, err := root.Find(args)
+	if err != nil {
+		return nil, false
+	}
+	for _, arg := range rest {
+		switch arg {
+		case "--":
+			return nil, false
+		case "--examples", "--examples=true":
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+// printExamples writes cmd's examples, one per line, ready to copy.
+func printExamples(out io.Writer, cmd *cobra.Command) error {
+	examples := commandExamples(cmd)
+	if len(examples) == 0 {
+		_, err := fmt.Fprintf(out, "No examples for %s; see %s --help, or chat-cli help topics.\n", cmd.CommandPath(), cmd.CommandPath())
+		return err
+	}
+	for _, example := range examples {
+		if _, err := fmt.Fprintln(out, example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// helpCmd replaces cobra's help command so it can have subcommands. Like
+// cobra's, it shows the help of the command named by its arguments.
+var helpCmd = &cobra.Command{
+	Use:   "help [command]",
+	Short: "Help about any command, and guides with help topics",
+	Long: `Help provides help for any command in the application.
+Simply type chat-cli help [path to command] for full details.
+
+For task-oriented guides, such as getting started or working with knowledge
+bases, see:
+
+> chat-cli help topics`,
+	Run: func(cmd *cobra.Command, args []string) {
+		target, _, err := cmd.Root().Find(args)
+		if target == nil || err != nil {
+			cmd.Printf("Unknown help topic %#q\n", args)
+			cobra.CheckErr(cmd.Root().Usage())
+			return
+		}
+		target.InitDefaultHelpFlag()
+		target.InitDefaultVersionFlag()
+		cobra.CheckErr(target.Help())
+	},
+}
+
+// helpTopicsCmd represents the help topics command
+var helpTopicsCmd = &cobra.Command{
+	Use:   "topics [topic]",
+	Short: "Read a guide to a task, such as getting started or sessions",
+	Long: `List the guides to common tasks, or read one. Each guide covers a task from
+start to finish with commands you can copy:
+
+> chat-cli help topics
+> chat-cli help topics getting-started`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: helpTopicNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			if err := printHelpTopics(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		text, _, err := loadHelpTopic(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(renderMarkdown(text, utils.ColorEnabled(os.Stdout)))
+	},
+}
+
+func init() {
+	rootCmd.SetHelpCommand(helpCmd)
+	helpCmd.AddCommand(helpTopicsCmd)
+
+	rootCmd.PersistentFlags().Bool("examples", false, "print copy-paste examples of the command and exit")
+}
@@ -0,0 +1,26 @@
+//go:build !lite
+
+package cmd
+
+import (
+	"github.com/charmbracelet/glamour"
+)
+
+// renderMarkdown renders the markdown the help topics are written in for a
+// terminal with glamour. Without color, or if glamour can't render it, the
+// markdown is returned as it is, which reads fine as plain text.
+func renderMarkdown(text string, color bool) string {
+	if !color {
+		return text
+	}
+
+	renderer, err := glamour.NewTermRenderer(glamour.WithAutoStyle(), glamour.WithWordWrap(80))
+	if err != nil {
+		return text
+	}
+	rendered, err := renderer.Render(text)
+	if err != nil {
+		return text
+	}
+	return rendered
+}
@@ -0,0 +1,44 @@
+//go:build lite
+
+package cmd
+
+import (
+	"regexp"
+	"strings"
+)
+
+// inlineCode matches `code` spans within a line of markdown.
+var inlineCode = regexp.MustCompile("`([^`]+)`")
+
+// renderMarkdown styles the markdown the help topics are written in for a
+// terminal: bold headings, indented code blocks, and highlighted code. The
+// lite build does this itself rather than with glamour, which would add its
+// markdown parser and syntax highlighter to the binary. Without color, the
+// markdown is returned as it is, which reads fine as plain text.
+func renderMarkdown(text string, color bool) string {
+	if !color {
+		return text
+	}
+
+	var b strings.Builder
+	inCode := false
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "```"):
+			inCode = !inCode
+			continue
+		case inCode:
+			b.WriteString("    \033[36m" + line + "\033[0m")
+		case strings.HasPrefix(line, "# "):
+			b.WriteString("\033[1;4m" + strings.TrimPrefix(line, "# ") + "\033[0m")
+		case strings.HasPrefix(line, "## "):
+			b.WriteString("\033[1m" + strings.TrimPrefix(line, "## ") + "\033[0m")
+		case strings.HasPrefix(line, "- "):
+			b.WriteString("  • " + inlineCode.ReplaceAllString(strings.TrimPrefix(line, "- "), "\033[36m$1\033[0m"))
+		default:
+			b.WriteString(inlineCode.ReplaceAllString(line, "\033[36m$1\033[0m"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,13 @@
+//go:build lite
+
+package cmd
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	text := "# Title\n\nRun `chat-cli doctor` first.\n\n```shell\nchat-cli doctor\n```\n- a `flag`\n"
+	want := "\033[1;4mTitle\033[0m\n\nRun \033[36mchat-cli doctor\033[0m first.\n\n    \033[36mchat-cli doctor\033[0m\n  • a \033[36mflag\033[0m\n"
+	if got := renderMarkdown(text, true); got != want {
+		t.Errorf("renderMarkdown() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !lite
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	text := "# Title\n\nRun `chat-cli doctor` first.\n\n```shell\nchat-cli doctor\n```\n- a `flag`\n"
+	got := renderMarkdown(text, true)
+	for _, want := range []string{"Title", "Run chat-cli doctor first.", "• a flag"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the rendered markdown, got %q", want, got)
+		}
+	}
+	if strings.Contains(got, "`") {
+		t.Errorf("expected code spans and fences to be rendered, got %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHelpTopics(t *testing.T) {
+	for _, name := range helpTopicNames {
+		text, title, err := loadHelpTopic(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if title == "" || strings.HasPrefix(title, "#") {
+			t.Errorf("%s: expected a title from its first heading, got %q", name, title)
+		}
+
+		// every command a guide's code blocks show should exist
+		inCode := false
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "```") {
+				inCode = !inCode
+				continue
+			}
+			fields := strings.Fields(line)
+			if !inCode || len(fields) < 2 || fields[0] != "chat-cli" || strings.HasPrefix(fields[1], "-") {
+				continue
+			}
+			if cmd, _, err := rootCmd.Find(fields[1:]); err != nil || cmd == rootCmd {
+				t.Errorf("%s: %q doesn't run a chat-cli command", name, line)
+			}
+		}
+	}
+
+	if _, _, err := loadHelpTopic("nope"); !errors.Is(err, errUnknownTopic) || !strings.Contains(err.Error(), "getting-started") {
+		t.Errorf("expected the topics listed for an unknown one, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := printHelpTopics(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "rag              Knowledge Bases (RAG)") {
+		t.Errorf("unexpected listing\n%s", out.String())
+	}
+}
+
+func TestRenderMarkdown_NoColor(t *testing.T) {
+	text := "# Title\n\nRun `chat-cli doctor` first.\n\n```shell\nchat-cli doctor\n```\n- a `flag`\n"
+	if got := renderMarkdown(text, false); got != text {
+		t.Errorf("expected markdown unchanged without color, got %q", got)
+	}
+}
+
+func TestExamplesCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"config", "set", "--examples"}, "config set"},
+		{[]string{"chat", "--examples=true", "list"}, "list"},
+		{[]string{"--examples"}, "chat-cli"},
+		{[]string{"config", "set", "model-id", "x"}, ""},
+		{[]string{"prompt", "--", "--examples"}, ""},
+	}
+	for _, tt := range tests {
+		cmd, ok := examplesCommand(rootCmd, tt.args)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("%v: didn't expect examples", tt.args)
+			}
+			continue
+		}
+		if !ok || !strings.HasSuffix(cmd.CommandPath(), tt.want) {
+			t.Errorf("%v: expected the examples of %s, got %v", tt.args, tt.want, cmd)
+		}
+	}
+}
+
+func TestCommandExamples(t *testing.T) {
+	var out bytes.Buffer
+	if err := printExamples(&out, promptCmd); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "chat-cli prompt \"What is your name?\"\n") {
+		t.Errorf("expected the examples from prompt's description, got\n%s", out.String())
+	}
+
+	if got := commandExamples(doctorCmd); len(got) != 3 || got[2] != "chat-cli doctor --ci" {
+		t.Errorf("expected doctor's Example, got %q", got)
+	}
+}
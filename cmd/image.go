@@ -8,23 +8,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"os"
 	"slices"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/chat-cli/chat-cli/repository"
 	"github.com/chat-cli/chat-cli/utils" //nolint:goimports // false positive from CI version diff
 	"github.com/go-micah/go-bedrock/providers"
 	"github.com/spf13/cobra" //nolint:goimports // false positive from CI version diff
+
+	conf "github.com/chat-cli/chat-cli/config"
 )
 
+// maxImageSeed is the largest seed accepted by every supported image
+// provider (Amazon Titan/Nova Canvas caps at 2147483646; Stability accepts
+// more), so a randomly chosen seed is always valid for a later rerun.
+const maxImageSeed = 2147483646
+
+// imageParams are the inputs to a single image generation, as recorded in
+// image history so the generation can be reproduced with `image rerun`.
+type imageParams struct { //nolint:govet // fieldalignment is a minor optimization
+	ModelId  string
+	Prompt   string
+	Scale    float64
+	Steps    int
+	Seed     int
+	Filename string
+}
+
 // imageCmd represents the image command
 var imageCmd = &cobra.Command{
 	Use:   "image",
 	Short: "Generate an image with a prompt",
-	Long:  `Send a prompt to one of the models on Amazon Bedrock that supports image generation and save the reuslt to disk.`,
+	Long: `Send a prompt to one of the models on Amazon Bedrock that supports image generation and save the reuslt to disk.
+
+Every generation is recorded with its prompt, seed, and parameters. Use 'chat-cli image list'
+to see recent generations and 'chat-cli image rerun <id>' to reproduce one.`,
+	Example: `  chat-cli image "a lighthouse at dusk, watercolor"
+  chat-cli image list
+  chat-cli image rerun <id>`,
 
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -37,169 +62,230 @@ var imageCmd = &cobra.Command{
 		}
 		prompt += document
 
-		accept := "*/*"
-		contentType := "application/json"
-
-		var bodyString []byte
-
 		// set up connection to AWS
 		region, err := cmd.Parent().PersistentFlags().GetString("region")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
-		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+		cfg, err := loadAWSConfig(cmd.Context(), region)
 		if err != nil {
 			log.Fatalf("unable to load AWS config: %v", err)
 		}
 
-		modelId, err := cmd.PersistentFlags().GetString("model-id")
+		params, err := imageParamsFromFlags(cmd)
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
 		}
+		params.Prompt = prompt
 
-		bedrockSvc := bedrock.NewFromConfig(cfg)
-
-		model, err := bedrockSvc.GetFoundationModel(context.TODO(), &bedrock.GetFoundationModelInput{
-			ModelIdentifier: &modelId,
-		})
+		preview, err := cmd.Flags().GetString("preview")
 		if err != nil {
-			log.Fatalf("error: %v", err)
+			log.Fatalf("unable to get flag: %v", err)
 		}
-
-		// validate model supports image generation
-		if !slices.Contains(model.ModelDetails.OutputModalities, "IMAGE") {
-			log.Fatalf("model %s does not support image generation. please use a different model", *model.ModelDetails.ModelId)
+		if err := validatePreviewMode(preview); err != nil {
+			log.Fatal(err)
 		}
 
-		// get options
-		scale, err := cmd.PersistentFlags().GetFloat64("scale")
-		if err != nil {
-			log.Fatalf("unable to get flag: %v", err)
+		// pick a seed up front when none was given, so the generation is
+		// reproducible from history
+		if !cmd.Flags().Changed("seed") {
+			params.Seed = rand.IntN(maxImageSeed + 1) // #nosec G404 - seeds don't need a cryptographic source
 		}
 
-		steps, err := cmd.PersistentFlags().GetInt("steps")
+		outputFile, err := generateImage(cmd.Context(), cfg, params)
 		if err != nil {
-			log.Fatalf("unable to get flag: %v", err)
+			log.Fatal(err)
 		}
 
-		seed, err := cmd.PersistentFlags().GetInt("seed")
-		if err != nil {
-			log.Fatalf("unable to get flag: %v", err)
-		}
+		log.Println("image written to file", outputFile)
+		previewGeneratedImage(preview, outputFile)
 
-		filename, err := cmd.PersistentFlags().GetString("filename")
-		if err != nil {
-			log.Fatalf("unable to get flag: %v", err)
-		}
+		params.Filename = outputFile
+		recordImageGeneration(cmd.Context(), params)
+	},
+}
 
-		// serialize body
-		switch *model.ModelDetails.ProviderName {
-		case "Stability AI":
-			body := providers.StabilityAIStableDiffusionInvokeModelInput{
-				Prompt: []providers.StabilityAIStableDiffusionTextPrompt{
-					{
-						Text: prompt,
-					},
-				},
-				Scale: scale,
-				Steps: steps,
-				Seed:  seed,
-			}
-
-			bodyString, err = json.Marshal(body)
-			if err != nil {
-				log.Fatalf("unable to marshal body: %v", err)
-			}
-		case "Amazon":
-			body := providers.AmazonTitanImageInvokeModelInput{
-				TaskType: "TEXT_IMAGE",
-				TextToImageParams: providers.AmazonTitanImageInvokeModelInputTextToImageParams{
-					Text: prompt,
-				},
-				ImageGenerationConfig: providers.AmazonTitanImageInvokeModelInputImageGenerationConfig{
-					NumberOfImages: 1,
-					Scale:          scale,
-					Seed:           seed,
-				},
-			}
+// imageParamsFromFlags reads the model and generation parameters shared by
+// image and its subcommands.
+func imageParamsFromFlags(cmd *cobra.Command) (imageParams, error) {
+	var params imageParams
+	var err error
+
+	if params.ModelId, err = cmd.Flags().GetString("model-id"); err != nil {
+		return params, err
+	}
+	if params.Scale, err = cmd.Flags().GetFloat64("scale"); err != nil {
+		return params, err
+	}
+	if params.Steps, err = cmd.Flags().GetInt("steps"); err != nil {
+		return params, err
+	}
+	if params.Seed, err = cmd.Flags().GetInt("seed"); err != nil {
+		return params, err
+	}
+	if params.Filename, err = cmd.Flags().GetString("filename"); err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
 
-			bodyString, err = json.Marshal(body)
-			if err != nil {
-				log.Fatalf("unable to marshal body: %v", err)
-			}
-		default:
-			log.Fatalf("invalid model: %s", *model.ModelDetails.ModelId)
+// generateImage validates that params.ModelId supports image generation,
+// invokes it, and writes the decoded image to disk. It returns the name of
+// the file written: params.Filename if set, otherwise a timestamped name.
+func generateImage(ctx context.Context, cfg aws.Config, params imageParams) (string, error) {
+	accept := "*/*"
+	contentType := "application/json"
+
+	model, err := newModelCatalog(cfg).model(ctx, params.ModelId)
+	if err != nil {
+		return "", fmt.Errorf("error: %v", err)
+	}
+
+	// validate model supports image generation
+	if !slices.Contains(model.OutputModalities, "IMAGE") {
+		return "", fmt.Errorf("model %s does not support image generation. please use a different model", model.ID)
+	}
+
+	var bodyString []byte
+
+	// serialize body
+	switch model.Provider {
+	case "Stability AI":
+		body := providers.StabilityAIStableDiffusionInvokeModelInput{
+			Prompt: []providers.StabilityAIStableDiffusionTextPrompt{
+				{
+					Text: params.Prompt,
+				},
+			},
+			Scale: params.Scale,
+			Steps: params.Steps,
+			Seed:  params.Seed,
 		}
 
-		svc := bedrockruntime.NewFromConfig(cfg)
-
-		resp, err := svc.InvokeModel(context.TODO(), &bedrockruntime.InvokeModelInput{
-			Accept:      &accept,
-			ModelId:     model.ModelDetails.ModelId,
-			ContentType: &contentType,
-			Body:        bodyString,
-		})
+		bodyString, err = json.Marshal(body)
 		if err != nil {
-			log.Fatalf("error from Bedrock, %v", err)
+			return "", fmt.Errorf("unable to marshal body: %v", err)
+		}
+	case "Amazon":
+		body := providers.AmazonTitanImageInvokeModelInput{
+			TaskType: "TEXT_IMAGE",
+			TextToImageParams: providers.AmazonTitanImageInvokeModelInputTextToImageParams{
+				Text: params.Prompt,
+			},
+			ImageGenerationConfig: providers.AmazonTitanImageInvokeModelInputImageGenerationConfig{
+				NumberOfImages: 1,
+				Scale:          params.Scale,
+				Seed:           params.Seed,
+			},
 		}
 
-		// save images to disk
-		switch *model.ModelDetails.ProviderName {
-		case "Stability AI":
-			var out providers.StabilityAIStableDiffusionInvokeModelOutput
-
-			err = json.Unmarshal(resp.Body, &out)
-			if err != nil {
-				log.Fatalf("unable to unmarshal response from Bedrock: %v", err)
-			}
-
-			decoded, decodeErr := utils.DecodeImage(out.Artifacts[0].Base64)
-			if decodeErr != nil {
-				log.Fatalf("unable to decode image: %v", decodeErr)
-			}
-
-			outputFile := fmt.Sprintf("%d.jpg", time.Now().Unix())
-
-			// if we have a filename set, us it instead
-			if filename != "" {
-				outputFile = filename
-			}
-
-			err = os.WriteFile(outputFile, decoded, 0600)
-			if err != nil {
-				log.Fatalf("error writing to file: %v", err)
-			}
+		bodyString, err = json.Marshal(body)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal body: %v", err)
+		}
+	default:
+		return "", fmt.Errorf("invalid model: %s", model.ID)
+	}
+
+	svc := bedrockruntime.NewFromConfig(cfg)
+
+	resp, err := svc.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		Accept:      &accept,
+		ModelId:     aws.String(model.ID),
+		ContentType: &contentType,
+		Body:        bodyString,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error from Bedrock, %v", err)
+	}
+
+	var encoded string
+
+	switch model.Provider {
+	case "Stability AI":
+		var out providers.StabilityAIStableDiffusionInvokeModelOutput
+
+		if err := json.Unmarshal(resp.Body, &out); err != nil {
+			return "", fmt.Errorf("unable to unmarshal response from Bedrock: %v", err)
+		}
+		if len(out.Artifacts) == 0 {
+			return "", fmt.Errorf("no image returned from Bedrock")
+		}
+		encoded = out.Artifacts[0].Base64
+	case "Amazon":
+		var out providers.AmazonTitanImageInvokeModelOutput
 
-			log.Println("image written to file", outputFile)
-		case "Amazon":
-			var out providers.AmazonTitanImageInvokeModelOutput
+		if err := json.Unmarshal(resp.Body, &out); err != nil {
+			return "", fmt.Errorf("unable to unmarshal response from Bedrock: %v", err)
+		}
+		if len(out.Images) == 0 {
+			return "", fmt.Errorf("no image returned from Bedrock")
+		}
+		encoded = out.Images[0]
+	}
 
-			err = json.Unmarshal(resp.Body, &out)
-			if err != nil {
-				log.Fatalf("unable to unmarshal response from Bedrock: %v", err)
-			}
+	decoded, err := utils.DecodeImage(encoded)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode image: %v", err)
+	}
 
-			decoded, err := utils.DecodeImage(out.Images[0])
-			if err != nil {
-				log.Fatalf("unable to decode image: %v", err)
-			}
+	outputFile := fmt.Sprintf("%d.jpg", time.Now().Unix())
 
-			outputFile := fmt.Sprintf("%d.jpg", time.Now().Unix())
+	// if we have a filename set, us it instead
+	if params.Filename != "" {
+		outputFile = params.Filename
+	}
 
-			// if we have a filename set, us it instead
-			if filename != "" {
-				outputFile = filename
-			}
+	if err := os.WriteFile(outputFile, decoded, 0600); err != nil {
+		return "", fmt.Errorf("error writing to file: %v", err)
+	}
 
-			err = os.WriteFile(outputFile, decoded, 0600)
-			if err != nil {
-				log.Fatalf("error writing to file: %v", err)
-			}
+	return outputFile, nil
+}
 
-			log.Println("image written to file", outputFile)
+// recordImageGeneration saves params to image history. The image is already
+// on disk by the time this runs, so failures are reported as warnings
+// rather than failing the command.
+func recordImageGeneration(ctx context.Context, params imageParams) {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Printf("Warning: unable to record image history: %v", err)
+		return
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Printf("Warning: unable to record image history: %v", initErr)
+		return
+	}
+
+	database, err := openDatabase(fm)
+	if err != nil {
+		log.Printf("Warning: unable to record image history: %v", err)
+		return
+	}
+	defer func() {
+		if err := database.Close(); err != nil {
+			log.Printf("Warning: failed to close database: %v", err)
 		}
-	},
+	}()
+
+	image := &repository.Image{
+		ModelId:  params.ModelId,
+		Prompt:   params.Prompt,
+		Seed:     params.Seed,
+		Scale:    params.Scale,
+		Steps:    params.Steps,
+		Filename: params.Filename,
+	}
+
+	if err := repository.NewImageRepository(database).Create(ctx, image); err != nil {
+		log.Printf("Warning: unable to record image history: %v", err)
+		return
+	}
+
+	log.Printf("recorded as generation %d (seed %d)", image.ID, image.Seed)
 }
 
 func init() {
@@ -211,12 +297,13 @@ func init() {
 	// and all subcommands, e.g.:
 	imageCmd.PersistentFlags().Float64("scale", 10, "Set the scale")
 	imageCmd.PersistentFlags().Int("steps", 10, "Set the steps")
-	imageCmd.PersistentFlags().Int("seed", 0, "Set the seed")
+	imageCmd.PersistentFlags().Int("seed", 0, "Set the seed (a random seed is chosen and recorded when unset)")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// imageCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	imageCmd.PersistentFlags().StringP("model-id", "m", "amazon.nova-canvas-v1:0", "set the model id")
 	imageCmd.PersistentFlags().StringP("filename", "f", "", "provide an output filename")
+	imageCmd.PersistentFlags().String("preview", previewAuto, "show the image in the terminal: auto, kitty, iterm, blocks, or off")
 
 }
@@ -0,0 +1,205 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// imageListCmd represents the image list command
+var imageListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Prints a list of recent image generations and IDs",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		images, err := repository.NewImageRepository(database).List(cmd.Context())
+		if err != nil {
+			log.Fatalf("Failed to list images: %v", err)
+		}
+
+		fmt.Println("")
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		if _, err := fmt.Fprintln(w, "ID\t Created Date\t Model ID\t Seed\t File\t Prompt"); err != nil {
+			log.Printf("Error writing header: %v", err)
+		}
+
+		if _, err := fmt.Fprintln(w, "\t\t\t\t\t"); err != nil {
+			log.Printf("Error writing separator: %v", err)
+		}
+
+		for _, image := range images {
+			if _, err := fmt.Fprintf(w, "%d\t %s\t %s\t %d\t %s\t %s\n", image.ID, image.Created, image.ModelId, image.Seed, image.Filename, truncate(image.Prompt, 40)); err != nil {
+				log.Printf("Error writing image data: %v", err)
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing writer: %v", err)
+		}
+	},
+}
+
+// imageRerunCmd represents the image rerun command
+var imageRerunCmd = &cobra.Command{
+	Use:   "rerun <id>",
+	Short: "Re-run a past image generation with the same seed and parameters",
+	Long: `Re-run a past image generation using its recorded prompt, model, seed, scale, and steps.
+
+Any of --prompt, --model-id, --seed, --scale, or --steps can be given to override that one
+parameter while keeping the rest, which is useful for controlled variations:
+
+> chat-cli image rerun 12 --seed 7
+> chat-cli image rerun 12 --prompt "the same lighthouse, at dawn"`,
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("invalid image id %q: must be a number", args[0])
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		record, err := repository.NewImageRepository(database).GetByID(cmd.Context(), id)
+		if closeErr := database.Close(); closeErr != nil {
+			log.Printf("Warning: failed to close database: %v", closeErr)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		params, err := rerunImageParams(record, cmd.Flags())
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		preview, err := cmd.Flags().GetString("preview")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if err := validatePreviewMode(preview); err != nil {
+			log.Fatal(err)
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		outputFile, err := generateImage(cmd.Context(), cfg, params)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("image written to file", outputFile)
+		previewGeneratedImage(preview, outputFile)
+
+		params.Filename = outputFile
+		recordImageGeneration(cmd.Context(), params)
+	},
+}
+
+// rerunImageParams starts from a recorded generation and applies any
+// parameters explicitly set on the command line. The original filename is
+// never reused, so a rerun can't silently overwrite the image it came from.
+func rerunImageParams(record *repository.Image, flags *pflag.FlagSet) (imageParams, error) {
+	params := imageParams{
+		ModelId: record.ModelId,
+		Prompt:  record.Prompt,
+		Seed:    record.Seed,
+		Scale:   record.Scale,
+		Steps:   record.Steps,
+	}
+
+	var err error
+
+	if flags.Changed("prompt") {
+		if params.Prompt, err = flags.GetString("prompt"); err != nil {
+			return params, err
+		}
+	}
+	if flags.Changed("model-id") {
+		if params.ModelId, err = flags.GetString("model-id"); err != nil {
+			return params, err
+		}
+	}
+	if flags.Changed("seed") {
+		if params.Seed, err = flags.GetInt("seed"); err != nil {
+			return params, err
+		}
+	}
+	if flags.Changed("scale") {
+		if params.Scale, err = flags.GetFloat64("scale"); err != nil {
+			return params, err
+		}
+	}
+	if flags.Changed("steps") {
+		if params.Steps, err = flags.GetInt("steps"); err != nil {
+			return params, err
+		}
+	}
+	if flags.Changed("filename") {
+		if params.Filename, err = flags.GetString("filename"); err != nil {
+			return params, err
+		}
+	}
+
+	return params, nil
+}
+
+func init() {
+	imageCmd.AddCommand(imageListCmd)
+	imageCmd.AddCommand(imageRerunCmd)
+
+	imageRerunCmd.Flags().String("prompt", "", "override the recorded prompt")
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/pflag"
+)
+
+func newRerunFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("rerun", pflag.ContinueOnError)
+	flags.String("prompt", "", "")
+	flags.String("model-id", "amazon.nova-canvas-v1:0", "")
+	flags.Int("seed", 0, "")
+	flags.Float64("scale", 10, "")
+	flags.Int("steps", 10, "")
+	flags.String("filename", "", "")
+	return flags
+}
+
+func TestRerunImageParams(t *testing.T) {
+	record := &repository.Image{
+		ID:       3,
+		ModelId:  "stability.sd3-large-v1:0",
+		Prompt:   "a lighthouse at dusk",
+		Seed:     1234,
+		Scale:    7.5,
+		Steps:    40,
+		Filename: "original.jpg",
+	}
+
+	t.Run("no overrides reproduces the recorded generation", func(t *testing.T) {
+		params, err := rerunImageParams(record, newRerunFlagSet())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := imageParams{
+			ModelId: record.ModelId,
+			Prompt:  record.Prompt,
+			Seed:    record.Seed,
+			Scale:   record.Scale,
+			Steps:   record.Steps,
+		}
+		if params != want {
+			t.Errorf("got %+v, want %+v", params, want)
+		}
+	})
+
+	t.Run("original filename is never reused", func(t *testing.T) {
+		params, err := rerunImageParams(record, newRerunFlagSet())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Filename != "" {
+			t.Errorf("expected empty filename so a new one is generated, got %q", params.Filename)
+		}
+	})
+
+	t.Run("a single override keeps every other parameter", func(t *testing.T) {
+		flags := newRerunFlagSet()
+		if err := flags.Parse([]string{"--seed", "7"}); err != nil {
+			t.Fatal(err)
+		}
+
+		params, err := rerunImageParams(record, flags)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Seed != 7 {
+			t.Errorf("expected seed override 7, got %d", params.Seed)
+		}
+		if params.Prompt != record.Prompt || params.Scale != record.Scale || params.Steps != record.Steps || params.ModelId != record.ModelId {
+			t.Errorf("expected non-overridden params to match the record, got %+v", params)
+		}
+	})
+
+	t.Run("prompt and filename overrides", func(t *testing.T) {
+		flags := newRerunFlagSet()
+		if err := flags.Parse([]string{"--prompt", "at dawn", "--filename", "dawn.jpg"}); err != nil {
+			t.Fatal(err)
+		}
+
+		params, err := rerunImageParams(record, flags)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Prompt != "at dawn" {
+			t.Errorf("expected prompt override, got %q", params.Prompt)
+		}
+		if params.Filename != "dawn.jpg" {
+			t.Errorf("expected filename override, got %q", params.Filename)
+		}
+		if params.Seed != record.Seed {
+			t.Errorf("expected recorded seed %d, got %d", record.Seed, params.Seed)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+const (
+	// maxImagesPerRequest is the Converse API's limit on images in one
+	// request.
+	maxImagesPerRequest = 20
+	// maxImageBytes is the Converse API's limit on a single image.
+	maxImageBytes = 3_932_160 // 3.75 MB
+	// maxImagePayloadBytes caps the combined size of a request's images,
+	// staying under the request size models accept.
+	maxImagePayloadBytes = 20 * 1024 * 1024
+)
+
+// modelImageLimits lists model families that accept fewer images per
+// request than the Converse API allows, keyed by model ID prefix.
+var modelImageLimits = map[string]int{
+	// Llama 3.2 vision models reason over a single image
+	"meta.llama": 1,
+}
+
+// maxImagesForModel returns how many images modelID accepts in one request.
+// Inference profile IDs are matched on the model they route to.
+func maxImagesForModel(modelID string) int {
+	id := modelID
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	for _, prefix := range []string{"us.", "eu.", "apac.", "global."} {
+		id = strings.TrimPrefix(id, prefix)
+	}
+
+	for prefix, limit := range modelImageLimits {
+		if strings.HasPrefix(id, prefix) {
+			return limit
+		}
+	}
+	return maxImagesPerRequest
+}
+
+// buildImageContentBlocks reads each image and returns one content block
+// per image, in order. It fails before anything is sent if there are more
+// images than modelID accepts, or if any image, or all of them together,
+// is too large.
+func buildImageContentBlocks(paths []string, modelID string) ([]types.ContentBlock, error) {
+	if limit := maxImagesForModel(modelID); len(paths) > limit {
+		return nil, fmt.Errorf("%d images given, but %s accepts at most %d per request", len(paths), modelID, limit)
+	}
+
+	var blocks []types.ContentBlock
+	total := 0
+	for _, path := range paths {
+		data, format, err := utils.ReadImage(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read image %s: %w", path, err)
+		}
+		if len(data) > maxImageBytes {
+			return nil, fmt.Errorf("image %s is %s; images can be at most %s", path, formatByteSize(len(data)), formatByteSize(maxImageBytes))
+		}
+
+		total += len(data)
+		if total > maxImagePayloadBytes {
+			return nil, fmt.Errorf("images total more than %s; attach fewer or smaller images", formatByteSize(maxImagePayloadBytes))
+		}
+
+		blocks = append(blocks, &types.ContentBlockMemberImage{
+			Value: types.ImageBlock{
+				Format: types.ImageFormat(format),
+				Source: &types.ImageSourceMemberBytes{Value: data},
+			},
+		})
+	}
+	return blocks, nil
+}
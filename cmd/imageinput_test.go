@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func writeTestImage(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMaxImagesForModel(t *testing.T) {
+	cases := map[string]int{
+		"anthropic.claude-3-5-sonnet-20240620-v1:0":                                     20,
+		"us.anthropic.claude-sonnet-4-20250514-v1:0":                                    20,
+		"us.meta.llama3-2-90b-instruct-v1:0":                                            1,
+		"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.meta.llama3-2-11b": 1,
+	}
+	for modelID, want := range cases {
+		if got := maxImagesForModel(modelID); got != want {
+			t.Errorf("maxImagesForModel(%q) = %d, want %d", modelID, got, want)
+		}
+	}
+}
+
+func TestBuildImageContentBlocks(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("attaches every image in order", func(t *testing.T) {
+		paths := []string{writeTestImage(t, dir, "before.png", 10), writeTestImage(t, dir, "after.jpg", 20)}
+		blocks, err := buildImageContentBlocks(paths, "amazon.nova-pro-v1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != 2 {
+			t.Fatalf("expected 2 blocks, got %d", len(blocks))
+		}
+		second, ok := blocks[1].(*types.ContentBlockMemberImage)
+		if !ok || second.Value.Format != types.ImageFormatJpeg {
+			t.Errorf("unexpected second block %#v", blocks[1])
+		}
+	})
+
+	t.Run("too many images for the model", func(t *testing.T) {
+		paths := []string{writeTestImage(t, dir, "a.png", 1), writeTestImage(t, dir, "b.png", 1)}
+		_, err := buildImageContentBlocks(paths, "us.meta.llama3-2-90b-instruct-v1:0")
+		if err == nil || !strings.Contains(err.Error(), "at most 1") {
+			t.Errorf("expected a per-model limit error, got %v", err)
+		}
+	})
+
+	t.Run("image too large", func(t *testing.T) {
+		path := writeTestImage(t, dir, "huge.png", maxImageBytes+1)
+		if _, err := buildImageContentBlocks([]string{path}, "amazon.nova-pro-v1:0"); err == nil || !strings.Contains(err.Error(), "huge.png") {
+			t.Errorf("expected a size error naming the image, got %v", err)
+		}
+	})
+
+	t.Run("total payload too large", func(t *testing.T) {
+		var paths []string
+		for _, name := range []string{"1.png", "2.png", "3.png", "4.png", "5.png", "6.png"} {
+			paths = append(paths, writeTestImage(t, dir, name, maxImageBytes))
+		}
+		if _, err := buildImageContentBlocks(paths, "amazon.nova-pro-v1:0"); err == nil || !strings.Contains(err.Error(), "total") {
+			t.Errorf("expected a total size error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // decode generated JPEGs
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Ways an image can be previewed in the terminal.
+const (
+	previewAuto   = "auto"
+	previewKitty  = "kitty"
+	previewITerm  = "iterm"
+	previewBlocks = "blocks"
+	previewOff    = "off"
+)
+
+// maxPreviewColumns caps how wide a preview is drawn, so a large image
+// doesn't take over the whole scrollback.
+const maxPreviewColumns = 60
+
+// kittyChunkSize is the most base64 the kitty graphics protocol accepts in
+// one escape sequence.
+const kittyChunkSize = 4096
+
+// detectPreviewProtocol picks the richest image protocol the terminal
+// supports, from the variables terminals set to identify themselves.
+func detectPreviewProtocol(getenv func(string) string) string {
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || getenv("TERM") == "xterm-kitty" || getenv("TERM_PROGRAM") == "ghostty":
+		return previewKitty
+	case getenv("TERM_PROGRAM") == "iTerm.app" || getenv("TERM_PROGRAM") == "WezTerm" || getenv("LC_TERMINAL") == "iTerm2":
+		return previewITerm
+	}
+	return previewBlocks
+}
+
+// validatePreviewMode rejects values of --preview other than the ones above.
+func validatePreviewMode(mode string) error {
+	switch mode {
+	case previewAuto, previewKitty, previewITerm, previewBlocks, previewOff:
+		return nil
+	}
+	return fmt.Errorf("invalid --preview %q: use auto, kitty, iterm, blocks, or off", mode)
+}
+
+// previewGeneratedImage shows the image at path in the terminal, as mode
+// asks. auto previews only when stdout is a terminal. A preview that fails
+// is reported but doesn't fail the command, since the image is already
+// saved.
+func previewGeneratedImage(mode, path string) {
+	if mode == previewOff {
+		return
+	}
+	if mode == previewAuto {
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			return
+		}
+		mode = detectPreviewProtocol(os.Getenv)
+	}
+
+	columns := maxPreviewColumns
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		columns = min(columns, width)
+	}
+
+	data, err := os.ReadFile(path) // nolint:gosec // the file the command just wrote
+	if err != nil {
+		log.Printf("Warning: unable to preview image: %v", err)
+		return
+	}
+	if err := writeImagePreview(os.Stdout, data, mode, columns); err != nil {
+		log.Printf("Warning: unable to preview image: %v", err)
+	}
+}
+
+// writeImagePreview draws the image in data to w, columns cells wide, with
+// the given protocol.
+func writeImagePreview(w io.Writer, data []byte, protocol string, columns int) error {
+	if protocol == previewITerm {
+		// iTerm2 decodes the file itself, in any format it supports
+		_, err := fmt.Fprintf(w, "\033]1337;File=inline=1;size=%d;width=%d;preserveAspectRatio=1:%s\a\n",
+			len(data), columns, base64.StdEncoding.EncodeToString(data))
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to decode image: %w", err)
+	}
+
+	if protocol == previewKitty {
+		return writeKittyImage(w, img, columns)
+	}
+	return writeBlockImage(w, img, columns)
+}
+
+// writeKittyImage sends img with the kitty graphics protocol, which takes
+// PNG data in base64 chunks.
+func writeKittyImage(w io.Writer, img image.Image, columns int) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	for first := true; first || payload != ""; first = false {
+		chunk := payload[:min(kittyChunkSize, len(payload))]
+		payload = payload[len(chunk):]
+
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+
+		control := fmt.Sprintf("m=%d", more)
+		if first {
+			control = fmt.Sprintf("a=T,f=100,c=%d,%s", columns, control)
+		}
+		if _, err := fmt.Fprintf(w, "\033_G%s;%s\033\\", control, chunk); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// writeBlockImage draws img with half-block characters in 24-bit color,
+// two pixels per cell: the upper one as the foreground, the lower one as
+// the background. It works in any terminal with true color.
+func writeBlockImage(w io.Writer, img image.Image, columns int) error {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return fmt.Errorf("image is empty")
+	}
+
+	width := min(columns, bounds.Dx())
+	// terminal cells are about twice as tall as they are wide, and each
+	// holds two rows of pixels, so rows come out square
+	height := max(2, bounds.Dy()*width/bounds.Dx())
+	height += height % 2
+
+	sample := func(x, y int) color.RGBA {
+		sx := bounds.Min.X + x*bounds.Dx()/width
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		return color.RGBAModel.Convert(img.At(sx, sy)).(color.RGBA)
+	}
+
+	var b strings.Builder
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x++ {
+			top, bottom := sample(x, y), sample(x, y+1)
+			fmt.Fprintf(&b, "\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		b.WriteString("\033[0m\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
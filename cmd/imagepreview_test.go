@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// testPNG encodes a w×h image, red on top and blue below.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if y >= h/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectPreviewProtocol(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"TERM": "xterm-kitty"}, previewKitty},
+		{map[string]string{"KITTY_WINDOW_ID": "1", "TERM": "xterm-256color"}, previewKitty},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, previewITerm},
+		{map[string]string{"TERM_PROGRAM": "WezTerm"}, previewITerm},
+		{map[string]string{"TERM": "xterm-256color"}, previewBlocks},
+		{nil, previewBlocks},
+	}
+	for _, c := range cases {
+		if got := detectPreviewProtocol(func(k string) string { return c.env[k] }); got != c.want {
+			t.Errorf("%v: got %s, want %s", c.env, got, c.want)
+		}
+	}
+}
+
+func TestValidatePreviewMode(t *testing.T) {
+	for _, mode := range []string{"auto", "kitty", "iterm", "blocks", "off"} {
+		if err := validatePreviewMode(mode); err != nil {
+			t.Errorf("%s: unexpected error %v", mode, err)
+		}
+	}
+	if err := validatePreviewMode("sixel"); err == nil {
+		t.Error("expected an error for an unsupported mode")
+	}
+}
+
+func TestWriteImagePreview(t *testing.T) {
+	data := testPNG(t, 8, 8)
+
+	t.Run("blocks", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := writeImagePreview(&out, data, previewBlocks, 4); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		// 4 columns of a square image are 4 pixel rows, two per line
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %d: %q", len(lines), out.String())
+		}
+		if strings.Count(lines[0], "▀") != 4 {
+			t.Errorf("expected 4 cells per line, got %q", lines[0])
+		}
+		if !strings.Contains(lines[0], "\033[38;2;255;0;0m\033[48;2;255;0;0m") || !strings.Contains(lines[1], "\033[38;2;0;0;255m") {
+			t.Errorf("expected red above blue, got %q", out.String())
+		}
+	})
+
+	t.Run("kitty", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := writeImagePreview(&out, data, previewKitty, 30); err != nil {
+			t.Fatal(err)
+		}
+		got := out.String()
+		if !strings.HasPrefix(got, "\033_Ga=T,f=100,c=30,m=0;") || !strings.Contains(got, "\033\\") {
+			t.Errorf("unexpected kitty sequence %q", got)
+		}
+	})
+
+	t.Run("kitty splits large images into chunks", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := writeKittyImage(&out, noisyImage(128), 30); err != nil {
+			t.Fatal(err)
+		}
+		got := out.String()
+		if !strings.Contains(got, "m=1;") || !strings.Contains(got, "\033_Gm=0;") {
+			t.Errorf("expected a chunked transfer, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("iterm", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := writeImagePreview(&out, data, previewITerm, 30); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "\033]1337;File=inline=1;") || !strings.Contains(out.String(), base64.StdEncoding.EncodeToString(data)) {
+			t.Errorf("unexpected iTerm2 sequence %q", out.String())
+		}
+	})
+
+	t.Run("undecodable data", func(t *testing.T) {
+		if err := writeImagePreview(&bytes.Buffer{}, []byte("not an image"), previewBlocks, 10); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+// noisyImage is an image that doesn't compress well.
+func noisyImage(size int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rng.UintN(256))
+	}
+	return img
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/chat-cli/chat-cli/documents"
+)
+
+// Values for the injection-check setting, which decides what happens to an
+// attached document that looks like it's trying to instruct the model.
+const (
+	injectionWarn  = "warn"
+	injectionStrip = "strip"
+	injectionOff   = "off"
+)
+
+// validateInjectionCheck returns an error for anything other than warn,
+// strip, or off.
+func validateInjectionCheck(mode string) error {
+	if mode != injectionWarn && mode != injectionStrip && mode != injectionOff {
+		return fmt.Errorf("invalid injection-check setting %q: use %q, %q, or %q", mode, injectionWarn, injectionStrip, injectionOff)
+	}
+	return nil
+}
+
+// injectionGuard screens attached documents for prompt injection before
+// they're sent.
+type injectionGuard struct {
+	mode string
+	// tools is set when the model can run tools, so an injected
+	// instruction could do more than skew an answer.
+	tools bool
+	out   io.Writer
+}
+
+// screen checks the text extracted from the document at path, warning
+// about what it finds and, in strip mode, returning the text without it.
+func (g injectionGuard) screen(path, text string) string {
+	if g.mode == injectionOff {
+		return text
+	}
+	found := documents.FindInjections(text)
+	if len(found) == 0 {
+		return text
+	}
+	g.warn(path, found, g.mode == injectionStrip)
+	if g.mode == injectionStrip {
+		return documents.StripInjections(text)
+	}
+	return text
+}
+
+// screenDocument checks a --document, which is sent to Bedrock as the file
+// itself. Text formats are stripped like --file text; a PDF or Word file
+// can only be checked, so in strip mode one with findings is refused.
+func (g injectionGuard) screenDocument(path string, data []byte, format string) ([]byte, error) {
+	if g.mode == injectionOff {
+		return data, nil
+	}
+
+	var text string
+	editable := true
+	switch format {
+	case "txt", "md", "csv", "html":
+		text = string(data)
+	case "pdf", "docx":
+		doc, err := documents.Load(path)
+		if err != nil {
+			// nothing to check, such as a scanned PDF
+			return data, nil
+		}
+		text, editable = doc.Text, false
+	default:
+		return data, nil
+	}
+
+	found := documents.FindInjections(text)
+	if len(found) == 0 {
+		return data, nil
+	}
+	if g.mode == injectionStrip && !editable {
+		g.warn(path, found, false)
+		return nil, fmt.Errorf("%s can't be cleaned before sending; attach it with --file to send its text without these passages, or set injection-check to %s", path, injectionWarn)
+	}
+	g.warn(path, found, g.mode == injectionStrip)
+	if g.mode == injectionStrip {
+		return []byte(documents.StripInjections(text)), nil
+	}
+	return data, nil
+}
+
+func (g injectionGuard) warn(path string, found []documents.Injection, stripped bool) {
+	fmt.Fprintf(g.out, "warning: %s may contain a prompt injection:\n", path)
+	for _, f := range found {
+		fmt.Fprintf(g.out, "  line %d (%s): %s\n", f.Line, f.Kind, f.Excerpt)
+	}
+	switch {
+	case stripped:
+		fmt.Fprintln(g.out, "  these passages were removed before sending")
+	case g.mode == injectionWarn && g.tools:
+		fmt.Fprintln(g.out, "  the model can use tools in this chat, so check it won't act on these; set injection-check to strip to remove them")
+	case g.mode == injectionWarn:
+		fmt.Fprintln(g.out, "  sent as is; set injection-check to strip to remove them")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// stopReasonInterrupted is saved as the stop reason of a chat reply that
+// was cut short with Ctrl+C. It isn't one of Bedrock's.
+const stopReasonInterrupted types.StopReason = "interrupted"
+
+// interruptedPlaceholder stands in for a reply cancelled before any text
+// arrived, since Bedrock won't accept an empty assistant message.
+const interruptedPlaceholder = "[reply cancelled before any text arrived]"
+
+// turnInterrupts lets Ctrl+C cancel the chat turn in progress instead of
+// the whole command. Execute offers it each interrupt first.
+type turnInterrupts struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	// fired is set once the turn has been cancelled, so another Ctrl+C
+	// before it winds down quits instead
+	fired bool
+}
+
+var interrupts turnInterrupts
+
+// begin returns a context for one turn that Ctrl+C cancels, and a func to
+// call when the turn is over.
+func (t *turnInterrupts) begin(ctx context.Context) (context.Context, func()) {
+	turnCtx, cancel := context.WithCancel(ctx)
+	t.mu.Lock()
+	t.cancel, t.fired = cancel, false
+	t.mu.Unlock()
+	return turnCtx, func() {
+		t.mu.Lock()
+		t.cancel = nil
+		t.mu.Unlock()
+		cancel()
+	}
+}
+
+// interrupt cancels the turn in progress, reporting whether it did. With
+// no turn running, or one already cancelled, the interrupt is left for
+// the command as a whole.
+func (t *turnInterrupts) interrupt() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel == nil || t.fired {
+		return false
+	}
+	t.fired = true
+	t.cancel()
+	return true
+}
+
+// interruptedReply is what's kept of a cancelled reply: the text that
+// arrived, or a placeholder if none did.
+func interruptedReply(partial string) string {
+	if strings.TrimSpace(partial) == "" {
+		return interruptedPlaceholder
+	}
+	return partial
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func TestTurnInterrupts(t *testing.T) {
+	var ti turnInterrupts
+	if ti.interrupt() {
+		t.Error("an interrupt with no turn running should be left for the command")
+	}
+
+	ctx, end := ti.begin(t.Context())
+	if !ti.interrupt() {
+		t.Fatal("expected the interrupt to cancel the turn")
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the turn's context to be cancelled")
+	}
+	if ti.interrupt() {
+		t.Error("a second interrupt should quit instead of cancelling the turn again")
+	}
+	end()
+
+	ctx, end = ti.begin(t.Context())
+	defer end()
+	if ctx.Err() != nil || !ti.interrupt() {
+		t.Error("a new turn should be cancellable again")
+	}
+}
+
+func TestRunChatTurnWithToolsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	send := func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		ch := make(chan types.ConverseStreamOutput, 1)
+		ch <- &types.ConverseStreamOutputMemberContentBlockDelta{
+			Value: types.ContentBlockDeltaEvent{
+				ContentBlockIndex: aws.Int32(0),
+				Delta:             &types.ContentBlockDeltaMemberText{Value: "The first half"},
+			},
+		}
+		close(ch)
+		return ch, nil
+	}
+	// Ctrl+C arrives while the text streams, so the stream ends early
+	onText := func(context.Context, string) error {
+		cancel()
+		return nil
+	}
+
+	result, err := runChatTurnWithTools(ctx, send, &bedrockruntime.ConverseStreamInput{}, tools.NewRegistry(), nil, onText,
+		func(context.Context, string) error { return nil }, chatTurnOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v; want context.Canceled", err)
+	}
+	if result.Text != "The first half" || result.StopReason != stopReasonInterrupted {
+		t.Errorf("result = %+v; want the partial text, marked interrupted", result)
+	}
+}
+
+func TestInterruptedReply(t *testing.T) {
+	if got := interruptedReply("Half a"); got != "Half a" {
+		t.Errorf("got %q", got)
+	}
+	if got := interruptedReply("  "); got != interruptedPlaceholder {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// defaultInvokeMaxTokens is the max_tokens used when a request doesn't
+// set it, the same as prompt's --max-tokens.
+const defaultInvokeMaxTokens = 4096
+
+// invokeCmd represents the invoke command
+var invokeCmd = &cobra.Command{
+	Use:   "invoke --json",
+	Short: "Send one request read as JSON from stdin and print the response as JSON",
+	Long: `Send one request, read as a JSON object from stdin, and print the response
+as a JSON object on stdout, with nothing else written to stdout or stderr.
+It's meant for Lambda functions, CI steps and other scripts.
+
+The request has the prompt, and optionally the model (the configured
+model-id by default), a system prompt, inference params, and earlier turns
+of the conversation as history:
+
+  {
+    "prompt": "Summarize this build log: ...",
+    "model": "us.anthropic.claude-3-5-haiku-20241022-v1:0",
+    "system": "You are terse.",
+    "params": {"max_tokens": 512, "temperature": 0.2, "top_p": 0.9, "stop_sequences": ["END"]},
+    "history": [
+      {"role": "user", "content": "Hi"},
+      {"role": "assistant", "content": "Hello! How can I help?"}
+    ]
+  }
+
+The response has the same fields as prompt --output json. If the request
+fails, it has an error field instead of a response and chat-cli exits with
+status 1.
+
+> echo '{"prompt": "What is 2+2?"}' | chat-cli invoke --json`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if !asJSON {
+			log.Fatal("invoke reads its request and writes its response as JSON; pass --json")
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		result := invokeFromJSON(cmd.Context(), os.Stdin, region)
+		if err := writePromptResult(os.Stdout, result); err != nil {
+			os.Exit(1)
+		}
+		if result.Error != "" {
+			os.Exit(1)
+		}
+	},
+}
+
+// invokeRequest is what invoke --json reads.
+type invokeRequest struct {
+	Prompt  string          `json:"prompt"`
+	Model   string          `json:"model"`
+	System  string          `json:"system"`
+	Params  invokeParams    `json:"params"`
+	History []invokeMessage `json:"history"`
+}
+
+type invokeParams struct {
+	MaxTokens     *int32   `json:"max_tokens"`
+	Temperature   *float32 `json:"temperature"`
+	TopP          *float32 `json:"top_p"`
+	StopSequences []string `json:"stop_sequences"`
+}
+
+type invokeMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// invokeFromJSON reads a request from r and sends it, reporting any
+// failure in the result rather than logging it.
+func invokeFromJSON(ctx context.Context, r io.Reader, region string) promptResult {
+	req, err := parseInvokeRequest(r)
+	if err != nil {
+		return promptResult{Error: err.Error()}
+	}
+	if req.Model == "" {
+		req.Model = configuredModelID()
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return promptResult{Model: req.Model, Prompt: req.Prompt, Error: fmt.Sprintf("unable to load AWS config: %v", err)}
+	}
+	svc := bedrockruntime.NewFromConfig(cfg)
+
+	return runInvoke(ctx, func(ctx context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+		return converseWithFallbacks(ctx, svc, in)
+	}, req)
+}
+
+// parseInvokeRequest reads a single request, rejecting unknown fields so
+// a misspelled one isn't silently ignored.
+func parseInvokeRequest(r io.Reader) (invokeRequest, error) {
+	var req invokeRequest
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		if errors.Is(err, io.EOF) {
+			return req, errors.New("no request on stdin")
+		}
+		return req, fmt.Errorf("invalid request: %v", err)
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return req, errors.New("invalid request: prompt is required")
+	}
+	if err := checkInvokeHistory(req.History); err != nil {
+		return req, fmt.Errorf("invalid request: %v", err)
+	}
+	return req, nil
+}
+
+// checkInvokeHistory checks history takes turns, starting with the user
+// and ending with the assistant so the prompt can follow it.
+func checkInvokeHistory(history []invokeMessage) error {
+	for i, m := range history {
+		want := "user"
+		if i%2 == 1 {
+			want = "assistant"
+		}
+		if m.Role != want {
+			return fmt.Errorf("history[%d] has role %q; history must alternate user and assistant turns, starting with user", i, m.Role)
+		}
+		if strings.TrimSpace(m.Content) == "" {
+			return fmt.Errorf("history[%d] has no content", i)
+		}
+	}
+	if len(history)%2 == 1 {
+		return errors.New("history must end with an assistant turn, since the prompt is the next user turn")
+	}
+	return nil
+}
+
+// configuredModelID returns the model-id from the config file, or the
+// default model when there's no config, as in a Lambda function with no
+// home directory.
+func configuredModelID() string {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil || fm.InitializeViper() != nil {
+		return DefaultModelID
+	}
+	return fm.GetConfigValue("model-id", "", DefaultModelID).(string)
+}
+
+// runInvoke sends req with converse and describes the response.
+func runInvoke(ctx context.Context, converse func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error), req invokeRequest) promptResult {
+	maxTokens := int32(defaultInvokeMaxTokens)
+	if req.Params.MaxTokens != nil {
+		maxTokens = *req.Params.MaxTokens
+	}
+	inference := buildInferenceConfiguration(maxTokens, req.Params.Temperature, req.Params.TopP)
+	inference.StopSequences = req.Params.StopSequences
+
+	messages := make([]types.Message, 0, len(req.History)+1)
+	for _, m := range req.History {
+		messages = append(messages, types.Message{
+			Role:    types.ConversationRole(m.Role),
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: m.Content}},
+		})
+	}
+	messages = append(messages, userTextMessage(req.Prompt))
+
+	start := time.Now()
+	output, err := converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId:         aws.String(req.Model),
+		InferenceConfig: &inference,
+		System:          buildSystemContentBlocks(req.System),
+		Messages:        messages,
+	})
+	if err != nil {
+		return promptResult{Model: req.Model, Prompt: req.Prompt, Error: err.Error(), LatencyMs: time.Since(start).Milliseconds()}
+	}
+
+	var response string
+	if message, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+		response = messageText(message.Value)
+	}
+	usage := usageFromBedrock(output.Usage)
+	return newPromptResult(req.Model, req.Prompt, response, output.StopReason, &usage, time.Since(start))
+}
+
+func init() {
+	rootCmd.AddCommand(invokeCmd)
+	invokeCmd.Flags().Bool("json", false, "read the request from stdin and write the response to stdout as JSON")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestParseInvokeRequest(t *testing.T) {
+	req, err := parseInvokeRequest(strings.NewReader(`{
+		"prompt": "And 3+3?",
+		"model": "amazon.nova-micro-v1:0",
+		"params": {"max_tokens": 64, "temperature": 0.2},
+		"history": [{"role": "user", "content": "2+2?"}, {"role": "assistant", "content": "4"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Prompt != "And 3+3?" || req.Model != "amazon.nova-micro-v1:0" || *req.Params.MaxTokens != 64 || len(req.History) != 2 {
+		t.Errorf("req = %+v", req)
+	}
+
+	tests := []struct {
+		name, input, want string
+	}{
+		{"empty", "", "no request on stdin"},
+		{"not JSON", "hello", "invalid request"},
+		{"unknown field", `{"prompt": "hi", "temprature": 0.2}`, `unknown field "temprature"`},
+		{"no prompt", `{"model": "amazon.nova-micro-v1:0"}`, "prompt is required"},
+		{"history out of turn", `{"prompt": "hi", "history": [{"role": "assistant", "content": "hello"}]}`, "starting with user"},
+		{"history ending with user", `{"prompt": "hi", "history": [{"role": "user", "content": "hello"}]}`, "must end with an assistant turn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseInvokeRequest(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunInvoke(t *testing.T) {
+	var sent *bedrockruntime.ConverseInput
+	converse := func(ctx context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+		sent = in
+		return &bedrockruntime.ConverseOutput{
+			Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+				Role:    types.ConversationRoleAssistant,
+				Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "6"}},
+			}},
+			StopReason: types.StopReasonEndTurn,
+			Usage:      &types.TokenUsage{InputTokens: aws.Int32(12), OutputTokens: aws.Int32(1)},
+		}, nil
+	}
+
+	req := invokeRequest{
+		Prompt:  "And 3+3?",
+		Model:   "amazon.nova-micro-v1:0",
+		System:  "Answer with a number.",
+		Params:  invokeParams{StopSequences: []string{"END"}},
+		History: []invokeMessage{{Role: "user", Content: "2+2?"}, {Role: "assistant", Content: "4"}},
+	}
+	result := runInvoke(t.Context(), converse, req)
+
+	if result.Error != "" || result.Response != "6" || result.Model != req.Model || result.StopReason != "end_turn" {
+		t.Errorf("result = %+v", result)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 12 || result.Usage.OutputTokens != 1 {
+		t.Errorf("usage = %+v", result.Usage)
+	}
+
+	if len(sent.Messages) != 3 || sent.Messages[1].Role != types.ConversationRoleAssistant || messageText(sent.Messages[2]) != "And 3+3?" {
+		t.Errorf("messages = %+v", sent.Messages)
+	}
+	if aws.ToInt32(sent.InferenceConfig.MaxTokens) != defaultInvokeMaxTokens || sent.InferenceConfig.Temperature != nil || sent.InferenceConfig.StopSequences[0] != "END" {
+		t.Errorf("inference config = %+v", sent.InferenceConfig)
+	}
+	if len(sent.System) != 1 {
+		t.Errorf("system = %+v", sent.System)
+	}
+}
+
+func TestRunInvokeError(t *testing.T) {
+	converse := func(ctx context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+		return nil, errors.New("AccessDeniedException: no access")
+	}
+	result := runInvoke(t.Context(), converse, invokeRequest{Prompt: "hi", Model: "amazon.nova-micro-v1:0"})
+	if result.Error == "" || result.Response != "" || result.Model != "amazon.nova-micro-v1:0" {
+		t.Errorf("result = %+v", result)
+	}
+}
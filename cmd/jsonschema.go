@@ -0,0 +1,400 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// defaultJSONSchemaRetries is how many times prompt --json-schema asks the
+// model to fix a response that doesn't match the schema.
+const defaultJSONSchemaRetries = 2
+
+// loadJSONSchema reads the JSON Schema at path.
+func loadJSONSchema(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path) // nolint:gosec // the user's own schema file
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JSON schema: %w", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%s isn't a JSON schema: %v", path, err)
+	}
+	return schema, nil
+}
+
+// jsonSchemaInstruction is added to the system prompt to ask for a response
+// that matches schema.
+func jsonSchemaInstruction(schema map[string]interface{}) string {
+	data, _ := json.MarshalIndent(schema, "", "  ")
+	return "Respond with only a JSON value that conforms to the following JSON Schema, " +
+		"with no other text and no Markdown code fence:\n\n" + string(data)
+}
+
+// extractJSON finds the JSON value in a model's response, which may be
+// wrapped in a code fence or surrounded by a sentence or two despite being
+// asked not to.
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	if m := jsonFence.FindStringSubmatch(text); m != nil && json.Valid([]byte(m[1])) {
+		return m[1]
+	}
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+		return text[start : end+1]
+	}
+	return text
+}
+
+var jsonFence = regexp.MustCompile("(?s)```(?:json)?\\s*\n(.*?)\n\\s*```")
+
+// checkJSONResponse parses text as JSON and validates it against schema,
+// returning what's wrong with it.
+func checkJSONResponse(schema map[string]interface{}, text string) (string, []string) {
+	raw := extractJSON(text)
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return raw, []string{fmt.Sprintf("the response isn't valid JSON: %v", err)}
+	}
+	return raw, validateJSONSchema(schema, schema, value, "$")
+}
+
+// validateJSONSchema checks value against schema, returning a description
+// of each mismatch prefixed with its path. It covers the keywords schemas
+// for structured output commonly use: type, enum, const, properties,
+// required, additionalProperties, items, the length and range bounds,
+// pattern, allOf/anyOf/oneOf, and $ref to a local definition. Others are
+// ignored. root is the whole schema, which $ref points into.
+func validateJSONSchema(root, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := resolveSchemaRef(root, ref)
+		if err != nil {
+			return []string{fmt.Sprintf("%s: %v", path, err)}
+		}
+		return validateJSONSchema(root, target, value, path)
+	}
+
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := schema["type"]; ok {
+		var allowed []string
+		switch t := t.(type) {
+		case string:
+			allowed = []string{t}
+		case []interface{}:
+			for _, v := range t {
+				if s, ok := v.(string); ok {
+					allowed = append(allowed, s)
+				}
+			}
+		}
+		if !matchesSchemaType(value, allowed) {
+			fail("expected %s, got %s", strings.Join(allowed, " or "), jsonTypeName(value))
+			// the remaining keywords assume the right type
+			return problems
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsJSON(enum, value) {
+		fail("%s isn't one of %s", compactJSON(value), compactJSON(enum))
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, value) {
+		fail("expected %s, got %s", compactJSON(c), compactJSON(value))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		problems = append(problems, validateJSONObject(root, schema, v, path)...)
+	case []interface{}:
+		if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < n {
+			fail("expected at least %v items, got %d", n, len(v))
+		}
+		if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > n {
+			fail("expected at most %v items, got %d", n, len(v))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, validateJSONSchema(root, items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := schemaNumber(schema, "minLength"); ok && length < n {
+			fail("expected at least %v characters, got %v", n, length)
+		}
+		if n, ok := schemaNumber(schema, "maxLength"); ok && length > n {
+			fail("expected at most %v characters, got %v", n, length)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("%q doesn't match the pattern %q", v, pattern)
+			}
+		}
+	case float64:
+		if n, ok := schemaNumber(schema, "minimum"); ok && v < n {
+			fail("expected at least %v, got %v", n, v)
+		}
+		if n, ok := schemaNumber(schema, "maximum"); ok && v > n {
+			fail("expected at most %v, got %v", n, v)
+		}
+		if n, ok := schemaNumber(schema, "exclusiveMinimum"); ok && v <= n {
+			fail("expected more than %v, got %v", n, v)
+		}
+		if n, ok := schemaNumber(schema, "exclusiveMaximum"); ok && v >= n {
+			fail("expected less than %v, got %v", n, v)
+		}
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if s, ok := sub.(map[string]interface{}); ok {
+				problems = append(problems, validateJSONSchema(root, s, value, path)...)
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && countMatchingSchemas(root, anyOf, value, path) == 0 {
+		fail("doesn't match any of the allowed schemas (anyOf)")
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if n := countMatchingSchemas(root, oneOf, value, path); n != 1 {
+			fail("matches %d of the schemas in oneOf, expected exactly 1", n)
+		}
+	}
+
+	return problems
+}
+
+func validateJSONObject(root, schema map[string]interface{}, obj map[string]interface{}, path string) []string {
+	var problems []string
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if prop, ok := properties[key].(map[string]interface{}); ok {
+			problems = append(problems, validateJSONSchema(root, prop, obj[key], childPath)...)
+			continue
+		}
+		if _, declared := properties[key]; declared {
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				problems = append(problems, fmt.Sprintf("%s: property %q isn't allowed", path, key))
+			}
+		case map[string]interface{}:
+			problems = append(problems, validateJSONSchema(root, extra, obj[key], childPath)...)
+		}
+	}
+	return problems
+}
+
+// resolveSchemaRef follows a reference within the schema, such as
+// #/$defs/address.
+func resolveSchemaRef(root map[string]interface{}, ref string) (map[string]interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("only references within the schema are supported, not %q", ref)
+	}
+	node := root
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		next, ok := node[part].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference %q doesn't point to a schema", ref)
+		}
+		node = next
+	}
+	return node, nil
+}
+
+func countMatchingSchemas(root map[string]interface{}, schemas []interface{}, value interface{}, path string) int {
+	n := 0
+	for _, sub := range schemas {
+		if s, ok := sub.(map[string]interface{}); ok && len(validateJSONSchema(root, s, value, path)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesSchemaType(value interface{}, allowed []string) bool {
+	for _, t := range allowed {
+		switch t {
+		case "integer":
+			if f, ok := value.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		default:
+			if jsonTypeName(value) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonTypeName names the JSON type of a decoded value, as JSON Schema does.
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	n, ok := schema[key].(float64)
+	return n, ok
+}
+
+func containsJSON(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if jsonEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	return compactJSON(a) == compactJSON(b)
+}
+
+func compactJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// structuredResponse sends input and checks the response against schema.
+// A response that doesn't match is sent back with what's wrong with it, up
+// to retries times, before giving up. It returns the JSON from the last
+// response, the last response itself, and the usage of every attempt.
+// onRetry is called before each retry.
+func structuredResponse(
+	ctx context.Context,
+	converse func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error),
+	input *bedrockruntime.ConverseInput,
+	schema map[string]interface{},
+	retries int,
+	onRetry func(attempt int, problems []string),
+) (string, *bedrockruntime.ConverseOutput, tokenUsage, error) {
+	check := func(text string) (string, []string) {
+		return checkJSONResponse(schema, text)
+	}
+	raw, output, usage, err := checkedResponse(ctx, converse, input, retries, "the JSON schema", "Reply with only the corrected JSON.", check, onRetry)
+	if err != nil {
+		return raw, output, usage, err
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(raw), "", "  "); err == nil {
+		raw = pretty.String()
+	}
+	return raw, output, usage, nil
+}
+
+// checkedResponse sends input and passes the response's text to check,
+// which returns the part of it to keep and what's wrong with it. A response
+// with problems is sent back, saying it doesn't match want and asking for
+// fix, up to retries times, before giving up. It returns what check kept
+// from the last response, the last response itself, and the usage of every
+// attempt. onRetry is called before each retry.
+func checkedResponse(
+	ctx context.Context,
+	converse func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error),
+	input *bedrockruntime.ConverseInput,
+	retries int,
+	want, fix string,
+	check func(text string) (string, []string),
+	onRetry func(attempt int, problems []string),
+) (string, *bedrockruntime.ConverseOutput, tokenUsage, error) {
+	var usage tokenUsage
+	for attempt := 0; ; attempt++ {
+		output, err := converse(ctx, input)
+		if err != nil {
+			return "", nil, usage, err
+		}
+		usage.add(usageFromBedrock(output.Usage))
+		if output.StopReason == types.StopReasonGuardrailIntervened {
+			// the guardrail's message won't become valid by asking again
+			return "", output, usage, fmt.Errorf("the guardrail blocked the response")
+		}
+
+		msg, ok := output.Output.(*types.ConverseOutputMemberMessage)
+		if !ok {
+			return "", output, usage, fmt.Errorf("the response had no message")
+		}
+		kept, problems := check(messageText(msg.Value))
+		if len(problems) == 0 {
+			return kept, output, usage, nil
+		}
+		if attempt >= retries {
+			return kept, output, usage, fmt.Errorf("the response doesn't match %s after %d attempts:\n  %s", want, attempt+1, strings.Join(problems, "\n  "))
+		}
+		if onRetry != nil {
+			onRetry(attempt+1, problems)
+		}
+
+		// the model sees its own answer and what to fix
+		input.Messages = append(input.Messages, msg.Value, types.Message{
+			Role: types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{
+				Value: "That response doesn't match " + want + ":\n- " + strings.Join(problems, "\n- ") +
+					"\n\n" + fix,
+			}},
+		})
+	}
+}
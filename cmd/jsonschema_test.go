@@ -0,0 +1,189 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+const testPersonSchema = `{
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "age": {"type": "integer", "minimum": 0},
+    "role": {"enum": ["admin", "user"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+    "address": {"$ref": "#/$defs/address"}
+  },
+  "required": ["name", "age"],
+  "additionalProperties": false,
+  "$defs": {
+    "address": {
+      "type": "object",
+      "properties": {"zip": {"type": "string", "pattern": "^[0-9]{5}$"}},
+      "required": ["zip"]
+    }
+  }
+}`
+
+func testSchema(t *testing.T, doc string) map[string]interface{} {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := loadJSONSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := testSchema(t, testPersonSchema)
+
+	cases := []struct {
+		doc  string
+		want []string
+	}{
+		{`{"name": "Ada", "age": 36, "role": "admin", "tags": ["x"], "address": {"zip": "02139"}}`, nil},
+		{`{"name": "Ada"}`, []string{`$: missing required property "age"`}},
+		{`{"name": "", "age": 3.5}`, []string{"$.age: expected integer, got number", "$.name: expected at least 1 characters, got 0"}},
+		{`{"name": "Ada", "age": -1, "role": "root"}`, []string{"$.age: expected at least 0, got -1", `$.role: "root" isn't one of ["admin","user"]`}},
+		{`{"name": "Ada", "age": 1, "tags": ["a", 2, "c"]}`, []string{"$.tags: expected at most 2 items, got 3", "$.tags[1]: expected string, got number"}},
+		{`{"name": "Ada", "age": 1, "address": {"zip": "abc"}}`, []string{`$.address.zip: "abc" doesn't match the pattern "^[0-9]{5}$"`}},
+		{`{"name": "Ada", "age": 1, "nickname": "A"}`, []string{`$: property "nickname" isn't allowed`}},
+		{`["Ada"]`, []string{"$: expected object, got array"}},
+	}
+	for _, c := range cases {
+		var value interface{}
+		if err := json.Unmarshal([]byte(c.doc), &value); err != nil {
+			t.Fatal(err)
+		}
+		if got := validateJSONSchema(schema, schema, value, "$"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s:\n got %q\nwant %q", c.doc, got, c.want)
+		}
+	}
+}
+
+func TestValidateJSONSchemaCombinators(t *testing.T) {
+	schema := testSchema(t, `{"oneOf": [{"type": "string"}, {"type": "integer"}, {"type": "number"}]}`)
+	var value interface{} = "x"
+	if got := validateJSONSchema(schema, schema, value, "$"); got != nil {
+		t.Errorf("expected a string to match once, got %q", got)
+	}
+	// an integer is a number too
+	value = 3.0
+	if got := validateJSONSchema(schema, schema, value, "$"); len(got) != 1 || !strings.Contains(got[0], "matches 2 of the schemas") {
+		t.Errorf("expected oneOf to fail, got %q", got)
+	}
+
+	schema = testSchema(t, `{"anyOf": [{"type": "null"}, {"type": "boolean"}]}`)
+	value = "no"
+	if got := validateJSONSchema(schema, schema, value, "$"); len(got) != 1 {
+		t.Errorf("expected anyOf to fail, got %q", got)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"a": 1}`:                                 `{"a": 1}`,
+		"```json\n{\"a\": 1}\n```":                 `{"a": 1}`,
+		"Here you go:\n```\n[1, 2]\n```\nEnjoy!":   `[1, 2]`,
+		`Sure! {"a": {"b": 2}} Let me know.`:       `{"a": {"b": 2}}`,
+		"not json at all":                          "not json at all",
+		"  {\"a\": 1}  \n":                         `{"a": 1}`,
+		"```json\n{\"a\": [1,\n 2]}\n```\ntrailer": "{\"a\": [1,\n 2]}",
+	}
+	for in, want := range cases {
+		if got := extractJSON(in); got != want {
+			t.Errorf("extractJSON(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStructuredResponse(t *testing.T) {
+	schema := testSchema(t, testPersonSchema)
+	reply := func(text string) *bedrockruntime.ConverseOutput {
+		return &bedrockruntime.ConverseOutput{
+			Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+				Role:    types.ConversationRoleAssistant,
+				Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: text}},
+			}},
+			Usage: &types.TokenUsage{InputTokens: aws.Int32(10), OutputTokens: aws.Int32(5)},
+		}
+	}
+
+	t.Run("retries with the problems until the response matches", func(t *testing.T) {
+		replies := []string{`{"name": "Ada"}`, "```json\n{\"name\": \"Ada\", \"age\": 36}\n```"}
+		var sent []*bedrockruntime.ConverseInput
+		converse := func(_ context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+			sent = append(sent, in)
+			return reply(replies[len(sent)-1]), nil
+		}
+		var retried []string
+		input := &bedrockruntime.ConverseInput{Messages: []types.Message{{Role: types.ConversationRoleUser}}}
+
+		out, _, usage, err := structuredResponse(context.Background(), converse, input, schema, 2, func(_ int, problems []string) {
+			retried = append(retried, problems...)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "{\n  \"name\": \"Ada\",\n  \"age\": 36\n}" {
+			t.Errorf("unexpected output %q", out)
+		}
+		if usage.input != 20 || usage.output != 10 {
+			t.Errorf("expected usage from both attempts, got %+v", usage)
+		}
+		if len(retried) != 1 || retried[0] != `$: missing required property "age"` {
+			t.Errorf("unexpected retry problems %q", retried)
+		}
+
+		// the retry carries the rejected answer and the feedback
+		msgs := input.Messages
+		if len(msgs) != 3 || msgs[1].Role != types.ConversationRoleAssistant || !strings.Contains(messageText(msgs[2]), `missing required property "age"`) {
+			t.Errorf("unexpected retry conversation %#v", msgs)
+		}
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		calls := 0
+		converse := func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+			calls++
+			return reply("I can't do that."), nil
+		}
+		input := &bedrockruntime.ConverseInput{}
+		_, _, _, err := structuredResponse(context.Background(), converse, input, schema, 1, nil)
+		if err == nil || !strings.Contains(err.Error(), "after 2 attempts") || !strings.Contains(err.Error(), "isn't valid JSON") {
+			t.Errorf("unexpected error %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 attempts, got %d", calls)
+		}
+	})
+}
+
+func TestLoadJSONSchemaErrors(t *testing.T) {
+	if _, err := loadJSONSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte("type: object"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadJSONSchema(path); err == nil || !strings.Contains(err.Error(), "isn't a JSON schema") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
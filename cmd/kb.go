@@ -0,0 +1,508 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/documents"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/utils"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+const (
+	// defaultKBChunkTokens is the size documents are split into when they're
+	// added to a knowledge base. Smaller chunks match questions more
+	// closely; larger ones carry more context with each match.
+	defaultKBChunkTokens = 500
+	// defaultKBTopK is how many chunks --kb adds to a prompt.
+	defaultKBTopK = 4
+)
+
+// kbCmd represents the kb command
+var kbCmd = &cobra.Command{
+	Use:   "kb",
+	Short: "Manage local knowledge bases for retrieval-augmented prompts",
+	Long: `Manage local knowledge bases. A knowledge base is a set of documents split into
+chunks and turned into embeddings with a Titan or Cohere embedding model, stored in
+the chat-cli database.
+
+Pass --kb <name> to chat or prompt to look up the chunks most relevant to each
+message and send them along with it.
+
+> chat-cli kb add handbook ./docs employee-handbook.pdf
+> chat-cli prompt --kb handbook "How many days of leave do I get?"
+> chat-cli --kb handbook`,
+}
+
+// kbAddCmd represents the kb add command
+var kbAddCmd = &cobra.Command{
+	Use:   "add <name> <path>...",
+	Short: "Add documents to a knowledge base",
+	Long: `Add pdf, docx, html, txt, md, and csv files to a knowledge base, creating it if it
+doesn't exist. Directories are searched for those files, respecting .gitignore.
+Adding a file that's already in the knowledge base replaces its chunks, so run add
+again after a document changes.
+
+Every document in a knowledge base is embedded with the same model, chosen when the
+knowledge base is created.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		modelID, err := cmd.Flags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		chunkTokens, err := cmd.Flags().GetInt("chunk-tokens")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if chunkTokens <= 0 {
+			log.Fatal("--chunk-tokens must be positive")
+		}
+
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if concurrency < 1 {
+			log.Fatal("--concurrency must be at least 1")
+		}
+
+		if _, err := embeddingProvider(modelID); err != nil {
+			log.Fatal(err)
+		}
+
+		paths, err := kbSourcePaths(args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(paths) == 0 {
+			log.Fatal("no pdf, docx, html, txt, md, or csv files found")
+		}
+
+		repo, closeDB := openKnowledgeBaseRepository()
+		defer closeDB()
+
+		existing, err := repo.ModelId(cmd.Context(), name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if existing != "" && existing != modelID {
+			if cmd.Flags().Changed("model-id") {
+				log.Fatalf("knowledge base %s is embedded with %s; add to it with the same model, or delete it first", name, existing)
+			}
+			modelID = existing
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+		invoke := invokeModelWith(bedrockruntime.NewFromConfig(cfg))
+
+		added, chunks := 0, 0
+		for _, path := range paths {
+			n, err := addKBSource(cmd.Context(), repo, invoke, name, embedOptions{modelID: modelID, concurrency: concurrency}, path, chunkTokens)
+			if errors.Is(err, documents.ErrNoText) {
+				fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
+				continue
+			}
+			if err != nil {
+				log.Fatalf("unable to add %s: %v", path, err)
+			}
+			fmt.Printf("Added %s (%d chunks)\n", path, n)
+			added++
+			chunks += n
+		}
+
+		fmt.Printf("Knowledge base %s: added %d document(s), %d chunk(s) with %s\n", name, added, chunks, modelID)
+	},
+}
+
+// kbListCmd represents the kb list command
+var kbListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List knowledge bases",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo, closeDB := openKnowledgeBaseRepository()
+		defer closeDB()
+
+		summaries, err := repo.List(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(summaries) == 0 {
+			fmt.Println("No knowledge bases yet. Create one with: chat-cli kb add <name> <path>...")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tMODEL\tDOCUMENTS\tCHUNKS\tUPDATED")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", s.Name, s.ModelId, s.Sources, s.Chunks, s.Updated)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing output: %v", err)
+		}
+	},
+}
+
+// kbSearchCmd represents the kb search command
+var kbSearchCmd = &cobra.Command{
+	Use:   "search <name> <query>",
+	Short: "Show the chunks of a knowledge base that best match a query",
+	Long: `Show the chunks of a knowledge base that best match a query, with their similarity
+scores. These are the chunks --kb would send with the same message.
+
+With --rerank (or kb.rerank set), a Bedrock reranking model chooses them from
+a wider set instead, and each is shown with its relevance score and the place
+it had by similarity, so you can see whether reranking helps.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		topK, err := cmd.Flags().GetInt("top-k")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		rerank, err := cmd.Flags().GetBool("rerank")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		repo, closeDB := openKnowledgeBaseRepository()
+		defer closeDB()
+
+		retriever, err := loadKBRetriever(cmd.Context(), repo, args[0], topK, invokeModelWith(bedrockruntime.NewFromConfig(cfg)))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+		retriever.reranker, err = configuredKBReranker(fm, cfg, rerank)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		matches, err := retriever.retrieve(cmd.Context(), args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range matches {
+			if m.reranked {
+				fmt.Printf("%.3f  %s (part %d; similarity %.3f, was %d)\n  %s\n\n", m.relevance, m.chunk.Source, m.chunk.Part, m.score, m.rank, truncate(oneLine(m.chunk.Content), 160))
+				continue
+			}
+			fmt.Printf("%.3f  %s (part %d)\n  %s\n\n", m.score, m.chunk.Source, m.chunk.Part, truncate(oneLine(m.chunk.Content), 160))
+		}
+		if line := kbRerankLine(matches, topK); line != "" {
+			fmt.Println(line)
+		}
+	},
+}
+
+// kbDeleteCmd represents the kb delete command
+var kbDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a knowledge base",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		repo, closeDB := openKnowledgeBaseRepository()
+		defer closeDB()
+
+		if !force {
+			if !confirmAction(os.Stdin, os.Stdout, fmt.Sprintf("Delete knowledge base %s?", name)) {
+				fmt.Println("Aborted.")
+				return
+			}
+		}
+
+		deleted, err := repo.Delete(cmd.Context(), name)
+		if errors.Is(err, repository.ErrKnowledgeBaseNotFound) {
+			log.Fatalf("no knowledge base named %s (see chat-cli kb list)", name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Deleted knowledge base %s (%d chunks)\n", name, deleted)
+	},
+}
+
+// openKnowledgeBaseRepository loads config and opens the database for the
+// kb commands. The returned func closes the database.
+func openKnowledgeBaseRepository() (*repository.KnowledgeBaseRepository, func()) {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+
+	database, err := openDatabase(fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return repository.NewKnowledgeBaseRepository(database), func() {
+		if err := database.Close(); err != nil {
+			log.Printf("Warning: failed to close database: %v", err)
+		}
+	}
+}
+
+// kbSourcePaths expands args into the documents to add: files are taken as
+// given, and directories are searched for files documents can read.
+func kbSourcePaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !documents.Supported(arg) {
+				return nil, fmt.Errorf("%s: %w (supported: pdf, docx, html, txt, md, csv)", arg, documents.ErrUnsupportedFormat)
+			}
+			paths = append(paths, arg)
+			continue
+		}
+
+		dir, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+		root := utils.FindGitBoundary(dir)
+		if root == "" {
+			root = dir
+		}
+		ignore := utils.LoadIgnoreMatcher(root, ".gitignore")
+
+		err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, relErr := filepath.Rel(root, p)
+			if relErr != nil || p == dir {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") || ignore.Ignored(rel, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && documents.Supported(p) {
+				paths = append(paths, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// addKBSource splits the document at path into chunks of about chunkTokens,
+// embeds them with opts.modelID, and stores them in the knowledge base name
+// in place of any earlier copy of the document. It returns the number of
+// chunks stored.
+func addKBSource(ctx context.Context, repo *repository.KnowledgeBaseRepository, invoke invokeModelFunc, name string, opts embedOptions, path string, chunkTokens int) (int, error) {
+	doc, err := documents.Load(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// sources are stored by absolute path, so the same file added from
+	// another directory replaces rather than duplicates it
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+
+	docChunks := doc.Chunks(chunkTokens)
+	texts := make([]string, len(docChunks))
+	for i, c := range docChunks {
+		texts[i] = c.Text
+	}
+
+	opts.normalize, opts.inputType = true, "search_document"
+	vectors, err := embedTexts(ctx, invoke, opts, texts)
+	if err != nil {
+		return 0, err
+	}
+
+	chunks := make([]repository.KBChunk, len(docChunks))
+	for i, c := range docChunks {
+		chunks[i] = repository.KBChunk{Part: c.Part, Content: c.Text, ModelId: opts.modelID, Embedding: vectors[i], StartLine: c.StartLine, EndLine: c.EndLine}
+	}
+	if err := repo.ReplaceSource(ctx, name, source, chunks); err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+// kbRetriever finds the chunks of a knowledge base most similar to a
+// message. The knowledge base is read once, when it is loaded.
+type kbRetriever struct {
+	name   string
+	topK   int
+	chunks []repository.KBChunk
+	invoke invokeModelFunc
+	// reranker, when set, picks the topK from a wider set of the most
+	// similar chunks; see kbReranker.
+	reranker *kbReranker
+}
+
+// kbMatch is a chunk retrieved for a message and its cosine similarity to
+// it. rank is its place by similarity, from 1; when reranked, relevance is
+// the reranking model's score.
+type kbMatch struct {
+	chunk     repository.KBChunk
+	score     float64
+	rank      int
+	relevance float64
+	reranked  bool
+}
+
+// loadKBRetriever reads the knowledge base name for retrieval.
+func loadKBRetriever(ctx context.Context, repo *repository.KnowledgeBaseRepository, name string, topK int, invoke invokeModelFunc) (*kbRetriever, error) {
+	if topK <= 0 {
+		return nil, fmt.Errorf("--kb-top-k must be positive")
+	}
+
+	chunks, err := repo.Chunks(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no knowledge base named %s (see chat-cli kb list)", name)
+	}
+	return &kbRetriever{name: name, topK: topK, chunks: chunks, invoke: invoke}, nil
+}
+
+// retrieve embeds query with the knowledge base's model and returns its
+// topK closest chunks, best first. With a reranker, the topK are the
+// reranker's choice from the closest topK*rerankCandidates; if reranking
+// fails, the closest are used with a warning.
+func (r *kbRetriever) retrieve(ctx context.Context, query string) ([]kbMatch, error) {
+	opts := embedOptions{modelID: r.chunks[0].ModelId, normalize: true, inputType: "search_query"}
+	vectors, err := embedTexts(ctx, r.invoke, opts, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("unable to search knowledge base %s: %w", r.name, err)
+	}
+
+	matches := make([]kbMatch, len(r.chunks))
+	for i, chunk := range r.chunks {
+		matches[i] = kbMatch{chunk: chunk, score: utils.CosineSimilarity(vectors[0], chunk.Embedding)}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	for i := range matches {
+		matches[i].rank = i + 1
+	}
+
+	if r.reranker != nil && len(matches) > 1 {
+		candidates := matches[:min(len(matches), r.topK*rerankCandidates)]
+		reranked, err := r.reranker.rerank(ctx, query, candidates, r.topK)
+		if err == nil {
+			return reranked, nil
+		}
+		log.Printf("Warning: unable to rerank knowledge base %s, using similarity order: %v", r.name, err)
+	}
+
+	if len(matches) > r.topK {
+		matches = matches[:r.topK]
+	}
+	return matches, nil
+}
+
+// kbContentBlock wraps retrieved chunks in a <kb_context> tag to go ahead
+// of the message they were retrieved for. Each excerpt has an ID the reply
+// can cite it by; with requireCitations, the model is told it must.
+func kbContentBlock(name string, matches []kbMatch, requireCitations bool) types.ContentBlock {
+	instruction := "These excerpts from a knowledge base may help with the message that follows. Use them where they're relevant and say which source you used."
+	if requireCitations {
+		instruction = kbCitationInstruction
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<kb_context name=%q>\n%s\n", name, instruction)
+	for i, m := range matches {
+		fmt.Fprintf(&b, "<excerpt id=%q source=%q part=\"%d\">\n%s\n</excerpt>\n", kbCitationID(i), m.chunk.Source, m.chunk.Part, m.chunk.Content)
+	}
+	b.WriteString("</kb_context>")
+	return &types.ContentBlockMemberText{Value: b.String()}
+}
+
+// kbSourcesLine names the documents matches came from, for showing which
+// were used.
+func kbSourcesLine(matches []kbMatch) string {
+	parts := make([]string, len(matches))
+	for i, m := range matches {
+		parts[i] = fmt.Sprintf("%s#%d", filepath.Base(m.chunk.Source), m.chunk.Part)
+	}
+	return "kb: " + strings.Join(parts, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(kbCmd)
+	kbCmd.AddCommand(kbAddCmd)
+	kbCmd.AddCommand(kbListCmd)
+	kbCmd.AddCommand(kbSearchCmd)
+	kbCmd.AddCommand(kbDeleteCmd)
+
+	kbAddCmd.Flags().String("model-id", defaultEmbedModelID, "the embedding model for a new knowledge base (amazon.titan-embed-* or cohere.embed-*)")
+	kbAddCmd.Flags().Int("chunk-tokens", defaultKBChunkTokens, "approximate size of each chunk, in tokens")
+	kbAddCmd.Flags().Int("concurrency", defaultBatchConcurrency, "most embedding requests to send at once; lowered automatically while Bedrock is throttling")
+	kbSearchCmd.Flags().Int("top-k", defaultKBTopK, "how many chunks to show")
+	kbSearchCmd.Flags().Bool("rerank", false, "choose the chunks with a Bedrock reranking model (see kb.rerank)")
+	kbDeleteCmd.Flags().BoolP("force", "f", false, "delete without asking for confirmation")
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func newKBTestRepo(t *testing.T) *repository.KnowledgeBaseRepository {
+	t.Helper()
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = database.Close()
+	})
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return repository.NewKnowledgeBaseRepository(database)
+}
+
+// fakeTopicModel embeds text as how often it mentions cats and dogs, so
+// similarity follows the topic.
+func fakeTopicModel() (invokeModelFunc, *[]map[string]interface{}) {
+	return fakeEmbedModel(func(body map[string]interface{}) string {
+		text := strings.ToLower(body["inputText"].(string))
+		return fmt.Sprintf(`{"embedding": [%d, %d]}`, strings.Count(text, "cat"), strings.Count(text, "dog"))
+	})
+}
+
+func TestKBSourcePaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "b.txt", "main.go", ".hidden.md", "sub/c.html", "sub/skip.md", ".git/config.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("text"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("sub/skip.md\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := kbSourcePaths([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "sub", "c.html")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("kbSourcePaths = %v, want %v", paths, want)
+	}
+
+	if _, err := kbSourcePaths([]string{filepath.Join(dir, "main.go")}); err == nil {
+		t.Error("expected an error for an unsupported file")
+	}
+	if _, err := kbSourcePaths([]string{filepath.Join(dir, "missing.md")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestKBAddAndRetrieve(t *testing.T) {
+	repo := newKBTestRepo(t)
+	invoke, requests := fakeTopicModel()
+	model := "amazon.titan-embed-text-v2:0"
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pets.md")
+	content := "Cats sleep most of the day. A cat purrs.\n\nDogs need walks. A dog barks.\n\nBoth cats and dogs like treats, and so does a dog."
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// small chunks, so each paragraph is its own
+	n, err := addKBSource(context.Background(), repo, invoke, "pets", embedOptions{modelID: model}, path, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 chunks, got %d", n)
+	}
+	if got := (*requests)[0]["modelId"]; got != model {
+		t.Errorf("expected chunks embedded with %s, got %v", model, got)
+	}
+
+	// adding the file again replaces its chunks
+	if n, err = addKBSource(context.Background(), repo, invoke, "pets", embedOptions{modelID: model}, path, 12); err != nil || n != 3 {
+		t.Fatalf("re-adding: %d, %v", n, err)
+	}
+
+	retriever, err := loadKBRetriever(t.Context(), repo, "pets", 2, invoke)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retriever.chunks) != 3 {
+		t.Fatalf("expected re-adding to replace chunks, got %d", len(retriever.chunks))
+	}
+
+	matches, err := retriever.retrieve(context.Background(), "Why does my dog bark?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected the top 2 chunks, got %d", len(matches))
+	}
+	if !strings.HasPrefix(matches[0].chunk.Content, "Dogs need walks") || matches[0].score < matches[1].score {
+		t.Errorf("expected the dog paragraph first, got %+v", matches)
+	}
+	if last := (*requests)[len(*requests)-1]; last["inputText"] != "Why does my dog bark?" || last["modelId"] != model {
+		t.Errorf("expected the query embedded with the knowledge base's model, got %v", last)
+	}
+
+	block, ok := kbContentBlock("pets", matches, false).(*types.ContentBlockMemberText)
+	if !ok {
+		t.Fatal("expected a text block")
+	}
+	for _, want := range []string{`<kb_context name="pets">`, `id="kb1" source="` + path + `" part="2"`, "Dogs need walks", "</kb_context>"} {
+		if !strings.Contains(block.Value, want) {
+			t.Errorf("expected %q in %s", want, block.Value)
+		}
+	}
+	if got := kbSourcesLine(matches[:1]); got != "kb: pets.md#2" {
+		t.Errorf("kbSourcesLine = %q", got)
+	}
+}
+
+func TestLoadKBRetrieverMissing(t *testing.T) {
+	repo := newKBTestRepo(t)
+	invoke, _ := fakeTopicModel()
+
+	if _, err := loadKBRetriever(t.Context(), repo, "nope", defaultKBTopK, invoke); err == nil || !strings.Contains(err.Error(), "no knowledge base named nope") {
+		t.Errorf("expected a missing knowledge base error, got %v", err)
+	}
+	if _, err := loadKBRetriever(t.Context(), repo, "nope", 0, invoke); err == nil {
+		t.Error("expected an error for a zero top-k")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// kbCitationInstruction replaces the usual kb_context instruction with
+// --require-citations.
+const kbCitationInstruction = "Answer the message that follows from these excerpts from a knowledge base. " +
+	"Cite the excerpt behind every statement you take from one by its id in square brackets, such as [kb1] or [kb1, kb3]. " +
+	"If the excerpts don't answer the message, say so instead of answering from memory."
+
+// kbCitationPattern matches a citation in a reply: one or more excerpt IDs
+// in square brackets.
+var kbCitationPattern = regexp.MustCompile(`\[\s*(kb\d+(?:\s*,\s*kb\d+)*)\s*\]`)
+
+// kbCitationID is the ID of the i'th retrieved chunk in a kb_context block.
+func kbCitationID(i int) string {
+	return "kb" + strconv.Itoa(i+1)
+}
+
+// kbCitations is what a reply cited of the chunks retrieved for it.
+type kbCitations struct {
+	// cited has one entry per retrieved chunk, true if the reply cites it.
+	cited []bool
+	// unknown are the IDs the reply cites that weren't retrieved.
+	unknown []string
+}
+
+// checkKBCitations finds the citations of the retrieved matches in reply.
+func checkKBCitations(reply string, matches []kbMatch) kbCitations {
+	c := kbCitations{cited: make([]bool, len(matches))}
+	seen := map[string]bool{}
+	for _, group := range kbCitationPattern.FindAllStringSubmatch(reply, -1) {
+		for _, id := range strings.Split(group[1], ",") {
+			id = strings.TrimSpace(id)
+			n, _ := strconv.Atoi(strings.TrimPrefix(id, "kb"))
+			switch {
+			case n >= 1 && n <= len(matches):
+				c.cited[n-1] = true
+			case !seen[id]:
+				c.unknown = append(c.unknown, id)
+			}
+			seen[id] = true
+		}
+	}
+	return c
+}
+
+// grounded reports whether the reply cites at least one retrieved chunk.
+func (c kbCitations) grounded() bool {
+	for _, cited := range c.cited {
+		if cited {
+			return true
+		}
+	}
+	return false
+}
+
+// warning flags a reply that cites none of the retrieved chunks, or cites
+// ones that weren't retrieved; it's "" for a reply that's grounded.
+func (c kbCitations) warning() string {
+	var problems []string
+	if !c.grounded() {
+		problems = append(problems, "the answer cites none of the retrieved sources, so it may not be grounded in them")
+	}
+	if len(c.unknown) > 0 {
+		problems = append(problems, fmt.Sprintf("it cites %s, which weren't retrieved", strings.Join(c.unknown, ", ")))
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+	return "Warning: " + strings.Join(problems, "; ")
+}
+
+// kbSourceLocation is where a chunk came from: its file and lines, or its
+// part for a chunk added before line ranges were kept.
+func kbSourceLocation(m kbMatch) string {
+	switch {
+	case m.chunk.StartLine == 0:
+		return fmt.Sprintf("%s, part %d", m.chunk.Source, m.chunk.Part)
+	case m.chunk.StartLine == m.chunk.EndLine:
+		return fmt.Sprintf("%s, line %d", m.chunk.Source, m.chunk.StartLine)
+	default:
+		return fmt.Sprintf("%s, lines %d-%d", m.chunk.Source, m.chunk.StartLine, m.chunk.EndLine)
+	}
+}
+
+// kbSourcesSection lists the retrieved chunks by ID, with their files and
+// line ranges, marking those the reply didn't cite.
+func kbSourcesSection(matches []kbMatch, c kbCitations) string {
+	var b strings.Builder
+	b.WriteString("Sources:")
+	for i, m := range matches {
+		fmt.Fprintf(&b, "\n[%s] %s", kbCitationID(i), kbSourceLocation(m))
+		if !c.cited[i] {
+			b.WriteString(" (not cited)")
+		}
+	}
+	return b.String()
+}
+
+// printKBCitations prints the sources section after reply to out, and any
+// warning about its citations to warnings.
+func printKBCitations(out, warnings io.Writer, reply string, matches []kbMatch) {
+	c := checkKBCitations(reply, matches)
+	fmt.Fprintf(out, "\n%s\n", kbSourcesSection(matches, c))
+	if line := c.warning(); line != "" {
+		fmt.Fprintf(warnings, "\033[33m%s\033[0m\n", line)
+	}
+}
+
+// promptCitations is the --require-citations report in --output json.
+type promptCitations struct {
+	Grounded bool           `json:"grounded"`
+	Sources  []promptSource `json:"sources"`
+	// Unknown are cited IDs that weren't retrieved.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+type promptSource struct {
+	ID        string `json:"id"`
+	Source    string `json:"source"`
+	Part      int    `json:"part"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Cited     bool   `json:"cited"`
+}
+
+func newPromptCitations(matches []kbMatch, c kbCitations) *promptCitations {
+	report := &promptCitations{Grounded: c.grounded(), Sources: []promptSource{}, Unknown: c.unknown}
+	for i, m := range matches {
+		report.Sources = append(report.Sources, promptSource{
+			ID:        kbCitationID(i),
+			Source:    m.chunk.Source,
+			Part:      m.chunk.Part,
+			StartLine: m.chunk.StartLine,
+			EndLine:   m.chunk.EndLine,
+			Cited:     c.cited[i],
+		})
+	}
+	return report
+}
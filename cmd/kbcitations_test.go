@@ -0,0 +1,90 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func citationTestMatches() []kbMatch {
+	return []kbMatch{
+		{chunk: repository.KBChunk{Source: "/docs/leave.md", Part: 2, StartLine: 12, EndLine: 20}},
+		{chunk: repository.KBChunk{Source: "/docs/pay.md", Part: 1, StartLine: 4, EndLine: 4}},
+		// added before line ranges were kept
+		{chunk: repository.KBChunk{Source: "/docs/old.pdf", Part: 3}},
+	}
+}
+
+func TestCheckKBCitations(t *testing.T) {
+	matches := citationTestMatches()
+
+	c := checkKBCitations("You get 25 days [kb1], paid monthly [ kb1, kb3 ]. See also [kb9] and [kb9].", matches)
+	if !c.cited[0] || c.cited[1] || !c.cited[2] {
+		t.Errorf("expected kb1 and kb3 cited, got %v", c.cited)
+	}
+	if len(c.unknown) != 1 || c.unknown[0] != "kb9" {
+		t.Errorf("expected kb9 reported once as unknown, got %v", c.unknown)
+	}
+	if !c.grounded() {
+		t.Error("expected an answer citing kb1 to be grounded")
+	}
+	if got := c.warning(); !strings.Contains(got, "cites kb9, which weren't retrieved") || strings.Contains(got, "none") {
+		t.Errorf("unexpected warning %q", got)
+	}
+
+	// markdown links and other brackets aren't citations
+	c = checkKBCitations("See [the handbook](https://example.com) and [1].", matches)
+	if c.grounded() || len(c.unknown) != 0 {
+		t.Errorf("expected no citations, got %+v", c)
+	}
+	if got := c.warning(); !strings.Contains(got, "cites none of the retrieved sources") {
+		t.Errorf("expected an ungrounded warning, got %q", got)
+	}
+
+	if got := checkKBCitations("Yes [kb2].", matches).warning(); got != "" {
+		t.Errorf("expected no warning for a grounded answer, got %q", got)
+	}
+}
+
+func TestKBSourcesSection(t *testing.T) {
+	matches := citationTestMatches()
+	want := "Sources:\n" +
+		"[kb1] /docs/leave.md, lines 12-20\n" +
+		"[kb2] /docs/pay.md, line 4 (not cited)\n" +
+		"[kb3] /docs/old.pdf, part 3 (not cited)"
+	if got := kbSourcesSection(matches, checkKBCitations("[kb1]", matches)); got != want {
+		t.Errorf("kbSourcesSection =\n%s\nwant\n%s", got, want)
+	}
+
+	var out, warnings bytes.Buffer
+	printKBCitations(&out, &warnings, "No idea.", matches)
+	if !strings.Contains(out.String(), "[kb1] /docs/leave.md, lines 12-20 (not cited)") {
+		t.Errorf("expected the sources section, got %q", out.String())
+	}
+	if !strings.Contains(warnings.String(), "cites none") {
+		t.Errorf("expected an ungrounded warning, got %q", warnings.String())
+	}
+
+	report := newPromptCitations(matches, checkKBCitations("[kb2]", matches))
+	if !report.Grounded || len(report.Sources) != 3 || !report.Sources[1].Cited || report.Sources[0].StartLine != 12 {
+		t.Errorf("unexpected JSON report %+v", report)
+	}
+}
+
+func TestKBContentBlockRequireCitations(t *testing.T) {
+	block, ok := kbContentBlock("hr", citationTestMatches(), true).(*types.ContentBlockMemberText)
+	if !ok {
+		t.Fatal("expected a text block")
+	}
+	for _, want := range []string{kbCitationInstruction, `<excerpt id="kb3" source="/docs/old.pdf"`} {
+		if !strings.Contains(block.Value, want) {
+			t.Errorf("expected %q in %s", want, block.Value)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	agentruntimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+const (
+	// kbRerankKey turns reranking on for --kb; kbRerankModelKey picks the
+	// model that does it.
+	kbRerankKey      = "kb.rerank"
+	kbRerankModelKey = "kb.rerank_model"
+	// defaultRerankModelID is used when kbRerankModelKey isn't set.
+	defaultRerankModelID = "amazon.rerank-v1:0"
+	// rerankCandidates is how many times topK chunks are retrieved by
+	// similarity for the reranker to choose from.
+	rerankCandidates = 4
+)
+
+// kbReranker reorders retrieved chunks with a Bedrock reranking model,
+// through the Rerank API of the Bedrock Agents runtime. A reranker reads
+// the query and each chunk together, so it judges relevance better than
+// comparing their embeddings does.
+type kbReranker struct {
+	runtime  *bedrockagentruntime.Client
+	modelARN string
+}
+
+func newKBReranker(cfg aws.Config, modelID string) *kbReranker {
+	modelARN := modelID
+	if !strings.HasPrefix(modelID, "arn:") {
+		modelARN = fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", cfg.Region, modelID)
+	}
+	return &kbReranker{bedrockagentruntime.NewFromConfig(cfg), modelARN}
+}
+
+// configuredKBReranker returns the reranker --kb should use, or nil when
+// reranking is off: it's on with --kb-rerank or kb.rerank set to true.
+func configuredKBReranker(fm *conf.FileManager, cfg aws.Config, flag bool) (*kbReranker, error) {
+	if !flag {
+		enabled := configString(fm, kbRerankKey)
+		if enabled == "" {
+			return nil, nil
+		}
+		on, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: use true or false", kbRerankKey, enabled)
+		}
+		if !on {
+			return nil, nil
+		}
+	}
+
+	modelID := configString(fm, kbRerankModelKey)
+	if modelID == "" {
+		modelID = defaultRerankModelID
+	}
+	return newKBReranker(cfg, modelID), nil
+}
+
+// newRerankRequest asks for the n of texts most relevant to query.
+func newRerankRequest(modelARN, query string, texts []string, n int) *bedrockagentruntime.RerankInput {
+	req := &bedrockagentruntime.RerankInput{
+		Queries: []agentruntimetypes.RerankQuery{{
+			Type:      agentruntimetypes.RerankQueryContentTypeText,
+			TextQuery: &agentruntimetypes.RerankTextDocument{Text: aws.String(query)},
+		}},
+		RerankingConfiguration: &agentruntimetypes.RerankingConfiguration{
+			Type: agentruntimetypes.RerankingConfigurationTypeBedrockRerankingModel,
+			BedrockRerankingConfiguration: &agentruntimetypes.BedrockRerankingConfiguration{
+				NumberOfResults:    aws.Int32(int32(n)),
+				ModelConfiguration: &agentruntimetypes.BedrockRerankingModelConfiguration{ModelArn: aws.String(modelARN)},
+			},
+		},
+	}
+
+	for _, text := range texts {
+		req.Sources = append(req.Sources, agentruntimetypes.RerankSource{
+			Type: agentruntimetypes.RerankSourceTypeInline,
+			InlineDocumentSource: &agentruntimetypes.RerankDocument{
+				Type:         agentruntimetypes.RerankDocumentTypeText,
+				TextDocument: &agentruntimetypes.RerankTextDocument{Text: aws.String(text)},
+			},
+		})
+	}
+	return req
+}
+
+// rerank returns the n of candidates most relevant to query, most relevant
+// first, with their relevance scores set.
+func (r *kbReranker) rerank(ctx context.Context, query string, candidates []kbMatch, n int) ([]kbMatch, error) {
+	texts := make([]string, len(candidates))
+	for i, m := range candidates {
+		texts[i] = m.chunk.Content
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	resp, err := r.runtime.Rerank(ctx, newRerankRequest(r.modelARN, query, texts, min(n, len(candidates))))
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]kbMatch, 0, len(resp.Results))
+	for _, result := range resp.Results {
+		index := int(aws.ToInt32(result.Index))
+		if index < 0 || index >= len(candidates) {
+			return nil, fmt.Errorf("reranker returned source %d of %d", index, len(candidates))
+		}
+		m := candidates[index]
+		m.relevance = float64(aws.ToFloat32(result.RelevanceScore))
+		m.reranked = true
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// kbRerankLine shows how reranking changed what was retrieved: each chunk
+// with its relevance score and the place it had by similarity, and how many
+// of the topK would have been retrieved without reranking. It's "" when
+// matches weren't reranked.
+func kbRerankLine(matches []kbMatch, topK int) string {
+	if len(matches) == 0 || !matches[0].reranked {
+		return ""
+	}
+	parts := make([]string, len(matches))
+	kept := 0
+	for i, m := range matches {
+		parts[i] = fmt.Sprintf("%s#%d %.2f (was %d)", filepath.Base(m.chunk.Source), m.chunk.Part, m.relevance, m.rank)
+		if m.rank <= topK {
+			kept++
+		}
+	}
+	return fmt.Sprintf("rerank: %s; %d of %d also in the similarity top %d", strings.Join(parts, ", "), kept, len(matches), topK)
+}
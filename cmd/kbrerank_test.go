@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+// newRerankTestRetriever returns a retriever over three chunks about cats
+// and dogs, reranked by server.
+func newRerankTestRetriever(server *httptest.Server) *kbRetriever {
+	invoke, _ := fakeTopicModel()
+	model := "amazon.titan-embed-text-v2:0"
+	return &kbRetriever{
+		name: "pets",
+		topK: 2,
+		chunks: []repository.KBChunk{
+			{Source: "/docs/pets.md", Part: 1, Content: "Cats sleep all day.", ModelId: model, Embedding: []float64{1, 0}},
+			{Source: "/docs/pets.md", Part: 2, Content: "Dogs need walks.", ModelId: model, Embedding: []float64{0, 1}},
+			{Source: "/docs/pets.md", Part: 3, Content: "Cats and dogs like treats.", ModelId: model, Embedding: []float64{1, 1}},
+		},
+		invoke:   invoke,
+		reranker: newKBReranker(testAWSConfig(server), "amazon.rerank-v1:0"),
+	}
+}
+
+func TestKBRetrieveReranked(t *testing.T) {
+	var got struct {
+		Queries []struct {
+			TextQuery struct {
+				Text string `json:"text"`
+			} `json:"textQuery"`
+		} `json:"queries"`
+		Sources                []json.RawMessage `json:"sources"`
+		RerankingConfiguration struct {
+			BedrockRerankingConfiguration struct {
+				NumberOfResults    int `json:"numberOfResults"`
+				ModelConfiguration struct {
+					ModelArn string `json:"modelArn"`
+				} `json:"modelConfiguration"`
+			} `json:"bedrockRerankingConfiguration"`
+		} `json:"rerankingConfiguration"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rerank" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		// the least similar chunk is the most relevant
+		_, _ = w.Write([]byte(`{"results":[{"index":2,"relevanceScore":0.91},{"index":0,"relevanceScore":0.40}]}`))
+	}))
+	defer server.Close()
+
+	matches, err := newRerankTestRetriever(server).retrieve(t.Context(), "Why does my dog bark?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Sources) != 3 || got.Queries[0].TextQuery.Text != "Why does my dog bark?" {
+		t.Errorf("expected all three chunks sent with the query, got %+v", got)
+	}
+	config := got.RerankingConfiguration.BedrockRerankingConfiguration
+	if config.NumberOfResults != 2 || !strings.HasSuffix(config.ModelConfiguration.ModelArn, "amazon.rerank-v1:0") {
+		t.Errorf("expected the top 2 from the rerank model, got %+v", config)
+	}
+
+	if len(matches) != 2 || matches[0].chunk.Part != 1 || math.Abs(matches[0].relevance-0.91) > 1e-6 || !matches[0].reranked {
+		t.Fatalf("expected the reranker's order, got %+v", matches)
+	}
+	// by similarity: dogs (part 2), then treats (part 3), then cats (part 1)
+	if matches[0].rank != 3 || matches[1].rank != 1 {
+		t.Errorf("expected similarity ranks 3 and 1, got %d and %d", matches[0].rank, matches[1].rank)
+	}
+
+	want := "rerank: pets.md#1 0.91 (was 3), pets.md#2 0.40 (was 1); 1 of 2 also in the similarity top 2"
+	if line := kbRerankLine(matches, 2); line != want {
+		t.Errorf("kbRerankLine = %q, want %q", line, want)
+	}
+}
+
+func TestKBRetrieveRerankFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"User is not authorized"}`))
+	}))
+	defer server.Close()
+
+	matches, err := newRerankTestRetriever(server).retrieve(t.Context(), "Why does my dog bark?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].chunk.Part != 2 || matches[0].reranked {
+		t.Errorf("expected similarity order when reranking fails, got %+v", matches)
+	}
+	if line := kbRerankLine(matches, 2); line != "" {
+		t.Errorf("expected no rerank line, got %q", line)
+	}
+}
+
+func TestKBRerankBadIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"results":[{"index":7,"relevanceScore":0.5}]}`))
+	}))
+	defer server.Close()
+
+	retriever := newRerankTestRetriever(server)
+	candidates := []kbMatch{{chunk: retriever.chunks[0], rank: 1}}
+	if _, err := retriever.reranker.rerank(t.Context(), "cats", candidates, 1); err == nil {
+		t.Error("expected an error for a source that wasn't sent")
+	}
+}
+
+func TestNewKBRerankerModelARN(t *testing.T) {
+	cfg := aws.Config{Region: "us-west-2"}
+	if got := newKBReranker(cfg, "cohere.rerank-v3-5:0").modelARN; got != "arn:aws:bedrock:us-west-2::foundation-model/cohere.rerank-v3-5:0" {
+		t.Errorf("expected a foundation model ARN, got %s", got)
+	}
+	arn := "arn:aws:bedrock:us-west-2:123456789012:provisioned-model/abc"
+	if got := newKBReranker(cfg, arn).modelARN; got != arn {
+		t.Errorf("expected an ARN to be used as is, got %s", got)
+	}
+}
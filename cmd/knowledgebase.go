@@ -0,0 +1,378 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
+	agentruntimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// defaultKnowledgeBaseResults is how many passages a Bedrock knowledge base
+// retrieves for each question unless --knowledge-base-results says
+// otherwise. It matches the service's own default.
+const defaultKnowledgeBaseResults = 5
+
+// knowledgeBaseClient calls RetrieveAndGenerate on the Bedrock Agents
+// runtime, which answers a question from a managed knowledge base.
+type knowledgeBaseClient struct {
+	runtime *bedrockagentruntime.Client
+}
+
+func newKnowledgeBaseClient(cfg aws.Config) *knowledgeBaseClient {
+	return &knowledgeBaseClient{bedrockagentruntime.NewFromConfig(cfg)}
+}
+
+// ragTextInferenceConfig holds the inference parameters for the model that
+// writes the answer. Temperature and topP are only sent when set.
+type ragTextInferenceConfig struct {
+	MaxTokens   int32    `json:"maxTokens,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"topP,omitempty"`
+}
+
+// ragRequest is a RetrieveAndGenerate request as the service's JSON
+// describes it, which is what --dry-run prints.
+type ragRequest struct {
+	Input struct {
+		Text string `json:"text"`
+	} `json:"input"`
+	RetrieveAndGenerateConfiguration struct {
+		Type                       string `json:"type"`
+		KnowledgeBaseConfiguration struct {
+			KnowledgeBaseID        string `json:"knowledgeBaseId"`
+			ModelArn               string `json:"modelArn"`
+			RetrievalConfiguration struct {
+				VectorSearchConfiguration struct {
+					NumberOfResults int `json:"numberOfResults"`
+				} `json:"vectorSearchConfiguration"`
+			} `json:"retrievalConfiguration"`
+			GenerationConfiguration struct {
+				InferenceConfig struct {
+					TextInferenceConfig ragTextInferenceConfig `json:"textInferenceConfig"`
+				} `json:"inferenceConfig"`
+				GuardrailConfiguration *ragGuardrail `json:"guardrailConfiguration,omitempty"`
+			} `json:"generationConfiguration"`
+		} `json:"knowledgeBaseConfiguration"`
+	} `json:"retrieveAndGenerateConfiguration"`
+}
+
+// ragLocation is where a retrieved passage came from. Only the field for
+// its type is set.
+type ragLocation struct {
+	Type       string `json:"type"`
+	S3Location *struct {
+		URI string `json:"uri"`
+	} `json:"s3Location,omitempty"`
+	WebLocation *struct {
+		URL string `json:"url"`
+	} `json:"webLocation,omitempty"`
+	ConfluenceLocation *struct {
+		URL string `json:"url"`
+	} `json:"confluenceLocation,omitempty"`
+	SalesforceLocation *struct {
+		URL string `json:"url"`
+	} `json:"salesforceLocation,omitempty"`
+	SharePointLocation *struct {
+		URL string `json:"url"`
+	} `json:"sharePointLocation,omitempty"`
+	CustomDocumentLocation *struct {
+		ID string `json:"id"`
+	} `json:"customDocumentLocation,omitempty"`
+}
+
+// String returns the passage's URI or URL, or its type if it has neither.
+func (l ragLocation) String() string {
+	switch {
+	case l.S3Location != nil:
+		return l.S3Location.URI
+	case l.WebLocation != nil:
+		return l.WebLocation.URL
+	case l.ConfluenceLocation != nil:
+		return l.ConfluenceLocation.URL
+	case l.SalesforceLocation != nil:
+		return l.SalesforceLocation.URL
+	case l.SharePointLocation != nil:
+		return l.SharePointLocation.URL
+	case l.CustomDocumentLocation != nil:
+		return l.CustomDocumentLocation.ID
+	}
+	return strings.ToLower(l.Type)
+}
+
+type ragReference struct {
+	Content struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	Location ragLocation `json:"location"`
+}
+
+// ragCitation ties a span of the answer to the passages that support it.
+type ragCitation struct {
+	GeneratedResponsePart struct {
+		TextResponsePart struct {
+			Text string `json:"text"`
+			Span struct {
+				Start int `json:"start"`
+				End   int `json:"end"`
+			} `json:"span"`
+		} `json:"textResponsePart"`
+	} `json:"generatedResponsePart"`
+	RetrievedReferences []ragReference `json:"retrievedReferences"`
+}
+
+type ragGuardrail struct {
+	GuardrailID      string `json:"guardrailId"`
+	GuardrailVersion string `json:"guardrailVersion"`
+}
+
+type ragResponse struct {
+	Output struct {
+		Text string `json:"text"`
+	} `json:"output"`
+	Citations []ragCitation `json:"citations"`
+	// GuardrailAction is INTERVENED when the guardrail blocked the answer.
+	GuardrailAction string `json:"guardrailAction"`
+}
+
+// newRAGRequest builds a RetrieveAndGenerate request that answers question
+// from the knowledge base with the model modelARN.
+func newRAGRequest(knowledgeBaseID, modelARN, question string, results int, inference ragTextInferenceConfig) *ragRequest {
+	req := &ragRequest{}
+	req.Input.Text = question
+
+	kb := &req.RetrieveAndGenerateConfiguration.KnowledgeBaseConfiguration
+	req.RetrieveAndGenerateConfiguration.Type = "KNOWLEDGE_BASE"
+	kb.KnowledgeBaseID = knowledgeBaseID
+	kb.ModelArn = modelARN
+	kb.RetrievalConfiguration.VectorSearchConfiguration.NumberOfResults = results
+	kb.GenerationConfiguration.InferenceConfig.TextInferenceConfig = inference
+	return req
+}
+
+// setGuardrail applies the guardrail c, if any, to the generated answer.
+func (req *ragRequest) setGuardrail(c *types.GuardrailStreamConfiguration) {
+	if c == nil {
+		return
+	}
+	req.RetrieveAndGenerateConfiguration.KnowledgeBaseConfiguration.GenerationConfiguration.GuardrailConfiguration = &ragGuardrail{
+		GuardrailID:      aws.ToString(c.GuardrailIdentifier),
+		GuardrailVersion: aws.ToString(c.GuardrailVersion),
+	}
+}
+
+// retrieveAndGenerateInput returns req for the SDK.
+func (req *ragRequest) retrieveAndGenerateInput() *bedrockagentruntime.RetrieveAndGenerateInput {
+	kb := req.RetrieveAndGenerateConfiguration.KnowledgeBaseConfiguration
+	inference := kb.GenerationConfiguration.InferenceConfig.TextInferenceConfig
+
+	generation := &agentruntimetypes.GenerationConfiguration{
+		InferenceConfig: &agentruntimetypes.InferenceConfig{
+			TextInferenceConfig: &agentruntimetypes.TextInferenceConfig{
+				Temperature: inference.Temperature,
+				TopP:        inference.TopP,
+			},
+		},
+	}
+	if inference.MaxTokens > 0 {
+		generation.InferenceConfig.TextInferenceConfig.MaxTokens = aws.Int32(inference.MaxTokens)
+	}
+	if g := kb.GenerationConfiguration.GuardrailConfiguration; g != nil {
+		generation.GuardrailConfiguration = &agentruntimetypes.GuardrailConfiguration{
+			GuardrailId:      aws.String(g.GuardrailID),
+			GuardrailVersion: aws.String(g.GuardrailVersion),
+		}
+	}
+
+	return &bedrockagentruntime.RetrieveAndGenerateInput{
+		Input: &agentruntimetypes.RetrieveAndGenerateInput{Text: aws.String(req.Input.Text)},
+		RetrieveAndGenerateConfiguration: &agentruntimetypes.RetrieveAndGenerateConfiguration{
+			Type: agentruntimetypes.RetrieveAndGenerateType(req.RetrieveAndGenerateConfiguration.Type),
+			KnowledgeBaseConfiguration: &agentruntimetypes.KnowledgeBaseRetrieveAndGenerateConfiguration{
+				KnowledgeBaseId: aws.String(kb.KnowledgeBaseID),
+				ModelArn:        aws.String(kb.ModelArn),
+				RetrievalConfiguration: &agentruntimetypes.KnowledgeBaseRetrievalConfiguration{
+					VectorSearchConfiguration: &agentruntimetypes.KnowledgeBaseVectorSearchConfiguration{
+						NumberOfResults: aws.Int32(int32(kb.RetrievalConfiguration.VectorSearchConfiguration.NumberOfResults)),
+					},
+				},
+				GenerationConfiguration: generation,
+			},
+		},
+	}
+}
+
+// retrieveAndGenerate sends req and returns the answer with its citations.
+func (c *knowledgeBaseClient) retrieveAndGenerate(ctx context.Context, req *ragRequest) (*ragResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	out, err := c.runtime.RetrieveAndGenerate(ctx, req.retrieveAndGenerateInput())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ragResponse{GuardrailAction: string(out.GuardrailAction)}
+	if out.Output != nil {
+		resp.Output.Text = aws.ToString(out.Output.Text)
+	}
+	for _, c := range out.Citations {
+		var citation ragCitation
+		if part := c.GeneratedResponsePart; part != nil && part.TextResponsePart != nil {
+			text := &citation.GeneratedResponsePart.TextResponsePart
+			text.Text = aws.ToString(part.TextResponsePart.Text)
+			if span := part.TextResponsePart.Span; span != nil {
+				text.Span.Start = int(aws.ToInt32(span.Start))
+				text.Span.End = int(aws.ToInt32(span.End))
+			}
+		}
+		citation.RetrievedReferences = ragReferencesOf(c.RetrievedReferences)
+		resp.Citations = append(resp.Citations, citation)
+	}
+	return resp, nil
+}
+
+// ragReferencesOf converts retrieved passages from the SDK.
+func ragReferencesOf(refs []agentruntimetypes.RetrievedReference) []ragReference {
+	var converted []ragReference
+	for _, r := range refs {
+		var ref ragReference
+		if r.Content != nil {
+			ref.Content.Text = aws.ToString(r.Content.Text)
+		}
+		if l := r.Location; l != nil {
+			ref.Location.Type = string(l.Type)
+			switch {
+			case l.S3Location != nil:
+				ref.Location.S3Location = &struct {
+					URI string `json:"uri"`
+				}{aws.ToString(l.S3Location.Uri)}
+			case l.WebLocation != nil:
+				ref.Location.WebLocation = &struct {
+					URL string `json:"url"`
+				}{aws.ToString(l.WebLocation.Url)}
+			case l.ConfluenceLocation != nil:
+				ref.Location.ConfluenceLocation = &struct {
+					URL string `json:"url"`
+				}{aws.ToString(l.ConfluenceLocation.Url)}
+			case l.SalesforceLocation != nil:
+				ref.Location.SalesforceLocation = &struct {
+					URL string `json:"url"`
+				}{aws.ToString(l.SalesforceLocation.Url)}
+			case l.SharePointLocation != nil:
+				ref.Location.SharePointLocation = &struct {
+					URL string `json:"url"`
+				}{aws.ToString(l.SharePointLocation.Url)}
+			case l.CustomDocumentLocation != nil:
+				ref.Location.CustomDocumentLocation = &struct {
+					ID string `json:"id"`
+				}{aws.ToString(l.CustomDocumentLocation.Id)}
+			}
+		}
+		converted = append(converted, ref)
+	}
+	return converted
+}
+
+// knowledgeBaseModelARN returns the ARN RetrieveAndGenerate needs for
+// modelID. Foundation model IDs are turned into their ARN in region, and
+// inference profile IDs are looked up, since their ARN includes the account.
+func knowledgeBaseModelARN(ctx context.Context, svc *bedrock.Client, region, modelID string) (string, error) {
+	if strings.HasPrefix(modelID, "arn:") {
+		return modelID, nil
+	}
+	if isInferenceProfileID(modelID) {
+		out, err := svc.GetInferenceProfile(ctx, &bedrock.GetInferenceProfileInput{InferenceProfileIdentifier: aws.String(modelID)})
+		if err != nil {
+			return "", fmt.Errorf("unable to look up inference profile %s: %w", modelID, err)
+		}
+		return aws.ToString(out.InferenceProfileArn), nil
+	}
+	return fmt.Sprintf("arn:aws:bedrock:%s::foundation-model/%s", region, modelID), nil
+}
+
+// formatRAGAnswer returns the answer with a [n] marker after each cited
+// span, followed by a numbered list of the sources. Sources are numbered
+// in the order they're first cited, and a source cited more than once
+// keeps its number.
+func formatRAGAnswer(resp *ragResponse) string {
+	text := resp.Output.Text
+
+	type marker struct {
+		at      int
+		numbers []int
+	}
+	var markers []marker
+	var sources []ragReference
+	numbers := map[string]int{}
+
+	for _, c := range resp.Citations {
+		var cited []int
+		for _, ref := range c.RetrievedReferences {
+			key := ref.Location.String() + "\x00" + ref.Content.Text
+			n, ok := numbers[key]
+			if !ok {
+				sources = append(sources, ref)
+				n = len(sources)
+				numbers[key] = n
+			}
+			if !slices.Contains(cited, n) {
+				cited = append(cited, n)
+			}
+		}
+		if len(cited) == 0 {
+			continue
+		}
+
+		markers = append(markers, marker{at: citationEnd(text, c), numbers: cited})
+	}
+
+	// insert from the end so earlier positions stay put
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].at > markers[j].at })
+	for _, m := range markers {
+		labels := make([]string, len(m.numbers))
+		for i, n := range m.numbers {
+			labels[i] = fmt.Sprintf("[%d]", n)
+		}
+		text = text[:m.at] + strings.Join(labels, "") + text[m.at:]
+	}
+
+	if len(sources) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	b.WriteString(text)
+	b.WriteString("\n\nSources:\n")
+	for i, ref := range sources {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, ref.Location)
+		if excerpt := truncate(oneLine(ref.Content.Text), 120); excerpt != "" {
+			fmt.Fprintf(&b, "    %s\n", excerpt)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// citationEnd returns where in text the span c cites ends. The cited text
+// is searched for from the span's start, since that doesn't depend on
+// whether the span's end is inclusive; the span's end is the fallback.
+func citationEnd(text string, c ragCitation) int {
+	part := c.GeneratedResponsePart.TextResponsePart
+	start := min(max(part.Span.Start, 0), len(text))
+	if part.Text != "" {
+		if i := strings.Index(text[start:], part.Text); i >= 0 {
+			return start + i + len(part.Text)
+		}
+	}
+	return min(max(part.Span.End+1, 0), len(text))
+}
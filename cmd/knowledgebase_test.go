@@ -0,0 +1,133 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const ragResponseFixture = `{
+  "output": {"text": "Staff get 25 days of leave. Unused days carry over."},
+  "citations": [
+    {
+      "generatedResponsePart": {"textResponsePart": {"text": "Staff get 25 days of leave.", "span": {"start": 0, "end": 26}}},
+      "retrievedReferences": [
+        {"content": {"text": "All staff are entitled to 25 days of annual leave."}, "location": {"type": "S3", "s3Location": {"uri": "s3://handbook/leave.pdf"}}}
+      ]
+    },
+    {
+      "generatedResponsePart": {"textResponsePart": {"text": "Unused days carry over.", "span": {"start": 28, "end": 50}}},
+      "retrievedReferences": [
+        {"content": {"text": "Up to 5 unused days carry over."}, "location": {"type": "WEB", "webLocation": {"url": "https://intranet/leave"}}},
+        {"content": {"text": "All staff are entitled to 25 days of annual leave."}, "location": {"type": "S3", "s3Location": {"uri": "s3://handbook/leave.pdf"}}}
+      ]
+    }
+  ],
+  "sessionId": "session-1"
+}`
+
+func TestRetrieveAndGenerate(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &gotBody)
+		_, _ = w.Write([]byte(ragResponseFixture))
+	}))
+	defer server.Close()
+
+	client := newKnowledgeBaseClient(testAWSConfig(server))
+	modelARN := "arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-3-haiku-20240307-v1:0"
+	req := newRAGRequest("KB123", modelARN, "How much leave do I get?", 3, ragTextInferenceConfig{MaxTokens: 512, Temperature: aws.Float32(0.2)})
+
+	resp, err := client.retrieveAndGenerate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/retrieveAndGenerate" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	body := mustJSON(t, gotBody)
+	for _, want := range []string{
+		`"input":{"text":"How much leave do I get?"}`,
+		`"knowledgeBaseId":"KB123"`,
+		`"modelArn":"` + modelARN + `"`,
+		`"numberOfResults":3`,
+		`"textInferenceConfig":{"maxTokens":512,"temperature":0.2}`,
+		`"type":"KNOWLEDGE_BASE"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in request %s", want, body)
+		}
+	}
+
+	if resp.Output.Text != "Staff get 25 days of leave. Unused days carry over." || len(resp.Citations) != 2 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestFormatRAGAnswer(t *testing.T) {
+	var resp ragResponse
+	if err := json.Unmarshal([]byte(ragResponseFixture), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Staff get 25 days of leave.[1] Unused days carry over.[2][1]
+
+Sources:
+[1] s3://handbook/leave.pdf
+    All staff are entitled to 25 days of annual leave.
+[2] https://intranet/leave
+    Up to 5 unused days carry over.`
+	if got := formatRAGAnswer(&resp); got != want {
+		t.Errorf("formatRAGAnswer =\n%s\nwant\n%s", got, want)
+	}
+
+	t.Run("without citations", func(t *testing.T) {
+		var plain ragResponse
+		plain.Output.Text = "I couldn't find that."
+		if got := formatRAGAnswer(&plain); got != "I couldn't find that." {
+			t.Errorf("unexpected answer %q", got)
+		}
+	})
+}
+
+func TestCitationEnd(t *testing.T) {
+	text := "One. Two. Three."
+	citation := func(part string, start, end int) ragCitation {
+		var c ragCitation
+		c.GeneratedResponsePart.TextResponsePart.Text = part
+		c.GeneratedResponsePart.TextResponsePart.Span.Start = start
+		c.GeneratedResponsePart.TextResponsePart.Span.End = end
+		return c
+	}
+
+	tests := []struct {
+		name string
+		c    ragCitation
+		want int
+	}{
+		{"found from the span start", citation("Two.", 5, 8), 9},
+		{"span start off by one", citation("Two.", 4, 8), 9},
+		{"text not found falls back to the span", citation("Four.", 5, 8), 9},
+		{"span past the end", citation("", 0, 99), len(text)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := citationEnd(text, tt.c); got != tt.want {
+				t.Errorf("citationEnd = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
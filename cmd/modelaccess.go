@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+// What models list --check-access finds out about each model.
+const (
+	accessGranted     = "granted"
+	accessDenied      = "no access"
+	accessProfileOnly = "inference profile only"
+	accessNotChecked  = "not checked"
+	accessUnknown     = "unknown"
+)
+
+// modelAccessConcurrency is how many models --check-access tries at once.
+const modelAccessConcurrency = 4
+
+// accessProbeFunc sends modelID the smallest request it will answer.
+type accessProbeFunc func(ctx context.Context, modelID string) error
+
+// converseProbe asks for a single token of reply, so checking a model
+// costs next to nothing.
+func converseProbe(svc *bedrockruntime.Client) accessProbeFunc {
+	return func(ctx context.Context, modelID string) error {
+		_, err := svc.Converse(ctx, &bedrockruntime.ConverseInput{
+			ModelId: aws.String(modelID),
+			Messages: []types.Message{{
+				Role:    types.ConversationRoleUser,
+				Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "Hi"}},
+			}},
+			InferenceConfig: &types.InferenceConfiguration{MaxTokens: aws.Int32(1)},
+		})
+		return err
+	}
+}
+
+// accessStatus describes what a probe's error says about access to the
+// model. A request that fails validation still got past the access
+// check, so the model can be used.
+func accessStatus(err error) string {
+	if err == nil {
+		return accessGranted
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return accessUnknown
+	}
+	switch apiErr.ErrorCode() {
+	case "AccessDeniedException":
+		return accessDenied
+	case "ValidationException":
+		if strings.Contains(apiErr.ErrorMessage(), "on-demand throughput") {
+			return accessProfileOnly
+		}
+		return accessGranted
+	}
+	return accessUnknown
+}
+
+// checkModelAccess tries each model that answers with text on demand,
+// returning what it found in the same order as models. Other models
+// can't be tried with Converse and are reported as not checked.
+func checkModelAccess(ctx context.Context, models []modelInfo, probe accessProbeFunc) ([]string, error) {
+	statuses := make([]string, len(models))
+	_, err := runAdaptive(ctx, len(models), modelAccessConcurrency, func(ctx context.Context, i int) error {
+		m := models[i]
+		if !slices.Contains(m.OutputModalities, "TEXT") || !slices.Contains(m.InferenceTypes, "ON_DEMAND") {
+			statuses[i] = accessNotChecked
+			return nil
+		}
+		err := probe(ctx, m.ID)
+		if isThrottlingError(err) {
+			// runAdaptive retries it
+			return err
+		}
+		statuses[i] = accessStatus(err)
+		return nil
+	})
+	return statuses, err
+}
+
+// accessHelp explains how to use the models checkModelAccess found
+// couldn't be, or is "" if they all can.
+func accessHelp(region string, statuses []string) string {
+	var lines []string
+	if slices.Contains(statuses, accessDenied) {
+		lines = append(lines, fmt.Sprintf("Models with no access need it granted in the Bedrock console: https://%s.console.aws.amazon.com/bedrock/home?region=%s#/modelaccess", region, region))
+	}
+	if slices.Contains(statuses, accessProfileOnly) {
+		lines = append(lines, "Models marked inference profile only must be used through a cross-region inference profile, such as us.<model-id>, with --model-id or --inference-profile.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inferenceLabel describes how a model can be used for models list.
+func inferenceLabel(inferenceTypes []string) string {
+	labels := make([]string, 0, len(inferenceTypes))
+	for _, t := range inferenceTypes {
+		labels = append(labels, strings.ReplaceAll(strings.ToLower(t), "_", "-"))
+	}
+	return strings.Join(labels, ", ")
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestAccessStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"answered", nil, accessGranted},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "You don't have access to the model with the specified model ID."}, accessDenied},
+		{"profile only", &smithy.GenericAPIError{Code: "ValidationException", Message: "Invocation of model ID x with on-demand throughput isn’t supported."}, accessProfileOnly},
+		{"other validation", &smithy.GenericAPIError{Code: "ValidationException", Message: "This model doesn't support the system field."}, accessGranted},
+		{"other API error", &smithy.GenericAPIError{Code: "ResourceNotFoundException"}, accessUnknown},
+		{"network", errors.New("connection reset"), accessUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accessStatus(tt.err); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckModelAccess(t *testing.T) {
+	var mu sync.Mutex
+	var probed []string
+	probe := func(ctx context.Context, modelID string) error {
+		mu.Lock()
+		probed = append(probed, modelID)
+		mu.Unlock()
+		if strings.HasPrefix(modelID, "anthropic.") {
+			return &smithy.GenericAPIError{Code: "AccessDeniedException"}
+		}
+		return nil
+	}
+
+	models := []modelInfo{
+		{ID: "anthropic.claude-3-haiku-20240307-v1:0", OutputModalities: []string{"TEXT"}, InferenceTypes: []string{"ON_DEMAND"}},
+		{ID: "amazon.titan-embed-text-v2:0", OutputModalities: []string{"EMBEDDING"}, InferenceTypes: []string{"ON_DEMAND"}},
+		{ID: "meta.llama3-70b-instruct-v1:0", OutputModalities: []string{"TEXT"}, InferenceTypes: []string{"ON_DEMAND"}},
+		{ID: "amazon.nova-premier-v1:0", OutputModalities: []string{"TEXT"}, InferenceTypes: []string{"PROVISIONED"}},
+	}
+	statuses, err := checkModelAccess(t.Context(), models, probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{accessDenied, accessNotChecked, accessGranted, accessNotChecked}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	if len(probed) != 2 {
+		t.Errorf("probed %v; want only the on-demand text models", probed)
+	}
+
+	help := accessHelp("us-west-2", statuses)
+	if !strings.Contains(help, "https://us-west-2.console.aws.amazon.com/bedrock/home?region=us-west-2#/modelaccess") {
+		t.Errorf("help = %q", help)
+	}
+	if accessHelp("us-west-2", []string{accessGranted, accessNotChecked}) != "" {
+		t.Error("expected no help when every model can be used")
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// modelCacheTTL is how long model metadata is used before Bedrock is asked
+// for it again.
+const modelCacheTTL = 24 * time.Hour
+
+// modelCacheVersion is raised when modelInfo gains fields, so caches
+// written without them are fetched again.
+const modelCacheVersion = 1
+
+// modelInfo is what chat-cli needs to know about a foundation model to
+// check it can be used.
+type modelInfo struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Provider         string   `json:"provider"`
+	InputModalities  []string `json:"input_modalities"`
+	OutputModalities []string `json:"output_modalities"`
+	Streaming        bool     `json:"streaming"`
+	// InferenceTypes are how the model can be used: ON_DEMAND, or
+	// PROVISIONED for provisioned throughput only.
+	InferenceTypes []string  `json:"inference_types"`
+	Fetched        time.Time `json:"fetched"`
+}
+
+// modelCache is the file the catalog keeps for a region. Models are keyed
+// by the identifier they were looked up with, which for a model found
+// through GetFoundationModel may be an ARN rather than its ID.
+type modelCache struct {
+	Version int `json:"version"`
+	// Listed is when every model was last fetched with ListFoundationModels.
+	Listed time.Time            `json:"listed"`
+	Models map[string]modelInfo `json:"models"`
+}
+
+// modelCatalog looks up foundation models, keeping what it finds in a
+// file in the data directory so each prompt and chat doesn't have to call
+// GetFoundationModel.
+type modelCatalog struct {
+	// path is the cache file; without one, every lookup goes to Bedrock.
+	path string
+	now  func() time.Time
+
+	getModel   func(ctx context.Context, id string) (modelInfo, error)
+	listModels func(ctx context.Context) ([]modelInfo, error)
+}
+
+// newModelCatalog returns a catalog of the models in cfg's region.
+func newModelCatalog(cfg aws.Config) *modelCatalog {
+	svc := bedrock.NewFromConfig(cfg)
+	return &modelCatalog{
+		path: modelCachePath(cfg.Region),
+		now:  time.Now,
+		getModel: func(ctx context.Context, id string) (modelInfo, error) {
+			out, err := svc.GetFoundationModel(ctx, &bedrock.GetFoundationModelInput{ModelIdentifier: aws.String(id)})
+			if err != nil {
+				return modelInfo{}, err
+			}
+			return modelFromDetails(out.ModelDetails), nil
+		},
+		listModels: func(ctx context.Context) ([]modelInfo, error) {
+			out, err := svc.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+			if err != nil {
+				return nil, err
+			}
+			models := make([]modelInfo, 0, len(out.ModelSummaries))
+			for i := range out.ModelSummaries {
+				models = append(models, modelFromSummary(&out.ModelSummaries[i]))
+			}
+			return models, nil
+		},
+	}
+}
+
+// modelCachePath is where the models in region are cached, or "" if the
+// data directory can't be found.
+func modelCachePath(region string) string {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil || region == "" {
+		return ""
+	}
+	return filepath.Join(fm.DataPath, "models-"+region+".json")
+}
+
+func modelFromDetails(d *types.FoundationModelDetails) modelInfo {
+	if d == nil {
+		return modelInfo{}
+	}
+	return modelInfo{
+		ID:               aws.ToString(d.ModelId),
+		Name:             aws.ToString(d.ModelName),
+		Provider:         aws.ToString(d.ProviderName),
+		InputModalities:  modalityNames(d.InputModalities),
+		OutputModalities: modalityNames(d.OutputModalities),
+		Streaming:        aws.ToBool(d.ResponseStreamingSupported),
+		InferenceTypes:   inferenceTypeNames(d.InferenceTypesSupported),
+	}
+}
+
+func modelFromSummary(s *types.FoundationModelSummary) modelInfo {
+	return modelInfo{
+		ID:               aws.ToString(s.ModelId),
+		Name:             aws.ToString(s.ModelName),
+		Provider:         aws.ToString(s.ProviderName),
+		InputModalities:  modalityNames(s.InputModalities),
+		OutputModalities: modalityNames(s.OutputModalities),
+		Streaming:        aws.ToBool(s.ResponseStreamingSupported),
+		InferenceTypes:   inferenceTypeNames(s.InferenceTypesSupported),
+	}
+}
+
+func modalityNames(modalities []types.ModelModality) []string {
+	names := make([]string, len(modalities))
+	for i, m := range modalities {
+		names[i] = string(m)
+	}
+	return names
+}
+
+func inferenceTypeNames(inferenceTypes []types.InferenceType) []string {
+	names := make([]string, len(inferenceTypes))
+	for i, t := range inferenceTypes {
+		names[i] = string(t)
+	}
+	return names
+}
+
+// model returns the model id names, from the cache if it was fetched
+// within modelCacheTTL.
+func (c *modelCatalog) model(ctx context.Context, id string) (modelInfo, error) {
+	cache := c.load()
+	if m, ok := cache.Models[id]; ok && c.fresh(m.Fetched) {
+		return m, nil
+	}
+
+	m, err := c.getModel(ctx, id)
+	if err != nil {
+		return modelInfo{}, err
+	}
+	m.Fetched = c.now()
+	cache.Models[id] = m
+	c.save(cache)
+	return m, nil
+}
+
+// list returns every model in the region sorted by provider and ID, from
+// the cache unless refresh is set or it's older than modelCacheTTL.
+func (c *modelCatalog) list(ctx context.Context, refresh bool) ([]modelInfo, error) {
+	cache := c.load()
+	if refresh || !c.fresh(cache.Listed) {
+		models, err := c.listModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cache = modelCache{Version: modelCacheVersion, Listed: c.now(), Models: make(map[string]modelInfo, len(models))}
+		for _, m := range models {
+			m.Fetched = cache.Listed
+			cache.Models[m.ID] = m
+		}
+		c.save(cache)
+	}
+
+	var models []modelInfo
+	for id, m := range cache.Models {
+		// skip models cached under an ARN, which are also under their ID
+		if id == m.ID {
+			models = append(models, m)
+		}
+	}
+	slices.SortFunc(models, func(a, b modelInfo) int {
+		if n := strings.Compare(a.Provider, b.Provider); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return models, nil
+}
+
+func (c *modelCatalog) fresh(fetched time.Time) bool {
+	return !fetched.IsZero() && c.now().Sub(fetched) < modelCacheTTL
+}
+
+// load reads the cache, treating a missing or unreadable file, or one from
+// another version of the cache, as empty.
+func (c *modelCatalog) load() modelCache {
+	cache := modelCache{Version: modelCacheVersion, Models: map[string]modelInfo{}}
+	if c.path == "" {
+		return cache
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Version != modelCacheVersion || cache.Models == nil {
+		return modelCache{Version: modelCacheVersion, Models: map[string]modelInfo{}}
+	}
+	return cache
+}
+
+// save writes the cache. It's only an optimization, so failing to write
+// it isn't an error; the next run looks the models up again.
+func (c *modelCatalog) save(cache modelCache) {
+	if c.path == "" {
+		return
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+	}
+}
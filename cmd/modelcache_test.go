@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeModelCatalog returns a catalog caching in a temporary file, whose
+// clock is *now, and which counts the calls it makes to Bedrock.
+func fakeModelCatalog(t *testing.T, now *time.Time) (*modelCatalog, *int, *int) {
+	t.Helper()
+	gets, lists := 0, 0
+	models := []modelInfo{
+		{ID: "anthropic.claude-3-haiku-20240307-v1:0", Provider: "Anthropic", OutputModalities: []string{"TEXT"}, Streaming: true},
+		{ID: "amazon.titan-image-generator-v2:0", Provider: "Amazon", OutputModalities: []string{"IMAGE"}},
+	}
+	c := &modelCatalog{
+		path: filepath.Join(t.TempDir(), "models-us-east-1.json"),
+		now:  func() time.Time { return *now },
+		getModel: func(ctx context.Context, id string) (modelInfo, error) {
+			gets++
+			for _, m := range models {
+				if m.ID == id || "arn:aws:bedrock:us-east-1::foundation-model/"+m.ID == id {
+					return m, nil
+				}
+			}
+			return modelInfo{}, errors.New("model not found")
+		},
+		listModels: func(ctx context.Context) ([]modelInfo, error) {
+			lists++
+			return models, nil
+		},
+	}
+	return c, &gets, &lists
+}
+
+func TestModelCatalogModel(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c, gets, _ := fakeModelCatalog(t, &now)
+	id := "anthropic.claude-3-haiku-20240307-v1:0"
+
+	m, err := c.model(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Streaming || m.Provider != "Anthropic" {
+		t.Errorf("model = %+v", m)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := c.model(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+	if *gets != 1 {
+		t.Errorf("looked the model up %d times within the TTL; want 1", *gets)
+	}
+
+	now = now.Add(modelCacheTTL)
+	if _, err := c.model(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+	if *gets != 2 {
+		t.Errorf("looked the model up %d times after the TTL; want 2", *gets)
+	}
+
+	if _, err := c.model(t.Context(), "no.such-model"); err == nil {
+		t.Error("expected an error for a model Bedrock doesn't have")
+	}
+}
+
+func TestModelCatalogList(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c, gets, lists := fakeModelCatalog(t, &now)
+
+	// a model looked up by ARN isn't listed twice
+	if _, err := c.model(t.Context(), "arn:aws:bedrock:us-east-1::foundation-model/amazon.titan-image-generator-v2:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := c.list(t.Context(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].Provider != "Amazon" || models[1].Provider != "Anthropic" {
+		t.Fatalf("list = %+v; want both models sorted by provider", models)
+	}
+
+	// listing caches every model, so neither is looked up on its own
+	if _, err := c.list(t.Context(), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.model(t.Context(), "anthropic.claude-3-haiku-20240307-v1:0"); err != nil {
+		t.Fatal(err)
+	}
+	if *lists != 1 || *gets != 1 {
+		t.Errorf("listed %d times and looked up %d times; want 1 and 1", *lists, *gets)
+	}
+
+	if _, err := c.list(t.Context(), true); err != nil {
+		t.Fatal(err)
+	}
+	if *lists != 2 {
+		t.Errorf("refresh listed %d times in all; want 2", *lists)
+	}
+}
+
+func TestModelCatalogWithoutCacheFile(t *testing.T) {
+	now := time.Now()
+	c, gets, _ := fakeModelCatalog(t, &now)
+	c.path = ""
+
+	for range 2 {
+		if _, err := c.model(t.Context(), "anthropic.claude-3-haiku-20240307-v1:0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *gets != 2 {
+		t.Errorf("looked the model up %d times without a cache; want 2", *gets)
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// modelDefaultsKey is the config.yaml key holding default inference
+// parameters for models and providers. It's a list rather than a map
+// because viper splits keys on the dots in model IDs:
+//
+//	model-defaults:
+//	  - model: anthropic
+//	    temperature: 0.5
+//	  - model: amazon.titan-text-express-v1
+//	    topP: 0.9
+//	    max-tokens: 2048
+const modelDefaultsKey = "model-defaults"
+
+// modelDefaultParams are the settings a model-defaults entry can hold, in
+// the order they're shown, each named after the flag it's a default for.
+var modelDefaultParams = []string{"temperature", "topP", "max-tokens"}
+
+// modelDefaults is one entry of model-defaults: the parameters for a model
+// ID, or for every model from a provider.
+type modelDefaults struct {
+	model  string
+	params map[string]string
+}
+
+// matches reports whether the entry is for modelID exactly (exact) or for
+// its provider. Cross-region inference profile prefixes such as us. are
+// ignored, so "anthropic" matches us.anthropic.claude-sonnet-5.
+func (d modelDefaults) matches(modelID string) (matched, exact bool) {
+	if strings.EqualFold(d.model, modelID) || strings.EqualFold(d.model, baseModelID(modelID)) {
+		return true, true
+	}
+	return strings.EqualFold(d.model, modelProvider(modelID)), false
+}
+
+// baseModelID returns modelID without a cross-region inference profile
+// prefix.
+func baseModelID(modelID string) string {
+	for _, prefix := range []string{"us.", "eu.", "apac.", "global."} {
+		if rest, ok := strings.CutPrefix(modelID, prefix); ok && strings.Contains(rest, ".") {
+			return rest
+		}
+	}
+	return modelID
+}
+
+// modelProvider returns the provider part of a model ID, such as
+// "anthropic" for anthropic.claude-3-haiku-20240307-v1:0, or "" for an
+// ARN.
+func modelProvider(modelID string) string {
+	if strings.HasPrefix(modelID, "arn:") {
+		return ""
+	}
+	provider, _, _ := strings.Cut(baseModelID(modelID), ".")
+	return provider
+}
+
+// loadModelDefaults reads model-defaults from config.yaml, in order. It
+// returns nil if there are none.
+func loadModelDefaults() ([]modelDefaults, error) {
+	if !viper.IsSet(modelDefaultsKey) {
+		return nil, nil
+	}
+	return parseModelDefaults(viper.Get(modelDefaultsKey))
+}
+
+// parseModelDefaults converts the YAML-decoded list of entries, rejecting
+// unknown keys and values that aren't numbers.
+func parseModelDefaults(value interface{}) ([]modelDefaults, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list, got %v", modelDefaultsKey, value)
+	}
+
+	entries := make([]modelDefaults, 0, len(list))
+	for i, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s entry %d: expected a map, got %v", modelDefaultsKey, i+1, item)
+		}
+
+		entry := modelDefaults{params: map[string]string{}}
+		for key, v := range fields {
+			if key == "model" {
+				model, err := ruleString(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s entry %d: model: %v", modelDefaultsKey, i+1, err)
+				}
+				entry.model = model
+				continue
+			}
+			param, ok := modelDefaultParam(key)
+			if !ok {
+				return nil, fmt.Errorf("%s entry %d: %s: unknown key (use model, %s)", modelDefaultsKey, i+1, key, strings.Join(modelDefaultParams, ", "))
+			}
+			if err := checkModelDefaultValue(param, fmt.Sprint(v)); err != nil {
+				return nil, fmt.Errorf("%s entry %d: %v", modelDefaultsKey, i+1, err)
+			}
+			entry.params[param] = fmt.Sprint(v)
+		}
+
+		if entry.model == "" {
+			return nil, fmt.Errorf("%s entry %d: model is required", modelDefaultsKey, i+1)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// modelDefaultParam returns the canonical spelling of a parameter. Viper
+// lowercases keys, so topP comes back from the file as topp.
+func modelDefaultParam(key string) (string, bool) {
+	for _, param := range modelDefaultParams {
+		if strings.EqualFold(param, key) {
+			return param, true
+		}
+	}
+	return "", false
+}
+
+func checkModelDefaultValue(param, value string) error {
+	var err error
+	if param == "max-tokens" {
+		_, err = strconv.ParseInt(value, 10, 32)
+	} else {
+		_, err = strconv.ParseFloat(value, 32)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: expected a number, got %q", param, value)
+	}
+	return nil
+}
+
+// defaultsForModel merges the entries that match modelID: those for its
+// provider first, then those for the model itself, so a model's own
+// defaults win over its provider's.
+func defaultsForModel(entries []modelDefaults, modelID string) map[string]string {
+	params := map[string]string{}
+	for _, wantExact := range []bool{false, true} {
+		for _, entry := range entries {
+			if matched, exact := entry.matches(modelID); matched && exact == wantExact {
+				for param, value := range entry.params {
+					params[param] = value
+				}
+			}
+		}
+	}
+	return params
+}
+
+// applyModelDefaults sets each inference flag that wasn't given, on the
+// command line or by the active profile, to modelID's default for it
+// from model-defaults. The flag is marked as changed so optional
+// parameters like temperature are sent.
+func applyModelDefaults(flags *pflag.FlagSet, modelID string) error {
+	entries, err := loadModelDefaults()
+	if err != nil {
+		return err
+	}
+	for param, value := range defaultsForModel(entries, modelID) {
+		f := flags.Lookup(param)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("%s for %s: %s: %w", modelDefaultsKey, modelID, param, err)
+		}
+		f.Changed = true
+	}
+	return nil
+}
+
+// setModelDefaults adds params to the entry for model, adding the entry if
+// there isn't one, and returns the new list.
+func setModelDefaults(entries []modelDefaults, model string, params map[string]string) []modelDefaults {
+	for i := range entries {
+		if strings.EqualFold(entries[i].model, model) {
+			for param, value := range params {
+				entries[i].params[param] = value
+			}
+			return entries
+		}
+	}
+	return append(entries, modelDefaults{model: model, params: params})
+}
+
+// modelDefaultsConfig converts entries back to what's written to
+// config.yaml.
+func modelDefaultsConfig(entries []modelDefaults) []interface{} {
+	list := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		fields := map[string]interface{}{"model": entry.model}
+		for param, value := range entry.params {
+			if param == "max-tokens" {
+				n, _ := strconv.Atoi(value)
+				fields[param] = n
+			} else {
+				f, _ := strconv.ParseFloat(value, 64)
+				fields[param] = f
+			}
+		}
+		list = append(list, fields)
+	}
+	return list
+}
+
+// formatModelDefaults lists params as key=value pairs in the order of
+// modelDefaultParams.
+func formatModelDefaults(params map[string]string) string {
+	var pairs []string
+	for _, param := range modelDefaultParams {
+		if value, ok := params[param]; ok {
+			pairs = append(pairs, param+"="+value)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// configModelDefaultsCmd represents the config model-defaults command
+var configModelDefaultsCmd = &cobra.Command{
+	Use:   "model-defaults",
+	Short: "Manage default inference parameters for models and providers",
+	Long: `Manage the temperature, topP, and max-tokens used with a model, or with every
+model from a provider, when they aren't given as flags or set by the active
+profile. Defaults for a model win over defaults for its provider, and
+providers match cross-region inference profiles too, so "anthropic" covers
+us.anthropic.claude-sonnet-5.
+
+> chat-cli config model-defaults set anthropic --temperature 0.5 --max-tokens 8192
+> chat-cli config model-defaults set amazon.titan-text-express-v1 --topP 0.9
+> chat-cli config model-defaults get us.anthropic.claude-sonnet-5`,
+}
+
+// configModelDefaultsSetCmd represents the config model-defaults set command
+var configModelDefaultsSetCmd = &cobra.Command{
+	Use:   "set <model-id|provider>",
+	Short: "Set default inference parameters for a model or provider",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := map[string]string{}
+		for _, param := range modelDefaultParams {
+			if f := cmd.Flags().Lookup(param); f.Changed {
+				params[param] = f.Value.String()
+			}
+		}
+		if len(params) == 0 {
+			log.Fatal("set at least one of --temperature, --topP, or --max-tokens")
+		}
+
+		profileFileManager()
+		entries, err := loadModelDefaults()
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries = setModelDefaults(entries, args[0], params)
+
+		viper.Set(modelDefaultsKey, modelDefaultsConfig(entries))
+		if err := viper.WriteConfig(); err != nil {
+			log.Fatalf("Error writing config file: %v", err)
+		}
+		fmt.Printf("Defaults for %s: %s\n", args[0], formatModelDefaults(defaultsForModel(entries, args[0])))
+	},
+}
+
+// configModelDefaultsGetCmd represents the config model-defaults get command
+var configModelDefaultsGetCmd = &cobra.Command{
+	Use:   "get [model-id]",
+	Short: "Show the default inference parameters for a model, or all of them",
+	Long: `Show the defaults that apply to a model, combining those for its provider
+and for the model itself. Without a model, every entry is listed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		profileFileManager()
+		entries, err := loadModelDefaults()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(args) == 1 {
+			params := defaultsForModel(entries, args[0])
+			if len(params) == 0 {
+				fmt.Printf("No defaults for %s\n", args[0])
+				return
+			}
+			fmt.Println(formatModelDefaults(params))
+			return
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("No model defaults yet. Add some with: chat-cli config model-defaults set <model-id|provider> --temperature 0.5")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tDEFAULTS")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\n", entry.model, formatModelDefaults(entry.params))
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing output: %v", err)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configModelDefaultsCmd)
+	configModelDefaultsCmd.AddCommand(configModelDefaultsSetCmd)
+	configModelDefaultsCmd.AddCommand(configModelDefaultsGetCmd)
+
+	configModelDefaultsSetCmd.Flags().Float32("temperature", 0, "the default temperature (0-1)")
+	configModelDefaultsSetCmd.Flags().Float32("topP", 0, "the default top-P (0-1)")
+	configModelDefaultsSetCmd.Flags().Int32("max-tokens", 0, "the default max tokens")
+}
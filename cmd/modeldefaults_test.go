@@ -0,0 +1,130 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const testModelDefaultsYAML = `
+model-defaults:
+  - model: anthropic
+    temperature: 0.5
+    max-tokens: 8192
+  - model: anthropic.claude-3-haiku-20240307-v1:0
+    temperature: 0.2
+  - model: amazon.titan-text-express-v1
+    topP: 0.9
+`
+
+func loadTestModelDefaults(t *testing.T) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(strings.NewReader(testModelDefaultsYAML)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultsForModel(t *testing.T) {
+	loadTestModelDefaults(t)
+	entries, err := loadModelDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		modelID string
+		want    string
+	}{
+		{"anthropic.claude-3-haiku-20240307-v1:0", "temperature=0.2, max-tokens=8192"},
+		{"us.anthropic.claude-sonnet-5", "temperature=0.5, max-tokens=8192"},
+		{"eu.anthropic.claude-3-haiku-20240307-v1:0", "temperature=0.2, max-tokens=8192"},
+		{"amazon.titan-text-express-v1", "topP=0.9"},
+		{"amazon.nova-lite-v1:0", ""},
+		{"arn:aws:bedrock:us-east-1:123456789012:custom-model/anthropic.x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modelID, func(t *testing.T) {
+			if got := formatModelDefaults(defaultsForModel(entries, tt.modelID)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyModelDefaults(t *testing.T) {
+	loadTestModelDefaults(t)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Float32("temperature", 1.0, "")
+	flags.Float32("topP", 0.999, "")
+	flags.Int32("max-tokens", 4096, "")
+	if err := flags.Parse([]string{"--max-tokens", "100"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyModelDefaults(flags, "anthropic.claude-3-haiku-20240307-v1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	temperature, err := optionalFloat32Flag(flags, "temperature")
+	if err != nil || temperature == nil || *temperature != 0.2 {
+		t.Errorf("expected the model's temperature to be sent, got %v, %v", temperature, err)
+	}
+	if topP, _ := optionalFloat32Flag(flags, "topP"); topP != nil {
+		t.Errorf("expected topP to be left out, got %v", *topP)
+	}
+	if maxTokens, _ := flags.GetInt32("max-tokens"); maxTokens != 100 {
+		t.Errorf("expected the command line to win over model-defaults, got max-tokens %d", maxTokens)
+	}
+}
+
+func TestParseModelDefaultsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"not a list", "anthropic", "expected a list"},
+		{"no model", []interface{}{map[string]interface{}{"temperature": 0.5}}, "model is required"},
+		{"unknown key", []interface{}{map[string]interface{}{"model": "anthropic", "temp": 0.5}}, "unknown key"},
+		{"not a number", []interface{}{map[string]interface{}{"model": "anthropic", "max-tokens": "lots"}}, "expected a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseModelDefaults(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetModelDefaults(t *testing.T) {
+	entries := []modelDefaults{{model: "anthropic", params: map[string]string{"temperature": "0.5"}}}
+
+	entries = setModelDefaults(entries, "Anthropic", map[string]string{"max-tokens": "2048"})
+	entries = setModelDefaults(entries, "amazon", map[string]string{"topP": "0.9"})
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v; want the anthropic entry updated and one added", entries)
+	}
+	if got := formatModelDefaults(entries[0].params); got != "temperature=0.5, max-tokens=2048" {
+		t.Errorf("anthropic = %q", got)
+	}
+
+	// written back, the entries read the same
+	parsed, err := parseModelDefaults(modelDefaultsConfig(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatModelDefaults(parsed[1].params); parsed[1].model != "amazon" || got != "topP=0.9" {
+		t.Errorf("amazon = %s %q", parsed[1].model, got)
+	}
+}
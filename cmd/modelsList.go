@@ -5,14 +5,18 @@ package cmd
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/spf13/cobra"
 )
 
@@ -20,58 +24,276 @@ import (
 var modelsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all available models",
+	Long: `List the foundation models available in the region. The list is cached in
+chat-cli's data directory for a day; use chat-cli models refresh to update
+it sooner.
+
+--provider, --modality and --streaming-only narrow the list, and --output
+prints it as a table, JSON, or CSV for scripts.
+
+Each model shows whether it can be used on demand or only with provisioned
+throughput. --check-access also sends each text model a one-token request
+to find the ones the account hasn't been granted access to, and how to get
+it.
+
+> chat-cli models list --provider anthropic --streaming-only
+> chat-cli models list --modality text --check-access
+> chat-cli models list --modality embedding --output json`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		var filter modelFilter
+		if filter.provider, err = cmd.Flags().GetString("provider"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if filter.modality, err = cmd.Flags().GetString("modality"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if filter.streamingOnly, err = cmd.Flags().GetBool("streaming-only"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if err := filter.validate(); err != nil {
+			log.Fatal(err)
+		}
+
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if output != modelsOutputTable && output != modelsOutputJSON && output != modelsOutputCSV {
+			log.Fatalf("invalid --output %q: use %s, %s, or %s", output, modelsOutputTable, modelsOutputJSON, modelsOutputCSV)
+		}
+
+		checkAccess, err := cmd.Flags().GetBool("check-access")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		if err := listModels(cmd.Context(), region, filter, output, checkAccess); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// modelsRefreshCmd represents the models refresh command
+var modelsRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Update the cached list of models",
+	Long: `Fetch every foundation model in the region from Bedrock and replace the
+cached details chat-cli checks models against, such as when a new model
+has been enabled in the account.`,
+	Args: cobra.NoArgs,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		listModels()
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		cfg, err := loadAWSConfig(cmd.Context(), region)
+		if err != nil {
+			log.Fatalf("unable to load AWS config: %v", err)
+		}
+
+		models, err := newModelCatalog(cfg).list(cmd.Context(), true)
+		if err != nil {
+			log.Fatalf("Error listing models: %v", err)
+		}
+		fmt.Printf("Cached %d models in %s\n", len(models), region)
 	},
 }
 
 func init() {
 	modelsCmd.AddCommand(modelsListCmd)
+	modelsCmd.AddCommand(modelsRefreshCmd)
+	modelsListCmd.Flags().String("provider", "", "only list models from this provider, such as anthropic or amazon")
+	modelsListCmd.Flags().String("modality", "", "only list models that output text, image, or embedding")
+	modelsListCmd.Flags().Bool("streaming-only", false, "only list models that can stream their responses")
+	modelsListCmd.Flags().StringP("output", "o", modelsOutputTable, "output format: table, json, or csv")
+	modelsListCmd.Flags().Bool("check-access", false, "send each text model a one-token request to check the account has access to it")
+}
+
+// Formats models list can print in.
+const (
+	modelsOutputTable = "table"
+	modelsOutputJSON  = "json"
+	modelsOutputCSV   = "csv"
+)
+
+// modelModalities are the --modality values, which match a model's output.
+var modelModalities = []string{"text", "image", "embedding"}
+
+// modelFilter is the models models list keeps.
+type modelFilter struct {
+	provider      string
+	modality      string
+	streamingOnly bool
 }
 
-func listModels() {
+func (f modelFilter) validate() error {
+	if f.modality != "" && !slices.Contains(modelModalities, strings.ToLower(f.modality)) {
+		return fmt.Errorf("invalid --modality %q: use %s", f.modality, strings.Join(modelModalities, ", "))
+	}
+	return nil
+}
+
+// matches reports whether m passes the filter. The provider is matched
+// without case against the provider's name or the start of the model ID,
+// so both "stability ai" and "stability" find Stability AI's models.
+func (f modelFilter) matches(m modelInfo) bool {
+	if f.provider != "" {
+		prefix, _, _ := strings.Cut(m.ID, ".")
+		if !strings.EqualFold(f.provider, m.Provider) && !strings.EqualFold(f.provider, prefix) {
+			return false
+		}
+	}
+	if f.modality != "" && !slices.Contains(m.OutputModalities, strings.ToUpper(f.modality)) {
+		return false
+	}
+	return !f.streamingOnly || m.Streaming
+}
+
+func filterModels(models []modelInfo, f modelFilter) []modelInfo {
+	var kept []modelInfo
+	for _, m := range models {
+		if f.matches(m) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// modelRecord is one model in models list --output json.
+type modelRecord struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Provider         string   `json:"provider"`
+	InputModalities  []string `json:"input_modalities"`
+	OutputModalities []string `json:"output_modalities"`
+	Streaming        bool     `json:"streaming"`
+	InferenceTypes   []string `json:"inference_types"`
+	// Access is left out unless --check-access is set.
+	Access string `json:"access,omitempty"`
+}
+
+func listModels(ctx context.Context, region string, filter modelFilter, output string, checkAccess bool) error {
 	// Load the default configuration
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("us-east-1"))
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
-		fmt.Println("Error loading configuration:", err)
-		return
+		return fmt.Errorf("error loading configuration: %w", err)
 	}
 
-	// Create a new Bedrock client
-	svc := bedrock.NewFromConfig(cfg)
-
-	// Call the ListModels API
-	result, err := svc.ListFoundationModels(context.TODO(), &bedrock.ListFoundationModelsInput{})
+	models, err := newModelCatalog(cfg).list(ctx, false)
 	if err != nil {
-		fmt.Println("Error listing models:", err)
-		return
+		return fmt.Errorf("error listing models: %w", err)
 	}
+	models = filterModels(models, filter)
 
-	fmt.Println("")
+	var access []string
+	if checkAccess {
+		if access, err = checkModelAccess(ctx, models, converseProbe(bedrockruntime.NewFromConfig(cfg))); err != nil {
+			return fmt.Errorf("error checking model access: %w", err)
+		}
+	}
+
+	if err := writeModels(os.Stdout, models, access, output); err != nil {
+		return err
+	}
+	if help := accessHelp(region, access); help != "" {
+		fmt.Fprintf(stderr, "\n%s\n", help)
+	}
+	return nil
+}
+
+// writeModels prints models in the given --output format, with what
+// --check-access found for each when access isn't nil.
+func writeModels(out io.Writer, models []modelInfo, access []string, output string) error {
+	switch output {
+	case modelsOutputJSON:
+		records := make([]modelRecord, 0, len(models))
+		for i, m := range models {
+			record := modelRecord{
+				ID:               m.ID,
+				Name:             m.Name,
+				Provider:         m.Provider,
+				InputModalities:  m.InputModalities,
+				OutputModalities: m.OutputModalities,
+				Streaming:        m.Streaming,
+				InferenceTypes:   m.InferenceTypes,
+			}
+			if access != nil {
+				record.Access = access[i]
+			}
+			records = append(records, record)
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+
+	case modelsOutputCSV:
+		w := csv.NewWriter(out)
+		header := []string{"provider", "name", "model_id", "input_modalities", "output_modalities", "streaming", "inference_types"}
+		if access != nil {
+			header = append(header, "access")
+		}
+		if err := w.Write(header); err != nil {
+			return err
+		}
+		for i, m := range models {
+			row := []string{
+				m.Provider,
+				m.Name,
+				m.ID,
+				strings.Join(m.InputModalities, ";"),
+				strings.Join(m.OutputModalities, ";"),
+				strconv.FormatBool(m.Streaming),
+				strings.Join(m.InferenceTypes, ";"),
+			}
+			if access != nil {
+				row = append(row, access[i])
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	fmt.Fprintln(out, "")
 
 	// Create a new tabwriter
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
 
 	// Print the header
-	if _, err := fmt.Fprintln(w, "Provider\t Name\t Model ID"); err != nil {
+	header, separator := "Provider\t Name\t Model ID\t Inference", "\t\t\t"
+	if access != nil {
+		header, separator = header+"\t Access", separator+"\t"
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
 		log.Printf("Error writing header: %v", err)
 	}
 
-	if _, err := fmt.Fprintln(w, "\t\t"); err != nil {
+	if _, err := fmt.Fprintln(w, separator); err != nil {
 		log.Printf("Error writing separator: %v", err)
 	}
 
 	// Print the models
-	for i := range result.ModelSummaries {
-		model := &result.ModelSummaries[i]
-		if _, err := fmt.Fprintf(w, "%s\t %s\t %s\n", aws.ToString(model.ProviderName), aws.ToString(model.ModelName), aws.ToString(model.ModelId)); err != nil {
+	for i, model := range models {
+		row := fmt.Sprintf("%s\t %s\t %s\t %s", model.Provider, model.Name, model.ID, inferenceLabel(model.InferenceTypes))
+		if access != nil {
+			row += "\t " + access[i]
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
 			log.Printf("Error writing model data: %v", err)
 		}
 	}
 
 	// Flush the writer
-	if err := w.Flush(); err != nil {
-		log.Printf("Error flushing writer: %v", err)
-	}
+	return w.Flush()
 }
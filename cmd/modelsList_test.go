@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testModels = []modelInfo{
+	{ID: "amazon.titan-embed-text-v2:0", Name: "Titan Text Embeddings V2", Provider: "Amazon", InputModalities: []string{"TEXT"}, OutputModalities: []string{"EMBEDDING"}, InferenceTypes: []string{"ON_DEMAND"}},
+	{ID: "anthropic.claude-3-haiku-20240307-v1:0", Name: "Claude 3 Haiku", Provider: "Anthropic", InputModalities: []string{"TEXT", "IMAGE"}, OutputModalities: []string{"TEXT"}, Streaming: true, InferenceTypes: []string{"ON_DEMAND", "PROVISIONED"}},
+	{ID: "stability.sd3-large-v1:0", Name: "SD3 Large 1.0", Provider: "Stability AI", InputModalities: []string{"TEXT", "IMAGE"}, OutputModalities: []string{"IMAGE"}, InferenceTypes: []string{"PROVISIONED"}},
+}
+
+func TestFilterModels(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter modelFilter
+		want   []string
+	}{
+		{"no filter", modelFilter{}, []string{"amazon.titan-embed-text-v2:0", "anthropic.claude-3-haiku-20240307-v1:0", "stability.sd3-large-v1:0"}},
+		{"provider name", modelFilter{provider: "stability ai"}, []string{"stability.sd3-large-v1:0"}},
+		{"provider ID prefix", modelFilter{provider: "Stability"}, []string{"stability.sd3-large-v1:0"}},
+		{"modality", modelFilter{modality: "embedding"}, []string{"amazon.titan-embed-text-v2:0"}},
+		{"modality without case", modelFilter{modality: "Text"}, []string{"anthropic.claude-3-haiku-20240307-v1:0"}},
+		{"streaming only", modelFilter{streamingOnly: true}, []string{"anthropic.claude-3-haiku-20240307-v1:0"}},
+		{"all together", modelFilter{provider: "amazon", modality: "text", streamingOnly: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range filterModels(testModels, tt.filter) {
+				got = append(got, m.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelFilterValidate(t *testing.T) {
+	if err := (modelFilter{modality: "IMAGE"}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (modelFilter{modality: "video"}).validate(); err == nil {
+		t.Error("expected an error for an unknown modality")
+	}
+}
+
+func TestWriteModels(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeModels(&buf, testModels[1:2], nil, modelsOutputJSON); err != nil {
+			t.Fatal(err)
+		}
+		var records []modelRecord
+		if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+			t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+		}
+		if len(records) != 1 || records[0].ID != testModels[1].ID || !records[0].Streaming || len(records[0].InputModalities) != 2 || len(records[0].InferenceTypes) != 2 || records[0].Access != "" {
+			t.Errorf("records = %+v", records)
+		}
+	})
+
+	t.Run("json with no models", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeModels(&buf, nil, nil, modelsOutputJSON); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != "[]" {
+			t.Errorf("got %q, want []", got)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeModels(&buf, testModels[1:], nil, modelsOutputCSV); err != nil {
+			t.Fatal(err)
+		}
+		want := "provider,name,model_id,input_modalities,output_modalities,streaming,inference_types\n" +
+			"Anthropic,Claude 3 Haiku,anthropic.claude-3-haiku-20240307-v1:0,TEXT;IMAGE,TEXT,true,ON_DEMAND;PROVISIONED\n" +
+			"Stability AI,SD3 Large 1.0,stability.sd3-large-v1:0,TEXT;IMAGE,IMAGE,false,PROVISIONED\n"
+		if buf.String() != want {
+			t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeModels(&buf, testModels[:1], nil, modelsOutputTable); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "Model ID") || !strings.Contains(buf.String(), "amazon.titan-embed-text-v2:0") || !strings.Contains(buf.String(), "on-demand") {
+			t.Errorf("table = %q", buf.String())
+		}
+		if strings.Contains(buf.String(), "Access") {
+			t.Errorf("table has an Access column without --check-access: %q", buf.String())
+		}
+	})
+
+	t.Run("table with access", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeModels(&buf, testModels[1:2], []string{accessDenied}, modelsOutputTable); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "Access") || !strings.Contains(buf.String(), "on-demand, provisioned") || !strings.Contains(buf.String(), accessDenied) {
+			t.Errorf("table = %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,29 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxTurnSize is the longest line newLineTurnReader accepts as one turn.
+const maxTurnSize = 1024 * 1024
+
+// newLineTurnReader returns a function that reads the user's turns for
+// chat --non-interactive, one per line of r. Blank lines are skipped. It
+// reports false once r is exhausted.
+func newLineTurnReader(r io.Reader) func() (string, bool) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTurnSize)
+	return func() (string, bool) {
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				return line, true
+			}
+		}
+		return "", false
+	}
+}
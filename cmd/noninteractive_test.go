@@ -0,0 +1,31 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewLineTurnReader(t *testing.T) {
+	next := newLineTurnReader(strings.NewReader("hello\n\n   \n  what's 2+2?  \r\n/model\nlast line without newline"))
+
+	var turns []string
+	for {
+		turn, ok := next()
+		if !ok {
+			break
+		}
+		turns = append(turns, turn)
+	}
+
+	want := []string{"hello", "what's 2+2?", "/model", "last line without newline"}
+	if strings.Join(turns, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", turns, want)
+	}
+
+	if _, ok := next(); ok {
+		t.Error("expected no more turns after EOF")
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// offlineKey turns on offline mode from config, like --offline.
+const offlineKey = "offline"
+
+// offlineFlag is the value of --offline, set before each command runs.
+var offlineFlag bool
+
+// errOffline is returned by loadAWSConfig in offline mode, so every command
+// that needs AWS stops before it makes a call.
+var errOffline = errors.New("chat-cli is offline (--offline or the offline setting) and this command needs AWS; " +
+	"offline, only local features work, such as chat list, chat replay, chat archive, and config")
+
+// configuredOffline reports whether chat-cli should stay off the network:
+// --offline, then CHAT_CLI_OFFLINE, then offline in the active profile or
+// the config file.
+func configuredOffline(fm *conf.FileManager) (bool, error) {
+	if offlineFlag {
+		return true, nil
+	}
+	value := configString(fm, offlineKey)
+	if value == "" {
+		return false, nil
+	}
+	offline, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: use true or false", offlineKey, value)
+	}
+	return offline, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestConfiguredOffline(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	saved := offlineFlag
+	t.Cleanup(func() { offlineFlag = saved })
+	offlineFlag = false
+	fm := &conf.FileManager{}
+
+	if offline, err := configuredOffline(fm); offline || err != nil {
+		t.Errorf("expected online by default, got %v, %v", offline, err)
+	}
+
+	viper.Set(offlineKey, "true")
+	if offline, err := configuredOffline(fm); !offline || err != nil {
+		t.Errorf("expected the config file to turn offline mode on, got %v, %v", offline, err)
+	}
+
+	t.Setenv("CHAT_CLI_OFFLINE", "false")
+	if offline, err := configuredOffline(fm); offline || err != nil {
+		t.Errorf("expected the environment to override the config file, got %v, %v", offline, err)
+	}
+
+	offlineFlag = true
+	if offline, err := configuredOffline(fm); !offline || err != nil {
+		t.Errorf("expected --offline to win, got %v, %v", offline, err)
+	}
+
+	offlineFlag = false
+	t.Setenv("CHAT_CLI_OFFLINE", "sometimes")
+	if _, err := configuredOffline(fm); err == nil || !strings.Contains(err.Error(), "use true or false") {
+		t.Errorf("expected an invalid value to be reported, got %v", err)
+	}
+}
+
+func TestLoadAWSConfigOffline(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	saved := offlineFlag
+	t.Cleanup(func() { offlineFlag = saved })
+	offlineFlag = true
+
+	if _, err := loadAWSConfig(t.Context(), "us-east-1"); !errors.Is(err, errOffline) {
+		t.Errorf("expected loadAWSConfig to refuse offline, got %v", err)
+	}
+}
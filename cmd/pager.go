@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// Values for the pager setting. With "auto", a response taller than the
+// terminal is opened in a pager once it has finished streaming.
+const (
+	pagerAuto = "auto"
+	pagerOff  = "off"
+)
+
+// defaultPager is used when $PAGER isn't set. -R passes ANSI colors through.
+const defaultPager = "less -R"
+
+// validatePagerMode returns an error for anything other than auto or off.
+func validatePagerMode(mode string) error {
+	if mode != pagerAuto && mode != pagerOff {
+		return fmt.Errorf("invalid pager setting %q: use %q or %q", mode, pagerAuto, pagerOff)
+	}
+	return nil
+}
+
+// pagerCommand splits $PAGER, or the default, into a program and its
+// arguments. A blank $PAGER gives an empty command, which disables paging.
+func pagerCommand() []string {
+	pager, ok := os.LookupEnv("PAGER")
+	if !ok {
+		pager = defaultPager
+	}
+	return strings.Fields(pager)
+}
+
+// displayLines counts the terminal rows text takes up at the given width,
+// wrapping long lines. Every rune is counted as one column, which is close
+// enough to decide whether text fits on a screen.
+func displayLines(text string, width int) int {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return 0
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	rows := 0
+	for _, line := range strings.Split(text, "\n") {
+		n := utf8.RuneCountInString(line)
+		if n == 0 {
+			rows++
+			continue
+		}
+		rows += (n + width - 1) / width
+	}
+	return rows
+}
+
+// needsPager reports whether text is too tall to read on a screen of the
+// given size, leaving a row for the prompt that follows it.
+func needsPager(text string, width, height int) bool {
+	return height > 0 && displayLines(text, width) >= height
+}
+
+// pageResponse opens text in the pager when mode is auto, stdout is a
+// terminal, and text doesn't fit on it. Headless runs never page, since
+// nobody is there to quit the pager. The response has already been
+// printed, so a pager that fails to start is only reported.
+func pageResponse(mode, text string) {
+	if mode != pagerAuto || utils.Headless() || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || !needsPager(text, width, height) {
+		return
+	}
+
+	if err := runPager(pagerCommand(), strings.NewReader(text)); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to open pager: %v\n", err)
+	}
+}
+
+// runPager runs the pager with text on its stdin, attached to the
+// terminal.
+func runPager(command []string, text io.Reader) error {
+	if len(command) == 0 {
+		return nil
+	}
+
+	pager := exec.Command(command[0], command[1:]...) // #nosec G204 - the pager is the user's own $PAGER
+	pager.Stdin = text
+	pager.Stdout = os.Stdout
+	pager.Stderr = os.Stderr
+	return pager.Run()
+}
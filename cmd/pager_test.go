@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisplayLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  int
+	}{
+		{"empty", "", 80, 0},
+		{"one line", "hello\n", 80, 1},
+		{"blank lines count", "a\n\nb", 80, 3},
+		{"wraps long lines", strings.Repeat("x", 25), 10, 3},
+		{"exact width", strings.Repeat("x", 10), 10, 1},
+		{"counts runes, not bytes", strings.Repeat("é", 10), 10, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayLines(tt.text, tt.width); got != tt.want {
+				t.Errorf("displayLines(%q, %d) = %d, want %d", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsPager(t *testing.T) {
+	short := "one\ntwo\n"
+	long := strings.Repeat("line\n", 30)
+
+	if needsPager(short, 80, 24) {
+		t.Error("expected a short response to fit")
+	}
+	if !needsPager(long, 80, 24) {
+		t.Error("expected a long response to need the pager")
+	}
+	if needsPager(long, 80, 0) {
+		t.Error("expected an unknown height to never page")
+	}
+}
+
+func TestValidatePagerMode(t *testing.T) {
+	for _, mode := range []string{pagerAuto, pagerOff} {
+		if err := validatePagerMode(mode); err != nil {
+			t.Errorf("%s: unexpected error %v", mode, err)
+		}
+	}
+	if err := validatePagerMode("always"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestPagerCommand(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		t.Setenv("PAGER", "")
+		if err := os.Unsetenv("PAGER"); err != nil {
+			t.Fatal(err)
+		}
+		if got := pagerCommand(); !reflect.DeepEqual(got, []string{"less", "-R"}) {
+			t.Errorf("unexpected default pager %v", got)
+		}
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("PAGER", "most -s")
+		if got := pagerCommand(); !reflect.DeepEqual(got, []string{"most", "-s"}) {
+			t.Errorf("unexpected pager %v", got)
+		}
+	})
+
+	t.Run("blank disables", func(t *testing.T) {
+		t.Setenv("PAGER", " ")
+		if got := pagerCommand(); len(got) != 0 {
+			t.Errorf("expected no pager, got %v", got)
+		}
+	})
+}
+
+func TestRunPager(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "paged.txt")
+	if err := runPager([]string{"sh", "-c", "cat > " + out}, strings.NewReader("long answer\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "long answer\n" {
+		t.Errorf("expected the response on the pager's stdin, got %q", got)
+	}
+
+	if err := runPager(nil, strings.NewReader("ignored")); err != nil {
+		t.Errorf("expected no pager to be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// personaSettingKey is the chat setting a chat's persona is kept under, so
+// the chat record shows who it was had with.
+const personaSettingKey = "persona"
+
+// personaFlags maps each persona setting to the chat and prompt flag it
+// provides a value for.
+var personaFlags = map[string]string{
+	"system-prompt": "system",
+	"model-id":      "model-id",
+	"temperature":   "temperature",
+	"topP":          "topP",
+	"max-tokens":    "max-tokens",
+}
+
+// applyPersona sets the flags the persona chosen with --persona has
+// settings for, leaving alone those given on the command line. It runs
+// before the active profile is applied, so a persona's model and
+// parameters win over the profile's.
+func applyPersona(flags *pflag.FlagSet) error {
+	if flags.Lookup("persona") == nil {
+		return nil
+	}
+	name, err := flags.GetString("persona")
+	if err != nil || name == "" {
+		return err
+	}
+
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		return err
+	}
+	settings, err := fm.ReadPersona(name)
+	if errors.Is(err, conf.ErrPersonaNotFound) {
+		return fmt.Errorf("no persona named %s (see chat-cli personas list)", name)
+	}
+	if err != nil {
+		return err
+	}
+	return applyPersonaSettings(flags, settings)
+}
+
+// applyPersonaSettings sets each flag in personaFlags that wasn't given on
+// the command line to the persona's value for it.
+func applyPersonaSettings(flags *pflag.FlagSet, settings map[string]interface{}) error {
+	for key, flagName := range personaFlags {
+		value, ok := settings[key]
+		if !ok {
+			continue
+		}
+
+		f := flags.Lookup(flagName)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("persona setting %s: %w", key, err)
+		}
+		f.Changed = true
+	}
+	return nil
+}
+
+// isPersonaCommand reports whether cmd is one of the personas commands,
+// whose flags define a persona rather than take one on.
+func isPersonaCommand(cmd *cobra.Command) bool {
+	return cmd == personasCmd || cmd.Parent() == personasCmd
+}
+
+// personaFileManager loads config for the personas commands.
+func personaFileManager() *conf.FileManager {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+	return fm
+}
+
+// personaSettingsFromFlags collects the settings given as flags to
+// personas create.
+func personaSettingsFromFlags(flags *pflag.FlagSet) map[string]string {
+	settings := make(map[string]string)
+	for key := range conf.PersonaSchema {
+		if f := flags.Lookup(key); f != nil && f.Changed {
+			settings[key] = f.Value.String()
+		}
+	}
+	return settings
+}
+
+// personasCmd represents the personas command
+var personasCmd = &cobra.Command{
+	Use:   "personas",
+	Short: "Manage named personas: a system prompt with its model and parameters",
+	Long: `Manage personas. A persona is a system prompt, optionally with the model and
+inference parameters that suit it, saved under a name so a chat or prompt can take
+it on with --persona. Flags given alongside --persona still win over its settings.
+
+A chat keeps the name of the persona it was started with.
+
+> chat-cli personas create reviewer --system-prompt "You review Go code. Point out bugs first." --temperature 0.2
+> chat-cli --persona reviewer
+> chat-cli prompt --persona reviewer "Is this safe to call concurrently?" < cache.go`,
+}
+
+// personasCreateCmd represents the personas create command
+var personasCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a persona",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		settings := personaSettingsFromFlags(cmd.Flags())
+		if settings["system-prompt"] == "" {
+			log.Fatal("a persona needs a --system-prompt")
+		}
+
+		fm := personaFileManager()
+		if err := fm.CreatePersona(args[0], settings, force); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created persona %s\n", args[0])
+		fmt.Printf("Use it with: chat-cli --persona %s\n", args[0])
+	},
+}
+
+// personasListCmd represents the personas list command
+var personasListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List personas",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fm := personaFileManager()
+
+		names := fm.Personas()
+		if len(names) == 0 {
+			fmt.Println("No personas yet. Create one with: chat-cli personas create <name> --system-prompt <prompt>")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSETTINGS")
+		for _, name := range names {
+			settings, err := fm.PersonaSettings(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			// the prompt can be long; show shows it whole
+			delete(settings, "system-prompt")
+			fmt.Fprintf(w, "%s\t%s\n", name, formatProfileSettings(settings))
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing output: %v", err)
+		}
+	},
+}
+
+// personasShowCmd represents the personas show command
+var personasShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a persona's system prompt and settings",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		settings, err := personaFileManager().PersonaSettings(args[0])
+		if errors.Is(err, conf.ErrPersonaNotFound) {
+			log.Fatalf("no persona named %s (see chat-cli personas list)", args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		prompt := fmt.Sprint(settings["system-prompt"])
+		delete(settings, "system-prompt")
+		if len(settings) > 0 {
+			fmt.Printf("Settings: %s\n\n", formatProfileSettings(settings))
+		}
+		fmt.Println(prompt)
+	},
+}
+
+// personasDeleteCmd represents the personas delete command
+var personasDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a persona",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := personaFileManager().DeletePersona(args[0])
+		if errors.Is(err, conf.ErrPersonaNotFound) {
+			log.Fatalf("no persona named %s (see chat-cli personas list)", args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted persona %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(personasCmd)
+	personasCmd.AddCommand(personasCreateCmd)
+	personasCmd.AddCommand(personasListCmd)
+	personasCmd.AddCommand(personasShowCmd)
+	personasCmd.AddCommand(personasDeleteCmd)
+
+	personasCreateCmd.Flags().String("system-prompt", "", "the system prompt the persona is given (required)")
+	personasCreateCmd.Flags().String("model-id", "", "the model id or inference profile id the persona uses")
+	personasCreateCmd.Flags().Float32("temperature", 0, "the temperature (0-1)")
+	personasCreateCmd.Flags().Float32("topP", 0, "the top-P (0-1)")
+	personasCreateCmd.Flags().Int32("max-tokens", 0, "the max tokens")
+	personasCreateCmd.Flags().BoolP("force", "f", false, "replace an existing persona with the same name")
+
+	rootCmd.PersistentFlags().String("persona", "", "take on a persona saved with chat-cli personas: its system prompt, model, and parameters (chat only)")
+	promptCmd.PersistentFlags().String("persona", "", "take on a persona saved with chat-cli personas: its system prompt, model, and parameters")
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyPersonaSettings(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("system", "", "")
+	flags.String("model-id", DefaultModelID, "")
+	flags.Float32("temperature", 1.0, "")
+	flags.Int32("max-tokens", 4096, "")
+	if err := flags.Parse([]string{"--model-id", "cli-model"}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := map[string]interface{}{
+		"system-prompt": "You review Go code.",
+		"model-id":      "persona-model",
+		"temperature":   "0.2",
+		"max-tokens":    1024,
+	}
+	if err := applyPersonaSettings(flags, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if system, _ := flags.GetString("system"); system != "You review Go code." {
+		t.Errorf("system = %q", system)
+	}
+	if modelID, _ := flags.GetString("model-id"); modelID != "cli-model" {
+		t.Errorf("expected the command line to win over the persona, got model-id %q", modelID)
+	}
+	temperature, err := optionalFloat32Flag(flags, "temperature")
+	if err != nil || temperature == nil || *temperature != 0.2 {
+		t.Errorf("expected the persona's temperature to be sent, got %v, %v", temperature, err)
+	}
+	if maxTokens, _ := flags.GetInt32("max-tokens"); maxTokens != 1024 {
+		t.Errorf("max-tokens = %d", maxTokens)
+	}
+
+	// the active profile is applied afterwards and mustn't override it
+	if err := applyProfileSettings(flags, map[string]interface{}{"temperature": "0.9"}); err != nil {
+		t.Fatal(err)
+	}
+	if temperature, _ := flags.GetFloat32("temperature"); temperature != 0.2 {
+		t.Errorf("expected the persona to win over the profile, got temperature %v", temperature)
+	}
+
+	t.Run("bad values", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Int32("max-tokens", 4096, "")
+		if err := applyPersonaSettings(flags, map[string]interface{}{"max-tokens": "lots"}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestPersonaFlags(t *testing.T) {
+	for _, cmd := range []string{"chat", "prompt"} {
+		c, _, err := rootCmd.Find([]string{cmd})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, flag := range personaFlags {
+			if c.Flags().Lookup(flag) == nil && c.InheritedFlags().Lookup(flag) == nil {
+				t.Errorf("%s has no --%s flag for the persona's %s", cmd, flag, key)
+			}
+		}
+		if c.Flags().Lookup("persona") == nil && c.InheritedFlags().Lookup("persona") == nil {
+			t.Errorf("%s has no --persona flag", cmd)
+		}
+	}
+}
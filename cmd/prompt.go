@@ -5,16 +5,21 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"slices"
+	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrock"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
-	"github.com/chat-cli/chat-cli/utils" //nolint:goimports // false positive from CI version diff
-	"github.com/spf13/cobra"             //nolint:goimports // false positive from CI version diff
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/templates" //nolint:goimports // false positive from CI version diff
+	"github.com/chat-cli/chat-cli/utils"     //nolint:goimports // false positive from CI version diff
+	"github.com/spf13/cobra"                 //nolint:goimports // false positive from CI version diff
 
 	conf "github.com/chat-cli/chat-cli/config" //nolint:goimports // false positive from CI version diff
 )
@@ -25,11 +30,32 @@ var promptCmd = &cobra.Command{
 	Short: "Send a prompt to a LLM",
 	Long: `Allows you to send a one-line prompt to Amazon Bedrock like so:
 
-> chat-cli prompt "What is your name?"`,
-	Args: cobra.MinimumNArgs(1),
+> chat-cli prompt "What is your name?"
+
+Or fill in a saved template (see chat-cli templates):
+
+> chat-cli prompt --template review --var file=main.go
+
+Or run a prompt from Bedrock Prompt Management, filling in its variables:
+
+> chat-cli prompt --prompt-arn arn:aws:bedrock:us-east-1:123456789012:prompt/PROMPT12345:1 --var topic=lighthouses`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		// templates and managed prompts carry their own text, so the
+		// argument is optional
+		promptArn, _ := cmd.PersistentFlags().GetString("prompt-arn")
+		templateName, _ := cmd.PersistentFlags().GetString("template")
+		if promptArn != "" || templateName != "" {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
 
-		prompt := args[0]
+		var prompt string
+		if len(args) > 0 {
+			prompt = args[0]
+		}
 
 		document, err := utils.LoadDocument()
 		if err != nil {
@@ -52,7 +78,7 @@ var promptCmd = &cobra.Command{
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
-		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+		cfg, err := loadAWSConfig(ctx, region)
 		if err != nil {
 			log.Fatalf("unable to load AWS config: %v", err)
 		}
@@ -62,8 +88,8 @@ var promptCmd = &cobra.Command{
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
-		// get feature flag for image attachment
-		image, err := cmd.PersistentFlags().GetString("image")
+		// get feature flag for image attachments
+		images, err := cmd.PersistentFlags().GetStringArray("image")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
 		}
@@ -74,6 +100,52 @@ var promptCmd = &cobra.Command{
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
+		files, err := cmd.PersistentFlags().GetStringArray("file")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		injectionCheckFlag, err := cmd.PersistentFlags().GetString("injection-check")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		guard := injectionGuard{
+			mode: fmt.Sprint(fm.GetConfigValue("injection-check", injectionCheckFlag, injectionWarn)),
+			out:  os.Stderr,
+		}
+		if err := validateInjectionCheck(guard.mode); err != nil {
+			log.Fatal(err)
+		}
+
+		// extract --file text up front, so a bad file fails before any
+		// request is made
+		fileChunks, _, err := loadFileChunks(files, guard)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		contextDir, err := cmd.PersistentFlags().GetString("context-dir")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		contextDirTokens, err := cmd.PersistentFlags().GetInt("context-dir-tokens")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		contextInclude, err := cmd.PersistentFlags().GetStringArray("context-include")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		// the directory's files go in alongside any --file text
+		if contextDoc, _, dirErr := loadContextDir(contextDir, contextDirTokens, contextInclude); dirErr != nil {
+			log.Fatal(dirErr)
+		} else if contextDoc != "" {
+			fileChunks = append(fileChunks, contextDoc)
+		}
+
 		// check if --no-stream is set
 		noStream, err := cmd.PersistentFlags().GetBool("no-stream")
 		if err != nil {
@@ -85,6 +157,19 @@ var promptCmd = &cobra.Command{
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
+		inferenceProfile, err := cmd.PersistentFlags().GetString("inference-profile")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if err := checkInferenceProfile(inferenceProfile); err != nil {
+			log.Fatal(err)
+		}
+
+		skipValidation, err := cmd.PersistentFlags().GetBool("skip-validation")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
 		systemFlag, err := cmd.PersistentFlags().GetString("system")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
@@ -109,15 +194,255 @@ var promptCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
+		reasoningFlagValue, err := reasoningFlag(cmd.PersistentFlags())
+		if err != nil {
+			log.Fatal(err)
+		}
+		reasoningDisplay := fmt.Sprint(fm.GetConfigValue("reasoning", reasoningFlagValue, reasoningShow))
+		if err := validateReasoningDisplay(reasoningDisplay); err != nil {
+			log.Fatal(err)
+		}
+
+		dryRun, err := cmd.PersistentFlags().GetBool("dry-run")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		pagerFlag, err := cmd.PersistentFlags().GetString("pager")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		outputFormat, err := cmd.PersistentFlags().GetString("output")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if err := validatePromptOutput(outputFormat); err != nil {
+			log.Fatal(err)
+		}
+		asJSON := outputFormat == promptOutputJSON
+
+		verbose, err := cmd.PersistentFlags().GetBool("verbose")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		jsonSchemaPath, err := cmd.PersistentFlags().GetString("json-schema")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		jsonSchemaRetries, err := cmd.PersistentFlags().GetInt("json-schema-retries")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if jsonSchemaRetries < 0 {
+			log.Fatal("--json-schema-retries can't be negative")
+		}
+
+		var jsonSchema map[string]interface{}
+		if jsonSchemaPath != "" {
+			jsonSchema, err = loadJSONSchema(jsonSchemaPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		formatFlag, err := cmd.PersistentFlags().GetString("format")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		format, err := parseResponseFormat(formatFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if format != "" && jsonSchema != nil {
+			log.Fatal("--format and --json-schema can't be used together")
+		}
+
+		promptArn, err := cmd.PersistentFlags().GetString("prompt-arn")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		kbName, err := cmd.PersistentFlags().GetString("kb")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		kbTopK, err := cmd.PersistentFlags().GetInt("kb-top-k")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		kbRerank, err := cmd.PersistentFlags().GetBool("kb-rerank")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		requireCitations, err := cmd.PersistentFlags().GetBool("require-citations")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		knowledgeBaseID, err := cmd.PersistentFlags().GetString("knowledge-base-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		knowledgeBaseResults, err := cmd.PersistentFlags().GetInt("knowledge-base-results")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if knowledgeBaseID != "" {
+			// RetrieveAndGenerate takes the question as plain text and
+			// nothing else
+			switch {
+			case promptArn != "" || kbName != "":
+				log.Fatal("--knowledge-base-id can't be used with --prompt-arn or --kb")
+			case len(images) > 0 || documentPath != "" || document != "" || len(fileChunks) > 0:
+				log.Fatal("--knowledge-base-id can't be used with attachments or piped input")
+			case knowledgeBaseResults <= 0:
+				log.Fatal("--knowledge-base-results must be positive")
+			}
+		}
+
+		guardrailIDFlag, err := cmd.PersistentFlags().GetString("guardrail-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		guardrailVersionFlag, err := cmd.PersistentFlags().GetString("guardrail-version")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		promptVarFlags, err := cmd.PersistentFlags().GetStringArray("var")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		templateName, err := cmd.PersistentFlags().GetString("template")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if templateName != "" && promptArn != "" {
+			log.Fatal("--template and --prompt-arn can't be used together")
+		}
+		if jsonSchema != nil && (promptArn != "" || knowledgeBaseID != "") {
+			// neither takes a system prompt to ask for JSON with
+			log.Fatal("--json-schema can't be used with --prompt-arn or --knowledge-base-id")
+		}
+		if format != "" && (promptArn != "" || knowledgeBaseID != "") {
+			log.Fatal("--format can't be used with --prompt-arn or --knowledge-base-id")
+		}
+
+		promptVariables, err := parsePromptVariables(promptVarFlags)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if promptArn == "" && templateName == "" && promptVariables != nil {
+			log.Fatal("--var requires --template or --prompt-arn")
+		}
+		if templateName != "" {
+			// the filled-in template is the prompt, followed by the
+			// argument if there is one
+			prompt, err = renderTemplatePrompt(templates.NewStore(fm.ConfigPath), templateName, promptVarFlags, prompt)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if promptArn != "" {
+			if _, _, ok := parsePromptARN(promptArn); !ok {
+				log.Fatalf("%q is not a Bedrock prompt ARN", promptArn)
+			}
+		}
+
+		compareFlag, err := cmd.PersistentFlags().GetStringSlice("compare")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		var compare []string
+		if cmd.PersistentFlags().Changed("compare") {
+			if compare, err = parseCompareModels(compareFlag); err != nil {
+				log.Fatal(err)
+			}
+			switch {
+			case cmd.PersistentFlags().Changed("model-id") || cmd.PersistentFlags().Changed("custom-arn") || inferenceProfile != "":
+				log.Fatal("--compare names the models to use, so it can't be used with --model-id, --custom-arn or --inference-profile")
+			case promptArn != "" || knowledgeBaseID != "" || jsonSchema != nil || format != "":
+				log.Fatal("--compare can't be used with --prompt-arn, --knowledge-base-id, --json-schema or --format")
+			}
+		}
+		if requireCitations {
+			switch {
+			case kbName == "":
+				log.Fatal("--require-citations needs --kb")
+			case compare != nil || jsonSchema != nil || format != "":
+				log.Fatal("--require-citations can't be used with --compare, --json-schema or --format")
+			}
+		}
+
+		speakStream, err := cmd.PersistentFlags().GetBool("speak-stream")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if speakStream && (noStream || compare != nil || knowledgeBaseID != "" || jsonSchema != nil || format != "") {
+			log.Fatal("--speak-stream reads a streamed reply, so it can't be used with --no-stream, --compare, --knowledge-base-id, --json-schema or --format")
+		}
+
 		// Get configuration values with precedence order (flag -> config -> default)
-		modelId := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+		modelIdValue, modelIdSource := fm.ResolveConfigValue("model-id", modelIdFlag, DefaultModelID)
+		modelId := modelIdValue.(string)
 		customArn := fm.GetConfigValue("custom-arn", customArnFlag, "").(string)
+
+		// routing rules pick the model from what's being sent, unless a
+		// model was asked for with -m or CHAT_CLI_MODEL_ID
+		explicitModel := cmd.PersistentFlags().Changed("model-id") || modelIdSource == conf.SourceEnv
+		if promptArn == "" && customArn == "" && inferenceProfile == "" && !explicitModel && compare == nil {
+			rules, err := loadRoutingRules()
+			if err != nil {
+				log.Fatal(err)
+			}
+			routed := strings.Join(append([]string{prompt, document}, fileChunks...), "\n")
+			if rule, n, ok := routeModel(rules, routed); ok {
+				modelId = rule.modelID
+				if verbose || dryRun {
+					fmt.Fprintf(stderr, "\033[90mRouted to %s by routing rule %d (%s)\033[0m\n", modelId, n, rule.describe())
+				}
+			} else if verbose && len(rules) > 0 {
+				fmt.Fprintf(stderr, "\033[90mNo routing rule matched, using %s\033[0m\n", modelId)
+			}
+		}
 		systemPrompt := fm.GetConfigValue("system-prompt", systemFlag, "").(string)
+		if jsonSchema != nil {
+			systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + jsonSchemaInstruction(jsonSchema))
+		}
+		if format != "" {
+			systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + format.instruction())
+		}
+		pagerMode := fmt.Sprint(fm.GetConfigValue("pager", pagerFlag, pagerOff))
+		if err := validatePagerMode(pagerMode); err != nil {
+			log.Fatal(err)
+		}
+		guardrail := buildGuardrailStreamConfig(
+			fm.GetConfigValue("guardrail-id", guardrailIDFlag, "").(string),
+			fm.GetConfigValue("guardrail-version", guardrailVersionFlag, "").(string),
+		)
 
 		// Ensure custom-arn takes precedence over model-id when both are set
 		// If custom-arn is set (from any source), use it; otherwise use model-id
 		var finalModelId string
-		if customArn != "" {
+		if promptArn != "" {
+			// the managed prompt's own model is used; its ARN goes where the
+			// model ID would
+			finalModelId = promptArn
+		} else if compare != nil {
+			// the request is built for the first model, and sent to each
+			finalModelId = compare[0]
+		} else if inferenceProfile != "" {
+			finalModelId = inferenceProfile
+		} else if customArn != "" {
 			finalModelId = customArn
 		} else {
 			finalModelId = modelId
@@ -126,40 +451,62 @@ var promptCmd = &cobra.Command{
 		var modelIdString string
 
 		bedrockSvc := bedrock.NewFromConfig(cfg)
-
-		if customArn == "" && !isInferenceProfileID(finalModelId) {
+		models := newModelCatalog(cfg)
+
+		if compare != nil {
+			for i, model := range compare {
+				if !dryRun {
+					if compare[i], err = resolveChatModelID(ctx, models, model, skipValidation); err != nil {
+						log.Fatal(err)
+					}
+				}
+				// each model must take the images being sent
+				if len(images) > 0 {
+					if _, err := buildImageContentBlocks(images, compare[i]); err != nil {
+						log.Fatal(err)
+					}
+				}
+			}
+			modelIdString = compare[0]
+		} else if !dryRun && !skipValidation && promptArn == "" && customArn == "" && !isInferenceProfileID(finalModelId) {
 			// Using a foundation model-id, validate with Bedrock
-			model, modelErr := bedrockSvc.GetFoundationModel(context.TODO(), &bedrock.GetFoundationModelInput{
-				ModelIdentifier: &finalModelId,
-			})
+			model, modelErr := models.model(ctx, finalModelId)
 			if modelErr != nil {
 				log.Fatalf("error: %v", modelErr)
 			}
 
 			// check if this is a text model
-			if !slices.Contains(model.ModelDetails.OutputModalities, "TEXT") {
-				log.Fatalf("model %s is not a text model, so it can't be used with the chat function", *model.ModelDetails.ModelId)
+			if !slices.Contains(model.OutputModalities, "TEXT") {
+				log.Fatalf("model %s is not a text model, so it can't be used with the chat function", model.ID)
 			}
 
 			// check if model supports image/vision capabilities
-			if (image != "") && (!slices.Contains(model.ModelDetails.InputModalities, "IMAGE")) {
-				log.Fatalf("model %s does not support images as input. please use a different model", *model.ModelDetails.ModelId)
+			if len(images) > 0 && !slices.Contains(model.InputModalities, "IMAGE") {
+				log.Fatalf("model %s does not support images as input. please use a different model", model.ID)
 			}
 
 			// check if model supports streaming and --no-stream is not set
-			if (!noStream) && (!*model.ModelDetails.ResponseStreamingSupported) {
-				log.Fatalf("model %s does not support streaming. please use the --no-stream flag", *model.ModelDetails.ModelId)
+			if (!noStream) && (!model.Streaming) {
+				log.Fatalf("model %s does not support streaming. please use the --no-stream flag", model.ID)
 			}
 
-			modelIdString = *model.ModelDetails.ModelId
+			modelIdString = model.ID
 		} else {
-			// Inference profile or custom ARN — pass through to Converse directly
+			// Inference profile or custom ARN (or a dry run, which doesn't
+			// call Bedrock at all, or --skip-validation) — pass through to
+			// Converse directly
 			modelIdString = finalModelId
 		}
 
+		// fill in inference parameters the flags and profile left unset
+		if err := applyModelDefaults(cmd.PersistentFlags(), modelIdString); err != nil {
+			log.Fatal(err)
+		}
+
 		// get options — temperature and topP are omitted from the Bedrock
-		// request unless explicitly set on the command line, since newer
-		// models (e.g. Claude Sonnet 5) reject them entirely.
+		// request unless explicitly set on the command line, a profile, or
+		// model-defaults, since newer models (e.g. Claude Sonnet 5) reject
+		// them entirely.
 		temperature, err := optionalFloat32Flag(cmd.PersistentFlags(), "temperature")
 		if err != nil {
 			log.Fatalf("unable to get flag: %v", err)
@@ -175,6 +522,63 @@ var promptCmd = &cobra.Command{
 			log.Fatalf("unable to get flag: %v", err)
 		}
 
+		// a Bedrock knowledge base retrieves passages and writes the answer
+		// in one call, so none of the Converse request below is needed
+		if knowledgeBaseID != "" {
+			modelARN := modelIdString
+			// looking up an inference profile's ARN calls Bedrock, which a
+			// dry run doesn't
+			if !dryRun || !isInferenceProfileID(modelIdString) {
+				modelARN, err = knowledgeBaseModelARN(ctx, bedrockSvc, region, modelIdString)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			ragReq := newRAGRequest(knowledgeBaseID, modelARN, prompt, knowledgeBaseResults, ragTextInferenceConfig{MaxTokens: maxTokens, Temperature: temperature, TopP: topP})
+			ragReq.setGuardrail(guardrail)
+			if dryRun {
+				data, err := json.MarshalIndent(ragReq, "", "  ")
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Printf("RetrieveAndGenerate request:\n%s\n", data)
+				return
+			}
+
+			start := time.Now()
+			resp, err := newKnowledgeBaseClient(cfg).retrieveAndGenerate(ctx, ragReq)
+			if err != nil {
+				log.Fatalf("error from Bedrock, %v", err)
+			}
+
+			// RetrieveAndGenerate reports no stop reason or usage, only
+			// whether a guardrail stepped in
+			var stopReason types.StopReason
+			if resp.GuardrailAction == "INTERVENED" {
+				stopReason = types.StopReasonGuardrailIntervened
+			}
+
+			answer := formatRAGAnswer(resp)
+			if asJSON {
+				if err := writePromptResult(os.Stdout, newPromptResult(modelIdString, prompt, answer, stopReason, nil, time.Since(start))); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				fmt.Println(answer)
+			}
+			if verbose {
+				printStopReason(stderr, stopReason)
+			}
+			if notice := guardrailNotice(stopReason, guardrail); notice != "" {
+				fmt.Fprintf(stderr, "\033[33m%s\033[0m\n", notice)
+			}
+			if !asJSON {
+				pageResponse(pagerMode, answer)
+			}
+			return
+		}
+
 		svc := bedrockruntime.NewFromConfig(cfg)
 
 		// craft prompt - split into separate document/question content blocks
@@ -186,22 +590,54 @@ var promptCmd = &cobra.Command{
 			Content: buildQuestionContent(document, prompt),
 		}
 
-		// attach image if we have one
-		if image != "" {
-			imageBytes, imageType, err := utils.ReadImage(image)
-			if err != nil {
-				log.Fatalf("unable to read image: %v", err)
+		// chunks retrieved from --kb go just ahead of the question, after
+		// the cache point that follows any --file text, since they change
+		// with every question
+		var kbMatches []kbMatch
+		if kbName != "" {
+			database, dbErr := openDatabase(fm)
+			if dbErr != nil {
+				log.Fatal(dbErr)
+			}
+			retriever, kbErr := loadKBRetriever(ctx, repository.NewKnowledgeBaseRepository(database), kbName, kbTopK, invokeModelWith(svc))
+			if closeErr := database.Close(); closeErr != nil {
+				log.Printf("Warning: failed to close database: %v", closeErr)
+			}
+			if kbErr != nil {
+				log.Fatal(kbErr)
+			}
+			retriever.reranker, kbErr = configuredKBReranker(fm, cfg, kbRerank)
+			if kbErr != nil {
+				log.Fatal(kbErr)
 			}
 
-			userMsg.Content = append(userMsg.Content, &types.ContentBlockMemberImage{
-				Value: types.ImageBlock{
-					Format: types.ImageFormat(imageType),
-					Source: &types.ImageSourceMemberBytes{
-						Value: imageBytes,
-					},
-				},
-			})
+			if dryRun {
+				// retrieval embeds the question with Bedrock
+				fmt.Fprintf(os.Stderr, "note: --dry-run doesn't search knowledge base %s\n", kbName)
+			} else {
+				kbMatches, kbErr = retriever.retrieve(ctx, prompt)
+				if kbErr != nil {
+					log.Fatal(kbErr)
+				}
+				if line := kbRerankLine(kbMatches, kbTopK); verbose && line != "" {
+					fmt.Fprintln(os.Stderr, line)
+				}
+				userMsg.Content = append([]types.ContentBlock{kbContentBlock(kbName, kbMatches, requireCitations)}, userMsg.Content...)
+			}
+		}
 
+		// text from --file and --context-dir goes ahead of the question
+		if len(fileChunks) > 0 {
+			userMsg.Content = append(buildFileContentBlocks(fileChunks), userMsg.Content...)
+		}
+
+		// attach images if we have any, checked against the model's limits
+		if len(images) > 0 {
+			imageBlocks, err := buildImageContentBlocks(images, modelIdString)
+			if err != nil {
+				log.Fatal(err)
+			}
+			userMsg.Content = append(userMsg.Content, imageBlocks...)
 		}
 
 		// attach a document if we have one (independent of --image, Rule 5)
@@ -210,80 +646,253 @@ var promptCmd = &cobra.Command{
 			if docErr != nil {
 				log.Fatalf("unable to read document: %v", docErr)
 			}
+			docBytes, docErr = guard.screenDocument(documentPath, docBytes, docFormat)
+			if docErr != nil {
+				log.Fatal(docErr)
+			}
 
 			userMsg.Content = append(userMsg.Content, buildDocumentContentBlock(docBytes, docFormat, sanitizeDocumentName(documentPath)))
 		}
 
 		conf := buildInferenceConfiguration(maxTokens, temperature, topP)
 
-		if noStream {
-			// set up ConverseInput with model and prompt
-			converseInput := &bedrockruntime.ConverseInput{
-				ModelId:                      &modelIdString,
-				InferenceConfig:              &conf,
-				System:                       withSystemCachePoint(buildSystemContentBlocks(systemPrompt)),
-				AdditionalModelRequestFields: buildReasoningConfig(modelIdString, thinkingEnabled, thinkingBudget, thinkingEffort),
+		converseStreamInput := &bedrockruntime.ConverseStreamInput{
+			ModelId:                      &modelIdString,
+			InferenceConfig:              &conf,
+			System:                       withSystemCachePoint(buildSystemContentBlocks(systemPrompt)),
+			AdditionalModelRequestFields: buildReasoningConfig(modelIdString, thinkingEnabled, thinkingBudget, thinkingEffort),
+			Messages:                     []types.Message{userMsg},
+			GuardrailConfig:              guardrail,
+		}
+		if promptArn != "" {
+			converseStreamInput.System, converseStreamInput.InferenceConfig, converseStreamInput.AdditionalModelRequestFields = nil, nil, nil
+			converseStreamInput.Messages = managedPromptMessages(userMsg)
+			converseStreamInput.PromptVariables = promptVariables
+		}
+
+		if dryRun {
+			if compare != nil {
+				fmt.Fprintf(os.Stderr, "note: this request would be sent to each of %s\n", strings.Join(compare, ", "))
 			}
-			converseInput.Messages = append(converseInput.Messages, userMsg)
+			printDryRun(os.Stdout, converseStreamInput)
+			return
+		}
 
+		// throttled requests are retried, showing the wait on stderr
+		retrier := newBedrockRetrier(fm)
+		retriedSvc := bedrockruntime.NewFromConfig(cfg, retrier.clientOptions)
+
+		// --compare sends the request to every model at once and prints
+		// the answers together once they're all in
+		if compare != nil {
+			send := retrier.withoutSpinner().wrap(injectedFaults.wrap(func(ctx context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+				output, err := converseStreamWithFallbacks(ctx, retriedSvc, in)
+				if err != nil {
+					return nil, err
+				}
+				return output.GetStream().Events(), nil
+			}))
+			results := compareModels(ctx, send, converseStreamInput, compare, reasoningFields(thinkingEnabled, thinkingBudget, thinkingEffort))
+
+			if asJSON {
+				if err := writeComparisonJSON(os.Stdout, prompt, results); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				writeComparison(os.Stdout, results)
+			}
+			for _, result := range results {
+				if line := stopReasonLine(result.stopReason); verbose && line != "" {
+					fmt.Fprintf(stderr, "\033[90m%s: %s\033[0m\n", result.model, line)
+				}
+				if notice := guardrailNotice(result.stopReason, guardrail); notice != "" {
+					fmt.Fprintf(stderr, "\033[33m%s: %s\033[0m\n", result.model, notice)
+				}
+			}
+			for _, result := range results {
+				if result.err != nil {
+					os.Exit(1)
+				}
+			}
+			return
+		}
+
+		// same request, without streaming, for --no-stream, --json-schema
+		// and --format
+		converseInput := &bedrockruntime.ConverseInput{
+			ModelId:                      converseStreamInput.ModelId,
+			InferenceConfig:              converseStreamInput.InferenceConfig,
+			System:                       converseStreamInput.System,
+			AdditionalModelRequestFields: converseStreamInput.AdditionalModelRequestFields,
+			Messages:                     converseStreamInput.Messages,
+			PromptVariables:              converseStreamInput.PromptVariables,
+			GuardrailConfig:              guardrailConfigForConverse(converseStreamInput.GuardrailConfig),
+		}
+
+		// a response that doesn't match the schema or format is asked for
+		// again, so it isn't streamed
+		if jsonSchema != nil || format != "" {
+			start := time.Now()
+			converse := retrier.converse(func(ctx context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+				return converseWithFallbacks(ctx, retriedSvc, in)
+			})
+			var response string
+			var output *bedrockruntime.ConverseOutput
+			var usage tokenUsage
+			if jsonSchema != nil {
+				response, output, usage, err = structuredResponse(ctx, converse, converseInput, jsonSchema, jsonSchemaRetries, func(attempt int, problems []string) {
+					fmt.Fprintf(stderr, "\033[90mThe response doesn't match the schema (%s), retrying (%d of %d)\033[0m\n", strings.Join(problems, "; "), attempt, jsonSchemaRetries)
+				})
+			} else {
+				response, output, usage, err = format.response(ctx, converse, converseInput, func(attempt int, problems []string) {
+					fmt.Fprintf(stderr, "\033[90mThe response isn't in the %s format (%s), retrying\033[0m\n", format, strings.Join(problems, "; "))
+				})
+			}
+			var stopReason types.StopReason
+			if output != nil {
+				stopReason = output.StopReason
+			}
+			if verbose {
+				printStopReason(stderr, stopReason)
+			}
+			if notice := guardrailNotice(stopReason, guardrail); notice != "" {
+				fmt.Fprintf(stderr, "\033[33m%s\033[0m\n", notice)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if asJSON {
+				if err := writePromptResult(os.Stdout, newPromptResult(modelIdString, prompt, response, stopReason, &usage, time.Since(start))); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				fmt.Println(response)
+			}
+			return
+		}
+
+		if noStream {
 			// invoke and wait for full response
-			output, err := converseWithFallbacks(context.TODO(), svc, converseInput)
+			start := time.Now()
+			output, err := retrier.converse(func(ctx context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+				return converseWithFallbacks(ctx, retriedSvc, in)
+			})(ctx, converseInput)
 			if err != nil {
 				log.Fatalf("error from Bedrock, %v", err)
 			}
 
 			response, _ := output.Output.(*types.ConverseOutputMemberMessage)
+			if asJSON {
+				usage := usageFromBedrock(output.Usage)
+				result := newPromptResult(modelIdString, prompt, messageText(response.Value), output.StopReason, &usage, time.Since(start))
+				if requireCitations {
+					result.Citations = newPromptCitations(kbMatches, checkKBCitations(result.Response, kbMatches))
+				}
+				if err := writePromptResult(os.Stdout, result); err != nil {
+					log.Fatal(err)
+				}
+				if verbose {
+					printStopReason(stderr, output.StopReason)
+				}
+				if notice := guardrailNotice(output.StopReason, guardrail); notice != "" {
+					fmt.Fprintf(stderr, "\033[33m%s\033[0m\n", notice)
+				}
+				return
+			}
+
 			for _, block := range response.Value.Content {
 				if reasoningBlock, ok := block.(*types.ContentBlockMemberReasoningContent); ok {
-					printReasoningBlock(reasoningBlock)
+					printReasoningBlock(reasoningBlock, reasoningDisplay == reasoningHide)
 				}
 			}
 			for _, block := range response.Value.Content {
 				if textBlock, ok := block.(*types.ContentBlockMemberText); ok {
 					fmt.Println(textBlock.Value)
+					pageResponse(pagerMode, textBlock.Value)
 					break
 				}
 			}
+			if requireCitations {
+				printKBCitations(os.Stdout, stderr, messageText(response.Value), kbMatches)
+			}
+			if verbose {
+				printStopReason(stderr, output.StopReason)
+			}
+			if notice := guardrailNotice(output.StopReason, guardrail); notice != "" {
+				fmt.Fprintf(stderr, "\033[33m%s\033[0m\n", notice)
+			}
 
 		} else {
-			converseStreamInput := &bedrockruntime.ConverseStreamInput{
-				ModelId:                      &modelIdString,
-				InferenceConfig:              &conf,
-				System:                       withSystemCachePoint(buildSystemContentBlocks(systemPrompt)),
-				AdditionalModelRequestFields: buildReasoningConfig(modelIdString, thinkingEnabled, thinkingBudget, thinkingEffort),
+			// invoke with streaming response; the usage Bedrock reports at
+			// the end of the stream is kept for --output json
+			usage := newUsageTracker()
+			send := meteredSend(retrier.wrap(injectedFaults.wrap(func(ctx context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+				output, err := converseStreamWithFallbacks(ctx, retriedSvc, in)
+				if err != nil {
+					return nil, err
+				}
+				return output.GetStream().Events(), nil
+			})), usage)
+
+			// the reply streams to stdout, reasoning included; with
+			// --output json it's printed whole at the end instead
+			renderer := newReplyRenderer(os.Stdout, os.Stdout)
+			renderer.quiet = asJSON
+			renderer.hideReasoning = reasoningDisplay == reasoningHide
+
+			// --speak-stream reads the reply aloud as it arrives
+			var speaker *streamSpeaker
+			if speakStream {
+				speaker, err = newStreamSpeaker(ctx, fm, cfg)
+				if err != nil {
+					log.Fatal(err)
+				}
+				speaker.start(ctx)
 			}
-			converseStreamInput.Messages = append(converseStreamInput.Messages, userMsg)
 
-			// invoke with streaming response
-			output, err := converseStreamWithFallbacks(context.Background(), svc, converseStreamInput)
+			start := time.Now()
+			msg, _, stopReason, resumes, err := streamResponse(ctx, send, converseStreamInput, speakAlong(speaker, renderer.onText), renderer.onReasoning)
 			if err != nil {
 				log.Fatalf("error from Bedrock, %v", err)
 			}
-
-			reasoningActive := false
-			onText := func(ctx context.Context, part string) error {
-				if reasoningActive {
-					fmt.Print("\033[0m\n\n")
-					reasoningActive = false
+			if speaker != nil {
+				if err := speaker.finish(ctx); err != nil {
+					fmt.Fprintf(stderr, "\n\033[33mspeech: %v\033[0m\n", err)
 				}
-				fmt.Print(part)
-				return nil
 			}
-			onReasoning := func(ctx context.Context, part string) error {
-				if !reasoningActive {
-					fmt.Print("\033[90m[thinking] ")
-					reasoningActive = true
-				}
-				fmt.Print(part)
-				return nil
+			if resumes > 0 {
+				fmt.Fprintf(stderr, "\n\033[90m%s\033[0m\n", resumedNote(resumes))
 			}
 
-			_, err = utils.ProcessStreamingOutput(output, onText, onReasoning)
-			if err != nil {
-				log.Fatal("streaming output processing error: ", err)
+			if asJSON {
+				result := newPromptResult(modelIdString, prompt, messageText(msg), stopReason, &usage.turn, time.Since(start))
+				if requireCitations {
+					result.Citations = newPromptCitations(kbMatches, checkKBCitations(result.Response, kbMatches))
+				}
+				if err := writePromptResult(os.Stdout, result); err != nil {
+					log.Fatal(err)
+				}
+				if verbose {
+					printStopReason(stderr, stopReason)
+				}
+				if notice := guardrailNotice(stopReason, guardrail); notice != "" {
+					fmt.Fprintf(stderr, "\033[33m%s\033[0m\n", notice)
+				}
+				return
 			}
 
 			fmt.Println()
+			if requireCitations {
+				printKBCitations(os.Stdout, stderr, messageText(msg), kbMatches)
+			}
+			if verbose {
+				printStopReason(stderr, stopReason)
+			}
+			if notice := guardrailNotice(stopReason, guardrail); notice != "" {
+				fmt.Fprintf(stderr, "\033[33m%s\033[0m\n", notice)
+			}
+			pageResponse(pagerMode, messageText(msg))
 		}
 	},
 }
@@ -292,16 +901,46 @@ func init() {
 	rootCmd.AddCommand(promptCmd)
 	promptCmd.PersistentFlags().StringP("model-id", "m", DefaultModelID, "set the model id or inference profile id")
 	promptCmd.PersistentFlags().String("custom-arn", "", "pass a custom arn from bedrock marketplace or cross-region inference")
+	promptCmd.PersistentFlags().String("inference-profile", "", "use a cross-region or application inference profile ID or ARN as the model (overrides --model-id and --custom-arn)")
+	promptCmd.PersistentFlags().Bool("skip-validation", false, "send the model ID to Bedrock as is, without checking it with GetFoundationModel first")
 	promptCmd.PersistentFlags().String("system", "", "set a system prompt")
+	promptCmd.PersistentFlags().String("guardrail-id", "", "ID or ARN of a Bedrock guardrail to apply to the request and response")
+	promptCmd.PersistentFlags().String("guardrail-version", "", "version of the guardrail (default DRAFT)")
 	promptCmd.PersistentFlags().Bool("thinking", false, "enable extended thinking / reasoning mode")
 	promptCmd.PersistentFlags().Int32("thinking-budget", 1024, "token budget for extended thinking on legacy models (requires --thinking)")
 	promptCmd.PersistentFlags().String("thinking-effort", defaultThinkingEffort, "reasoning effort for adaptive models: low, medium, or high (requires --thinking)")
+	promptCmd.PersistentFlags().Bool("show-reasoning", false, "show the model's reasoning as it streams (the default; overrides reasoning: hide in config)")
+	promptCmd.PersistentFlags().Bool("hide-reasoning", false, "collapse the model's reasoning to a line giving its length")
 
-	promptCmd.PersistentFlags().StringP("image", "i", "", "path to image")
+	promptCmd.PersistentFlags().StringArrayP("image", "i", nil, "path to an image (repeatable, e.g. -i before.png -i after.png)")
 	promptCmd.PersistentFlags().StringP("document", "d", "", "path to a document (pdf, csv, doc, docx, xls, xlsx, html, txt, md)")
+	promptCmd.PersistentFlags().StringArray("file", nil, "extract the text of a pdf, docx, html, txt, md, or csv file into the prompt (repeatable)")
+	promptCmd.PersistentFlags().String("context-dir", "", "include the text files in a directory (respecting .gitignore) as context")
+	promptCmd.PersistentFlags().Int("context-dir-tokens", defaultContextDirTokens, "approximate token budget for --context-dir")
+	promptCmd.PersistentFlags().StringArray("context-include", nil, "only include --context-dir files matching this glob, e.g. \"*.go\" (repeatable)")
+	promptCmd.PersistentFlags().String("kb", "", "add the most relevant chunks of a local knowledge base to the prompt (see chat-cli kb)")
+	promptCmd.PersistentFlags().Int("kb-top-k", defaultKBTopK, "how many chunks --kb adds")
+	promptCmd.PersistentFlags().Bool("require-citations", false, "have the answer cite the --kb chunks it uses, flag it if it cites none, and list the sources after it")
+	promptCmd.PersistentFlags().Bool("kb-rerank", false, "have a Bedrock reranking model choose the chunks --kb adds from a wider set (see kb.rerank)")
+	promptCmd.PersistentFlags().String("knowledge-base-id", "", "answer from a Bedrock knowledge base with RetrieveAndGenerate, citing its sources")
+	promptCmd.PersistentFlags().Int("knowledge-base-results", defaultKnowledgeBaseResults, "how many passages --knowledge-base-id retrieves")
 	promptCmd.PersistentFlags().Bool("no-stream", false, "return the full response once it has completed")
+	promptCmd.PersistentFlags().Bool("speak-stream", false, "read the reply aloud with Amazon Polly as it streams in (experimental)")
+	promptCmd.PersistentFlags().Bool("dry-run", false, "print the assembled request and an estimated token count instead of sending it")
+	promptCmd.PersistentFlags().String("pager", "", "open responses taller than the terminal in $PAGER once complete: auto or off")
+	promptCmd.PersistentFlags().String("injection-check", "", "what to do with --file and --document passages that look like prompt injection: warn (default), strip, or off")
+	promptCmd.PersistentFlags().String("json-schema", "", "path to a JSON Schema the response must match; it's checked and asked for again if it doesn't")
+	promptCmd.PersistentFlags().Int("json-schema-retries", defaultJSONSchemaRetries, "how many times to ask again for a response that doesn't match --json-schema")
+	promptCmd.PersistentFlags().String("format", "", "ask for the response as a bullet list, a table, or code only (bullet, table, or code-only); it's checked and asked for again once if it isn't")
+	promptCmd.PersistentFlags().Bool("verbose", false, "show which model was chosen and why, and why the model stopped, on stderr")
+	promptCmd.PersistentFlags().StringP("output", "o", promptOutputText, "output format: text, or json for the response with its model, stop reason, usage, and latency")
+	promptCmd.PersistentFlags().StringSlice("compare", nil, "send the prompt to each of these models at once and show their answers together, e.g. --compare model1,model2")
 
 	promptCmd.PersistentFlags().Float32("temperature", 1.0, "optional temperature (0-1); omitted from the request unless set")
 	promptCmd.PersistentFlags().Float32("topP", 0.999, "optional top-P (0-1); omitted from the request unless set")
 	promptCmd.PersistentFlags().Int32("max-tokens", 4096, "max tokens")
+
+	promptCmd.PersistentFlags().String("template", "", "fill in a saved prompt template (see chat-cli templates) and send it")
+	promptCmd.PersistentFlags().String("prompt-arn", "", "run a managed prompt from Bedrock Prompt Management (its model and settings are used)")
+	promptCmd.PersistentFlags().StringArray("var", nil, "set a template or managed prompt variable as key=value (repeatable, requires --template or --prompt-arn)")
 }
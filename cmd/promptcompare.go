@@ -0,0 +1,132 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// compareResult is one model's answer in a prompt --compare run.
+type compareResult struct {
+	model      string
+	text       string
+	stopReason types.StopReason
+	usage      tokenUsage
+	latency    time.Duration
+	err        error
+}
+
+// parseCompareModels cleans up --compare's list of models, dropping blanks
+// and repeats. It takes at least two models to compare.
+func parseCompareModels(values []string) ([]string, error) {
+	var models []string
+	for _, value := range values {
+		model := strings.TrimSpace(value)
+		if model != "" && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	if len(models) < 2 {
+		return nil, fmt.Errorf("--compare needs at least two models, e.g. --compare %s,amazon.nova-lite-v1:0", DefaultModelID)
+	}
+	return models, nil
+}
+
+// compareModels sends input to each of models at once and returns their
+// answers in the same order. requestFields gives the model-specific
+// request fields, such as reasoning settings, for each model.
+func compareModels(ctx context.Context, send converseStreamFunc, input *bedrockruntime.ConverseStreamInput, models []string, requestFields func(model string) document.Interface) []compareResult {
+	results := make([]compareResult, len(models))
+
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// each request gets its own copy, since the fallbacks in
+			// converseStreamWithFallbacks rewrite the one they're given
+			in := *input
+			in.ModelId = aws.String(model)
+			in.AdditionalModelRequestFields = requestFields(model)
+			in.Messages = slices.Clone(input.Messages)
+
+			usage := newUsageTracker()
+			start := time.Now()
+			result := compareResult{model: model}
+
+			events, err := meteredSend(send, usage)(ctx, &in)
+			if err == nil {
+				var msg types.Message
+				msg, _, result.stopReason, err = accumulateStream(ctx, events, discardStream, discardStream)
+				result.text = messageText(msg)
+			}
+			result.err = err
+			result.usage = usage.turn
+			result.latency = time.Since(start)
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// reasoningFields returns the request fields that turn on reasoning for
+// each model, for compareModels.
+func reasoningFields(enabled bool, budgetTokens int32, effort string) func(model string) document.Interface {
+	return func(model string) document.Interface {
+		return buildReasoningConfig(model, enabled, budgetTokens, effort)
+	}
+}
+
+// discardStream is a utils.StreamingOutputHandler that ignores what it's
+// given, for replies that are collected rather than shown as they stream.
+func discardStream(context.Context, string) error { return nil }
+
+// writeComparison prints each model's answer under a heading with its
+// latency and token usage.
+func writeComparison(w io.Writer, results []compareResult) {
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if result.err != nil {
+			fmt.Fprintf(w, "=== %s · failed after %s ===\n%v\n", result.model, formatElapsed(result.latency), result.err)
+			continue
+		}
+		fmt.Fprintf(w, "=== %s · %s · %s ===\n%s\n", result.model, formatElapsed(result.latency), formatTokenCounts(result.usage), strings.TrimSpace(result.text))
+	}
+}
+
+// writeComparisonJSON prints the answers as a JSON array of the objects
+// prompt --output json prints for one.
+func writeComparisonJSON(w io.Writer, prompt string, results []compareResult) error {
+	out := make([]promptResult, 0, len(results))
+	for _, result := range results {
+		if result.err != nil {
+			r := newPromptResult(result.model, prompt, "", "", nil, result.latency)
+			r.Error = result.err.Error()
+			out = append(out, r)
+			continue
+		}
+		out = append(out, newPromptResult(result.model, prompt, result.text, result.stopReason, &result.usage, result.latency))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestParseCompareModels(t *testing.T) {
+	models, err := parseCompareModels([]string{" model-a", "model-b", "", "model-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(models, ",") != "model-a,model-b" {
+		t.Errorf("expected blanks and repeats dropped, got %v", models)
+	}
+
+	if _, err := parseCompareModels([]string{"model-a", "model-a"}); err == nil {
+		t.Error("expected one model to be rejected")
+	}
+}
+
+func TestCompareModels(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	send := func(_ context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		model := aws.ToString(in.ModelId)
+		mu.Lock()
+		seen = append(seen, model)
+		mu.Unlock()
+		if model == "model-c" {
+			return nil, errors.New("access denied")
+		}
+		return streamEvents([]fixtureEvent{
+			{Text: "answer from " + model},
+			{Stop: "end_turn"},
+			{Usage: &struct {
+				Input  int32 `json:"input"`
+				Output int32 `json:"output"`
+			}{Input: 10, Output: 4}},
+		}), nil
+	}
+
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:  aws.String("model-a"),
+		Messages: []types.Message{userTextMessage("which is best?")},
+	}
+	noFields := reasoningFields(false, 0, "")
+	results := compareModels(t.Context(), send, input, []string{"model-a", "model-b", "model-c"}, noFields)
+
+	if len(seen) != 3 {
+		t.Errorf("expected a request per model, got %v", seen)
+	}
+	if aws.ToString(input.ModelId) != "model-a" {
+		t.Errorf("expected the original request to be left alone, got %s", aws.ToString(input.ModelId))
+	}
+	for i, want := range []string{"model-a", "model-b"} {
+		r := results[i]
+		if r.model != want || r.text != "answer from "+want || r.stopReason != types.StopReasonEndTurn || r.usage.output != 4 || r.err != nil {
+			t.Errorf("unexpected result for %s: %+v", want, r)
+		}
+	}
+	if results[2].err == nil {
+		t.Error("expected the failed model's error")
+	}
+
+	var out bytes.Buffer
+	writeComparison(&out, results)
+	for _, want := range []string{"=== model-a · ", "10 in / 4 out ===\nanswer from model-a\n", "=== model-c · failed after ", "access denied"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := writeComparisonJSON(&out, "which is best?", results); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []promptResult
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[1].Response != "answer from model-b" || decoded[2].Error != "access denied" {
+		t.Errorf("unexpected JSON results %+v", decoded)
+	}
+}
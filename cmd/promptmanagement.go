@@ -0,0 +1,313 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockagent"
+	agenttypes "github.com/aws/aws-sdk-go-v2/service/bedrockagent/types"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// managedPromptVariant is the name given to the single variant chat-cli
+// creates for each pushed template.
+const managedPromptVariant = "default"
+
+// managedPromptSummary is one entry of a ListPrompts response.
+type managedPromptSummary struct {
+	Arn         string `json:"arn"`
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type promptInputVariable struct {
+	Name string `json:"name"`
+}
+
+type textPromptTemplate struct {
+	Text           string                `json:"text"`
+	InputVariables []promptInputVariable `json:"inputVariables,omitempty"`
+}
+
+type promptTemplateConfiguration struct {
+	Text *textPromptTemplate `json:"text,omitempty"`
+}
+
+type promptVariant struct {
+	Name                  string                      `json:"name"`
+	TemplateType          string                      `json:"templateType"`
+	ModelId               string                      `json:"modelId,omitempty"`
+	TemplateConfiguration promptTemplateConfiguration `json:"templateConfiguration"`
+}
+
+// managedPrompt is the request body of CreatePrompt/UpdatePrompt and the
+// response of GetPrompt.
+type managedPrompt struct {
+	Arn            string          `json:"arn,omitempty"`
+	Id             string          `json:"id,omitempty"`
+	Name           string          `json:"name"`
+	Description    string          `json:"description,omitempty"`
+	Version        string          `json:"version,omitempty"`
+	DefaultVariant string          `json:"defaultVariant,omitempty"`
+	Variants       []promptVariant `json:"variants"`
+}
+
+// variant returns the prompt's default variant, or its first one if no
+// default is set.
+func (p *managedPrompt) variant() (*promptVariant, error) {
+	for i := range p.Variants {
+		if p.Variants[i].Name == p.DefaultVariant {
+			return &p.Variants[i], nil
+		}
+	}
+	if len(p.Variants) > 0 {
+		return &p.Variants[0], nil
+	}
+	return nil, fmt.Errorf("prompt %s has no variants", p.Name)
+}
+
+// promptManagementClient calls the Bedrock Prompt Management API
+// (bedrock-agent).
+type promptManagementClient struct {
+	agent *bedrockagent.Client
+}
+
+func newPromptManagementClient(cfg aws.Config) *promptManagementClient {
+	return &promptManagementClient{bedrockagent.NewFromConfig(cfg)}
+}
+
+// listPrompts returns every managed prompt in the region.
+func (c *promptManagementClient) listPrompts(ctx context.Context) ([]managedPromptSummary, error) {
+	var prompts []managedPromptSummary
+
+	pages := bedrockagent.NewListPromptsPaginator(c.agent, &bedrockagent.ListPromptsInput{MaxResults: aws.Int32(100)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.PromptSummaries {
+			prompts = append(prompts, managedPromptSummary{
+				Arn:         aws.ToString(p.Arn),
+				Id:          aws.ToString(p.Id),
+				Name:        aws.ToString(p.Name),
+				Description: aws.ToString(p.Description),
+				Version:     aws.ToString(p.Version),
+			})
+		}
+	}
+	return prompts, nil
+}
+
+// getPrompt fetches a prompt by ID, at version (the draft if empty).
+func (c *promptManagementClient) getPrompt(ctx context.Context, id, version string) (*managedPrompt, error) {
+	input := &bedrockagent.GetPromptInput{PromptIdentifier: aws.String(id)}
+	if version != "" {
+		input.PromptVersion = aws.String(version)
+	}
+
+	out, err := c.agent.GetPrompt(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return &managedPrompt{
+		Arn:            aws.ToString(out.Arn),
+		Id:             aws.ToString(out.Id),
+		Name:           aws.ToString(out.Name),
+		Description:    aws.ToString(out.Description),
+		Version:        aws.ToString(out.Version),
+		DefaultVariant: aws.ToString(out.DefaultVariant),
+		Variants:       fromAgentVariants(out.Variants),
+	}, nil
+}
+
+func (c *promptManagementClient) createPrompt(ctx context.Context, prompt *managedPrompt) (*managedPrompt, error) {
+	out, err := c.agent.CreatePrompt(ctx, &bedrockagent.CreatePromptInput{
+		Name:           aws.String(prompt.Name),
+		Description:    optionalString(prompt.Description),
+		DefaultVariant: optionalString(prompt.DefaultVariant),
+		Variants:       toAgentVariants(prompt.Variants),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &managedPrompt{
+		Arn:            aws.ToString(out.Arn),
+		Id:             aws.ToString(out.Id),
+		Name:           aws.ToString(out.Name),
+		Description:    aws.ToString(out.Description),
+		Version:        aws.ToString(out.Version),
+		DefaultVariant: aws.ToString(out.DefaultVariant),
+		Variants:       fromAgentVariants(out.Variants),
+	}, nil
+}
+
+func (c *promptManagementClient) updatePrompt(ctx context.Context, id string, prompt *managedPrompt) (*managedPrompt, error) {
+	out, err := c.agent.UpdatePrompt(ctx, &bedrockagent.UpdatePromptInput{
+		PromptIdentifier: aws.String(id),
+		Name:             aws.String(prompt.Name),
+		Description:      optionalString(prompt.Description),
+		DefaultVariant:   optionalString(prompt.DefaultVariant),
+		Variants:         toAgentVariants(prompt.Variants),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &managedPrompt{
+		Arn:            aws.ToString(out.Arn),
+		Id:             aws.ToString(out.Id),
+		Name:           aws.ToString(out.Name),
+		Description:    aws.ToString(out.Description),
+		Version:        aws.ToString(out.Version),
+		DefaultVariant: aws.ToString(out.DefaultVariant),
+		Variants:       fromAgentVariants(out.Variants),
+	}, nil
+}
+
+// createPromptVersion snapshots the prompt's draft and returns the new
+// version's ARN.
+func (c *promptManagementClient) createPromptVersion(ctx context.Context, id string) (string, error) {
+	out, err := c.agent.CreatePromptVersion(ctx, &bedrockagent.CreatePromptVersionInput{PromptIdentifier: aws.String(id)})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Arn), nil
+}
+
+// toAgentVariants converts variants for a CreatePrompt or UpdatePrompt
+// request. Only text templates are sent; chat-cli doesn't make others.
+func toAgentVariants(variants []promptVariant) []agenttypes.PromptVariant {
+	converted := make([]agenttypes.PromptVariant, 0, len(variants))
+	for _, v := range variants {
+		variant := agenttypes.PromptVariant{
+			Name:         aws.String(v.Name),
+			TemplateType: agenttypes.PromptTemplateType(v.TemplateType),
+			ModelId:      optionalString(v.ModelId),
+		}
+		if text := v.TemplateConfiguration.Text; text != nil {
+			config := agenttypes.TextPromptTemplateConfiguration{Text: aws.String(text.Text)}
+			for _, in := range text.InputVariables {
+				config.InputVariables = append(config.InputVariables, agenttypes.PromptInputVariable{Name: aws.String(in.Name)})
+			}
+			variant.TemplateConfiguration = &agenttypes.PromptTemplateConfigurationMemberText{Value: config}
+		}
+		converted = append(converted, variant)
+	}
+	return converted
+}
+
+// fromAgentVariants converts the variants of a prompt returned by Prompt
+// Management. A variant with a chat template has no Text configuration.
+func fromAgentVariants(variants []agenttypes.PromptVariant) []promptVariant {
+	converted := make([]promptVariant, 0, len(variants))
+	for _, v := range variants {
+		variant := promptVariant{
+			Name:         aws.ToString(v.Name),
+			TemplateType: string(v.TemplateType),
+			ModelId:      aws.ToString(v.ModelId),
+		}
+		if text, ok := v.TemplateConfiguration.(*agenttypes.PromptTemplateConfigurationMemberText); ok {
+			template := &textPromptTemplate{Text: aws.ToString(text.Value.Text)}
+			for _, in := range text.Value.InputVariables {
+				template.InputVariables = append(template.InputVariables, promptInputVariable{Name: aws.ToString(in.Name)})
+			}
+			variant.TemplateConfiguration.Text = template
+		}
+		converted = append(converted, variant)
+	}
+	return converted
+}
+
+// optionalString returns nil for an empty s, so an unset field is left
+// out of a request.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// parsePromptARN splits a prompt ARN such as
+// arn:aws:bedrock:us-east-1:123456789012:prompt/PROMPT12345:2 into its
+// prompt ID and version (empty for the draft). ok is false if arn isn't a
+// prompt ARN.
+func parsePromptARN(arn string) (id, version string, ok bool) {
+	if !strings.HasPrefix(arn, "arn:") {
+		return "", "", false
+	}
+	_, resource, found := strings.Cut(arn, ":prompt/")
+	if !found || resource == "" {
+		return "", "", false
+	}
+	id, version, _ = strings.Cut(resource, ":")
+	return id, version, id != ""
+}
+
+// unversionedPromptARN strips the version suffix from a prompt ARN.
+func unversionedPromptARN(arn string) string {
+	_, version, ok := parsePromptARN(arn)
+	if !ok || version == "" {
+		return arn
+	}
+	return strings.TrimSuffix(arn, ":"+version)
+}
+
+// parseVarFlags turns --var key=value flags into a map. Everything after
+// the first '=' is the value.
+func parseVarFlags(vars []string) (map[string]string, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		key, value, ok := strings.Cut(v, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --var %q: expected key=value", v)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parsePromptVariables turns --var key=value flags into the variables
+// Converse fills into a managed prompt.
+func parsePromptVariables(vars []string) (map[string]types.PromptVariableValues, error) {
+	parsed, err := parseVarFlags(vars)
+	if err != nil || parsed == nil {
+		return nil, err
+	}
+
+	values := make(map[string]types.PromptVariableValues, len(parsed))
+	for key, value := range parsed {
+		values[key] = &types.PromptVariableValuesMemberText{Value: value}
+	}
+	return values, nil
+}
+
+// managedPromptMessages returns the messages to send after a managed
+// prompt: userMsg without empty text blocks, or none at all if nothing is
+// left, since the prompt itself supplies the request.
+func managedPromptMessages(userMsg types.Message) []types.Message {
+	var content []types.ContentBlock
+	for _, block := range userMsg.Content {
+		if text, ok := block.(*types.ContentBlockMemberText); ok && text.Value == "" {
+			continue
+		}
+		content = append(content, block)
+	}
+	content = stripContentCachePoints(content)
+
+	if len(content) == 0 {
+		return nil
+	}
+	return []types.Message{{Role: userMsg.Role, Content: content}}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/templates"
+)
+
+const testPromptARN = "arn:aws:bedrock:us-east-1:123456789012:prompt/PROMPT12345"
+
+// fakePromptManagement serves just enough of the Prompt Management API for
+// push/pull, recording the requests it receives.
+type fakePromptManagement struct {
+	prompts  map[string]*managedPrompt
+	requests []string
+	bodies   map[string]managedPrompt
+}
+
+func (f *fakePromptManagement) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"missing signature"}`))
+		return
+	}
+
+	var body managedPrompt
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	if f.bodies == nil {
+		f.bodies = map[string]managedPrompt{}
+	}
+	f.bodies[r.Method+" "+r.URL.Path] = body
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/prompts/":
+		var summaries []managedPromptSummary
+		for _, p := range f.prompts {
+			summaries = append(summaries, managedPromptSummary{Arn: p.Arn, Id: p.Id, Name: p.Name})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"promptSummaries": summaries})
+	case r.Method == http.MethodPost && r.URL.Path == "/prompts/":
+		body.Id, body.Arn = "NEWPROMPT1", "arn:aws:bedrock:us-east-1:123456789012:prompt/NEWPROMPT1"
+		_ = json.NewEncoder(w).Encode(body)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/versions"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/prompts/"), "/versions")
+		_ = json.NewEncoder(w).Encode(map[string]string{"arn": "arn:aws:bedrock:us-east-1:123456789012:prompt/" + id + ":1"})
+	default:
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/prompts/"), "/")
+		p, ok := f.prompts[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"prompt not found"}`))
+			return
+		}
+		if r.Method == http.MethodPut {
+			body.Id, body.Arn = p.Id, p.Arn
+			_ = json.NewEncoder(w).Encode(body)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(p)
+	}
+}
+
+func newTestPromptManagementClient(t *testing.T, fake *fakePromptManagement) *promptManagementClient {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return newPromptManagementClient(testAWSConfig(server))
+}
+
+func existingPrompt() *managedPrompt {
+	return &managedPrompt{
+		Arn:            testPromptARN,
+		Id:             "PROMPT12345",
+		Name:           "review",
+		Description:    "Code review",
+		DefaultVariant: "v1",
+		Variants: []promptVariant{{
+			Name:         "v1",
+			TemplateType: "TEXT",
+			ModelId:      "amazon.nova-pro-v1:0",
+			TemplateConfiguration: promptTemplateConfiguration{
+				Text: &textPromptTemplate{Text: "Review {{file}}"},
+			},
+		}},
+	}
+}
+
+func TestPushTemplate(t *testing.T) {
+	t.Run("creates a new prompt with its variables", func(t *testing.T) {
+		fake := &fakePromptManagement{prompts: map[string]*managedPrompt{}}
+		client := newTestPromptManagementClient(t, fake)
+
+		tmpl := &templates.Template{Name: "summarize", Text: "Summarize {{topic}} for {{audience}}"}
+		versionArn, err := pushTemplate(context.Background(), client, tmpl, "default-model", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if versionArn != "arn:aws:bedrock:us-east-1:123456789012:prompt/NEWPROMPT1:1" {
+			t.Errorf("unexpected version ARN %q", versionArn)
+		}
+		if tmpl.PromptARN != "arn:aws:bedrock:us-east-1:123456789012:prompt/NEWPROMPT1" || tmpl.ModelID != "default-model" {
+			t.Errorf("expected template to be linked to the new prompt, got %+v", tmpl)
+		}
+
+		sent := fake.bodies["POST /prompts/"]
+		variant, err := sent.variant()
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := variant.TemplateConfiguration.Text
+		if text == nil || text.Text != tmpl.Text || len(text.InputVariables) != 2 || text.InputVariables[1].Name != "audience" {
+			t.Errorf("unexpected prompt sent: %+v", sent)
+		}
+	})
+
+	t.Run("updates the prompt with the same name", func(t *testing.T) {
+		fake := &fakePromptManagement{prompts: map[string]*managedPrompt{"PROMPT12345": existingPrompt()}}
+		client := newTestPromptManagementClient(t, fake)
+
+		managed, err := client.listPrompts(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmpl := &templates.Template{Name: "review", Text: "Review {{file}} carefully", ModelID: "amazon.nova-lite-v1:0"}
+		if _, err := pushTemplate(context.Background(), client, tmpl, "default-model", managed); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"GET /prompts/", "PUT /prompts/PROMPT12345/", "POST /prompts/PROMPT12345/versions"}
+		if strings.Join(fake.requests, ",") != strings.Join(want, ",") {
+			t.Errorf("expected requests %v, got %v", want, fake.requests)
+		}
+		if tmpl.PromptARN != testPromptARN || tmpl.ModelID != "amazon.nova-lite-v1:0" {
+			t.Errorf("unexpected template after push: %+v", tmpl)
+		}
+	})
+}
+
+func TestPullPrompt(t *testing.T) {
+	fake := &fakePromptManagement{prompts: map[string]*managedPrompt{"PROMPT12345": existingPrompt()}}
+	client := newTestPromptManagementClient(t, fake)
+	managed := []managedPromptSummary{{Arn: testPromptARN, Id: "PROMPT12345", Name: "review"}}
+
+	for _, identifier := range []string{"review", "PROMPT12345", testPromptARN + ":3"} {
+		tmpl, err := pullPrompt(context.Background(), client, identifier, managed)
+		if err != nil {
+			t.Fatalf("%s: %v", identifier, err)
+		}
+		if tmpl.Name != "review" || tmpl.Text != "Review {{file}}" || tmpl.ModelID != "amazon.nova-pro-v1:0" || tmpl.PromptARN != testPromptARN {
+			t.Errorf("%s: unexpected template %+v", identifier, tmpl)
+		}
+	}
+
+	if _, err := pullPrompt(context.Background(), client, "missing", managed); err == nil || !strings.Contains(err.Error(), "prompt not found") {
+		t.Errorf("expected the API error to be surfaced, got %v", err)
+	}
+}
+
+func TestSaveLinkedTemplate(t *testing.T) {
+	store := templates.NewStore(t.TempDir())
+	if err := store.Save(&templates.Template{Name: "review", Text: "my own prompt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pulled := &templates.Template{Name: "review", Text: "Review {{file}}", PromptARN: testPromptARN}
+	if err := saveLinkedTemplate(store, pulled, false); err == nil {
+		t.Error("expected an unlinked local template to be protected")
+	}
+	if err := saveLinkedTemplate(store, pulled, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// once linked, later pulls update it without --force
+	pulled.Text = "Review {{file}} again"
+	if err := saveLinkedTemplate(store, pulled, false); err != nil {
+		t.Errorf("expected a linked template to update, got %v", err)
+	}
+}
+
+func TestParsePromptARN(t *testing.T) {
+	cases := []struct {
+		arn, id, version string
+		ok               bool
+	}{
+		{testPromptARN, "PROMPT12345", "", true},
+		{testPromptARN + ":2", "PROMPT12345", "2", true},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.amazon.nova-pro-v1:0", "", "", false},
+		{"PROMPT12345", "", "", false},
+	}
+	for _, c := range cases {
+		id, version, ok := parsePromptARN(c.arn)
+		if id != c.id || version != c.version || ok != c.ok {
+			t.Errorf("parsePromptARN(%q) = %q, %q, %v", c.arn, id, version, ok)
+		}
+	}
+
+	if got := unversionedPromptARN(testPromptARN + ":2"); got != testPromptARN {
+		t.Errorf("unversionedPromptARN = %q", got)
+	}
+}
+
+func TestParsePromptVariables(t *testing.T) {
+	values, err := parsePromptVariables([]string{"topic=lighthouses", "query=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := values["query"].(*types.PromptVariableValuesMemberText); !ok || v.Value != "a=b" {
+		t.Errorf("expected value to keep everything after the first '=', got %v", values["query"])
+	}
+
+	if values, err := parsePromptVariables(nil); values != nil || err != nil {
+		t.Errorf("expected no variables, got %v, %v", values, err)
+	}
+
+	for _, bad := range []string{"novalue", "=value"} {
+		if _, err := parsePromptVariables([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestManagedPromptMessages(t *testing.T) {
+	if msgs := managedPromptMessages(userTextMessage("")); msgs != nil {
+		t.Errorf("expected no messages for an empty prompt, got %v", msgs)
+	}
+
+	msgs := managedPromptMessages(types.Message{
+		Role:    types.ConversationRoleUser,
+		Content: buildQuestionContent("piped document", ""),
+	})
+	if len(msgs) != 1 || len(msgs[0].Content) != 1 || messageText(msgs[0]) != "piped document" {
+		t.Errorf("expected only the document to be kept, got %+v", msgs)
+	}
+}
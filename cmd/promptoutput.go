@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Formats prompt can print its response in.
+const (
+	promptOutputText = "text"
+	promptOutputJSON = "json"
+)
+
+// validatePromptOutput rejects values of --output other than the ones above.
+func validatePromptOutput(format string) error {
+	if format != promptOutputText && format != promptOutputJSON {
+		return fmt.Errorf("invalid --output %q: use %s or %s", format, promptOutputText, promptOutputJSON)
+	}
+	return nil
+}
+
+// promptResult is what prompt --output json prints.
+type promptResult struct {
+	Model      string `json:"model"`
+	Prompt     string `json:"prompt"`
+	Response   string `json:"response"`
+	StopReason string `json:"stop_reason,omitempty"`
+	// Usage is left out when Bedrock doesn't report it, as for
+	// --knowledge-base-id.
+	Usage     *promptUsage `json:"usage,omitempty"`
+	LatencyMs int64        `json:"latency_ms"`
+	// Error is why a model gave no response, for --compare.
+	Error string `json:"error,omitempty"`
+	// Citations is set with --require-citations.
+	Citations *promptCitations `json:"citations,omitempty"`
+}
+
+type promptUsage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens"`
+	CacheWriteTokens int `json:"cache_write_tokens"`
+}
+
+func newPromptResult(model, prompt, response string, stopReason types.StopReason, usage *tokenUsage, latency time.Duration) promptResult {
+	result := promptResult{
+		Model:      model,
+		Prompt:     prompt,
+		Response:   response,
+		StopReason: string(stopReason),
+		LatencyMs:  latency.Milliseconds(),
+	}
+	if usage != nil {
+		result.Usage = &promptUsage{
+			InputTokens:      usage.input,
+			OutputTokens:     usage.output,
+			CacheReadTokens:  usage.cacheRead,
+			CacheWriteTokens: usage.cacheWrite,
+		}
+	}
+	return result
+}
+
+// writePromptResult prints result as indented JSON.
+func writePromptResult(w io.Writer, result promptResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
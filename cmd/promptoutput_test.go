@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestValidatePromptOutput(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		if err := validatePromptOutput(format); err != nil {
+			t.Errorf("%s: unexpected error %v", format, err)
+		}
+	}
+	if err := validatePromptOutput("yaml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestWritePromptResult(t *testing.T) {
+	t.Run("with usage", func(t *testing.T) {
+		var out bytes.Buffer
+		usage := tokenUsage{input: 12, output: 34, cacheRead: 5}
+		result := newPromptResult("us.anthropic.claude-sonnet-5", "What is 2+2?", "4", types.StopReasonEndTurn, &usage, 1500*time.Millisecond)
+		if err := writePromptResult(&out, result); err != nil {
+			t.Fatal(err)
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("output isn't JSON: %v\n%s", err, out.String())
+		}
+		want := map[string]any{
+			"model":       "us.anthropic.claude-sonnet-5",
+			"prompt":      "What is 2+2?",
+			"response":    "4",
+			"stop_reason": "end_turn",
+			"latency_ms":  float64(1500),
+			"usage": map[string]any{
+				"input_tokens":       float64(12),
+				"output_tokens":      float64(34),
+				"cache_read_tokens":  float64(5),
+				"cache_write_tokens": float64(0),
+			},
+		}
+		if mustJSON(t, got) != mustJSON(t, want) {
+			t.Errorf("got %s, want %s", mustJSON(t, got), mustJSON(t, want))
+		}
+	})
+
+	t.Run("without usage", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := writePromptResult(&out, newPromptResult("m", "q", "a", "", nil, time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := got["usage"]; ok {
+			t.Errorf("expected no usage, got %v", got["usage"])
+		}
+		if _, ok := got["stop_reason"]; ok {
+			t.Errorf("expected no stop reason, got %v", got["stop_reason"])
+		}
+	})
+}
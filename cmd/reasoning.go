@@ -4,16 +4,25 @@ Copyright © 2024 Micah Walter
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/spf13/pflag"
 )
 
 const defaultThinkingEffort = "medium"
 
+// Values for the reasoning setting: whether a reply's reasoning is shown
+// as it streams, or collapsed to a line giving its length.
+const (
+	reasoningShow = "show"
+	reasoningHide = "hide"
+)
+
 var validThinkingEfforts = map[string]struct{}{
 	"low":    {},
 	"medium": {},
@@ -84,16 +93,95 @@ func buildReasoningConfig(modelID string, enabled bool, budgetTokens int32, effo
 	})
 }
 
+// reasoningFlag turns --show-reasoning and --hide-reasoning into a value
+// for the reasoning setting, or "" if neither was given.
+func reasoningFlag(flags *pflag.FlagSet) (string, error) {
+	show, err := flags.GetBool("show-reasoning")
+	if err != nil {
+		return "", err
+	}
+	hide, err := flags.GetBool("hide-reasoning")
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case show && hide:
+		return "", fmt.Errorf("--show-reasoning and --hide-reasoning can't be used together")
+	case show:
+		return reasoningShow, nil
+	case hide:
+		return reasoningHide, nil
+	}
+	return "", nil
+}
+
+// validateReasoningDisplay returns an error for anything other than show
+// or hide.
+func validateReasoningDisplay(mode string) error {
+	if mode != reasoningShow && mode != reasoningHide {
+		return fmt.Errorf("invalid reasoning setting %q: use %q or %q", mode, reasoningShow, reasoningHide)
+	}
+	return nil
+}
+
+// collapsedReasoningLine stands in for hidden reasoning.
+func collapsedReasoningLine(reasoning string) string {
+	return fmt.Sprintf("thinking hidden, ~%d tokens", estimateTokens(reasoning))
+}
+
 // printReasoningBlock prints a finalized reasoning content block's text,
 // visually distinct from the final answer (Rule 2,
-// functional-design/business-logic-model.md, unit-5-extended-thinking).
-// Redacted content (encrypted bytes) is intentionally not printed - it
-// isn't human-readable text (Rule 5).
-func printReasoningBlock(block *types.ContentBlockMemberReasoningContent) {
+// functional-design/business-logic-model.md, unit-5-extended-thinking), or
+// only its length when hide is set. Redacted content (encrypted bytes) is
+// intentionally not printed - it isn't human-readable text (Rule 5).
+func printReasoningBlock(block *types.ContentBlockMemberReasoningContent, hide bool) {
 	reasoningText, ok := block.Value.(*types.ReasoningContentBlockMemberReasoningText)
 	if !ok {
 		return
 	}
 
-	fmt.Printf("\033[90m[thinking] %s\033[0m\n\n", aws.ToString(reasoningText.Value.Text))
+	text := aws.ToString(reasoningText.Value.Text)
+	if hide {
+		fmt.Fprintf(stdout, "\033[90m[%s]\033[0m\n\n", collapsedReasoningLine(text))
+		return
+	}
+	fmt.Fprintf(stdout, "\033[90m[thinking] %s\033[0m\n\n", text)
+}
+
+// runReasoningCommand shows the last reply's reasoning, or with show or
+// hide, changes whether reasoning is shown as it streams.
+func runReasoningCommand(s *chatSession, args string) error {
+	if s.renderer == nil {
+		return errors.New("/reasoning is not available in this session")
+	}
+
+	switch strings.TrimSpace(args) {
+	case "":
+		reasoning := s.renderer.reasoning.String()
+		if reasoning == "" {
+			if !s.thinkingEnabled {
+				return errors.New("the last reply had no reasoning; start chat with --thinking to ask for it")
+			}
+			return errors.New("the last reply had no reasoning")
+		}
+		fmt.Fprintf(s.out, "\033[90m%s\033[0m\n", reasoning)
+	case reasoningShow:
+		s.renderer.hideReasoning = false
+		fmt.Fprintln(s.out, "Reasoning will be shown as it streams.")
+	case reasoningHide:
+		s.renderer.hideReasoning = true
+		fmt.Fprintln(s.out, "Reasoning will be collapsed; /reasoning shows the last reply's.")
+	default:
+		return fmt.Errorf("usage: /reasoning [show|hide]")
+	}
+	return nil
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "reasoning",
+		usage:       "[show|hide]",
+		description: "Show the last reply's reasoning, or show or hide reasoning as it streams",
+		run:         runReasoningCommand,
+	})
 }
@@ -5,7 +5,10 @@ package cmd
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/spf13/pflag"
 )
 
 func TestUsesAdaptiveThinking(t *testing.T) {
@@ -119,3 +122,65 @@ func TestBuildReasoningConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestReasoningFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "", false},
+		{[]string{"--show-reasoning"}, reasoningShow, false},
+		{[]string{"--hide-reasoning"}, reasoningHide, false},
+		{[]string{"--show-reasoning", "--hide-reasoning"}, "", true},
+	}
+	for _, tc := range tests {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Bool("show-reasoning", false, "")
+		flags.Bool("hide-reasoning", false, "")
+		if err := flags.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		got, err := reasoningFlag(flags)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("%v: got %q, %v", tc.args, got, err)
+		}
+	}
+
+	if err := validateReasoningDisplay("collapsed"); err == nil {
+		t.Error("expected an error for an unknown reasoning setting")
+	}
+}
+
+func TestRunReasoningCommand(t *testing.T) {
+	s, out := newTestChatSession()
+	s.renderer = newReplyRenderer(&strings.Builder{}, &strings.Builder{})
+
+	if _, err := dispatchSlashCommand(s, "/reasoning"); err == nil || !strings.Contains(err.Error(), "--thinking") {
+		t.Errorf("expected a hint to use --thinking, got %v", err)
+	}
+
+	if _, err := dispatchSlashCommand(s, "/reasoning hide"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.renderer.hideReasoning {
+		t.Error("expected reasoning to be hidden")
+	}
+
+	s.renderer.startReply()
+	_ = s.renderer.onReasoning(t.Context(), "91 = 7 × 13")
+	_ = s.renderer.onText(t.Context(), "No.")
+	if _, err := dispatchSlashCommand(s, "/reasoning"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "91 = 7 × 13") {
+		t.Errorf("expected the hidden reasoning to be shown, got %q", out.String())
+	}
+
+	if _, err := dispatchSlashCommand(s, "/reasoning show"); err != nil || s.renderer.hideReasoning {
+		t.Errorf("expected reasoning to be shown again, got %v", err)
+	}
+	if _, err := dispatchSlashCommand(s, "/reasoning sometimes"); err == nil {
+		t.Error("expected a usage error")
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+// replyRenderer writes a reply as it streams in. The reply's text goes to
+// out; reasoning, tool steps and the notes after a reply go to display,
+// dimmed. chat and prompt share it so both render a stream the same way,
+// and the golden transcript tests exercise it directly.
+type replyRenderer struct {
+	out     io.Writer
+	display io.Writer
+
+	// quiet drops the streamed text and reasoning, for prompt --output
+	// json, which prints the whole reply at the end.
+	quiet bool
+
+	// hideReasoning collapses each run of reasoning to one line giving its
+	// length, with reasoningHint after it, such as how to see it anyway.
+	hideReasoning bool
+	reasoningHint string
+
+	// reasoning is everything the current reply reasoned, shown or not;
+	// reasoningStart is where the run in progress began.
+	reasoning       strings.Builder
+	reasoningStart  int
+	reasoningActive bool
+}
+
+func newReplyRenderer(out, display io.Writer) *replyRenderer {
+	return &replyRenderer{out: out, display: display}
+}
+
+// startReply forgets the previous reply's reasoning.
+func (r *replyRenderer) startReply() {
+	r.reasoning.Reset()
+	r.reasoningStart = 0
+	r.reasoningActive = false
+}
+
+// onText is a utils.StreamingOutputHandler for text deltas. It closes a
+// reasoning section that's still open first.
+func (r *replyRenderer) onText(_ context.Context, part string) error {
+	if r.quiet {
+		return nil
+	}
+	r.closeReasoning("\n\n")
+	fmt.Fprint(r.out, part)
+	return nil
+}
+
+// onReasoning is a utils.StreamingOutputHandler for reasoning deltas,
+// shown under a [thinking] label, or kept back with hideReasoning.
+func (r *replyRenderer) onReasoning(_ context.Context, part string) error {
+	if r.quiet {
+		return nil
+	}
+	if !r.reasoningActive {
+		if r.reasoning.Len() > 0 {
+			r.reasoning.WriteString("\n\n")
+		}
+		r.reasoningStart = r.reasoning.Len()
+		r.reasoningActive = true
+		if !r.hideReasoning {
+			fmt.Fprint(r.display, "\033[90m[thinking] ")
+		}
+	}
+	r.reasoning.WriteString(part)
+	if !r.hideReasoning {
+		fmt.Fprint(r.display, part)
+	}
+	return nil
+}
+
+// closeReasoning ends a reasoning section that's still open, printing the
+// collapsed line for it if it was hidden, followed by end.
+func (r *replyRenderer) closeReasoning(end string) {
+	if !r.reasoningActive {
+		return
+	}
+	r.reasoningActive = false
+	if !r.hideReasoning {
+		fmt.Fprint(r.display, "\033[0m"+end)
+		return
+	}
+	line := collapsedReasoningLine(r.reasoning.String()[r.reasoningStart:])
+	if r.reasoningHint != "" {
+		line += "; " + r.reasoningHint
+	}
+	fmt.Fprintf(r.display, "\033[90m[%s]\033[0m%s", line, end)
+}
+
+// onToolCall and onToolResult show tool steps inline, for
+// chatTurnOptions.
+func (r *replyRenderer) onToolCall(call tools.ToolCall) {
+	if r.hideReasoning {
+		// the tool line starts with its own newline
+		r.closeReasoning("\n")
+	}
+	fmt.Fprintf(r.display, "\n\033[90m%s\033[0m\n", toolCallLine(call))
+}
+
+func (r *replyRenderer) onToolResult(_ tools.ToolCall, result types.ToolResultBlock) {
+	fmt.Fprintf(r.display, "\033[90m%s\033[0m\n", toolResultLine(result))
+}
+
+// note and warn add a line after a reply: dimmed, or in yellow for
+// something the user should act on. Empty lines are skipped.
+func (r *replyRenderer) note(line string) {
+	if line != "" {
+		fmt.Fprintf(r.display, "\n\n\033[90m%s\033[0m", line)
+	}
+}
+
+func (r *replyRenderer) warn(line string) {
+	if line != "" {
+		fmt.Fprintf(r.display, "\n\n\033[33m%s\033[0m", line)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// defaultRepoMapTokens is the default --repo-map-tokens budget.
+const defaultRepoMapTokens = 2048
+
+// maxRepoMapSymbolFileSize bounds which files are read for symbol
+// extraction; larger files are still listed, just without symbols.
+const maxRepoMapSymbolFileSize = 256 * 1024
+
+// maxRepoMapSymbolsPerFile caps how many symbols are listed for a single
+// file so one huge file can't consume the whole budget.
+const maxRepoMapSymbolsPerFile = 12
+
+// estimateTokens gives a rough token count for s using the common
+// ~4-characters-per-token heuristic. It's only used for budgeting context
+// chat-cli adds on its own, never for billing.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// buildRepoMap walks root (respecting .gitignore) and renders a compact
+// indented tree of directories and files, with each recognized source
+// file followed by its top-level symbols. Output stops once tokenBudget
+// would be exceeded; truncated reports whether that happened.
+func buildRepoMap(root string, tokenBudget int) (repoMap string, truncated bool, err error) {
+	ignore := utils.LoadIgnoreMatcher(root, ".gitignore")
+
+	var b strings.Builder
+	used := 0
+	omitted := 0
+
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable entries are skipped rather than failing the map
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil || rel == "." {
+			return nil
+		}
+
+		if ignore.Ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if truncated {
+			if !d.IsDir() {
+				omitted++
+			}
+			return nil
+		}
+
+		depth := strings.Count(filepath.ToSlash(rel), "/")
+		line := strings.Repeat("  ", depth) + d.Name()
+		if d.IsDir() {
+			line += "/"
+		} else if symbols := repoMapSymbols(path, d); len(symbols) > 0 {
+			line += ": " + strings.Join(symbols, ", ")
+		}
+		line += "\n"
+
+		cost := estimateTokens(line)
+		if used+cost > tokenBudget {
+			truncated = true
+			if !d.IsDir() {
+				omitted++
+			}
+			return nil
+		}
+
+		b.WriteString(line)
+		used += cost
+		return nil
+	})
+	if walkErr != nil {
+		return "", false, walkErr
+	}
+
+	if truncated {
+		fmt.Fprintf(&b, "... (%d more files omitted to stay within the repo map token budget)\n", omitted)
+	}
+
+	return b.String(), truncated, nil
+}
+
+// repoMapSymbols returns up to maxRepoMapSymbolsPerFile top-level symbols
+// for a source file, or nil for unrecognized, oversized, or unreadable
+// files.
+func repoMapSymbols(path string, d fs.DirEntry) []string {
+	if !utils.HasSymbolPatterns(path) {
+		return nil
+	}
+
+	info, err := d.Info()
+	if err != nil || info.Size() > maxRepoMapSymbolFileSize {
+		return nil
+	}
+
+	content, err := os.ReadFile(path) // #nosec G304 - path comes from walking the user's own repository
+	if err != nil {
+		return nil
+	}
+
+	symbols := utils.ExtractSymbols(path, content)
+	if len(symbols) > maxRepoMapSymbolsPerFile {
+		extra := len(symbols) - maxRepoMapSymbolsPerFile
+		symbols = append(symbols[:maxRepoMapSymbolsPerFile], fmt.Sprintf("+%d more", extra))
+	}
+
+	return symbols
+}
+
+// withRepoMap appends a repository map section to systemPrompt, so the
+// model sees the project's structure before it starts calling tools.
+func withRepoMap(systemPrompt, repoMap string) string {
+	section := "The following is a map of the current repository's directories, files, and top-level symbols:\n\n<repository_map>\n" +
+		repoMap + "</repository_map>"
+
+	if systemPrompt == "" {
+		return section
+	}
+
+	return systemPrompt + "\n\n" + section
+}
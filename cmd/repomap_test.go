@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRepoMapFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		full := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildRepoMap(t *testing.T) {
+	root := t.TempDir()
+	writeRepoMapFixture(t, root, map[string]string{
+		".gitignore":              "node_modules/\n*.log\n",
+		"main.go":                 "package main\n\nfunc main() {}\n",
+		"cmd/server.go":           "package cmd\n\ntype Server struct{}\n\nfunc (s *Server) Run() {}\n",
+		"node_modules/pkg/x.js":   "function hidden() {}\n",
+		"debug.log":               "noise\n",
+		"README.md":               "# readme\n",
+		".git/HEAD":               "ref: refs/heads/main\n",
+		"scripts/build/helper.py": "def helper():\n    pass\n",
+	})
+
+	repoMap, truncated, err := buildRepoMap(root, 10000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if truncated {
+		t.Error("expected map to fit within a generous budget")
+	}
+
+	for _, want := range []string{
+		"main.go: main\n",
+		"cmd/\n",
+		"  server.go: Server, Server.Run\n",
+		"README.md\n",
+		"    helper.py: helper\n",
+	} {
+		if !strings.Contains(repoMap, want) {
+			t.Errorf("expected repo map to contain %q, got:\n%s", want, repoMap)
+		}
+	}
+
+	for _, unwanted := range []string{"node_modules", "debug.log", "HEAD", "hidden"} {
+		if strings.Contains(repoMap, unwanted) {
+			t.Errorf("expected repo map to exclude %q, got:\n%s", unwanted, repoMap)
+		}
+	}
+}
+
+func TestBuildRepoMap_TruncatesToBudget(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go"} {
+		files[name] = "package x\n\nfunc " + strings.TrimSuffix(name, ".go") + "Function() {}\n"
+	}
+	writeRepoMapFixture(t, root, files)
+
+	repoMap, truncated, err := buildRepoMap(root, 12)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !truncated {
+		t.Fatal("expected a tiny budget to truncate the map")
+	}
+	if !strings.Contains(repoMap, "more files omitted") {
+		t.Errorf("expected a truncation marker, got:\n%s", repoMap)
+	}
+	if strings.Contains(repoMap, "f.go") {
+		t.Errorf("expected later files to be omitted, got:\n%s", repoMap)
+	}
+}
+
+func TestWithRepoMap(t *testing.T) {
+	t.Run("no existing system prompt", func(t *testing.T) {
+		got := withRepoMap("", "main.go\n")
+		if !strings.HasPrefix(got, "The following is a map") {
+			t.Errorf("expected the map section alone, got %q", got)
+		}
+		if !strings.Contains(got, "<repository_map>\nmain.go\n</repository_map>") {
+			t.Errorf("expected map wrapped in tags, got %q", got)
+		}
+	})
+
+	t.Run("appends to an existing system prompt", func(t *testing.T) {
+		got := withRepoMap("Be terse.", "main.go\n")
+		if !strings.HasPrefix(got, "Be terse.\n\n") {
+			t.Errorf("expected existing prompt to be preserved first, got %q", got)
+		}
+	})
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := estimateTokens(""); got != 0 {
+		t.Errorf("expected 0 tokens for empty string, got %d", got)
+	}
+	if got := estimateTokens("abcd"); got != 1 {
+		t.Errorf("expected 1 token for 4 chars, got %d", got)
+	}
+	if got := estimateTokens("abcde"); got != 2 {
+		t.Errorf("expected partial tokens to round up, got %d", got)
+	}
+}
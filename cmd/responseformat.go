@@ -0,0 +1,210 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// responseFormat is a shape prompt --format asks the model to answer in,
+// and checks the answer has.
+type responseFormat string
+
+const (
+	formatBullet   responseFormat = "bullet"
+	formatTable    responseFormat = "table"
+	formatCodeOnly responseFormat = "code-only"
+)
+
+// formatRetries is how many times prompt --format asks again for a
+// response that isn't in the format.
+const formatRetries = 1
+
+// parseResponseFormat reads a --format value; "" means no format.
+func parseResponseFormat(value string) (responseFormat, error) {
+	switch f := responseFormat(strings.ToLower(strings.TrimSpace(value))); f {
+	case "", formatBullet, formatTable, formatCodeOnly:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid --format %q: use bullet, table, or code-only", value)
+	}
+}
+
+// instruction is added to the system prompt to ask for the format.
+func (f responseFormat) instruction() string {
+	switch f {
+	case formatBullet:
+		return "Format your response as a Markdown bullet list. Every line must be a bullet point starting with \"- \", " +
+			"with no introduction, headings, or closing remarks. Indent a line to continue or nest under the bullet above it."
+	case formatTable:
+		return "Format your response as a single Markdown table: a header row, a separator row, and a row for each item, " +
+			"each starting and ending with |. Write nothing before or after the table."
+	case formatCodeOnly:
+		return "Respond with only code, in a single fenced Markdown code block, with no explanation before or after it."
+	}
+	return ""
+}
+
+// check returns the part of text to print and what keeps it from being in
+// the format: the list for bullet, the table without anything around it
+// for table, and the code without its fence for code-only.
+func (f responseFormat) check(text string) (string, []string) {
+	switch f {
+	case formatBullet:
+		return checkBulletList(text)
+	case formatTable:
+		return checkTable(text)
+	case formatCodeOnly:
+		return checkCodeOnly(text)
+	}
+	return text, nil
+}
+
+// response sends input and checks the response is in the format, asking
+// again up to formatRetries times if it isn't. It's structuredResponse for
+// formats.
+func (f responseFormat) response(
+	ctx context.Context,
+	converse func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error),
+	input *bedrockruntime.ConverseInput,
+	onRetry func(attempt int, problems []string),
+) (string, *bedrockruntime.ConverseOutput, tokenUsage, error) {
+	fix := map[responseFormat]string{
+		formatBullet:   "Reply again with only the bullet list.",
+		formatTable:    "Reply again with only the table.",
+		formatCodeOnly: "Reply again with only the code block.",
+	}[f]
+	return checkedResponse(ctx, converse, input, formatRetries, fmt.Sprintf("the %s format", f), fix, f.check, onRetry)
+}
+
+// bulletMarkers start a line of a bullet list.
+var bulletMarkers = []string{"- ", "* ", "+ ", "• "}
+
+func checkBulletList(text string) (string, []string) {
+	text = strings.TrimSpace(text)
+	var problems []string
+	bullets := 0
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case hasBulletMarker(trimmed):
+			bullets++
+		case bullets > 0 && line != strings.TrimLeft(line, " \t"):
+			// an indented line continues the bullet above it
+		default:
+			problems = append(problems, fmt.Sprintf("line %d isn't a bullet point: %q", i+1, truncateRunes(trimmed, 60)))
+		}
+	}
+	if bullets == 0 && len(problems) == 0 {
+		problems = append(problems, "the response has no bullet points")
+	}
+	return text, problems
+}
+
+func hasBulletMarker(line string) bool {
+	for _, marker := range bulletMarkers {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableSeparator matches a Markdown table's separator row, such as
+// |---|:---:|.
+var tableSeparator = regexp.MustCompile(`^\|?(\s*:?-+:?\s*\|)+\s*(:?-+:?\s*)?$`)
+
+func checkTable(text string) (string, []string) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	// tables are runs of lines starting with |
+	var tables [][]string
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), "|") {
+			continue
+		}
+		start := i
+		for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|") {
+			i++
+		}
+		tables = append(tables, lines[start:i])
+	}
+
+	switch {
+	case len(tables) == 0:
+		return strings.TrimSpace(text), []string{"the response has no Markdown table"}
+	case len(tables) > 1:
+		return strings.TrimSpace(text), []string{fmt.Sprintf("the response has %d tables; expected one", len(tables))}
+	}
+
+	table := tables[0]
+	for i := range table {
+		table[i] = strings.TrimSpace(table[i])
+	}
+	kept := strings.Join(table, "\n")
+	if len(table) < 2 || !tableSeparator.MatchString(table[1]) {
+		return kept, []string{"the table has no separator row (like |---|---|) under its header"}
+	}
+	if len(table) < 3 {
+		return kept, []string{"the table has a header but no rows"}
+	}
+
+	var problems []string
+	columns := len(tableCells(table[0]))
+	for i, row := range table[2:] {
+		if n := len(tableCells(row)); n != columns {
+			problems = append(problems, fmt.Sprintf("row %d has %d columns, but the header has %d", i+1, n, columns))
+		}
+	}
+	return kept, problems
+}
+
+// tableCells splits a table row into its cells, leaving escaped pipes in
+// the cells they're in.
+func tableCells(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(row), "|"), "|")
+	row = strings.ReplaceAll(row, `\|`, "\x00")
+	cells := strings.Split(row, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(strings.ReplaceAll(cell, "\x00", `\|`))
+	}
+	return cells
+}
+
+func checkCodeOnly(text string) (string, []string) {
+	text = strings.TrimSpace(text)
+
+	var blocks []string
+	var block []string
+	inBlock := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inBlock {
+				blocks = append(blocks, strings.Join(block, "\n"))
+				block = nil
+			}
+			inBlock = !inBlock
+			continue
+		}
+		if inBlock {
+			block = append(block, line)
+		}
+	}
+
+	switch {
+	case inBlock:
+		return text, []string{"the code block isn't closed with ```"}
+	case len(blocks) == 0:
+		return text, []string{"the response has no fenced code block"}
+	case len(blocks) > 1:
+		return text, []string{fmt.Sprintf("the response has %d code blocks; expected one", len(blocks))}
+	}
+	return blocks[0], nil
+}
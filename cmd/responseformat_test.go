@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestParseResponseFormat(t *testing.T) {
+	for _, value := range []string{"", "bullet", "Table", "code-only"} {
+		if _, err := parseResponseFormat(value); err != nil {
+			t.Errorf("parseResponseFormat(%q): %v", value, err)
+		}
+	}
+	if _, err := parseResponseFormat("haiku"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestResponseFormatCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   responseFormat
+		text     string
+		kept     string
+		problems string
+	}{
+		{"bullets", formatBullet, "- one\n  continued\n* two\n", "- one\n  continued\n* two", ""},
+		{"bullets with an introduction", formatBullet, "Here you go:\n- one", "", `line 1 isn't a bullet point: "Here you go:"`},
+		{"no bullets", formatBullet, "", "", "no bullet points"},
+		{"table with text around it", formatTable, "Sure:\n\n| a | b |\n|---|:-:|\n| 1 | x \\| y |\n\nHope that helps.", "| a | b |\n|---|:-:|\n| 1 | x \\| y |", ""},
+		{"no table", formatTable, "a, b\n1, 2", "", "no Markdown table"},
+		{"no separator", formatTable, "| a | b |\n| 1 | 2 |", "", "no separator row"},
+		{"no rows", formatTable, "| a | b |\n|---|---|", "", "no rows"},
+		{"ragged table", formatTable, "| a | b |\n|---|---|\n| 1 |", "", "row 1 has 1 columns, but the header has 2"},
+		{"two tables", formatTable, "| a |\n|---|\n| 1 |\n\n| b |\n|---|\n| 2 |", "", "2 tables"},
+		{"code", formatCodeOnly, "Here it is:\n```go\nfmt.Println(1)\n```", "fmt.Println(1)", ""},
+		{"no code block", formatCodeOnly, "fmt.Println(1)", "", "no fenced code block"},
+		{"unclosed code block", formatCodeOnly, "```\nx", "", "isn't closed"},
+		{"two code blocks", formatCodeOnly, "```\nx\n```\n```\ny\n```", "", "2 code blocks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, problems := tt.format.check(tt.text)
+			if tt.problems == "" {
+				if len(problems) != 0 || kept != tt.kept {
+					t.Errorf("expected %q with no problems, got %q and %q", tt.kept, kept, problems)
+				}
+				return
+			}
+			if len(problems) == 0 || !strings.Contains(problems[0], tt.problems) {
+				t.Errorf("expected a problem containing %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
+
+func TestResponseFormatAsksAgainOnce(t *testing.T) {
+	replies := []string{"Two colors:\n- red\n- blue", "Still not a list."}
+	var sent int
+	converse := func(_ context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+		sent++
+		return &bedrockruntime.ConverseOutput{
+			Output: &types.ConverseOutputMemberMessage{Value: textMessage(types.ConversationRoleAssistant, replies[sent-1])},
+		}, nil
+	}
+
+	input := &bedrockruntime.ConverseInput{Messages: []types.Message{userTextMessage("name two colors")}}
+	retries := 0
+	_, _, _, err := formatBullet.response(t.Context(), converse, input, func(int, []string) { retries++ })
+	if err == nil || !strings.Contains(err.Error(), "the bullet format after 2 attempts") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if sent != 2 || retries != 1 {
+		t.Errorf("expected one retry, got %d requests and %d retries", sent, retries)
+	}
+	if len(input.Messages) != 3 || !strings.Contains(messageText(input.Messages[2]), "Reply again with only the bullet list.") {
+		t.Errorf("unexpected retry conversation %#v", input.Messages)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+const (
+	// retryAttemptsKey is the config setting for how many times chat and
+	// prompt try a request again when Bedrock turns it away for now.
+	retryAttemptsKey = "error.retry_attempts"
+	// defaultRetryAttempts is used when retryAttemptsKey isn't set.
+	defaultRetryAttempts = 3
+	// maxRetryAttempts bounds retryAttemptsKey, since the backoff between
+	// attempts grows to 20 seconds.
+	maxRetryAttempts = 10
+)
+
+// spinnerFrames are drawn in turn while a retry waits.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const spinnerInterval = 100 * time.Millisecond
+
+// isRetryableError reports whether err is Bedrock turning a request away
+// for now rather than for good: throttling, or the service or model being
+// briefly unavailable. The same request may well succeed shortly.
+func isRetryableError(err error) bool {
+	if isThrottlingError(err) {
+		return true
+	}
+
+	var unavailable *types.ServiceUnavailableException
+	var internal *types.InternalServerException
+	var notReady *types.ModelNotReadyException
+	if errors.As(err, &unavailable) || errors.As(err, &internal) || errors.As(err, &notReady) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ServiceUnavailableException", "InternalServerException", "ModelNotReadyException":
+			return true
+		}
+	}
+	return false
+}
+
+// retryReason describes a retryable error for the retry spinner.
+func retryReason(err error) string {
+	if isThrottlingError(err) {
+		return "Bedrock is throttling requests"
+	}
+	return "Bedrock is unavailable"
+}
+
+// bedrockRetrier tries requests again when Bedrock turns them away with a
+// retryable error, waiting longer before each attempt (see throttleDelay)
+// and showing how long on out. A nil bedrockRetrier sends each request
+// once.
+type bedrockRetrier struct {
+	// attempts is how many times a request is tried again after the
+	// first, so it's sent at most attempts+1 times
+	attempts int
+	out      io.Writer
+	// animate draws a spinner that counts down each wait; otherwise each
+	// wait is one line, for output that isn't a terminal
+	animate bool
+}
+
+// newBedrockRetrier returns a retrier that honors error.retry_attempts and
+// reports on stderr.
+func newBedrockRetrier(fm *conf.FileManager) *bedrockRetrier {
+	return &bedrockRetrier{
+		attempts: configuredRetryAttempts(fm),
+		out:      stderr,
+		animate:  utils.ColorEnabled(os.Stderr),
+	}
+}
+
+// clientOptions turns off the SDK's own retries for a Bedrock client whose
+// requests go through r. Otherwise each of r's attempts is itself tried up
+// to three times, multiplying the requests sent behind r's attempt count.
+func (r *bedrockRetrier) clientOptions(o *bedrockruntime.Options) {
+	if r != nil {
+		o.RetryMaxAttempts = 1
+	}
+}
+
+// configuredRetryAttempts reads error.retry_attempts, falling back to
+// defaultRetryAttempts when it isn't a whole number from 0 to
+// maxRetryAttempts.
+func configuredRetryAttempts(fm *conf.FileManager) int {
+	value := fm.GetConfigValue(retryAttemptsKey, "", strconv.Itoa(defaultRetryAttempts))
+	n, err := strconv.Atoi(strings.TrimSpace(fmt.Sprint(value)))
+	if err != nil || n < 0 || n > maxRetryAttempts {
+		log.Printf("ignoring %s %q: use a whole number from 0 to %d", retryAttemptsKey, fmt.Sprint(value), maxRetryAttempts)
+		return defaultRetryAttempts
+	}
+	return n
+}
+
+// do calls call until it succeeds, fails with an error that isn't
+// retryable, or has been retried r.attempts times, returning its last
+// error. Cancelling ctx during a wait returns ctx's error.
+func (r *bedrockRetrier) do(ctx context.Context, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if r == nil || err == nil || !isRetryableError(err) || attempt > r.attempts || ctx.Err() != nil {
+			return err
+		}
+		if waitErr := r.wait(ctx, attempt, retryDelay(attempt-1), err); waitErr != nil {
+			return waitErr
+		}
+	}
+}
+
+// wait pauses for delay before retry attempt, showing why.
+func (r *bedrockRetrier) wait(ctx context.Context, attempt int, delay time.Duration, err error) error {
+	status := func(left time.Duration) string {
+		return fmt.Sprintf("%s; retrying in %.1fs (attempt %d of %d)", retryReason(err), left.Seconds(), attempt, r.attempts)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	if !r.animate {
+		fmt.Fprintf(r.out, "\033[33m%s\033[0m\n", status(delay))
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(delay)
+	// the spinner's line is cleared however the wait ends, so the reply
+	// starts on a clean line
+	defer fmt.Fprint(r.out, "\r\033[K")
+
+	for frame := 0; ; frame++ {
+		fmt.Fprintf(r.out, "\r\033[K\033[33m%s %s\033[0m", spinnerFrames[frame%len(spinnerFrames)], status(max(time.Until(deadline), 0)))
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// wrap returns send with retryable errors retried. Only the request is
+// retried; a stream that breaks off after it starts is resumed by
+// streamResponse instead.
+func (r *bedrockRetrier) wrap(send converseStreamFunc) converseStreamFunc {
+	if r == nil {
+		return send
+	}
+	return func(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		var events <-chan types.ConverseStreamOutput
+		err := r.do(ctx, func() error {
+			var sendErr error
+			events, sendErr = send(ctx, input)
+			return sendErr
+		})
+		return events, err
+	}
+}
+
+// converse returns converse with retryable errors retried.
+func (r *bedrockRetrier) converse(converse func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error)) func(context.Context, *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+	if r == nil {
+		return converse
+	}
+	return func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+		var output *bedrockruntime.ConverseOutput
+		err := r.do(ctx, func() error {
+			var converseErr error
+			output, converseErr = converse(ctx, input)
+			return converseErr
+		})
+		return output, err
+	}
+}
+
+// withoutSpinner returns a copy of r that reports each wait on its own
+// line, for requests sent side by side whose spinners would overwrite
+// each other.
+func (r *bedrockRetrier) withoutSpinner() *bedrockRetrier {
+	if r == nil {
+		return nil
+	}
+	plain := *r
+	plain.animate = false
+	return &plain
+}
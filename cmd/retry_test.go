@@ -0,0 +1,238 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"throttling", &types.ThrottlingException{Message: aws.String("slow down")}, true},
+		{"service unavailable", &types.ServiceUnavailableException{}, true},
+		{"internal server error", &types.InternalServerException{}, true},
+		{"model not ready", &types.ModelNotReadyException{}, true},
+		{"generic API error code", &smithy.GenericAPIError{Code: "ServiceUnavailableException"}, true},
+		{"validation", &types.ValidationException{}, false},
+		{"access denied", &types.AccessDeniedException{}, false},
+		{"other", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.err); got != tt.want {
+				t.Errorf("isRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// fastRetries makes retry waits instant for the rest of the test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	saved := retryDelay
+	retryDelay = func(int) time.Duration { return time.Millisecond }
+	t.Cleanup(func() { retryDelay = saved })
+}
+
+func TestBedrockRetrier_Do(t *testing.T) {
+	fastRetries(t)
+	throttled := &types.ThrottlingException{Message: aws.String("slow down")}
+
+	t.Run("retries until the call succeeds", func(t *testing.T) {
+		var out bytes.Buffer
+		r := &bedrockRetrier{attempts: 3, out: &out}
+		calls := 0
+		err := r.do(context.Background(), func() error {
+			calls++
+			if calls < 3 {
+				return throttled
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Fatalf("expected success on the third call, got %v after %d calls", err, calls)
+		}
+		if got := strings.Count(out.String(), "Bedrock is throttling requests; retrying in"); got != 2 {
+			t.Errorf("expected a line for each of 2 waits, got:\n%s", out.String())
+		}
+		if !strings.Contains(out.String(), "(attempt 2 of 3)") {
+			t.Errorf("expected the attempt count, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("gives up after the configured attempts", func(t *testing.T) {
+		r := &bedrockRetrier{attempts: 2, out: &bytes.Buffer{}}
+		calls := 0
+		err := r.do(context.Background(), func() error {
+			calls++
+			return throttled
+		})
+		if !errors.Is(err, throttled) || calls != 3 {
+			t.Errorf("expected the throttling error after 3 calls, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("other errors aren't retried", func(t *testing.T) {
+		r := &bedrockRetrier{attempts: 3, out: &bytes.Buffer{}}
+		calls := 0
+		denied := &types.AccessDeniedException{}
+		err := r.do(context.Background(), func() error {
+			calls++
+			return denied
+		})
+		if !errors.Is(err, denied) || calls != 1 {
+			t.Errorf("expected one call, got %d (%v)", calls, err)
+		}
+	})
+
+	t.Run("zero attempts sends once", func(t *testing.T) {
+		r := &bedrockRetrier{attempts: 0, out: &bytes.Buffer{}}
+		calls := 0
+		_ = r.do(context.Background(), func() error {
+			calls++
+			return throttled
+		})
+		if calls != 1 {
+			t.Errorf("expected one call, got %d", calls)
+		}
+	})
+
+	t.Run("nil retrier sends once", func(t *testing.T) {
+		var r *bedrockRetrier
+		calls := 0
+		_ = r.do(context.Background(), func() error {
+			calls++
+			return throttled
+		})
+		if calls != 1 {
+			t.Errorf("expected one call, got %d", calls)
+		}
+	})
+
+	t.Run("cancelling during a wait stops it", func(t *testing.T) {
+		retryDelay = func(int) time.Duration { return time.Hour }
+		defer func() { retryDelay = func(int) time.Duration { return time.Millisecond } }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		r := &bedrockRetrier{attempts: 3, out: &bytes.Buffer{}, animate: true}
+		err := r.do(ctx, func() error {
+			return throttled
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the context's error, got %v", err)
+		}
+	})
+}
+
+func TestBedrockRetrier_SpinnerClearsItsLine(t *testing.T) {
+	var out bytes.Buffer
+	r := &bedrockRetrier{attempts: 1, out: &out, animate: true}
+	if err := r.wait(context.Background(), 1, 250*time.Millisecond, &types.ServiceUnavailableException{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Bedrock is unavailable; retrying in") {
+		t.Errorf("expected the spinner's status, got %q", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "\r\033[K") {
+		t.Errorf("expected the spinner's line to be cleared, got %q", out.String())
+	}
+}
+
+func TestBedrockRetrier_Wrap(t *testing.T) {
+	fastRetries(t)
+
+	calls := 0
+	send := func(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		calls++
+		if calls == 1 {
+			return nil, &types.ThrottlingException{}
+		}
+		return textOnlyChannel("hello"), nil
+	}
+	r := &bedrockRetrier{attempts: 1, out: &bytes.Buffer{}}
+
+	events, err := r.wrap(send)(context.Background(), &bedrockruntime.ConverseStreamInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events == nil || calls != 2 {
+		t.Errorf("expected the retried request's stream, got %v after %d calls", events, calls)
+	}
+}
+
+func TestBedrockRetrier_ClientOptions(t *testing.T) {
+	fastRetries(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("X-Amzn-Errortype", "ThrottlingException")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"slow down"}`))
+	}))
+	defer server.Close()
+
+	// the SDK's default of three attempts, as a loaded config has
+	cfg := testAWSConfig(server)
+	cfg.RetryMaxAttempts = 0
+
+	r := &bedrockRetrier{attempts: 2, out: &bytes.Buffer{}}
+	svc := bedrockruntime.NewFromConfig(cfg, r.clientOptions)
+	converse := func(ctx context.Context, in *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error) {
+		return svc.Converse(ctx, in)
+	}
+	_, err := r.converse(converse)(t.Context(), &bedrockruntime.ConverseInput{ModelId: aws.String("model")})
+	if !isThrottlingError(err) {
+		t.Fatalf("expected the throttling error, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected one request per attempt (3), got %d", requests)
+	}
+}
+
+func TestConfiguredRetryAttempts(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	fm := &conf.FileManager{}
+
+	if got := configuredRetryAttempts(fm); got != defaultRetryAttempts {
+		t.Errorf("expected the default, got %d", got)
+	}
+
+	viper.Set("error.retry_attempts", 5)
+	if got := configuredRetryAttempts(fm); got != 5 {
+		t.Errorf("expected 5 from the config file, got %d", got)
+	}
+
+	t.Setenv("CHAT_CLI_ERROR_RETRY_ATTEMPTS", "0")
+	if got := configuredRetryAttempts(fm); got != 0 {
+		t.Errorf("expected 0 from the environment, got %d", got)
+	}
+
+	t.Setenv("CHAT_CLI_ERROR_RETRY_ATTEMPTS", "lots")
+	if got := configuredRetryAttempts(fm); got != defaultRetryAttempts {
+		t.Errorf("expected the default for a bad value, got %d", got)
+	}
+}
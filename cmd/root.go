@@ -4,9 +4,15 @@ Copyright © 2024 Micah Walter
 package cmd
 
 import (
+	"context"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -27,6 +33,50 @@ Use 'chat-cli [command]' to access other features like prompts, image generation
 
 To quit a chat session, type "quit" or "/quit"
 	`,
+	Example: `  chat-cli
+  chat-cli --model-id amazon.nova-pro-v1:0 --system "You are a terse assistant."
+  chat-cli --chat-name refactor-auth
+  chat-cli --enable-tools --repo-map`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setupOutput()
+
+		strict, err := cmd.Flags().GetBool("strict-config")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		conf.StrictValidation = strict
+
+		profile, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		conf.ProfileOverride = profile
+
+		if awsProfileFlag, err = cmd.Flags().GetString("aws-profile"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if offlineFlag, err = cmd.Flags().GetBool("offline"); err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		fault, err := cmd.Flags().GetString("inject-fault")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		if injectedFaults, err = parseFault(fault); err != nil {
+			log.Fatal(err)
+		}
+		if !isPersonaCommand(cmd) {
+			if err := applyPersona(cmd.Flags()); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if !isProfileCommand(cmd) {
+			if err := applyActiveProfile(cmd.Flags()); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// When root command is called without subcommands, run the chat command
 		chatCmd.Run(cmd, args)
@@ -35,8 +85,36 @@ To quit a chat session, type "quit" or "/quit"
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Commands get a context from cmd.Context() that's canceled on Ctrl+C or
+// SIGTERM, so Bedrock calls, database queries and tools stop and the command
+// can clean up. A second Ctrl+C exits straight away. While chat is streaming
+// a reply, Ctrl+C cancels just that reply (see turnInterrupts).
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			if sig == os.Interrupt && interrupts.interrupt() {
+				continue
+			}
+			cancel()
+			signal.Stop(signals)
+			return
+		}
+	}()
+
+	if cmd, ok := examplesCommand(rootCmd, os.Args[1:]); ok {
+		if err := printExamples(os.Stdout, cmd); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -44,17 +122,55 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringP("region", "r", "us-east-1", "set the AWS region")
+	rootCmd.PersistentFlags().String("aws-profile", "", "use this profile from your AWS config and credentials files (overrides AWS_PROFILE)")
+	rootCmd.PersistentFlags().Bool("offline", false, "make no AWS calls, limiting chat-cli to local features such as chat history, export, and config")
 
 	// Add chat-specific flags to root command so they work when running chat-cli directly
 	rootCmd.PersistentFlags().StringP("model-id", "m", DefaultModelID, "set the model id or inference profile id")
 	rootCmd.PersistentFlags().String("custom-arn", "", "pass a custom arn from bedrock marketplace or cross-region inference")
+	rootCmd.PersistentFlags().String("inference-profile", "", "use a cross-region or application inference profile ID or ARN as the model (overrides --model-id and --custom-arn)")
+	rootCmd.PersistentFlags().Bool("skip-validation", false, "send the model ID to Bedrock as is, without checking it with GetFoundationModel first")
 	rootCmd.PersistentFlags().String("chat-id", "", "pass a valid chat-id to load a previous conversation")
+	rootCmd.PersistentFlags().String("chat-name", "", "start or resume the chat with this name in the current repository or directory")
 	rootCmd.PersistentFlags().String("system", "", "set a system prompt")
+	rootCmd.PersistentFlags().String("guardrail-id", "", "ID or ARN of a Bedrock guardrail to apply to every message and response")
+	rootCmd.PersistentFlags().String("guardrail-version", "", "version of the guardrail (default DRAFT)")
 	rootCmd.PersistentFlags().Bool("no-context-file", false, "disable automatic project-context file discovery (AGENTS.md/CLAUDE.md/etc., chat only)")
+	rootCmd.PersistentFlags().Bool("repo-map", false, "include a compact file tree and symbol map of the current repository as context (chat only)")
+	rootCmd.PersistentFlags().Int("repo-map-tokens", defaultRepoMapTokens, "approximate token budget for --repo-map")
+	rootCmd.PersistentFlags().StringArray("file", nil, "extract the text of a pdf, docx, html, txt, md, or csv file into the conversation (repeatable, chat only)")
+	rootCmd.PersistentFlags().String("context-dir", "", "include the text files in a directory (respecting .gitignore) as context (chat only)")
+	rootCmd.PersistentFlags().Int("context-dir-tokens", defaultContextDirTokens, "approximate token budget for --context-dir")
+	rootCmd.PersistentFlags().StringArray("context-include", nil, "only include --context-dir files matching this glob, e.g. \"*.go\" (repeatable)")
+	rootCmd.PersistentFlags().String("kb", "", "send the most relevant chunks of a local knowledge base with each message (see chat-cli kb, chat only)")
+	rootCmd.PersistentFlags().Int("kb-top-k", defaultKBTopK, "how many chunks --kb sends with each message")
+	rootCmd.PersistentFlags().Bool("require-citations", false, "have replies cite the --kb chunks they use, flag those that cite none, and list the sources after each (chat only)")
+	rootCmd.PersistentFlags().Bool("kb-rerank", false, "have a Bedrock reranking model choose the chunks --kb sends from a wider set (see kb.rerank)")
+	rootCmd.PersistentFlags().Bool("enable-tools", false, "let the model read, list, and (with confirmation) write files and run commands in the working directory (chat only)")
+	rootCmd.PersistentFlags().String("agent-prompt", "", "replace the instructions /agent runs are given; {{cwd}}, {{os}}, {{arch}}, {{shell}}, {{date}}, and {{model}} are filled in (chat only)")
+	rootCmd.PersistentFlags().String("agent-prompt-append", "", "add to the instructions /agent runs are given, e.g. project conventions; takes the same variables as --agent-prompt (chat only)")
+	rootCmd.PersistentFlags().String("worker-model-id", "", "cheaper model for follow-up tool-use steps; the main model still plans and writes the final answer (chat only)")
+	rootCmd.PersistentFlags().Bool("verify", false, "after a turn that writes files, re-read them and run --verify-command to check the result (chat only)")
+	rootCmd.PersistentFlags().String("verify-command", "", "command to run when verifying file changes, e.g. \"go build ./...\" (requires --verify)")
 	rootCmd.PersistentFlags().Bool("thinking", false, "enable extended thinking / reasoning mode")
 	rootCmd.PersistentFlags().Int32("thinking-budget", 1024, "token budget for extended thinking on legacy models (requires --thinking)")
 	rootCmd.PersistentFlags().String("thinking-effort", defaultThinkingEffort, "reasoning effort for adaptive models: low, medium, or high (requires --thinking)")
+	rootCmd.PersistentFlags().Bool("show-reasoning", false, "show the model's reasoning as it streams (the default; overrides reasoning: hide in config)")
+	rootCmd.PersistentFlags().Bool("hide-reasoning", false, "collapse the model's reasoning to a line giving its length; /reasoning shows it (chat only)")
 	rootCmd.PersistentFlags().Float32("temperature", 1.0, "optional temperature (0-1); omitted from the request unless set")
 	rootCmd.PersistentFlags().Float32("topP", 0.999, "optional top-P (0-1); omitted from the request unless set")
 	rootCmd.PersistentFlags().Int32("max-tokens", 4096, "max tokens")
+	rootCmd.PersistentFlags().String("pager", "", "open responses taller than the terminal in $PAGER once complete: auto or off (chat only)")
+	rootCmd.PersistentFlags().String("injection-check", "", "what to do with --file passages that look like prompt injection: warn (default), strip, or off (chat only)")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "print the assembled request (system prompt, history, parameters, tools) and an estimated token count, then exit without calling Bedrock (chat only)")
+	rootCmd.PersistentFlags().Bool("verbose", false, "show extra detail about each reply, such as why the model stopped (chat only)")
+	rootCmd.PersistentFlags().Bool("speak-stream", false, "read replies aloud with Amazon Polly as they stream in (experimental, chat only)")
+	rootCmd.PersistentFlags().Bool("plain", false, "use the plain prompt and terminal output instead of the full-screen chat view (chat only)")
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "read one message per line from stdin and write only the replies to stdout, for scripting (chat only)")
+	rootCmd.PersistentFlags().String("profile", "", "use the named configuration profile (overrides CHAT_CLI_PROFILE and config profile use)")
+	rootCmd.PersistentFlags().Bool("strict-config", false, "fail on unknown keys or wrongly typed values in config.yaml instead of warning")
+
+	// for developers: simulate Bedrock failures in chat and prompt
+	rootCmd.PersistentFlags().String("inject-fault", "", "inject a fault into about half of Bedrock's responses: throttle, disconnect, or slow")
+	_ = rootCmd.PersistentFlags().MarkHidden("inject-fault")
 }
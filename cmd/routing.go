@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// routingKey is the config.yaml key holding the routing rules.
+const routingKey = "routing"
+
+// routingRule sends prompts with certain characteristics to a model of
+// their own. Every condition a rule sets must hold for it to match.
+type routingRule struct {
+	modelID      string
+	minTokens    int
+	maxTokens    int
+	containsCode bool
+	match        *regexp.Regexp
+}
+
+// codeFence finds the start of a fenced Markdown code block.
+var codeFence = regexp.MustCompile("(?m)^\\s*(```|~~~)")
+
+// matches reports whether the rule applies to a prompt of text.
+func (r routingRule) matches(text string) bool {
+	tokens := estimateTokens(text)
+	switch {
+	case r.minTokens > 0 && tokens < r.minTokens:
+		return false
+	case r.maxTokens > 0 && tokens > r.maxTokens:
+		return false
+	case r.containsCode && !codeFence.MatchString(text):
+		return false
+	case r.match != nil && !r.match.MatchString(text):
+		return false
+	}
+	return true
+}
+
+// describe lists the rule's conditions, for --verbose.
+func (r routingRule) describe() string {
+	var conditions []string
+	if r.minTokens > 0 {
+		conditions = append(conditions, fmt.Sprintf("at least %d tokens", r.minTokens))
+	}
+	if r.maxTokens > 0 {
+		conditions = append(conditions, fmt.Sprintf("at most %d tokens", r.maxTokens))
+	}
+	if r.containsCode {
+		conditions = append(conditions, "contains code")
+	}
+	if r.match != nil {
+		conditions = append(conditions, fmt.Sprintf("matches %q", r.match.String()))
+	}
+	return strings.Join(conditions, ", ")
+}
+
+// loadRoutingRules reads the routing rules from config.yaml, in order. It
+// returns nil if there are none.
+func loadRoutingRules() ([]routingRule, error) {
+	if !viper.IsSet(routingKey) {
+		return nil, nil
+	}
+	return parseRoutingRules(viper.Get(routingKey))
+}
+
+// parseRoutingRules converts the YAML-decoded list of rules, rejecting
+// unknown keys and rules that can't match anything in particular.
+func parseRoutingRules(value interface{}) ([]routingRule, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list of rules, got %v", routingKey, value)
+	}
+
+	rules := make([]routingRule, 0, len(list))
+	for i, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s rule %d: expected a map, got %v", routingKey, i+1, item)
+		}
+
+		var rule routingRule
+		for key, v := range fields {
+			var err error
+			switch key {
+			case "model-id":
+				rule.modelID, err = ruleString(v)
+			case "min-tokens":
+				rule.minTokens, err = ruleInt(v)
+			case "max-tokens":
+				rule.maxTokens, err = ruleInt(v)
+			case "contains-code":
+				rule.containsCode, ok = v.(bool)
+				if !ok {
+					err = fmt.Errorf("expected true or false, got %v", v)
+				}
+			case "match":
+				var pattern string
+				if pattern, err = ruleString(v); err == nil {
+					rule.match, err = regexp.Compile(pattern)
+				}
+			default:
+				err = fmt.Errorf("unknown key (use model-id, min-tokens, max-tokens, contains-code, or match)")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s rule %d: %s: %v", routingKey, i+1, key, err)
+			}
+		}
+
+		switch {
+		case rule.modelID == "":
+			return nil, fmt.Errorf("%s rule %d: model-id is required", routingKey, i+1)
+		case rule.describe() == "":
+			return nil, fmt.Errorf("%s rule %d: set at least one of min-tokens, max-tokens, contains-code, or match", routingKey, i+1)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func ruleString(v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("expected a non-empty string, got %v", v)
+	}
+	return s, nil
+}
+
+func ruleInt(v interface{}) (int, error) {
+	n, ok := v.(int)
+	if !ok || n <= 0 {
+		return 0, fmt.Errorf("expected a positive integer, got %v", v)
+	}
+	return n, nil
+}
+
+// routeModel returns the first rule that matches a prompt of text, and
+// false if none does.
+func routeModel(rules []routingRule, text string) (routingRule, int, bool) {
+	for i, rule := range rules {
+		if rule.matches(text) {
+			return rule, i + 1, true
+		}
+	}
+	return routingRule{}, 0, false
+}
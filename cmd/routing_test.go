@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// routingRulesFromYAML parses rules the way they'd be read from config.yaml.
+func routingRulesFromYAML(t *testing.T, doc string) ([]routingRule, error) {
+	t.Helper()
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(doc), &value); err != nil {
+		t.Fatal(err)
+	}
+	return parseRoutingRules(value)
+}
+
+func TestRouteModel(t *testing.T) {
+	rules, err := routingRulesFromYAML(t, `
+- min-tokens: 100
+  model-id: long-model
+- contains-code: true
+  model-id: code-model
+- match: (?i)\btranslate\b
+  max-tokens: 50
+  model-id: translate-model
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text      string
+		wantModel string
+		wantRule  int
+	}{
+		{strings.Repeat("word ", 100), "long-model", 1},
+		{"Why does this fail?\n```go\nx := 1\n```", "code-model", 2},
+		{"Translate this to French: hello", "translate-model", 3},
+		{"Translate: " + strings.Repeat("x", 300), "", 0},
+		{"What's the capital of France?", "", 0},
+	}
+	for _, c := range cases {
+		rule, n, ok := routeModel(rules, c.text)
+		if ok != (c.wantModel != "") || rule.modelID != c.wantModel || n != c.wantRule {
+			t.Errorf("%.30q: got %q (rule %d), want %q (rule %d)", c.text, rule.modelID, n, c.wantModel, c.wantRule)
+		}
+	}
+
+	if got := rules[2].describe(); got != `at most 50 tokens, matches "(?i)\\btranslate\\b"` {
+		t.Errorf("unexpected description %q", got)
+	}
+}
+
+func TestParseRoutingRulesErrors(t *testing.T) {
+	cases := map[string]string{
+		"model-id: x":                            "expected a list",
+		"- contains-code: true":                  "model-id is required",
+		"- model-id: x":                          "set at least one",
+		"- model-id: x\n  min-tokens: many":      "min-tokens",
+		"- model-id: x\n  contains-code: yes":    "contains-code",
+		"- model-id: x\n  match: '('":            "match",
+		"- model-id: x\n  longer-than: 5":        "unknown key",
+		"- model-id: x\n  contains-code: 'true'": "expected true or false",
+	}
+	for doc, want := range cases {
+		if _, err := routingRulesFromYAML(t, doc); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected an error mentioning %q, got %v", doc, want, err)
+		}
+	}
+}
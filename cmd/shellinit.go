@@ -0,0 +1,266 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// shellHistoryContext is how many recent commands the key binding sends
+// along with the command line, so the model knows what you've been doing.
+const shellHistoryContext = 10
+
+const zshInit = `# chat-cli shell integration for zsh
+# Add to ~/.zshrc:  eval "$(chat-cli shell-init zsh)"
+
+# ?? <question>: ask a quick question
+_chat_cli_ask() {
+  chat-cli prompt "$*"
+}
+alias '??'='noglob _chat_cli_ask'
+
+# Ctrl+X Ctrl+A: send the current command line as a prompt, with your
+# recent commands as context
+_chat_cli_send_buffer() {
+  [[ -z "$BUFFER" ]] && return
+  local question="$BUFFER"
+  print -s -- "$question"
+  BUFFER=""
+  zle -I
+  print -r -- "> $question"
+  { print "Recent shell commands:"; fc -ln -%[1]d } | chat-cli prompt "$question"
+  zle reset-prompt
+}
+zle -N _chat_cli_send_buffer
+bindkey '^X^A' _chat_cli_send_buffer
+
+# Remember each command and its exit status for chat-cli explain-last. Set
+# CHAT_CLI_CAPTURE_STDERR=1 before loading this to keep each command's
+# stderr too; commands then see stderr as a pipe rather than the terminal.
+# The capture is kept in a directory only you can use: under
+# $XDG_RUNTIME_DIR, or else $TMPDIR or /tmp, and not at all if someone
+# else already has that directory.
+_chat_cli_dir="${XDG_RUNTIME_DIR:-${TMPDIR:-/tmp}}/chat-cli-$UID"
+mkdir -p -m 700 "$_chat_cli_dir" 2>/dev/null
+if [[ -d "$_chat_cli_dir" && -O "$_chat_cli_dir" && ! -L "$_chat_cli_dir" ]] && chmod 700 "$_chat_cli_dir"; then
+  export CHAT_CLI_CAPTURE_FILE="$_chat_cli_dir/last-$$"
+else
+  unset CHAT_CLI_CAPTURE_FILE
+  print -u2 -r -- "chat-cli: not capturing commands for explain-last: $_chat_cli_dir is not a directory only you own"
+fi
+unset _chat_cli_dir
+_chat_cli_preexec() {
+  _chat_cli_command="$1"
+  if [[ "$1" == *explain-last* ]]; then
+    _chat_cli_command=""
+    return
+  fi
+  if [[ -n "$CHAT_CLI_CAPTURE_STDERR" ]]; then
+    exec {_chat_cli_stderr}>&2
+    exec 2> >(tee "$CHAT_CLI_CAPTURE_FILE.stderr" >&$_chat_cli_stderr)
+  fi
+}
+_chat_cli_precmd() {
+  local exit_status=$?
+  if [[ -n "$_chat_cli_stderr" ]]; then
+    exec 2>&$_chat_cli_stderr {_chat_cli_stderr}>&-
+    unset _chat_cli_stderr
+  fi
+  [[ -z "$_chat_cli_command" ]] && return
+  printf '%%s\n%%s\n' "$exit_status" "$_chat_cli_command" >| "$CHAT_CLI_CAPTURE_FILE"
+  _chat_cli_command=""
+}
+_chat_cli_cleanup() {
+  rm -f "$CHAT_CLI_CAPTURE_FILE" "$CHAT_CLI_CAPTURE_FILE.stderr"
+}
+if [[ -n "$CHAT_CLI_CAPTURE_FILE" ]]; then
+  autoload -Uz add-zsh-hook
+  add-zsh-hook preexec _chat_cli_preexec
+  add-zsh-hook precmd _chat_cli_precmd
+  add-zsh-hook zshexit _chat_cli_cleanup
+fi
+`
+
+const bashInit = `# chat-cli shell integration for bash
+# Add to ~/.bashrc:  eval "$(chat-cli shell-init bash)"
+
+# ?? <question>: ask a quick question
+_chat_cli_ask() {
+  chat-cli prompt "$*"
+}
+alias '??'='_chat_cli_ask'
+
+# Ctrl+X Ctrl+A: send the current command line as a prompt, with your
+# recent commands as context
+_chat_cli_send_line() {
+  [[ -z "$READLINE_LINE" ]] && return
+  local question="$READLINE_LINE"
+  history -s -- "$question"
+  READLINE_LINE=""
+  READLINE_POINT=0
+  printf '> %%s\n' "$question"
+  { echo "Recent shell commands:"; history %[1]d | sed 's/^ *[0-9]* *//'; } | chat-cli prompt "$question"
+}
+bind -x '"\C-x\C-a": _chat_cli_send_line'
+
+# Remember each command and its exit status for chat-cli explain-last. Set
+# CHAT_CLI_CAPTURE_STDERR=1 before loading this to keep each command's
+# stderr too; that uses the DEBUG trap, and commands then see stderr as a
+# pipe rather than the terminal. The capture is kept in a directory only
+# you can use: under $XDG_RUNTIME_DIR, or else $TMPDIR or /tmp, and not at
+# all if someone else already has that directory.
+_chat_cli_dir="${XDG_RUNTIME_DIR:-${TMPDIR:-/tmp}}/chat-cli-$UID"
+mkdir -p -m 700 "$_chat_cli_dir" 2>/dev/null
+if [[ -d "$_chat_cli_dir" && -O "$_chat_cli_dir" && ! -L "$_chat_cli_dir" ]] && chmod 700 "$_chat_cli_dir"; then
+  export CHAT_CLI_CAPTURE_FILE="$_chat_cli_dir/last-$$"
+else
+  unset CHAT_CLI_CAPTURE_FILE
+  printf 'chat-cli: not capturing commands for explain-last: %%s is not a directory only you own\n' "$_chat_cli_dir" >&2
+fi
+unset _chat_cli_dir
+_chat_cli_last_entry() {
+  HISTTIMEFORMAT= history 1
+}
+_chat_cli_history="$(_chat_cli_last_entry)"
+_chat_cli_precmd() {
+  local exit_status=$?
+  if [[ -n "$_chat_cli_stderr" ]]; then
+    exec 2>&"$_chat_cli_stderr" {_chat_cli_stderr}>&-
+    unset _chat_cli_stderr
+  fi
+  local entry command
+  entry="$(_chat_cli_last_entry)"
+  if [[ "$entry" != "$_chat_cli_history" ]]; then
+    _chat_cli_history="$entry"
+    command="$(sed '1s/^ *[0-9]*[* ] *//' <<< "$entry")"
+    if [[ "$command" != *explain-last* ]]; then
+      printf '%%s\n%%s\n' "$exit_status" "$command" > "$CHAT_CLI_CAPTURE_FILE"
+    fi
+  fi
+  return $exit_status
+}
+if [[ -n "$CHAT_CLI_CAPTURE_FILE" ]]; then
+  if [[ -n "$CHAT_CLI_CAPTURE_STDERR" ]]; then
+    _chat_cli_preexec() {
+      [[ -n "$_chat_cli_at_prompt" && -z "$COMP_LINE" ]] || return
+      _chat_cli_at_prompt=""
+      [[ "$(_chat_cli_last_entry)" == *explain-last* ]] && return
+      exec {_chat_cli_stderr}>&2
+      exec 2> >(tee "$CHAT_CLI_CAPTURE_FILE.stderr" >&"$_chat_cli_stderr")
+    }
+    trap '_chat_cli_preexec' DEBUG
+  fi
+  PROMPT_COMMAND="_chat_cli_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}; _chat_cli_at_prompt=1"
+fi
+`
+
+const fishInit = `# chat-cli shell integration for fish
+# Add to ~/.config/fish/config.fish:  chat-cli shell-init fish | source
+
+# ?? <question>: ask a quick question
+function '??' --description 'Ask chat-cli a quick question'
+    chat-cli prompt (string join ' ' -- $argv)
+end
+
+# Ctrl+X Ctrl+A: send the current command line as a prompt, with your
+# recent commands as context
+function _chat_cli_send_line
+    set -l question (commandline)
+    test -z "$question"; and return
+    history append -- $question
+    commandline ''
+    echo
+    echo "> $question"
+    begin
+        echo "Recent shell commands:"
+        history --max %[1]d
+    end | chat-cli prompt $question
+    commandline -f repaint
+end
+bind \cx\ca _chat_cli_send_line
+
+# Remember each command and its exit status for chat-cli explain-last, in a
+# directory only you can use: under $XDG_RUNTIME_DIR, or else $TMPDIR or
+# /tmp, and not at all if someone else already has that directory
+set -l _chat_cli_dir /tmp
+set -q TMPDIR; and set _chat_cli_dir $TMPDIR
+set -q XDG_RUNTIME_DIR; and set _chat_cli_dir $XDG_RUNTIME_DIR
+set _chat_cli_dir $_chat_cli_dir/chat-cli-(id -u)
+mkdir -p -m 700 $_chat_cli_dir 2>/dev/null
+if test -d $_chat_cli_dir -a -O $_chat_cli_dir -a ! -L $_chat_cli_dir; and chmod 700 $_chat_cli_dir
+    set -gx CHAT_CLI_CAPTURE_FILE $_chat_cli_dir/last-$fish_pid
+    function _chat_cli_postexec --on-event fish_postexec
+        set -l exit_status $status
+        string match -q -- '*explain-last*' $argv[1]; and return
+        printf '%%s\n%%s\n' $exit_status $argv[1] > $CHAT_CLI_CAPTURE_FILE
+    end
+    function _chat_cli_cleanup --on-event fish_exit
+        rm -f $CHAT_CLI_CAPTURE_FILE $CHAT_CLI_CAPTURE_FILE.stderr
+    end
+else
+    set -e CHAT_CLI_CAPTURE_FILE
+    echo "chat-cli: not capturing commands for explain-last: $_chat_cli_dir is not a directory only you own" >&2
+end
+`
+
+// shellInitScripts maps each supported shell to its integration script.
+var shellInitScripts = map[string]string{
+	"zsh":  zshInit,
+	"bash": bashInit,
+	"fish": fishInit,
+}
+
+// shellInitScript returns the integration script for shell.
+func shellInitScript(shell string) (string, error) {
+	script, ok := shellInitScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(supportedShells(), ", "))
+	}
+	return fmt.Sprintf(script, shellHistoryContext), nil
+}
+
+func supportedShells() []string {
+	shells := make([]string, 0, len(shellInitScripts))
+	for shell := range shellInitScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// shellInitCmd represents the shell-init command
+var shellInitCmd = &cobra.Command{
+	Use:   "shell-init <zsh|bash|fish>",
+	Short: "Print shell integration for your shell's startup file",
+	Long: `Print functions and key bindings that bring chat-cli into your shell:
+
+  ?? <question>      ask a quick question, e.g. ?? how do I undo the last git commit
+  Ctrl+X Ctrl+A      send the command line you're typing as a prompt, along with
+                     your last 10 commands as context
+
+Load it from your shell's startup file:
+
+  zsh:   eval "$(chat-cli shell-init zsh)"     # ~/.zshrc
+  bash:  eval "$(chat-cli shell-init bash)"    # ~/.bashrc
+  fish:  chat-cli shell-init fish | source     # ~/.config/fish/config.fish`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: supportedShells(),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		script, err := shellInitScript(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(script)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellInitCmd)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellInitScript(t *testing.T) {
+	for _, shell := range supportedShells() {
+		t.Run(shell, func(t *testing.T) {
+			script, err := shellInitScript(shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(script, "%!") || strings.Contains(script, "%[") {
+				t.Errorf("script has an unexpanded format verb:\n%s", script)
+			}
+			for _, want := range []string{"??", "chat-cli prompt", "10", "CHAT_CLI_CAPTURE_FILE", "explain-last"} {
+				if !strings.Contains(script, want) {
+					t.Errorf("expected %q in the %s script", want, shell)
+				}
+			}
+
+			// check the syntax when the shell is installed
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				return
+			}
+			file := filepath.Join(t.TempDir(), "init."+shell)
+			if err := os.WriteFile(file, []byte(script), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if out, err := exec.Command(path, "-n", file).CombinedOutput(); err != nil {
+				t.Errorf("%s rejected the script: %v\n%s", shell, err, out)
+			}
+		})
+	}
+
+	if _, err := shellInitScript("powershell"); err == nil || !strings.Contains(err.Error(), "bash, fish, zsh") {
+		t.Errorf("expected an error listing the supported shells, got %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/tools"
+	"github.com/chat-cli/chat-cli/utils"
+	uuid "github.com/satori/go.uuid"
+)
+
+// errQuitChat is returned by a slash command to end the chat session.
+var errQuitChat = errors.New("quit chat")
+
+// chatSession is the state of an interactive chat that slash commands can
+// inspect and change between turns.
+type chatSession struct {
+	input    *bedrockruntime.ConverseStreamInput
+	chatId   string
+	chatRepo *repository.ChatRepository
+	// annotations has the notes from chat annotate that /save includes
+	annotations *repository.AnnotationRepository
+	// feedback stores the ratings given with /thumbs
+	feedback *repository.FeedbackRepository
+	// sqlQuery runs /sql against a read-only handle on the history
+	// database; nil when history isn't in SQLite.
+	sqlQuery *tools.SQLQueryTool
+
+	systemPrompt    string
+	thinkingEnabled bool
+	thinkingBudget  int32
+	thinkingEffort  string
+
+	// repoMap and fileChunks are the --repo-map and --file sections
+	// appended to the system prompt, kept so they survive the prompt being
+	// reloaded from config.
+	repoMap       string
+	fileChunks    []string
+	verifyCommand string
+	// pager is the pager setting, auto or off.
+	pager string
+
+	// usage counts the session's tokens and cost; showStats prints a
+	// footer with them after each turn.
+	usage     *usageTracker
+	showStats bool
+
+	// pendingImages are attached with /image and sent with the next message.
+	pendingImages []chatImage
+
+	// scratchDir holds the snippets /scratch saves; pendingScratch are
+	// the ones loaded for the next message.
+	scratchDir     string
+	pendingScratch []scratchSnippet
+
+	// lastReplyID is the chat history row of the latest reply, which
+	// /retry marks superseded; 0 if it wasn't saved. retryTemperature
+	// overrides the temperature for the turn /retry asks for.
+	lastReplyID      int
+	retryTemperature *float32
+
+	// inferenceDefaults is the inference config the session started with,
+	// which /set <name> default goes back to. settings are the values
+	// changed with /set, saved with the chat in chatSettings (nil doesn't
+	// save them) so they're restored when it's resumed.
+	inferenceDefaults types.InferenceConfiguration
+	settings          map[string]string
+	chatSettings      *repository.ChatSettingsRepository
+
+	// resolveModel validates a model ID for /model; nil skips validation.
+	resolveModel func(modelID string) (string, error)
+
+	// converse sends a single non-streaming request with the session's
+	// model client; used to title the chat. nil disables titling.
+	converse func(ctx context.Context, input *bedrockruntime.ConverseInput) (*bedrockruntime.ConverseOutput, error)
+	// titled is set once the chat has a title or one is being generated.
+	titled bool
+	titles chan chatTitle
+
+	// renderer draws replies; /reasoning reads and changes how it shows
+	// reasoning. nil in sessions built without one.
+	renderer *replyRenderer
+
+	// agentPrompt replaces the built-in /agent instructions and
+	// agentPromptAppend is added to them; see buildAgentInstructions.
+	agentPrompt       string
+	agentPromptAppend string
+
+	// runTurn runs a full tool-use turn for input, with the session's
+	// model client, tools, and permission gate; used by /agent.
+	runTurn func(ctx context.Context, input *bedrockruntime.ConverseStreamInput, callbacks turnCallbacks) (chatTurnResult, error)
+	// agentRunsDir is where /agent runs are saved for agent export; ""
+	// doesn't save them.
+	agentRunsDir string
+
+	out io.Writer
+
+	// ctx is the command's context, canceled on Ctrl+C.
+	ctx context.Context
+}
+
+// context returns the session's context, or context.Background() for a
+// session built without one.
+func (s *chatSession) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// slashCommand is one command available at the chat prompt as /name.
+type slashCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(s *chatSession, args string) error
+}
+
+// slashCommands holds every registered command by name. Commands register
+// themselves from init() with registerSlashCommand, so adding one never
+// requires touching the chat loop.
+var slashCommands = map[string]*slashCommand{}
+
+func registerSlashCommand(c *slashCommand) {
+	slashCommands[c.name] = c
+}
+
+// dispatchSlashCommand runs line as a slash command if it is one, and
+// reports whether it was handled. Lines that don't start with "/" (other
+// than a bare "quit") are left for the model. An unknown command is
+// handled - with a pointer to /help - rather than sent to the model.
+func dispatchSlashCommand(s *chatSession, line string) (bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "quit" {
+		line = "/quit"
+	}
+	if !strings.HasPrefix(line, "/") {
+		return false, nil
+	}
+
+	name, args, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	command, ok := slashCommands[strings.ToLower(name)]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command /%s - type /help to see available commands\n", name)
+		return true, nil
+	}
+
+	return true, command.run(s, strings.TrimSpace(args))
+}
+
+// messageText returns the concatenated text blocks of msg, skipping tool
+// use, tool results, and reasoning.
+func messageText(msg types.Message) string {
+	var b strings.Builder
+	for _, block := range msg.Content {
+		if textBlock, ok := block.(*types.ContentBlockMemberText); ok {
+			b.WriteString(textBlock.Value)
+		}
+	}
+	return b.String()
+}
+
+// transcriptMessages returns the session's user/assistant exchanges that
+// carry text, skipping the tool-use round trips in between.
+func transcriptMessages(s *chatSession) []types.Message {
+	var messages []types.Message
+	for _, msg := range s.input.Messages {
+		if strings.TrimSpace(messageText(msg)) != "" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+func roleLabel(role types.ConversationRole) string {
+	if role == types.ConversationRoleUser {
+		return "User"
+	}
+	return "Assistant"
+}
+
+func runHelpCommand(s *chatSession, _ string) error {
+	names := make([]string, 0, len(slashCommands))
+	for name := range slashCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(s.out, "Available commands:")
+	for _, name := range names {
+		c := slashCommands[name]
+		usage := "/" + c.name
+		if c.usage != "" {
+			usage += " " + c.usage
+		}
+		fmt.Fprintf(s.out, "  %-22s %s\n", usage, c.description)
+	}
+	return nil
+}
+
+func runClearCommand(s *chatSession, _ string) error {
+	s.input.Messages = nil
+	s.chatId = uuid.NewV4().String()
+	s.input.RequestMetadata = map[string]string{"chat-session-id": s.chatId}
+	s.titled = false
+	s.pendingImages = nil
+	s.pendingScratch = nil
+	// /set values carry over to the new chat
+	s.saveSettings()
+
+	fmt.Fprintf(s.out, "Conversation cleared. New chat ID: %s\n", s.chatId)
+	return nil
+}
+
+// saveUsage is the usage line for /save.
+const saveUsage = "usage: /save <file> [--last] [--from N|time] [--to N|time] [--role user|assistant] [--strip-system]"
+
+func runSaveCommand(s *chatSession, args string) error {
+	if args == "" {
+		return errors.New(saveUsage)
+	}
+
+	path, filter, err := parseSaveArgs(args)
+	if err != nil {
+		return err
+	}
+	path, err = utils.ValidateOutputPath(path)
+	if err != nil {
+		return err
+	}
+
+	if filter.last {
+		reply := lastReplyText(s.input.Messages)
+		if reply == "" {
+			return errors.New("there's no reply to save yet")
+		}
+		if err := os.WriteFile(path, []byte(reply+"\n"), 0600); err != nil {
+			return fmt.Errorf("unable to save reply: %w", err)
+		}
+		fmt.Fprintf(s.out, "Saved the last reply (%s) to %s\n", formatByteSize(len(reply)), path)
+		return nil
+	}
+
+	entries, notes, err := sessionTranscript(s)
+	if err != nil {
+		return fmt.Errorf("unable to load the conversation: %w", err)
+	}
+	entries, err = filter.apply(entries)
+	if err != nil {
+		return err
+	}
+
+	systemPrompt := s.systemPrompt
+	if filter.stripSystem {
+		systemPrompt = ""
+	}
+
+	if err := os.WriteFile(path, []byte(renderTranscript(systemPrompt, notes, entries)), 0600); err != nil {
+		return fmt.Errorf("unable to save transcript: %w", err)
+	}
+
+	fmt.Fprintf(s.out, "Saved %d messages to %s\n", len(entries), path)
+	return nil
+}
+
+func runModelCommand(s *chatSession, args string) error {
+	if args == "" {
+		fmt.Fprintf(s.out, "Current model: %s\n", aws.ToString(s.input.ModelId))
+		return nil
+	}
+
+	modelID := args
+	if s.resolveModel != nil {
+		resolved, err := s.resolveModel(args)
+		if err != nil {
+			return err
+		}
+		modelID = resolved
+	}
+
+	s.input.ModelId = aws.String(modelID)
+	// reasoning parameters are model-specific, so rebuild them for the
+	// new model
+	s.input.AdditionalModelRequestFields = buildReasoningConfig(modelID, s.thinkingEnabled, s.thinkingBudget, s.thinkingEffort)
+
+	fmt.Fprintf(s.out, "Switched model to %s\n", modelID)
+	return nil
+}
+
+func runHistoryCommand(s *chatSession, _ string) error {
+	messages := transcriptMessages(s)
+	if len(messages) == 0 {
+		fmt.Fprintln(s.out, "No messages yet.")
+		return nil
+	}
+
+	for _, msg := range messages {
+		fmt.Fprintf(s.out, "[%s]: %s\n", roleLabel(msg.Role), strings.TrimSpace(messageText(msg)))
+	}
+	return nil
+}
+
+func runSystemCommand(s *chatSession, args string) error {
+	if args == "" {
+		if s.systemPrompt == "" {
+			fmt.Fprintln(s.out, "No system prompt set.")
+		} else {
+			fmt.Fprintf(s.out, "Current system prompt:\n%s\n", s.systemPrompt)
+		}
+		return nil
+	}
+
+	s.systemPrompt = args
+	s.input.System = withSystemCachePoint(buildSystemContentBlocks(args))
+
+	fmt.Fprintln(s.out, "System prompt updated.")
+	return nil
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "help",
+		description: "Show available commands",
+		run:         runHelpCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "quit",
+		description: "End the chat session",
+		run: func(*chatSession, string) error {
+			return errQuitChat
+		},
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "clear",
+		description: "Start a fresh conversation, forgetting all context",
+		run:         runClearCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "save",
+		usage:       "<file> [options]",
+		description: "Save the conversation, or part of it (--from, --to, --role, --strip-system), to a Markdown file; --last saves just the latest reply",
+		run:         runSaveCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "model",
+		usage:       "[id]",
+		description: "Show the current model, or switch to another",
+		run:         runModelCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "history",
+		description: "Show this conversation so far",
+		run:         runHistoryCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		name:        "system",
+		usage:       "[prompt]",
+		description: "Show the system prompt, or replace it",
+		run:         runSystemCommand,
+	})
+}
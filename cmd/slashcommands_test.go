@@ -0,0 +1,203 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func textMessage(role types.ConversationRole, text string) types.Message {
+	return types.Message{
+		Role:    role,
+		Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: text}},
+	}
+}
+
+func newTestChatSession() (*chatSession, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &chatSession{
+		input: &bedrockruntime.ConverseStreamInput{
+			ModelId: aws.String("anthropic.claude-3-haiku-20240307-v1:0"),
+			Messages: []types.Message{
+				textMessage(types.ConversationRoleUser, "hello"),
+				textMessage(types.ConversationRoleAssistant, "hi there"),
+			},
+		},
+		chatId: "original-id",
+		out:    out,
+	}, out
+}
+
+func TestDispatchSlashCommand(t *testing.T) {
+	t.Run("plain text is not a command", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		handled, err := dispatchSlashCommand(s, "what is /etc/hosts for?\n")
+		if handled || err != nil {
+			t.Errorf("expected plain text to pass through, got handled=%v err=%v", handled, err)
+		}
+	})
+
+	t.Run("quit and /quit end the session", func(t *testing.T) {
+		for _, line := range []string{"quit\n", "/quit\n", "/QUIT"} {
+			s, _ := newTestChatSession()
+			if _, err := dispatchSlashCommand(s, line); !errors.Is(err, errQuitChat) {
+				t.Errorf("%q: expected errQuitChat, got %v", line, err)
+			}
+		}
+	})
+
+	t.Run("unknown command is handled locally", func(t *testing.T) {
+		s, out := newTestChatSession()
+		handled, err := dispatchSlashCommand(s, "/frobnicate now")
+		if !handled || err != nil {
+			t.Errorf("expected unknown command to be handled, got handled=%v err=%v", handled, err)
+		}
+		if !strings.Contains(out.String(), "/help") {
+			t.Errorf("expected a pointer to /help, got %q", out.String())
+		}
+	})
+
+	t.Run("help lists registered commands", func(t *testing.T) {
+		s, out := newTestChatSession()
+		if _, err := dispatchSlashCommand(s, "/help"); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"/clear", "/save <file>", "/model [id]", "/history", "/system [prompt]"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected help to list %q, got:\n%s", want, out.String())
+			}
+		}
+	})
+}
+
+func TestSlashCommands(t *testing.T) {
+	t.Run("clear resets context and starts a new chat", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		if _, err := dispatchSlashCommand(s, "/clear"); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.input.Messages) != 0 {
+			t.Errorf("expected no messages, got %d", len(s.input.Messages))
+		}
+		if s.chatId == "original-id" || s.input.RequestMetadata["chat-session-id"] != s.chatId {
+			t.Errorf("expected a fresh chat ID in session and metadata, got %q / %v", s.chatId, s.input.RequestMetadata)
+		}
+	})
+
+	t.Run("save writes a markdown transcript", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		path := filepath.Join(t.TempDir(), "chat.md")
+		if _, err := dispatchSlashCommand(s, "/save "+path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "## User\n\nhello\n\n## Assistant\n\nhi there\n\n"
+		if string(data) != want {
+			t.Errorf("expected %q, got %q", want, string(data))
+		}
+	})
+
+	t.Run("save --last writes just the latest reply", func(t *testing.T) {
+		s, out := newTestChatSession()
+		path := filepath.Join(t.TempDir(), "reply.md")
+		if _, err := dispatchSlashCommand(s, "/save "+path+" --last"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "hi there\n" {
+			t.Errorf("expected just the reply, got %q", string(data))
+		}
+		if !strings.Contains(out.String(), "Saved the last reply") {
+			t.Errorf("unexpected output %q", out.String())
+		}
+
+		if _, err := dispatchSlashCommand(s, "/save "+path+" --last --role user"); err == nil {
+			t.Error("expected --last with --role to be rejected")
+		}
+	})
+
+	t.Run("save checks the path", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		for _, path := range []string{"../../../escape.md", t.TempDir(), filepath.Join(t.TempDir(), "missing", "chat.md")} {
+			if _, err := dispatchSlashCommand(s, "/save "+path); err == nil {
+				t.Errorf("/save %s: expected an error", path)
+			}
+		}
+	})
+
+	t.Run("save requires a filename", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		if _, err := dispatchSlashCommand(s, "/save"); err == nil {
+			t.Error("expected a usage error, got none")
+		}
+	})
+
+	t.Run("model switches after validation", func(t *testing.T) {
+		s, _ := newTestChatSession()
+		s.resolveModel = func(id string) (string, error) {
+			if id == "bad" {
+				return "", errors.New("not a text model")
+			}
+			return id + "-resolved", nil
+		}
+
+		if _, err := dispatchSlashCommand(s, "/model bad"); err == nil {
+			t.Error("expected an invalid model to be rejected")
+		}
+		if _, err := dispatchSlashCommand(s, "/model amazon.nova-pro-v1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if got := aws.ToString(s.input.ModelId); got != "amazon.nova-pro-v1:0-resolved" {
+			t.Errorf("expected switched model, got %q", got)
+		}
+	})
+
+	t.Run("history skips tool round trips", func(t *testing.T) {
+		s, out := newTestChatSession()
+		s.input.Messages = append(s.input.Messages, types.Message{
+			Role: types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberToolResult{
+				Value: types.ToolResultBlock{ToolUseId: aws.String("t1")},
+			}},
+		})
+		if _, err := dispatchSlashCommand(s, "/history"); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != "[User]: hello\n[Assistant]: hi there\n" {
+			t.Errorf("unexpected history %q", got)
+		}
+	})
+
+	t.Run("system replaces the system prompt", func(t *testing.T) {
+		s, out := newTestChatSession()
+		if _, err := dispatchSlashCommand(s, "/system Be terse."); err != nil {
+			t.Fatal(err)
+		}
+		if s.systemPrompt != "Be terse." || len(s.input.System) == 0 {
+			t.Errorf("expected system prompt to be set, got %q / %v", s.systemPrompt, s.input.System)
+		}
+		out.Reset()
+		if _, err := dispatchSlashCommand(s, "/system"); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "Be terse.") {
+			t.Errorf("expected current prompt to be shown, got %q", out.String())
+		}
+	})
+}
@@ -0,0 +1,369 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/polly"
+	pollytypes "github.com/aws/aws-sdk-go-v2/service/polly/types"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// --speak-stream reads a reply aloud as it streams in. The text is cut into
+// sentences, each is synthesized with Amazon Polly as soon as it's
+// complete, and the clips are played one after another, so the first
+// sentence is heard while the model is still writing the rest.
+const (
+	speechVoiceKey  = "speech.voice"
+	speechEngineKey = "speech.engine"
+	speechPlayerKey = "speech.player"
+
+	defaultSpeechVoice  = "Joanna"
+	defaultSpeechEngine = "neural"
+
+	// minSpeechChunk keeps short sentences, and fragments like a list's
+	// "1.", with the text after them, so there are fewer, smoother clips.
+	minSpeechChunk = 40
+	// maxSpeechChunk cuts text that has no sentence boundary, well inside
+	// the 3000 characters Polly takes at once.
+	maxSpeechChunk = 1500
+	// maxSpeechClip caps the audio read back for one chunk.
+	maxSpeechClip = 16 << 20
+)
+
+// speechCodeFence opens and closes a Markdown code block, which isn't read out.
+const speechCodeFence = "```"
+
+// sentenceChunker cuts streamed text into chunks worth synthesizing:
+// whole sentences, at least minSpeechChunk long, with code blocks and
+// Markdown markup left out.
+type sentenceChunker struct {
+	pending string
+	inCode  bool
+}
+
+// add takes the next part of the reply and returns the chunks it
+// completes.
+func (c *sentenceChunker) add(part string) []string {
+	c.pending += part
+
+	var chunks []string
+	emit := func(text string) {
+		if spoken := speakableText(text); spoken != "" {
+			chunks = append(chunks, spoken)
+		}
+	}
+	for {
+		if c.inCode {
+			end := strings.Index(c.pending, speechCodeFence)
+			if end < 0 {
+				// keep enough to spot a fence split across parts
+				c.pending = c.pending[max(0, len(c.pending)-len(speechCodeFence)+1):]
+				return chunks
+			}
+			c.pending = c.pending[end+len(speechCodeFence):]
+			c.inCode = false
+			continue
+		}
+
+		prose := c.pending
+		fence := strings.Index(prose, speechCodeFence)
+		if fence >= 0 {
+			prose = prose[:fence]
+		}
+		if end := sentenceEnd(prose); end > 0 {
+			emit(prose[:end])
+			c.pending = c.pending[end:]
+			continue
+		}
+		if fence >= 0 {
+			emit(prose)
+			c.pending = c.pending[fence+len(speechCodeFence):]
+			c.inCode = true
+			continue
+		}
+		if len(prose) > maxSpeechChunk {
+			cut := strings.LastIndexAny(prose[:maxSpeechChunk], " \t\n")
+			if cut <= 0 {
+				cut = maxSpeechChunk
+				for !utf8.RuneStart(prose[cut]) {
+					cut--
+				}
+			}
+			emit(prose[:cut])
+			c.pending = c.pending[cut:]
+			continue
+		}
+		return chunks
+	}
+}
+
+// flush returns what's left once the reply is complete.
+func (c *sentenceChunker) flush() []string {
+	rest, inCode := c.pending, c.inCode
+	c.pending, c.inCode = "", false
+	if inCode {
+		return nil
+	}
+	if spoken := speakableText(rest); spoken != "" {
+		return []string{spoken}
+	}
+	return nil
+}
+
+// sentenceEnd returns where the first sentence in text of at least
+// minSpeechChunk ends: after a newline, or after '.', '!' or '?' once the
+// next character shows it isn't part of a number or name. It returns -1
+// when no sentence is complete yet.
+func sentenceEnd(text string) int {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+		case '.', '!', '?':
+			if i+1 >= len(text) || !strings.ContainsRune(" \t\n", rune(text[i+1])) {
+				continue
+			}
+		default:
+			continue
+		}
+		if len(strings.TrimSpace(text[:i+1])) >= minSpeechChunk {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// markdownMarkup is dropped from what's read out.
+var markdownMarkup = strings.NewReplacer("`", "", "**", "", "__", "", "*", "", "#", "")
+
+// speakableText is text as it should be read: without Markdown markup or
+// runs of whitespace.
+func speakableText(text string) string {
+	return strings.Join(strings.Fields(markdownMarkup.Replace(text)), " ")
+}
+
+// pollySpeech synthesizes speech with Amazon Polly's SynthesizeSpeech
+// API.
+type pollySpeech struct {
+	client *polly.Client
+	voice  string
+	engine string
+}
+
+// newPollySpeech returns a synthesizer for cfg's region and credentials
+// with the configured voice and engine.
+func newPollySpeech(fm *conf.FileManager, cfg aws.Config) *pollySpeech {
+	return &pollySpeech{
+		client: polly.NewFromConfig(cfg),
+		voice:  fmt.Sprint(fm.GetConfigValue(speechVoiceKey, "", defaultSpeechVoice)),
+		engine: fmt.Sprint(fm.GetConfigValue(speechEngineKey, "", defaultSpeechEngine)),
+	}
+}
+
+// synthesize returns text spoken as MP3 audio.
+func (p *pollySpeech) synthesize(ctx context.Context, text string) ([]byte, error) {
+	out, err := p.client.SynthesizeSpeech(ctx, &polly.SynthesizeSpeechInput{
+		Engine:       pollytypes.Engine(p.engine),
+		OutputFormat: pollytypes.OutputFormatMp3,
+		Text:         aws.String(text),
+		VoiceId:      pollytypes.VoiceId(p.voice),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("polly: %w", err)
+	}
+	defer out.AudioStream.Close()
+
+	data, err := io.ReadAll(io.LimitReader(out.AudioStream, maxSpeechClip))
+	if err != nil {
+		return nil, fmt.Errorf("polly: %w", err)
+	}
+	return data, nil
+}
+
+// speechPlayers are the players tried, in order, when speech.player isn't
+// set. Each is given the path of an MP3 file.
+func speechPlayers(goos string) [][]string {
+	if goos == "darwin" {
+		return [][]string{{"afplay"}}
+	}
+	return [][]string{
+		{"mpv", "--no-video", "--really-quiet"},
+		{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"},
+		{"mpg123", "-q"},
+	}
+}
+
+// speechPlayerCommand returns the command that plays a clip: speech.player
+// if it's set, or else the first of speechPlayers that's installed.
+func speechPlayerCommand(configured, goos string, lookPath func(string) (string, error)) ([]string, error) {
+	if args := strings.Fields(configured); len(args) > 0 {
+		return args, nil
+	}
+
+	var tried []string
+	for _, player := range speechPlayers(goos) {
+		if _, err := lookPath(player[0]); err == nil {
+			return player, nil
+		}
+		tried = append(tried, player[0])
+	}
+	return nil, fmt.Errorf("no audio player found; install one of %s, or set %s to a command that plays an MP3 file", strings.Join(tried, ", "), speechPlayerKey)
+}
+
+// playSpeech returns a func that plays a clip with player, through a
+// temporary file, stopping it if ctx is cancelled.
+func playSpeech(player []string) func(ctx context.Context, clip []byte) error {
+	return func(ctx context.Context, clip []byte) error {
+		f, err := os.CreateTemp("", "chat-cli-speech-*.mp3")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.Write(clip); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		args := append(append([]string{}, player[1:]...), f.Name())
+		cmd := exec.CommandContext(ctx, player[0], args...) // #nosec G204 - the player is a known one or the user's own speech.player
+		if err := cmd.Run(); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("%s: %w", player[0], err)
+		}
+		return nil
+	}
+}
+
+// streamSpeaker reads replies aloud as they stream. Chunks are synthesized
+// in order on one goroutine and played on another, so the next chunk is
+// ready by the time the one before it has been heard.
+type streamSpeaker struct {
+	synthesize func(ctx context.Context, text string) ([]byte, error)
+	play       func(ctx context.Context, clip []byte) error
+
+	chunker sentenceChunker
+	chunks  chan string
+	cancel  context.CancelFunc
+	done    chan error
+}
+
+// newStreamSpeaker returns a speaker that reads with Polly and plays with
+// the configured player.
+func newStreamSpeaker(ctx context.Context, fm *conf.FileManager, cfg aws.Config) (*streamSpeaker, error) {
+	player, err := speechPlayerCommand(fmt.Sprint(fm.GetConfigValue(speechPlayerKey, "", "")), runtime.GOOS, exec.LookPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return nil, fmt.Errorf("unable to get AWS credentials for Polly: %w", err)
+	}
+	return &streamSpeaker{synthesize: newPollySpeech(fm, cfg).synthesize, play: playSpeech(player)}, nil
+}
+
+// start begins reading a new reply. Cancelling ctx stops it, including the
+// clip playing.
+func (s *streamSpeaker) start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.chunker = sentenceChunker{}
+	s.chunks = make(chan string, 256)
+	s.done = make(chan error, 1)
+
+	clips := make(chan []byte, 2)
+	var synthErr error
+	go func() {
+		defer close(clips)
+		// later chunks are still taken after a failure, so onText never
+		// blocks on a full channel
+		for chunk := range s.chunks {
+			if synthErr != nil || ctx.Err() != nil {
+				continue
+			}
+			clip, err := s.synthesize(ctx, chunk)
+			if err != nil {
+				synthErr = err
+				continue
+			}
+			select {
+			case clips <- clip:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	go func() {
+		var playErr error
+		for clip := range clips {
+			if playErr != nil || ctx.Err() != nil {
+				continue
+			}
+			playErr = s.play(ctx, clip)
+		}
+		if ctx.Err() != nil {
+			s.done <- nil
+			return
+		}
+		// clips is closed only once synthesis is over
+		s.done <- errors.Join(synthErr, playErr)
+	}()
+}
+
+// onText is a utils.StreamingOutputHandler that queues each sentence of
+// the reply as it's completed.
+func (s *streamSpeaker) onText(_ context.Context, part string) error {
+	for _, chunk := range s.chunker.add(part) {
+		s.chunks <- chunk
+	}
+	return nil
+}
+
+// finish queues the end of the reply and waits until it's been read out,
+// or until ctx is cancelled, which stops it.
+func (s *streamSpeaker) finish(ctx context.Context) error {
+	for _, chunk := range s.chunker.flush() {
+		s.chunks <- chunk
+	}
+	close(s.chunks)
+
+	select {
+	case err := <-s.done:
+		s.cancel()
+		return err
+	case <-ctx.Done():
+		s.cancel()
+		return <-s.done
+	}
+}
+
+// stop ends the reading of a reply at once.
+func (s *streamSpeaker) stop() {
+	s.cancel()
+	_ = s.finish(context.Background())
+}
+
+// speakAlong returns onText extended to also read the text with speaker,
+// or onText itself when there's no speaker.
+func speakAlong(speaker *streamSpeaker, onText utils.StreamingOutputHandler) utils.StreamingOutputHandler {
+	if speaker == nil {
+		return onText
+	}
+	return func(ctx context.Context, part string) error {
+		if err := onText(ctx, part); err != nil {
+			return err
+		}
+		return speaker.onText(ctx, part)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/polly"
+)
+
+func TestSentenceChunker(t *testing.T) {
+	reply := "Pi is about 3.14159, which is close enough here. **Short.** Then some more text follows it!\n" +
+		"```go\nfmt.Println(\"not read. at all\")\n```\n" +
+		"# Done\nThat's the end"
+
+	var c sentenceChunker
+	var got []string
+	// streamed a few characters at a time, as Bedrock sends it
+	for i := 0; i < len(reply); i += 3 {
+		got = append(got, c.add(reply[i:min(i+3, len(reply))])...)
+	}
+	got = append(got, c.flush()...)
+
+	want := []string{
+		"Pi is about 3.14159, which is close enough here.",
+		"Short. Then some more text follows it!",
+		"Done That's the end",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestSentenceChunkerCutsLongText(t *testing.T) {
+	var c sentenceChunker
+	chunks := c.add(strings.Repeat("word ", 400))
+	if len(chunks) != 1 || len(chunks[0]) > maxSpeechChunk {
+		t.Fatalf("expected one chunk of at most %d characters, got %d", maxSpeechChunk, len(chunks))
+	}
+	if rest := c.flush(); len(rest) != 1 {
+		t.Errorf("expected the rest at the end, got %q", rest)
+	}
+
+	c.add("```\nunfinished code")
+	if rest := c.flush(); rest != nil {
+		t.Errorf("expected an unclosed code block not to be read, got %q", rest)
+	}
+}
+
+func TestPollySynthesize(t *testing.T) {
+	type pollyRequest struct {
+		Engine       string
+		OutputFormat string
+		Text         string
+		VoiceId      string
+	}
+	var got pollyRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/speech" || !strings.Contains(r.Header.Get("Authorization"), "/us-west-2/polly/aws4_request") {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if got.Text == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message": "This voice does not support the selected engine."}`))
+			return
+		}
+		_, _ = w.Write([]byte("ID3 audio"))
+	}))
+	defer server.Close()
+
+	cfg := testAWSConfig(server)
+	cfg.Region = "us-west-2"
+	p := &pollySpeech{client: polly.NewFromConfig(cfg), voice: "Joanna", engine: "neural"}
+	clip, err := p.synthesize(t.Context(), "Hello there.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(clip) != "ID3 audio" {
+		t.Errorf("clip = %q", clip)
+	}
+	if want := (pollyRequest{Engine: "neural", OutputFormat: "mp3", Text: "Hello there.", VoiceId: "Joanna"}); got != want {
+		t.Errorf("request = %+v", got)
+	}
+
+	if _, err := p.synthesize(t.Context(), "fail"); err == nil || !strings.Contains(err.Error(), "does not support the selected engine") {
+		t.Errorf("expected Polly's message in the error, got %v", err)
+	}
+}
+
+func TestSpeechPlayerCommand(t *testing.T) {
+	only := func(name string) func(string) (string, error) {
+		return func(file string) (string, error) {
+			if file == name {
+				return "/usr/bin/" + name, nil
+			}
+			return "", errors.New("not found")
+		}
+	}
+
+	if got, _ := speechPlayerCommand("vlc --intf dummy", "linux", only("mpv")); !reflect.DeepEqual(got, []string{"vlc", "--intf", "dummy"}) {
+		t.Errorf("expected speech.player to win, got %q", got)
+	}
+	if got, _ := speechPlayerCommand("", "linux", only("mpg123")); !reflect.DeepEqual(got, []string{"mpg123", "-q"}) {
+		t.Errorf("got %q", got)
+	}
+	if got, _ := speechPlayerCommand("", "darwin", only("afplay")); !reflect.DeepEqual(got, []string{"afplay"}) {
+		t.Errorf("got %q", got)
+	}
+	if _, err := speechPlayerCommand("", "linux", only("nothing")); err == nil || !strings.Contains(err.Error(), "mpv, ffplay, mpg123") {
+		t.Errorf("expected the players tried in the error, got %v", err)
+	}
+}
+
+func TestStreamSpeaker(t *testing.T) {
+	var mu sync.Mutex
+	var played []string
+	s := &streamSpeaker{
+		synthesize: func(_ context.Context, text string) ([]byte, error) {
+			return []byte(text), nil
+		},
+		play: func(_ context.Context, clip []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			played = append(played, string(clip))
+			return nil
+		},
+	}
+
+	s.start(t.Context())
+	onText := speakAlong(s, func(context.Context, string) error { return nil })
+	for _, part := range []string{"The first sentence is long enough to read. ", "The second one", " ends the reply."} {
+		if err := onText(t.Context(), part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.finish(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"The first sentence is long enough to read.", "The second one ends the reply."}; !reflect.DeepEqual(played, want) {
+		t.Errorf("played %q", played)
+	}
+
+	// a failure is reported once the reply is done
+	s.synthesize = func(context.Context, string) ([]byte, error) { return nil, errors.New("throttled") }
+	s.start(t.Context())
+	_ = s.onText(t.Context(), "Something that won't be heard at all, sadly.")
+	if err := s.finish(t.Context()); err == nil || err.Error() != "throttled" {
+		t.Errorf("expected the synthesis error, got %v", err)
+	}
+}
+
+func TestStreamSpeakerStop(t *testing.T) {
+	playing := make(chan struct{})
+	s := &streamSpeaker{
+		synthesize: func(_ context.Context, text string) ([]byte, error) { return []byte(text), nil },
+		play: func(ctx context.Context, _ []byte) error {
+			close(playing)
+			<-ctx.Done()
+			return nil
+		},
+	}
+	s.start(t.Context())
+	_ = s.onText(t.Context(), "A sentence that takes a very long time to say.\n")
+	<-playing
+
+	// Ctrl+C while waiting for the reading stops the clip playing
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := s.finish(ctx); err != nil {
+		t.Errorf("expected no error when stopped, got %v", err)
+	}
+
+	if speakAlong(nil, nil) != nil {
+		t.Error("expected the handler itself without a speaker")
+	}
+}
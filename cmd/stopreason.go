@@ -0,0 +1,43 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// stopReasonMeanings says in a few words what each of Bedrock's stop
+// reasons means for the reply the user is looking at.
+var stopReasonMeanings = map[types.StopReason]string{
+	types.StopReasonEndTurn:                    "the model finished its reply",
+	types.StopReasonMaxTokens:                  "the reply reached the max-tokens limit and may be cut off",
+	types.StopReasonStopSequence:               "the model produced a stop sequence",
+	types.StopReasonGuardrailIntervened:        "a guardrail stepped in",
+	types.StopReasonToolUse:                    "the model asked to use a tool",
+	types.StopReasonContentFiltered:            "the reply was filtered",
+	types.StopReasonModelContextWindowExceeded: "the conversation no longer fits the model's context window",
+	stopReasonInterrupted:                      "Ctrl+C cancelled the reply",
+}
+
+// stopReasonLine describes why a reply ended, for --verbose. It's empty
+// when Bedrock didn't report a reason.
+func stopReasonLine(stopReason types.StopReason) string {
+	if stopReason == "" {
+		return ""
+	}
+	if meaning, ok := stopReasonMeanings[stopReason]; ok {
+		return fmt.Sprintf("Stop reason: %s (%s)", stopReason, meaning)
+	}
+	return fmt.Sprintf("Stop reason: %s", stopReason)
+}
+
+// printStopReason writes stopReasonLine to w, dimmed, if there is one.
+func printStopReason(w io.Writer, stopReason types.StopReason) {
+	if line := stopReasonLine(stopReason); line != "" {
+		fmt.Fprintf(w, "\033[90m%s\033[0m\n", line)
+	}
+}
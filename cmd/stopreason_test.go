@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+func TestStopReasonLine(t *testing.T) {
+	cases := map[types.StopReason]string{
+		types.StopReasonMaxTokens: "Stop reason: max_tokens (the reply reached the max-tokens limit and may be cut off)",
+		types.StopReasonEndTurn:   "Stop reason: end_turn (the model finished its reply)",
+		"something_new":           "Stop reason: something_new",
+		"":                        "",
+	}
+	for reason, want := range cases {
+		if got := stopReasonLine(reason); got != want {
+			t.Errorf("stopReasonLine(%q) = %q, want %q", reason, got, want)
+		}
+	}
+
+	var out bytes.Buffer
+	printStopReason(&out, "")
+	if out.Len() != 0 {
+		t.Errorf("expected nothing printed without a stop reason, got %q", out.String())
+	}
+}
+
+func TestRecordReplySavesStopReason(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &chatSession{chatId: "chat-1", chatRepo: repository.NewChatRepository(database)}
+	s.recordReply("Once upon", types.StopReasonMaxTokens, 0)
+
+	messages, err := s.chatRepo.GetMessages(t.Context(), s.chatId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Persona != "Assistant" || messages[0].StopReason != "max_tokens" {
+		t.Errorf("unexpected saved messages %+v", messages)
+	}
+}
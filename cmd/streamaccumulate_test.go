@@ -41,7 +41,7 @@ func TestAccumulateStream_TextOnly(t *testing.T) {
 
 	onReasoning := func(_ context.Context, _ string) error { return nil }
 
-	msg, toolCalls, stopReason, err := accumulateStream(events, onText, onReasoning)
+	msg, toolCalls, stopReason, err := accumulateStream(t.Context(), events, onText, onReasoning)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -103,7 +103,7 @@ func TestAccumulateStream_ToolUse(t *testing.T) {
 
 	onReasoning := func(_ context.Context, _ string) error { return nil }
 
-	msg, toolCalls, stopReason, err := accumulateStream(events, onText, onReasoning)
+	msg, toolCalls, stopReason, err := accumulateStream(t.Context(), events, onText, onReasoning)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -170,7 +170,7 @@ func TestAccumulateStream_ReasoningContent(t *testing.T) {
 		return nil
 	}
 
-	msg, _, _, err := accumulateStream(events, onText, onReasoning)
+	msg, _, _, err := accumulateStream(t.Context(), events, onText, onReasoning)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// maxStreamResumes bounds how many times one response is continued after
+// its stream breaks off.
+const maxStreamResumes = 2
+
+// continueInstruction asks the model to finish a response whose stream
+// broke off.
+const continueInstruction = "Your previous response was cut off by a connection problem. " +
+	"Continue it from exactly where it stopped, without repeating any of it or mentioning the interruption."
+
+// streamResponse sends input and reads the response, like send followed by
+// accumulateStream. A stream that ends without a stop reason, as one does
+// when the connection drops, is picked up where it stopped: the text so far
+// is sent back with continueInstruction, and the continuation streams on
+// through onText and is joined to it. resumes counts the follow-up requests.
+func streamResponse(
+	ctx context.Context,
+	send converseStreamFunc,
+	input *bedrockruntime.ConverseStreamInput,
+	onText, onReasoning utils.StreamingOutputHandler,
+) (msg types.Message, toolCalls []tools.ToolCall, stopReason types.StopReason, resumes int, err error) {
+	events, err := send(ctx, input)
+	if err != nil {
+		return types.Message{}, nil, "", 0, err
+	}
+	msg, toolCalls, stopReason, err = accumulateStream(ctx, events, onText, onReasoning)
+
+	for err == nil && stopReason == "" && ctx.Err() == nil {
+		// reasoning and half-streamed tool calls can't be sent back, so
+		// only the text is kept
+		partial := messageText(msg)
+		msg, toolCalls = assistantTextMessage(partial), nil
+		if resumes == maxStreamResumes {
+			break
+		}
+		resumes++
+
+		next := input
+		if partial != "" {
+			next = continuationInput(input, partial)
+		}
+		// with nothing worth keeping, the request is simply sent again
+		events, sendErr := send(ctx, next)
+		if sendErr != nil {
+			return msg, nil, "", resumes, fmt.Errorf("the response broke off and couldn't be resumed: %w", sendErr)
+		}
+		var cont types.Message
+		cont, toolCalls, stopReason, err = accumulateStream(ctx, events, onText, onReasoning)
+		msg = stitchResponse(partial, cont)
+	}
+	return msg, toolCalls, stopReason, resumes, err
+}
+
+// resumedNote tells the user a reply was stitched together from resumes+1
+// responses.
+func resumedNote(resumes int) string {
+	if resumes == 1 {
+		return "The response broke off and was resumed."
+	}
+	return fmt.Sprintf("The response broke off and was resumed %d times.", resumes)
+}
+
+// continuationInput returns a copy of input that also carries the partial
+// response and the instruction to continue it.
+func continuationInput(input *bedrockruntime.ConverseStreamInput, partial string) *bedrockruntime.ConverseStreamInput {
+	next := *input
+	next.Messages = append(slices.Clone(input.Messages), assistantTextMessage(partial), userTextMessage(continueInstruction))
+	return &next
+}
+
+// stitchResponse joins partial, the text of a response that broke off, to
+// the start of cont's text, giving the one message the conversation keeps.
+func stitchResponse(partial string, cont types.Message) types.Message {
+	if partial == "" {
+		return cont
+	}
+	content := slices.Clone(cont.Content)
+	for i, block := range content {
+		if text, ok := block.(*types.ContentBlockMemberText); ok {
+			content[i] = &types.ContentBlockMemberText{Value: partial + text.Value}
+			return types.Message{Role: types.ConversationRoleAssistant, Content: content}
+		}
+	}
+	// no text, only tool calls: the partial text goes before them, after
+	// any reasoning
+	at := 0
+	for at < len(content) {
+		if _, ok := content[at].(*types.ContentBlockMemberReasoningContent); !ok {
+			break
+		}
+		at++
+	}
+	content = slices.Insert(content, at, types.ContentBlock(&types.ContentBlockMemberText{Value: partial}))
+	return types.Message{Role: types.ConversationRoleAssistant, Content: content}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+// brokenChannel streams text and then ends without a stop reason, as a
+// dropped connection does.
+func brokenChannel(text string) <-chan types.ConverseStreamOutput {
+	ch := make(chan types.ConverseStreamOutput, 1)
+	if text != "" {
+		ch <- &types.ConverseStreamOutputMemberContentBlockDelta{
+			Value: types.ContentBlockDeltaEvent{
+				ContentBlockIndex: aws.Int32(0),
+				Delta:             &types.ContentBlockDeltaMemberText{Value: text},
+			},
+		}
+	}
+	close(ch)
+	return ch
+}
+
+func TestStreamResponseResumes(t *testing.T) {
+	input := &bedrockruntime.ConverseStreamInput{Messages: []types.Message{userTextMessage("Say hello")}}
+	var sent []*bedrockruntime.ConverseStreamInput
+	send := func(_ context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		sent = append(sent, in)
+		if len(sent) == 1 {
+			return brokenChannel("Hello, wor"), nil
+		}
+		return textOnlyChannel("ld!"), nil
+	}
+	var streamed strings.Builder
+	onText := func(_ context.Context, part string) error {
+		streamed.WriteString(part)
+		return nil
+	}
+
+	msg, _, stopReason, resumes, err := streamResponse(t.Context(), send, input, onText, onText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageText(msg); got != "Hello, world!" || streamed.String() != "Hello, world!" {
+		t.Errorf("message = %q, streamed = %q; want the two parts joined", got, streamed.String())
+	}
+	if stopReason != types.StopReasonEndTurn || resumes != 1 {
+		t.Errorf("stop reason = %q, resumes = %d", stopReason, resumes)
+	}
+
+	// the follow-up carries the partial reply and the instruction to go on,
+	// without changing the conversation itself
+	cont := sent[1].Messages
+	if len(cont) != 3 || messageText(cont[1]) != "Hello, wor" || messageText(cont[2]) != continueInstruction {
+		t.Errorf("continuation messages = %+v", cont)
+	}
+	if len(input.Messages) != 1 {
+		t.Errorf("input was changed: %d messages", len(input.Messages))
+	}
+}
+
+func TestStreamResponseGivesUp(t *testing.T) {
+	calls := 0
+	send := func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		calls++
+		return brokenChannel("more "), nil
+	}
+	discard := func(context.Context, string) error { return nil }
+
+	msg, _, stopReason, resumes, err := streamResponse(t.Context(), send, &bedrockruntime.ConverseStreamInput{}, discard, discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != maxStreamResumes+1 || resumes != maxStreamResumes || stopReason != "" {
+		t.Errorf("calls = %d, resumes = %d, stop reason = %q", calls, resumes, stopReason)
+	}
+	if got := messageText(msg); got != "more more more " {
+		t.Errorf("message = %q; want what arrived from each attempt", got)
+	}
+}
+
+func TestStreamResponseResendsWhenNothingArrived(t *testing.T) {
+	input := &bedrockruntime.ConverseStreamInput{Messages: []types.Message{userTextMessage("hi")}}
+	var sent []*bedrockruntime.ConverseStreamInput
+	send := func(_ context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		sent = append(sent, in)
+		if len(sent) == 1 {
+			return brokenChannel(""), nil
+		}
+		return textOnlyChannel("Hi!"), nil
+	}
+	discard := func(context.Context, string) error { return nil }
+
+	msg, _, _, resumes, err := streamResponse(t.Context(), send, input, discard, discard)
+	if err != nil || messageText(msg) != "Hi!" || resumes != 1 {
+		t.Fatalf("msg = %q, resumes = %d, err = %v", messageText(msg), resumes, err)
+	}
+	if sent[1] != input {
+		t.Error("expected the original request to be sent again")
+	}
+}
+
+func TestStreamResponseResumeFails(t *testing.T) {
+	calls := 0
+	send := func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		calls++
+		if calls == 1 {
+			return brokenChannel("Half"), nil
+		}
+		return nil, errors.New("throttled")
+	}
+	discard := func(context.Context, string) error { return nil }
+
+	msg, _, _, _, err := streamResponse(t.Context(), send, &bedrockruntime.ConverseStreamInput{}, discard, discard)
+	if err == nil || !strings.Contains(err.Error(), "couldn't be resumed") || messageText(msg) != "Half" {
+		t.Errorf("msg = %q, err = %v", messageText(msg), err)
+	}
+}
+
+func TestStitchResponse(t *testing.T) {
+	cont := types.Message{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{
+		&types.ContentBlockMemberReasoningContent{Value: &types.ReasoningContentBlockMemberReasoningText{}},
+		&types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{Name: aws.String("read_file")}},
+	}}
+	got := stitchResponse("Let me check.", cont)
+	if len(got.Content) != 3 {
+		t.Fatalf("content = %+v", got.Content)
+	}
+	if text, ok := got.Content[1].(*types.ContentBlockMemberText); !ok || text.Value != "Let me check." {
+		t.Errorf("expected the partial text after the reasoning, got %+v", got.Content[1])
+	}
+	if len(cont.Content) != 2 {
+		t.Error("cont was changed")
+	}
+}
+
+func TestRunChatTurnWithToolsCountsResumes(t *testing.T) {
+	calls := 0
+	send := func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		calls++
+		if calls == 1 {
+			return brokenChannel("The answer"), nil
+		}
+		return textOnlyChannel(" is 42."), nil
+	}
+	discard := func(context.Context, string) error { return nil }
+	input := &bedrockruntime.ConverseStreamInput{Messages: []types.Message{userTextMessage("?")}}
+
+	result, err := runChatTurnWithTools(t.Context(), send, input, tools.NewRegistry(), nil, discard, discard, chatTurnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Text != "The answer is 42." || result.Resumes != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	// the conversation keeps one stitched reply
+	if len(input.Messages) != 2 || messageText(input.Messages[1]) != "The answer is 42." {
+		t.Errorf("messages = %+v", input.Messages)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/chat-cli/chat-cli/templates"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// templatesCmd represents the templates command
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage and run prompt templates, and sync them with Bedrock Prompt Management",
+	Long: `Prompt templates are reusable prompts with {{variable}} placeholders, stored in
+chat-cli's config directory.
+
+> chat-cli templates create review --text "Review {{file}} for bugs."
+> chat-cli templates run review --var file="$(cat main.go)"
+
+Templates can be kept in sync with Amazon Bedrock Prompt Management:
+
+> chat-cli templates push review
+> chat-cli templates pull
+
+Once pushed, a template can be run by its ARN with variables filled in:
+
+> chat-cli prompt --prompt-arn <arn> --var file=main.go`,
+}
+
+// templatesPushCmd represents the templates push command
+var templatesPushCmd = &cobra.Command{
+	Use:   "push [name...]",
+	Short: "Create or update managed prompts from local templates (all if none are named)",
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, store, client := templatesSetup(cmd)
+
+		modelIdFlag, err := cmd.Flags().GetString("model-id")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+		defaultModel := fm.GetConfigValue("model-id", modelIdFlag, DefaultModelID).(string)
+
+		var local []templates.Template
+		if len(args) == 0 {
+			if local, err = store.List(); err != nil {
+				log.Fatal(err)
+			}
+			if len(local) == 0 {
+				fmt.Println("No local templates to push.")
+				return
+			}
+		} else {
+			for _, name := range args {
+				t, loadErr := store.Load(name)
+				if loadErr != nil {
+					log.Fatalf("%s: %v", name, loadErr)
+				}
+				local = append(local, *t)
+			}
+		}
+
+		ctx := cmd.Context()
+		managed, err := client.listPrompts(ctx)
+		if err != nil {
+			log.Fatalf("unable to list managed prompts: %v", err)
+		}
+
+		failed := false
+		for i := range local {
+			t := &local[i]
+			versionArn, pushErr := pushTemplate(ctx, client, t, defaultModel, managed)
+			if pushErr != nil {
+				log.Printf("%s: %v", t.Name, pushErr)
+				failed = true
+				continue
+			}
+			if saveErr := store.Save(t); saveErr != nil {
+				log.Printf("%s: pushed, but unable to record the prompt ARN locally: %v", t.Name, saveErr)
+			}
+			fmt.Printf("Pushed %s -> %s\n", t.Name, versionArn)
+		}
+
+		if failed {
+			log.Fatal("some templates could not be pushed")
+		}
+	},
+}
+
+// templatesPullCmd represents the templates pull command
+var templatesPullCmd = &cobra.Command{
+	Use:   "pull [name|id|arn...]",
+	Short: "Save managed prompts as local templates (all if none are named)",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, store, client := templatesSetup(cmd)
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		ctx := cmd.Context()
+		managed, err := client.listPrompts(ctx)
+		if err != nil {
+			log.Fatalf("unable to list managed prompts: %v", err)
+		}
+
+		identifiers := args
+		if len(identifiers) == 0 {
+			for _, p := range managed {
+				identifiers = append(identifiers, p.Id)
+			}
+			if len(identifiers) == 0 {
+				fmt.Println("No managed prompts to pull.")
+				return
+			}
+		}
+
+		failed := false
+		for _, identifier := range identifiers {
+			t, pullErr := pullPrompt(ctx, client, identifier, managed)
+			if pullErr == nil {
+				pullErr = saveLinkedTemplate(store, t, force)
+			}
+			if pullErr != nil {
+				log.Printf("%s: %v", identifier, pullErr)
+				failed = true
+				continue
+			}
+			fmt.Printf("Pulled %s from %s\n", t.Name, t.PromptARN)
+		}
+
+		if failed {
+			log.Fatal("some prompts could not be pulled")
+		}
+	},
+}
+
+// templatesSetup loads config and returns the local template store and a
+// Prompt Management client for the selected region.
+func templatesSetup(cmd *cobra.Command) (*conf.FileManager, *templates.Store, *promptManagementClient) {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+
+	region, err := cmd.Flags().GetString("region")
+	if err != nil {
+		log.Fatalf("unable to get flag: %v", err)
+	}
+
+	cfg, err := loadAWSConfig(cmd.Context(), region)
+	if err != nil {
+		log.Fatalf("unable to load AWS config: %v", err)
+	}
+
+	return fm, templates.NewStore(fm.ConfigPath), newPromptManagementClient(cfg)
+}
+
+// pushTemplate creates or updates the managed prompt for t and publishes a
+// new version of it, returning that version's ARN. The prompt is found by
+// t.PromptARN, or failing that by name among managed. On success
+// t.PromptARN and t.ModelID record what was pushed.
+func pushTemplate(ctx context.Context, client *promptManagementClient, t *templates.Template, defaultModel string, managed []managedPromptSummary) (string, error) {
+	modelID := t.ModelID
+	if modelID == "" {
+		modelID = defaultModel
+	}
+
+	var inputs []promptInputVariable
+	for _, name := range templates.Variables(t.Text) {
+		inputs = append(inputs, promptInputVariable{Name: name})
+	}
+
+	prompt := &managedPrompt{
+		Name:           t.Name,
+		Description:    t.Description,
+		DefaultVariant: managedPromptVariant,
+		Variants: []promptVariant{{
+			Name:         managedPromptVariant,
+			TemplateType: "TEXT",
+			ModelId:      modelID,
+			TemplateConfiguration: promptTemplateConfiguration{
+				Text: &textPromptTemplate{Text: t.Text, InputVariables: inputs},
+			},
+		}},
+	}
+
+	id, _, _ := parsePromptARN(t.PromptARN)
+	if id == "" {
+		for _, p := range managed {
+			if p.Name == t.Name {
+				id = p.Id
+				break
+			}
+		}
+	}
+
+	var saved *managedPrompt
+	var err error
+	if id == "" {
+		saved, err = client.createPrompt(ctx, prompt)
+	} else {
+		saved, err = client.updatePrompt(ctx, id, prompt)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	versionArn, err := client.createPromptVersion(ctx, saved.Id)
+	if err != nil {
+		return "", fmt.Errorf("prompt saved as a draft, but no version could be created: %w", err)
+	}
+
+	t.PromptARN = unversionedPromptARN(saved.Arn)
+	t.ModelID = modelID
+	return versionArn, nil
+}
+
+// pullPrompt fetches the managed prompt named by identifier - an ARN
+// (optionally versioned), a prompt name from managed, or a prompt ID - and
+// converts it to a template.
+func pullPrompt(ctx context.Context, client *promptManagementClient, identifier string, managed []managedPromptSummary) (*templates.Template, error) {
+	id, version, ok := parsePromptARN(identifier)
+	if !ok {
+		id = identifier
+		for _, p := range managed {
+			if p.Name == identifier {
+				id = p.Id
+				break
+			}
+		}
+	}
+
+	prompt, err := client.getPrompt(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	variant, err := prompt.variant()
+	if err != nil {
+		return nil, err
+	}
+	if variant.TemplateConfiguration.Text == nil {
+		return nil, fmt.Errorf("prompt %s uses a %s template; only TEXT prompts can be pulled", prompt.Name, variant.TemplateType)
+	}
+
+	return &templates.Template{
+		Name:        prompt.Name,
+		Description: prompt.Description,
+		Text:        variant.TemplateConfiguration.Text.Text,
+		ModelID:     variant.ModelId,
+		PromptARN:   unversionedPromptARN(prompt.Arn),
+	}, nil
+}
+
+// saveLinkedTemplate stores a pulled template. It refuses to replace a
+// local template of the same name that isn't linked to the same managed
+// prompt, unless force is set, so a pull never silently clobbers unrelated
+// work.
+func saveLinkedTemplate(store *templates.Store, t *templates.Template, force bool) error {
+	existing, err := store.Load(t.Name)
+	switch {
+	case errors.Is(err, templates.ErrTemplateNotFound):
+	case err != nil:
+		return err
+	case !force && existing.PromptARN != t.PromptARN:
+		return fmt.Errorf("a local template named %s already exists and isn't linked to this prompt (use --force to replace it)", t.Name)
+	}
+
+	return store.Save(t)
+}
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+	templatesCmd.AddCommand(templatesPushCmd)
+	templatesCmd.AddCommand(templatesPullCmd)
+
+	templatesPullCmd.Flags().BoolP("force", "f", false, "replace local templates that aren't linked to the pulled prompt")
+}
@@ -0,0 +1,291 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chat-cli/chat-cli/templates"
+	"github.com/chat-cli/chat-cli/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// templatesCreateCmd represents the templates create command
+var templatesCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Save a prompt template",
+	Long: `Save a prompt template. The text comes from --text, or from stdin:
+
+> chat-cli templates create review --text "Review {{file}} for bugs."
+> cat review-prompt.md | chat-cli templates create review --description "Code review"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		text, err := cmd.Flags().GetString("text")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		description, err := cmd.Flags().GetString("description")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		if text == "" {
+			if text, err = utils.ReadPipedStdin(); err != nil {
+				log.Fatalf("unable to read stdin: %v", err)
+			}
+		}
+		if strings.TrimSpace(text) == "" {
+			log.Fatal("no template text: pass --text or pipe it in")
+		}
+
+		t, err := createTemplate(templateStore(), name, text, description, force)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Saved template %s", t.Name)
+		if vars := templates.Variables(t.Text); len(vars) > 0 {
+			fmt.Printf(" (variables: %s)", strings.Join(vars, ", "))
+		}
+		fmt.Println()
+	},
+}
+
+// templatesListCmd represents the templates list command
+var templatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved prompt templates",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		list, err := templateStore().List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(list) == 0 {
+			fmt.Println("No templates yet. Create one with: chat-cli templates create <name> --text \"...\"")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVARIABLES\tDESCRIPTION")
+		for _, t := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, strings.Join(templates.Variables(t.Text), ", "), t.Description)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing output: %v", err)
+		}
+	},
+}
+
+// templatesShowCmd represents the templates show command
+var templatesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a prompt template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		t, err := loadTemplate(templateStore(), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Name: %s\n", t.Name)
+		if t.Description != "" {
+			fmt.Printf("Description: %s\n", t.Description)
+		}
+		if vars := templates.Variables(t.Text); len(vars) > 0 {
+			fmt.Printf("Variables: %s\n", strings.Join(vars, ", "))
+		}
+		if t.PromptARN != "" {
+			fmt.Printf("Managed prompt: %s\n", t.PromptARN)
+		}
+		fmt.Printf("\n%s\n", strings.TrimRight(t.Text, "\n"))
+	},
+}
+
+// templatesDeleteCmd represents the templates delete command
+var templatesDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a prompt template",
+	Long: `Delete a local prompt template. A managed prompt it was pushed to is left
+in place.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		store := templateStore()
+		if _, err := loadTemplate(store, name); err != nil {
+			log.Fatal(err)
+		}
+
+		if !force && !confirmAction(os.Stdin, os.Stdout, fmt.Sprintf("Delete template %s?", name)) {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		if err := store.Delete(name); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted template %s\n", name)
+	},
+}
+
+// templatesRunCmd represents the templates run command
+var templatesRunCmd = &cobra.Command{
+	Use:   "run <name> [text]",
+	Short: "Fill in a prompt template and send it",
+	Long: `Fill in a template's variables with --var and send it, exactly as
+"chat-cli prompt --template <name>" does. Any text given after the name is
+sent after the template.
+
+> chat-cli templates run review --var file="$(cat main.go)"
+> chat-cli templates run review --var file="$(cat main.go)" "Focus on error handling."`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// the prompt command does the work; pass on the flags it shares
+		// with this one
+		if err := promptCmd.PersistentFlags().Set("template", args[0]); err != nil {
+			log.Fatal(err)
+		}
+		if err := copyChangedFlags(cmd.Flags(), promptCmd.PersistentFlags()); err != nil {
+			log.Fatal(err)
+		}
+
+		promptCmd.Run(promptCmd, args[1:])
+	},
+}
+
+// templateStore loads config and returns the local template store.
+func templateStore() *templates.Store {
+	fm, err := conf.NewFileManager("chat-cli")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if initErr := fm.InitializeViper(); initErr != nil {
+		log.Fatal(initErr)
+	}
+
+	return templates.NewStore(fm.ConfigPath)
+}
+
+// loadTemplate loads the named template, with an error that says how to
+// find the right name when there isn't one.
+func loadTemplate(store *templates.Store, name string) (*templates.Template, error) {
+	t, err := store.Load(name)
+	if errors.Is(err, templates.ErrTemplateNotFound) {
+		return nil, fmt.Errorf("no template named %s (see chat-cli templates list)", name)
+	}
+	return t, err
+}
+
+// createTemplate saves a new template. An existing template with the same
+// name is only replaced when force is set; its link to a managed prompt is
+// kept, so the next push updates the same prompt.
+func createTemplate(store *templates.Store, name, text, description string, force bool) (*templates.Template, error) {
+	t := &templates.Template{Name: name, Description: description, Text: text}
+
+	existing, err := store.Load(name)
+	switch {
+	case errors.Is(err, templates.ErrTemplateNotFound):
+	case err != nil:
+		return nil, err
+	case !force:
+		return nil, fmt.Errorf("a template named %s already exists (use --force to replace it)", name)
+	default:
+		t.ModelID = existing.ModelID
+		t.PromptARN = existing.PromptARN
+	}
+
+	if err := store.Save(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// renderTemplatePrompt fills in the named template from --var flags, with
+// extra (the prompt argument, if any) sent after it.
+func renderTemplatePrompt(store *templates.Store, name string, varFlags []string, extra string) (string, error) {
+	t, err := loadTemplate(store, name)
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := parseVarFlags(varFlags)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := templates.Render(t.Text, vars)
+	if err != nil {
+		return "", fmt.Errorf("template %s: %w", name, err)
+	}
+
+	if extra != "" {
+		text += "\n\n" + extra
+	}
+	return text, nil
+}
+
+// copyChangedFlags sets each flag given on the command line in from on the
+// flag of the same name in to, if it has one.
+func copyChangedFlags(from, to *pflag.FlagSet) error {
+	var err error
+	from.Visit(func(f *pflag.Flag) {
+		target := to.Lookup(f.Name)
+		if target == nil || err != nil {
+			return
+		}
+
+		values := []string{f.Value.String()}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		for _, value := range values {
+			if err = target.Value.Set(value); err != nil {
+				err = fmt.Errorf("--%s: %w", f.Name, err)
+				return
+			}
+		}
+		target.Changed = true
+	})
+	return err
+}
+
+func init() {
+	templatesCmd.AddCommand(templatesCreateCmd)
+	templatesCmd.AddCommand(templatesListCmd)
+	templatesCmd.AddCommand(templatesShowCmd)
+	templatesCmd.AddCommand(templatesDeleteCmd)
+	templatesCmd.AddCommand(templatesRunCmd)
+
+	templatesCreateCmd.Flags().StringP("text", "t", "", "the template text, with {{variable}} placeholders (read from stdin if not given)")
+	templatesCreateCmd.Flags().String("description", "", "a short description, shown by templates list")
+	templatesCreateCmd.Flags().BoolP("force", "f", false, "replace an existing template with the same name")
+
+	templatesDeleteCmd.Flags().BoolP("force", "f", false, "delete without asking for confirmation")
+
+	templatesRunCmd.Flags().StringArray("var", nil, "set a template variable as key=value (repeatable)")
+	templatesRunCmd.Flags().Bool("no-stream", false, "return the full response once it has completed")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/templates"
+	"github.com/spf13/pflag"
+)
+
+func TestCreateTemplate(t *testing.T) {
+	store := templates.NewStore(t.TempDir())
+	if err := store.Save(&templates.Template{Name: "review", Text: "old", PromptARN: "arn:aws:bedrock:us-east-1:123456789012:prompt/ABC123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := createTemplate(store, "review", "new", "", false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("expected an already exists error, got %v", err)
+	}
+
+	saved, err := createTemplate(store, "review", "Review {{file}}", "Code review", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Text != "Review {{file}}" || saved.Description != "Code review" {
+		t.Errorf("unexpected template %+v", saved)
+	}
+	if saved.PromptARN == "" {
+		t.Error("expected the managed prompt link to be kept")
+	}
+
+	if _, err := createTemplate(store, "../escape", "x", "", false); err == nil {
+		t.Error("expected an invalid name error")
+	}
+}
+
+func TestRenderTemplatePrompt(t *testing.T) {
+	store := templates.NewStore(t.TempDir())
+	if err := store.Save(&templates.Template{Name: "review", Text: "Review {{file}}."}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := renderTemplatePrompt(store, "review", []string{"file=main.go"}, "Be brief.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Review main.go.\n\nBe brief." {
+		t.Errorf("unexpected prompt %q", got)
+	}
+
+	if _, err := renderTemplatePrompt(store, "missing", nil, ""); err == nil || !strings.Contains(err.Error(), "templates list") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if _, err := renderTemplatePrompt(store, "review", nil, ""); err == nil || !strings.Contains(err.Error(), "missing values for file") {
+		t.Errorf("expected a missing value error, got %v", err)
+	}
+	if _, err := renderTemplatePrompt(store, "review", []string{"file"}, ""); err == nil {
+		t.Error("expected an invalid --var error")
+	}
+}
+
+func TestCopyChangedFlags(t *testing.T) {
+	from := pflag.NewFlagSet("from", pflag.ContinueOnError)
+	from.StringArray("var", nil, "")
+	from.String("model-id", "default", "")
+	from.Bool("dry-run", false, "")
+	from.String("chat-id", "", "")
+	if err := from.Parse([]string{"--var", "a=1", "--var", "b=2", "--dry-run", "--chat-id", "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	to := pflag.NewFlagSet("to", pflag.ContinueOnError)
+	to.StringArray("var", nil, "")
+	to.String("model-id", "default", "")
+	to.Bool("dry-run", false, "")
+
+	if err := copyChangedFlags(from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	if vars, _ := to.GetStringArray("var"); strings.Join(vars, ",") != "a=1,b=2" {
+		t.Errorf("unexpected vars %v", vars)
+	}
+	if dryRun, _ := to.GetBool("dry-run"); !dryRun {
+		t.Error("expected --dry-run to be copied")
+	}
+	if to.Changed("model-id") {
+		t.Error("expected unset flags to be left alone")
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/tools"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// The tickets settings give tool use a create_ticket tool that files
+// tickets in Jira or Linear.
+const (
+	ticketProviderKey = "tickets.provider"
+	ticketEndpointKey = "tickets.endpoint"
+	ticketTokenKey    = "tickets.token"
+	ticketUserKey     = "tickets.user"
+	ticketProjectKey  = "tickets.project"
+)
+
+// configuredTicketTool returns the create_ticket tool for the tickets
+// settings, or nil if no provider is set.
+func configuredTicketTool(fm *conf.FileManager) (*tools.CreateTicketTool, error) {
+	provider := configString(fm, ticketProviderKey)
+	if provider == "" {
+		return nil, nil
+	}
+	token, err := configSecret(fm, ticketTokenKey)
+	if err != nil {
+		return nil, err
+	}
+	tool, err := tools.NewCreateTicketTool(tools.TicketConfig{
+		Provider: provider,
+		Endpoint: configString(fm, ticketEndpointKey),
+		Token:    token,
+		User:     configString(fm, ticketUserKey),
+		Project:  configString(fm, ticketProjectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid tickets settings: %w", err)
+	}
+	return tool, nil
+}
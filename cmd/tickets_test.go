@@ -0,0 +1,39 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+func TestConfiguredTicketTool(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	fm := &conf.FileManager{}
+
+	if tool, err := configuredTicketTool(fm); tool != nil || err != nil {
+		t.Errorf("expected no tool without a provider, got %v, %v", tool, err)
+	}
+
+	viper.Set(ticketProviderKey, "jira")
+	viper.Set(ticketTokenKey, "secret")
+	viper.Set(ticketProjectKey, "OPS")
+	if _, err := configuredTicketTool(fm); err == nil || !strings.Contains(err.Error(), "invalid tickets settings") {
+		t.Errorf("expected a Jira provider without an endpoint to be refused, got %v", err)
+	}
+
+	viper.Set(ticketEndpointKey, "https://my-org.atlassian.net")
+	tool, err := configuredTicketTool(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool.Name() != "create_ticket" || !strings.Contains(tool.Description(), "Jira project OPS") {
+		t.Errorf("unexpected tool %s: %s", tool.Name(), tool.Description())
+	}
+}
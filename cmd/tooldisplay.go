@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+// maxToolDisplayLen caps how much of a tool call's input or error is
+// echoed on one line.
+const maxToolDisplayLen = 80
+
+// toolCallLine renders a tool invocation on one line, e.g.
+// `→ read_file {"path":"go.mod"}`.
+func toolCallLine(call tools.ToolCall) string {
+	return "→ " + call.Name + " " + oneLine(string(call.Input))
+}
+
+// toolResultLine renders a tool result on one line: its size on success,
+// or the first line of the error.
+func toolResultLine(result types.ToolResultBlock) string {
+	text := toolResultText(result)
+
+	if result.Status == types.ToolResultStatusError {
+		firstLine, _, _ := strings.Cut(text, "\n")
+		return "← error: " + oneLine(firstLine)
+	}
+
+	return fmt.Sprintf("← ok (%s)", formatByteSize(len(text)))
+}
+
+// oneLine collapses whitespace in s and truncates it to maxToolDisplayLen
+// characters.
+func oneLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > maxToolDisplayLen {
+		// cutting bytes could split a multi-byte character
+		s = truncateRunes(s, maxToolDisplayLen) + "…"
+	}
+	return s
+}
+
+func formatByteSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d bytes", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
+
+// toolResultText is the text of a tool result's content.
+func toolResultText(result types.ToolResultBlock) string {
+	var text strings.Builder
+	for _, block := range result.Content {
+		if textBlock, ok := block.(*types.ToolResultContentBlockMemberText); ok {
+			text.WriteString(textBlock.Value)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func TestToolCallLine(t *testing.T) {
+	got := toolCallLine(tools.ToolCall{Name: "read_file", Input: json.RawMessage("{\n  \"path\": \"go.mod\"\n}")})
+	if got != `→ read_file { "path": "go.mod" }` {
+		t.Errorf("unexpected line %q", got)
+	}
+
+	long := toolCallLine(tools.ToolCall{Name: "write_file", Input: json.RawMessage(`{"content":"` + strings.Repeat("x", 500) + `"}`)})
+	if !strings.HasSuffix(long, "…") || len(long) > 120 {
+		t.Errorf("expected long input to be truncated, got %d bytes", len(long))
+	}
+
+	accented := toolCallLine(tools.ToolCall{Name: "write_file", Input: json.RawMessage(`{"content":"x` + strings.Repeat("é", 500) + `"}`)})
+	if !utf8.ValidString(accented) || !strings.HasSuffix(accented, "é…") {
+		t.Errorf("expected input to be truncated between characters, got %q", accented)
+	}
+}
+
+func TestToolResultLine(t *testing.T) {
+	textResult := func(status types.ToolResultStatus, text string) types.ToolResultBlock {
+		return types.ToolResultBlock{
+			Status:  status,
+			Content: []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: text}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		result types.ToolResultBlock
+		want   string
+	}{
+		{"small success", textResult(types.ToolResultStatusSuccess, "hello"), "← ok (5 bytes)"},
+		{"kilobytes", textResult(types.ToolResultStatusSuccess, strings.Repeat("x", 1536)), "← ok (1.5 KB)"},
+		{"error shows first line", textResult(types.ToolResultStatusError, "[not_found] file does not exist: x\nCheck the path"), "← error: [not_found] file does not exist: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toolResultLine(tt.result); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -6,7 +6,11 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -48,6 +52,9 @@ type blockAccumulator struct {
 	reasoningSignature string
 }
 
+// streamWarnings is where accumulateStream reports the events it skipped.
+var streamWarnings io.Writer = os.Stderr
+
 // accumulateStream drains a Bedrock ConverseStream event channel, invoking
 // onText for each text delta as it arrives (same behavior as
 // utils.ProcessStreamingOutput), and finalizes any tool-use blocks
@@ -55,11 +62,14 @@ type blockAccumulator struct {
 // conversation history), any finalized tool calls (in stream order), the
 // stop reason, and an error only for malformed tool-input JSON (Rule 4) -
 // never for an unknown tool, which is Registry.Dispatch's job (Rule 2).
-func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReasoning utils.StreamingOutputHandler) (types.Message, []tools.ToolCall, types.StopReason, error) {
+func accumulateStream(ctx context.Context, events <-chan types.ConverseStreamOutput, onText, onReasoning utils.StreamingOutputHandler) (types.Message, []tools.ToolCall, types.StopReason, error) {
 	blocks := make(map[int32]*blockAccumulator)
 	var order []int32
 	var stopReason types.StopReason
 
+	var skipped utils.SkippedEvents
+	defer skipped.Warn(streamWarnings)
+
 	for event := range events {
 		switch v := event.(type) {
 		case *types.ConverseStreamOutputMemberContentBlockStart:
@@ -71,9 +81,20 @@ func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReason
 					toolUseID: aws.ToString(toolStart.Value.ToolUseId),
 				}
 				order = append(order, idx)
+			} else if v.Value.Start != nil {
+				skipped.Record(v.Value.Start)
 			}
 
 		case *types.ConverseStreamOutputMemberContentBlockDelta:
+			switch v.Value.Delta.(type) {
+			case *types.ContentBlockDeltaMemberText, *types.ContentBlockDeltaMemberToolUse, *types.ContentBlockDeltaMemberReasoningContent:
+			default:
+				// a delta this version can't handle doesn't start a block,
+				// which would otherwise be sent back as empty text
+				skipped.Record(v.Value.Delta)
+				continue
+			}
+
 			idx := aws.ToInt32(v.Value.ContentBlockIndex)
 			acc, ok := blocks[idx]
 			if !ok {
@@ -92,7 +113,7 @@ func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReason
 			switch delta := v.Value.Delta.(type) {
 			case *types.ContentBlockDeltaMemberText:
 				acc.text.WriteString(delta.Value)
-				if err := onText(context.Background(), delta.Value); err != nil {
+				if err := onText(ctx, delta.Value); err != nil {
 					return types.Message{}, nil, "", fmt.Errorf("handler error: %w", err)
 				}
 			case *types.ContentBlockDeltaMemberToolUse:
@@ -101,11 +122,13 @@ func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReason
 				switch reasoningDelta := delta.Value.(type) {
 				case *types.ReasoningContentBlockDeltaMemberText:
 					acc.reasoningText.WriteString(reasoningDelta.Value)
-					if err := onReasoning(context.Background(), reasoningDelta.Value); err != nil {
+					if err := onReasoning(ctx, reasoningDelta.Value); err != nil {
 						return types.Message{}, nil, "", fmt.Errorf("handler error: %w", err)
 					}
 				case *types.ReasoningContentBlockDeltaMemberSignature:
 					acc.reasoningSignature = reasoningDelta.Value
+				case *types.UnknownUnionMember:
+					skipped.Record(reasoningDelta)
 				// ReasoningContentBlockDeltaMemberRedactedContent is preserved
 				// implicitly (nothing to accumulate into visible text) - it's
 				// encrypted bytes, not rendered per Rule 5.
@@ -115,6 +138,17 @@ func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReason
 
 		case *types.ConverseStreamOutputMemberMessageStop:
 			stopReason = v.Value.StopReason
+
+		case *types.ConverseStreamOutputMemberMessageStart,
+			*types.ConverseStreamOutputMemberContentBlockStop,
+			*types.ConverseStreamOutputMemberMetadata:
+			// the role is always assistant, and usage is read by
+			// meteredSend
+
+		default:
+			// includes *types.UnknownUnionMember, for events newer than
+			// the SDK
+			skipped.Record(event)
 		}
 	}
 
@@ -151,6 +185,37 @@ func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReason
 	return types.Message{Role: types.ConversationRoleAssistant, Content: content}, toolCalls, stopReason, nil
 }
 
+// chatTurnResult is the outcome of one user turn through
+// runChatTurnWithTools.
+type chatTurnResult struct {
+	// Text is the model's final text response.
+	Text string
+	// StopReason is why the final response ended.
+	StopReason types.StopReason
+	// Resumes counts the times a response stream broke off during the turn
+	// and was continued; see streamResponse.
+	Resumes int
+	// Writes maps each file the model successfully wrote with write_file
+	// during the turn to the content it last wrote there.
+	Writes map[string]string
+}
+
+// chatTurnOptions tunes how runChatTurnWithTools drives a turn.
+type chatTurnOptions struct {
+	// WorkerModelID, when set, is a cheaper model used for the follow-up
+	// requests after tool results - the routine "which tool next" steps -
+	// while input.ModelId still handles the first request and the final
+	// answer. See runChatTurnWithTools.
+	WorkerModelID string
+
+	// OnToolCall, when set, is called before each tool call is dispatched,
+	// so callers can show the steps being taken.
+	OnToolCall func(call tools.ToolCall)
+	// OnToolResult, when set, is called with each tool call's result
+	// before it's sent back to the model.
+	OnToolResult func(call tools.ToolCall, result types.ToolResultBlock)
+}
+
 // runChatTurnWithTools sends input via send, processes the response (text
 // via onText, tool-use via registry), and - per the algorithm in
 // functional-design/business-logic-model.md - loops on StopReasonToolUse
@@ -158,6 +223,14 @@ func accumulateStream(events <-chan types.ConverseStreamOutput, onText, onReason
 // exceeded. input.Messages is mutated in place to build up the full
 // conversation, including any intermediate tool-use/tool-result exchanges,
 // exactly as Bedrock requires for context continuity within the turn.
+//
+// With opts.WorkerModelID set, requests that follow tool results go to the
+// worker model and its text is held back. If the worker asks for more
+// tools, its text is released and the loop continues; if it tries to give
+// the final answer instead, that response is discarded and the same
+// request is re-sent to the main model, so the user always gets the final
+// synthesis from the stronger model. A worker request that fails disables
+// the worker for the rest of the turn.
 func runChatTurnWithTools(
 	ctx context.Context,
 	send converseStreamFunc,
@@ -166,53 +239,199 @@ func runChatTurnWithTools(
 	gate tools.PermissionGate,
 	onText utils.StreamingOutputHandler,
 	onReasoning utils.StreamingOutputHandler,
-) (string, error) {
+	opts chatTurnOptions,
+) (chatTurnResult, error) {
 	input.ToolConfig = registry.ToolConfiguration()
 
+	// repeated identical reads within this turn are answered from cache
+	cache := tools.NewResultCache()
+
+	result := chatTurnResult{Writes: map[string]string{}}
+
+	workerEnabled := opts.WorkerModelID != ""
+	useMainModel := true
+
 	roundTrips := 0
 	for {
-		events, err := send(ctx, input)
-		if err != nil {
-			return "", err
+		if workerEnabled && !useMainModel {
+			assistantMsg, toolCalls, outcome := runWorkerStep(ctx, send, input, opts.WorkerModelID, onText)
+			if outcome != workerRequestedTools {
+				// the main model takes this step instead, and gives the
+				// final answer
+				workerEnabled = outcome != workerFailed
+				useMainModel = true
+				continue
+			}
+
+			input.Messages = append(input.Messages, assistantMsg)
+			if err := checkRoundTrips(&roundTrips); err != nil {
+				return chatTurnResult{}, err
+			}
+			input.Messages = append(input.Messages, dispatchToolCalls(ctx, registry, gate, cache, toolCalls, result.Writes, opts))
+			continue
 		}
 
-		assistantMsg, toolCalls, stopReason, err := accumulateStream(events, onText, onReasoning)
+		assistantMsg, toolCalls, stopReason, resumes, err := streamResponse(ctx, send, input, onText, onReasoning)
+		result.Resumes += resumes
+		if err == nil && ctx.Err() != nil {
+			// the stream ended because the turn was cancelled; what
+			// arrived is returned for the caller to keep
+			err = ctx.Err()
+		}
 		if err != nil {
-			return "", err
+			if ctx.Err() != nil {
+				return chatTurnResult{Text: messageText(assistantMsg), StopReason: stopReasonInterrupted, Writes: result.Writes}, err
+			}
+			return chatTurnResult{}, err
 		}
 
 		input.Messages = append(input.Messages, assistantMsg)
 
 		if stopReason != types.StopReasonToolUse {
-			finalText := ""
-			for _, block := range assistantMsg.Content {
-				if textBlock, ok := block.(*types.ContentBlockMemberText); ok {
-					finalText += textBlock.Value
-				}
-			}
-			return finalText, nil
+			result.Text = messageText(assistantMsg)
+			result.StopReason = stopReason
+			return result, nil
+		}
+
+		if err := checkRoundTrips(&roundTrips); err != nil {
+			return chatTurnResult{}, err
 		}
+		input.Messages = append(input.Messages, dispatchToolCalls(ctx, registry, gate, cache, toolCalls, result.Writes, opts))
+		useMainModel = false
+	}
+}
+
+// checkRoundTrips counts one tool-use round trip and errors once the turn
+// exceeds maxToolRoundTrips.
+func checkRoundTrips(roundTrips *int) error {
+	*roundTrips++
+	if *roundTrips > maxToolRoundTrips {
+		return fmt.Errorf("stopped after %d tool calls in a single turn to avoid a runaway loop - you can ask a follow-up to continue", maxToolRoundTrips)
+	}
+	return nil
+}
 
-		roundTrips++
-		if roundTrips > maxToolRoundTrips {
-			return "", fmt.Errorf("stopped after %d tool calls in a single turn to avoid a runaway loop - you can ask a follow-up to continue", maxToolRoundTrips)
+// dispatchToolCalls runs each of a response's tool calls and returns the
+// user message carrying their results, recording successful writes.
+func dispatchToolCalls(ctx context.Context, registry *tools.Registry, gate tools.PermissionGate, cache *tools.ResultCache, toolCalls []tools.ToolCall, writes map[string]string, opts chatTurnOptions) types.Message {
+	var resultContent []types.ContentBlock
+	for _, call := range toolCalls {
+		if opts.OnToolCall != nil {
+			opts.OnToolCall(call)
 		}
 
-		var resultContent []types.ContentBlock
-		for _, call := range toolCalls {
-			var result types.ToolResultBlock
-			if call.InputParseErr != nil {
-				result = toolParseErrorResult(call.ToolUseID, call.InputParseErr)
-			} else {
-				result = registry.Dispatch(ctx, call, gate)
-			}
-			resultContent = append(resultContent, &types.ContentBlockMemberToolResult{Value: result})
+		var toolResult types.ToolResultBlock
+		if call.InputParseErr != nil {
+			toolResult = toolParseErrorResult(call.ToolUseID, call.InputParseErr)
+		} else {
+			toolResult = registry.DispatchCached(ctx, call, gate, cache)
+		}
+		if toolResult.Status == types.ToolResultStatusSuccess {
+			recordWrite(writes, call)
+		}
+		if opts.OnToolResult != nil {
+			opts.OnToolResult(call, toolResult)
 		}
-		input.Messages = append(input.Messages, types.Message{
-			Role:    types.ConversationRoleUser,
-			Content: resultContent,
-		})
+		resultContent = append(resultContent, &types.ContentBlockMemberToolResult{Value: toolResult})
 	}
+
+	return types.Message{
+		Role:    types.ConversationRoleUser,
+		Content: resultContent,
+	}
+}
+
+// workerOutcome is how a worker-model step ended.
+type workerOutcome int
+
+const (
+	// workerRequestedTools: the worker's response should be kept and its
+	// tool calls run.
+	workerRequestedTools workerOutcome = iota
+	// workerAnswered: the worker tried to give the final answer, which is
+	// the main model's job; its response was discarded.
+	workerAnswered
+	// workerFailed: the worker request failed.
+	workerFailed
+)
+
+// maxWorkerPreamble is how much text the worker model may write before
+// calling a tool. A worker that writes more is taken to be answering,
+// which is the main model's job, so its stream is cut off rather than
+// generated in full and thrown away.
+const maxWorkerPreamble = 1024
+
+// errWorkerAnswering stops a worker's stream once it passes
+// maxWorkerPreamble.
+var errWorkerAnswering = errors.New("worker model is answering")
+
+// runWorkerStep sends input to the worker model with its text held back,
+// releasing the text to onText only if the worker requested tools.
+func runWorkerStep(ctx context.Context, send converseStreamFunc, input *bedrockruntime.ConverseStreamInput, workerModelID string, onText utils.StreamingOutputHandler) (types.Message, []tools.ToolCall, workerOutcome) {
+	workerInput := *input
+	workerInput.ModelId = aws.String(workerModelID)
+	// reasoning settings are specific to the main model
+	workerInput.AdditionalModelRequestFields = nil
+
+	// cancelling the request's context stops Bedrock generating the rest
+	// of a response that's cut off
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := send(workerCtx, &workerInput)
+	if err != nil {
+		log.Printf("worker model %s failed, using the main model for the rest of this turn: %v", workerModelID, err)
+		return types.Message{}, nil, workerFailed
+	}
+
+	var held strings.Builder
+	hold := func(_ context.Context, part string) error {
+		held.WriteString(part)
+		if held.Len() > maxWorkerPreamble {
+			return errWorkerAnswering
+		}
+		return nil
+	}
+	discard := func(context.Context, string) error { return nil }
+
+	assistantMsg, toolCalls, stopReason, err := accumulateStream(workerCtx, events, hold, discard)
+	if errors.Is(err, errWorkerAnswering) {
+		return types.Message{}, nil, workerAnswered
+	}
+	if err != nil {
+		log.Printf("worker model %s failed, using the main model for the rest of this turn: %v", workerModelID, err)
+		return types.Message{}, nil, workerFailed
+	}
+
+	if stopReason != types.StopReasonToolUse {
+		return types.Message{}, nil, workerAnswered
+	}
+
+	if held.Len() > 0 {
+		if err := onText(ctx, held.String()); err != nil {
+			log.Printf("worker model %s output could not be displayed: %v", workerModelID, err)
+		}
+	}
+
+	return assistantMsg, toolCalls, workerRequestedTools
+}
+
+// recordWrite notes the path and content of a successful write_file call
+// in writes, so the turn's changes can be verified afterwards.
+func recordWrite(writes map[string]string, call tools.ToolCall) {
+	if call.Name != "write_file" {
+		return
+	}
+
+	var params struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(call.Input, &params); err != nil || params.Path == "" {
+		return
+	}
+
+	writes[params.Path] = params.Content
 }
 
 // finalizeToolCall parses a tool call's accumulated raw JSON input fragments
@@ -4,8 +4,10 @@ Copyright © 2024 Micah Walter
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"os"
 	"strings"
 	"testing"
 
@@ -42,7 +44,7 @@ func malformedWriteFileChannel(toolUseID string) <-chan types.ConverseStreamOutp
 }
 
 func TestAccumulateStream_MalformedToolInputDoesNotError(t *testing.T) {
-	_, toolCalls, stopReason, err := accumulateStream(
+	_, toolCalls, stopReason, err := accumulateStream(t.Context(),
 		malformedWriteFileChannel("write-1"),
 		func(context.Context, string) error { return nil },
 		func(context.Context, string) error { return nil },
@@ -61,6 +63,45 @@ func TestAccumulateStream_MalformedToolInputDoesNotError(t *testing.T) {
 	}
 }
 
+func TestAccumulateStream_SkipsUnhandledEvents(t *testing.T) {
+	var warnings bytes.Buffer
+	streamWarnings = &warnings
+	defer func() { streamWarnings = os.Stderr }()
+
+	ch := make(chan types.ConverseStreamOutput, 8)
+	ch <- &types.UnknownUnionMember{Tag: "accumulateTestEvent"}
+	ch <- &types.ConverseStreamOutputMemberContentBlockDelta{
+		Value: types.ContentBlockDeltaEvent{ContentBlockIndex: aws.Int32(0), Delta: &types.ContentBlockDeltaMemberText{Value: "Hello"}},
+	}
+	ch <- &types.ConverseStreamOutputMemberContentBlockDelta{
+		Value: types.ContentBlockDeltaEvent{ContentBlockIndex: aws.Int32(1), Delta: &types.UnknownUnionMember{Tag: "accumulateTestDelta"}},
+	}
+	ch <- &types.ConverseStreamOutputMemberContentBlockDelta{
+		Value: types.ContentBlockDeltaEvent{ContentBlockIndex: aws.Int32(1), Delta: &types.UnknownUnionMember{Tag: "accumulateTestDelta"}},
+	}
+	ch <- &types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonEndTurn}}
+	close(ch)
+
+	msg, _, stopReason, err := accumulateStream(t.Context(), ch,
+		func(context.Context, string) error { return nil },
+		func(context.Context, string) error { return nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopReason != types.StopReasonEndTurn {
+		t.Errorf("expected end_turn, got %v", stopReason)
+	}
+	// the unhandled delta mustn't leave an empty block behind
+	if len(msg.Content) != 1 || messageText(msg) != "Hello" {
+		t.Errorf("expected only the text block, got %#v", msg.Content)
+	}
+	want := "warning: skipped 3 stream events this version of chat-cli doesn't handle (accumulateTestDelta ×2, accumulateTestEvent ×1); upgrading may add support\n"
+	if warnings.String() != want {
+		t.Errorf("got %q, want %q", warnings.String(), want)
+	}
+}
+
 func TestRunChatTurnWithTools_MalformedToolInputRecovers(t *testing.T) {
 	callCount := 0
 	send := func(_ context.Context, _ *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
@@ -83,16 +124,20 @@ func TestRunChatTurnWithTools_MalformedToolInputRecovers(t *testing.T) {
 		nil,
 		func(context.Context, string) error { return nil },
 		func(context.Context, string) error { return nil },
+		chatTurnOptions{},
 	)
 	if err != nil {
 		t.Fatalf("expected recovery from malformed tool input, got: %v", err)
 	}
-	if !strings.Contains(result, "recovered after tool input error") {
-		t.Fatalf("expected model to continue after tool error, got %q", result)
+	if !strings.Contains(result.Text, "recovered after tool input error") {
+		t.Fatalf("expected model to continue after tool error, got %q", result.Text)
 	}
 	if callCount != 2 {
 		t.Fatalf("expected 2 round trips (error result + retry), got %d", callCount)
 	}
+	if result.StopReason != types.StopReasonEndTurn {
+		t.Errorf("expected the final response's stop reason, got %q", result.StopReason)
+	}
 }
 
 func TestFinalizeToolCall(t *testing.T) {
@@ -6,6 +6,10 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -84,12 +88,12 @@ func TestRunChatTurnWithTools_NoToolUse(t *testing.T) {
 
 	onReasoning := func(_ context.Context, _ string) error { return nil }
 
-	result, err := runChatTurnWithTools(context.Background(), send, input, registry, nil, onText, onReasoning)
+	result, err := runChatTurnWithTools(context.Background(), send, input, registry, nil, onText, onReasoning, chatTurnOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if result != "final answer" {
-		t.Errorf("expected 'final answer', got %q", result)
+	if result.Text != "final answer" {
+		t.Errorf("expected 'final answer', got %q", result.Text)
 	}
 	if callCount != 1 {
 		t.Errorf("expected send to be called exactly once, got %d", callCount)
@@ -110,7 +114,7 @@ func TestRunChatTurnWithTools_RoundTripCap(t *testing.T) {
 
 	onReasoning := func(_ context.Context, _ string) error { return nil }
 
-	_, err := runChatTurnWithTools(context.Background(), send, input, registry, nil, onText, onReasoning)
+	_, err := runChatTurnWithTools(context.Background(), send, input, registry, nil, onText, onReasoning, chatTurnOptions{})
 	if err == nil {
 		t.Fatal("expected an error when the round-trip cap is exceeded, got none")
 	}
@@ -121,3 +125,172 @@ func TestRunChatTurnWithTools_RoundTripCap(t *testing.T) {
 		t.Errorf("expected at least %d calls to send before the cap kicks in, got %d", maxToolRoundTrips, callCount)
 	}
 }
+
+func TestRunChatTurnWithTools_WorkerModel(t *testing.T) {
+	t.Run("worker handles tool steps, main model gives the final answer", func(t *testing.T) {
+		var models []string
+		send := func(_ context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+			model := aws.ToString(in.ModelId)
+			models = append(models, model)
+			switch {
+			case len(models) <= 2:
+				return toolUseChannel(fmt.Sprintf("call-%d", len(models))), nil
+			case model == "worker":
+				return textOnlyChannel("worker answer"), nil
+			default:
+				return textOnlyChannel("final answer"), nil
+			}
+		}
+
+		registry := tools.NewRegistry()
+		registry.Register(&fakeTurnTool{})
+		input := &bedrockruntime.ConverseStreamInput{ModelId: aws.String("main")}
+
+		var shown strings.Builder
+		onText := func(_ context.Context, part string) error {
+			shown.WriteString(part)
+			return nil
+		}
+		onReasoning := func(_ context.Context, _ string) error { return nil }
+
+		result, err := runChatTurnWithTools(context.Background(), send, input, registry, nil, onText, onReasoning, chatTurnOptions{WorkerModelID: "worker"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if want := []string{"main", "worker", "worker", "main"}; !reflect.DeepEqual(models, want) {
+			t.Errorf("expected models %v, got %v", want, models)
+		}
+		if result.Text != "final answer" {
+			t.Errorf("expected the main model's answer, got %q", result.Text)
+		}
+		if strings.Contains(shown.String(), "worker answer") {
+			t.Errorf("expected the worker's discarded answer to never be shown, got %q", shown.String())
+		}
+		if aws.ToString(input.ModelId) != "main" {
+			t.Errorf("expected the session's model to be unchanged, got %q", aws.ToString(input.ModelId))
+		}
+		// tool use + result from main, tool use + result from worker,
+		// final answer from main
+		if len(input.Messages) != 5 {
+			t.Errorf("expected 5 messages in history, got %d", len(input.Messages))
+		}
+	})
+
+	t.Run("failing worker falls back to the main model", func(t *testing.T) {
+		var models []string
+		mainCalls := 0
+		send := func(_ context.Context, in *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+			model := aws.ToString(in.ModelId)
+			models = append(models, model)
+			if model == "worker" {
+				return nil, errors.New("access denied")
+			}
+			mainCalls++
+			if mainCalls < 3 {
+				return toolUseChannel(fmt.Sprintf("call-%d", len(models))), nil
+			}
+			return textOnlyChannel("final answer"), nil
+		}
+
+		registry := tools.NewRegistry()
+		registry.Register(&fakeTurnTool{})
+		input := &bedrockruntime.ConverseStreamInput{ModelId: aws.String("main")}
+		noop := func(_ context.Context, _ string) error { return nil }
+
+		result, err := runChatTurnWithTools(context.Background(), send, input, registry, nil, noop, noop, chatTurnOptions{WorkerModelID: "worker"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Text != "final answer" {
+			t.Errorf("expected final answer, got %q", result.Text)
+		}
+		if want := []string{"main", "worker", "main", "main"}; !reflect.DeepEqual(models, want) {
+			t.Errorf("expected the worker to be tried once, got %v", models)
+		}
+	})
+}
+
+func TestRunWorkerStep_CutsOffAnAnsweringWorker(t *testing.T) {
+	const total = 1000
+	delta := strings.Repeat("x", 100)
+
+	sent := 0
+	done := make(chan struct{})
+	send := func(ctx context.Context, _ *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		ch := make(chan types.ConverseStreamOutput)
+		go func() {
+			defer close(done)
+			defer close(ch)
+			for sent < total {
+				select {
+				case ch <- &types.ConverseStreamOutputMemberContentBlockDelta{
+					Value: types.ContentBlockDeltaEvent{
+						ContentBlockIndex: aws.Int32(0),
+						Delta:             &types.ContentBlockDeltaMemberText{Value: delta},
+					},
+				}:
+					sent++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	}
+
+	var shown strings.Builder
+	onText := func(_ context.Context, part string) error {
+		shown.WriteString(part)
+		return nil
+	}
+
+	_, calls, outcome := runWorkerStep(context.Background(), send, &bedrockruntime.ConverseStreamInput{}, "worker", onText)
+	<-done
+
+	if outcome != workerAnswered {
+		t.Errorf("expected the worker to be treated as answering, got outcome %v", outcome)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected no tool calls, got %v", calls)
+	}
+	if shown.Len() != 0 {
+		t.Errorf("expected the worker's text to be held back, got %d bytes", shown.Len())
+	}
+	if limit := maxWorkerPreamble/len(delta) + 2; sent > limit {
+		t.Errorf("expected the worker stream to stop after at most %d deltas, got %d of %d", limit, sent, total)
+	}
+}
+
+func TestRunChatTurnWithTools_ReportsToolCallsAndResults(t *testing.T) {
+	callCount := 0
+	send := func(_ context.Context, _ *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		callCount++
+		if callCount == 1 {
+			return toolUseChannel("call-1"), nil
+		}
+		return textOnlyChannel("done"), nil
+	}
+
+	registry := tools.NewRegistry()
+	registry.Register(&fakeTurnTool{})
+	noop := func(_ context.Context, _ string) error { return nil }
+
+	var events []string
+	opts := chatTurnOptions{
+		OnToolCall: func(call tools.ToolCall) {
+			events = append(events, "call:"+call.Name)
+		},
+		OnToolResult: func(call tools.ToolCall, result types.ToolResultBlock) {
+			events = append(events, "result:"+call.Name+":"+string(result.Status))
+		},
+	}
+
+	if _, err := runChatTurnWithTools(context.Background(), send, &bedrockruntime.ConverseStreamInput{}, registry, nil, noop, noop, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"call:fake_tool", "result:fake_tool:success"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("expected %v, got %v", want, events)
+	}
+}
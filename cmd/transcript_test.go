@@ -0,0 +1,292 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+// The golden transcript tests replay recorded Bedrock responses through the
+// chat and prompt rendering code and compare what's printed with a golden
+// file, so changes to streaming and rendering show up as a diff. Each
+// testdata/transcripts/<name>.json fixture has a <name>.golden beside it;
+// after an intended change in output, rewrite them with
+//
+//	go test ./cmd -run TestGoldenTranscripts -update
+//
+// and review the diff.
+var updateGolden = flag.Bool("update", false, "rewrite the golden transcript files")
+
+// transcriptFixture is one recorded exchange.
+type transcriptFixture struct {
+	// Pipeline is chat, prompt, or prompt-json (prompt --output json).
+	Pipeline string `json:"pipeline"`
+	Model    string `json:"model"`
+	Prompt   string `json:"prompt"`
+	// Verbose and Guardrail turn on --verbose and a --guardrail-id.
+	Verbose   bool `json:"verbose"`
+	Guardrail bool `json:"guardrail"`
+	// HideReasoning collapses reasoning, as with --hide-reasoning.
+	HideReasoning bool `json:"hide_reasoning"`
+	// Responses are the streams Bedrock sent, one per request, in order.
+	Responses [][]fixtureEvent `json:"responses"`
+	// ToolResults are what each tool returns, by tool name.
+	ToolResults map[string]string `json:"tool_results"`
+}
+
+// fixtureEvent is a stream event in a compact form; exactly one field is
+// set.
+type fixtureEvent struct {
+	Text      string `json:"text,omitempty"`
+	Reasoning string `json:"reasoning,omitempty"`
+	ToolUse   *struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Input string `json:"input"`
+	} `json:"tool_use,omitempty"`
+	Stop  string `json:"stop,omitempty"`
+	Usage *struct {
+		Input  int32 `json:"input"`
+		Output int32 `json:"output"`
+	} `json:"usage,omitempty"`
+	// Unknown is an event the SDK doesn't know, by its tag.
+	Unknown string `json:"unknown,omitempty"`
+}
+
+// streamEvents turns a recorded response into the events Bedrock's stream
+// delivers, giving each run of text, reasoning or tool input its own
+// content block.
+func streamEvents(recorded []fixtureEvent) <-chan types.ConverseStreamOutput {
+	ch := make(chan types.ConverseStreamOutput, 2*len(recorded)+1)
+	ch <- &types.ConverseStreamOutputMemberMessageStart{Value: types.MessageStartEvent{Role: types.ConversationRoleAssistant}}
+
+	index, last := int32(-1), ""
+	block := func(kind string) *int32 {
+		if kind != last || kind == "tool_use" {
+			index++
+			last = kind
+		}
+		return aws.Int32(index)
+	}
+	for _, e := range recorded {
+		switch {
+		case e.Text != "":
+			ch <- &types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+				ContentBlockIndex: block("text"),
+				Delta:             &types.ContentBlockDeltaMemberText{Value: e.Text},
+			}}
+		case e.Reasoning != "":
+			ch <- &types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+				ContentBlockIndex: block("reasoning"),
+				Delta: &types.ContentBlockDeltaMemberReasoningContent{
+					Value: &types.ReasoningContentBlockDeltaMemberText{Value: e.Reasoning},
+				},
+			}}
+		case e.ToolUse != nil:
+			idx := block("tool_use")
+			ch <- &types.ConverseStreamOutputMemberContentBlockStart{Value: types.ContentBlockStartEvent{
+				ContentBlockIndex: idx,
+				Start: &types.ContentBlockStartMemberToolUse{Value: types.ToolUseBlockStart{
+					Name:      aws.String(e.ToolUse.Name),
+					ToolUseId: aws.String(e.ToolUse.ID),
+				}},
+			}}
+			ch <- &types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+				ContentBlockIndex: idx,
+				Delta:             &types.ContentBlockDeltaMemberToolUse{Value: types.ToolUseBlockDelta{Input: aws.String(e.ToolUse.Input)}},
+			}}
+		case e.Stop != "":
+			ch <- &types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReason(e.Stop)}}
+		case e.Usage != nil:
+			ch <- &types.ConverseStreamOutputMemberMetadata{Value: types.ConverseStreamMetadataEvent{
+				Usage: &types.TokenUsage{
+					InputTokens:  aws.Int32(e.Usage.Input),
+					OutputTokens: aws.Int32(e.Usage.Output),
+					TotalTokens:  aws.Int32(e.Usage.Input + e.Usage.Output),
+				},
+			}}
+		case e.Unknown != "":
+			ch <- &types.UnknownUnionMember{Tag: e.Unknown}
+		}
+	}
+	close(ch)
+	return ch
+}
+
+// replaySend answers each request with the next recorded response.
+func replaySend(t *testing.T, responses [][]fixtureEvent) converseStreamFunc {
+	next := 0
+	return func(context.Context, *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		if next == len(responses) {
+			t.Fatalf("the fixture has %d responses but another request was sent", len(responses))
+		}
+		next++
+		return streamEvents(responses[next-1]), nil
+	}
+}
+
+// fixtureTool returns the fixture's recorded result for a tool.
+type fixtureTool struct {
+	name   string
+	result string
+}
+
+func (f *fixtureTool) Name() string        { return f.name }
+func (f *fixtureTool) Description() string { return "recorded tool" }
+func (f *fixtureTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{"type": "object"})
+}
+func (f *fixtureTool) Execute(context.Context, json.RawMessage) (string, error) {
+	return f.result, nil
+}
+func (f *fixtureTool) RequiresConfirmation() bool { return false }
+func (f *fixtureTool) ConfirmationSummary(json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// replayTranscript replays fixture through its pipeline and returns what
+// went to stdout and stderr.
+func replayTranscript(t *testing.T, fixture transcriptFixture) (string, string) {
+	var stdout, stderr bytes.Buffer
+	streamWarnings = &stderr
+	defer func() { streamWarnings = os.Stderr }()
+
+	var guardrail *types.GuardrailStreamConfiguration
+	if fixture.Guardrail {
+		guardrail = &types.GuardrailStreamConfiguration{GuardrailIdentifier: aws.String("gr-123"), GuardrailVersion: aws.String("1")}
+	}
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:  aws.String(fixture.Model),
+		Messages: []types.Message{userTextMessage(fixture.Prompt)},
+	}
+	usage := newUsageTracker()
+	send := meteredSend(replaySend(t, fixture.Responses), usage)
+
+	switch fixture.Pipeline {
+	case "chat":
+		// as chat renders a turn, with its footer for a turn that took no
+		// time
+		renderer := newReplyRenderer(&stdout, &stdout)
+		renderer.hideReasoning = fixture.HideReasoning
+		renderer.reasoningHint = "/reasoning to show"
+		registry := tools.NewRegistry()
+		for name, result := range fixture.ToolResults {
+			registry.Register(&fixtureTool{name: name, result: result})
+		}
+		opts := chatTurnOptions{OnToolCall: renderer.onToolCall, OnToolResult: renderer.onToolResult}
+
+		usage.startTurn()
+		fmt.Fprintf(&stdout, "\033[90m> %s\033[0m\n\n* ", fixture.Prompt)
+		out, err := runChatTurnWithTools(t.Context(), send, input, registry, nil, renderer.onText, renderer.onReasoning, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fixture.Verbose {
+			renderer.note(stopReasonLine(out.StopReason))
+		}
+		renderer.warn(guardrailNotice(out.StopReason, guardrail))
+		renderer.note(usage.footer(0))
+		fmt.Fprint(&stdout, "\n\n")
+
+	case "prompt", "prompt-json":
+		asJSON := fixture.Pipeline == "prompt-json"
+		renderer := newReplyRenderer(&stdout, &stdout)
+		renderer.quiet = asJSON
+
+		events, err := send(t.Context(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _, stopReason, err := accumulateStream(t.Context(), events, renderer.onText, renderer.onReasoning)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if asJSON {
+			if err := writePromptResult(&stdout, newPromptResult(fixture.Model, fixture.Prompt, messageText(msg), stopReason, &usage.turn, 0)); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			fmt.Fprintln(&stdout)
+		}
+		if fixture.Verbose {
+			printStopReason(&stderr, stopReason)
+		}
+		if notice := guardrailNotice(stopReason, guardrail); notice != "" {
+			fmt.Fprintf(&stderr, "\033[33m%s\033[0m\n", notice)
+		}
+
+	default:
+		t.Fatalf("unknown pipeline %q", fixture.Pipeline)
+	}
+	return stdout.String(), stderr.String()
+}
+
+// formatTranscript lays out the output for a golden file, with escape
+// characters made visible.
+func formatTranscript(stdout, stderr string) string {
+	var b strings.Builder
+	b.WriteString("-- stdout --\n")
+	b.WriteString(stdout)
+	if stderr != "" {
+		b.WriteString("\n-- stderr --\n")
+		b.WriteString(stderr)
+	}
+	return strings.ReplaceAll(b.String(), "\033", "␛")
+}
+
+func TestGoldenTranscripts(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "transcripts", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no transcript fixtures found")
+	}
+
+	for _, path := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path) // #nosec G304 - test fixture
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fixture transcriptFixture
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("invalid fixture: %v", err)
+			}
+
+			got := formatTranscript(replayTranscript(t, fixture))
+			goldenPath := strings.TrimSuffix(path, ".json") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, []byte(got), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath) // #nosec G304 - test fixture
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s (run with -update to accept it):\n--- got ---\n%s\n--- want ---\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
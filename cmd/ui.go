@@ -0,0 +1,277 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	conf "github.com/chat-cli/chat-cli/config"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/chat-cli/chat-cli/templates"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// errCancelled is returned by a uiAction when the user backs out of a
+// choice it asked for.
+var errCancelled = errors.New("cancelled")
+
+// uiAction is one entry in the ui palette. args works out the chat-cli
+// arguments that carry it out, asking for anything more it needs, such as
+// which chat to resume.
+type uiAction struct {
+	item utils.PaletteItem
+	args func(ctx context.Context, ui *uiContext) ([]string, error)
+}
+
+// uiContext is what the ui actions share: the config and the region given
+// to ui.
+type uiContext struct {
+	fm     *conf.FileManager
+	region string
+}
+
+// uiActions are the palette's entries, most common first.
+var uiActions = []uiAction{
+	{
+		item: utils.PaletteItem{Title: "New chat", Description: "start a conversation with the default model"},
+		args: func(context.Context, *uiContext) ([]string, error) { return []string{}, nil },
+	},
+	{
+		item: utils.PaletteItem{Title: "Resume last chat", Description: "pick up the most recent conversation"},
+		args: func(ctx context.Context, ui *uiContext) ([]string, error) {
+			chats, err := ui.recentChats(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return []string{"--chat-id", chats[0].ChatId}, nil
+		},
+	},
+	{
+		item: utils.PaletteItem{Title: "Resume a chat", Description: "choose from recent conversations"},
+		args: func(ctx context.Context, ui *uiContext) ([]string, error) {
+			chats, err := ui.recentChats(ctx)
+			if err != nil {
+				return nil, err
+			}
+			items := make([]utils.PaletteItem, len(chats))
+			for i, chat := range chats {
+				items[i] = utils.PaletteItem{Title: chatListTitle(chat), Description: chat.Created}
+			}
+			index, ok := utils.Choose("Resume which chat?", items)
+			if !ok {
+				return nil, errCancelled
+			}
+			return []string{"--chat-id", chats[index].ChatId}, nil
+		},
+	},
+	{
+		item: utils.PaletteItem{Title: "Pick a model", Description: "choose the default model for chat and prompt"},
+		args: pickModelArgs,
+	},
+	{
+		item: utils.PaletteItem{Title: "Run an agent", Description: "chat with tools enabled; hand off a task with /agent <task>"},
+		args: func(context.Context, *uiContext) ([]string, error) { return []string{"--enable-tools"}, nil },
+	},
+	{
+		item: utils.PaletteItem{Title: "Run a template", Description: "fill in a saved prompt template and send it"},
+		args: runTemplateArgs,
+	},
+	{
+		item: utils.PaletteItem{Title: "View usage", Description: "tokens and estimated cost by model"},
+		args: func(context.Context, *uiContext) ([]string, error) { return []string{"usage"}, nil },
+	},
+	{
+		item: utils.PaletteItem{Title: "Check setup", Description: "run doctor on credentials, model access, and config"},
+		args: func(context.Context, *uiContext) ([]string, error) { return []string{"doctor"}, nil },
+	},
+	{
+		item: utils.PaletteItem{Title: "Read a guide", Description: "help topics such as getting started and sessions"},
+		args: func(context.Context, *uiContext) ([]string, error) {
+			items := make([]utils.PaletteItem, len(helpTopicNames))
+			for i, name := range helpTopicNames {
+				_, title, err := loadHelpTopic(name)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = utils.PaletteItem{Title: title, Description: name}
+			}
+			index, ok := utils.Choose("Read which guide?", items)
+			if !ok {
+				return nil, errCancelled
+			}
+			return []string{"help", "topics", helpTopicNames[index]}, nil
+		},
+	},
+}
+
+// recentChats returns the most recently active chats, newest first.
+func (ui *uiContext) recentChats(ctx context.Context) ([]repository.Chat, error) {
+	database, err := openDatabase(ui.fm)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := database.Close(); err != nil {
+			log.Printf("Warning: failed to close database: %v", err)
+		}
+	}()
+
+	chats, err := repository.NewChatRepository(database).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(chats) == 0 {
+		return nil, errors.New("there are no saved chats yet; start one with New chat")
+	}
+	return chats, nil
+}
+
+// pickModelArgs offers the text models in the region that stream, and
+// sets the one chosen as the default.
+func pickModelArgs(ctx context.Context, ui *uiContext) ([]string, error) {
+	cfg, err := loadAWSConfig(ctx, ui.region)
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	models, err := newModelCatalog(cfg).list(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("error listing models: %w", err)
+	}
+	models = filterModels(models, modelFilter{modality: "text", streamingOnly: true})
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no streaming text models found in %s", ui.region)
+	}
+	slices.SortFunc(models, func(a, b modelInfo) int { return strings.Compare(a.ID, b.ID) })
+
+	items := make([]utils.PaletteItem, len(models))
+	for i, m := range models {
+		items[i] = utils.PaletteItem{Title: m.ID, Description: m.Provider + " " + m.Name}
+	}
+	index, ok := utils.Choose("Use which model by default?", items)
+	if !ok {
+		return nil, errCancelled
+	}
+	return []string{"config", "set", "model-id", models[index].ID}, nil
+}
+
+// runTemplateArgs offers the saved templates and asks for a value for
+// each variable of the one chosen.
+func runTemplateArgs(_ context.Context, ui *uiContext) ([]string, error) {
+	list, err := templates.NewStore(ui.fm.ConfigPath).List()
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("there are no saved templates yet; create one with chat-cli templates create")
+	}
+
+	items := make([]utils.PaletteItem, len(list))
+	for i, t := range list {
+		items[i] = utils.PaletteItem{Title: t.Name, Description: t.Description}
+	}
+	index, ok := utils.Choose("Run which template?", items)
+	if !ok {
+		return nil, errCancelled
+	}
+
+	args := []string{"templates", "run", list[index].Name}
+	for _, name := range templates.Variables(list[index].Text) {
+		fmt.Fprintf(os.Stderr, "Value for {{%s}}:\n", name)
+		value := strings.TrimRight(utils.StringPrompt(">"), "\r\n")
+		args = append(args, "--var", name+"="+value)
+	}
+	return args, nil
+}
+
+// forwardedFlags returns the root flags given to ui, such as --region or
+// --profile, as arguments to pass on to the command an action runs.
+func forwardedFlags(cmd *cobra.Command) []string {
+	var args []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if cmd.Root().PersistentFlags().Lookup(f.Name) == nil {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range slice.GetSlice() {
+				args = append(args, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
+
+// uiCmd represents the ui command
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Choose what to do from a searchable menu",
+	Long: `Open a command palette of common actions - start or resume a chat, pick a model,
+run an agent or a template, view usage, and more - and type to narrow it down.
+The action chosen runs as the chat-cli command it stands for, so nothing needs
+to be remembered to get started:
+
+> chat-cli ui`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+		region, err := cmd.Root().PersistentFlags().GetString("region")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		items := make([]utils.PaletteItem, len(uiActions))
+		for i, action := range uiActions {
+			items[i] = action.item
+		}
+		index, ok := utils.Choose("What would you like to do?", items)
+		if !ok {
+			return
+		}
+
+		actionArgs, err := uiActions[index].args(cmd.Context(), &uiContext{fm: fm, region: region})
+		if errors.Is(err, errCancelled) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Run the action as its own process, so it parses its flags afresh.
+		// It isn't tied to cmd's context: Ctrl+C reaches it directly, and a
+		// chat uses it to cancel a reply rather than quit.
+		action := exec.Command(exe, append(actionArgs, forwardedFlags(cmd)...)...) // #nosec G204 - runs chat-cli itself with arguments chosen from the menu
+		action.Stdin, action.Stdout, action.Stderr = os.Stdin, os.Stdout, os.Stderr
+		var exitErr *exec.ExitError
+		if err := action.Run(); errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		} else if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestForwardedFlags(t *testing.T) {
+	root := &cobra.Command{Use: "chat-cli"}
+	root.PersistentFlags().String("region", "us-east-1", "")
+	root.PersistentFlags().StringArray("file", nil, "")
+	root.PersistentFlags().Bool("offline", false, "")
+	ui := &cobra.Command{Use: "ui", Run: func(*cobra.Command, []string) {}}
+	ui.Flags().Bool("local", false, "")
+	root.AddCommand(ui)
+
+	root.SetArgs([]string{"ui", "--region", "eu-west-1", "--file", "a.md", "--file", "b.md", "--local"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"--file=a.md", "--file=b.md", "--region=eu-west-1"}
+	if got := forwardedFlags(ui); !reflect.DeepEqual(got, want) {
+		t.Errorf("forwardedFlags() = %q, want %q", got, want)
+	}
+}
+
+func TestUIActionsHaveTitles(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, action := range uiActions {
+		if action.item.Title == "" || action.item.Description == "" || action.args == nil {
+			t.Errorf("incomplete action %+v", action.item)
+		}
+		if seen[action.item.Title] {
+			t.Errorf("duplicate action %q", action.item.Title)
+		}
+		seen[action.item.Title] = true
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// tokenUsage counts the tokens billed for one or more requests.
+type tokenUsage struct {
+	input      int
+	output     int
+	cacheRead  int
+	cacheWrite int
+}
+
+func (u *tokenUsage) add(other tokenUsage) {
+	u.input += other.input
+	u.output += other.output
+	u.cacheRead += other.cacheRead
+	u.cacheWrite += other.cacheWrite
+}
+
+func (u *tokenUsage) sub(other tokenUsage) {
+	u.input -= other.input
+	u.output -= other.output
+	u.cacheRead -= other.cacheRead
+	u.cacheWrite -= other.cacheWrite
+}
+
+// usageFromBedrock converts the usage Bedrock reports at the end of a
+// response.
+func usageFromBedrock(u *types.TokenUsage) tokenUsage {
+	if u == nil {
+		return tokenUsage{}
+	}
+	return tokenUsage{
+		input:      int(aws.ToInt32(u.InputTokens)),
+		output:     int(aws.ToInt32(u.OutputTokens)),
+		cacheRead:  int(aws.ToInt32(u.CacheReadInputTokens)),
+		cacheWrite: int(aws.ToInt32(u.CacheWriteInputTokens)),
+	}
+}
+
+// modelPrice is a model's on-demand price in US dollars per million tokens.
+type modelPrice struct {
+	input      float64
+	output     float64
+	cacheRead  float64
+	cacheWrite float64
+}
+
+// modelPrices are list prices for common models, keyed by a fragment of
+// the model ID so regional inference profiles and foundation model ARNs
+// match too. The longest matching key wins. Prices change and vary by
+// region, so costs worked out from them are estimates.
+var modelPrices = map[string]modelPrice{
+	"anthropic.claude-3-haiku":    {input: 0.25, output: 1.25},
+	"anthropic.claude-3-5-haiku":  {input: 0.80, output: 4.00, cacheRead: 0.08, cacheWrite: 1.00},
+	"anthropic.claude-haiku-4-5":  {input: 1.00, output: 5.00, cacheRead: 0.10, cacheWrite: 1.25},
+	"anthropic.claude-3-5-sonnet": {input: 3.00, output: 15.00},
+	"anthropic.claude-3-7-sonnet": {input: 3.00, output: 15.00, cacheRead: 0.30, cacheWrite: 3.75},
+	"anthropic.claude-sonnet":     {input: 3.00, output: 15.00, cacheRead: 0.30, cacheWrite: 3.75},
+	"anthropic.claude-opus-4":     {input: 15.00, output: 75.00, cacheRead: 1.50, cacheWrite: 18.75},
+	"anthropic.claude-opus-4-5":   {input: 5.00, output: 25.00, cacheRead: 0.50, cacheWrite: 6.25},
+	"amazon.nova-micro":           {input: 0.035, output: 0.14, cacheRead: 0.00875},
+	"amazon.nova-lite":            {input: 0.06, output: 0.24, cacheRead: 0.015},
+	"amazon.nova-pro":             {input: 0.80, output: 3.20, cacheRead: 0.20},
+	"amazon.nova-premier":         {input: 2.50, output: 12.50, cacheRead: 0.625},
+	"meta.llama3-3-70b":           {input: 0.72, output: 0.72},
+	"deepseek.r1":                 {input: 1.35, output: 5.40},
+}
+
+// priceForModel looks up the price of modelID, reporting false for models
+// it doesn't know.
+func priceForModel(modelID string) (modelPrice, bool) {
+	best := ""
+	for key := range modelPrices {
+		if strings.Contains(modelID, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return modelPrice{}, false
+	}
+	return modelPrices[best], true
+}
+
+func (p modelPrice) cost(u tokenUsage) float64 {
+	return (float64(u.input)*p.input +
+		float64(u.output)*p.output +
+		float64(u.cacheRead)*p.cacheRead +
+		float64(u.cacheWrite)*p.cacheWrite) / 1_000_000
+}
+
+// usageTracker totals token usage and estimated cost over a chat session,
+// and for the turn in progress.
+type usageTracker struct {
+	turn    tokenUsage
+	session tokenUsage
+	turns   int
+	cost    float64
+
+	// unpriced lists the models used this session that have no known
+	// price, so the cost is known to be incomplete.
+	unpriced map[string]bool
+
+	// pricedAs maps model ids that can't be priced directly, such as
+	// application inference profile ARNs, to the model they invoke.
+	pricedAs map[string]string
+
+	// save, if set, is called with each request's usage and cost (nil when
+	// unknown), so it can be kept for usage reports.
+	save func(modelID string, u tokenUsage, cost *float64)
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{unpriced: map[string]bool{}, pricedAs: map[string]string{}}
+}
+
+// priceAs prices requests to id at the price of modelID.
+func (t *usageTracker) priceAs(id, modelID string) {
+	t.pricedAs[id] = modelID
+}
+
+// startTurn resets the per-turn counts.
+func (t *usageTracker) startTurn() {
+	t.turn = tokenUsage{}
+	t.turns++
+}
+
+// record adds the usage of one request to modelID.
+func (t *usageTracker) record(modelID string, u tokenUsage) {
+	t.turn.add(u)
+	t.session.add(u)
+
+	priceID := modelID
+	if id, ok := t.pricedAs[modelID]; ok {
+		priceID = id
+	}
+
+	var cost *float64
+	if price, ok := priceForModel(priceID); ok {
+		c := price.cost(u)
+		t.cost += c
+		cost = &c
+	} else {
+		t.unpriced[modelID] = true
+	}
+
+	if t.save != nil {
+		t.save(modelID, u, cost)
+	}
+}
+
+// footer is the one-line summary shown after a turn: how long it took, its
+// tokens, and the session's cost so far.
+func (t *usageTracker) footer(elapsed time.Duration) string {
+	return fmt.Sprintf("%s · %s · %s", formatElapsed(elapsed), formatTokenCounts(t.turn), t.costText())
+}
+
+// summary describes the whole session so far, for /stats.
+func (t *usageTracker) summary() string {
+	return fmt.Sprintf("%d turns · %s · %s", t.turns, formatTokenCounts(t.session), t.costText())
+}
+
+// costText is the session cost, noting any models it leaves out.
+func (t *usageTracker) costText() string {
+	if len(t.unpriced) == 0 {
+		return formatCost(t.cost) + " this session"
+	}
+
+	models := make([]string, 0, len(t.unpriced))
+	for model := range t.unpriced {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	if t.cost == 0 {
+		return "cost unknown for " + strings.Join(models, ", ")
+	}
+	return fmt.Sprintf("%s this session, excluding %s", formatCost(t.cost), strings.Join(models, ", "))
+}
+
+func formatTokenCounts(u tokenUsage) string {
+	input := u.input + u.cacheRead + u.cacheWrite
+	text := fmt.Sprintf("%d in / %d out", input, u.output)
+	if u.cacheRead > 0 {
+		text += fmt.Sprintf(" (%d cached)", u.cacheRead)
+	}
+	return text
+}
+
+func formatElapsed(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return d.Round(time.Second).String()
+}
+
+// formatCost shows small amounts with enough precision that a cheap turn
+// doesn't read as free.
+func formatCost(cost float64) string {
+	if cost < 1 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+// meteredSend wraps send so the usage reported at the end of each
+// response is recorded in tracker against the model that produced it.
+// Events are passed through unchanged.
+func meteredSend(send converseStreamFunc, tracker *usageTracker) converseStreamFunc {
+	return func(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		events, err := send(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		modelID := aws.ToString(input.ModelId)
+		out := make(chan types.ConverseStreamOutput)
+		go func() {
+			defer close(out)
+			for event := range events {
+				if meta, ok := event.(*types.ConverseStreamOutputMemberMetadata); ok {
+					tracker.record(modelID, usageFromBedrock(meta.Value.Usage))
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+func runStatsCommand(s *chatSession, args string) error {
+	switch args {
+	case "":
+		if s.usage == nil {
+			fmt.Fprintln(s.out, "No usage recorded yet.")
+			return nil
+		}
+		fmt.Fprintln(s.out, s.usage.summary())
+	case "on":
+		s.showStats = true
+		fmt.Fprintln(s.out, "Turn stats on.")
+	case "off":
+		s.showStats = false
+		fmt.Fprintln(s.out, "Turn stats off.")
+	default:
+		return fmt.Errorf("usage: /stats [on|off]")
+	}
+	return nil
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		name:        "stats",
+		usage:       "[on|off]",
+		description: "Show session token usage and cost, or turn the per-turn footer on or off",
+		run:         runStatsCommand,
+	})
+}
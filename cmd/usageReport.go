@@ -0,0 +1,334 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/chat-cli/chat-cli/repository"
+	"github.com/spf13/cobra"
+
+	conf "github.com/chat-cli/chat-cli/config"
+)
+
+// Labels for usage that can't be put in a profile or tag group.
+const (
+	noProfileLabel = "(no profile)"
+	untaggedLabel  = "(untagged)"
+)
+
+// usageCmd represents the usage command
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and estimated cost",
+	Long: `Report the tokens used and estimated cost of chat sessions over a period, grouped
+by inference profile, cost allocation tag, or model.
+
+Requests sent through an application inference profile (with --custom-arn) are
+recorded with the profile's ARN, so usage can be grouped the same way AWS cost
+allocation reports group it: by profile, or by the value of one of the profile's
+tags. Tags are looked up from Bedrock when the report runs.
+
+> chat-cli usage --since 7d
+> chat-cli usage --by tag:team
+> chat-cli usage --by model`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, err := cmd.Flags().GetString("since")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		by, err := cmd.Flags().GetString("by")
+		if err != nil {
+			log.Fatalf("unable to get flag: %v", err)
+		}
+
+		age, err := parseAge(since)
+		if err != nil {
+			log.Fatalf("invalid --since: %v", err)
+		}
+
+		tagKey, byTag := strings.CutPrefix(by, "tag:")
+		if by != "profile" && by != "model" && (!byTag || tagKey == "") {
+			log.Fatalf("invalid --by %q: use profile, model, or tag:<key>", by)
+		}
+
+		fm, err := conf.NewFileManager("chat-cli")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if initErr := fm.InitializeViper(); initErr != nil {
+			log.Fatal(initErr)
+		}
+
+		database, err := openDatabase(fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := database.Close(); err != nil {
+				log.Printf("Warning: failed to close database: %v", err)
+			}
+		}()
+
+		totals, err := repository.NewUsageRepository(database).Totals(cmd.Context(), time.Now().Add(-age))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(totals) == 0 {
+			fmt.Printf("No usage recorded in the last %s.\n", since)
+			return
+		}
+
+		key := usageByModel
+		switch {
+		case by == "profile":
+			key = usageByProfile
+		case byTag:
+			region, err := cmd.Parent().PersistentFlags().GetString("region")
+			if err != nil {
+				log.Fatalf("unable to get flag: %v", err)
+			}
+
+			cfg, err := loadAWSConfig(cmd.Context(), region)
+			if err != nil {
+				log.Fatalf("unable to load AWS config: %v", err)
+			}
+
+			tags, err := profileTags(cmd.Context(), bedrockTagLookup(bedrock.NewFromConfig(cfg)), totals)
+			if err != nil {
+				log.Fatal(err)
+			}
+			key = usageByTag(tagKey, tags)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GROUP\tREQUESTS\tINPUT\tOUTPUT\tCOST")
+		var all usageGroup
+		for _, g := range groupUsage(totals, key) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", g.name, g.requests, g.usage.input+g.usage.cacheRead+g.usage.cacheWrite, g.usage.output, g.costText())
+			all.merge(g)
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%s\n", all.requests, all.usage.input+all.usage.cacheRead+all.usage.cacheWrite, all.usage.output, all.costText())
+		if err := w.Flush(); err != nil {
+			log.Printf("Error flushing output: %v", err)
+		}
+	},
+}
+
+// inferenceProfileARN returns modelID if it's an inference profile ARN,
+// system-defined or application, and "" otherwise.
+func inferenceProfileARN(modelID string) string {
+	if strings.HasPrefix(modelID, "arn:aws:bedrock:") && strings.Contains(modelID, "inference-profile/") {
+		return modelID
+	}
+	return ""
+}
+
+// isApplicationInferenceProfile reports whether id is the ARN of an
+// application inference profile, which names no model of its own.
+func isApplicationInferenceProfile(id string) bool {
+	return strings.HasPrefix(id, "arn:aws:bedrock:") && strings.Contains(id, ":application-inference-profile/")
+}
+
+// profileModelID looks up the model an application inference profile
+// invokes, so its requests can be priced.
+func profileModelID(ctx context.Context, svc *bedrock.Client, arn string) (string, error) {
+	out, err := svc.GetInferenceProfile(ctx, &bedrock.GetInferenceProfileInput{
+		InferenceProfileIdentifier: aws.String(arn),
+	}, withARNRegion(arn))
+	if err != nil {
+		return "", err
+	}
+	if len(out.Models) == 0 {
+		return "", fmt.Errorf("inference profile %s has no models", arn)
+	}
+
+	modelARN := aws.ToString(out.Models[0].ModelArn)
+	if i := strings.LastIndex(modelARN, "/"); i >= 0 {
+		return modelARN[i+1:], nil
+	}
+	return modelARN, nil
+}
+
+// withARNRegion sends a request to the region in arn, which may not be the
+// one the client was made for.
+func withARNRegion(arn string) func(*bedrock.Options) {
+	return func(o *bedrock.Options) {
+		if parts := strings.Split(arn, ":"); len(parts) > 3 && parts[3] != "" {
+			o.Region = parts[3]
+		}
+	}
+}
+
+// priceInferenceProfiles arranges for requests to application inference
+// profiles among ids to be priced as the model behind them. A profile that
+// can't be looked up is left unpriced.
+func priceInferenceProfiles(ctx context.Context, svc *bedrock.Client, tracker *usageTracker, ids ...string) {
+	for _, id := range ids {
+		if !isApplicationInferenceProfile(id) {
+			continue
+		}
+		if modelID, err := profileModelID(ctx, svc, id); err == nil {
+			tracker.priceAs(id, modelID)
+		}
+	}
+}
+
+// usageRecorder returns a usageTracker save func that stores each request's
+// usage against chatId.
+func usageRecorder(ctx context.Context, repo *repository.UsageRepository, chatId string) func(string, tokenUsage, *float64) {
+	return func(modelID string, u tokenUsage, cost *float64) {
+		err := repo.Create(ctx, &repository.Usage{
+			ChatId:           chatId,
+			ModelId:          modelID,
+			ProfileArn:       inferenceProfileARN(modelID),
+			InputTokens:      u.input,
+			OutputTokens:     u.output,
+			CacheReadTokens:  u.cacheRead,
+			CacheWriteTokens: u.cacheWrite,
+			Cost:             cost,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+}
+
+// usageGroup is one row of the usage report.
+type usageGroup struct {
+	name     string
+	requests int
+	usage    tokenUsage
+	cost     float64
+	unpriced int
+}
+
+func (g *usageGroup) merge(other usageGroup) {
+	g.requests += other.requests
+	g.usage.add(other.usage)
+	g.cost += other.cost
+	g.unpriced += other.unpriced
+}
+
+// costText is the group's cost, noting requests it leaves out.
+func (g usageGroup) costText() string {
+	if g.unpriced == 0 {
+		return formatCost(g.cost)
+	}
+	return fmt.Sprintf("%s (+%d unpriced)", formatCost(g.cost), g.unpriced)
+}
+
+// groupUsage combines totals with the same key, most expensive first.
+func groupUsage(totals []repository.UsageTotal, key func(repository.UsageTotal) string) []usageGroup {
+	byName := map[string]*usageGroup{}
+	for _, t := range totals {
+		name := key(t)
+		g, ok := byName[name]
+		if !ok {
+			g = &usageGroup{name: name}
+			byName[name] = g
+		}
+		g.merge(usageGroup{
+			requests: t.Requests,
+			usage:    tokenUsage{input: t.InputTokens, output: t.OutputTokens, cacheRead: t.CacheReadTokens, cacheWrite: t.CacheWriteTokens},
+			cost:     t.Cost,
+			unpriced: t.Unpriced,
+		})
+	}
+
+	groups := make([]usageGroup, 0, len(byName))
+	for _, g := range byName {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].cost != groups[j].cost {
+			return groups[i].cost > groups[j].cost
+		}
+		return groups[i].name < groups[j].name
+	})
+	return groups
+}
+
+func usageByModel(t repository.UsageTotal) string {
+	return t.ModelId
+}
+
+func usageByProfile(t repository.UsageTotal) string {
+	if t.ProfileArn == "" {
+		return noProfileLabel
+	}
+	return t.ProfileArn
+}
+
+// usageByTag groups by the value of the tag key on each request's
+// profile, given the tags of every profile.
+func usageByTag(key string, tags map[string]map[string]string) func(repository.UsageTotal) string {
+	return func(t repository.UsageTotal) string {
+		if t.ProfileArn == "" {
+			return noProfileLabel
+		}
+		if value, ok := tags[t.ProfileArn][key]; ok {
+			return key + "=" + value
+		}
+		return untaggedLabel
+	}
+}
+
+// tagLookupFunc returns the tags on a Bedrock resource.
+type tagLookupFunc func(ctx context.Context, arn string) (map[string]string, error)
+
+func bedrockTagLookup(svc *bedrock.Client) tagLookupFunc {
+	return func(ctx context.Context, arn string) (map[string]string, error) {
+		out, err := svc.ListTagsForResource(ctx, &bedrock.ListTagsForResourceInput{ResourceARN: aws.String(arn)}, withARNRegion(arn))
+		if err != nil {
+			return nil, err
+		}
+
+		tags := make(map[string]string, len(out.Tags))
+		for _, tag := range out.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		return tags, nil
+	}
+}
+
+// profileTags looks up the tags of each application inference profile in
+// totals. System-defined profiles can't be tagged, so they're skipped.
+func profileTags(ctx context.Context, lookup tagLookupFunc, totals []repository.UsageTotal) (map[string]map[string]string, error) {
+	tags := map[string]map[string]string{}
+	for _, t := range totals {
+		if !isApplicationInferenceProfile(t.ProfileArn) {
+			continue
+		}
+		if _, done := tags[t.ProfileArn]; done {
+			continue
+		}
+
+		profileTags, err := lookup(ctx, t.ProfileArn)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get tags for %s: %w", t.ProfileArn, err)
+		}
+		tags[t.ProfileArn] = profileTags
+	}
+	return tags, nil
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+
+	usageCmd.Flags().String("since", "30d", "report usage from this long ago (e.g. 7d, 4w, 12h)")
+	usageCmd.Flags().String("by", "profile", "group by profile, model, or tag:<key> (a cost allocation tag on the profile)")
+}
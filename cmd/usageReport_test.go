@@ -0,0 +1,143 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/sqlite"
+	"github.com/chat-cli/chat-cli/repository"
+)
+
+const (
+	teamAProfile = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/team-a"
+	teamBProfile = "arn:aws:bedrock:us-west-2:123456789012:application-inference-profile/team-b"
+	usProfile    = "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.amazon.nova-pro-v1:0"
+)
+
+func TestInferenceProfileARN(t *testing.T) {
+	tests := []struct {
+		id          string
+		arn         string
+		application bool
+	}{
+		{teamAProfile, teamAProfile, true},
+		{usProfile, usProfile, false},
+		{"us.amazon.nova-pro-v1:0", "", false},
+		{"arn:aws:bedrock:us-east-1::foundation-model/amazon.nova-pro-v1:0", "", false},
+	}
+	for _, tt := range tests {
+		if got := inferenceProfileARN(tt.id); got != tt.arn {
+			t.Errorf("inferenceProfileARN(%q) = %q", tt.id, got)
+		}
+		if got := isApplicationInferenceProfile(tt.id); got != tt.application {
+			t.Errorf("isApplicationInferenceProfile(%q) = %v", tt.id, got)
+		}
+	}
+}
+
+func TestUsageRecorder(t *testing.T) {
+	database := sqlite.NewSQLiteDB(&db.Config{Driver: "sqlite", Name: filepath.Join(t.TempDir(), "chats.db")})
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewUsageRepository(database)
+
+	tracker := newUsageTracker()
+	tracker.save = usageRecorder(t.Context(), repo, "chat-1")
+	tracker.priceAs(teamAProfile, "amazon.nova-pro-v1:0")
+
+	tracker.record(teamAProfile, tokenUsage{input: 1_000_000})
+	tracker.record("mystery.model-v1", tokenUsage{input: 10, output: 5})
+
+	if tracker.cost != 0.80 {
+		t.Errorf("expected the profile to be priced as its model, got cost %v", tracker.cost)
+	}
+
+	totals, err := repo.Totals(t.Context(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 2 {
+		t.Fatalf("expected 2 recorded groups, got %+v", totals)
+	}
+	if got := totals[0]; got.ProfileArn != teamAProfile || got.Cost != 0.80 || got.InputTokens != 1_000_000 {
+		t.Errorf("unexpected profile usage %+v", got)
+	}
+	if got := totals[1]; got.ProfileArn != "" || got.Unpriced != 1 {
+		t.Errorf("unexpected unpriced usage %+v", got)
+	}
+}
+
+func TestGroupUsage(t *testing.T) {
+	totals := []repository.UsageTotal{
+		{ModelId: teamAProfile, ProfileArn: teamAProfile, Requests: 2, InputTokens: 100, Cost: 1},
+		{ModelId: teamBProfile, ProfileArn: teamBProfile, Requests: 1, InputTokens: 50, Cost: 2},
+		{ModelId: usProfile, ProfileArn: usProfile, Requests: 1, InputTokens: 5, Unpriced: 1},
+		{ModelId: "amazon.nova-pro-v1:0", Requests: 3, InputTokens: 30, Cost: 0.5},
+	}
+
+	var looked []string
+	lookup := func(ctx context.Context, arn string) (map[string]string, error) {
+		looked = append(looked, arn)
+		if arn == teamBProfile {
+			return map[string]string{"team": "search"}, nil
+		}
+		return map[string]string{"team": "chat", "env": "prod"}, nil
+	}
+
+	tags, err := profileTags(context.Background(), lookup, totals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(looked) != 2 {
+		t.Errorf("expected only application profiles to be looked up, got %q", looked)
+	}
+
+	t.Run("by tag", func(t *testing.T) {
+		groups := groupUsage(totals, usageByTag("team", tags))
+		want := []struct {
+			name string
+			cost float64
+		}{{"team=search", 2}, {"team=chat", 1}, {noProfileLabel, 0.5}, {untaggedLabel, 0}}
+		if len(groups) != len(want) {
+			t.Fatalf("got %+v", groups)
+		}
+		for i, w := range want {
+			if groups[i].name != w.name || groups[i].cost != w.cost {
+				t.Errorf("group %d: got %+v, want %+v", i, groups[i], w)
+			}
+		}
+		if got := groups[3].costText(); got != "$0.0000 (+1 unpriced)" {
+			t.Errorf("unexpected cost text %q", got)
+		}
+	})
+
+	t.Run("by profile", func(t *testing.T) {
+		groups := groupUsage(totals, usageByProfile)
+		if len(groups) != 4 || groups[0].name != teamBProfile || groups[2].name != noProfileLabel {
+			t.Errorf("got %+v", groups)
+		}
+	})
+
+	t.Run("lookup errors", func(t *testing.T) {
+		failing := func(ctx context.Context, arn string) (map[string]string, error) {
+			return nil, errors.New("access denied")
+		}
+		if _, err := profileTags(context.Background(), failing, totals); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
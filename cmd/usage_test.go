@@ -0,0 +1,130 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestPriceForModel(t *testing.T) {
+	tests := []struct {
+		modelID string
+		want    float64
+		ok      bool
+	}{
+		{"anthropic.claude-3-5-haiku-20241022-v1:0", 0.80, true},
+		{"us.anthropic.claude-opus-4-5-20251101-v1:0", 5.00, true},
+		{"us.anthropic.claude-opus-4-1-20250805-v1:0", 15.00, true},
+		{"arn:aws:bedrock:us-east-1::foundation-model/amazon.nova-pro-v1:0", 0.80, true},
+		{"cohere.command-r-v1:0", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modelID, func(t *testing.T) {
+			price, ok := priceForModel(tt.modelID)
+			if ok != tt.ok || price.input != tt.want {
+				t.Errorf("got %+v, %v; want input price %v, %v", price, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUsageTracker(t *testing.T) {
+	t.Run("footer shows the turn and the session cost", func(t *testing.T) {
+		tracker := newUsageTracker()
+		tracker.startTurn()
+		tracker.record("amazon.nova-pro-v1:0", tokenUsage{input: 1_000_000})
+		tracker.startTurn()
+		tracker.record("amazon.nova-pro-v1:0", tokenUsage{input: 900, output: 100, cacheRead: 100})
+
+		footer := tracker.footer(1500 * time.Millisecond)
+		for _, want := range []string{"1.5s", "1000 in / 100 out (100 cached)", "$0.8011 this session"} {
+			if !strings.Contains(footer, want) {
+				t.Errorf("expected %q in %q", want, footer)
+			}
+		}
+
+		if summary := tracker.summary(); !strings.HasPrefix(summary, "2 turns · 1001000 in / 100 out") {
+			t.Errorf("unexpected summary %q", summary)
+		}
+	})
+
+	t.Run("unknown models are called out", func(t *testing.T) {
+		tracker := newUsageTracker()
+		tracker.startTurn()
+		tracker.record("custom-model", tokenUsage{input: 10, output: 10})
+		if got := tracker.costText(); got != "cost unknown for custom-model" {
+			t.Errorf("unexpected cost text %q", got)
+		}
+
+		tracker.record("amazon.nova-pro-v1:0", tokenUsage{output: 1_000_000})
+		if got := tracker.costText(); got != "$3.20 this session, excluding custom-model" {
+			t.Errorf("unexpected cost text %q", got)
+		}
+	})
+}
+
+func TestMeteredSend(t *testing.T) {
+	send := func(ctx context.Context, input *bedrockruntime.ConverseStreamInput) (<-chan types.ConverseStreamOutput, error) {
+		ch := make(chan types.ConverseStreamOutput, 2)
+		ch <- &types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonEndTurn}}
+		ch <- &types.ConverseStreamOutputMemberMetadata{Value: types.ConverseStreamMetadataEvent{
+			Usage: &types.TokenUsage{InputTokens: aws.Int32(12), OutputTokens: aws.Int32(34)},
+		}}
+		close(ch)
+		return ch, nil
+	}
+
+	tracker := newUsageTracker()
+	tracker.startTurn()
+	events, err := meteredSend(send, tracker)(context.Background(), &bedrockruntime.ConverseStreamInput{ModelId: aws.String("amazon.nova-lite-v1:0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for range events {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected every event to be passed through, got %d", count)
+	}
+	if tracker.turn != (tokenUsage{input: 12, output: 34}) {
+		t.Errorf("unexpected usage %+v", tracker.turn)
+	}
+	if len(tracker.unpriced) != 0 || tracker.cost == 0 {
+		t.Errorf("expected the usage to be priced, got cost %v", tracker.cost)
+	}
+}
+
+func TestRunStatsCommand(t *testing.T) {
+	var out bytes.Buffer
+	s := &chatSession{usage: newUsageTracker(), showStats: true, out: &out}
+
+	if err := runStatsCommand(s, "off"); err != nil || s.showStats {
+		t.Fatalf("expected stats off, got %v, %v", s.showStats, err)
+	}
+	if err := runStatsCommand(s, "on"); err != nil || !s.showStats {
+		t.Fatalf("expected stats on, got %v, %v", s.showStats, err)
+	}
+
+	out.Reset()
+	if err := runStatsCommand(s, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "0 turns") {
+		t.Errorf("expected the session summary, got %q", out.String())
+	}
+
+	if err := runStatsCommand(s, "sometimes"); err == nil {
+		t.Error("expected a usage error")
+	}
+}
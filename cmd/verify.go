@@ -0,0 +1,141 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// verifyCommandTimeout bounds how long a --verify-command check may run;
+// a var so tests can shorten it.
+var verifyCommandTimeout = 2 * time.Minute
+
+// maxVerifyOutputLines caps how much of a failing check command's output
+// is shown.
+const maxVerifyOutputLines = 20
+
+// fileCheck is the verification outcome for one file written during a
+// turn.
+type fileCheck struct {
+	Path    string
+	Problem string // empty when the file reads back as written
+}
+
+// verificationReport describes whether a turn's file changes landed as
+// intended, and whether the configured check command passed afterwards.
+type verificationReport struct {
+	Files         []fileCheck
+	Command       string
+	CommandOutput string
+	CommandErr    error
+}
+
+// Passed reports whether every file check and the check command (if any)
+// succeeded.
+func (r *verificationReport) Passed() bool {
+	for _, f := range r.Files {
+		if f.Problem != "" {
+			return false
+		}
+	}
+	return r.CommandErr == nil
+}
+
+// verifyChatTurn re-reads each file in writes and compares it with what
+// the model wrote, then runs checkCommand (if set) through the shell from
+// the working directory.
+func verifyChatTurn(ctx context.Context, writes map[string]string, checkCommand string) *verificationReport {
+	report := &verificationReport{Command: checkCommand}
+
+	paths := make([]string, 0, len(writes))
+	for path := range writes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		check := fileCheck{Path: path}
+
+		data, err := os.ReadFile(path) // #nosec G304 - path was already validated and written by write_file
+		switch {
+		case err != nil:
+			check.Problem = fmt.Sprintf("unable to re-read: %v", err)
+		case string(data) != writes[path]:
+			check.Problem = "contents differ from what was written"
+		}
+
+		report.Files = append(report.Files, check)
+	}
+
+	if checkCommand != "" {
+		runCtx, cancel := context.WithTimeout(ctx, verifyCommandTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(runCtx, "sh", "-c", checkCommand) // #nosec G204 - the check command comes from the user's own flag or config
+		// on timeout kill the whole process group, not just the shell: a
+		// child it started (go test, make) would otherwise keep the output
+		// pipe open and CombinedOutput waiting until it finished
+		utils.SetProcessGroup(cmd)
+		cmd.Cancel = func() error {
+			utils.KillProcessGroup(cmd)
+			return nil
+		}
+		cmd.WaitDelay = time.Second
+		output, err := cmd.CombinedOutput()
+		report.CommandOutput = string(output)
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", verifyCommandTimeout)
+		}
+		report.CommandErr = err
+	}
+
+	return report
+}
+
+// Summary renders the report for display after a turn.
+func (r *verificationReport) Summary() string {
+	var b strings.Builder
+
+	status := "passed"
+	if !r.Passed() {
+		status = "FAILED"
+	}
+	fmt.Fprintf(&b, "Verification %s:\n", status)
+
+	for _, f := range r.Files {
+		if f.Problem == "" {
+			fmt.Fprintf(&b, "  ✓ %s\n", f.Path)
+		} else {
+			fmt.Fprintf(&b, "  ✗ %s: %s\n", f.Path, f.Problem)
+		}
+	}
+
+	if r.Command != "" {
+		if r.CommandErr == nil {
+			fmt.Fprintf(&b, "  ✓ %s\n", r.Command)
+		} else {
+			fmt.Fprintf(&b, "  ✗ %s: %v\n", r.Command, r.CommandErr)
+			lines := strings.Split(strings.TrimRight(r.CommandOutput, "\n"), "\n")
+			if len(lines) > maxVerifyOutputLines {
+				lines = append(lines[:maxVerifyOutputLines], fmt.Sprintf("... (%d more lines)", len(lines)-maxVerifyOutputLines))
+			}
+			for _, line := range lines {
+				if line != "" {
+					fmt.Fprintf(&b, "      %s\n", line)
+				}
+			}
+		}
+	}
+
+	return b.String()
+}
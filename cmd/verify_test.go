@@ -0,0 +1,102 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chat-cli/chat-cli/tools"
+)
+
+func TestVerifyChatTurn(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	changed := filepath.Join(dir, "changed.txt")
+	missing := filepath.Join(dir, "missing.txt")
+
+	if err := os.WriteFile(good, []byte("as written"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(changed, []byte("edited afterwards"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("file checks", func(t *testing.T) {
+		report := verifyChatTurn(context.Background(), map[string]string{
+			good:    "as written",
+			changed: "original",
+			missing: "gone",
+		}, "")
+
+		if report.Passed() {
+			t.Error("expected verification to fail")
+		}
+		problems := map[string]string{}
+		for _, f := range report.Files {
+			problems[f.Path] = f.Problem
+		}
+		if problems[good] != "" {
+			t.Errorf("expected %s to pass, got %q", good, problems[good])
+		}
+		if !strings.Contains(problems[changed], "differ") {
+			t.Errorf("expected a content mismatch for %s, got %q", changed, problems[changed])
+		}
+		if !strings.Contains(problems[missing], "unable to re-read") {
+			t.Errorf("expected a read failure for %s, got %q", missing, problems[missing])
+		}
+	})
+
+	t.Run("passing check command", func(t *testing.T) {
+		report := verifyChatTurn(context.Background(), map[string]string{good: "as written"}, "true")
+		if !report.Passed() {
+			t.Errorf("expected verification to pass, got:\n%s", report.Summary())
+		}
+		if !strings.HasPrefix(report.Summary(), "Verification passed:") {
+			t.Errorf("unexpected summary:\n%s", report.Summary())
+		}
+	})
+
+	t.Run("failing check command shows its output", func(t *testing.T) {
+		report := verifyChatTurn(context.Background(), map[string]string{good: "as written"}, "echo broken build; exit 1")
+		if report.Passed() {
+			t.Fatal("expected verification to fail")
+		}
+		summary := report.Summary()
+		if !strings.HasPrefix(summary, "Verification FAILED:") || !strings.Contains(summary, "broken build") {
+			t.Errorf("expected failure summary with command output, got:\n%s", summary)
+		}
+	})
+
+	t.Run("timeout kills a grandchild holding the output open", func(t *testing.T) {
+		defer func(timeout time.Duration) { verifyCommandTimeout = timeout }(verifyCommandTimeout)
+		verifyCommandTimeout = 100 * time.Millisecond
+
+		start := time.Now()
+		report := verifyChatTurn(context.Background(), nil, "sleep 10; true")
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("expected the check to stop at the timeout, took %s", elapsed)
+		}
+		if report.CommandErr == nil || !strings.Contains(report.CommandErr.Error(), "timed out") {
+			t.Errorf("expected a timeout, got %v", report.CommandErr)
+		}
+	})
+}
+
+func TestRecordWrite(t *testing.T) {
+	writes := map[string]string{}
+
+	recordWrite(writes, tools.ToolCall{Name: "read_file", Input: json.RawMessage(`{"path":"a.go"}`)})
+	recordWrite(writes, tools.ToolCall{Name: "write_file", Input: json.RawMessage(`{"path":"a.go","content":"v1"}`)})
+	recordWrite(writes, tools.ToolCall{Name: "write_file", Input: json.RawMessage(`{"path":"a.go","content":"v2"}`)})
+
+	if len(writes) != 1 || writes["a.go"] != "v2" {
+		t.Errorf("expected only the last write_file content to be recorded, got %v", writes)
+	}
+}
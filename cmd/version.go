@@ -8,6 +8,8 @@ import (
 	"runtime"
 
 	"github.com/spf13/cobra"
+
+	"github.com/chat-cli/chat-cli/utils"
 )
 
 // version is set at build time via -ldflags (GoReleaser / Makefile).
@@ -20,7 +22,11 @@ var versionCmd = &cobra.Command{
 	Short: "Prints the current version",
 	Long:  `Prints the current version`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("chat-cli %s, %s/%s\n", version, runtime.GOOS, runtime.GOARCH)
+		build := ""
+		if utils.LiteBuild {
+			build = " (lite)"
+		}
+		fmt.Printf("chat-cli %s%s, %s/%s\n", version, build, runtime.GOOS, runtime.GOARCH)
 	},
 }
 
@@ -0,0 +1,143 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"strings"
+	"unicode"
+)
+
+// diffOp says what a run of words in a word diff is.
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffSpan is a run of words that are in both texts, or only in one.
+type diffSpan struct {
+	op    diffOp
+	words []string
+}
+
+// diffWords tokenizes a text for diffWordSpans: its words, with each line
+// break kept as a "\n" token so the diff can keep the text's layout.
+func diffWords(text string) []string {
+	var words []string
+	for _, line := range strings.SplitAfter(strings.TrimSpace(text), "\n") {
+		words = append(words, strings.FieldsFunc(line, unicode.IsSpace)...)
+		if strings.HasSuffix(line, "\n") {
+			words = append(words, "\n")
+		}
+	}
+	return words
+}
+
+// diffWordSpans returns the word-level difference between a and b, as the
+// spans that turn a into b, using Myers' algorithm so the result has as
+// few words deleted and inserted as possible.
+func diffWordSpans(a, b []string) []diffSpan {
+	var spans []diffSpan
+	add := func(op diffOp, word string) {
+		if n := len(spans); n > 0 && spans[n-1].op == op {
+			spans[n-1].words = append(spans[n-1].words, word)
+			return
+		}
+		spans = append(spans, diffSpan{op: op, words: []string{word}})
+	}
+
+	// the words both start and end with are matched up front, since
+	// answers to the same question often share them
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, word := range a[:prefix] {
+		add(diffEqual, word)
+	}
+	for _, op := range myersOps(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		add(op.op, op.word)
+	}
+	for _, word := range a[len(a)-suffix:] {
+		add(diffEqual, word)
+	}
+	return spans
+}
+
+type wordOp struct {
+	op   diffOp
+	word string
+}
+
+// myersOps finds the shortest edit script from a to b. trace keeps, for
+// each number of edits, the furthest point reached on each diagonal the
+// edits could have reached, so the script can be walked back from the end.
+func myersOps(a, b []string) []wordOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace[d][k+d+1] is v[offset+k] before the d'th edit
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var ops []wordOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0 && (x > 0 || y > 0); d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, wordOp{diffEqual, a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, wordOp{diffInsert, b[y]})
+		} else {
+			x--
+			ops = append(ops, wordOp{diffDelete, a[x]})
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
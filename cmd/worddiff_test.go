@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Micah Walter
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffWordSpans(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{"identical", "the same answer", "the same answer", "the same answer\n"},
+		{"word changed", "the quick brown fox", "the slow brown fox", "the [-quick-] {+slow+} brown fox\n"},
+		{"words added at the end", "it works", "it works on Linux", "it works {+on Linux+}\n"},
+		{"words removed at the start", "Well, it works", "it works", "[-Well,-] it works\n"},
+		{"spacing is ignored", "one  two\tthree", "one two three", "one two three\n"},
+		{"layout follows the second text", "first line second line", "first line\nsecond line", "first line\nsecond line\n"},
+		{"empty before", "", "all new", "{+all new+}\n"},
+		{"empty after", "all gone", "", "[-all gone-]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeWordDiff(&out, diffWordSpans(diffWords(tt.before), diffWords(tt.after)), false)
+			if out.String() != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestDiffWordSpansIsMinimal(t *testing.T) {
+	before := diffWords("a b c a b b a")
+	after := diffWords("c b a b a c")
+	var deleted, inserted int
+	var rebuiltBefore, rebuiltAfter []string
+	for _, span := range diffWordSpans(before, after) {
+		switch span.op {
+		case diffEqual:
+			rebuiltBefore = append(rebuiltBefore, span.words...)
+			rebuiltAfter = append(rebuiltAfter, span.words...)
+		case diffDelete:
+			deleted += len(span.words)
+			rebuiltBefore = append(rebuiltBefore, span.words...)
+		case diffInsert:
+			inserted += len(span.words)
+			rebuiltAfter = append(rebuiltAfter, span.words...)
+		}
+	}
+	if strings.Join(rebuiltBefore, " ") != strings.Join(before, " ") || strings.Join(rebuiltAfter, " ") != strings.Join(after, " ") {
+		t.Fatalf("the spans don't rebuild both texts: %v / %v", rebuiltBefore, rebuiltAfter)
+	}
+	// the classic example from Myers' paper has an edit distance of 5
+	if deleted+inserted != 5 {
+		t.Errorf("expected 5 edits, got %d deleted and %d inserted", deleted, inserted)
+	}
+}
+
+func TestWriteWordDiffColor(t *testing.T) {
+	var out bytes.Buffer
+	writeWordDiff(&out, diffWordSpans(diffWords("red fish"), diffWords("blue fish")), true)
+	if want := "\033[31mred\033[0m \033[32mblue\033[0m fish\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestParseMessageRef(t *testing.T) {
+	chatId, index, err := parseMessageRef("3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90:4")
+	if err != nil || chatId != "3f2b9c1e-0d4a-4c55-9a8e-6f1d2a7b8c90" || index != 4 {
+		t.Errorf("unexpected reference %q %d (%v)", chatId, index, err)
+	}
+	for _, ref := range []string{"abc", "abc:", ":4", "abc:0", "abc:two"} {
+		if _, _, err := parseMessageRef(ref); err == nil {
+			t.Errorf("%q: expected an error", ref)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
+
+	"github.com/chat-cli/chat-cli/utils"
 )
 
 // FileManager handles OS-specific paths for configuration and data storage
@@ -71,6 +76,14 @@ func (fm *FileManager) initializePaths() error {
 		}
 		configBase = xdgConfig
 		dataBase = xdgData
+
+		// in a container with a writable volume at /data and no XDG paths
+		// set, keep everything on the volume so it outlives the container,
+		// unless chat-cli is already set up in the home directory
+		if dir := containerDataDir(); dir != "" && os.Getenv("XDG_CONFIG_HOME") == "" && os.Getenv("XDG_DATA_HOME") == "" && !fm.hasConfigIn(configBase) {
+			configBase = filepath.Join(dir, "config")
+			dataBase = filepath.Join(dir, "data")
+		}
 	}
 
 	// Set final paths
@@ -88,6 +101,36 @@ func (fm *FileManager) initializePaths() error {
 	return nil
 }
 
+// ContainerDataDir is where a volume for chat-cli's config and data is
+// expected to be mounted in a container.
+var ContainerDataDir = "/data"
+
+// containerDataDir returns ContainerDataDir when running in a container
+// that has it as a directory chat-cli can write to, or "".
+func containerDataDir() string {
+	if !utils.InContainer() {
+		return ""
+	}
+	if info, err := os.Stat(ContainerDataDir); err != nil || !info.IsDir() {
+		return ""
+	}
+	// a read-only volume can't hold the config or history
+	probe, err := os.CreateTemp(ContainerDataDir, ".chat-cli-*")
+	if err != nil {
+		return ""
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return ContainerDataDir
+}
+
+// hasConfigIn reports whether a config file exists for the app under
+// configBase.
+func (fm *FileManager) hasConfigIn(configBase string) bool {
+	_, err := os.Stat(filepath.Join(configBase, fm.AppName, fm.ConfigFile))
+	return err == nil
+}
+
 // InitializeViper sets up Viper with the correct config file path
 func (fm *FileManager) InitializeViper() error {
 	viper.SetConfigName(fm.ConfigFile[:len(fm.ConfigFile)-len(filepath.Ext(fm.ConfigFile))])
@@ -108,7 +151,22 @@ func (fm *FileManager) InitializeViper() error {
 		return err
 	}
 
-	return fm.migrateLegacyDBDriver()
+	// Migrate first, so a legacy value isn't reported as invalid
+	if err := fm.migrateLegacyDBDriver(); err != nil {
+		return err
+	}
+
+	if issues := ValidateConfig(); len(issues) > 0 {
+		configPath := filepath.Join(fm.ConfigPath, fm.ConfigFile)
+		if StrictValidation {
+			return &ValidationError{File: configPath, Issues: issues}
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", configPath, issue)
+		}
+	}
+
+	return nil
 }
 
 // migrateLegacyDBDriver rewrites config files persisted by chat-cli
@@ -130,8 +188,12 @@ func (fm *FileManager) GetDBPath() string {
 	return filepath.Join(fm.DataPath, fm.DBFile)
 }
 
-// GetDBDriver returns the database type from the config
+// GetDBDriver returns the database type from the environment
+// (CHAT_CLI_DB_DRIVER) or the config
 func (fm *FileManager) GetDBDriver() string {
+	if driver, ok := os.LookupEnv(EnvVarName("db_driver")); ok && driver != "" {
+		return driver
+	}
 	return viper.GetString("db_driver")
 }
 
@@ -144,34 +206,92 @@ func (fm *FileManager) createDefaultConfig() error {
 	return nil
 }
 
+// Source identifies where an effective configuration value came from.
+type Source string
+
+const (
+	SourceFlag       Source = "flag"
+	SourceEnv        Source = "env"
+	SourceProfile    Source = "profile"
+	SourceConfigFile Source = "config file"
+	SourceDefault    Source = "default"
+)
+
+// envPrefix namespaces the environment variables that override config
+// file values, e.g. CHAT_CLI_MODEL_ID for model-id.
+const envPrefix = "CHAT_CLI_"
+
+// EnvVarName returns the environment variable that overrides key.
+func EnvVarName(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
 // GetConfigValue returns a configuration value with precedence order:
 // 1. Feature flag (command line argument)
-// 2. Configuration file
-// 3. Default value
+// 2. Environment variable (see EnvVarName)
+// 3. The active profile (see ActiveProfile)
+// 4. Configuration file
+// 5. Default value
 func (fm *FileManager) GetConfigValue(key string, flagValue, defaultValue interface{}) interface{} {
+	value, _ := fm.ResolveConfigValue(key, flagValue, defaultValue)
+	return value
+}
+
+// ResolveConfigValue is GetConfigValue, also reporting which source the
+// value came from.
+func (fm *FileManager) ResolveConfigValue(key string, flagValue, defaultValue interface{}) (interface{}, Source) {
 	// Check if flag value is provided and not empty/zero value
 	switch v := flagValue.(type) {
 	case string:
 		if v != "" && v != defaultValue {
-			return v
+			return v, SourceFlag
 		}
 	case int32:
 		if v != 0 && v != defaultValue {
-			return v
+			return v, SourceFlag
 		}
 	case float32:
 		if v != 0.0 && v != defaultValue {
-			return v
+			return v, SourceFlag
 		}
 	}
 
+	// Check the environment. Environment variables are looked up directly
+	// rather than bound into viper, so they never leak into the config file
+	// when it is rewritten.
+	if raw, ok := os.LookupEnv(EnvVarName(key)); ok && raw != "" {
+		if value, ok := parseEnvValue(raw, defaultValue); ok {
+			return value, SourceEnv
+		}
+	}
+
+	// Check the active profile
+	if value, ok := fm.ProfileValue(key); ok {
+		return value, SourceProfile
+	}
+
 	// Check configuration file
 	if viper.IsSet(key) {
-		return viper.Get(key)
+		return viper.Get(key), SourceConfigFile
 	}
 
 	// Return default value
-	return defaultValue
+	return defaultValue, SourceDefault
+}
+
+// parseEnvValue converts an environment variable to the type of
+// defaultValue, so callers can rely on the same type whatever the source.
+func parseEnvValue(raw string, defaultValue interface{}) (interface{}, bool) {
+	switch defaultValue.(type) {
+	case int32:
+		n, err := strconv.ParseInt(raw, 10, 32)
+		return int32(n), err == nil
+	case float32:
+		f, err := strconv.ParseFloat(raw, 32)
+		return float32(f), err == nil
+	default:
+		return raw, true
+	}
 }
 
 // IsConfigSet reports whether key is explicitly set in the configuration file.
@@ -378,3 +378,148 @@ func TestInitializeViper_MigratesLegacySqlite3Driver(t *testing.T) {
 
 	viper.Reset()
 }
+
+func TestResolveConfigValue(t *testing.T) {
+	fm := &FileManager{}
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("model-id", "config-model")
+
+	t.Run("flag beats env and config file", func(t *testing.T) {
+		t.Setenv("CHAT_CLI_MODEL_ID", "env-model")
+		value, source := fm.ResolveConfigValue("model-id", "flag-model", "default-model")
+		if value != "flag-model" || source != SourceFlag {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("env beats config file", func(t *testing.T) {
+		t.Setenv("CHAT_CLI_MODEL_ID", "env-model")
+		value, source := fm.ResolveConfigValue("model-id", "", "default-model")
+		if value != "env-model" || source != SourceEnv {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("config file when no flag or env", func(t *testing.T) {
+		value, source := fm.ResolveConfigValue("model-id", "default-model", "default-model")
+		if value != "config-model" || source != SourceConfigFile {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("default when nothing is set", func(t *testing.T) {
+		value, source := fm.ResolveConfigValue("custom-arn", "", "")
+		if value != "" || source != SourceDefault {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("env values take the default's type", func(t *testing.T) {
+		t.Setenv("CHAT_CLI_MAX_TOKENS", "2048")
+		if value, source := fm.ResolveConfigValue("max-tokens", int32(0), int32(4096)); value != int32(2048) || source != SourceEnv {
+			t.Errorf("got %v (%T) from %s", value, value, source)
+		}
+
+		t.Setenv("CHAT_CLI_MAX_TOKENS", "lots")
+		if value, source := fm.ResolveConfigValue("max-tokens", int32(0), int32(4096)); value != int32(4096) || source != SourceDefault {
+			t.Errorf("expected an unparseable env value to be ignored, got %v from %s", value, source)
+		}
+	})
+}
+
+func TestEnvVarName(t *testing.T) {
+	if got := EnvVarName("worker-model-id"); got != "CHAT_CLI_WORKER_MODEL_ID" {
+		t.Errorf("EnvVarName = %q", got)
+	}
+	if got := EnvVarName("error.retry_attempts"); got != "CHAT_CLI_ERROR_RETRY_ATTEMPTS" {
+		t.Errorf("EnvVarName = %q", got)
+	}
+}
+
+func TestInitializePathsInContainer(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("container paths are only used on Linux")
+	}
+
+	dataDir := t.TempDir()
+	old := ContainerDataDir
+	ContainerDataDir = dataDir
+	t.Cleanup(func() { ContainerDataDir = old })
+
+	home := t.TempDir()
+	t.Setenv("container", "docker")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	fm := &FileManager{AppName: "test-app", ConfigFile: "config.yaml", DBFile: "data.db"}
+	if err := fm.initializePaths(); err != nil {
+		t.Fatalf("initializePaths failed: %v", err)
+	}
+	if want := filepath.Join(dataDir, "config", "test-app"); fm.ConfigPath != want {
+		t.Errorf("ConfigPath = %q, want %q", fm.ConfigPath, want)
+	}
+	if want := filepath.Join(dataDir, "data", "test-app"); fm.DataPath != want {
+		t.Errorf("DataPath = %q, want %q", fm.DataPath, want)
+	}
+
+	// XDG paths set for the container win over the volume
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if err := fm.initializePaths(); err != nil {
+		t.Fatalf("initializePaths failed: %v", err)
+	}
+	if want := filepath.Join(xdg, "test-app"); fm.ConfigPath != want {
+		t.Errorf("ConfigPath = %q, want %q", fm.ConfigPath, want)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// a config already in the home directory isn't moved to the volume
+	homeConfig := filepath.Join(home, ".config", "test-app")
+	if err := os.MkdirAll(homeConfig, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeConfig, "config.yaml"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.initializePaths(); err != nil {
+		t.Fatalf("initializePaths failed: %v", err)
+	}
+	if fm.ConfigPath != homeConfig {
+		t.Errorf("ConfigPath = %q, want %q", fm.ConfigPath, homeConfig)
+	}
+}
+
+func TestInitializePathsReadOnlyContainerVolume(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("container paths are only used on Linux")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to a read-only directory")
+	}
+
+	dataDir := t.TempDir()
+	if err := os.Chmod(dataDir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(dataDir, 0755) })
+	old := ContainerDataDir
+	ContainerDataDir = dataDir
+	t.Cleanup(func() { ContainerDataDir = old })
+
+	home := t.TempDir()
+	t.Setenv("container", "docker")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	fm := &FileManager{AppName: "test-app", ConfigFile: "config.yaml", DBFile: "data.db"}
+	if err := fm.initializePaths(); err != nil {
+		t.Fatalf("expected the home directory to be used, got %v", err)
+	}
+	if want := filepath.Join(home, ".config", "test-app"); fm.ConfigPath != want {
+		t.Errorf("ConfigPath = %q, want %q", fm.ConfigPath, want)
+	}
+}
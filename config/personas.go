@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// A persona is a named way for the model to behave, kept under personas in
+// config.yaml and chosen per run with --persona:
+//
+//	personas:
+//	  reviewer:
+//	    system-prompt: You review Go code. Point out bugs first.
+//	    model-id: anthropic.claude-sonnet-4-5
+//	    temperature: 0.2
+//
+// Unlike a profile, which says where and how chat-cli runs, a persona only
+// shapes the conversation, so it can be used under any profile.
+const personasKey = "personas"
+
+// PersonaSchema lists the settings a persona can hold and their types.
+var PersonaSchema = map[string]ValueType{
+	"system-prompt": TypeString,
+	"model-id":      TypeString,
+	"temperature":   TypeFloat,
+	"topP":          TypeFloat,
+	"max-tokens":    TypeInt,
+}
+
+// ErrPersonaNotFound is returned when a persona isn't in the config file.
+var ErrPersonaNotFound = errors.New("persona not found")
+
+// ValidatePersonaName rejects names that can't be used as a YAML key path.
+func ValidatePersonaName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid persona name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// PersonaKey returns the canonical spelling of a persona setting.
+func PersonaKey(key string) (string, bool) {
+	for known := range PersonaSchema {
+		if strings.EqualFold(known, key) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// Personas returns the names of the personas in the config file, sorted.
+func (fm *FileManager) Personas() []string {
+	names := make([]string, 0)
+	for name := range viper.GetStringMap(personasKey) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PersonaSettings returns the settings of the named persona, keyed by their
+// canonical names.
+func (fm *FileManager) PersonaSettings(name string) (map[string]interface{}, error) {
+	return personaSettings(viper.GetViper(), name)
+}
+
+func personaSettings(v *viper.Viper, name string) (map[string]interface{}, error) {
+	path := personasKey + "." + name
+	if !v.IsSet(path) {
+		return nil, fmt.Errorf("%w: %s", ErrPersonaNotFound, name)
+	}
+
+	settings := make(map[string]interface{})
+	for key, value := range v.GetStringMap(path) {
+		if canonical, ok := PersonaKey(key); ok {
+			key = canonical
+		}
+		settings[key] = value
+	}
+	return settings, nil
+}
+
+// CreatePersona adds a persona with the given settings to the config file.
+// An existing persona with the same name is only replaced when force is
+// set.
+func (fm *FileManager) CreatePersona(name string, settings map[string]string, force bool) error {
+	if err := ValidatePersonaName(name); err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		// viper drops empty maps when it writes the file
+		return fmt.Errorf("persona %s needs at least one setting", name)
+	}
+	if viper.IsSet(personasKey+"."+name) && !force {
+		return fmt.Errorf("a persona named %s already exists (use --force to replace it)", name)
+	}
+
+	persona := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		canonical, ok := PersonaKey(key)
+		if !ok {
+			return fmt.Errorf("unsupported persona setting %q", key)
+		}
+		if want := PersonaSchema[canonical]; !hasType(value, want) {
+			return fmt.Errorf("%s: expected %s, got %q", canonical, want, value)
+		}
+		if err := checkRule(canonical, canonical, value); err != nil {
+			return err
+		}
+		persona[canonical] = value
+	}
+
+	viper.Set(personasKey+"."+name, persona)
+	return viper.WriteConfig()
+}
+
+// DeletePersona removes the named persona from the config file.
+func (fm *FileManager) DeletePersona(name string) error {
+	if _, err := fm.PersonaSettings(name); err != nil {
+		return err
+	}
+
+	// viper can't remove a key, so the persona is cut out of the YAML and
+	// the file read back in
+	path := filepath.Join(fm.ConfigPath, fm.ConfigFile)
+	raw, err := os.ReadFile(path) // #nosec G304 - the path is in chat-cli's config directory
+	if err != nil {
+		return err
+	}
+	var data map[string]interface{}
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	personas, _ := data[personasKey].(map[string]interface{})
+	delete(personas, name)
+	if len(personas) == 0 {
+		delete(data, personasKey)
+	}
+
+	raw, err = yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return err
+	}
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	// a persona created earlier in this run is also in viper's overrides
+	if viper.IsSet(personasKey + "." + name) {
+		viper.Set(personasKey, personas)
+	}
+	return nil
+}
+
+// ReadPersona returns the settings of the named persona straight from the
+// config file, without loading it into viper, so flags can be defaulted
+// from it before a command loads its configuration.
+func (fm *FileManager) ReadPersona(name string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(fm.ConfigPath, fm.ConfigFile))
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return personaSettings(v, name)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestPersonas(t *testing.T) {
+	dir := t.TempDir()
+	fm := &FileManager{AppName: "test-app", ConfigFile: "config.yaml", DBFile: "data.db", ConfigPath: dir, DataPath: dir}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if err := fm.InitializeViper(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fm.CreatePersona("reviewer", map[string]string{"system-prompt": "Review Go code.", "temperature": "0.2"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.CreatePersona("tutor", map[string]string{"system-prompt": "Explain slowly.", "max-tokens": "1024"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.CreatePersona("reviewer", map[string]string{"system-prompt": "other"}, false); err == nil {
+		t.Error("expected an error replacing a persona without force")
+	}
+	for _, bad := range []map[string]string{
+		{"temperature": "warm"},
+		{"temperature": "1.5"},
+		{"region": "us-west-2"},
+	} {
+		if err := fm.CreatePersona("bad", bad, false); err == nil {
+			t.Errorf("expected an error creating a persona with %v", bad)
+		}
+	}
+	if err := fm.CreatePersona("bad name", map[string]string{"system-prompt": "x"}, false); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+
+	if got := fm.Personas(); !reflect.DeepEqual(got, []string{"reviewer", "tutor"}) {
+		t.Errorf("Personas() = %v", got)
+	}
+
+	settings, err := fm.ReadPersona("reviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"system-prompt": "Review Go code.", "temperature": "0.2"}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("ReadPersona() = %v, want %v", settings, want)
+	}
+
+	if err := fm.DeletePersona("reviewer"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fm.ReadPersona("reviewer"); !errors.Is(err, ErrPersonaNotFound) {
+		t.Errorf("expected ErrPersonaNotFound after delete, got %v", err)
+	}
+	if got := fm.Personas(); !reflect.DeepEqual(got, []string{"tutor"}) {
+		t.Errorf("Personas() after delete = %v", got)
+	}
+	if err := fm.DeletePersona("reviewer"); !errors.Is(err, ErrPersonaNotFound) {
+		t.Errorf("expected ErrPersonaNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestValidateConfigPersonas(t *testing.T) {
+	loadTestConfig(t, "personas:\n  reviewer:\n    system-prompt: Review.\n    topP: 0.5\n  broken:\n    temperature: 2\n    region: us-west-2\n")
+
+	want := []string{
+		`unknown key "personas.broken.region"`,
+		`invalid personas.broken.temperature "2": must be from 0 to 1`,
+	}
+	if got := ValidateConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// A profile is a named set of settings, kept under profiles in config.yaml,
+// that is laid over the rest of the file while it is active:
+//
+//	profile: work
+//	profiles:
+//	  work:
+//	    model-id: anthropic.claude-sonnet-4-5
+//	    region: us-west-2
+//	  personal:
+//	    model-id: amazon.nova-lite-v1:0
+//	    temperature: 0.7
+const (
+	activeProfileKey = "profile"
+	profilesKey      = "profiles"
+)
+
+// ProfileSchema lists the settings a profile can hold and their types.
+// Besides the model settings, a profile can choose the region, the AWS
+// credentials profile, and inference parameters, which are otherwise only
+// set by flags.
+var ProfileSchema = map[string]ValueType{
+	"model-id":        TypeString,
+	"custom-arn":      TypeString,
+	"system-prompt":   TypeString,
+	"worker-model-id": TypeString,
+	"region":          TypeString,
+	"aws-profile":     TypeString,
+	"temperature":     TypeFloat,
+	"topP":            TypeFloat,
+	"max-tokens":      TypeInt,
+}
+
+// ProfileOverride names the profile to use for this run, ahead of
+// CHAT_CLI_PROFILE and the profile chosen with "config profile use". It is
+// set by --profile.
+var ProfileOverride string
+
+// ErrProfileNotFound is returned when a profile isn't in the config file.
+var ErrProfileNotFound = errors.New("profile not found")
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateProfileName rejects names that can't be used as a YAML key path.
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// ProfileKey returns the canonical spelling of a profile setting. Viper
+// lowercases keys, so "topP" comes back from the file as "topp".
+func ProfileKey(key string) (string, bool) {
+	for known := range ProfileSchema {
+		if strings.EqualFold(known, key) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// ActiveProfile returns the name of the profile in use, or "" for none.
+func (fm *FileManager) ActiveProfile() string {
+	return activeProfile(viper.GetViper())
+}
+
+func activeProfile(v *viper.Viper) string {
+	if ProfileOverride != "" {
+		return ProfileOverride
+	}
+	if name, ok := os.LookupEnv(EnvVarName(activeProfileKey)); ok && name != "" {
+		return name
+	}
+	return v.GetString(activeProfileKey)
+}
+
+// Profiles returns the names of the profiles in the config file, sorted.
+func (fm *FileManager) Profiles() []string {
+	names := make([]string, 0)
+	for name := range viper.GetStringMap(profilesKey) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileSettings returns the settings of the named profile, keyed by their
+// canonical names.
+func (fm *FileManager) ProfileSettings(name string) (map[string]interface{}, error) {
+	return profileSettings(viper.GetViper(), name)
+}
+
+func profileSettings(v *viper.Viper, name string) (map[string]interface{}, error) {
+	path := profilesKey + "." + name
+	if !v.IsSet(path) {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	settings := make(map[string]interface{})
+	for key, value := range v.GetStringMap(path) {
+		if canonical, ok := ProfileKey(key); ok {
+			key = canonical
+		}
+		settings[key] = value
+	}
+	return settings, nil
+}
+
+// CreateProfile adds a profile with the given settings to the config file.
+// An existing profile with the same name is only replaced when force is set.
+func (fm *FileManager) CreateProfile(name string, settings map[string]string, force bool) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		// viper drops empty maps when it writes the file
+		return fmt.Errorf("profile %s needs at least one setting", name)
+	}
+	if viper.IsSet(profilesKey+"."+name) && !force {
+		return fmt.Errorf("a profile named %s already exists (use --force to replace it)", name)
+	}
+
+	profile := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		canonical, ok := ProfileKey(key)
+		if !ok {
+			return fmt.Errorf("unsupported profile setting %q", key)
+		}
+		if want := ProfileSchema[canonical]; !hasType(value, want) {
+			return fmt.Errorf("%s: expected %s, got %q", canonical, want, value)
+		}
+		profile[canonical] = value
+	}
+
+	viper.Set(profilesKey+"."+name, profile)
+	return viper.WriteConfig()
+}
+
+// UseProfile makes the named profile the default for future runs. An empty
+// name goes back to using no profile.
+func (fm *FileManager) UseProfile(name string) error {
+	if name != "" {
+		if _, err := fm.ProfileSettings(name); err != nil {
+			return err
+		}
+	}
+	viper.Set(activeProfileKey, name)
+	return viper.WriteConfig()
+}
+
+// ProfileValue returns key's value in the active profile, if it sets one.
+func (fm *FileManager) ProfileValue(key string) (interface{}, bool) {
+	name := fm.ActiveProfile()
+	if name == "" {
+		return nil, false
+	}
+
+	settings, err := fm.ProfileSettings(name)
+	if err != nil {
+		return nil, false
+	}
+	value, ok := settings[key]
+	return value, ok
+}
+
+// ReadActiveProfile returns the name and settings of the active profile
+// straight from the config file, without loading it into viper. It is for
+// use before a command has loaded its configuration, e.g. to default flags
+// from the profile. The name is "" when no profile is in use.
+func (fm *FileManager) ReadActiveProfile() (string, map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(fm.ConfigPath, fm.ConfigFile))
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", nil, err
+	}
+
+	name := activeProfile(v)
+	if name == "" {
+		return "", nil, nil
+	}
+	settings, err := profileSettings(v, name)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, settings, nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const profilesYAML = `model-id: file-model
+profile: work
+profiles:
+  work:
+    model-id: work-model
+    region: us-west-2
+    topP: "0.9"
+  personal:
+    model-id: personal-model
+`
+
+func TestActiveProfile(t *testing.T) {
+	fm := &FileManager{}
+	loadTestConfig(t, profilesYAML)
+
+	if got := fm.ActiveProfile(); got != "work" {
+		t.Errorf("expected the config file's profile, got %q", got)
+	}
+
+	t.Setenv("CHAT_CLI_PROFILE", "personal")
+	if got := fm.ActiveProfile(); got != "personal" {
+		t.Errorf("expected CHAT_CLI_PROFILE to win over the config file, got %q", got)
+	}
+
+	ProfileOverride = "other"
+	t.Cleanup(func() { ProfileOverride = "" })
+	if got := fm.ActiveProfile(); got != "other" {
+		t.Errorf("expected --profile to win over CHAT_CLI_PROFILE, got %q", got)
+	}
+}
+
+func TestProfileSettings(t *testing.T) {
+	fm := &FileManager{}
+	loadTestConfig(t, profilesYAML)
+
+	if got, want := fm.Profiles(), []string{"personal", "work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Profiles() = %q, want %q", got, want)
+	}
+
+	settings, err := fm.ProfileSettings("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"model-id": "work-model", "region": "us-west-2", "topP": "0.9"}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("got %v, want %v", settings, want)
+	}
+
+	if _, err := fm.ProfileSettings("missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestResolveConfigValueProfile(t *testing.T) {
+	fm := &FileManager{}
+	loadTestConfig(t, profilesYAML)
+
+	t.Run("profile beats config file", func(t *testing.T) {
+		value, source := fm.ResolveConfigValue("model-id", "", "default-model")
+		if value != "work-model" || source != SourceProfile {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("env beats profile", func(t *testing.T) {
+		t.Setenv("CHAT_CLI_MODEL_ID", "env-model")
+		value, source := fm.ResolveConfigValue("model-id", "", "default-model")
+		if value != "env-model" || source != SourceEnv {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("config file for settings the profile leaves out", func(t *testing.T) {
+		viper.Set("custom-arn", "file-arn")
+		value, source := fm.ResolveConfigValue("custom-arn", "", "")
+		if value != "file-arn" || source != SourceConfigFile {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+
+	t.Run("config file when the profile doesn't exist", func(t *testing.T) {
+		t.Setenv("CHAT_CLI_PROFILE", "missing")
+		value, source := fm.ResolveConfigValue("model-id", "", "default-model")
+		if value != "file-model" || source != SourceConfigFile {
+			t.Errorf("got %v from %s", value, source)
+		}
+	})
+}
+
+func TestValidateConfigProfiles(t *testing.T) {
+	loadTestConfig(t, profilesYAML+"  broken:\n    temperature: warm\n    colour: blue\n")
+
+	want := []string{
+		`unknown key "profiles.broken.colour"`,
+		`profiles.broken.temperature: expected number, got string warm`,
+	}
+	if got := ValidateConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCreateAndUseProfile(t *testing.T) {
+	dir := t.TempDir()
+	fm := &FileManager{AppName: "test-app", ConfigFile: "config.yaml", DBFile: "data.db", ConfigPath: dir, DataPath: dir}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if err := fm.InitializeViper(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fm.CreateProfile("work", map[string]string{"model-id": "work-model", "topp": "0.5"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.CreateProfile("work", map[string]string{"model-id": "other"}, false); err == nil {
+		t.Error("expected an error replacing a profile without force")
+	}
+	if err := fm.CreateProfile("bad name", map[string]string{"model-id": "x"}, false); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+	if err := fm.CreateProfile("bad", map[string]string{"temperature": "warm"}, false); err == nil {
+		t.Error("expected an error for a wrongly typed setting")
+	}
+	if err := fm.CreateProfile("bad", map[string]string{"colour": "blue"}, false); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+
+	if err := fm.UseProfile("missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+	if err := fm.UseProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	name, settings, err := fm.ReadActiveProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"model-id": "work-model", "topP": "0.5"}
+	if name != "work" || !reflect.DeepEqual(settings, want) {
+		t.Errorf("ReadActiveProfile() = %q, %v", name, settings)
+	}
+}
+
+func TestReadActiveProfileWithoutConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	fm := &FileManager{ConfigFile: "config.yaml", ConfigPath: filepath.Join(dir, "missing")}
+
+	if name, _, err := fm.ReadActiveProfile(); name != "" || err != nil {
+		t.Errorf("got %q, %v", name, err)
+	}
+
+	t.Setenv("CHAT_CLI_PROFILE", "work")
+	if _, _, err := fm.ReadActiveProfile(); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+	if _, err := os.Stat(fm.ConfigPath); !os.IsNotExist(err) {
+		t.Errorf("expected no config file to be created, got %v", err)
+	}
+}
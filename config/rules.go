@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules holds the checks a value must pass beyond its type, keyed like
+// Schema and ProfileSchema. Each mirrors what the command reading the key
+// accepts, so a bad value is caught by "config set" and "config validate"
+// instead of on the next run.
+var Rules = map[string]func(string) error{
+	"db_driver": oneOf("sqlite", "postgres"),
+	"db_port":   between(1, 65535),
+
+	"offline":         boolean,
+	"model-id":        modelID,
+	"custom-arn":      arn,
+	"worker-model-id": modelID,
+	"pager":           oneOf("auto", "off"),
+	"injection-check": oneOf("warn", "strip", "off"),
+	"reasoning":       oneOf("show", "hide"),
+
+	"error.retry_attempts": between(0, 10),
+
+	"auth.role_arn":         arn,
+	"auth.session_duration": durationBetween(15*time.Minute, 12*time.Hour),
+	"auth.sso_start_url":    urlWithScheme("https"),
+	"auth.sso_region":       region,
+	"auth.sso_account_id":   accountID,
+
+	"kb.rerank": boolean,
+
+	"tickets.provider": oneOf("jira", "linear"),
+	"tickets.endpoint": urlWithScheme("https", "http"),
+
+	"secrets.backend": oneOf(SecretBackendKeychain, SecretBackendFile),
+
+	"speech.engine": oneOf("standard", "neural", "long-form", "generative"),
+
+	// profile settings
+	"region":      region,
+	"temperature": between(0, 1),
+	"topP":        between(0, 1),
+	"max-tokens":  between(1, 1<<20),
+}
+
+// ValidateValue checks a value given on the command line, such as by
+// "config set", against key's type in Schema and its rule in Rules.
+func ValidateValue(key, value string) error {
+	want, ok := Schema[key]
+	if !ok {
+		return errors.New(unknownKey(key))
+	}
+	if !hasType(value, want) {
+		return fmt.Errorf("invalid %s %q: expected %s", key, value, want)
+	}
+	return checkRule(key, key, value)
+}
+
+// checkRule applies the rule for setting, if it has one, to value,
+// naming the value by key in the error.
+func checkRule(key, setting, value string) error {
+	rule, ok := Rules[setting]
+	if !ok {
+		return nil
+	}
+	if err := rule(value); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return nil
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("use %s", strings.Join(values, ", "))
+	}
+}
+
+func between(lo, hi float64) func(string) error {
+	return func(value string) error {
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || n < lo || n > hi {
+			return fmt.Errorf("must be from %v to %v", lo, hi)
+		}
+		return nil
+	}
+}
+
+func durationBetween(lo, hi time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("use a duration such as 1h or 45m")
+		}
+		if d < lo || d > hi {
+			return fmt.Errorf("must be from %s to %s", lo, hi)
+		}
+		return nil
+	}
+}
+
+func urlWithScheme(schemes ...string) func(string) error {
+	return func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("use a URL such as %s://host/...", schemes[0])
+		}
+		if err := oneOf(schemes...)(u.Scheme); err != nil {
+			return fmt.Errorf("%s URLs aren't supported: %w", u.Scheme, err)
+		}
+		return nil
+	}
+}
+
+func boolean(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return errors.New("use true or false")
+	}
+	return nil
+}
+
+func modelID(value string) error {
+	if value == "" || strings.ContainsAny(value, " \t\r\n") {
+		return errors.New("use a model ID such as amazon.nova-pro-v1:0, without spaces")
+	}
+	return nil
+}
+
+func arn(value string) error {
+	if !strings.HasPrefix(value, "arn:") || strings.Count(value, ":") < 5 {
+		return errors.New("use an ARN such as arn:aws:bedrock:us-east-1:123456789012:...")
+	}
+	return nil
+}
+
+var (
+	regionPattern    = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+)
+
+func region(value string) error {
+	if !regionPattern.MatchString(value) {
+		return errors.New("use an AWS region such as us-east-1")
+	}
+	return nil
+}
+
+func accountID(value string) error {
+	if !accountIDPattern.MatchString(value) {
+		return errors.New("use a 12-digit AWS account ID")
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ValueType is the type a configuration key is expected to hold.
+type ValueType string
+
+const (
+	TypeString ValueType = "string"
+	TypeInt    ValueType = "integer"
+	TypeFloat  ValueType = "number"
+	TypeBool   ValueType = "boolean"
+	TypeList   ValueType = "list"
+)
+
+// Schema lists every key chat-cli reads from config.yaml and the type of
+// value it expects. Keys not listed here are reported as unknown.
+var Schema = map[string]ValueType{
+	// written by InitializeViper
+	"environment": TypeString,
+	"db_path":     TypeString,
+	"db_driver":   TypeString,
+
+	"db_url":      TypeString,
+	"db_host":     TypeString,
+	"db_port":     TypeInt,
+	"db_name":     TypeString,
+	"db_user":     TypeString,
+	"db_password": TypeString,
+
+	"aws-profile":         TypeString,
+	"offline":             TypeBool,
+	"model-id":            TypeString,
+	"custom-arn":          TypeString,
+	"system-prompt":       TypeString,
+	"context-files":       TypeString,
+	"verify-command":      TypeString,
+	"worker-model-id":     TypeString,
+	"guardrail-id":        TypeString,
+	"guardrail-version":   TypeString,
+	"pager":               TypeString,
+	"injection-check":     TypeString,
+	"reasoning":           TypeString,
+	"agent-prompt":        TypeString,
+	"agent-prompt-append": TypeString,
+	"archive-after":       TypeString,
+	"routing":             TypeList,
+	"model-defaults":      TypeList,
+
+	"error.retry_attempts": TypeInt,
+
+	"auth.role_arn":         TypeString,
+	"auth.mfa_serial":       TypeString,
+	"auth.external_id":      TypeString,
+	"auth.session_duration": TypeString,
+	"auth.sso_start_url":    TypeString,
+	"auth.sso_region":       TypeString,
+	"auth.sso_account_id":   TypeString,
+	"auth.sso_role_name":    TypeString,
+
+	"kb.rerank":       TypeString,
+	"kb.rerank_model": TypeString,
+
+	"github.token": TypeString,
+
+	"tickets.provider": TypeString,
+	"tickets.endpoint": TypeString,
+	"tickets.token":    TypeString,
+	"tickets.user":     TypeString,
+	"tickets.project":  TypeString,
+
+	// see secrets.go
+	"secrets.backend": TypeString,
+
+	"speech.voice":  TypeString,
+	"speech.engine": TypeString,
+	"speech.player": TypeString,
+
+	// see profiles.go
+	"profile": TypeString,
+}
+
+// StrictValidation makes InitializeViper fail when the config file doesn't
+// match Schema, instead of printing warnings. It is set by --strict-config.
+var StrictValidation bool
+
+// ValidationError is returned by InitializeViper in strict mode when the
+// config file has problems.
+type ValidationError struct {
+	File   string
+	Issues []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config file %s:\n  %s", e.File, strings.Join(e.Issues, "\n  "))
+}
+
+// ValidateConfig checks the loaded configuration against Schema and Rules
+// and returns a description of each unknown key and each value of the wrong
+// type or out of range, sorted by key.
+func ValidateConfig() []string {
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+
+	var issues []string
+	for _, key := range keys {
+		want, ok := Schema[key]
+		setting := key
+		if profileKey, found := strings.CutPrefix(key, profilesKey+"."); found {
+			// profiles.<name>.<setting>
+			_, setting, _ = strings.Cut(profileKey, ".")
+			if setting == "" {
+				continue
+			}
+			setting, ok = ProfileKey(setting)
+			want = ProfileSchema[setting]
+		} else if personaKey, found := strings.CutPrefix(key, personasKey+"."); found {
+			// personas.<name>.<setting>
+			_, setting, _ = strings.Cut(personaKey, ".")
+			if setting == "" {
+				continue
+			}
+			setting, ok = PersonaKey(setting)
+			want = PersonaSchema[setting]
+		}
+		if !ok {
+			issues = append(issues, unknownKey(key))
+			continue
+		}
+
+		value := viper.Get(key)
+		if !hasType(value, want) {
+			issues = append(issues, fmt.Sprintf("%s: expected %s, got %s %v", key, want, describeType(value), value))
+			continue
+		}
+		if want != TypeList {
+			if err := checkRule(key, setting, fmt.Sprint(value)); err != nil {
+				issues = append(issues, err.Error())
+			}
+		}
+	}
+	return issues
+}
+
+// unknownKey describes a key that isn't in Schema, suggesting the key that
+// was probably meant.
+func unknownKey(key string) string {
+	issue := fmt.Sprintf("unknown key %q", key)
+	if suggestion := SuggestKey(key); suggestion != "" {
+		issue += fmt.Sprintf(" (did you mean %q?)", suggestion)
+	}
+	return issue
+}
+
+// hasType reports whether value, as decoded from YAML, can be used as t.
+// Numbers written as quoted strings are accepted, since that's how they
+// arrive from the environment too.
+func hasType(value interface{}, t ValueType) bool {
+	switch t {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeInt:
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case string:
+			_, err := strconv.Atoi(v)
+			return err == nil
+		}
+		return false
+	case TypeFloat:
+		switch v := value.(type) {
+		case int, int64, float64:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(v, 64)
+			return err == nil
+		}
+		return false
+	case TypeBool:
+		switch v := value.(type) {
+		case bool:
+			return true
+		case string:
+			// config set writes "true" and "false" as strings
+			_, err := strconv.ParseBool(v)
+			return err == nil
+		}
+		return false
+	case TypeList:
+		_, ok := value.([]interface{})
+		return ok
+	}
+	return true
+}
+
+// describeType names the type of a YAML-decoded value in the same terms as
+// ValueType.
+func describeType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case int, int64:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	case nil:
+		return "empty value"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// SuggestKey returns the known key that differs from key only in its use
+// of '-' and '_', the most common way to get a key name wrong.
+func SuggestKey(key string) string {
+	normalize := func(s string) string { return strings.ReplaceAll(s, "_", "-") }
+	for known := range Schema {
+		if normalize(known) == normalize(key) {
+			return known
+		}
+	}
+	return ""
+}
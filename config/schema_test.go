@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func loadTestConfig(t *testing.T, yaml string) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(strings.NewReader(yaml)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		loadTestConfig(t, "model-id: amazon.nova-pro-v1:0\ndb_driver: sqlite\ndb_port: \"5432\"\n")
+		if issues := ValidateConfig(); len(issues) != 0 {
+			t.Errorf("expected no issues, got %q", issues)
+		}
+	})
+
+	t.Run("unknown keys and type mismatches", func(t *testing.T) {
+		loadTestConfig(t, "model_id: amazon.nova-pro-v1:0\nsystem-prompt: true\ndb_port: fifty\ncolour: blue\n")
+
+		want := []string{
+			`unknown key "colour"`,
+			`db_port: expected integer, got string fifty`,
+			`unknown key "model_id" (did you mean "model-id"?)`,
+			`system-prompt: expected string, got boolean true`,
+		}
+		if got := ValidateConfig(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestValidateConfigRules(t *testing.T) {
+	loadTestConfig(t, `db_driver: mysql
+error:
+  retry_attempts: 3
+pager: auto
+profiles:
+  work:
+    region: us-west-2
+    temperature: 1.5
+    topP: 0.9
+  broken:
+    region: Oregon
+`)
+
+	want := []string{
+		`invalid db_driver "mysql": use sqlite, postgres`,
+		`invalid profiles.broken.region "Oregon": use an AWS region such as us-east-1`,
+		`invalid profiles.work.temperature "1.5": must be from 0 to 1`,
+	}
+	if got := ValidateConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"model-id", "us.anthropic.claude-sonnet-4-5-20250929-v1:0", ""},
+		{"model-id", "amazon nova", "without spaces"},
+		{"custom-arn", "arn:aws:bedrock:us-east-1:123456789012:endpoint/my-endpoint", ""},
+		{"custom-arn", "my-endpoint", "use an ARN"},
+		{"offline", "true", ""},
+		{"offline", "sometimes", "expected boolean"},
+		{"error.retry_attempts", "-1", "must be from 0 to 10"},
+		{"error.retry_attempts", "many", "expected integer"},
+		{"auth.session_duration", "2h", ""},
+		{"auth.session_duration", "24h", "must be from 15m0s to 12h0m0s"},
+		{"auth.sso_start_url", "https://my-org.awsapps.com/start", ""},
+		{"auth.sso_start_url", "my-org", "use a URL"},
+		{"auth.sso_account_id", "12345", "12-digit"},
+		{"tickets.provider", "trello", "use jira, linear"},
+		{"system-prompt", "anything goes", ""},
+		{"model_id", "x", `unknown key "model_id" (did you mean "model-id"?)`},
+	}
+	for _, tt := range tests {
+		err := ValidateValue(tt.key, tt.value)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ValidateValue(%s, %q): unexpected error %v", tt.key, tt.value, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateValue(%s, %q) = %v, want an error containing %q", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRulesHaveKnownKeys(t *testing.T) {
+	for key := range Rules {
+		_, inSchema := Schema[key]
+		_, inProfile := ProfileSchema[key]
+		if !inSchema && !inProfile {
+			t.Errorf("rule for %s, which is in neither schema", key)
+		}
+	}
+}
+
+func TestHasType(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		typ   ValueType
+		want  bool
+	}{
+		{"text", TypeString, true},
+		{1.5, TypeString, false},
+		{5432, TypeInt, true},
+		{"5432", TypeInt, true},
+		{1.5, TypeInt, false},
+		{1, TypeFloat, true},
+		{0.7, TypeFloat, true},
+		{"warm", TypeFloat, false},
+		{true, TypeBool, true},
+		{"true", TypeBool, true},
+		{"sometimes", TypeBool, false},
+		{[]interface{}{map[string]interface{}{"model-id": "x"}}, TypeList, true},
+		{"x", TypeList, false},
+	}
+	for _, c := range cases {
+		if got := hasType(c.value, c.typ); got != c.want {
+			t.Errorf("hasType(%#v, %s) = %v, want %v", c.value, c.typ, got, c.want)
+		}
+	}
+}
+
+func TestInitializeViperStrict(t *testing.T) {
+	dir := t.TempDir()
+	fm := &FileManager{AppName: "test-app", ConfigFile: "config.yaml", DBFile: "data.db", ConfigPath: dir, DataPath: dir}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("modelid: x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+	defer func() { StrictValidation = false }()
+
+	if err := fm.InitializeViper(); err != nil {
+		t.Fatalf("expected only warnings by default, got %v", err)
+	}
+
+	viper.Reset()
+	StrictValidation = true
+	err := fm.InitializeViper()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Issues) != 1 {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Where SecretsManager keeps secrets, chosen with the secrets.backend
+// setting. Without one, the OS keychain is used when there is one.
+const (
+	SecretBackendKeychain = "keychain"
+	SecretBackendFile     = "file"
+)
+
+const (
+	// secretsFile holds the file backend's secrets, in the config
+	// directory.
+	secretsFile = "secrets.enc"
+	// keychainService is the service secrets are filed under in the OS
+	// keychain.
+	keychainService = "chat-cli"
+	// pbkdf2Iterations is OWASP's recommendation for PBKDF2-HMAC-SHA256.
+	pbkdf2Iterations = 600000
+	// secretsCheck is encrypted into a new secrets file, so a wrong
+	// passphrase is caught before anything is written with it.
+	secretsCheck = "chat-cli secrets"
+)
+
+var (
+	// ErrSecretNotFound is returned by Get and Unset for a secret that
+	// hasn't been set.
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrWrongPassphrase is returned when the secrets file can't be
+	// decrypted with the passphrase given.
+	ErrWrongPassphrase = errors.New("wrong passphrase for the secrets file")
+)
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,99}$`)
+
+// ValidateSecretName rejects names that can't be used as a keychain
+// account or a key in the secrets file.
+func ValidateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '.', '-' and '_'", name)
+	}
+	return nil
+}
+
+// Passphrase returns the passphrase for the secrets file. confirm is set
+// when the file is about to be created, so the passphrase can be asked for
+// twice.
+type Passphrase func(confirm bool) (string, error)
+
+// secretStore is a place secrets are kept.
+type secretStore interface {
+	get(name string) (string, error)
+	set(name, value string) error
+	unset(name string) error
+}
+
+// SecretsManager keeps sensitive settings, such as API tokens and database
+// passwords, out of config.yaml: in the OS keychain, or in a file
+// encrypted with a key derived from a passphrase.
+type SecretsManager struct {
+	backend string
+	store   secretStore
+}
+
+// NewSecretsManager returns a SecretsManager using backend, or, when
+// backend is "", the OS keychain if one can be used and the file in fm's
+// config directory otherwise. passphrase is only called when the file is
+// used.
+func NewSecretsManager(fm *FileManager, backend string, passphrase Passphrase) (*SecretsManager, error) {
+	keychain := newOSKeychain()
+	switch backend {
+	case "":
+		if keychain != nil {
+			return &SecretsManager{backend: SecretBackendKeychain, store: keychain}, nil
+		}
+	case SecretBackendKeychain:
+		if keychain == nil {
+			return nil, fmt.Errorf("no keychain is available on %s (macOS needs security, Linux needs secret-tool): set secrets.backend to %s", runtime.GOOS, SecretBackendFile)
+		}
+		return &SecretsManager{backend: backend, store: keychain}, nil
+	case SecretBackendFile:
+	default:
+		return nil, fmt.Errorf("unknown secrets.backend %q: use %s or %s", backend, SecretBackendKeychain, SecretBackendFile)
+	}
+	return &SecretsManager{
+		backend: SecretBackendFile,
+		store:   &secretFile{path: filepath.Join(fm.ConfigPath, secretsFile), passphrase: passphrase},
+	}, nil
+}
+
+// Backend returns where the secrets are kept: SecretBackendKeychain or
+// SecretBackendFile.
+func (m *SecretsManager) Backend() string {
+	return m.backend
+}
+
+// Get returns the named secret.
+func (m *SecretsManager) Get(name string) (string, error) {
+	if err := ValidateSecretName(name); err != nil {
+		return "", err
+	}
+	return m.store.get(name)
+}
+
+// Set stores value as the named secret, replacing any it had.
+func (m *SecretsManager) Set(name, value string) error {
+	if err := ValidateSecretName(name); err != nil {
+		return err
+	}
+	return m.store.set(name, value)
+}
+
+// Unset removes the named secret.
+func (m *SecretsManager) Unset(name string) error {
+	if err := ValidateSecretName(name); err != nil {
+		return err
+	}
+	return m.store.unset(name)
+}
+
+// Exists reports whether the file backend has a secrets file, so callers
+// that only look secrets up as a fallback can skip asking for a passphrase
+// when none have been stored.
+func (m *SecretsManager) Exists() bool {
+	f, ok := m.store.(*secretFile)
+	if !ok {
+		return true
+	}
+	_, err := os.Stat(f.path)
+	return err == nil
+}
+
+// secretFileData is the secrets file. Each value is sealed with AES-GCM
+// under a key derived from the passphrase and Salt, with the secret's name
+// as additional data so values can't be swapped between names.
+type secretFileData struct {
+	Version int               `json:"version"`
+	Salt    string            `json:"salt"`
+	Check   string            `json:"check"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// secretFile is the passphrase-encrypted file backend.
+type secretFile struct {
+	path       string
+	passphrase Passphrase
+}
+
+// open reads the file and derives its key, checking the passphrase. A
+// file that doesn't exist yet is returned empty, with a new salt, when
+// create is set.
+func (f *secretFile) open(create bool) (*secretFileData, cipher.AEAD, error) {
+	raw, err := os.ReadFile(f.path) // #nosec G304 - the path is in chat-cli's config directory
+	if errors.Is(err, os.ErrNotExist) {
+		if !create {
+			return nil, nil, ErrSecretNotFound
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+		data := &secretFileData{Version: 1, Salt: base64.StdEncoding.EncodeToString(salt), Secrets: map[string]string{}}
+		aead, err := f.key(data, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if data.Check, err = seal(aead, "", secretsCheck); err != nil {
+			return nil, nil, err
+		}
+		return data, aead, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", f.path, err)
+	}
+
+	var data secretFileData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, fmt.Errorf("error parsing %s: %w", f.path, err)
+	}
+	if data.Secrets == nil {
+		data.Secrets = map[string]string{}
+	}
+	aead, err := f.key(&data, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if check, err := open(aead, "", data.Check); err != nil || check != secretsCheck {
+		return nil, nil, ErrWrongPassphrase
+	}
+	return &data, aead, nil
+}
+
+// key derives the file's key from the passphrase.
+func (f *secretFile) key(data *secretFileData, confirm bool) (cipher.AEAD, error) {
+	salt, err := base64.StdEncoding.DecodeString(data.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", f.path, err)
+	}
+	passphrase, err := f.passphrase(confirm)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("the secrets passphrase must not be empty")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (f *secretFile) save(data *secretFileData) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0750); err != nil {
+		return err
+	}
+	// write then rename, so a failed write doesn't lose every secret
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("error writing %s: %w", f.path, err)
+	}
+	return os.Rename(tmp, f.path)
+}
+
+func (f *secretFile) get(name string) (string, error) {
+	data, aead, err := f.open(false)
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := data.Secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	value, err := open(aead, name, sealed)
+	if err != nil {
+		return "", fmt.Errorf("secret %s can't be decrypted: %w", name, err)
+	}
+	return value, nil
+}
+
+func (f *secretFile) set(name, value string) error {
+	data, aead, err := f.open(true)
+	if err != nil {
+		return err
+	}
+	if data.Secrets[name], err = seal(aead, name, value); err != nil {
+		return err
+	}
+	return f.save(data)
+}
+
+func (f *secretFile) unset(name string) error {
+	data, _, err := f.open(false)
+	if err != nil {
+		return err
+	}
+	if _, ok := data.Secrets[name]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(data.Secrets, name)
+	return f.save(data)
+}
+
+// seal encrypts value with a random nonce, which is kept in front of the
+// ciphertext.
+func seal(aead cipher.AEAD, name, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), []byte(name))), nil
+}
+
+func open(aead cipher.AEAD, name, sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", errors.New("malformed value")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// osKeychain keeps secrets in the OS keychain through its command-line
+// tool: security on macOS and secret-tool (libsecret) on Linux. Values are
+// passed on stdin, never as arguments, so they don't show up in ps.
+type osKeychain struct {
+	// run runs a command with stdin and returns its stdout.
+	run  func(stdin string, name string, args ...string) (string, error)
+	goos string
+}
+
+// newOSKeychain returns the keychain for this OS, or nil if it has none
+// chat-cli can use.
+func newOSKeychain() *osKeychain {
+	tool := map[string]string{"darwin": "security", "linux": "secret-tool"}[runtime.GOOS]
+	if tool == "" {
+		return nil
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil
+	}
+	return &osKeychain{run: runCommand, goos: runtime.GOOS}
+}
+
+func runCommand(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...) // #nosec G204 - runs the OS keychain tool with fixed arguments
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
+
+func (k *osKeychain) get(name string) (string, error) {
+	var out string
+	var err error
+	if k.goos == "darwin" {
+		out, err = k.run("", "security", "find-generic-password", "-s", keychainService, "-a", name, "-w")
+		// security prints its errors as "... could not be found"
+		if err != nil && strings.Contains(err.Error(), "could not be found") {
+			return "", ErrSecretNotFound
+		}
+	} else {
+		out, err = k.run("", "secret-tool", "lookup", "service", keychainService, "account", name)
+		// secret-tool exits with status 1, printing nothing, when there's
+		// no such secret
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 || err == nil && out == "" {
+			return "", ErrSecretNotFound
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (k *osKeychain) set(name, value string) error {
+	if k.goos == "darwin" {
+		// security -i reads commands from stdin, keeping the value out of
+		// the process list
+		_, err := k.run(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", quoteSecurityArg(keychainService), quoteSecurityArg(name), quoteSecurityArg(value)), "security", "-i")
+		return err
+	}
+	_, err := k.run(value, "secret-tool", "store", "--label", keychainService+" "+name, "service", keychainService, "account", name)
+	return err
+}
+
+func (k *osKeychain) unset(name string) error {
+	if _, err := k.get(name); err != nil {
+		return err
+	}
+	if k.goos == "darwin" {
+		_, err := k.run("", "security", "delete-generic-password", "-s", keychainService, "-a", name)
+		return err
+	}
+	_, err := k.run("", "secret-tool", "clear", "service", keychainService, "account", name)
+	return err
+}
+
+// quoteSecurityArg quotes an argument for a command read by security -i,
+// which splits words like a shell.
+func quoteSecurityArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fileSecrets(t *testing.T, dir, passphrase string) *SecretsManager {
+	t.Helper()
+	m, err := NewSecretsManager(&FileManager{ConfigPath: dir}, SecretBackendFile, func(bool) (string, error) { return passphrase, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	m := fileSecrets(t, dir, "correct horse")
+
+	if m.Exists() {
+		t.Error("expected no secrets file before one is set")
+	}
+	if _, err := m.Get("github.token"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Get before any secrets = %v, want ErrSecretNotFound", err)
+	}
+
+	if err := m.Set("github.token", "ghp_abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set("db_password", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.Get("github.token"); err != nil || got != "ghp_abc" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+
+	path := filepath.Join(dir, secretsFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("secrets file mode = %v, want 0600", perm)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "ghp_abc") || strings.Contains(string(raw), "hunter2") {
+		t.Error("secrets file holds a value in the clear")
+	}
+
+	if _, err := fileSecrets(t, dir, "wrong").Get("github.token"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Get with the wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if err := fileSecrets(t, dir, "wrong").Set("other", "x"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Set with the wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+
+	if err := m.Unset("github.token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("github.token"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Get after Unset = %v, want ErrSecretNotFound", err)
+	}
+	if err := m.Unset("github.token"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("second Unset = %v, want ErrSecretNotFound", err)
+	}
+	if got, err := m.Get("db_password"); err != nil || got != "hunter2" {
+		t.Errorf("other secret after Unset = %q, %v", got, err)
+	}
+}
+
+func TestSecretFileBindsNames(t *testing.T) {
+	dir := t.TempDir()
+	m := fileSecrets(t, dir, "pass")
+	if err := m.Set("a", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set("b", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	// move a's value under b
+	path := filepath.Join(dir, secretsFile)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data secretFileData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	data.Secrets["b"] = data.Secrets["a"]
+	raw, _ = json.Marshal(data)
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := m.Get("b"); err == nil {
+		t.Errorf("expected a value moved to another name to fail to decrypt, got %q", got)
+	}
+}
+
+func TestSecretNames(t *testing.T) {
+	m := fileSecrets(t, t.TempDir(), "pass")
+	for _, name := range []string{"", "has space", "../escape", "-flag"} {
+		if err := m.Set(name, "v"); err == nil {
+			t.Errorf("Set(%q) accepted a bad name", name)
+		}
+	}
+}
+
+func TestSecretsEmptyPassphrase(t *testing.T) {
+	if err := fileSecrets(t, t.TempDir(), "").Set("a", "v"); err == nil {
+		t.Error("expected an empty passphrase to be refused")
+	}
+}
+
+func TestNewSecretsManagerBackend(t *testing.T) {
+	if _, err := NewSecretsManager(&FileManager{}, "vault", nil); err == nil {
+		t.Error("expected an unknown backend to be refused")
+	}
+	m, err := NewSecretsManager(&FileManager{ConfigPath: t.TempDir()}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Backend() != SecretBackendKeychain && m.Backend() != SecretBackendFile {
+		t.Errorf("Backend() = %q", m.Backend())
+	}
+}
+
+// fakeKeychain records the commands an osKeychain runs and answers them
+// from items.
+type fakeKeychain struct {
+	items    map[string]string
+	commands []string
+	stdin    []string
+}
+
+func (f *fakeKeychain) run(stdin, name string, args ...string) (string, error) {
+	f.commands = append(f.commands, name+" "+strings.Join(args, " "))
+	f.stdin = append(f.stdin, stdin)
+	account := ""
+	for i, arg := range args {
+		if (arg == "-a" || arg == "account") && i+1 < len(args) {
+			account = args[i+1]
+		}
+	}
+	switch {
+	case name == "security" && args[0] == "-i":
+		f.items["from-stdin"] = stdin
+	case args[0] == "store":
+		f.items[account] = stdin
+	case args[0] == "find-generic-password":
+		if value, ok := f.items[account]; ok {
+			return value + "\n", nil
+		}
+		return "", errors.New("security: exit status 44: The specified item could not be found in the keychain.")
+	case args[0] == "lookup":
+		if value, ok := f.items[account]; ok {
+			return value, nil
+		}
+		return "", exec.Command("false").Run()
+	case args[0] == "delete-generic-password", args[0] == "clear":
+		delete(f.items, account)
+	}
+	return "", nil
+}
+
+func TestOSKeychainLinux(t *testing.T) {
+	if _, err := exec.LookPath("false"); err != nil {
+		t.Skip("needs false to fake secret-tool's exit status")
+	}
+	fake := &fakeKeychain{items: map[string]string{}}
+	k := &osKeychain{run: fake.run, goos: "linux"}
+
+	if err := k.set("github.token", "ghp_abc"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(fake.commands[0], "ghp_abc") || fake.stdin[0] != "ghp_abc" {
+		t.Errorf("expected the value on stdin only, ran %q", fake.commands[0])
+	}
+	if got, err := k.get("github.token"); err != nil || got != "ghp_abc" {
+		t.Errorf("get = %q, %v", got, err)
+	}
+	if err := k.unset("github.token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.get("github.token"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("get after unset = %v, want ErrSecretNotFound", err)
+	}
+	if err := k.unset("github.token"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("unset of a missing secret = %v, want ErrSecretNotFound", err)
+	}
+}
+
+func TestOSKeychainDarwin(t *testing.T) {
+	fake := &fakeKeychain{items: map[string]string{"db_password": "hunter2"}}
+	k := &osKeychain{run: fake.run, goos: "darwin"}
+
+	if got, err := k.get("db_password"); err != nil || got != "hunter2" {
+		t.Errorf("get = %q, %v", got, err)
+	}
+	if _, err := k.get("missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("get of a missing secret = %v, want ErrSecretNotFound", err)
+	}
+
+	if err := k.set("github.token", `say "hi" \o/`); err != nil {
+		t.Fatal(err)
+	}
+	last := len(fake.commands) - 1
+	if fake.commands[last] != "security -i" {
+		t.Errorf("expected the value to go through security -i, ran %q", fake.commands[last])
+	}
+	want := `add-generic-password -U -s "chat-cli" -a "github.token" -w "say \"hi\" \\o/"` + "\n"
+	if fake.stdin[last] != want {
+		t.Errorf("security -i input = %q, want %q", fake.stdin[last], want)
+	}
+}
@@ -18,4 +18,7 @@ type Config struct { //nolint:govet // fieldalignment is a minor optimization
 	Name     string
 	Username string
 	Password string
+	// URL is a full connection string; when set it takes precedence over
+	// the individual fields (PostgreSQL only).
+	URL string
 }
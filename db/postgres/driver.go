@@ -0,0 +1,8 @@
+//go:build postgres
+
+// db/postgres/driver.go
+package postgres
+
+import (
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
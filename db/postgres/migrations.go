@@ -0,0 +1,208 @@
+// db/postgres/migrations.go
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type PostgresMigration struct {
+	db *sql.DB
+}
+
+func NewPostgresMigration(db *sql.DB) *PostgresMigration {
+	return &PostgresMigration{db: db}
+}
+
+// MigrateUp creates the same schema as the SQLite migration, in
+// PostgreSQL's dialect.
+func (m *PostgresMigration) MigrateUp() error {
+
+	var err error
+
+	chatsTable := `
+	CREATE TABLE IF NOT EXISTS chats (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id TEXT NOT NULL,
+		persona TEXT NOT NULL,
+		message TEXT NOT NULL,
+		title TEXT,
+		image_path TEXT,
+		image_hash TEXT,
+		stop_reason TEXT,
+		superseded_at TIMESTAMP,
+		resumes INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	ALTER TABLE chats ADD COLUMN IF NOT EXISTS title TEXT;
+	ALTER TABLE chats ADD COLUMN IF NOT EXISTS image_path TEXT;
+	ALTER TABLE chats ADD COLUMN IF NOT EXISTS image_hash TEXT;
+	ALTER TABLE chats ADD COLUMN IF NOT EXISTS stop_reason TEXT;
+	ALTER TABLE chats ADD COLUMN IF NOT EXISTS superseded_at TIMESTAMP;
+	ALTER TABLE chats ADD COLUMN IF NOT EXISTS resumes INTEGER;
+
+	CREATE INDEX IF NOT EXISTS chats_chat_id_idx ON chats (chat_id);
+
+	-- Create trigger to update the updated_at timestamp
+	CREATE OR REPLACE FUNCTION chats_set_updated_at() RETURNS TRIGGER AS $$
+	BEGIN
+		NEW.updated_at = CURRENT_TIMESTAMP;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS chats_updated_at ON chats;
+	CREATE TRIGGER chats_updated_at
+	BEFORE UPDATE ON chats
+	FOR EACH ROW EXECUTE FUNCTION chats_set_updated_at();`
+
+	_, err = m.db.Exec(chatsTable)
+	if err != nil {
+		return fmt.Errorf("error creating chats table: %v", err)
+	}
+
+	imagesTable := `
+	CREATE TABLE IF NOT EXISTS images (
+		id BIGSERIAL PRIMARY KEY,
+		model_id TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		seed BIGINT NOT NULL,
+		scale REAL NOT NULL,
+		steps INTEGER NOT NULL,
+		filename TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = m.db.Exec(imagesTable)
+	if err != nil {
+		return fmt.Errorf("error creating images table: %v", err)
+	}
+
+	usageTable := `
+	CREATE TABLE IF NOT EXISTS token_usage (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id TEXT,
+		model_id TEXT NOT NULL,
+		profile_arn TEXT,
+		input_tokens INTEGER NOT NULL,
+		output_tokens INTEGER NOT NULL,
+		cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+		cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+		cost DOUBLE PRECISION,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = m.db.Exec(usageTable)
+	if err != nil {
+		return fmt.Errorf("error creating token_usage table: %v", err)
+	}
+
+	kbTable := `
+	CREATE TABLE IF NOT EXISTS kb_chunks (
+		id BIGSERIAL PRIMARY KEY,
+		kb TEXT NOT NULL,
+		source TEXT NOT NULL,
+		part INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		model_id TEXT NOT NULL,
+		embedding TEXT NOT NULL,
+		start_line INTEGER,
+		end_line INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	ALTER TABLE kb_chunks ADD COLUMN IF NOT EXISTS start_line INTEGER;
+	ALTER TABLE kb_chunks ADD COLUMN IF NOT EXISTS end_line INTEGER;
+
+	CREATE INDEX IF NOT EXISTS kb_chunks_kb ON kb_chunks (kb, source);`
+
+	_, err = m.db.Exec(kbTable)
+	if err != nil {
+		return fmt.Errorf("error creating kb_chunks table: %v", err)
+	}
+
+	chatNamesTable := `
+	CREATE TABLE IF NOT EXISTS chat_names (
+		workspace TEXT NOT NULL,
+		name TEXT NOT NULL,
+		chat_id TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (workspace, name)
+	);`
+
+	_, err = m.db.Exec(chatNamesTable)
+	if err != nil {
+		return fmt.Errorf("error creating chat_names table: %v", err)
+	}
+
+	annotationsTable := `
+	CREATE TABLE IF NOT EXISTS annotations (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id TEXT NOT NULL,
+		message_id BIGINT,
+		note TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS annotations_chat_id ON annotations (chat_id);`
+
+	_, err = m.db.Exec(annotationsTable)
+	if err != nil {
+		return fmt.Errorf("error creating annotations table: %v", err)
+	}
+
+	feedbackTable := `
+	CREATE TABLE IF NOT EXISTS feedback (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id TEXT NOT NULL,
+		message_id BIGINT,
+		model_id TEXT,
+		rating TEXT NOT NULL,
+		comment TEXT,
+		prompt TEXT NOT NULL,
+		response TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = m.db.Exec(feedbackTable)
+	if err != nil {
+		return fmt.Errorf("error creating feedback table: %v", err)
+	}
+
+	chatSettingsTable := `
+	CREATE TABLE IF NOT EXISTS chat_settings (
+		chat_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (chat_id, key)
+	);`
+
+	_, err = m.db.Exec(chatSettingsTable)
+	if err != nil {
+		return fmt.Errorf("error creating chat_settings table: %v", err)
+	}
+
+	return nil
+}
+
+func (m *PostgresMigration) MigrateDown() error {
+	dropTables := `
+	DROP TABLE IF EXISTS chats;
+	DROP FUNCTION IF EXISTS chats_set_updated_at();
+	DROP TABLE IF EXISTS images;
+	DROP TABLE IF EXISTS token_usage;
+	DROP TABLE IF EXISTS kb_chunks;
+	DROP TABLE IF EXISTS chat_names;
+	DROP TABLE IF EXISTS annotations;
+	DROP TABLE IF EXISTS feedback;
+	DROP TABLE IF EXISTS chat_settings;`
+
+	_, err := m.db.Exec(dropTables)
+	if err != nil {
+		return fmt.Errorf("error dropping tables: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// db/postgres/postgres.go
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// driverName is the database/sql driver used for PostgreSQL. It is
+// registered by driver.go, which is only compiled with the "postgres" build
+// tag so that the default build doesn't carry a PostgreSQL client.
+const driverName = "pgx"
+
+// defaultPort is used when the configuration doesn't give one.
+const defaultPort = 5432
+
+type PostgresDB struct {
+	db     *sql.DB
+	config db.Config
+}
+
+func (p *PostgresDB) Migrate() error {
+	migration := NewPostgresMigration(p.db)
+	return migration.MigrateUp()
+}
+
+func NewPostgresDB(config *db.Config) *PostgresDB {
+	return &PostgresDB{config: *config}
+}
+
+func (p *PostgresDB) Connect() error {
+	if !isDriverRegistered() {
+		return fmt.Errorf("postgres connection error: this build of chat-cli doesn't include PostgreSQL support (build with -tags postgres)")
+	}
+
+	dsn, err := ConnectionString(&p.config)
+	if err != nil {
+		return fmt.Errorf("postgres connection error: %v", err)
+	}
+
+	database, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("postgres connection error: %v", err)
+	}
+
+	// unlike SQLite, a bad host or password should fail here rather than on
+	// the first query
+	if err := database.Ping(); err != nil {
+		_ = database.Close()
+		return fmt.Errorf("postgres connection error: %v", err)
+	}
+
+	p.db = database
+	return nil
+}
+
+// isDriverRegistered reports whether this build includes the PostgreSQL
+// driver.
+func isDriverRegistered() bool {
+	return slices.Contains(sql.Drivers(), driverName)
+}
+
+func (p *PostgresDB) GetDB() *sql.DB {
+	return p.db
+}
+
+func (p *PostgresDB) Close() error {
+	if p.db == nil {
+		return nil
+	}
+	return p.db.Close()
+}
+
+// ConnectionString returns the connection URL for config: its URL as-is if
+// set, otherwise one built from the host, port, database name, and
+// credentials.
+func ConnectionString(config *db.Config) (string, error) {
+	if config.URL != "" {
+		return config.URL, nil
+	}
+
+	if config.Host == "" || config.Name == "" {
+		return "", fmt.Errorf("set db_url, or db_host and db_name, to connect to PostgreSQL")
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(config.Host, strconv.Itoa(port)),
+		Path:   "/" + config.Name,
+	}
+	switch {
+	case config.Username != "" && config.Password != "":
+		u.User = url.UserPassword(config.Username, config.Password)
+	case config.Username != "":
+		u.User = url.User(config.Username)
+	}
+
+	return u.String(), nil
+}
@@ -4,6 +4,7 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 type SQLiteMigration struct {
@@ -24,6 +25,12 @@ func (m *SQLiteMigration) MigrateUp() error {
 		chat_id TEXT NOT NULL,
 		persona TEXT NOT NULL,
 		message TEXT NOT NULL,
+		title TEXT,
+		image_path TEXT,
+		image_hash TEXT,
+		stop_reason TEXT,
+		superseded_at DATETIME,
+		resumes INTEGER,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -41,6 +48,168 @@ func (m *SQLiteMigration) MigrateUp() error {
 		return fmt.Errorf("error creating users table: %v", err)
 	}
 
+	// databases created before chats had titles, image references, stop
+	// reasons or superseded replies need the columns added; SQLite has no
+	// ADD COLUMN IF NOT EXISTS
+	for _, column := range []string{"title TEXT", "image_path TEXT", "image_hash TEXT", "stop_reason TEXT", "superseded_at DATETIME", "resumes INTEGER"} {
+		name, _, _ := strings.Cut(column, " ")
+		var exists int
+		err = m.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('chats') WHERE name = ?`, name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error inspecting chats table: %v", err)
+		}
+		if exists == 0 {
+			if _, err = m.db.Exec(`ALTER TABLE chats ADD COLUMN ` + column); err != nil {
+				return fmt.Errorf("error adding %s to chats table: %v", name, err)
+			}
+		}
+	}
+
+	imagesTable := `
+	CREATE TABLE IF NOT EXISTS images (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		model_id TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		seed INTEGER NOT NULL,
+		scale REAL NOT NULL,
+		steps INTEGER NOT NULL,
+		filename TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = m.db.Exec(imagesTable)
+	if err != nil {
+		return fmt.Errorf("error creating images table: %v", err)
+	}
+
+	// one row per model request made in chat; profile_arn is set when the
+	// request went through an inference profile ARN, and cost is NULL for
+	// models without a known price
+	usageTable := `
+	CREATE TABLE IF NOT EXISTS token_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id TEXT,
+		model_id TEXT NOT NULL,
+		profile_arn TEXT,
+		input_tokens INTEGER NOT NULL,
+		output_tokens INTEGER NOT NULL,
+		cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+		cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = m.db.Exec(usageTable)
+	if err != nil {
+		return fmt.Errorf("error creating token_usage table: %v", err)
+	}
+
+	// chunks of the documents added to local knowledge bases, with their
+	// embeddings stored as JSON arrays and the lines of the document they
+	// came from
+	kbTable := `
+	CREATE TABLE IF NOT EXISTS kb_chunks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kb TEXT NOT NULL,
+		source TEXT NOT NULL,
+		part INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		model_id TEXT NOT NULL,
+		embedding TEXT NOT NULL,
+		start_line INTEGER,
+		end_line INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS kb_chunks_kb ON kb_chunks (kb, source);`
+
+	_, err = m.db.Exec(kbTable)
+	if err != nil {
+		return fmt.Errorf("error creating kb_chunks table: %v", err)
+	}
+
+	// chunks added before line ranges were kept have none
+	for _, name := range []string{"start_line", "end_line"} {
+		var exists int
+		err = m.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('kb_chunks') WHERE name = ?`, name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error inspecting kb_chunks table: %v", err)
+		}
+		if exists == 0 {
+			if _, err = m.db.Exec(`ALTER TABLE kb_chunks ADD COLUMN ` + name + ` INTEGER`); err != nil {
+				return fmt.Errorf("error adding %s to kb_chunks table: %v", name, err)
+			}
+		}
+	}
+
+	// human-friendly chat names from --chat-name, unique within a workspace
+	// (the git repository or directory chat-cli was started in)
+	chatNamesTable := `
+	CREATE TABLE IF NOT EXISTS chat_names (
+		workspace TEXT NOT NULL,
+		name TEXT NOT NULL,
+		chat_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (workspace, name)
+	);`
+
+	_, err = m.db.Exec(chatNamesTable)
+	if err != nil {
+		return fmt.Errorf("error creating chat_names table: %v", err)
+	}
+
+	// notes from chat annotate; message_id is the annotated row in chats,
+	// or NULL for a note on the whole chat
+	annotationsTable := `
+	CREATE TABLE IF NOT EXISTS annotations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id TEXT NOT NULL,
+		message_id INTEGER,
+		note TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS annotations_chat_id ON annotations (chat_id);`
+
+	_, err = m.db.Exec(annotationsTable)
+	if err != nil {
+		return fmt.Errorf("error creating annotations table: %v", err)
+	}
+
+	// ratings from /thumbs, with copies of the prompt and reply so they
+	// outlive the chat they came from
+	feedbackTable := `
+	CREATE TABLE IF NOT EXISTS feedback (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id TEXT NOT NULL,
+		message_id INTEGER,
+		model_id TEXT,
+		rating TEXT NOT NULL,
+		comment TEXT,
+		prompt TEXT NOT NULL,
+		response TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = m.db.Exec(feedbackTable)
+	if err != nil {
+		return fmt.Errorf("error creating feedback table: %v", err)
+	}
+
+	// inference settings changed with /set, restored when the chat is
+	// resumed
+	chatSettingsTable := `
+	CREATE TABLE IF NOT EXISTS chat_settings (
+		chat_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (chat_id, key)
+	);`
+
+	_, err = m.db.Exec(chatSettingsTable)
+	if err != nil {
+		return fmt.Errorf("error creating chat_settings table: %v", err)
+	}
+
 	return nil
 }
 
@@ -48,7 +217,14 @@ func (m *SQLiteMigration) MigrateDown() error {
 	// Drop the users table and its trigger
 	dropTables := `
 	DROP TRIGGER IF EXISTS chats_updated_at;
-    DROP TABLE IF EXISTS chats;`
+    DROP TABLE IF EXISTS chats;
+	DROP TABLE IF EXISTS images;
+	DROP TABLE IF EXISTS token_usage;
+	DROP TABLE IF EXISTS kb_chunks;
+	DROP TABLE IF EXISTS chat_names;
+	DROP TABLE IF EXISTS annotations;
+	DROP TABLE IF EXISTS feedback;
+	DROP TABLE IF EXISTS chat_settings;`
 
 	_, err := m.db.Exec(dropTables)
 	if err != nil {
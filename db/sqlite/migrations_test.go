@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestMigrateUpAddsColumnsToExistingChats(t *testing.T) {
+	database, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	// keep every query on the one in-memory database
+	database.SetMaxOpenConns(1)
+
+	// the chats table as created before titles and image references existed
+	if _, err := database.Exec(`
+	CREATE TABLE chats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id TEXT NOT NULL,
+		persona TEXT NOT NULL,
+		message TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO chats (chat_id, persona, message) VALUES ('chat-1', 'User', 'Hello');`); err != nil {
+		t.Fatal(err)
+	}
+
+	migration := NewSQLiteMigration(database)
+	for i := 0; i < 2; i++ {
+		if err := migration.MigrateUp(); err != nil {
+			t.Fatalf("MigrateUp run %d: %v", i+1, err)
+		}
+	}
+
+	if _, err := database.Exec(`UPDATE chats SET title = 'Greeting' WHERE chat_id = 'chat-1'`); err != nil {
+		t.Errorf("expected the title column to exist: %v", err)
+	}
+	if _, err := database.Exec(`UPDATE chats SET image_path = '/tmp/a.png', image_hash = 'abc' WHERE chat_id = 'chat-1'`); err != nil {
+		t.Errorf("expected the image columns to exist: %v", err)
+	}
+	if _, err := database.Exec(`UPDATE chats SET stop_reason = 'end_turn', superseded_at = CURRENT_TIMESTAMP WHERE chat_id = 'chat-1'`); err != nil {
+		t.Errorf("expected the stop reason and superseded columns to exist: %v", err)
+	}
+	if _, err := database.Exec(`UPDATE chats SET resumes = 1 WHERE chat_id = 'chat-1'`); err != nil {
+		t.Errorf("expected the resumes column to exist: %v", err)
+	}
+}
+
+func TestMigrateUpAddsLineRangesToExistingKBChunks(t *testing.T) {
+	database, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = database.Close()
+	}()
+	database.SetMaxOpenConns(1)
+
+	// kb_chunks as created before line ranges were recorded
+	if _, err := database.Exec(`
+	CREATE TABLE kb_chunks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kb TEXT NOT NULL,
+		source TEXT NOT NULL,
+		part INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		model_id TEXT NOT NULL,
+		embedding TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO kb_chunks (kb, source, part, content, model_id, embedding) VALUES ('pets', '/pets.md', 1, 'Cats', 'm', '[1]');`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewSQLiteMigration(database).MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`UPDATE kb_chunks SET start_line = 1, end_line = 3 WHERE kb = 'pets'`); err != nil {
+		t.Errorf("expected the line range columns to exist: %v", err)
+	}
+}
@@ -4,6 +4,9 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
 
 	"github.com/chat-cli/chat-cli/db"
 
@@ -40,3 +43,25 @@ func (s *SQLiteDB) GetDB() *sql.DB {
 func (s *SQLiteDB) Close() error {
 	return s.db.Close()
 }
+
+// OpenReadOnly opens the database file at path so that nothing can be
+// written through the handle: the file is opened read-only and every
+// connection has query_only set, which also refuses writes to temporary
+// tables.
+func OpenReadOnly(path string) (*sql.DB, error) {
+	name := filepath.ToSlash(path)
+	if !strings.HasPrefix(name, "/") {
+		// Windows drive paths need a leading slash in a file: URI
+		name = "/" + name
+	}
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     name,
+		RawQuery: "mode=ro&_pragma=query_only(1)",
+	}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite connection error: %v", err)
+	}
+	return db, nil
+}
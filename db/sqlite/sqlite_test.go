@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.Exec(`CREATE TABLE chats (message TEXT); INSERT INTO chats VALUES ('hello');`); err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ro.Close()
+	}()
+
+	var message string
+	if err := ro.QueryRow(`SELECT message FROM chats`).Scan(&message); err != nil || message != "hello" {
+		t.Fatalf("expected to read the row, got %q (%v)", message, err)
+	}
+	for _, stmt := range []string{
+		`DELETE FROM chats`,
+		`CREATE TABLE notes (body TEXT)`,
+		`CREATE TEMP TABLE scratch (body TEXT)`,
+	} {
+		if _, err := ro.Exec(stmt); err == nil {
+			t.Errorf("expected %q to be refused", stmt)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package documents extracts plain text from PDF, Word (.docx), HTML, and
+// text files, so their contents can be put in front of a model as part of
+// a prompt.
+package documents
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// ErrUnsupportedFormat is returned by Load for files whose text can't be
+// extracted.
+var ErrUnsupportedFormat = errors.New("unsupported file type")
+
+// ErrNoText is returned when a file has no text to extract, such as a
+// scanned PDF.
+var ErrNoText = errors.New("no extractable text")
+
+// Document is the text extracted from a file.
+type Document struct {
+	// Path is the path the file was loaded from, as given.
+	Path string
+	// Format is the file's type: "pdf", "docx", "html", or "text".
+	Format string
+	Text   string
+	// lineOffset is how many lines were trimmed from the top of the text,
+	// so chunk line numbers can match the file's.
+	lineOffset int
+}
+
+// extractors turns a file's contents into text, keyed by file extension.
+var extractors = map[string]func([]byte) (string, error){
+	"pdf":  extractPDF,
+	"docx": extractDOCX,
+	"html": extractHTML,
+	"txt":  extractText,
+	"md":   extractText,
+	"csv":  extractText,
+}
+
+// Supported reports whether Load can extract text from the file at path,
+// judging by its extension.
+func Supported(path string) bool {
+	_, ok := extractors[strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")]
+	return ok
+}
+
+// Load reads the file at path and extracts its text. Paths are resolved
+// the same way as other attachments, relative to the working directory.
+func Load(path string) (*Document, error) {
+	data, format, err := utils.ReadDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	extract, ok := extractors[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (supported: pdf, docx, html, txt, md, csv)", ErrUnsupportedFormat, format)
+	}
+
+	text, err := extract(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract text from %s: %w", path, err)
+	}
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil, fmt.Errorf("%s: %w", path, ErrNoText)
+	}
+	lineOffset := strings.Count(text[:strings.Index(text, trimmed)], "\n")
+
+	switch format {
+	case "txt", "md", "csv":
+		format = "text"
+	}
+	return &Document{Path: path, Format: format, Text: trimmed, lineOffset: lineOffset}, nil
+}
+
+// Chunk is one part of a document's text.
+type Chunk struct {
+	// Part and Parts number this chunk among the document's chunks,
+	// starting at 1.
+	Part  int
+	Parts int
+	Text  string
+	// StartLine and EndLine are the lines of the document's text the chunk
+	// covers, from 1 and inclusive. For text files they're the file's own
+	// lines; for PDF, Word and HTML files, those of the extracted text.
+	StartLine int
+	EndLine   int
+}
+
+// Chunks splits the document's text on paragraph boundaries into chunks
+// of at most maxTokens (estimated at ~4 characters per token).
+func (d *Document) Chunks(maxTokens int) []Chunk {
+	// the .txt name makes ChunkSource split on paragraphs, whatever the
+	// document's original format
+	sourceChunks := utils.ChunkSource("document.txt", []byte(d.Text), maxTokens)
+
+	chunks := make([]Chunk, 0, len(sourceChunks))
+	for i, c := range sourceChunks {
+		text := strings.TrimSpace(c.Content)
+		// the lines of blank space trimmed from either end aren't covered
+		start := c.StartLine + strings.Count(c.Content[:strings.Index(c.Content, text)], "\n")
+		end := start + strings.Count(text, "\n")
+		chunks = append(chunks, Chunk{
+			Part:      i + 1,
+			Parts:     len(sourceChunks),
+			Text:      text,
+			StartLine: start + d.lineOffset,
+			EndLine:   end + d.lineOffset,
+		})
+	}
+	return chunks
+}
+
+func extractText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", errors.New("file is not valid UTF-8 text")
+	}
+	return string(data), nil
+}
+
+// collapseSpaces trims each line of s, collapses runs of spaces and tabs
+// within it, and drops runs of more than one blank line.
+func collapseSpaces(s string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if blank || len(lines) == 0 {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,109 @@
+package documents
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("extracts by file type", func(t *testing.T) {
+		doc, err := Load(write("report.docx", buildDOCX(t, `<w:p><w:r><w:t>Hello</w:t></w:r></w:p>`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Format != "docx" || doc.Text != "Hello" {
+			t.Errorf("unexpected document %+v", doc)
+		}
+
+		doc, err = Load(write("notes.md", []byte("# Notes\n\nSome text.\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Format != "text" || doc.Text != "# Notes\n\nSome text." {
+			t.Errorf("unexpected document %+v", doc)
+		}
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		if _, err := Load(write("sheet.xlsx", []byte("PK"))); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+		}
+	})
+
+	t.Run("no text", func(t *testing.T) {
+		if _, err := Load(write("scan.pdf", buildPDF(t, true, "q 100 0 0 100 0 0 cm /Im1 Do Q"))); !errors.Is(err, ErrNoText) {
+			t.Errorf("expected ErrNoText, got %v", err)
+		}
+	})
+}
+
+func TestChunks(t *testing.T) {
+	paragraph := strings.Repeat("word ", 30) + "\n\n"
+	doc := &Document{Path: "long.pdf", Text: strings.Repeat(paragraph, 10)}
+
+	chunks := doc.Chunks(100)
+	if len(chunks) < 2 {
+		t.Fatalf("expected the text to be split, got %d chunk(s)", len(chunks))
+	}
+	for i, c := range chunks {
+		if c.Part != i+1 || c.Parts != len(chunks) {
+			t.Errorf("chunk %d numbered %d/%d", i, c.Part, c.Parts)
+		}
+		if len(c.Text) > 400 {
+			t.Errorf("chunk %d has %d characters, over the 100-token budget", i, len(c.Text))
+		}
+	}
+}
+
+func TestChunkLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.md")
+	text := "\n\n# Cats\nCats purr.\n\n\n# Dogs\nDogs bark.\nDogs fetch.\n"
+	if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a small budget puts each paragraph in its own chunk
+	chunks := doc.Chunks(8)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %+v", chunks)
+	}
+	// lines are the file's, counting the blank ones Load trimmed
+	if c := chunks[0]; c.StartLine != 3 || c.EndLine != 4 {
+		t.Errorf("expected the first chunk on lines 3-4, got %d-%d", c.StartLine, c.EndLine)
+	}
+	if c := chunks[1]; c.StartLine != 7 || c.EndLine != 9 || c.Text != "# Dogs\nDogs bark.\nDogs fetch." {
+		t.Errorf("expected the second chunk on lines 7-9, got %d-%d %q", c.StartLine, c.EndLine, c.Text)
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for path, want := range map[string]bool{
+		"notes.md":       true,
+		"Report.PDF":     true,
+		"dir/page.html":  true,
+		"sheet.xlsx":     false,
+		"main.go":        false,
+		"no-extension":   false,
+		"archive.tar.gz": false,
+	} {
+		if got := Supported(path); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
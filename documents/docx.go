@@ -0,0 +1,104 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxDOCXXMLBytes caps how much of word/document.xml is read, so a zip
+// bomb can't exhaust memory.
+const maxDOCXXMLBytes = 64 << 20
+
+// extractDOCX reads the body text of a Word document: paragraphs become
+// lines and table cells are separated by tabs. Headers, footers, and
+// comments are left out.
+func extractDOCX(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("not a .docx file: %w", err)
+	}
+
+	var body *zip.File
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			body = f
+			break
+		}
+	}
+	if body == nil {
+		return "", errors.New("not a .docx file: word/document.xml is missing")
+	}
+
+	r, err := body.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var b strings.Builder
+	inText := false
+	decoder := xml.NewDecoder(io.LimitReader(r, maxDOCXXMLBytes))
+	for {
+		tok, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to parse document body: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "br", "cr":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p", "tr":
+				b.WriteString("\n")
+			case "tc":
+				b.WriteString("\t")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+
+	return collapseDOCXText(b.String()), nil
+}
+
+// collapseDOCXText trims trailing whitespace from each line and drops runs
+// of blank lines, keeping the tabs between table cells.
+func collapseDOCXText(s string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank || len(lines) == 0 {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
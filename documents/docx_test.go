@@ -0,0 +1,63 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func buildDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	f, err := w.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body + `</w:body></w:document>`
+	if _, err := f.Write([]byte(xml)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractDOCX(t *testing.T) {
+	body := `<w:p><w:r><w:t>Meeting </w:t></w:r><w:r><w:t>notes</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Owner:</w:t><w:tab/><w:t>Sam &amp; Lee</w:t></w:r></w:p>` +
+		`<w:p/><w:p/>` +
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Q1</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>10</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+		`<w:p><w:r><w:instrText>PAGE</w:instrText><w:t>Done</w:t></w:r></w:p>`
+
+	text, err := extractDOCX(buildDOCX(t, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Meeting notes\nOwner:\tSam & Lee\n\nQ1\n\t10\n\nDone"
+	if text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+}
+
+func TestExtractDOCXRejectsOtherFiles(t *testing.T) {
+	if _, err := extractDOCX([]byte("not a zip")); err == nil {
+		t.Error("expected an error for a non-zip file")
+	}
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	if _, err := w.Create("content.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractDOCX(b.Bytes()); err == nil {
+		t.Error("expected an error for a zip without word/document.xml")
+	}
+}
@@ -0,0 +1,102 @@
+package documents
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// htmlSkipped matches elements whose content is never text. They're
+// removed before parsing, since scripts in particular rarely parse as
+// markup.
+var htmlSkipped = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)<script\b.*?</script\s*>`),
+	regexp.MustCompile(`(?is)<style\b.*?</style\s*>`),
+	regexp.MustCompile(`(?is)<noscript\b.*?</noscript\s*>`),
+	regexp.MustCompile(`(?is)<svg\b.*?</svg\s*>`),
+}
+
+// htmlBlocks are the elements that start a new line.
+var htmlBlocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"title": true, "tr": true, "ul": true,
+}
+
+// extractHTML returns the visible text of an HTML page, one line per block
+// element. Parsing is lenient; if the markup breaks off partway, the text
+// up to that point is kept.
+func extractHTML(data []byte) (string, error) {
+	for _, pattern := range htmlSkipped {
+		data = pattern.ReplaceAll(data, nil)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var b strings.Builder
+	pre := 0
+	for {
+		tok, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if strings.TrimSpace(b.String()) == "" {
+				return "", fmt.Errorf("unable to parse HTML: %w", err)
+			}
+			break
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if htmlBlocks[name] {
+				b.WriteString("\n")
+			}
+			if name == "pre" {
+				pre++
+			}
+			if name == "td" || name == "th" {
+				b.WriteString("\t")
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if htmlBlocks[name] {
+				b.WriteString("\n")
+			}
+			if name == "pre" && pre > 0 {
+				pre--
+			}
+		case xml.CharData:
+			if pre > 0 {
+				b.Write(t)
+			} else {
+				// outside <pre>, runs of whitespace in the source are a
+				// single space
+				if len(t) > 0 && isHTMLSpace(t[0]) {
+					b.WriteString(" ")
+				}
+				b.WriteString(strings.Join(strings.Fields(string(t)), " "))
+				if len(t) > 0 && isHTMLSpace(t[len(t)-1]) {
+					b.WriteString(" ")
+				}
+			}
+		}
+	}
+
+	return collapseSpaces(b.String()), nil
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
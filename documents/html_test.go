@@ -0,0 +1,26 @@
+package documents
+
+import "testing"
+
+func TestExtractHTML(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title>Release Notes</title>
+<style>body { color: red; }</style>
+<script>if (a < b && c) { render(); }</script></head>
+<body>
+<h1>Version 2.0</h1>
+<p>Adds <b>faster</b> sync
+and&nbsp;fixes bugs.<br>Thanks to all.</p>
+<ul><li>One<li>Two</ul>
+<img src="logo.png">
+</body></html>`
+
+	text, err := extractHTML([]byte(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Release Notes\n\nVersion 2.0\n\nAdds faster sync and fixes bugs.\n\nThanks to all.\n\nOne\nTwo"
+	if text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+}
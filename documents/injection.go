@@ -0,0 +1,180 @@
+package documents
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of Injection.
+const (
+	// InjectionInstruction is text telling the model to drop its
+	// instructions, or marking the start of new ones.
+	InjectionInstruction = "instruction"
+	// InjectionComment is an HTML comment, invisible once rendered, that
+	// addresses the model.
+	InjectionComment = "hidden comment"
+	// InjectionInvisible is text in Unicode tag characters, which most
+	// fonts don't draw but models read.
+	InjectionInvisible = "invisible text"
+)
+
+// InjectionNotice replaces what StripInjections removes, so the model can
+// tell something was there.
+const InjectionNotice = "[removed by chat-cli: possible prompt injection]"
+
+// injectionPatterns match the phrasing commonly used to hijack a model
+// through a document it's asked to read.
+var injectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+|my\s+)?(previous|prior|above|earlier|preceding|original|system)\s+(instructions?|prompts?|directions|rules|guidelines)`),
+	regexp.MustCompile(`(?i)\b(new|updated|real)\s+(system\s+)?instructions\s*:`),
+	regexp.MustCompile(`(?i)\b(reveal|print|repeat|output)\s+(your|the)\s+(system\s+prompt|instructions)`),
+	regexp.MustCompile(`(?i)\b(do\s+not|don't)\s+(tell|inform|alert)\s+the\s+user`),
+	regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(in\s+)?(developer\s+mode|DAN|jailbroken|unrestricted)`),
+	regexp.MustCompile(`<\|im_start\|>|\[/?INST\]|<</?SYS>>`),
+}
+
+var (
+	htmlComment = regexp.MustCompile(`(?s)<!--(.*?)-->`)
+	// commentAddressee matches a comment written for the model rather
+	// than whoever maintains the page.
+	commentAddressee = regexp.MustCompile(`(?i)\b(AI|assistants?|LLMs?|language\s+models?|chatbots?)\b`)
+)
+
+// Injection is a passage of a document that looks written to instruct the
+// model reading it rather than the person who attached it.
+type Injection struct {
+	Kind string
+	// Line is the 1-based line the passage starts on.
+	Line int
+	// Excerpt is the passage, shortened to fit on one line.
+	Excerpt string
+
+	start, end int
+}
+
+// FindInjections returns the passages of text that look like prompt
+// injection, in order. It's a heuristic: it catches the common phrasings,
+// not a determined attacker, and may flag a document about the subject.
+func FindInjections(text string) []Injection {
+	var found []Injection
+	add := func(kind string, start, end int, excerpt string) {
+		for _, f := range found {
+			if start < f.end && f.start < end {
+				return
+			}
+		}
+		found = append(found, Injection{
+			Kind:    kind,
+			Line:    strings.Count(text[:start], "\n") + 1,
+			Excerpt: shortExcerpt(excerpt),
+			start:   start,
+			end:     end,
+		})
+	}
+
+	for _, m := range htmlComment.FindAllStringSubmatchIndex(text, -1) {
+		body := text[m[2]:m[3]]
+		if commentAddressee.MatchString(body) || matchesInjection(body) {
+			add(InjectionComment, m[0], m[1], body)
+		}
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isTagRune(r) {
+			i += size
+			continue
+		}
+		start := i
+		var hidden strings.Builder
+		for i < len(text) {
+			r, size = utf8.DecodeRuneInString(text[i:])
+			if !isTagRune(r) {
+				break
+			}
+			// tag characters mirror ASCII, offset by U+E0000
+			if c := r - 0xE0000; c >= 0x20 && c < 0x7F {
+				hidden.WriteRune(c)
+			}
+			i += size
+		}
+		add(InjectionInvisible, start, i, hidden.String())
+	}
+
+	for _, pattern := range injectionPatterns {
+		for _, m := range pattern.FindAllStringIndex(text, -1) {
+			start, end := lineBounds(text, m[0], m[1])
+			add(InjectionInstruction, start, end, text[start:end])
+		}
+	}
+
+	slices.SortFunc(found, func(a, b Injection) int { return a.start - b.start })
+	return found
+}
+
+// StripInjections returns text with each passage FindInjections reports
+// removed: hidden comments and invisible text entirely, and the whole line
+// around an instruction, replaced with InjectionNotice.
+func StripInjections(text string) string {
+	// removing a passage can leave one that overlapped it, such as an
+	// instruction on the same line as a comment, so go again until
+	// nothing's found
+	for {
+		found := FindInjections(text)
+		if len(found) == 0 {
+			return text
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, f := range found {
+			b.WriteString(text[last:f.start])
+			if f.Kind == InjectionInstruction {
+				b.WriteString(InjectionNotice)
+			}
+			last = f.end
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+}
+
+func matchesInjection(text string) bool {
+	for _, pattern := range injectionPatterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTagRune(r rune) bool {
+	return r >= 0xE0000 && r <= 0xE007F
+}
+
+// lineBounds widens start and end to the line they're on, without its
+// newline.
+func lineBounds(text string, start, end int) (int, int) {
+	if i := strings.LastIndexByte(text[:start], '\n'); i >= 0 {
+		start = i + 1
+	} else {
+		start = 0
+	}
+	if i := strings.IndexByte(text[end:], '\n'); i >= 0 {
+		end += i
+	} else {
+		end = len(text)
+	}
+	return start, end
+}
+
+// shortExcerpt collapses s to a single line of at most 80 characters.
+func shortExcerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= 80 {
+		return s
+	}
+	return string([]rune(s)[:77]) + "..."
+}
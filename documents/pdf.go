@@ -0,0 +1,389 @@
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamBytes caps how much a single content stream may inflate to,
+// so a malformed or hostile file can't exhaust memory.
+const maxPDFStreamBytes = 64 << 20
+
+var (
+	pdfStreamStart = regexp.MustCompile(`stream\r?\n`)
+	pdfFilter      = regexp.MustCompile(`/Filter\s*(\[\s*)?/(\w+)`)
+)
+
+// extractPDF pulls the text out of a PDF's content streams, in file order.
+// It handles text drawn with the standard text operators in uncompressed
+// or Flate-compressed streams, which covers most PDFs produced by word
+// processors. Text in fonts with custom encodings can come out garbled,
+// and scanned pages have no text to extract at all.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("encrypted PDFs aren't supported")
+	}
+
+	var text strings.Builder
+	for _, loc := range pdfStreamStart.FindAllIndex(data, -1) {
+		dict := pdfStreamDict(data[:loc[0]])
+		if dict == "" || strings.Contains(dict, "/Image") || strings.Contains(dict, "/FontFile") || strings.Contains(dict, "/XRef") {
+			continue
+		}
+
+		end := bytes.Index(data[loc[1]:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		raw := data[loc[1] : loc[1]+end]
+
+		content, ok := decodePDFStream(dict, raw)
+		if !ok {
+			continue
+		}
+		text.WriteString(pdfContentText(content))
+	}
+
+	return collapseSpaces(text.String()), nil
+}
+
+// pdfStreamDict returns the dictionary directly before a stream keyword,
+// or "" if there isn't one.
+func pdfStreamDict(before []byte) string {
+	before = bytes.TrimRight(before, "\r\n\t ")
+	if !bytes.HasSuffix(before, []byte(">>")) {
+		return ""
+	}
+
+	// walk back to the matching "<<", allowing for nested dictionaries
+	depth := 0
+	for i := len(before) - 2; i >= 0; i-- {
+		switch {
+		case before[i] == '>' && before[i+1] == '>':
+			depth++
+			i--
+		case before[i] == '<' && before[i+1] == '<':
+			depth--
+			if depth == 0 {
+				return string(before[i:])
+			}
+			i--
+		}
+	}
+	return ""
+}
+
+// decodePDFStream applies the stream's filter. Only unfiltered and
+// FlateDecode streams are supported; anything else is skipped.
+func decodePDFStream(dict string, raw []byte) ([]byte, bool) {
+	match := pdfFilter.FindStringSubmatch(dict)
+	if match == nil {
+		return raw, true
+	}
+	if match[2] != "FlateDecode" {
+		return nil, false
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	// a stream truncated by a trailing newline still inflates; keep what
+	// was read
+	decoded, err := io.ReadAll(io.LimitReader(r, maxPDFStreamBytes))
+	if err != nil && len(decoded) == 0 {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// pdfContentText interprets the text operators in a content stream,
+// starting a new line wherever the text position moves down the page.
+func pdfContentText(content []byte) string {
+	var b strings.Builder
+	var operands []pdfToken
+	inText := false
+
+	lex := &pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch tok.value {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			b.WriteString("\n")
+		case "Tj":
+			if inText {
+				writePDFStrings(&b, operands)
+			}
+		case "'", "\"":
+			if inText {
+				b.WriteString("\n")
+				writePDFStrings(&b, operands)
+			}
+		case "TJ":
+			if inText {
+				writePDFStrings(&b, operands)
+			}
+		case "T*":
+			b.WriteString("\n")
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, err := strconv.ParseFloat(operands[len(operands)-1].value, 64); err == nil && ty != 0 {
+					b.WriteString("\n")
+				} else {
+					b.WriteString(" ")
+				}
+			}
+		case "Tm":
+			b.WriteString("\n")
+		}
+		operands = operands[:0]
+	}
+
+	return b.String()
+}
+
+// writePDFStrings writes the string operands of a text operator. A large
+// negative adjustment inside a TJ array is a word gap and becomes a space.
+func writePDFStrings(b *strings.Builder, operands []pdfToken) {
+	for _, op := range operands {
+		switch op.kind {
+		case pdfString:
+			b.WriteString(decodePDFString(op.value))
+		case pdfNumber:
+			if n, err := strconv.ParseFloat(op.value, 64); err == nil && n < -200 {
+				b.WriteString(" ")
+			}
+		}
+	}
+}
+
+// decodePDFString converts string bytes to text: UTF-16 when marked with
+// a byte order mark or when it looks like two-byte text, and otherwise a
+// byte per character.
+func decodePDFString(s string) string {
+	raw := []byte(s)
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		return decodeUTF16BE(raw[2:])
+	}
+	if len(raw) >= 2 && len(raw)%2 == 0 {
+		zeros := 0
+		for i := 0; i < len(raw); i += 2 {
+			if raw[i] == 0 {
+				zeros++
+			}
+		}
+		if zeros == len(raw)/2 {
+			return decodeUTF16BE(raw)
+		}
+	}
+
+	runes := make([]rune, 0, len(raw))
+	for _, c := range raw {
+		if c >= 0x20 || c == '\t' || c == '\n' {
+			runes = append(runes, rune(c))
+		}
+	}
+	return string(runes)
+}
+
+func decodeUTF16BE(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfOther
+)
+
+type pdfToken struct {
+	kind  pdfTokenKind
+	value string
+}
+
+// pdfLexer splits a content stream into tokens. Strings are returned
+// decoded from their literal or hex form; array brackets are dropped, so a
+// TJ array's elements arrive as ordinary operands.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c) || c == '[' || c == ']':
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, value: l.literalString()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return pdfToken{kind: pdfOther, value: "<<"}, true
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfToken{kind: pdfOther, value: ">>"}, true
+		case c == '<':
+			return pdfToken{kind: pdfString, value: l.hexString()}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			l.word()
+			return pdfToken{kind: pdfOther, value: string(l.data[start:l.pos])}, true
+		default:
+			start := l.pos
+			l.word()
+			if l.pos == start {
+				// a stray delimiter
+				l.pos++
+				continue
+			}
+			word := string(l.data[start:l.pos])
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				return pdfToken{kind: pdfNumber, value: word}, true
+			}
+			// inline image data is binary; skip to its end marker
+			if word == "ID" {
+				if end := bytes.Index(l.data[l.pos:], []byte("EI")); end >= 0 {
+					l.pos += end + 2
+				} else {
+					l.pos = len(l.data)
+				}
+				continue
+			}
+			return pdfToken{kind: pdfOperator, value: word}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// word advances past a run of regular characters.
+func (l *pdfLexer) word() {
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) literalString() string {
+	var b []byte
+	depth := 0
+	l.pos++ // opening paren
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			b = append(b, c)
+		case ')':
+			if depth == 0 {
+				return string(b)
+			}
+			depth--
+			b = append(b, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return string(b)
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// line continuation
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = append(b, byte(n))
+				} else {
+					b = append(b, e)
+				}
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+func (l *pdfLexer) hexString() string {
+	l.pos++ // opening angle bracket
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; isHexDigit(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		b[i] = byte(n)
+	}
+	return string(b)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,106 @@
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF wraps content streams in just enough PDF structure for
+// extractPDF, compressing them when compress is set.
+func buildPDF(t *testing.T, compress bool, contents ...string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, content := range contents {
+		data := []byte(content)
+		filter := ""
+		if compress {
+			var z bytes.Buffer
+			w := zlib.NewWriter(&z)
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data = z.Bytes()
+			filter = " /Filter /FlateDecode"
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d%s >>\nstream\n", i+1, len(data), filter)
+		b.Write(data)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	page1 := "BT /F1 12 Tf 72 720 Td (Quarterly Report) Tj 0 -14 Td (Revenue grew \\(a lot\\).) Tj ET"
+	page2 := "BT /F1 12 Tf 72 720 Td [(Sum)-20(mary)-400(section)] TJ T* <FEFF00480069> Tj ET"
+
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed=%v", compress), func(t *testing.T) {
+			text, err := extractPDF(buildPDF(t, compress, page1, page2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "Quarterly Report\nRevenue grew (a lot).\n\nSummary section\nHi"
+			if text != want {
+				t.Errorf("got %q, want %q", text, want)
+			}
+		})
+	}
+
+	t.Run("skips images and unsupported filters", func(t *testing.T) {
+		data := buildPDF(t, false, "BT (Visible) Tj ET")
+		data = append(data, []byte("9 0 obj\n<< /Subtype /Image /Length 4 >>\nstream\nBT (Hidden) Tj ET\nendstream\n")...)
+		data = append(data, []byte("10 0 obj\n<< /Filter /DCTDecode /Length 4 >>\nstream\nBT (Jpeg) Tj ET\nendstream\n")...)
+
+		text, err := extractPDF(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != "Visible" {
+			t.Errorf("got %q", text)
+		}
+	})
+
+	t.Run("rejects encrypted and non-PDF files", func(t *testing.T) {
+		if _, err := extractPDF([]byte("hello")); err == nil {
+			t.Error("expected an error for a non-PDF file")
+		}
+		encrypted := append(buildPDF(t, false, "BT (x) Tj ET"), []byte("trailer << /Encrypt 5 0 R >>")...)
+		if _, err := extractPDF(encrypted); err == nil || !strings.Contains(err.Error(), "encrypted") {
+			t.Errorf("expected an encryption error, got %v", err)
+		}
+	})
+}
+
+func TestPDFStreamDict(t *testing.T) {
+	before := []byte("1 0 obj\n<< /Length 5 /DecodeParms << /Predictor 12 >> /Filter /FlateDecode >>\n")
+	if got := pdfStreamDict(before); !strings.HasPrefix(got, "<< /Length 5") || !strings.HasSuffix(got, "FlateDecode >>") {
+		t.Errorf("expected the whole nested dictionary, got %q", got)
+	}
+	if got := pdfStreamDict([]byte("no dictionary here")); got != "" {
+		t.Errorf("expected no dictionary, got %q", got)
+	}
+}
+
+func TestDecodePDFString(t *testing.T) {
+	tests := map[string]string{
+		"plain":               "plain",
+		"\xfe\xff\x00A\x00B":  "AB",
+		"\x00H\x00i":          "Hi",
+		"caf\xe9":             "café",
+		"\x01\x02visible\x03": "visible",
+	}
+	for in, want := range tests {
+		if got := decodePDFString(in); got != want {
+			t.Errorf("decodePDFString(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
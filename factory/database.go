@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/chat-cli/chat-cli/db"
+	"github.com/chat-cli/chat-cli/db/postgres"
 	"github.com/chat-cli/chat-cli/db/sqlite"
 )
 
@@ -14,9 +15,9 @@ func CreateDatabase(config *db.Config) (db.Database, error) {
 		database := sqlite.NewSQLiteDB(config)
 		err := database.Connect()
 		return database, err
-	// case "postgres":
-	// 	database := postgres.NewPostgresDB(config)
-	// 	return database, database.Connect()
+	case "postgres":
+		database := postgres.NewPostgresDB(config)
+		return database, database.Connect()
 	default:
 		return nil, fmt.Errorf("unsupported database driver: %s", config.Driver)
 	}
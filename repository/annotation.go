@@ -0,0 +1,83 @@
+// repository/annotation.go
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// Annotation is a note someone attached to a saved chat, or to one message
+// in it, such as feedback on an answer.
+type Annotation struct { //nolint:govet // fieldalignment is a minor optimization
+	ID     int
+	ChatId string
+	// MessageID is the id of the annotated message in chats, or 0 for a
+	// note on the whole chat.
+	MessageID int
+	Note      string
+	Created   string
+}
+
+// AnnotationRepository persists notes on saved chats
+type AnnotationRepository struct {
+	BaseRepository
+}
+
+func NewAnnotationRepository(db db.Database) *AnnotationRepository {
+	return &AnnotationRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+func (r *AnnotationRepository) Create(ctx context.Context, annotation *Annotation) error {
+	query := `
+        INSERT INTO annotations (chat_id, message_id, note)
+        VALUES ($1, NULLIF($2, 0), $3)
+        RETURNING id`
+
+	err := r.db.GetDB().QueryRowContext(ctx, query, annotation.ChatId, annotation.MessageID, annotation.Note).Scan(&annotation.ID)
+	if err != nil {
+		return fmt.Errorf("error saving annotation: %v", err)
+	}
+	return nil
+}
+
+// List returns the notes on the given chat in the order they were added.
+func (r *AnnotationRepository) List(ctx context.Context, chatId string) ([]Annotation, error) {
+	query := `
+        SELECT id, chat_id, message_id, note, created_at
+        FROM annotations
+        WHERE chat_id = $1
+        ORDER BY id ASC`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving annotations: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var annotations []Annotation
+	for rows.Next() {
+		var annotation Annotation
+		var messageID sql.NullInt64
+		if err := rows.Scan(&annotation.ID, &annotation.ChatId, &messageID, &annotation.Note, &annotation.Created); err != nil {
+			return nil, fmt.Errorf("error scanning annotation: %v", err)
+		}
+		annotation.MessageID = int(messageID.Int64)
+		annotations = append(annotations, annotation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over annotations: %v", err)
+	}
+
+	return annotations, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestAnnotationRepository(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewAnnotationRepository(mockDB)
+
+	notes := []Annotation{
+		{ChatId: "chat-1", MessageID: 5, Note: "this answer was wrong"},
+		{ChatId: "chat-1", Note: "good session overall"},
+		{ChatId: "chat-2", MessageID: 9, Note: "elsewhere"},
+	}
+	for i := range notes {
+		if err := repo.Create(t.Context(), &notes[i]); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if notes[i].ID == 0 {
+			t.Errorf("Expected an id for annotation %d", i)
+		}
+	}
+
+	got, err := repo.List(t.Context(), "chat-1")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 annotations, got %d", len(got))
+	}
+	if got[0].MessageID != 5 || got[0].Note != "this answer was wrong" || got[0].Created == "" {
+		t.Errorf("Unexpected message annotation %+v", got[0])
+	}
+	if got[1].MessageID != 0 || got[1].Note != "good session overall" {
+		t.Errorf("Expected a note on the whole chat, got %+v", got[1])
+	}
+
+	var nullMessages int
+	if err := mockDB.db.QueryRow(`SELECT COUNT(*) FROM annotations WHERE message_id IS NULL`).Scan(&nullMessages); err != nil {
+		t.Fatal(err)
+	}
+	if nullMessages != 1 {
+		t.Errorf("Expected the chat note to be stored without a message, got %d", nullMessages)
+	}
+}
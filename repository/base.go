@@ -1,15 +1,19 @@
 // repository/base.go
 package repository
 
-import "github.com/chat-cli/chat-cli/db"
+import (
+	"context"
+
+	"github.com/chat-cli/chat-cli/db"
+)
 
 // Repository defines the standard operations to be implemented by all repositories
 type Repository[T any] interface {
-	Create(entity *T) error
-	GetByID(id int) (*T, error)
-	Update(entity *T) error
-	Delete(id int) error
-	List() ([]T, error)
+	Create(ctx context.Context, entity *T) error
+	GetByID(ctx context.Context, id int) (*T, error)
+	Update(ctx context.Context, entity *T) error
+	Delete(ctx context.Context, id int) error
+	List(ctx context.Context) ([]T, error)
 }
 
 // BaseRepository provides common functionality for all repositories
@@ -2,17 +2,48 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/chat-cli/chat-cli/db"
 )
 
+// ErrChatNotFound is returned by Delete when no messages have the given
+// chat id.
+var ErrChatNotFound = errors.New("chat not found")
+
+// timestampLayout matches how created_at is stored by CURRENT_TIMESTAMP, so
+// cutoffs compare correctly as text.
+const timestampLayout = "2006-01-02 15:04:05"
+
 type Chat struct { //nolint:govet // fieldalignment is a minor optimization
 	ID      int
 	ChatId  string
 	Persona string
 	Message string
 	Created string
+	// Title is the chat's name, set by rename or generated after the first
+	// exchange; empty if it has none yet. Only List fills it in.
+	Title string
+	// ImagePath and ImageHash reference an image attached in the chat: its
+	// absolute path and the hex SHA-256 of its contents when attached. The
+	// image itself isn't stored. Both are empty for text messages.
+	ImagePath string
+	ImageHash string
+	// StopReason is why the model ended an assistant message, as Bedrock
+	// reported it (end_turn, max_tokens, ...); empty for other messages
+	// and for replies saved before it was recorded.
+	StopReason string
+	// Resumes is how many times an assistant message's response stream
+	// broke off and was continued with a follow-up request; 0 for most.
+	Resumes int
+	// Number is the message's place in its chat, counting both sides from
+	// 1 and leaving out superseded replies. Only GetMessageRange and
+	// GetMessagesBetween fill it in.
+	Number int
 }
 
 // ChatRepository implements Repository interface for Chat
@@ -26,29 +57,36 @@ func NewChatRepository(db db.Database) *ChatRepository {
 	}
 }
 
-func (r *ChatRepository) Create(chat *Chat) error {
+func (r *ChatRepository) Create(ctx context.Context, chat *Chat) error {
 	query := `
-        INSERT INTO chats (chat_id, persona, message)
-        VALUES ($1, $2, $3)
+        INSERT INTO chats (chat_id, persona, message, image_path, image_hash, stop_reason, resumes)
+        VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0))
         RETURNING id`
 
-	err := r.db.GetDB().QueryRow(query, chat.ChatId, chat.Persona, chat.Message).Scan(&chat.ID)
+	err := r.db.GetDB().QueryRowContext(ctx, query, chat.ChatId, chat.Persona, chat.Message, chat.ImagePath, chat.ImageHash, chat.StopReason, chat.Resumes).Scan(&chat.ID)
 	if err != nil {
 		return fmt.Errorf("error creating user: %v", err)
 	}
 	return nil
 }
 
-// Function to list 10 most recent chats
-func (r *ChatRepository) List() ([]Chat, error) {
+// Function to list the 10 most recently active chats, each represented by
+// its opening message
+func (r *ChatRepository) List(ctx context.Context) ([]Chat, error) {
+	// written without bare GROUP BY columns so it runs on PostgreSQL as well
+	// as SQLite
 	query := `
-        SELECT id, chat_id, persona, message, created_at
-        FROM chats
-		GROUP BY chat_id
-        ORDER BY id DESC
+        SELECT c.id, c.chat_id, c.persona, c.message, c.created_at, COALESCE(c.title, '')
+        FROM chats c
+        JOIN (
+            SELECT chat_id, MIN(id) AS first_id, MAX(id) AS last_id
+            FROM chats
+            GROUP BY chat_id
+        ) g ON c.id = g.first_id
+        ORDER BY g.last_id DESC
         LIMIT 10`
 
-	rows, err := r.db.GetDB().Query(query)
+	rows, err := r.db.GetDB().QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error listing chats: %v", err)
 	}
@@ -62,7 +100,7 @@ func (r *ChatRepository) List() ([]Chat, error) {
 	var chats []Chat
 	for rows.Next() {
 		var chat Chat
-		err := rows.Scan(&chat.ID, &chat.ChatId, &chat.Persona, &chat.Message, &chat.Created)
+		err := rows.Scan(&chat.ID, &chat.ChatId, &chat.Persona, &chat.Message, &chat.Created, &chat.Title)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning chat: %v", err)
 		}
@@ -76,15 +114,94 @@ func (r *ChatRepository) List() ([]Chat, error) {
 	return chats, nil
 }
 
-// function to retrieve all messages for a given chat_id
-func (r *ChatRepository) GetMessages(chatId string) ([]Chat, error) {
+// function to retrieve all messages for a given chat_id, leaving out
+// replies replaced with /retry
+func (r *ChatRepository) GetMessages(ctx context.Context, chatId string) ([]Chat, error) {
 	query := `
-        SELECT id, chat_id, persona, message
+        SELECT id, chat_id, persona, message, created_at, COALESCE(image_path, ''), COALESCE(image_hash, ''), COALESCE(stop_reason, ''), COALESCE(resumes, 0)
         FROM chats
-        WHERE chat_id = $1
+        WHERE chat_id = $1 AND superseded_at IS NULL
         ORDER BY id ASC`
 
-	rows, err := r.db.GetDB().Query(query, chatId)
+	rows, err := r.db.GetDB().QueryContext(ctx, query, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving messages: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var chats []Chat
+	for rows.Next() {
+		var chat Chat
+		err := rows.Scan(&chat.ID, &chat.ChatId, &chat.Persona, &chat.Message, &chat.Created, &chat.ImagePath, &chat.ImageHash, &chat.StopReason, &chat.Resumes)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning chat: %v", err)
+		}
+		chats = append(chats, chat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over chats: %v", err)
+	}
+
+	return chats, nil
+}
+
+// GetMessageRange returns messages from to to of the given chat, numbered
+// as GetMessages would return them, counting from 1. A to of 0 means up to
+// the last message.
+func (r *ChatRepository) GetMessageRange(ctx context.Context, chatId string, from, to int) ([]Chat, error) {
+	conditions := []string{"n >= $2"}
+	args := []interface{}{chatId, from}
+	if to > 0 {
+		conditions = append(conditions, "n <= $3")
+		args = append(args, to)
+	}
+	return r.numberedMessages(ctx, conditions, args)
+}
+
+// GetMessagesBetween returns the messages of the given chat sent from since
+// to until, inclusive, numbered as GetMessageRange numbers them. A zero
+// time leaves that end open.
+func (r *ChatRepository) GetMessagesBetween(ctx context.Context, chatId string, since, until time.Time) ([]Chat, error) {
+	var conditions []string
+	args := []interface{}{chatId}
+	if !since.IsZero() {
+		args = append(args, since.UTC().Format(timestampLayout))
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !until.IsZero() {
+		args = append(args, until.UTC().Format(timestampLayout))
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+	return r.numberedMessages(ctx, conditions, args)
+}
+
+// numberedMessages numbers the messages of the chat in args[0] before
+// filtering them by conditions, so the numbers match the whole chat's.
+func (r *ChatRepository) numberedMessages(ctx context.Context, conditions []string, args []interface{}) ([]Chat, error) {
+	query := `
+        SELECT id, chat_id, persona, message, created_at, image_path, image_hash, stop_reason, resumes, n
+        FROM (
+            SELECT id, chat_id, persona, message, created_at, COALESCE(image_path, '') AS image_path,
+                COALESCE(image_hash, '') AS image_hash, COALESCE(stop_reason, '') AS stop_reason,
+                COALESCE(resumes, 0) AS resumes,
+                ROW_NUMBER() OVER (ORDER BY id) AS n
+            FROM chats
+            WHERE chat_id = $1 AND superseded_at IS NULL
+        ) AS numbered`
+	if len(conditions) > 0 {
+		query += `
+        WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += `
+        ORDER BY n ASC`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving messages: %v", err)
 	}
@@ -98,7 +215,7 @@ func (r *ChatRepository) GetMessages(chatId string) ([]Chat, error) {
 	var chats []Chat
 	for rows.Next() {
 		var chat Chat
-		err := rows.Scan(&chat.ID, &chat.ChatId, &chat.Persona, &chat.Message)
+		err := rows.Scan(&chat.ID, &chat.ChatId, &chat.Persona, &chat.Message, &chat.Created, &chat.ImagePath, &chat.ImageHash, &chat.StopReason, &chat.Resumes, &chat.Number)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning chat: %v", err)
 		}
@@ -111,3 +228,257 @@ func (r *ChatRepository) GetMessages(chatId string) ([]Chat, error) {
 
 	return chats, nil
 }
+
+// Supersede marks the message with the given id as replaced by a newer
+// reply. It stays in the database but GetMessages no longer returns it.
+func (r *ChatRepository) Supersede(ctx context.Context, id int) error {
+	result, err := r.db.GetDB().ExecContext(ctx, `UPDATE chats SET superseded_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error superseding message: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error superseding message: %v", err)
+	}
+	if updated == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+// SetTitle names the given chat. The title is stored on every message so
+// far, which includes the opening message List reads it from.
+func (r *ChatRepository) SetTitle(ctx context.Context, chatId, title string) error {
+	result, err := r.db.GetDB().ExecContext(ctx, `UPDATE chats SET title = $1 WHERE chat_id = $2`, title, chatId)
+	if err != nil {
+		return fmt.Errorf("error renaming chat: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error renaming chat: %v", err)
+	}
+	if updated == 0 {
+		return ErrChatNotFound
+	}
+
+	return nil
+}
+
+// GetTitle returns the title of the given chat, or "" if it has none.
+func (r *ChatRepository) GetTitle(ctx context.Context, chatId string) (string, error) {
+	var title string
+	err := r.db.GetDB().QueryRowContext(ctx, `SELECT COALESCE(MAX(title), '') FROM chats WHERE chat_id = $1`, chatId).Scan(&title)
+	if err != nil {
+		return "", fmt.Errorf("error retrieving chat title: %v", err)
+	}
+	return title, nil
+}
+
+// Delete removes every message in the given chat, and the notes and
+// settings kept with it, and returns how many messages were removed.
+func (r *ChatRepository) Delete(ctx context.Context, chatId string) (int64, error) {
+	result, err := r.db.GetDB().ExecContext(ctx, `DELETE FROM chats WHERE chat_id = $1`, chatId)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting chat: %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting chat: %v", err)
+	}
+	if deleted == 0 {
+		return 0, ErrChatNotFound
+	}
+
+	if _, err := r.db.GetDB().ExecContext(ctx, `DELETE FROM annotations WHERE chat_id = $1`, chatId); err != nil {
+		return deleted, fmt.Errorf("error deleting chat annotations: %v", err)
+	}
+	if _, err := r.db.GetDB().ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = $1`, chatId); err != nil {
+		return deleted, fmt.Errorf("error deleting chat settings: %v", err)
+	}
+
+	return deleted, nil
+}
+
+// staleChatsQuery selects the chats whose most recent message is older than
+// the cutoff, so a conversation that is still in use is never cut in half.
+const staleChatsQuery = `
+        SELECT chat_id
+        FROM chats
+        GROUP BY chat_id
+        HAVING MAX(created_at) < $1`
+
+// CountOlderThan returns how many chats DeleteOlderThan would remove for
+// the same cutoff.
+func (r *ChatRepository) CountOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM (` + staleChatsQuery + `) AS stale`
+
+	var count int
+	if err := r.db.GetDB().QueryRowContext(ctx, query, cutoff.UTC().Format(timestampLayout)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting chats: %v", err)
+	}
+	return count, nil
+}
+
+// ListOlderThan returns the ids of the chats DeleteOlderThan would remove
+// for the same cutoff, sorted.
+func (r *ChatRepository) ListOlderThan(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := r.db.GetDB().QueryContext(ctx, staleChatsQuery+` ORDER BY chat_id`, cutoff.UTC().Format(timestampLayout))
+	if err != nil {
+		return nil, fmt.Errorf("error listing chats: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var chatIds []string
+	for rows.Next() {
+		var chatId string
+		if err := rows.Scan(&chatId); err != nil {
+			return nil, fmt.Errorf("error scanning chat: %v", err)
+		}
+		chatIds = append(chatIds, chatId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over chats: %v", err)
+	}
+
+	return chatIds, nil
+}
+
+// DeleteOlderThan removes every chat whose last message was created before
+// cutoff and returns how many chats were removed.
+func (r *ChatRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := r.db.GetDB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning chats: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.QueryContext(ctx, staleChatsQuery, cutoff.UTC().Format(timestampLayout))
+	if err != nil {
+		return 0, fmt.Errorf("error pruning chats: %v", err)
+	}
+
+	var chatIds []string
+	for rows.Next() {
+		var chatId string
+		if err := rows.Scan(&chatId); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("error scanning chat: %v", err)
+		}
+		chatIds = append(chatIds, chatId)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, fmt.Errorf("error iterating over chats: %v", err)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("error pruning chats: %v", err)
+	}
+
+	for _, chatId := range chatIds {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM chats WHERE chat_id = $1`, chatId); err != nil {
+			return 0, fmt.Errorf("error deleting chat: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM annotations WHERE chat_id = $1`, chatId); err != nil {
+			return 0, fmt.Errorf("error deleting chat annotations: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = $1`, chatId); err != nil {
+			return 0, fmt.Errorf("error deleting chat settings: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error pruning chats: %v", err)
+	}
+
+	return len(chatIds), nil
+}
+
+// ImportedChat is a whole chat to save with Replace: its messages, each
+// with the notes on it, and its title, settings and notes on the chat as a
+// whole.
+type ImportedChat struct {
+	ChatId   string
+	Title    string
+	Messages []ImportedMessage
+	Notes    []string
+	Settings map[string]string
+}
+
+// ImportedMessage is a message of an ImportedChat and the notes on it.
+type ImportedMessage struct {
+	Chat
+	Notes []string
+}
+
+// Replace saves chat under its id in place of any chat already saved
+// there, with its notes and settings. It all happens in one transaction,
+// so if any of it fails the chat already saved is left as it was.
+func (r *ChatRepository) Replace(ctx context.Context, chat *ImportedChat) error {
+	tx, err := r.db.GetDB().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error importing chat: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chats WHERE chat_id = $1`, chat.ChatId); err != nil {
+		return fmt.Errorf("error deleting chat: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM annotations WHERE chat_id = $1`, chat.ChatId); err != nil {
+		return fmt.Errorf("error deleting chat annotations: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = $1`, chat.ChatId); err != nil {
+		return fmt.Errorf("error deleting chat settings: %v", err)
+	}
+
+	messageQuery := `
+        INSERT INTO chats (chat_id, persona, message, image_path, image_hash, stop_reason, resumes, title)
+        VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, ''))
+        RETURNING id`
+	noteQuery := `
+        INSERT INTO annotations (chat_id, message_id, note)
+        VALUES ($1, NULLIF($2, 0), $3)`
+	for i := range chat.Messages {
+		m := &chat.Messages[i]
+		m.ChatId = chat.ChatId
+		err := tx.QueryRowContext(ctx, messageQuery, chat.ChatId, m.Persona, m.Message, m.ImagePath, m.ImageHash, m.StopReason, m.Resumes, chat.Title).Scan(&m.ID)
+		if err != nil {
+			return fmt.Errorf("error saving message: %v", err)
+		}
+		for _, note := range m.Notes {
+			if _, err := tx.ExecContext(ctx, noteQuery, chat.ChatId, m.ID, note); err != nil {
+				return fmt.Errorf("error saving annotation: %v", err)
+			}
+		}
+	}
+	for _, note := range chat.Notes {
+		if _, err := tx.ExecContext(ctx, noteQuery, chat.ChatId, 0, note); err != nil {
+			return fmt.Errorf("error saving annotation: %v", err)
+		}
+	}
+
+	settingQuery := `
+        INSERT INTO chat_settings (chat_id, key, value)
+        VALUES ($1, $2, $3)`
+	for key, value := range chat.Settings {
+		if _, err := tx.ExecContext(ctx, settingQuery, chat.ChatId, key, value); err != nil {
+			return fmt.Errorf("error saving chat setting: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error importing chat: %v", err)
+	}
+	return nil
+}
@@ -2,9 +2,13 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/chat-cli/chat-cli/db/sqlite"
 	_ "modernc.org/sqlite"
 )
 
@@ -32,25 +36,18 @@ func (m *MockDatabase) Migrate() error {
 	return nil // Migration handled in setupTestDB
 }
 
+// setupTestDB opens an in-memory database with the same schema the sqlite
+// driver migrates real databases to.
 func setupTestDB(t *testing.T) *MockDatabase {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
+	// every connection to :memory: gets its own empty database
+	db.SetMaxOpenConns(1)
 
-	// Create the chats table
-	createTableSQL := `
-		CREATE TABLE IF NOT EXISTS chats (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			chat_id TEXT NOT NULL,
-			persona TEXT NOT NULL,
-			message TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-	`
-
-	if _, err := db.Exec(createTableSQL); err != nil {
-		t.Fatalf("Failed to create test table: %v", err)
+	if err := sqlite.NewSQLiteMigration(db).MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
 	return &MockDatabase{db: db}
@@ -91,7 +88,7 @@ func TestChatRepository_Create(t *testing.T) {
 		Message: "Hello, world!",
 	}
 
-	err := repo.Create(chat)
+	err := repo.Create(t.Context(), chat)
 	if err != nil {
 		t.Errorf("Create failed: %v", err)
 	}
@@ -129,14 +126,14 @@ func TestChatRepository_List(t *testing.T) {
 	}
 
 	for i := range testChats {
-		err := repo.Create(&testChats[i])
+		err := repo.Create(t.Context(), &testChats[i])
 		if err != nil {
 			t.Fatalf("Failed to create test chat %d: %v", i, err)
 		}
 	}
 
 	// Test List function
-	chats, err := repo.List()
+	chats, err := repo.List(t.Context())
 	if err != nil {
 		t.Errorf("List failed: %v", err)
 	}
@@ -151,6 +148,149 @@ func TestChatRepository_List(t *testing.T) {
 	}
 }
 
+func TestChatRepository_ListMultiMessageChats(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+
+	// chat-1 is started first but has the most recent message
+	testChats := []Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "Opening question"},
+		{ChatId: "chat-2", Persona: "User", Message: "Other chat"},
+		{ChatId: "chat-1", Persona: "Assistant", Message: "Answer"},
+	}
+	for i := range testChats {
+		if err := repo.Create(t.Context(), &testChats[i]); err != nil {
+			t.Fatalf("Failed to create test chat %d: %v", i, err)
+		}
+	}
+
+	chats, err := repo.List(t.Context())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(chats) != 2 {
+		t.Fatalf("Expected 2 chats, got %d", len(chats))
+	}
+	if chats[0].ChatId != "chat-1" || chats[0].Message != "Opening question" {
+		t.Errorf("Expected the most recently active chat first, titled by its opening message, got %+v", chats[0])
+	}
+}
+
+func TestChatRepository_ImageReferences(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+	for _, chat := range []Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "[image: /tmp/diagram.png]", ImagePath: "/tmp/diagram.png", ImageHash: "abc123"},
+		{ChatId: "chat-1", Persona: "User", Message: "What does this show?"},
+	} {
+		if err := repo.Create(t.Context(), &chat); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	messages, err := repo.GetMessages(t.Context(), "chat-1")
+	if err != nil {
+		t.Fatalf("GetMessages failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].ImagePath != "/tmp/diagram.png" || messages[0].ImageHash != "abc123" {
+		t.Errorf("Expected the image reference to round-trip, got %q %q", messages[0].ImagePath, messages[0].ImageHash)
+	}
+	if messages[1].ImagePath != "" || messages[1].ImageHash != "" {
+		t.Errorf("Expected no image reference on a text message, got %q %q", messages[1].ImagePath, messages[1].ImageHash)
+	}
+
+	var nulls int
+	if err := mockDB.GetDB().QueryRow(`SELECT COUNT(*) FROM chats WHERE image_path IS NULL AND image_hash IS NULL`).Scan(&nulls); err != nil {
+		t.Fatal(err)
+	}
+	if nulls != 1 {
+		t.Errorf("Expected text messages to store NULL image columns, got %d such rows", nulls)
+	}
+}
+
+func TestChatRepository_StopReasonRoundTrip(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+	for _, chat := range []Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "Write a long essay"},
+		{ChatId: "chat-1", Persona: "Assistant", Message: "Once upon", StopReason: "max_tokens"},
+	} {
+		if err := repo.Create(t.Context(), &chat); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	messages, err := repo.GetMessages(t.Context(), "chat-1")
+	if err != nil {
+		t.Fatalf("GetMessages failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].StopReason != "" || messages[1].StopReason != "max_tokens" {
+		t.Errorf("Expected the stop reason on the reply only, got %q and %q", messages[0].StopReason, messages[1].StopReason)
+	}
+}
+
+func TestChatRepository_Supersede(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+	question := Chat{ChatId: "chat-1", Persona: "User", Message: "Name a color"}
+	first := Chat{ChatId: "chat-1", Persona: "Assistant", Message: "Red"}
+	second := Chat{ChatId: "chat-1", Persona: "Assistant", Message: "Blue"}
+	for _, chat := range []*Chat{&question, &first} {
+		if err := repo.Create(t.Context(), chat); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	if err := repo.Supersede(t.Context(), first.ID); err != nil {
+		t.Fatalf("Supersede failed: %v", err)
+	}
+	if err := repo.Create(t.Context(), &second); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	messages, err := repo.GetMessages(t.Context(), "chat-1")
+	if err != nil {
+		t.Fatalf("GetMessages failed: %v", err)
+	}
+	if len(messages) != 2 || messages[1].Message != "Blue" {
+		t.Errorf("Expected the superseded reply to be left out, got %+v", messages)
+	}
+
+	if err := repo.Supersede(t.Context(), 999); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Expected ErrChatNotFound for a missing message, got %v", err)
+	}
+}
+
 func TestChatRepository_GetMessages(t *testing.T) {
 	mockDB := setupTestDB(t)
 	defer func() {
@@ -170,14 +310,14 @@ func TestChatRepository_GetMessages(t *testing.T) {
 	}
 
 	for i := range testChats {
-		err := repo.Create(&testChats[i])
+		err := repo.Create(t.Context(), &testChats[i])
 		if err != nil {
 			t.Fatalf("Failed to create test chat %d: %v", i, err)
 		}
 	}
 
 	// Test GetMessages for chat-1
-	messages, err := repo.GetMessages("chat-1")
+	messages, err := repo.GetMessages(t.Context(), "chat-1")
 	if err != nil {
 		t.Errorf("GetMessages failed: %v", err)
 	}
@@ -199,7 +339,7 @@ func TestChatRepository_GetMessages(t *testing.T) {
 	}
 
 	// Test GetMessages for non-existent chat
-	emptyMessages, err := repo.GetMessages("non-existent-chat")
+	emptyMessages, err := repo.GetMessages(t.Context(), "non-existent-chat")
 	if err != nil {
 		t.Errorf("GetMessages failed for non-existent chat: %v", err)
 	}
@@ -209,6 +349,79 @@ func TestChatRepository_GetMessages(t *testing.T) {
 	}
 }
 
+func TestChatRepository_GetMessageRange(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+
+	now := time.Now().UTC()
+	rows := []struct{ chatId, message, created string }{
+		{"chat-1", "one", now.Add(-3 * time.Hour).Format(timestampLayout)},
+		{"chat-1", "replaced", now.Add(-2 * time.Hour).Format(timestampLayout)},
+		{"chat-2", "elsewhere", now.Add(-2 * time.Hour).Format(timestampLayout)},
+		{"chat-1", "two", now.Add(-2 * time.Hour).Format(timestampLayout)},
+		{"chat-1", "three", now.Add(-time.Hour).Format(timestampLayout)},
+		{"chat-1", "four", now.Format(timestampLayout)},
+	}
+	for _, row := range rows {
+		_, err := mockDB.GetDB().Exec(
+			`INSERT INTO chats (chat_id, persona, message, created_at) VALUES ($1, 'User', $2, $3)`,
+			row.chatId, row.message, row.created,
+		)
+		if err != nil {
+			t.Fatalf("Failed to insert test chat: %v", err)
+		}
+	}
+	if err := repo.Supersede(t.Context(), 2); err != nil {
+		t.Fatalf("Supersede failed: %v", err)
+	}
+
+	describe := func(chats []Chat) string {
+		var parts []string
+		for _, chat := range chats {
+			parts = append(parts, fmt.Sprintf("%d:%s", chat.Number, chat.Message))
+		}
+		return strings.Join(parts, " ")
+	}
+
+	messages, err := repo.GetMessageRange(t.Context(), "chat-1", 2, 3)
+	if err != nil {
+		t.Fatalf("GetMessageRange failed: %v", err)
+	}
+	if got := describe(messages); got != "2:two 3:three" {
+		t.Errorf("Expected messages 2 to 3, got %q", got)
+	}
+
+	messages, err = repo.GetMessageRange(t.Context(), "chat-1", 3, 0)
+	if err != nil {
+		t.Fatalf("GetMessageRange failed: %v", err)
+	}
+	if got := describe(messages); got != "3:three 4:four" {
+		t.Errorf("Expected messages from 3 on, got %q", got)
+	}
+
+	messages, err = repo.GetMessagesBetween(t.Context(), "chat-1", now.Add(-150*time.Minute), now.Add(-30*time.Minute))
+	if err != nil {
+		t.Fatalf("GetMessagesBetween failed: %v", err)
+	}
+	if got := describe(messages); got != "2:two 3:three" {
+		t.Errorf("Expected the messages in the last few hours but one, got %q", got)
+	}
+
+	messages, err = repo.GetMessagesBetween(t.Context(), "chat-1", time.Time{}, now.Add(-150*time.Minute))
+	if err != nil {
+		t.Fatalf("GetMessagesBetween failed: %v", err)
+	}
+	if got := describe(messages); got != "1:one" {
+		t.Errorf("Expected an open start, got %q", got)
+	}
+}
+
 func TestChatRepository_ListLimit(t *testing.T) {
 	mockDB := setupTestDB(t)
 	defer func() {
@@ -226,14 +439,14 @@ func TestChatRepository_ListLimit(t *testing.T) {
 			Persona: "user",
 			Message: fmt.Sprintf("Message %d", i),
 		}
-		err := repo.Create(chat)
+		err := repo.Create(t.Context(), chat)
 		if err != nil {
 			t.Fatalf("Failed to create test chat %d: %v", i, err)
 		}
 	}
 
 	// Test that List returns only 10 chats
-	chats, err := repo.List()
+	chats, err := repo.List(t.Context())
 	if err != nil {
 		t.Errorf("List failed: %v", err)
 	}
@@ -243,6 +456,205 @@ func TestChatRepository_ListLimit(t *testing.T) {
 	}
 }
 
+func TestChatRepository_Delete(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+
+	testChats := []Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "Hello"},
+		{ChatId: "chat-1", Persona: "Assistant", Message: "Hi there"},
+		{ChatId: "chat-2", Persona: "User", Message: "Another chat"},
+	}
+	for i := range testChats {
+		if err := repo.Create(t.Context(), &testChats[i]); err != nil {
+			t.Fatalf("Failed to create test chat %d: %v", i, err)
+		}
+	}
+
+	deleted, err := repo.Delete(t.Context(), "chat-1")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 messages deleted, got %d", deleted)
+	}
+
+	if messages, _ := repo.GetMessages(t.Context(), "chat-1"); len(messages) != 0 {
+		t.Errorf("Expected chat-1 to be gone, got %d messages", len(messages))
+	}
+	if messages, _ := repo.GetMessages(t.Context(), "chat-2"); len(messages) != 1 {
+		t.Errorf("Expected chat-2 to be untouched, got %d messages", len(messages))
+	}
+
+	if _, err := repo.Delete(t.Context(), "chat-1"); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Expected ErrChatNotFound for a missing chat, got %v", err)
+	}
+}
+
+func TestChatRepository_DeleteRemovesAnnotations(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+	annotations := NewAnnotationRepository(mockDB)
+
+	for _, chatId := range []string{"chat-1", "chat-2"} {
+		chat := Chat{ChatId: chatId, Persona: "User", Message: "Hello"}
+		if err := repo.Create(t.Context(), &chat); err != nil {
+			t.Fatal(err)
+		}
+		if err := annotations.Create(t.Context(), &Annotation{ChatId: chatId, MessageID: chat.ID, Note: "noted"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.Delete(t.Context(), "chat-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if notes, _ := annotations.List(t.Context(), "chat-1"); len(notes) != 0 {
+		t.Errorf("Expected chat-1's annotations to be gone, got %d", len(notes))
+	}
+	if notes, _ := annotations.List(t.Context(), "chat-2"); len(notes) != 1 {
+		t.Errorf("Expected chat-2's annotation to be kept, got %d", len(notes))
+	}
+}
+
+func TestChatRepository_SetTitle(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+
+	testChats := []Chat{
+		{ChatId: "chat-1", Persona: "User", Message: "How do I rotate logs?"},
+		{ChatId: "chat-1", Persona: "Assistant", Message: "Use logrotate."},
+		{ChatId: "chat-2", Persona: "User", Message: "Another chat"},
+	}
+	for i := range testChats {
+		if err := repo.Create(t.Context(), &testChats[i]); err != nil {
+			t.Fatalf("Failed to create test chat %d: %v", i, err)
+		}
+	}
+
+	if title, err := repo.GetTitle(t.Context(), "chat-1"); err != nil || title != "" {
+		t.Errorf("Expected no title yet, got %q, %v", title, err)
+	}
+
+	if err := repo.SetTitle(t.Context(), "chat-1", "Log rotation"); err != nil {
+		t.Fatalf("SetTitle failed: %v", err)
+	}
+
+	// messages added after naming keep the chat's title
+	if err := repo.Create(t.Context(), &Chat{ChatId: "chat-1", Persona: "User", Message: "Thanks"}); err != nil {
+		t.Fatal(err)
+	}
+	if title, err := repo.GetTitle(t.Context(), "chat-1"); err != nil || title != "Log rotation" {
+		t.Errorf("Expected title %q, got %q, %v", "Log rotation", title, err)
+	}
+
+	chats, err := repo.List(t.Context())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	titles := map[string]string{}
+	for _, chat := range chats {
+		titles[chat.ChatId] = chat.Title
+	}
+	if titles["chat-1"] != "Log rotation" || titles["chat-2"] != "" {
+		t.Errorf("Unexpected titles from List: %v", titles)
+	}
+
+	if err := repo.SetTitle(t.Context(), "missing", "Nope"); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Expected ErrChatNotFound for a missing chat, got %v", err)
+	}
+}
+
+func TestChatRepository_DeleteOlderThan(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatRepository(mockDB)
+
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -60).Format(timestampLayout)
+	recent := now.AddDate(0, 0, -1).Format(timestampLayout)
+
+	// stale-chat is old throughout; active-chat started long ago but was
+	// continued yesterday, so it must survive
+	rows := []struct{ chatId, created string }{
+		{"stale-chat", old},
+		{"stale-chat", old},
+		{"active-chat", old},
+		{"active-chat", recent},
+		{"new-chat", recent},
+	}
+	for _, row := range rows {
+		_, err := mockDB.GetDB().Exec(
+			`INSERT INTO chats (chat_id, persona, message, created_at) VALUES ($1, 'User', 'message', $2)`,
+			row.chatId, row.created,
+		)
+		if err != nil {
+			t.Fatalf("Failed to insert test chat: %v", err)
+		}
+	}
+
+	cutoff := now.AddDate(0, 0, -30)
+
+	count, err := repo.CountOlderThan(t.Context(), cutoff)
+	if err != nil {
+		t.Fatalf("CountOlderThan failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 stale chat, got %d", count)
+	}
+
+	stale, err := repo.ListOlderThan(t.Context(), cutoff)
+	if err != nil {
+		t.Fatalf("ListOlderThan failed: %v", err)
+	}
+	if len(stale) != 1 || stale[0] != "stale-chat" {
+		t.Errorf("Expected [stale-chat], got %v", stale)
+	}
+
+	deleted, err := repo.DeleteOlderThan(t.Context(), cutoff)
+	if err != nil {
+		t.Fatalf("DeleteOlderThan failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 chat deleted, got %d", deleted)
+	}
+
+	if messages, _ := repo.GetMessages(t.Context(), "stale-chat"); len(messages) != 0 {
+		t.Errorf("Expected stale-chat to be pruned, got %d messages", len(messages))
+	}
+	if messages, _ := repo.GetMessages(t.Context(), "active-chat"); len(messages) != 2 {
+		t.Errorf("Expected active-chat to keep all its messages, got %d", len(messages))
+	}
+
+	deleted, err = repo.DeleteOlderThan(t.Context(), cutoff)
+	if err != nil || deleted != 0 {
+		t.Errorf("Expected a second prune to delete nothing, got %d, %v", deleted, err)
+	}
+}
+
 func TestChatStruct(t *testing.T) {
 	chat := Chat{
 		ID:      1,
@@ -0,0 +1,65 @@
+// repository/chatname.go
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// ErrChatNameTaken is returned by Create when the name is already used in
+// the workspace.
+var ErrChatNameTaken = errors.New("chat name already in use")
+
+// ChatNameRepository maps human-friendly chat names to chat ids. A name is
+// unique within its workspace, so the same name can be reused in different
+// projects.
+type ChatNameRepository struct {
+	BaseRepository
+}
+
+func NewChatNameRepository(db db.Database) *ChatNameRepository {
+	return &ChatNameRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+// ChatId returns the id of the chat called name in workspace, or "" if
+// there isn't one.
+func (r *ChatNameRepository) ChatId(ctx context.Context, workspace, name string) (string, error) {
+	var chatId string
+	err := r.db.GetDB().QueryRowContext(ctx, `SELECT chat_id FROM chat_names WHERE workspace = $1 AND name = $2`, workspace, name).Scan(&chatId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error looking up chat name: %v", err)
+	}
+	return chatId, nil
+}
+
+// Create gives the chat chatId the name name in workspace.
+func (r *ChatNameRepository) Create(ctx context.Context, workspace, name, chatId string) error {
+	query := `
+        INSERT INTO chat_names (workspace, name, chat_id)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING`
+
+	result, err := r.db.GetDB().ExecContext(ctx, query, workspace, name, chatId)
+	if err != nil {
+		return fmt.Errorf("error naming chat: %v", err)
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error naming chat: %v", err)
+	}
+	if created == 0 {
+		return ErrChatNameTaken
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestChatNameRepository(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatNameRepository(mockDB)
+
+	chatId, err := repo.ChatId(t.Context(), "/src/infra", "tf-migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chatId != "" {
+		t.Errorf("expected no chat for an unused name, got %q", chatId)
+	}
+
+	if err := repo.Create(t.Context(), "/src/infra", "tf-migration", "chat-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(t.Context(), "/src/infra", "tf-migration", "chat-2"); !errors.Is(err, ErrChatNameTaken) {
+		t.Errorf("expected ErrChatNameTaken, got %v", err)
+	}
+	// names only need to be unique within a workspace
+	if err := repo.Create(t.Context(), "/src/app", "tf-migration", "chat-3"); err != nil {
+		t.Fatal(err)
+	}
+
+	for workspace, want := range map[string]string{"/src/infra": "chat-1", "/src/app": "chat-3"} {
+		got, err := repo.ChatId(t.Context(), workspace, "tf-migration")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ChatId(%q) = %q, want %q", workspace, got, want)
+		}
+	}
+}
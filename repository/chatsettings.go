@@ -0,0 +1,66 @@
+// repository/chatsettings.go
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// ChatSettingsRepository keeps settings changed during a chat, such as the
+// temperature set with /set, so they're restored when the chat is resumed.
+type ChatSettingsRepository struct {
+	BaseRepository
+}
+
+func NewChatSettingsRepository(db db.Database) *ChatSettingsRepository {
+	return &ChatSettingsRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+// Get returns the settings saved for chatId, by key.
+func (r *ChatSettingsRepository) Get(ctx context.Context, chatId string) (map[string]string, error) {
+	rows, err := r.db.GetDB().QueryContext(ctx, `SELECT key, value FROM chat_settings WHERE chat_id = $1`, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving chat settings: %v", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("error scanning chat setting: %v", err)
+		}
+		settings[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over chat settings: %v", err)
+	}
+	return settings, nil
+}
+
+// Set saves value as the chat's key setting, replacing any earlier value.
+func (r *ChatSettingsRepository) Set(ctx context.Context, chatId, key, value string) error {
+	query := `
+        INSERT INTO chat_settings (chat_id, key, value)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (chat_id, key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := r.db.GetDB().ExecContext(ctx, query, chatId, key, value); err != nil {
+		return fmt.Errorf("error saving chat setting: %v", err)
+	}
+	return nil
+}
+
+// Unset removes the chat's key setting, if it has one.
+func (r *ChatSettingsRepository) Unset(ctx context.Context, chatId, key string) error {
+	if _, err := r.db.GetDB().ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = $1 AND key = $2`, chatId, key); err != nil {
+		return fmt.Errorf("error removing chat setting: %v", err)
+	}
+	return nil
+}
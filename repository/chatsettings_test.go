@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestChatSettingsRepository(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewChatSettingsRepository(mockDB)
+	ctx := t.Context()
+
+	settings, err := repo.Get(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 0 {
+		t.Errorf("expected no settings for a new chat, got %v", settings)
+	}
+
+	for _, kv := range [][2]string{{"temperature", "0.7"}, {"max-tokens", "2000"}, {"temperature", "0.2"}} {
+		if err := repo.Set(ctx, "chat-1", kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Set(ctx, "chat-2", "temperature", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = repo.Get(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 2 || settings["temperature"] != "0.2" || settings["max-tokens"] != "2000" {
+		t.Errorf("settings = %v; want the latest temperature and max-tokens", settings)
+	}
+
+	if err := repo.Unset(ctx, "chat-1", "temperature"); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = repo.Get(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := settings["temperature"]; ok || len(settings) != 1 {
+		t.Errorf("settings after Unset = %v", settings)
+	}
+}
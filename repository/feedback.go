@@ -0,0 +1,94 @@
+// repository/feedback.go
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// Feedback ratings, as /thumbs records them
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// Feedback is a rating someone gave a model's reply in chat. The prompt and
+// reply are copied in, so the rating stays usable after the chat is
+// archived or deleted.
+type Feedback struct { //nolint:govet // fieldalignment is a minor optimization
+	ID     int
+	ChatId string
+	// MessageID is the id of the rated reply in chats, or 0 if the reply
+	// wasn't saved.
+	MessageID int
+	ModelID   string
+	Rating    string
+	Comment   string
+	Prompt    string
+	Response  string
+	Created   string
+}
+
+// FeedbackRepository persists ratings of model replies
+type FeedbackRepository struct {
+	BaseRepository
+}
+
+func NewFeedbackRepository(db db.Database) *FeedbackRepository {
+	return &FeedbackRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+func (r *FeedbackRepository) Create(ctx context.Context, feedback *Feedback) error {
+	query := `
+        INSERT INTO feedback (chat_id, message_id, model_id, rating, comment, prompt, response)
+        VALUES ($1, NULLIF($2, 0), NULLIF($3, ''), $4, NULLIF($5, ''), $6, $7)
+        RETURNING id`
+
+	err := r.db.GetDB().QueryRowContext(ctx, query,
+		feedback.ChatId, feedback.MessageID, feedback.ModelID, feedback.Rating, feedback.Comment, feedback.Prompt, feedback.Response,
+	).Scan(&feedback.ID)
+	if err != nil {
+		return fmt.Errorf("error saving feedback: %v", err)
+	}
+	return nil
+}
+
+// List returns all feedback in the order it was given, or only feedback
+// with the given rating when rating isn't empty.
+func (r *FeedbackRepository) List(ctx context.Context, rating string) ([]Feedback, error) {
+	query := `
+        SELECT id, chat_id, COALESCE(message_id, 0), COALESCE(model_id, ''), rating, COALESCE(comment, ''), prompt, response, created_at
+        FROM feedback
+        WHERE $1 = '' OR rating = $1
+        ORDER BY id ASC`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query, rating)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving feedback: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var feedback []Feedback
+	for rows.Next() {
+		var f Feedback
+		if err := rows.Scan(&f.ID, &f.ChatId, &f.MessageID, &f.ModelID, &f.Rating, &f.Comment, &f.Prompt, &f.Response, &f.Created); err != nil {
+			return nil, fmt.Errorf("error scanning feedback: %v", err)
+		}
+		feedback = append(feedback, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over feedback: %v", err)
+	}
+
+	return feedback, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestFeedbackRepository(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewFeedbackRepository(mockDB)
+
+	feedback := []Feedback{
+		{ChatId: "chat-1", MessageID: 2, ModelID: "model-a", Rating: RatingUp, Prompt: "2+2?", Response: "4"},
+		{ChatId: "chat-1", MessageID: 4, Rating: RatingDown, Comment: "wrong year", Prompt: "When was Go released?", Response: "2012"},
+	}
+	for i := range feedback {
+		if err := repo.Create(t.Context(), &feedback[i]); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if feedback[i].ID == 0 {
+			t.Errorf("Expected an id for feedback %d", i)
+		}
+	}
+
+	all, err := repo.List(t.Context(), "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ModelID != "model-a" || all[0].Comment != "" || all[1].Comment != "wrong year" || all[1].ModelID != "" {
+		t.Errorf("Unexpected feedback %+v", all)
+	}
+	if all[0].Created == "" {
+		t.Error("Expected a created time")
+	}
+
+	down, err := repo.List(t.Context(), RatingDown)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(down) != 1 || down[0].MessageID != 4 || down[0].Response != "2012" {
+		t.Errorf("Expected only the thumbs down, got %+v", down)
+	}
+}
@@ -0,0 +1,108 @@
+// repository/image.go
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// Image records the parameters of a single image generation, so it can be
+// reproduced later with `image rerun`.
+type Image struct { //nolint:govet // fieldalignment is a minor optimization
+	ID       int
+	ModelId  string
+	Prompt   string
+	Seed     int
+	Scale    float64
+	Steps    int
+	Filename string
+	Created  string
+}
+
+// ErrImageNotFound is returned by GetByID when no generation has the given id.
+var ErrImageNotFound = errors.New("image generation not found")
+
+// ImageRepository persists image generation history
+type ImageRepository struct {
+	BaseRepository
+}
+
+func NewImageRepository(db db.Database) *ImageRepository {
+	return &ImageRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+func (r *ImageRepository) Create(ctx context.Context, image *Image) error {
+	query := `
+        INSERT INTO images (model_id, prompt, seed, scale, steps, filename)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id`
+
+	err := r.db.GetDB().QueryRowContext(ctx, query, image.ModelId, image.Prompt, image.Seed, image.Scale, image.Steps, image.Filename).Scan(&image.ID)
+	if err != nil {
+		return fmt.Errorf("error creating image: %v", err)
+	}
+
+	return nil
+}
+
+func (r *ImageRepository) GetByID(ctx context.Context, id int) (*Image, error) {
+	query := `
+        SELECT id, model_id, prompt, seed, scale, steps, filename, created_at
+        FROM images
+        WHERE id = $1`
+
+	var image Image
+	err := r.db.GetDB().QueryRowContext(ctx, query, id).Scan(
+		&image.ID, &image.ModelId, &image.Prompt, &image.Seed, &image.Scale, &image.Steps, &image.Filename, &image.Created,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving image: %v", err)
+	}
+
+	return &image, nil
+}
+
+// Function to list the 10 most recent image generations
+func (r *ImageRepository) List(ctx context.Context) ([]Image, error) {
+	query := `
+        SELECT id, model_id, prompt, seed, scale, steps, filename, created_at
+        FROM images
+        ORDER BY id DESC
+        LIMIT 10`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var images []Image
+	for rows.Next() {
+		var image Image
+		err := rows.Scan(&image.ID, &image.ModelId, &image.Prompt, &image.Seed, &image.Scale, &image.Steps, &image.Filename, &image.Created)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning image: %v", err)
+		}
+		images = append(images, image)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over images: %v", err)
+	}
+
+	return images, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestImageRepository_CreateAndGetByID(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewImageRepository(mockDB)
+
+	image := &Image{
+		ModelId:  "amazon.nova-canvas-v1:0",
+		Prompt:   "a lighthouse at dusk",
+		Seed:     42,
+		Scale:    7.5,
+		Steps:    30,
+		Filename: "lighthouse.jpg",
+	}
+
+	if err := repo.Create(t.Context(), image); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if image.ID == 0 {
+		t.Fatal("Image ID was not set after creation")
+	}
+
+	got, err := repo.GetByID(t.Context(), image.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+
+	if got.ModelId != image.ModelId || got.Prompt != image.Prompt || got.Seed != image.Seed ||
+		got.Scale != image.Scale || got.Steps != image.Steps || got.Filename != image.Filename {
+		t.Errorf("GetByID returned %+v, want fields matching %+v", got, image)
+	}
+	if got.Created == "" {
+		t.Error("Expected Created to be populated")
+	}
+}
+
+func TestImageRepository_GetByIDNotFound(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewImageRepository(mockDB)
+
+	_, err := repo.GetByID(t.Context(), 99)
+	if !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Expected ErrImageNotFound, got %v", err)
+	}
+}
+
+func TestImageRepository_ListLimitAndOrder(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewImageRepository(mockDB)
+
+	for i := 1; i <= 12; i++ {
+		image := &Image{
+			ModelId:  "amazon.nova-canvas-v1:0",
+			Prompt:   fmt.Sprintf("prompt %d", i),
+			Seed:     i,
+			Scale:    10,
+			Steps:    10,
+			Filename: fmt.Sprintf("%d.jpg", i),
+		}
+		if err := repo.Create(t.Context(), image); err != nil {
+			t.Fatalf("Failed to create test image %d: %v", i, err)
+		}
+	}
+
+	images, err := repo.List(t.Context())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	if len(images) != 10 {
+		t.Errorf("Expected 10 images (limit), got %d", len(images))
+	}
+	if len(images) >= 2 && images[0].ID < images[1].ID {
+		t.Error("Images are not ordered by ID DESC")
+	}
+}
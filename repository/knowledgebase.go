@@ -0,0 +1,193 @@
+// repository/knowledgebase.go
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// ErrKnowledgeBaseNotFound is returned by Delete when a knowledge base has
+// no chunks.
+var ErrKnowledgeBaseNotFound = errors.New("knowledge base not found")
+
+// KBChunk is one embedded piece of a document in a knowledge base.
+type KBChunk struct { //nolint:govet // fieldalignment is a minor optimization
+	ID int
+	KB string
+	// Source is the path of the document the chunk came from.
+	Source  string
+	Part    int
+	Content string
+	// StartLine and EndLine are the lines of the document the chunk
+	// covers; both are 0 for chunks added before they were recorded.
+	StartLine int
+	EndLine   int
+	// ModelId is the embedding model that produced Embedding. Queries
+	// must be embedded with the same model to be compared with it.
+	ModelId   string
+	Embedding []float64
+	Created   string
+}
+
+// KBSummary describes one knowledge base.
+type KBSummary struct { //nolint:govet // fieldalignment is a minor optimization
+	Name    string
+	ModelId string
+	Sources int
+	Chunks  int
+	Updated string
+}
+
+// KnowledgeBaseRepository persists the chunks of local knowledge bases
+type KnowledgeBaseRepository struct {
+	BaseRepository
+}
+
+func NewKnowledgeBaseRepository(db db.Database) *KnowledgeBaseRepository {
+	return &KnowledgeBaseRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+// ReplaceSource stores chunks as the contents of source in kb, removing
+// whatever was stored for it before, so re-adding a changed document
+// doesn't leave stale chunks behind.
+func (r *KnowledgeBaseRepository) ReplaceSource(ctx context.Context, kb, source string, chunks []KBChunk) error {
+	tx, err := r.db.GetDB().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error adding %s: %v", source, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM kb_chunks WHERE kb = $1 AND source = $2`, kb, source); err != nil {
+		return fmt.Errorf("error replacing %s: %v", source, err)
+	}
+
+	query := `
+        INSERT INTO kb_chunks (kb, source, part, content, model_id, embedding, start_line, end_line)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	for _, chunk := range chunks {
+		embedding, err := json.Marshal(chunk.Embedding)
+		if err != nil {
+			return fmt.Errorf("error encoding embedding: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, query, kb, source, chunk.Part, chunk.Content, chunk.ModelId, string(embedding), chunk.StartLine, chunk.EndLine); err != nil {
+			return fmt.Errorf("error adding chunk: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error adding %s: %v", source, err)
+	}
+	return nil
+}
+
+// Chunks returns every chunk in kb, with its embedding.
+func (r *KnowledgeBaseRepository) Chunks(ctx context.Context, kb string) ([]KBChunk, error) {
+	query := `
+        SELECT id, kb, source, part, content, model_id, embedding, COALESCE(start_line, 0), COALESCE(end_line, 0), created_at
+        FROM kb_chunks
+        WHERE kb = $1
+        ORDER BY source, part`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query, kb)
+	if err != nil {
+		return nil, fmt.Errorf("error querying knowledge base: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var chunks []KBChunk
+	for rows.Next() {
+		var chunk KBChunk
+		var embedding string
+		err := rows.Scan(&chunk.ID, &chunk.KB, &chunk.Source, &chunk.Part, &chunk.Content, &chunk.ModelId, &embedding, &chunk.StartLine, &chunk.EndLine, &chunk.Created)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning chunk: %v", err)
+		}
+		if err := json.Unmarshal([]byte(embedding), &chunk.Embedding); err != nil {
+			return nil, fmt.Errorf("error decoding embedding of chunk %d: %v", chunk.ID, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over chunks: %v", err)
+	}
+
+	return chunks, nil
+}
+
+// ModelId returns the embedding model kb was built with, or "" if kb has
+// no chunks.
+func (r *KnowledgeBaseRepository) ModelId(ctx context.Context, kb string) (string, error) {
+	var modelId string
+	err := r.db.GetDB().QueryRowContext(ctx, `SELECT COALESCE(MIN(model_id), '') FROM kb_chunks WHERE kb = $1`, kb).Scan(&modelId)
+	if err != nil {
+		return "", fmt.Errorf("error querying knowledge base: %v", err)
+	}
+	return modelId, nil
+}
+
+// List summarizes each knowledge base, by name.
+func (r *KnowledgeBaseRepository) List(ctx context.Context) ([]KBSummary, error) {
+	query := `
+        SELECT kb, MIN(model_id), COUNT(DISTINCT source), COUNT(*), MAX(created_at)
+        FROM kb_chunks
+        GROUP BY kb
+        ORDER BY kb`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing knowledge bases: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var summaries []KBSummary
+	for rows.Next() {
+		var s KBSummary
+		if err := rows.Scan(&s.Name, &s.ModelId, &s.Sources, &s.Chunks, &s.Updated); err != nil {
+			return nil, fmt.Errorf("error scanning knowledge base: %v", err)
+		}
+		summaries = append(summaries, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over knowledge bases: %v", err)
+	}
+
+	return summaries, nil
+}
+
+// Delete removes kb and returns how many chunks it held.
+func (r *KnowledgeBaseRepository) Delete(ctx context.Context, kb string) (int64, error) {
+	result, err := r.db.GetDB().ExecContext(ctx, `DELETE FROM kb_chunks WHERE kb = $1`, kb)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting knowledge base: %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting knowledge base: %v", err)
+	}
+	if deleted == 0 {
+		return 0, ErrKnowledgeBaseNotFound
+	}
+
+	return deleted, nil
+}
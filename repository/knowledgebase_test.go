@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestKnowledgeBaseRepository(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewKnowledgeBaseRepository(mockDB)
+	model := "amazon.titan-embed-text-v2:0"
+
+	err := repo.ReplaceSource(t.Context(), "docs", "/notes/a.md", []KBChunk{
+		{Part: 1, Content: "first", ModelId: model, Embedding: []float64{1, 0}},
+		{Part: 2, Content: "second", ModelId: model, Embedding: []float64{0, 1}},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceSource failed: %v", err)
+	}
+	if err := repo.ReplaceSource(t.Context(), "docs", "/notes/b.md", []KBChunk{{Part: 1, Content: "other", ModelId: model, Embedding: []float64{0.5, 0.5}}}); err != nil {
+		t.Fatalf("ReplaceSource failed: %v", err)
+	}
+	if err := repo.ReplaceSource(t.Context(), "other", "/notes/c.md", []KBChunk{{Part: 1, Content: "elsewhere", ModelId: model, Embedding: []float64{1, 1}}}); err != nil {
+		t.Fatalf("ReplaceSource failed: %v", err)
+	}
+
+	// adding a source again replaces its chunks
+	if err := repo.ReplaceSource(t.Context(), "docs", "/notes/a.md", []KBChunk{{Part: 1, Content: "rewritten", ModelId: model, Embedding: []float64{0.25, 0.75}, StartLine: 3, EndLine: 7}}); err != nil {
+		t.Fatalf("ReplaceSource failed: %v", err)
+	}
+
+	chunks, err := repo.Chunks(t.Context(), "docs")
+	if err != nil {
+		t.Fatalf("Chunks failed: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %+v", chunks)
+	}
+	if chunks[0].Source != "/notes/a.md" || chunks[0].Content != "rewritten" || !reflect.DeepEqual(chunks[0].Embedding, []float64{0.25, 0.75}) || chunks[0].StartLine != 3 || chunks[0].EndLine != 7 {
+		t.Errorf("unexpected first chunk %+v", chunks[0])
+	}
+	if chunks[1].Source != "/notes/b.md" || chunks[1].ModelId != model {
+		t.Errorf("unexpected second chunk %+v", chunks[1])
+	}
+
+	if got, err := repo.ModelId(t.Context(), "docs"); err != nil || got != model {
+		t.Errorf("ModelId = %q, %v; want %q", got, err, model)
+	}
+	if got, err := repo.ModelId(t.Context(), "missing"); err != nil || got != "" {
+		t.Errorf("ModelId of a missing kb = %q, %v; want empty", got, err)
+	}
+
+	summaries, err := repo.List(t.Context())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 knowledge bases, got %+v", summaries)
+	}
+	if got := summaries[0]; got.Name != "docs" || got.Sources != 2 || got.Chunks != 2 || got.ModelId != model {
+		t.Errorf("unexpected summary %+v", got)
+	}
+
+	deleted, err := repo.Delete(t.Context(), "docs")
+	if err != nil || deleted != 2 {
+		t.Errorf("Delete = %d, %v; want 2", deleted, err)
+	}
+	if _, err := repo.Delete(t.Context(), "docs"); !errors.Is(err, ErrKnowledgeBaseNotFound) {
+		t.Errorf("expected ErrKnowledgeBaseNotFound, got %v", err)
+	}
+	if chunks, _ := repo.Chunks(t.Context(), "other"); len(chunks) != 1 {
+		t.Errorf("expected the other knowledge base to be left alone, got %+v", chunks)
+	}
+}
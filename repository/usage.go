@@ -0,0 +1,119 @@
+// repository/usage.go
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chat-cli/chat-cli/db"
+)
+
+// Usage records the tokens billed for a single model request.
+type Usage struct { //nolint:govet // fieldalignment is a minor optimization
+	ID      int
+	ChatId  string
+	ModelId string
+	// ProfileArn is the inference profile ARN the request was sent to, if
+	// any. Application inference profiles carry the cost allocation tags
+	// AWS bills against.
+	ProfileArn       string
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	// Cost is the estimated cost in US dollars, or nil if the model's
+	// price isn't known.
+	Cost    *float64
+	Created string
+}
+
+// UsageTotal sums the usage of one model and profile.
+type UsageTotal struct { //nolint:govet // fieldalignment is a minor optimization
+	ModelId          string
+	ProfileArn       string
+	Requests         int
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Cost             float64
+	// Unpriced counts the requests whose cost isn't known, which Cost
+	// leaves out.
+	Unpriced int
+}
+
+// UsageRepository persists per-request token usage
+type UsageRepository struct {
+	BaseRepository
+}
+
+func NewUsageRepository(db db.Database) *UsageRepository {
+	return &UsageRepository{
+		BaseRepository: BaseRepository{db: db},
+	}
+}
+
+func (r *UsageRepository) Create(ctx context.Context, usage *Usage) error {
+	query := `
+        INSERT INTO token_usage (chat_id, model_id, profile_arn, input_tokens, output_tokens, cache_read_tokens, cache_write_tokens, cost)
+        VALUES (NULLIF($1, ''), $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+        RETURNING id`
+
+	var cost sql.NullFloat64
+	if usage.Cost != nil {
+		cost = sql.NullFloat64{Float64: *usage.Cost, Valid: true}
+	}
+
+	err := r.db.GetDB().QueryRowContext(ctx, query,
+		usage.ChatId, usage.ModelId, usage.ProfileArn,
+		usage.InputTokens, usage.OutputTokens, usage.CacheReadTokens, usage.CacheWriteTokens, cost,
+	).Scan(&usage.ID)
+	if err != nil {
+		return fmt.Errorf("error recording usage: %v", err)
+	}
+	return nil
+}
+
+// Totals sums usage recorded at or after since for each model and profile,
+// most expensive first.
+func (r *UsageRepository) Totals(ctx context.Context, since time.Time) ([]UsageTotal, error) {
+	query := `
+        SELECT model_id, COALESCE(profile_arn, ''), COUNT(*),
+            SUM(input_tokens), SUM(output_tokens), SUM(cache_read_tokens), SUM(cache_write_tokens),
+            COALESCE(SUM(cost), 0), SUM(CASE WHEN cost IS NULL THEN 1 ELSE 0 END)
+        FROM token_usage
+        WHERE created_at >= $1
+        GROUP BY model_id, profile_arn
+        ORDER BY COALESCE(SUM(cost), 0) DESC, model_id`
+
+	rows, err := r.db.GetDB().QueryContext(ctx, query, since.UTC().Format(timestampLayout))
+	if err != nil {
+		return nil, fmt.Errorf("error summing usage: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			// Log error but don't return it as we're already processing the main query
+			fmt.Printf("Warning: failed to close rows: %v\n", err)
+		}
+	}()
+
+	var totals []UsageTotal
+	for rows.Next() {
+		var t UsageTotal
+		err := rows.Scan(&t.ModelId, &t.ProfileArn, &t.Requests,
+			&t.InputTokens, &t.OutputTokens, &t.CacheReadTokens, &t.CacheWriteTokens,
+			&t.Cost, &t.Unpriced)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning usage: %v", err)
+		}
+		totals = append(totals, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over usage: %v", err)
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestUsageRepository_Totals(t *testing.T) {
+	mockDB := setupTestDB(t)
+	defer func() {
+		if err := mockDB.Close(); err != nil {
+			t.Errorf("Failed to close mock database: %v", err)
+		}
+	}()
+
+	repo := NewUsageRepository(mockDB)
+
+	cost := func(c float64) *float64 { return &c }
+	profile := "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/team-a"
+	rows := []Usage{
+		{ChatId: "chat-1", ModelId: profile, ProfileArn: profile, InputTokens: 100, OutputTokens: 10, Cost: cost(0.5)},
+		{ChatId: "chat-2", ModelId: profile, ProfileArn: profile, InputTokens: 50, OutputTokens: 5, CacheReadTokens: 20, Cost: cost(0.25)},
+		{ChatId: "chat-1", ModelId: "amazon.nova-pro-v1:0", InputTokens: 10, OutputTokens: 1, Cost: cost(0.01)},
+		{ModelId: "mystery.model-v1", InputTokens: 1, OutputTokens: 1},
+	}
+	for i := range rows {
+		if err := repo.Create(t.Context(), &rows[i]); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if rows[i].ID == 0 {
+			t.Error("expected Create to set the ID")
+		}
+	}
+
+	// a row from long ago that the report period leaves out
+	_, err := mockDB.GetDB().Exec(`INSERT INTO token_usage (model_id, input_tokens, output_tokens, cost, created_at) VALUES ('amazon.nova-pro-v1:0', 1000, 1000, 9, '2000-01-01 00:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals, err := repo.Totals(t.Context(), time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("Totals failed: %v", err)
+	}
+	if len(totals) != 3 {
+		t.Fatalf("expected 3 groups, got %+v", totals)
+	}
+
+	got := totals[0]
+	if got.ProfileArn != profile || got.Requests != 2 || got.InputTokens != 150 || got.CacheReadTokens != 20 || got.Cost != 0.75 {
+		t.Errorf("unexpected profile total %+v", got)
+	}
+	if got := totals[1]; got.ModelId != "amazon.nova-pro-v1:0" || got.ProfileArn != "" || got.InputTokens != 10 {
+		t.Errorf("expected the old row to be left out, got %+v", got)
+	}
+	if got := totals[2]; got.Unpriced != 1 || got.Cost != 0 {
+		t.Errorf("expected an unpriced request, got %+v", got)
+	}
+}
@@ -0,0 +1,122 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// maxFileSize bounds what {{file "path"}} reads, so a stray path to a
+	// log or binary doesn't swamp the prompt.
+	maxFileSize = 256 << 10
+	// gitTimeout bounds the git command behind {{git_branch}}.
+	gitTimeout = 5 * time.Second
+)
+
+// function is a helper a template can call, as {{name}} or, when it takes
+// an argument, {{name "arg"}}. Functions run when the template is
+// rendered, never through a shell.
+type function struct {
+	takesArg bool
+	call     func(arg string) (string, error)
+}
+
+// functions are the helpers templates can call. The names of those that
+// take no argument can't be used as variable names.
+var functions = map[string]function{
+	"now":        {call: func(string) (string, error) { return timeNow().Format("2006-01-02 15:04:05 MST"), nil }},
+	"git_branch": {call: func(string) (string, error) { return gitBranch() }},
+	"env":        {takesArg: true, call: lookupEnv},
+	"file":       {takesArg: true, call: readFile},
+}
+
+// timeNow and gitBranch are replaced in tests.
+var (
+	timeNow   = time.Now
+	gitBranch = currentGitBranch
+)
+
+// isCall reports whether a placeholder naming name, with an argument or
+// not, calls a function. Functions that take an argument need it, so
+// {{file}} is still an ordinary variable and only {{file "notes.md"}} reads
+// a file.
+func isCall(name string, hasArg bool) bool {
+	fn, ok := functions[name]
+	return ok && fn.takesArg == hasArg
+}
+
+// callFunction runs the named function on arg.
+func callFunction(name, arg string) (string, error) {
+	value, err := functions[name].call(arg)
+	if err != nil {
+		return "", fmt.Errorf("{{%s}}: %w", name, err)
+	}
+	return value, nil
+}
+
+// currentGitBranch returns the branch checked out in the working
+// directory.
+func currentGitBranch() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", errors.New("the working directory isn't in a git repository with a commit")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// secretWords mark environment variables {{env}} won't read, so a shared
+// template can't send credentials to the model.
+var secretWords = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "CREDENTIAL", "PRIVATE", "ACCESS_KEY", "API_KEY", "SESSION"}
+
+func lookupEnv(name string) (string, error) {
+	upper := strings.ToUpper(name)
+	for _, word := range secretWords {
+		if strings.Contains(upper, word) {
+			return "", fmt.Errorf("%s looks like a secret, so it can't be used in a template", name)
+		}
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%s isn't set", name)
+	}
+	return value, nil
+}
+
+// readFile returns a file's contents. Only files inside the working
+// directory can be read; absolute paths, and paths or symlinks that lead
+// out of it, are refused.
+func readFile(path string) (string, error) {
+	root, err := os.OpenRoot(".")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	f, err := root.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("can't read %s (only files inside the working directory can be included): %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("can't read %s: %w", path, err)
+	}
+	if len(data) > maxFileSize {
+		return "", fmt.Errorf("%s is larger than %d KiB", path, maxFileSize>>10)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,80 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderFunctions(t *testing.T) {
+	timeNow = func() time.Time { return time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC) }
+	gitBranch = func() (string, error) { return "fix-login", nil }
+	defer func() {
+		timeNow = time.Now
+		gitBranch = currentGitBranch
+	}()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.md"), []byte("ship it"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	t.Setenv("CHAT_CLI_TEST_TEAM", "payments")
+
+	text := `{{ now }} on {{git_branch}} for {{env "CHAT_CLI_TEST_TEAM"}}: {{file "notes.md"}} ({{file}})`
+	if got := Variables(text); !reflect.DeepEqual(got, []string{"file"}) {
+		t.Errorf("expected only file as a variable, got %v", got)
+	}
+	got, err := Render(text, map[string]string{"file": "main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2026-03-14 09:30:00 UTC on fix-login for payments: ship it (main.go)"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderFunctionErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("CHAT_CLI_TEST_TOKEN", "secret")
+
+	tests := []struct {
+		text    string
+		wantErr string
+	}{
+		{`{{env "CHAT_CLI_TEST_UNSET"}}`, "isn't set"},
+		{`{{env "CHAT_CLI_TEST_TOKEN"}}`, "looks like a secret"},
+		{`{{file "missing.md"}}`, "can't read missing.md"},
+		{`{{file "../outside.md"}}`, "only files inside the working directory"},
+		{`{{file "/etc/hostname"}}`, "only files inside the working directory"},
+		{`{{env "bad\q"}}`, "invalid argument"},
+	}
+	for _, tt := range tests {
+		if _, err := Render(tt.text, nil); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Render(%s): expected an error containing %q, got %v", tt.text, tt.wantErr, err)
+		}
+	}
+}
+
+func TestRenderLeavesUnknownCalls(t *testing.T) {
+	got, err := Render(`{{upper "x"}} {{ now "x" }}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{{upper "x"}} {{ now "x" }}` {
+		t.Errorf("expected unknown calls left as written, got %q", got)
+	}
+}
+
+func TestReadFileSizeLimit(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("big.log", make([]byte, maxFileSize+1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFile("big.log"); err == nil || !strings.Contains(err.Error(), "larger than 256 KiB") {
+		t.Errorf("expected a size error, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+// Package templates stores reusable prompt templates in chat-cli's config
+// directory, one YAML file per template.
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const templatesDirName = "templates"
+
+// ErrTemplateNotFound is returned by Load when no template has the given
+// name.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Template is a prompt with {{variable}} placeholders. The placeholder
+// syntax is the one Bedrock Prompt Management uses, so a template can be
+// pushed to and pulled from a managed prompt unchanged.
+type Template struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Text        string `yaml:"text"`
+	// ModelID is the model the managed prompt runs on when pushed.
+	ModelID string `yaml:"model_id,omitempty"`
+	// PromptARN links the template to its managed prompt once pushed or
+	// pulled, so later syncs update the same prompt.
+	PromptARN string `yaml:"prompt_arn,omitempty"`
+}
+
+// variablePattern matches a {{name}} placeholder, allowing spaces inside
+// the braces.
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// placeholderPattern matches a variable placeholder or a function call
+// with a quoted argument, such as {{env "HOME"}}.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)(\s+"(?:[^"\\]|\\.)*")?\s*\}\}`)
+
+// namePattern restricts template names to something safe to use as a file
+// name and as a managed prompt name.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,99}$`)
+
+// ValidateName reports whether name can be used for a template.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name %q: use letters, numbers, '-' and '_' (up to 100 characters)", name)
+	}
+	return nil
+}
+
+// Variables returns the distinct placeholder names in text, in the order
+// they first appear. Calls to functions such as {{now}} aren't variables.
+func Variables(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range variablePattern.FindAllStringSubmatch(text, -1) {
+		if isCall(match[1], false) {
+			continue
+		}
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render fills in text's placeholders from vars and calls the functions
+// it uses, such as {{now}} and {{file "path"}}. Every placeholder must
+// have a value, and every value must be used, so a misspelled variable
+// name is caught rather than silently ignored. Values are inserted as
+// they are: placeholders inside them aren't expanded.
+func Render(text string, vars map[string]string) (string, error) {
+	used := make(map[string]bool, len(vars))
+	var missing []string
+	for _, name := range Variables(text) {
+		if _, ok := vars[name]; ok {
+			used[name] = true
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing values for %s (set them with --var name=value)", strings.Join(missing, ", "))
+	}
+
+	var unused []string
+	for name := range vars {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("the template has no variables named %s", strings.Join(unused, ", "))
+	}
+
+	var callErr error
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+		name, quoted := match[1], strings.TrimSpace(match[2])
+		switch {
+		case !isCall(name, quoted != "") && quoted == "":
+			return vars[name]
+		case !isCall(name, quoted != ""):
+			// not a call chat-cli knows, so leave it as written
+			return placeholder
+		case callErr != nil:
+			return ""
+		}
+
+		var arg string
+		if quoted != "" {
+			var err error
+			if arg, err = strconv.Unquote(quoted); err != nil {
+				callErr = fmt.Errorf("invalid argument in %s: %w", placeholder, err)
+				return ""
+			}
+		}
+		value, err := callFunction(name, arg)
+		if err != nil {
+			callErr = err
+		}
+		return value
+	})
+	if callErr != nil {
+		return "", callErr
+	}
+	return rendered, nil
+}
+
+// Store reads and writes templates under a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a Store for the templates kept in configPath (chat-cli's
+// config directory). The directory is created on first Save.
+func NewStore(configPath string) *Store {
+	return &Store{dir: filepath.Join(configPath, templatesDirName)}
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".yaml")
+}
+
+// Load returns the named template.
+func (s *Store) Load(name string) (*Template, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.path(name)) // #nosec G304 - name is validated and the directory is chat-cli's own
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading template %s: %w", name, err)
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", name, err)
+	}
+	// the file name is authoritative, so a hand-renamed file still loads
+	t.Name = name
+
+	return &t, nil
+}
+
+// Save writes t, replacing any existing template with the same name.
+func (s *Store) Save(t *Template) error {
+	if err := ValidateName(t.Name); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("error encoding template %s: %w", t.Name, err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("error creating templates directory: %w", err)
+	}
+
+	if err := os.WriteFile(s.path(t.Name), data, 0600); err != nil {
+		return fmt.Errorf("error writing template %s: %w", t.Name, err)
+	}
+	return nil
+}
+
+// List returns every stored template, sorted by name. Files that can't be
+// read or parsed are skipped rather than failing the whole listing.
+func (s *Store) List() ([]Template, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing templates: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".yaml")
+		if entry.IsDir() || !ok || ValidateName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list []Template
+	for _, name := range names {
+		t, err := s.Load(name)
+		if err != nil {
+			continue
+		}
+		list = append(list, *t)
+	}
+	return list, nil
+}
+
+// Delete removes the named template.
+func (s *Store) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrTemplateNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting template %s: %w", name, err)
+	}
+	return nil
+}
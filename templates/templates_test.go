@@ -0,0 +1,149 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	got := Variables("Review {{file}} for {{ focus }} issues. Be strict about {{focus}}. {{ not-a-var }}")
+	want := []string{"file", "focus"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+
+	if got := Variables("no placeholders here"); len(got) != 0 {
+		t.Errorf("expected no variables, got %v", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	text := "Review {{file}} for {{ focus }} issues. Be strict about {{focus}}."
+
+	t.Run("fills in every placeholder", func(t *testing.T) {
+		got, err := Render(text, map[string]string{"file": "main.go", "focus": "{{concurrency}}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "Review main.go for {{concurrency}} issues. Be strict about {{concurrency}}."
+		if got != want {
+			t.Errorf("Render() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("missing values", func(t *testing.T) {
+		_, err := Render(text, map[string]string{"file": "main.go"})
+		if err == nil || !strings.Contains(err.Error(), "missing values for focus") {
+			t.Errorf("expected a missing value error, got %v", err)
+		}
+	})
+
+	t.Run("unknown variables", func(t *testing.T) {
+		_, err := Render(text, map[string]string{"file": "main.go", "focus": "x", "fcous": "y"})
+		if err == nil || !strings.Contains(err.Error(), "no variables named fcous") {
+			t.Errorf("expected an unknown variable error, got %v", err)
+		}
+	})
+
+	t.Run("no placeholders", func(t *testing.T) {
+		if got, err := Render("plain", nil); err != nil || got != "plain" {
+			t.Errorf("got %q, %v", got, err)
+		}
+	})
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"review", "code-review_2", "A1"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q): unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../escape", "has space", "-leading", "dot.name"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q): expected an error", name)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Run("save and load round trip", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+		saved := &Template{
+			Name:      "review",
+			Text:      "Review {{file}}",
+			ModelID:   "amazon.nova-pro-v1:0",
+			PromptARN: "arn:aws:bedrock:us-east-1:123456789012:prompt/ABC123",
+		}
+		if err := store.Save(saved); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := store.Load("review")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, saved) {
+			t.Errorf("loaded %+v, want %+v", loaded, saved)
+		}
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+		if _, err := store.Load("nope"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("list is sorted and skips stray files", func(t *testing.T) {
+		configPath := t.TempDir()
+		store := NewStore(configPath)
+		for _, name := range []string{"zeta", "alpha"} {
+			if err := store.Save(&Template{Name: name, Text: "hi"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dir := filepath.Join(configPath, templatesDirName)
+		if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte(":\n\t- ["), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].Name != "alpha" || list[1].Name != "zeta" {
+			t.Errorf("unexpected list %+v", list)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := NewStore(t.TempDir())
+		if err := store.Save(&Template{Name: "review", Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Delete("review"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Load("review"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected the template to be gone, got %v", err)
+		}
+		if err := store.Delete("review"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("list with no templates directory", func(t *testing.T) {
+		list, err := NewStore(t.TempDir()).List()
+		if err != nil || len(list) != 0 {
+			t.Errorf("expected an empty list, got %v, %v", list, err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"encoding/json"
+)
+
+// cachedResultNote prefixes a result served from a ResultCache, so the
+// model knows it asked for something it already has.
+const cachedResultNote = "[cached: identical to an earlier call in this turn]\n"
+
+// ResultCache remembers successful results of read-only tools (those that
+// don't require confirmation) for the duration of one agent run, so a
+// model that re-reads the same file or re-lists the same directory in a
+// loop gets an instant answer instead of another filesystem pass. Any
+// call to a destructive tool clears it, since that may have changed what
+// a read would return.
+type ResultCache struct {
+	results map[string]string
+}
+
+// Uncacheable is implemented by read-only tools whose results can differ
+// between identical calls, such as a clock or a random number generator.
+// A ResultCache never answers for a tool whose Uncacheable returns true.
+type Uncacheable interface {
+	Uncacheable() bool
+}
+
+// cacheable reports whether a ResultCache may keep tool's results.
+func cacheable(tool Tool) bool {
+	if tool.RequiresConfirmation() {
+		return false
+	}
+	u, ok := tool.(Uncacheable)
+	return !ok || !u.Uncacheable()
+}
+
+// NewResultCache creates an empty ResultCache. Create one per run; a cache
+// shouldn't outlive the turn it was made for, since files can change
+// between turns.
+func NewResultCache() *ResultCache {
+	return &ResultCache{results: make(map[string]string)}
+}
+
+func (c *ResultCache) get(name string, input json.RawMessage) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	result, ok := c.results[resultCacheKey(name, input)]
+	return result, ok
+}
+
+func (c *ResultCache) put(name string, input json.RawMessage, result string) {
+	if c == nil {
+		return
+	}
+	c.results[resultCacheKey(name, input)] = result
+}
+
+func (c *ResultCache) clear() {
+	if c == nil {
+		return
+	}
+	c.results = make(map[string]string)
+}
+
+// resultCacheKey identifies a call by tool name and its input, with the
+// input re-encoded so key order and whitespace differences don't cause a
+// miss.
+func resultCacheKey(name string, input json.RawMessage) string {
+	var parsed interface{}
+	if err := json.Unmarshal(input, &parsed); err == nil {
+		if canonical, err := json.Marshal(parsed); err == nil {
+			return name + "\x00" + string(canonical)
+		}
+	}
+	return name + "\x00" + string(input)
+}
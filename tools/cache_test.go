@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+)
+
+// countingTool is a read-only test double that counts Execute calls.
+type countingTool struct {
+	calls int
+}
+
+func (c *countingTool) Name() string        { return "counting_tool" }
+func (c *countingTool) Description() string { return "counts its calls" }
+func (c *countingTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{"type": "object"})
+}
+func (c *countingTool) Execute(_ context.Context, _ json.RawMessage) (string, error) {
+	c.calls++
+	return "result", nil
+}
+func (c *countingTool) RequiresConfirmation() bool { return false }
+func (c *countingTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// volatileTool is a countingTool whose results can't be cached.
+type volatileTool struct {
+	countingTool
+}
+
+func (v *volatileTool) Uncacheable() bool { return true }
+
+func TestRegistry_DispatchCached(t *testing.T) {
+	call := func(input string) ToolCall {
+		return ToolCall{Name: "counting_tool", ToolUseID: "call", Input: []byte(input)}
+	}
+
+	t.Run("identical read-only calls are served from cache", func(t *testing.T) {
+		tool := &countingTool{}
+		r := NewRegistry()
+		r.Register(tool)
+		cache := NewResultCache()
+
+		first := r.DispatchCached(context.Background(), call(`{"path":"a.txt","limit":5}`), nil, cache)
+		second := r.DispatchCached(context.Background(), call(`{ "limit": 5, "path": "a.txt" }`), nil, cache)
+
+		if tool.calls != 1 {
+			t.Errorf("expected 1 execution, got %d", tool.calls)
+		}
+		if text := resultText(t, first); text != "result" {
+			t.Errorf("expected first result uncached, got %q", text)
+		}
+		if text := resultText(t, second); !strings.HasPrefix(text, cachedResultNote) || !strings.HasSuffix(text, "result") {
+			t.Errorf("expected cached result with a note, got %q", text)
+		}
+	})
+
+	t.Run("different input misses", func(t *testing.T) {
+		tool := &countingTool{}
+		r := NewRegistry()
+		r.Register(tool)
+		cache := NewResultCache()
+
+		r.DispatchCached(context.Background(), call(`{"path":"a.txt"}`), nil, cache)
+		r.DispatchCached(context.Background(), call(`{"path":"b.txt"}`), nil, cache)
+
+		if tool.calls != 2 {
+			t.Errorf("expected 2 executions, got %d", tool.calls)
+		}
+	})
+
+	t.Run("destructive call clears the cache", func(t *testing.T) {
+		tool := &countingTool{}
+		r := NewRegistry()
+		r.Register(tool)
+		r.Register(&fakeDestructiveTool{name: "destroy", result: "done"})
+		cache := NewResultCache()
+		gate := &fakeGate{decision: DecisionAllowOnce}
+
+		r.DispatchCached(context.Background(), call(`{}`), gate, cache)
+		r.DispatchCached(context.Background(), ToolCall{Name: "destroy", ToolUseID: "d", Input: []byte(`{}`)}, gate, cache)
+		r.DispatchCached(context.Background(), call(`{}`), gate, cache)
+
+		if tool.calls != 2 {
+			t.Errorf("expected the read after a destructive call to execute again, got %d executions", tool.calls)
+		}
+	})
+
+	t.Run("uncacheable tools always execute", func(t *testing.T) {
+		tool := &volatileTool{}
+		r := NewRegistry()
+		r.Register(tool)
+		cache := NewResultCache()
+
+		r.DispatchCached(context.Background(), call(`{}`), nil, cache)
+		second := r.DispatchCached(context.Background(), call(`{}`), nil, cache)
+
+		if tool.calls != 2 {
+			t.Errorf("expected 2 executions, got %d", tool.calls)
+		}
+		if text := resultText(t, second); text != "result" {
+			t.Errorf("expected a fresh result, got %q", text)
+		}
+	})
+
+	t.Run("nil cache disables caching", func(t *testing.T) {
+		tool := &countingTool{}
+		r := NewRegistry()
+		r.Register(tool)
+
+		r.Dispatch(context.Background(), call(`{}`), nil)
+		r.Dispatch(context.Background(), call(`{}`), nil)
+
+		if tool.calls != 2 {
+			t.Errorf("expected 2 executions without a cache, got %d", tool.calls)
+		}
+	})
+}
@@ -0,0 +1,467 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+)
+
+// CalculatorTool is a read-only built-in tool that evaluates arithmetic
+// expressions, so a model can get exact answers instead of guessing at
+// them. Expressions are parsed, never executed as code.
+type CalculatorTool struct{}
+
+// NewCalculatorTool creates a CalculatorTool.
+func NewCalculatorTool() *CalculatorTool {
+	return &CalculatorTool{}
+}
+
+func (t *CalculatorTool) Name() string {
+	return "calculator"
+}
+
+func (t *CalculatorTool) Description() string {
+	return "Evaluate an arithmetic expression exactly. Use this for any calculation instead of working it out yourself. " +
+		"Supports + - * / % and ^ (power), parentheses, the constants pi, e and tau, and the functions " +
+		"sqrt, cbrt, abs, round(x[, digits]), floor, ceil, trunc, exp, ln, log(x[, base]), log2, log10, " +
+		"sin, cos, tan, asin, acos, atan (radians), hypot, pow, min and max. Numbers are double precision."
+}
+
+func (t *CalculatorTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"expression": map[string]interface{}{
+				"type":        "string",
+				"description": "The expression to evaluate, e.g. \"(1200 * 1.07^5) / 12\".",
+			},
+		},
+		"required": []string{"expression"},
+	})
+}
+
+type calculatorInput struct {
+	Expression string `json:"expression"`
+}
+
+func (t *CalculatorTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
+	var params calculatorInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+	}
+	if strings.TrimSpace(params.Expression) == "" {
+		return "", toolErrorf(ErrorKindInvalidInput, "expression is required")
+	}
+
+	value, err := evaluate(params.Expression)
+	if err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "can't evaluate %q: %w", params.Expression, err)
+	}
+	return formatNumber(value), nil
+}
+
+func (t *CalculatorTool) RequiresConfirmation() bool {
+	return false
+}
+
+func (t *CalculatorTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// formatNumber writes v to 15 significant digits, which hides binary
+// rounding noise (0.1+0.2 is 0.3), and whole numbers without an exponent
+// so large results stay readable.
+func formatNumber(v float64) string {
+	if v == 0 {
+		return "0" // and not -0
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1e21 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	return strconv.FormatFloat(rounded, 'g', -1, 64)
+}
+
+// evaluate parses and evaluates an arithmetic expression. Precedence runs,
+// lowest first: + and -, then * / and %, then unary minus, then ^, which
+// is right-associative (2^3^2 is 2^9, and -2^2 is -4).
+func evaluate(expr string) (float64, error) {
+	p := &exprParser{}
+	if err := p.tokenize(expr); err != nil {
+		return 0, err
+	}
+	v, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return 0, fmt.Errorf("unexpected %s", describeToken(tok))
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("the result is not a finite number")
+	}
+	return v, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+)
+
+type exprToken struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) tokenize(expr string) error {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			// an exponent only counts when digits follow it
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+					}
+				}
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
+			if err != nil {
+				return fmt.Errorf("bad number %q", text)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenNumber, text: text, value: value})
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenIdent, text: strings.ToLower(string(runes[start:i]))})
+		case r == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			p.tokens = append(p.tokens, exprToken{kind: tokenOp, text: "^"})
+			i += 2
+		case strings.ContainsRune("+-*/%^(),", r):
+			p.tokens = append(p.tokens, exprToken{kind: tokenOp, text: string(r)})
+			i++
+		case r == '×':
+			p.tokens = append(p.tokens, exprToken{kind: tokenOp, text: "*"})
+			i++
+		case r == '÷':
+			p.tokens = append(p.tokens, exprToken{kind: tokenOp, text: "/"})
+			i++
+		default:
+			return fmt.Errorf("unexpected character %q", r)
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.pos >= len(p.tokens) {
+		return exprToken{kind: tokenEnd, text: "end of expression"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.peek()
+	if tok.kind != tokenEnd {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it's the operator op.
+func (p *exprParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokenOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) sum() (float64, error) {
+	v, err := p.product()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept("+"):
+			rhs, err := p.product()
+			if err != nil {
+				return 0, err
+			}
+			v += rhs
+		case p.accept("-"):
+			rhs, err := p.product()
+			if err != nil {
+				return 0, err
+			}
+			v -= rhs
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) product() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("*"):
+			op = "*"
+		case p.accept("/"):
+			op = "/"
+		case p.accept("%"):
+			op = "%"
+		default:
+			return v, nil
+		}
+		rhs, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "*":
+			v *= rhs
+		case "/":
+			if rhs == 0 {
+				return 0, errors.New("division by zero")
+			}
+			v /= rhs
+		case "%":
+			if rhs == 0 {
+				return 0, errors.New("modulo by zero")
+			}
+			v = math.Mod(v, rhs)
+		}
+	}
+}
+
+func (p *exprParser) unary() (float64, error) {
+	switch {
+	case p.accept("-"):
+		v, err := p.unary()
+		return -v, err
+	case p.accept("+"):
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *exprParser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if !p.accept("^") {
+		return base, nil
+	}
+	exp, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exp), nil
+}
+
+func (p *exprParser) primary() (float64, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return tok.value, nil
+	case tokenIdent:
+		if p.accept("(") {
+			args, err := p.arguments()
+			if err != nil {
+				return 0, err
+			}
+			return callFunction(tok.text, args)
+		}
+		if v, ok := exprConstants[tok.text]; ok {
+			return v, nil
+		}
+		if _, ok := exprFunctions[tok.text]; ok {
+			return 0, fmt.Errorf("%s needs parentheses, e.g. %s(2)", tok.text, tok.text)
+		}
+		return 0, fmt.Errorf("unknown name %q", tok.text)
+	case tokenOp:
+		if tok.text == "(" {
+			v, err := p.sum()
+			if err != nil {
+				return 0, err
+			}
+			if !p.accept(")") {
+				return 0, errors.New("missing )")
+			}
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unexpected %s", describeToken(tok))
+}
+
+// arguments parses a function's comma-separated arguments, after its (.
+func (p *exprParser) arguments() ([]float64, error) {
+	var args []float64
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+		if p.accept(")") {
+			return args, nil
+		}
+		if !p.accept(",") {
+			return nil, errors.New("missing ) after function arguments")
+		}
+	}
+}
+
+func describeToken(tok exprToken) string {
+	if tok.kind == tokenEnd {
+		return tok.text
+	}
+	return strconv.Quote(tok.text)
+}
+
+var exprConstants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+}
+
+// exprFunction is a calculator function taking between min and max
+// arguments; max of -1 means any number.
+type exprFunction struct {
+	min, max int
+	fn       func(args []float64) (float64, error)
+}
+
+func unaryFunction(fn func(float64) float64) exprFunction {
+	return exprFunction{min: 1, max: 1, fn: func(args []float64) (float64, error) {
+		return fn(args[0]), nil
+	}}
+}
+
+var exprFunctions = map[string]exprFunction{
+	"sqrt": {min: 1, max: 1, fn: func(args []float64) (float64, error) {
+		if args[0] < 0 {
+			return 0, errors.New("sqrt of a negative number")
+		}
+		return math.Sqrt(args[0]), nil
+	}},
+	"cbrt":  unaryFunction(math.Cbrt),
+	"abs":   unaryFunction(math.Abs),
+	"floor": unaryFunction(math.Floor),
+	"ceil":  unaryFunction(math.Ceil),
+	"trunc": unaryFunction(math.Trunc),
+	"exp":   unaryFunction(math.Exp),
+	"sin":   unaryFunction(math.Sin),
+	"cos":   unaryFunction(math.Cos),
+	"tan":   unaryFunction(math.Tan),
+	"asin":  unaryFunction(math.Asin),
+	"acos":  unaryFunction(math.Acos),
+	"atan":  unaryFunction(math.Atan),
+	"ln":    {min: 1, max: 1, fn: func(args []float64) (float64, error) { return logarithm(args[0], math.E) }},
+	"log2":  {min: 1, max: 1, fn: func(args []float64) (float64, error) { return logarithm(args[0], 2) }},
+	"log10": {min: 1, max: 1, fn: func(args []float64) (float64, error) { return logarithm(args[0], 10) }},
+	"log": {min: 1, max: 2, fn: func(args []float64) (float64, error) {
+		if len(args) == 2 {
+			return logarithm(args[0], args[1])
+		}
+		return logarithm(args[0], 10)
+	}},
+	"round": {min: 1, max: 2, fn: func(args []float64) (float64, error) {
+		if len(args) == 1 {
+			return math.Round(args[0]), nil
+		}
+		scale := math.Pow(10, math.Trunc(args[1]))
+		return math.Round(args[0]*scale) / scale, nil
+	}},
+	"pow":   {min: 2, max: 2, fn: func(args []float64) (float64, error) { return math.Pow(args[0], args[1]), nil }},
+	"hypot": {min: 2, max: 2, fn: func(args []float64) (float64, error) { return math.Hypot(args[0], args[1]), nil }},
+	"min": {min: 1, max: -1, fn: func(args []float64) (float64, error) {
+		v := args[0]
+		for _, arg := range args[1:] {
+			v = math.Min(v, arg)
+		}
+		return v, nil
+	}},
+	"max": {min: 1, max: -1, fn: func(args []float64) (float64, error) {
+		v := args[0]
+		for _, arg := range args[1:] {
+			v = math.Max(v, arg)
+		}
+		return v, nil
+	}},
+}
+
+func callFunction(name string, args []float64) (float64, error) {
+	f, ok := exprFunctions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q", name)
+	}
+	if len(args) < f.min || (f.max >= 0 && len(args) > f.max) {
+		switch {
+		case f.min == f.max:
+			return 0, fmt.Errorf("%s takes %d argument(s), got %d", name, f.min, len(args))
+		case f.max < 0:
+			return 0, fmt.Errorf("%s takes at least %d argument(s), got %d", name, f.min, len(args))
+		default:
+			return 0, fmt.Errorf("%s takes %d to %d arguments, got %d", name, f.min, f.max, len(args))
+		}
+	}
+	return f.fn(args)
+}
+
+func logarithm(x, base float64) (float64, error) {
+	if x <= 0 {
+		return 0, errors.New("logarithm of a number that isn't positive")
+	}
+	if base <= 0 || base == 1 {
+		return 0, errors.New("logarithm base must be positive and not 1")
+	}
+	switch base {
+	case 2:
+		return math.Log2(x), nil
+	case 10:
+		return math.Log10(x), nil
+	}
+	return math.Log(x) / math.Log(base), nil
+}
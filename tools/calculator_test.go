@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCalculatorTool_Execute(t *testing.T) {
+	tool := NewCalculatorTool()
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"2 + 2", "4"},
+		{"0.1 + 0.2", "0.3"},
+		{"2 + 3 * 4", "14"},
+		{"(2 + 3) * 4", "20"},
+		{"10 / 4", "2.5"},
+		{"7 % 3", "1"},
+		{"2^10", "1024"},
+		{"2**10", "1024"},
+		{"2^3^2", "512"},
+		{"-2^2", "-4"},
+		{"2^-1", "0.5"},
+		{"--3", "3"},
+		{"1.5e3 + 1_000", "2500"},
+		{"sqrt(2)", "1.4142135623731"},
+		{"round(2/3, 2)", "0.67"},
+		{"log(1000)", "3"},
+		{"log(8, 2)", "3"},
+		{"ln(e)", "1"},
+		{"max(3, 9, 4) - min(3, 9, 4)", "6"},
+		{"sin(pi / 2)", "1"},
+		{"12345678901 * 1000", "12345678901000"},
+		{"1200 * 1.07^5 / 12", "140.25517307"},
+		{"6 × 7 ÷ 2", "21"},
+		{"PI * 0", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			input, _ := json.Marshal(calculatorInput{Expression: tt.expression})
+			got, err := tool.Execute(context.Background(), input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculatorTool_Errors(t *testing.T) {
+	tool := NewCalculatorTool()
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "expression is required"},
+		{"1 / 0", "division by zero"},
+		{"5 % 0", "modulo by zero"},
+		{"sqrt(-1)", "negative"},
+		{"log(0)", "isn't positive"},
+		{"(1 + 2", "missing )"},
+		{"1 + 2)", `unexpected ")"`},
+		{"2 +", "unexpected end of expression"},
+		{"foo + 1", `unknown name "foo"`},
+		{"bar(1)", `unknown function "bar"`},
+		{"sqrt 4", "needs parentheses"},
+		{"pow(2)", "takes 2 argument(s), got 1"},
+		{"2 $ 3", "unexpected character"},
+		{"10^400", "not a finite number"},
+		{"1.2.3", "bad number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			input, _ := json.Marshal(calculatorInput{Expression: tt.expression})
+			_, err := tool.Execute(context.Background(), input)
+			if err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %q", tt.want, err)
+			}
+			if kind := ErrorKindOf(err); kind != ErrorKindInvalidInput {
+				t.Errorf("expected %s, got %s", ErrorKindInvalidInput, kind)
+			}
+		})
+	}
+}
+
+func TestCalculatorTool_RequiresConfirmation(t *testing.T) {
+	if NewCalculatorTool().RequiresConfirmation() {
+		t.Error("expected calculator to not require confirmation - it's read-only")
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := map[float64]string{
+		3:           "3",
+		-0.0:        "0",
+		1e20:        "100000000000000000000",
+		1e22:        "1e+22",
+		1.0 / 3:     "0.333333333333333",
+		0.000012345: "1.2345e-05",
+	}
+	for v, want := range tests {
+		if got := formatNumber(v); got != want {
+			t.Errorf("formatNumber(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
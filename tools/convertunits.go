@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+)
+
+// ConvertUnitsTool is a read-only built-in tool that converts a quantity
+// between units of length, mass, volume, area, time, speed, data size,
+// temperature, energy, and pressure.
+type ConvertUnitsTool struct{}
+
+// NewConvertUnitsTool creates a ConvertUnitsTool.
+func NewConvertUnitsTool() *ConvertUnitsTool {
+	return &ConvertUnitsTool{}
+}
+
+func (t *ConvertUnitsTool) Name() string {
+	return "convert_units"
+}
+
+func (t *ConvertUnitsTool) Description() string {
+	return "Convert a value from one unit to another, exactly. Use this instead of converting yourself. " +
+		"Covers length, mass, volume, area, time, speed, data size, temperature, energy, and pressure, " +
+		"using common symbols or names such as km, mi, ft, kg, lb, l, gal, m2, acre, h, mph, GB, GiB, C, F, kWh, psi. " +
+		"Gallons, quarts, pints, and cups are US measures; years are 365.25 days."
+}
+
+func (t *ConvertUnitsTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"value": map[string]interface{}{
+				"type":        "number",
+				"description": "The quantity to convert.",
+			},
+			"from": map[string]interface{}{
+				"type":        "string",
+				"description": "The unit the value is in, e.g. \"mi\".",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "The unit to convert to, e.g. \"km\".",
+			},
+		},
+		"required": []string{"value", "from", "to"},
+	})
+}
+
+type convertUnitsInput struct {
+	Value *float64 `json:"value"`
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+}
+
+func (t *ConvertUnitsTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
+	var params convertUnitsInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+	}
+	if params.Value == nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "value is required")
+	}
+
+	from, ok := lookupUnit(params.From)
+	if !ok {
+		return "", toolErrorf(ErrorKindUnsupported, "unknown unit %q", params.From)
+	}
+	to, ok := lookupUnit(params.To)
+	if !ok {
+		return "", toolErrorf(ErrorKindUnsupported, "unknown unit %q", params.To)
+	}
+	if from.dimension != to.dimension {
+		return "", toolErrorf(ErrorKindInvalidInput, "can't convert %s (%s) to %s (%s); try one of: %s",
+			params.From, from.dimension, params.To, to.dimension, strings.Join(unitsOf(from.dimension), ", "))
+	}
+
+	base := from.toBase(*params.Value)
+	if from.dimension == "temperature" && base < 0 {
+		return "", toolErrorf(ErrorKindInvalidInput, "%s %s is below absolute zero", formatNumber(*params.Value), from.symbol)
+	}
+	converted := to.fromBase(base)
+	if math.IsNaN(converted) || math.IsInf(converted, 0) {
+		return "", toolErrorf(ErrorKindInvalidInput, "%s %s is too large to convert to %s", formatNumber(*params.Value), from.symbol, to.symbol)
+	}
+	return fmt.Sprintf("%s %s = %s %s", formatNumber(*params.Value), from.symbol, formatNumber(roundSignificant(converted)), to.symbol), nil
+}
+
+// significantDigits is how many digits of a result are shown; the floating
+// point error of a conversion is in the digits after them.
+const significantDigits = 12
+
+func roundSignificant(v float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', significantDigits, 64), 64)
+	return rounded
+}
+
+func (t *ConvertUnitsTool) RequiresConfirmation() bool {
+	return false
+}
+
+func (t *ConvertUnitsTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// unit is one unit of measure. A value in the unit is (value+offset)*factor
+// in its dimension's base unit; only temperatures have an offset.
+type unit struct {
+	symbol    string
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+func (u unit) toBase(v float64) float64 {
+	return (v + u.offset) * u.factor
+}
+
+func (u unit) fromBase(v float64) float64 {
+	return v/u.factor - u.offset
+}
+
+// unitTable lists each unit with the names it's known by, the first being
+// the symbol shown in results.
+var unitTable = []struct {
+	names     []string
+	dimension string
+	factor    float64
+	offset    float64
+}{
+	// length, in meters
+	{[]string{"m", "meter", "metre"}, "length", 1, 0},
+	{[]string{"km", "kilometer", "kilometre"}, "length", 1e3, 0},
+	{[]string{"cm", "centimeter", "centimetre"}, "length", 1e-2, 0},
+	{[]string{"mm", "millimeter", "millimetre"}, "length", 1e-3, 0},
+	{[]string{"µm", "micrometer", "micron"}, "length", 1e-6, 0},
+	{[]string{"nm", "nanometer"}, "length", 1e-9, 0},
+	{[]string{"in", "inch", "inches"}, "length", 0.0254, 0},
+	{[]string{"ft", "foot", "feet"}, "length", 0.3048, 0},
+	{[]string{"yd", "yard"}, "length", 0.9144, 0},
+	{[]string{"mi", "mile"}, "length", 1609.344, 0},
+	{[]string{"nmi", "nautical mile"}, "length", 1852, 0},
+
+	// mass, in kilograms
+	{[]string{"kg", "kilogram"}, "mass", 1, 0},
+	{[]string{"g", "gram"}, "mass", 1e-3, 0},
+	{[]string{"mg", "milligram"}, "mass", 1e-6, 0},
+	{[]string{"t", "tonne", "metric ton"}, "mass", 1e3, 0},
+	{[]string{"lb", "lbs", "pound"}, "mass", 0.45359237, 0},
+	{[]string{"oz", "ounce"}, "mass", 0.028349523125, 0},
+	{[]string{"st", "stone"}, "mass", 6.35029318, 0},
+
+	// volume, in liters
+	{[]string{"l", "liter", "litre"}, "volume", 1, 0},
+	{[]string{"ml", "milliliter", "millilitre", "cm3", "cc"}, "volume", 1e-3, 0},
+	{[]string{"m3", "cubic meter", "cubic metre"}, "volume", 1e3, 0},
+	{[]string{"gal", "gallon", "us gallon"}, "volume", 3.785411784, 0},
+	{[]string{"imp gal", "imperial gallon", "uk gallon"}, "volume", 4.54609, 0},
+	{[]string{"qt", "quart"}, "volume", 0.946352946, 0},
+	{[]string{"pt", "pint"}, "volume", 0.473176473, 0},
+	{[]string{"cup"}, "volume", 0.2365882365, 0},
+	{[]string{"fl oz", "floz", "fluid ounce"}, "volume", 0.0295735295625, 0},
+	{[]string{"tbsp", "tablespoon"}, "volume", 0.01478676478125, 0},
+	{[]string{"tsp", "teaspoon"}, "volume", 0.00492892159375, 0},
+	{[]string{"ft3", "cubic foot", "cubic feet"}, "volume", 28.316846592, 0},
+
+	// area, in square meters
+	{[]string{"m2", "square meter", "square metre", "sq m"}, "area", 1, 0},
+	{[]string{"km2", "square kilometer", "square kilometre", "sq km"}, "area", 1e6, 0},
+	{[]string{"cm2", "square centimeter", "square centimetre"}, "area", 1e-4, 0},
+	{[]string{"ha", "hectare"}, "area", 1e4, 0},
+	{[]string{"acre"}, "area", 4046.8564224, 0},
+	{[]string{"ft2", "square foot", "square feet", "sq ft"}, "area", 0.09290304, 0},
+	{[]string{"in2", "square inch", "square inches", "sq in"}, "area", 0.00064516, 0},
+	{[]string{"yd2", "square yard", "sq yd"}, "area", 0.83612736, 0},
+	{[]string{"mi2", "square mile", "sq mi"}, "area", 2589988.110336, 0},
+
+	// time, in seconds
+	{[]string{"s", "sec", "second"}, "time", 1, 0},
+	{[]string{"ms", "millisecond"}, "time", 1e-3, 0},
+	{[]string{"µs", "us", "microsecond"}, "time", 1e-6, 0},
+	{[]string{"ns", "nanosecond"}, "time", 1e-9, 0},
+	{[]string{"min", "minute"}, "time", 60, 0},
+	{[]string{"h", "hr", "hour"}, "time", 3600, 0},
+	{[]string{"d", "day"}, "time", 86400, 0},
+	{[]string{"wk", "week"}, "time", 604800, 0},
+	{[]string{"yr", "year"}, "time", 31557600, 0},
+
+	// speed, in meters per second
+	{[]string{"m/s", "mps", "meters per second"}, "speed", 1, 0},
+	{[]string{"km/h", "kph", "kmh", "kilometers per hour"}, "speed", 1 / 3.6, 0},
+	{[]string{"mph", "mi/h", "miles per hour"}, "speed", 0.44704, 0},
+	{[]string{"kn", "kt", "knot"}, "speed", 1852.0 / 3600, 0},
+	{[]string{"ft/s", "fps", "feet per second"}, "speed", 0.3048, 0},
+
+	// data size, in bytes; KB and the like are decimal, KiB binary
+	{[]string{"B", "byte"}, "data size", 1, 0},
+	{[]string{"bit"}, "data size", 1.0 / 8, 0},
+	{[]string{"KB", "kilobyte"}, "data size", 1e3, 0},
+	{[]string{"MB", "megabyte"}, "data size", 1e6, 0},
+	{[]string{"GB", "gigabyte"}, "data size", 1e9, 0},
+	{[]string{"TB", "terabyte"}, "data size", 1e12, 0},
+	{[]string{"PB", "petabyte"}, "data size", 1e15, 0},
+	{[]string{"KiB", "kibibyte"}, "data size", 1 << 10, 0},
+	{[]string{"MiB", "mebibyte"}, "data size", 1 << 20, 0},
+	{[]string{"GiB", "gibibyte"}, "data size", 1 << 30, 0},
+	{[]string{"TiB", "tebibyte"}, "data size", 1 << 40, 0},
+	{[]string{"PiB", "pebibyte"}, "data size", 1 << 50, 0},
+	{[]string{"kbit", "kilobit"}, "data size", 1e3 / 8, 0},
+	{[]string{"Mbit", "megabit"}, "data size", 1e6 / 8, 0},
+	{[]string{"Gbit", "gigabit"}, "data size", 1e9 / 8, 0},
+
+	// temperature, in kelvin
+	{[]string{"K", "kelvin"}, "temperature", 1, 0},
+	{[]string{"°C", "C", "celsius", "degc"}, "temperature", 1, 273.15},
+	{[]string{"°F", "F", "fahrenheit", "degf"}, "temperature", 5.0 / 9, 459.67},
+
+	// energy, in joules
+	{[]string{"J", "joule"}, "energy", 1, 0},
+	{[]string{"kJ", "kilojoule"}, "energy", 1e3, 0},
+	{[]string{"MJ", "megajoule"}, "energy", 1e6, 0},
+	{[]string{"cal", "calorie"}, "energy", 4.184, 0},
+	{[]string{"kcal", "kilocalorie"}, "energy", 4184, 0},
+	{[]string{"Wh", "watt hour"}, "energy", 3600, 0},
+	{[]string{"kWh", "kilowatt hour"}, "energy", 3.6e6, 0},
+	{[]string{"BTU", "british thermal unit"}, "energy", 1055.05585262, 0},
+
+	// pressure, in pascals
+	{[]string{"Pa", "pascal"}, "pressure", 1, 0},
+	{[]string{"kPa", "kilopascal"}, "pressure", 1e3, 0},
+	{[]string{"MPa", "megapascal"}, "pressure", 1e6, 0},
+	{[]string{"bar"}, "pressure", 1e5, 0},
+	{[]string{"mbar", "millibar", "hPa"}, "pressure", 100, 0},
+	{[]string{"atm", "atmosphere"}, "pressure", 101325, 0},
+	{[]string{"psi"}, "pressure", 6894.757293168, 0},
+	{[]string{"mmHg", "torr"}, "pressure", 133.322387415, 0},
+}
+
+// units indexes unitTable by every name, lowercased.
+var units = func() map[string]unit {
+	index := make(map[string]unit)
+	for _, entry := range unitTable {
+		u := unit{symbol: entry.names[0], dimension: entry.dimension, factor: entry.factor, offset: entry.offset}
+		for _, name := range entry.names {
+			index[strings.ToLower(name)] = u
+		}
+	}
+	return index
+}()
+
+// lookupUnit finds a unit by name, ignoring case, a leading "degrees" or
+// "°", and a plural s.
+func lookupUnit(name string) (unit, bool) {
+	key := strings.Join(strings.Fields(strings.ToLower(name)), " ")
+	key = strings.TrimPrefix(key, "degrees ")
+	if u, ok := units[key]; ok {
+		return u, true
+	}
+	if u, ok := units[strings.TrimPrefix(key, "°")]; ok {
+		return u, true
+	}
+	if u, ok := units[strings.TrimSuffix(key, "s")]; ok && strings.HasSuffix(key, "s") {
+		return u, true
+	}
+	return unit{}, false
+}
+
+// unitsOf lists the symbols of dimension's units, for an error message.
+func unitsOf(dimension string) []string {
+	var symbols []string
+	for _, entry := range unitTable {
+		if entry.dimension == dimension {
+			symbols = append(symbols, entry.names[0])
+		}
+	}
+	return symbols
+}
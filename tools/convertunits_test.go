@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func convertUnits(t *testing.T, value float64, from, to string) (string, error) {
+	t.Helper()
+	input, _ := json.Marshal(map[string]interface{}{"value": value, "from": from, "to": to})
+	return NewConvertUnitsTool().Execute(context.Background(), input)
+}
+
+func TestConvertUnitsTool_Execute(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     string
+	}{
+		{1, "mi", "km", "1 mi = 1.609344 km"},
+		{26.2, "miles", "kilometers", "26.2 mi = 42.1648128 km"},
+		{6, "feet", "in", "6 ft = 72 in"},
+		{100, "C", "F", "100 °C = 212 °F"},
+		{98.6, "degrees fahrenheit", "celsius", "98.6 °F = 37 °C"},
+		{0, "K", "°C", "0 K = -273.15 °C"},
+		{1, "GiB", "MB", "1 GiB = 1073.741824 MB"},
+		{100, "Mbit", "MB", "100 Mbit = 12.5 MB"},
+		{1, "gal", "l", "1 gal = 3.785411784 l"},
+		{1, "acre", "m2", "1 acre = 4046.8564224 m2"},
+		{60, "mph", "km/h", "60 mph = 96.56064 km/h"},
+		{90, "min", "h", "90 min = 1.5 h"},
+		{2, "kWh", "kJ", "2 kWh = 7200 kJ"},
+		{1, "atm", "psi", "1 atm = 14.6959487755 psi"},
+		{1, "C", "F", "1 °C = 33.8 °F"},
+		{0, "F", "C", "0 °F = -17.7777777778 °C"},
+		{5, "LB", "kg", "5 lb = 2.26796185 kg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got, err := convertUnits(t, tt.value, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnitsTool_Errors(t *testing.T) {
+	t.Run("unknown unit", func(t *testing.T) {
+		_, err := convertUnits(t, 1, "furlong", "m")
+		if ErrorKindOf(err) != ErrorKindUnsupported {
+			t.Errorf("expected an unsupported error, got %v", err)
+		}
+	})
+
+	t.Run("mismatched dimensions", func(t *testing.T) {
+		_, err := convertUnits(t, 1, "kg", "m")
+		if err == nil || !strings.Contains(err.Error(), "can't convert kg (mass) to m (length)") {
+			t.Fatalf("expected a dimension mismatch, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "kg, g, mg") {
+			t.Errorf("expected the mass units to be suggested, got %v", err)
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := convertUnits(t, 1e308, "km", "mm")
+		if err == nil || !strings.Contains(err.Error(), "too large") || ErrorKindOf(err) != ErrorKindInvalidInput {
+			t.Errorf("expected an invalid input error, got %v", err)
+		}
+	})
+
+	t.Run("below absolute zero", func(t *testing.T) {
+		for _, tt := range []struct {
+			value float64
+			from  string
+		}{{-1, "K"}, {-300, "C"}, {-500, "F"}} {
+			_, err := convertUnits(t, tt.value, tt.from, "K")
+			if err == nil || !strings.Contains(err.Error(), "below absolute zero") || ErrorKindOf(err) != ErrorKindInvalidInput {
+				t.Errorf("%v %s: expected an invalid input error, got %v", tt.value, tt.from, err)
+			}
+		}
+		if _, err := convertUnits(t, -459.67, "F", "C"); err != nil {
+			t.Errorf("expected absolute zero itself to convert, got %v", err)
+		}
+	})
+
+	t.Run("missing value", func(t *testing.T) {
+		_, err := NewConvertUnitsTool().Execute(context.Background(), []byte(`{"from":"m","to":"ft"}`))
+		if err == nil || !strings.Contains(err.Error(), "value is required") {
+			t.Errorf("expected a missing value error, got %v", err)
+		}
+	})
+}
+
+func TestUnitTable_NamesAreUnambiguous(t *testing.T) {
+	seen := map[string]string{}
+	for _, entry := range unitTable {
+		for _, name := range entry.names {
+			key := strings.ToLower(name)
+			if other, ok := seen[key]; ok {
+				t.Errorf("%q names both %s and %s", name, other, entry.names[0])
+			}
+			seen[key] = entry.names[0]
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+)
+
+// The trackers create_ticket can file tickets in.
+const (
+	TicketProviderJira   = "jira"
+	TicketProviderLinear = "linear"
+)
+
+const (
+	// defaultLinearEndpoint is Linear's GraphQL API, used when no endpoint
+	// is configured. Jira has no default: every site has its own URL.
+	defaultLinearEndpoint = "https://api.linear.app/graphql"
+	// ticketTimeout bounds each request to the tracker.
+	ticketTimeout = 30 * time.Second
+	// maxTicketSummaryPreview is how much of a ticket's description the
+	// confirmation prompt shows.
+	maxTicketSummaryPreview = 500
+)
+
+// TicketConfig is where create_ticket files tickets.
+type TicketConfig struct {
+	// Provider is TicketProviderJira or TicketProviderLinear.
+	Provider string
+	// Endpoint is the Jira site, such as https://my-org.atlassian.net, or
+	// Linear's API URL.
+	Endpoint string
+	Token    string
+	// User is the Jira account email an API token belongs to. Without it
+	// the token is sent as a bearer token, as Jira Data Center's personal
+	// access tokens are.
+	User string
+	// Project is the Jira project key or the Linear team ID tickets are
+	// created in.
+	Project string
+}
+
+// CreateTicketTool is a destructive built-in tool that files a ticket in
+// Jira or Linear, so a model can turn its findings into tracked work.
+// Every call passes through a PermissionGate, since a ticket is visible to
+// everyone on the project.
+type CreateTicketTool struct {
+	config     TicketConfig
+	httpClient *http.Client
+}
+
+// NewCreateTicketTool creates a CreateTicketTool for config, checking it
+// has what the provider needs.
+func NewCreateTicketTool(config TicketConfig) (*CreateTicketTool, error) {
+	switch config.Provider {
+	case TicketProviderJira:
+		if config.Endpoint == "" {
+			return nil, errors.New("a Jira endpoint is required, such as https://my-org.atlassian.net")
+		}
+	case TicketProviderLinear:
+		if config.Endpoint == "" {
+			config.Endpoint = defaultLinearEndpoint
+		}
+	default:
+		return nil, fmt.Errorf("unknown ticket provider %q: use %s or %s", config.Provider, TicketProviderJira, TicketProviderLinear)
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("a %s token is required", config.Provider)
+	}
+	if config.Project == "" {
+		return nil, fmt.Errorf("a %s project is required", config.Provider)
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+
+	return &CreateTicketTool{config: config, httpClient: &http.Client{Timeout: ticketTimeout}}, nil
+}
+
+func (t *CreateTicketTool) Name() string {
+	return "create_ticket"
+}
+
+func (t *CreateTicketTool) Description() string {
+	return fmt.Sprintf("File a ticket in the user's issue tracker (%s project %s) and return its key and URL. "+
+		"Use it for work the user asked to have tracked, one ticket per distinct task. "+
+		"Write a short, specific title and a description with the context someone picking it up would need, "+
+		"such as file paths and line numbers.", trackerName(t.config.Provider), t.config.Project)
+}
+
+func (t *CreateTicketTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title": map[string]interface{}{
+				"type":        "string",
+				"description": "The ticket's title.",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "The ticket's description, in Markdown for Linear or plain text for Jira.",
+			},
+		},
+		"required": []interface{}{"title", "description"},
+	})
+}
+
+type createTicketInput struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func parseCreateTicketInput(input json.RawMessage) (createTicketInput, error) {
+	var params createTicketInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return params, toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+	}
+	params.Title = strings.TrimSpace(params.Title)
+	if params.Title == "" {
+		return params, toolErrorf(ErrorKindInvalidInput, "title must not be empty")
+	}
+	if strings.ContainsAny(params.Title, "\r\n") {
+		return params, toolErrorf(ErrorKindInvalidInput, "title must be a single line")
+	}
+	return params, nil
+}
+
+func (t *CreateTicketTool) RequiresConfirmation() bool {
+	return true
+}
+
+// ConfirmationSummary shows the ticket to be filed. The pattern key is the
+// tracker and project, so approving for the session lets a run file
+// several tickets there without asking each time.
+func (t *CreateTicketTool) ConfirmationSummary(input json.RawMessage) (string, string, error) {
+	params, err := parseCreateTicketInput(input)
+	if err != nil {
+		return "", "", err
+	}
+
+	description := params.Description
+	if len(description) > maxTicketSummaryPreview {
+		description = fmt.Sprintf("%s\n... (%d bytes total, shown truncated)", description[:maxTicketSummaryPreview], len(params.Description))
+	}
+	summary := fmt.Sprintf("Create a %s ticket in %s: %s\n%s", trackerName(t.config.Provider), t.config.Project, params.Title, description)
+	return summary, t.config.Provider + ":" + t.config.Project, nil
+}
+
+func (t *CreateTicketTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	params, err := parseCreateTicketInput(input)
+	if err != nil {
+		return "", err
+	}
+
+	if t.config.Provider == TicketProviderLinear {
+		return t.createLinearIssue(ctx, params)
+	}
+	return t.createJiraIssue(ctx, params)
+}
+
+// createJiraIssue files a Task with Jira's REST API. Version 2 is used
+// since it takes the description as plain text.
+func (t *CreateTicketTool) createJiraIssue(ctx context.Context, params createTicketInput) (string, error) {
+	body := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": t.config.Project},
+			"summary":     params.Title,
+			"description": params.Description,
+			"issuetype":   map[string]string{"name": "Task"},
+		},
+	}
+	auth := "Bearer " + t.config.Token
+	if t.config.User != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(t.config.User+":"+t.config.Token))
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := t.post(ctx, t.config.Endpoint+"/rest/api/2/issue", auth, body, &created); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created %s: %s/browse/%s", created.Key, t.config.Endpoint, created.Key), nil
+}
+
+// linearIssueCreate is the GraphQL mutation that files a Linear issue.
+const linearIssueCreate = `mutation IssueCreate($input: IssueCreateInput!) {
+  issueCreate(input: $input) { success issue { identifier url } }
+}`
+
+// createLinearIssue files an issue with Linear's GraphQL API, which takes
+// API keys as they are rather than as bearer tokens.
+func (t *CreateTicketTool) createLinearIssue(ctx context.Context, params createTicketInput) (string, error) {
+	body := map[string]interface{}{
+		"query": linearIssueCreate,
+		"variables": map[string]interface{}{
+			"input": map[string]string{
+				"teamId":      t.config.Project,
+				"title":       params.Title,
+				"description": params.Description,
+			},
+		},
+	}
+
+	var created struct {
+		Data struct {
+			IssueCreate struct {
+				Success bool `json:"success"`
+				Issue   struct {
+					Identifier string `json:"identifier"`
+					URL        string `json:"url"`
+				} `json:"issue"`
+			} `json:"issueCreate"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := t.post(ctx, t.config.Endpoint, t.config.Token, body, &created); err != nil {
+		return "", err
+	}
+	// GraphQL reports failures in the body of a successful response
+	if len(created.Errors) > 0 {
+		return "", fmt.Errorf("Linear refused the issue: %s", created.Errors[0].Message)
+	}
+	if !created.Data.IssueCreate.Success {
+		return "", errors.New("Linear didn't create the issue")
+	}
+	issue := created.Data.IssueCreate.Issue
+	return fmt.Sprintf("Created %s: %s", issue.Identifier, issue.URL), nil
+}
+
+// post sends body as JSON with the given Authorization header and decodes
+// the response into out. Rejected credentials are permission errors, so
+// the model doesn't retry them.
+func (t *CreateTicketTool) post(ctx context.Context, url, auth string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return toolErrorf(ErrorKindPermission, "%s rejected the token (HTTP %d): %s", trackerName(t.config.Provider), resp.StatusCode, strings.TrimSpace(string(data)))
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("%s returned HTTP %d: %s", trackerName(t.config.Provider), resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// trackerName is the provider's name as it's written.
+func trackerName(provider string) string {
+	if provider == TicketProviderLinear {
+		return "Linear"
+	}
+	return "Jira"
+}
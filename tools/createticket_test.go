@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewCreateTicketTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TicketConfig
+		wantErr string
+	}{
+		{"jira", TicketConfig{Provider: "jira", Endpoint: "https://my-org.atlassian.net", Token: "t", Project: "OPS"}, ""},
+		{"linear without endpoint", TicketConfig{Provider: "linear", Token: "t", Project: "team"}, ""},
+		{"jira without endpoint", TicketConfig{Provider: "jira", Token: "t", Project: "OPS"}, "endpoint is required"},
+		{"unknown provider", TicketConfig{Provider: "trello", Token: "t", Project: "OPS"}, "unknown ticket provider"},
+		{"no token", TicketConfig{Provider: "linear", Project: "team"}, "token is required"},
+		{"no project", TicketConfig{Provider: "linear", Token: "t"}, "project is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, err := NewCreateTicketTool(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.config.Provider == "linear" && tool.config.Endpoint != defaultLinearEndpoint {
+					t.Errorf("expected Linear's API by default, got %s", tool.config.Endpoint)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateTicketTool_ConfirmationSummary(t *testing.T) {
+	tool, err := NewCreateTicketTool(TicketConfig{Provider: "jira", Endpoint: "https://my-org.atlassian.net", Token: "t", Project: "OPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tool.RequiresConfirmation() {
+		t.Error("expected create_ticket to require confirmation")
+	}
+
+	summary, patternKey, err := tool.ConfirmationSummary([]byte(`{"title":"Remove the TODO in main.go","description":"` + strings.Repeat("x", 600) + `"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(summary, "Create a Jira ticket in OPS: Remove the TODO in main.go\n") || !strings.Contains(summary, "600 bytes total, shown truncated") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if patternKey != "jira:OPS" {
+		t.Errorf("expected the project as pattern key, got %q", patternKey)
+	}
+
+	for _, bad := range []string{`{"title":"  ","description":"d"}`, `{"title":"two\nlines","description":"d"}`, `not json`} {
+		if _, _, err := tool.ConfirmationSummary([]byte(bad)); ErrorKindOf(err) != ErrorKindInvalidInput {
+			t.Errorf("expected invalid input for %s, got %v", bad, err)
+		}
+	}
+}
+
+func TestCreateTicketTool_Jira(t *testing.T) {
+	var got struct {
+		Fields struct {
+			Project   struct{ Key string }  `json:"project"`
+			Summary   string                `json:"summary"`
+			IssueType struct{ Name string } `json:"issuetype"`
+		} `json:"fields"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/issue" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if user, token, ok := r.BasicAuth(); !ok || user != "jane@example.com" || token != "secret" {
+			t.Errorf("expected basic auth with the account email, got %q", r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"10001","key":"OPS-42"}`))
+	}))
+	defer server.Close()
+
+	tool, err := NewCreateTicketTool(TicketConfig{Provider: "jira", Endpoint: server.URL + "/", Token: "secret", User: "jane@example.com", Project: "OPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tool.Execute(context.Background(), []byte(`{"title":"Remove the TODO","description":"main.go:12"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Created OPS-42: "+server.URL+"/browse/OPS-42" {
+		t.Errorf("unexpected result %q", result)
+	}
+	if got.Fields.Project.Key != "OPS" || got.Fields.Summary != "Remove the TODO" || got.Fields.IssueType.Name != "Task" {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
+
+func TestCreateTicketTool_Linear(t *testing.T) {
+	var got struct {
+		Variables struct {
+			Input map[string]string `json:"input"`
+		} `json:"variables"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "lin_api_secret" {
+			t.Errorf("expected the API key as is, got %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(`{"data":{"issueCreate":{"success":true,"issue":{"identifier":"ENG-7","url":"https://linear.app/my-org/issue/ENG-7"}}}}`))
+	}))
+	defer server.Close()
+
+	tool, err := NewCreateTicketTool(TicketConfig{Provider: "linear", Endpoint: server.URL, Token: "lin_api_secret", Project: "team-id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tool.Execute(context.Background(), []byte(`{"title":"Remove the TODO","description":"main.go:12"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Created ENG-7: https://linear.app/my-org/issue/ENG-7" {
+		t.Errorf("unexpected result %q", result)
+	}
+	if got.Variables.Input["teamId"] != "team-id" || got.Variables.Input["title"] != "Remove the TODO" {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
+
+func TestCreateTicketTool_LinearError(t *testing.T) {
+	// GraphQL errors come back with HTTP 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errors":[{"message":"Team not found"}]}`))
+	}))
+	defer server.Close()
+
+	tool, err := NewCreateTicketTool(TicketConfig{Provider: "linear", Endpoint: server.URL, Token: "lin_api_secret", Project: "team-id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.Execute(context.Background(), []byte(`{"title":"t","description":"d"}`)); err == nil || !strings.Contains(err.Error(), "Team not found") {
+		t.Errorf("expected the GraphQL error, got %v", err)
+	}
+}
+
+func TestCreateTicketTool_RejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	tool, err := NewCreateTicketTool(TicketConfig{Provider: "jira", Endpoint: server.URL, Token: "expired", Project: "OPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tool.Execute(context.Background(), []byte(`{"title":"t","description":"d"}`))
+	var toolErr *Error
+	if !errors.As(err, &toolErr) || toolErr.Recoverable() {
+		t.Errorf("expected a permission error the model won't retry, got %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+)
+
+// DateMathTool is a read-only built-in tool for calendar arithmetic: the
+// current date and time, adding to a date, the time between two dates, and
+// facts about a date such as its weekday.
+type DateMathTool struct {
+	// now is the clock, swapped out in tests.
+	now func() time.Time
+}
+
+// NewDateMathTool creates a DateMathTool.
+func NewDateMathTool() *DateMathTool {
+	return &DateMathTool{now: time.Now}
+}
+
+func (t *DateMathTool) Name() string {
+	return "date_math"
+}
+
+func (t *DateMathTool) Description() string {
+	return "Do date and time arithmetic exactly. Use this instead of working out dates yourself. Operations: " +
+		"\"now\" gives the current date and time; " +
+		"\"add\" adds years, months, weeks, days, hours, minutes and seconds to a date (negative amounts subtract; " +
+		"adding a month to Jan 31 gives the last day of February); " +
+		"\"diff\" gives the time from date to end_date in days, weeks, calendar months and business days; " +
+		"\"info\" gives a date's weekday, day of year, ISO week and whether its year is a leap year. " +
+		"Dates are YYYY-MM-DD, YYYY-MM-DD HH:MM[:SS] or RFC 3339; \"now\" and \"today\" also work."
+}
+
+func (t *DateMathTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"operation": map[string]interface{}{
+				"type": "string",
+				"enum": []string{"now", "add", "diff", "info"},
+			},
+			"date": map[string]interface{}{
+				"type":        "string",
+				"description": "The date to work from; defaults to now.",
+			},
+			"end_date": map[string]interface{}{
+				"type":        "string",
+				"description": "For diff, the date to measure to.",
+			},
+			"years":   map[string]interface{}{"type": "integer"},
+			"months":  map[string]interface{}{"type": "integer"},
+			"weeks":   map[string]interface{}{"type": "integer"},
+			"days":    map[string]interface{}{"type": "integer"},
+			"hours":   map[string]interface{}{"type": "integer"},
+			"minutes": map[string]interface{}{"type": "integer"},
+			"seconds": map[string]interface{}{"type": "integer"},
+			"timezone": map[string]interface{}{
+				"type":        "string",
+				"description": "IANA time zone such as \"Europe/London\" for dates without an offset, and for now; defaults to the user's local zone.",
+			},
+		},
+		"required": []string{"operation"},
+	})
+}
+
+type dateMathInput struct {
+	Operation string `json:"operation"`
+	Date      string `json:"date"`
+	EndDate   string `json:"end_date"`
+	Years     int    `json:"years"`
+	Months    int    `json:"months"`
+	Weeks     int    `json:"weeks"`
+	Days      int    `json:"days"`
+	Hours     int    `json:"hours"`
+	Minutes   int    `json:"minutes"`
+	Seconds   int    `json:"seconds"`
+	Timezone  string `json:"timezone"`
+}
+
+// minYear and maxYear bound the dates add gives.
+const (
+	minYear = 1
+	maxYear = 9999
+)
+
+// amounts totals what add moves a date by: calendar months, days, and
+// clock time. It rejects any amount too big to land between minYear and
+// maxYear, before adding them up can overflow.
+func (p dateMathInput) amounts() (months, days int, clock time.Duration, err error) {
+	maxSeconds := int64(math.MaxInt64 / time.Second)
+	for _, f := range []struct {
+		name  string
+		value int
+		limit int64
+	}{
+		{"years", p.Years, maxYear},
+		{"months", p.Months, maxYear * 12},
+		{"weeks", p.Weeks, maxYear * 53},
+		{"days", p.Days, maxYear * 366},
+		{"hours", p.Hours, maxSeconds / 3600},
+		{"minutes", p.Minutes, maxSeconds / 60},
+		{"seconds", p.Seconds, maxSeconds},
+	} {
+		if int64(f.value) > f.limit || int64(f.value) < -f.limit {
+			return 0, 0, 0, toolErrorf(ErrorKindInvalidInput, "%s must be between -%d and %d", f.name, f.limit, f.limit)
+		}
+	}
+
+	seconds := int64(p.Hours)*3600 + int64(p.Minutes)*60 + int64(p.Seconds)
+	if seconds > maxSeconds || seconds < -maxSeconds {
+		return 0, 0, 0, toolErrorf(ErrorKindInvalidInput, "hours, minutes and seconds add up to more than %d seconds", maxSeconds)
+	}
+	return p.Years*12 + p.Months, p.Weeks*7 + p.Days, time.Duration(seconds) * time.Second, nil
+}
+
+func (t *DateMathTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
+	var params dateMathInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+	}
+
+	loc := time.Local
+	if params.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(params.Timezone); err != nil {
+			return "", toolErrorf(ErrorKindInvalidInput, "unknown time zone %q", params.Timezone)
+		}
+	}
+	now := t.now().In(loc)
+
+	switch params.Operation {
+	case "now":
+		return fmt.Sprintf("%s (%s)\ntime zone: %s", now.Format(time.RFC3339), now.Weekday(), zoneName(now)), nil
+
+	case "add":
+		start, dateOnly, err := parseDate(params.Date, now, loc)
+		if err != nil {
+			return "", err
+		}
+		months, days, clock, err := params.amounts()
+		if err != nil {
+			return "", err
+		}
+		result := addMonths(start, months).AddDate(0, 0, days).Add(clock)
+		if result.Year() < minYear || result.Year() > maxYear {
+			return "", toolErrorf(ErrorKindInvalidInput, "the result is outside the years %d to %d", minYear, maxYear)
+		}
+		return describeDate(result, dateOnly && clock == 0), nil
+
+	case "diff":
+		if params.EndDate == "" {
+			return "", toolErrorf(ErrorKindInvalidInput, "diff needs an end_date")
+		}
+		start, startDateOnly, err := parseDate(params.Date, now, loc)
+		if err != nil {
+			return "", err
+		}
+		end, endDateOnly, err := parseDate(params.EndDate, now, loc)
+		if err != nil {
+			return "", err
+		}
+		return describeSpan(start, end, startDateOnly && endDateOnly), nil
+
+	case "info":
+		date, _, err := parseDate(params.Date, now, loc)
+		if err != nil {
+			return "", err
+		}
+		year, week := date.ISOWeek()
+		leap := "no"
+		if daysIn(date.Year(), time.February) == 29 {
+			leap = "yes"
+		}
+		return fmt.Sprintf("%s is a %s\nday of year: %d\nISO week: %d-W%02d\nquarter: Q%d\ndays in month: %d\nleap year: %s",
+			date.Format("2006-01-02"), date.Weekday(), date.YearDay(), year, week,
+			(int(date.Month())-1)/3+1, daysIn(date.Year(), date.Month()), leap), nil
+
+	case "":
+		return "", toolErrorf(ErrorKindInvalidInput, "operation is required: now, add, diff or info")
+	}
+	return "", toolErrorf(ErrorKindInvalidInput, "unknown operation %q: use now, add, diff or info", params.Operation)
+}
+
+func (t *DateMathTool) RequiresConfirmation() bool {
+	return false
+}
+
+func (t *DateMathTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// Uncacheable reports that date_math can't be cached: "now" changes.
+func (t *DateMathTool) Uncacheable() bool {
+	return true
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseDate reads a date in one of the formats the tool describes, in loc
+// unless it carries its own offset. dateOnly reports a date without a time,
+// which results echo back without one.
+func parseDate(value string, now time.Time, loc *time.Location) (date time.Time, dateOnly bool, err error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", "now":
+		return now, false, nil
+	case "today":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), true, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return date, true, nil
+	}
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return date, false, nil
+		}
+	}
+	return time.Time{}, false, toolErrorf(ErrorKindInvalidInput, "can't read date %q: use YYYY-MM-DD, YYYY-MM-DD HH:MM or RFC 3339", value)
+}
+
+// addMonths adds n months to t, keeping the day of the month but clamping
+// it to the end of a shorter month instead of overflowing, as time.AddDate
+// does (Jan 31 plus a month is Feb 28 or 29, not Mar 2 or 3).
+func addMonths(t time.Time, n int) time.Time {
+	if n == 0 {
+		return t
+	}
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, n, 0)
+	day := min(t.Day(), daysIn(first.Year(), first.Month()))
+	return first.AddDate(0, 0, day-1)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func describeDate(t time.Time, dateOnly bool) string {
+	if dateOnly {
+		return fmt.Sprintf("%s (%s)", t.Format("2006-01-02"), t.Weekday())
+	}
+	return fmt.Sprintf("%s (%s)", t.Format(time.RFC3339), t.Weekday())
+}
+
+// describeSpan gives the time from start to end several ways. A span that
+// runs backwards is described forwards, with its days negative.
+func describeSpan(start, end time.Time, dateOnly bool) string {
+	sign := ""
+	if end.Before(start) {
+		start, end, sign = end, start, "-"
+	}
+
+	// calendar days, so a span across a DST change still counts whole days
+	days := calendarDays(start, end)
+	var b strings.Builder
+	fmt.Fprintf(&b, "days: %s%d", sign, days)
+	if days >= 7 {
+		fmt.Fprintf(&b, " (%s, %s)", plural(days/7, "week"), plural(days%7, "day"))
+	}
+
+	months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+	if addMonths(start, months).After(end) {
+		months--
+	}
+	rest := calendarDays(addMonths(start, months), end)
+	fmt.Fprintf(&b, "\ncalendar: %s%s", sign, calendarSpan(months/12, months%12, rest))
+
+	fmt.Fprintf(&b, "\nbusiness days (Mon-Fri, start inclusive, end exclusive): %s%d", sign, businessDays(start, end))
+	if !dateOnly {
+		fmt.Fprintf(&b, "\nexact: %s%s", sign, end.Sub(start))
+	}
+	return b.String()
+}
+
+// calendarDays counts midnights crossed from start to end, less one if end
+// is earlier in its day than start.
+func calendarDays(start, end time.Time) int {
+	startDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	endDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(endDay.Sub(startDay).Hours() / 24)
+	if days > 0 && clockOf(end) < clockOf(start) {
+		days--
+	}
+	return days
+}
+
+func clockOf(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// businessDays counts weekdays from start's date up to but not including
+// end's.
+func businessDays(start, end time.Time) int {
+	total := calendarDays(start, end)
+	count := total / 7 * 5
+	day := start.Weekday()
+	for i := 0; i < total%7; i++ {
+		if day != time.Saturday && day != time.Sunday {
+			count++
+		}
+		day = (day + 1) % 7
+	}
+	return count
+}
+
+// calendarSpan writes a span such as "1 year, 2 months, 3 days", leaving
+// out units that are zero.
+func calendarSpan(years, months, days int) string {
+	var parts []string
+	for _, unit := range []struct {
+		n    int
+		name string
+	}{{years, "year"}, {months, "month"}, {days, "day"}} {
+		if unit.n != 0 {
+			parts = append(parts, plural(unit.n, unit.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "0 days"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, name string) string {
+	if n == 1 {
+		return "1 " + name
+	}
+	return fmt.Sprintf("%d %ss", n, name)
+}
+
+// zoneName names t's zone by location when it has a useful one, falling
+// back to its abbreviation.
+func zoneName(t time.Time) string {
+	abbrev, _ := t.Zone()
+	if name := t.Location().String(); name != "" && name != "Local" && name != abbrev {
+		return fmt.Sprintf("%s (%s)", name, abbrev)
+	}
+	return abbrev
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDateMathTool() *DateMathTool {
+	tool := NewDateMathTool()
+	tool.now = func() time.Time { return time.Date(2024, time.February, 29, 15, 4, 5, 0, time.UTC) }
+	return tool
+}
+
+func dateMath(t *testing.T, input map[string]interface{}) (string, error) {
+	t.Helper()
+	if _, ok := input["timezone"]; !ok {
+		input["timezone"] = "UTC"
+	}
+	raw, _ := json.Marshal(input)
+	return newTestDateMathTool().Execute(context.Background(), raw)
+}
+
+func TestDateMathTool_Execute(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  string
+	}{
+		{
+			name:  "now",
+			input: map[string]interface{}{"operation": "now"},
+			want:  "2024-02-29T15:04:05Z (Thursday)\ntime zone: UTC",
+		},
+		{
+			name:  "add days",
+			input: map[string]interface{}{"operation": "add", "date": "2024-12-20", "days": 14},
+			want:  "2025-01-03 (Friday)",
+		},
+		{
+			name:  "add a month clamps to the end of the month",
+			input: map[string]interface{}{"operation": "add", "date": "2023-01-31", "months": 1},
+			want:  "2023-02-28 (Tuesday)",
+		},
+		{
+			name:  "add a year to a leap day",
+			input: map[string]interface{}{"operation": "add", "date": "today", "years": 1},
+			want:  "2025-02-28 (Friday)",
+		},
+		{
+			name:  "subtract with negative amounts",
+			input: map[string]interface{}{"operation": "add", "date": "2024-03-01", "weeks": -1, "days": -1},
+			want:  "2024-02-22 (Thursday)",
+		},
+		{
+			name:  "add hours keeps the time",
+			input: map[string]interface{}{"operation": "add", "date": "2024-03-01 22:30", "hours": 3},
+			want:  "2024-03-02T01:30:00Z (Saturday)",
+		},
+		{
+			name:  "diff",
+			input: map[string]interface{}{"operation": "diff", "date": "2024-01-15", "end_date": "2024-03-20"},
+			want: "days: 65 (9 weeks, 2 days)\ncalendar: 2 months, 5 days\n" +
+				"business days (Mon-Fri, start inclusive, end exclusive): 47",
+		},
+		{
+			name:  "diff backwards",
+			input: map[string]interface{}{"operation": "diff", "date": "2025-01-01", "end_date": "2024-01-01"},
+			want: "days: -366 (52 weeks, 2 days)\ncalendar: -1 year\n" +
+				"business days (Mon-Fri, start inclusive, end exclusive): -262",
+		},
+		{
+			name:  "diff with times",
+			input: map[string]interface{}{"operation": "diff", "date": "2024-02-28T18:00:00Z", "end_date": "now"},
+			want: "days: 0\ncalendar: 0 days\n" +
+				"business days (Mon-Fri, start inclusive, end exclusive): 0\nexact: 21h4m5s",
+		},
+		{
+			name:  "info",
+			input: map[string]interface{}{"operation": "info", "date": "2024-12-30"},
+			want:  "2024-12-30 is a Monday\nday of year: 365\nISO week: 2025-W01\nquarter: Q4\ndays in month: 31\nleap year: yes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dateMath(t, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateMathTool_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  string
+	}{
+		{"no operation", map[string]interface{}{}, "operation is required"},
+		{"unknown operation", map[string]interface{}{"operation": "multiply"}, "unknown operation"},
+		{"bad date", map[string]interface{}{"operation": "info", "date": "next tuesday"}, "can't read date"},
+		{"diff without end", map[string]interface{}{"operation": "diff", "date": "2024-01-01"}, "needs an end_date"},
+		{"bad zone", map[string]interface{}{"operation": "now", "timezone": "Mars/Olympus_Mons"}, "unknown time zone"},
+		{"huge days", map[string]interface{}{"operation": "add", "date": "2024-01-01", "days": int64(math.MaxInt64)}, "days must be between"},
+		{"huge years", map[string]interface{}{"operation": "add", "date": "2024-01-01", "years": int64(-1 << 40)}, "years must be between"},
+		{"huge hours", map[string]interface{}{"operation": "add", "date": "2024-01-01", "hours": int64(1 << 40)}, "hours must be between"},
+		{"huge seconds", map[string]interface{}{"operation": "add", "date": "2024-01-01", "seconds": int64(math.MaxInt64)}, "seconds must be between"},
+		{"clock overflows", map[string]interface{}{"operation": "add", "date": "2024-01-01", "hours": 2000000, "minutes": 100000000}, "add up to more than"},
+		{"past year 9999", map[string]interface{}{"operation": "add", "date": "2024-01-01", "years": 8000}, "outside the years 1 to 9999"},
+		{"before year 1", map[string]interface{}{"operation": "add", "date": "2024-01-01", "days": -800000}, "outside the years 1 to 9999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dateMath(t, tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+			if kind := ErrorKindOf(err); kind != ErrorKindInvalidInput {
+				t.Errorf("expected %s, got %s", ErrorKindInvalidInput, kind)
+			}
+		})
+	}
+}
+
+func TestDateMathTool_Uncacheable(t *testing.T) {
+	if cacheable(NewDateMathTool()) {
+		t.Error("expected date_math results not to be cached, since now changes")
+	}
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrorKind classifies why a tool call failed, so the model can tell a
+// mistake it can correct (a wrong path, an oversized read) from one it
+// shouldn't retry (a denied action), and so failures render consistently.
+type ErrorKind string
+
+const (
+	ErrorKindInvalidInput ErrorKind = "invalid_input"
+	ErrorKindNotFound     ErrorKind = "not_found"
+	ErrorKindPermission   ErrorKind = "permission_denied"
+	ErrorKindTooLarge     ErrorKind = "too_large"
+	ErrorKindUnsupported  ErrorKind = "unsupported"
+	ErrorKindTimeout      ErrorKind = "timeout"
+)
+
+// errorKindHints tells the model what to do next for each kind of failure.
+var errorKindHints = map[ErrorKind]string{
+	ErrorKindInvalidInput: "Fix the input to match the tool's schema and try again.",
+	ErrorKindNotFound:     "Check the path (list_files can help) and try again.",
+	ErrorKindPermission:   "This is not allowed; do not retry the same request.",
+	ErrorKindTooLarge:     "Request a smaller portion, e.g. with offset and limit.",
+	ErrorKindUnsupported:  "This tool can't handle that input; try a different approach.",
+	ErrorKindTimeout:      "The operation took too long; try a narrower request.",
+}
+
+// Error is a classified tool failure. Tools return it (via toolErrorf) for
+// failures they understand; Dispatch classifies any other error it can
+// recognize before reporting it to the model.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+// toolErrorf builds an *Error of the given kind. format supports %w.
+func toolErrorf(kind ErrorKind, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Recoverable reports whether the model can reasonably succeed by retrying
+// with different input. Only permission failures are final.
+func (e *Error) Recoverable() bool {
+	return e.Kind != ErrorKindPermission
+}
+
+// ErrorKindOf classifies err: the Kind of an *Error in its chain, or a kind
+// inferred from well-known filesystem and context errors. Returns "" when
+// err can't be classified.
+func ErrorKindOf(err error) ErrorKind {
+	var toolErr *Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &toolErr):
+		return toolErr.Kind
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorKindNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorKindPermission
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorKindTimeout
+	default:
+		return ""
+	}
+}
+
+// formatToolError renders err as tool-result text: "[kind] message" plus a
+// hint for classified errors, or the bare message otherwise.
+func formatToolError(err error) string {
+	kind := ErrorKindOf(err)
+	if kind == "" {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("[%s] %s\n%s", kind, err.Error(), errorKindHints[kind])
+}
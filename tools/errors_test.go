@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestErrorKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, ""},
+		{"typed error", toolErrorf(ErrorKindTooLarge, "too big"), ErrorKindTooLarge},
+		{"wrapped typed error", fmt.Errorf("outer: %w", toolErrorf(ErrorKindTimeout, "slow")), ErrorKindTimeout},
+		{"missing file", fmt.Errorf("unable to read file: %w", fs.ErrNotExist), ErrorKindNotFound},
+		{"permission", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}, ErrorKindPermission},
+		{"deadline", context.DeadlineExceeded, ErrorKindTimeout},
+		{"unclassified", errors.New("something odd"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorKindOf(tt.err); got != tt.want {
+				t.Errorf("ErrorKindOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_Recoverable(t *testing.T) {
+	if toolErrorf(ErrorKindPermission, "denied").Recoverable() {
+		t.Error("expected permission errors to be unrecoverable")
+	}
+	if !toolErrorf(ErrorKindNotFound, "missing").Recoverable() {
+		t.Error("expected not-found errors to be recoverable")
+	}
+}
+
+func TestFormatToolError(t *testing.T) {
+	t.Run("classified error gets kind and hint", func(t *testing.T) {
+		got := formatToolError(toolErrorf(ErrorKindTooLarge, "file is 9 MB"))
+		want := "[too_large] file is 9 MB\n" + errorKindHints[ErrorKindTooLarge]
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("unclassified error is passed through", func(t *testing.T) {
+		if got := formatToolError(errors.New("plain failure")); got != "plain failure" {
+			t.Errorf("expected bare message, got %q", got)
+		}
+	})
+}
+
+func TestTools_ReturnClassifiedErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(originalWd); err != nil {
+			t.Errorf("failed to change back to original directory: %v", err)
+		}
+	}()
+
+	tool := NewReadFileTool()
+
+	tests := []struct {
+		name  string
+		input string
+		want  ErrorKind
+	}{
+		{"missing file", `{"path":"nope.txt"}`, ErrorKindNotFound},
+		{"outside working directory", `{"path":"../../etc/passwd"}`, ErrorKindPermission},
+		{"malformed input", `not json`, ErrorKindInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tool.Execute(context.Background(), []byte(tt.input))
+			if got := ErrorKindOf(err); got != tt.want {
+				t.Errorf("expected %q, got %q (%v)", tt.want, got, err)
+			}
+		})
+	}
+
+	t.Run("dispatch renders the kind for the model", func(t *testing.T) {
+		r := NewRegistry()
+		r.Register(tool)
+		result := r.Dispatch(context.Background(), ToolCall{
+			Name:      "read_file",
+			ToolUseID: "call-1",
+			Input:     []byte(`{"path":"nope.txt"}`),
+		}, nil)
+		if text := resultText(t, result); !strings.HasPrefix(text, "[not_found] file does not exist: nope.txt") {
+			t.Errorf("unexpected result text %q", text)
+		}
+	})
+}
@@ -50,7 +50,7 @@ func (t *GitDiffTool) Execute(ctx context.Context, input json.RawMessage) (strin
 	// error (malformed JSON) is treated as invalid input.
 	if len(input) > 0 {
 		if err := json.Unmarshal(input, &params); err != nil {
-			return "", fmt.Errorf("invalid tool input: %w", err)
+			return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
 		}
 	}
 
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// ChatCliIgnoreFile names a project-level ignore file, in .gitignore
+// format, listing paths tools must never read or list - for things that
+// aren't gitignored but still shouldn't reach a model (fixtures containing
+// secrets, vendored code, large data directories).
+const ChatCliIgnoreFile = ".chatcliignore"
+
+// loadIgnoreMatcher returns the matcher tools use to hide paths under the
+// working directory, combining its .gitignore and .chatcliignore. It's
+// reloaded on each call so edits to either file apply without restarting
+// the session.
+func loadIgnoreMatcher() (*utils.IgnoreMatcher, string, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to get working directory: %w", err)
+	}
+
+	return utils.LoadIgnoreMatcher(root, ".gitignore", ChatCliIgnoreFile), root, nil
+}
+
+// checkNotIgnored returns an error if fullPath, an absolute path already
+// confined to the working directory, is excluded by .gitignore or
+// .chatcliignore.
+func checkNotIgnored(fullPath string, isDir bool) error {
+	ignore, root, err := loadIgnoreMatcher()
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == "." {
+		return nil
+	}
+
+	if ignore.Ignored(rel, isDir) {
+		return toolErrorf(ErrorKindPermission, "access denied: %s is excluded by .gitignore or %s", filepath.ToSlash(rel), ChatCliIgnoreFile)
+	}
+
+	return nil
+}
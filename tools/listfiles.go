@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/chat-cli/chat-cli/utils"
+)
+
+// maxListFilesEntries caps how many paths a single list_files call
+// returns, so listing a large tree can't flood the context window.
+const maxListFilesEntries = 500
+
+// ListFilesTool is a read-only, working-directory-confined recursive file
+// lister. Paths excluded by .gitignore or .chatcliignore are never listed.
+type ListFilesTool struct{}
+
+// NewListFilesTool creates a ListFilesTool.
+func NewListFilesTool() *ListFilesTool {
+	return &ListFilesTool{}
+}
+
+func (t *ListFilesTool) Name() string {
+	return "list_files"
+}
+
+func (t *ListFilesTool) Description() string {
+	return "Recursively list files under a directory within the current working directory, one path per line. Directories end in '/'."
+}
+
+func (t *ListFilesTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Directory to list, relative to the current working directory. Defaults to the working directory itself.",
+			},
+		},
+	})
+}
+
+type listFilesInput struct {
+	Path string `json:"path"`
+}
+
+func (t *ListFilesTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
+	var params listFilesInput
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &params); err != nil {
+			return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+		}
+	}
+	if params.Path == "" {
+		params.Path = "."
+	}
+
+	fullPath, err := utils.ValidateLocalPath(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to list directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", toolErrorf(ErrorKindInvalidInput, "not a directory: %s", params.Path)
+	}
+
+	if err := checkNotIgnored(fullPath, true); err != nil {
+		return "", err
+	}
+
+	ignore, root, err := loadIgnoreMatcher()
+	if err != nil {
+		return "", err
+	}
+
+	var entries []string
+	truncated := false
+
+	walkErr := filepath.WalkDir(fullPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != fullPath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if path == fullPath {
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return nil
+		}
+
+		if ignore.Ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if len(entries) >= maxListFilesEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		entry := filepath.ToSlash(rel)
+		if d.IsDir() {
+			entry += "/"
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if walkErr != nil {
+		return "", fmt.Errorf("unable to list directory: %w", walkErr)
+	}
+
+	if len(entries) == 0 {
+		return "(no files)", nil
+	}
+
+	result := strings.Join(entries, "\n")
+	if truncated {
+		result += fmt.Sprintf("\n... (listing stopped after %d entries; list a subdirectory to see more)", maxListFilesEntries)
+	}
+
+	return result, nil
+}
+
+func (t *ListFilesTool) RequiresConfirmation() bool {
+	return false
+}
+
+func (t *ListFilesTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
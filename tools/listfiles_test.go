@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListFilesTool_Execute(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(originalWd); err != nil {
+			t.Errorf("failed to change back to original directory: %v", err)
+		}
+	}()
+
+	for name, content := range map[string]string{
+		".gitignore":             "node_modules/\n*.log\n",
+		ChatCliIgnoreFile:        "secrets/\n",
+		"main.go":                "package main\n",
+		"src/app.go":             "package src\n",
+		"node_modules/dep/x.js":  "x\n",
+		"secrets/api-key.txt":    "hunter2\n",
+		"debug.log":              "noise\n",
+		"src/nested/deep/one.go": "package deep\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := NewListFilesTool()
+
+	t.Run("lists the working directory, skipping ignored paths", func(t *testing.T) {
+		result, err := tool.Execute(context.Background(), []byte(`{}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, want := range []string{"main.go", "src/", "src/app.go", "src/nested/deep/one.go", ".gitignore"} {
+			if !strings.Contains(result, want) {
+				t.Errorf("expected listing to contain %q, got:\n%s", want, result)
+			}
+		}
+		for _, unwanted := range []string{"node_modules", "secrets", "debug.log"} {
+			if strings.Contains(result, unwanted) {
+				t.Errorf("expected listing to exclude %q, got:\n%s", unwanted, result)
+			}
+		}
+	})
+
+	t.Run("lists a subdirectory", func(t *testing.T) {
+		result, err := tool.Execute(context.Background(), []byte(`{"path":"src"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(result, "main.go") {
+			t.Errorf("expected only src entries, got:\n%s", result)
+		}
+		if !strings.Contains(result, "src/app.go") {
+			t.Errorf("expected src/app.go, got:\n%s", result)
+		}
+	})
+
+	t.Run("ignored directory", func(t *testing.T) {
+		if _, err := tool.Execute(context.Background(), []byte(`{"path":"secrets"}`)); err == nil {
+			t.Error("expected an error listing an ignored directory, got none")
+		}
+	})
+
+	t.Run("path escaping working directory", func(t *testing.T) {
+		if _, err := tool.Execute(context.Background(), []byte(`{"path":"../.."}`)); err == nil {
+			t.Error("expected an error for a path outside the working directory, got none")
+		}
+	})
+
+	t.Run("path is a file", func(t *testing.T) {
+		if _, err := tool.Execute(context.Background(), []byte(`{"path":"main.go"}`)); err == nil {
+			t.Error("expected an error listing a regular file, got none")
+		}
+	})
+}
+
+func TestListFilesTool_RequiresConfirmation(t *testing.T) {
+	tool := NewListFilesTool()
+	if tool.RequiresConfirmation() {
+		t.Error("expected list_files to not require confirmation - it's read-only")
+	}
+}
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	uuid "github.com/satori/go.uuid"
+)
+
+// maxRandomCount bounds how many values one random call returns.
+const maxRandomCount = 100
+
+// RandomTool is a read-only built-in tool that generates UUIDs, random
+// integers, and random picks from a list, using crypto/rand so a model
+// doesn't make up "random" values that aren't.
+type RandomTool struct{}
+
+// NewRandomTool creates a RandomTool.
+func NewRandomTool() *RandomTool {
+	return &RandomTool{}
+}
+
+func (t *RandomTool) Name() string {
+	return "random"
+}
+
+func (t *RandomTool) Description() string {
+	return "Generate random values instead of inventing them: \"uuid\" gives version 4 UUIDs, " +
+		"\"integer\" gives whole numbers from min to max inclusive, \"choice\" picks from choices, " +
+		"and \"shuffle\" returns choices in a random order. count asks for several values at once (up to 100)."
+}
+
+func (t *RandomTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"kind": map[string]interface{}{
+				"type": "string",
+				"enum": []string{"uuid", "integer", "choice", "shuffle"},
+			},
+			"min": map[string]interface{}{
+				"type":        "integer",
+				"description": "For integer, the smallest value; defaults to 1.",
+			},
+			"max": map[string]interface{}{
+				"type":        "integer",
+				"description": "For integer, the largest value.",
+			},
+			"choices": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "For choice and shuffle, the items to pick from.",
+			},
+			"count": map[string]interface{}{
+				"type":        "integer",
+				"description": "How many values to generate; defaults to 1.",
+			},
+		},
+		"required": []string{"kind"},
+	})
+}
+
+type randomInput struct {
+	Kind    string   `json:"kind"`
+	Min     *int64   `json:"min"`
+	Max     *int64   `json:"max"`
+	Choices []string `json:"choices"`
+	Count   int      `json:"count"`
+}
+
+func (t *RandomTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
+	var params randomInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+	}
+	count := params.Count
+	if count == 0 {
+		count = 1
+	}
+	if count < 1 || count > maxRandomCount {
+		return "", toolErrorf(ErrorKindInvalidInput, "count must be from 1 to %d", maxRandomCount)
+	}
+
+	var values []string
+	switch params.Kind {
+	case "uuid":
+		for i := 0; i < count; i++ {
+			values = append(values, uuid.NewV4().String())
+		}
+
+	case "integer":
+		if params.Max == nil {
+			return "", toolErrorf(ErrorKindInvalidInput, "integer needs a max")
+		}
+		low := int64(1)
+		if params.Min != nil {
+			low = *params.Min
+		}
+		if *params.Max < low {
+			return "", toolErrorf(ErrorKindInvalidInput, "max (%d) is less than min (%d)", *params.Max, low)
+		}
+		span := new(big.Int).Sub(big.NewInt(*params.Max), big.NewInt(low))
+		span.Add(span, big.NewInt(1))
+		for i := 0; i < count; i++ {
+			n, err := rand.Int(rand.Reader, span)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, n.Add(n, big.NewInt(low)).String())
+		}
+
+	case "choice":
+		if len(params.Choices) == 0 {
+			return "", toolErrorf(ErrorKindInvalidInput, "choice needs a list of choices")
+		}
+		for i := 0; i < count; i++ {
+			n, err := randomIndex(len(params.Choices))
+			if err != nil {
+				return "", err
+			}
+			values = append(values, params.Choices[n])
+		}
+
+	case "shuffle":
+		if len(params.Choices) == 0 {
+			return "", toolErrorf(ErrorKindInvalidInput, "shuffle needs a list of choices")
+		}
+		values = append(values, params.Choices...)
+		// Fisher-Yates
+		for i := len(values) - 1; i > 0; i-- {
+			j, err := randomIndex(i + 1)
+			if err != nil {
+				return "", err
+			}
+			values[i], values[j] = values[j], values[i]
+		}
+
+	case "":
+		return "", toolErrorf(ErrorKindInvalidInput, "kind is required: uuid, integer, choice or shuffle")
+	default:
+		return "", toolErrorf(ErrorKindInvalidInput, "unknown kind %q: use uuid, integer, choice or shuffle", params.Kind)
+	}
+	return strings.Join(values, "\n"), nil
+}
+
+func (t *RandomTool) RequiresConfirmation() bool {
+	return false
+}
+
+func (t *RandomTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// Uncacheable reports that random results must never be reused: asking
+// twice should give two different answers.
+func (t *RandomTool) Uncacheable() bool {
+	return true
+}
+
+// randomIndex returns a uniformly random int in [0, n).
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func random(t *testing.T, input map[string]interface{}) (string, error) {
+	t.Helper()
+	raw, _ := json.Marshal(input)
+	return NewRandomTool().Execute(context.Background(), raw)
+}
+
+func TestRandomTool_Execute(t *testing.T) {
+	t.Run("uuids", func(t *testing.T) {
+		got, err := random(t, map[string]interface{}{"kind": "uuid", "count": 3})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lines := strings.Split(got, "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 UUIDs, got %q", got)
+		}
+		for _, line := range lines {
+			id, err := uuid.FromString(line)
+			if err != nil || id.Version() != uuid.V4 {
+				t.Errorf("expected a version 4 UUID, got %q", line)
+			}
+		}
+		if lines[0] == lines[1] {
+			t.Error("expected distinct UUIDs")
+		}
+	})
+
+	t.Run("integers stay in range", func(t *testing.T) {
+		got, err := random(t, map[string]interface{}{"kind": "integer", "min": -2, "max": 2, "count": 100})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, line := range strings.Split(got, "\n") {
+			n, err := strconv.Atoi(line)
+			if err != nil || n < -2 || n > 2 {
+				t.Errorf("expected an integer from -2 to 2, got %q", line)
+			}
+		}
+	})
+
+	t.Run("integer min defaults to 1", func(t *testing.T) {
+		got, err := random(t, map[string]interface{}{"kind": "integer", "max": 1})
+		if err != nil || got != "1" {
+			t.Errorf("expected 1, got %q (%v)", got, err)
+		}
+	})
+
+	t.Run("choice", func(t *testing.T) {
+		choices := []string{"red", "green", "blue"}
+		got, err := random(t, map[string]interface{}{"kind": "choice", "choices": choices})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Contains(choices, got) {
+			t.Errorf("expected one of %v, got %q", choices, got)
+		}
+	})
+
+	t.Run("shuffle keeps every item", func(t *testing.T) {
+		choices := []string{"a", "b", "c", "d", "e"}
+		got, err := random(t, map[string]interface{}{"kind": "shuffle", "choices": choices})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		shuffled := strings.Split(got, "\n")
+		slices.Sort(shuffled)
+		if !slices.Equal(shuffled, choices) {
+			t.Errorf("expected a permutation of %v, got %q", choices, got)
+		}
+	})
+}
+
+func TestRandomTool_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  string
+	}{
+		{"no kind", map[string]interface{}{}, "kind is required"},
+		{"unknown kind", map[string]interface{}{"kind": "dice"}, "unknown kind"},
+		{"integer without max", map[string]interface{}{"kind": "integer"}, "needs a max"},
+		{"max below min", map[string]interface{}{"kind": "integer", "min": 5, "max": 1}, "less than min"},
+		{"choice without choices", map[string]interface{}{"kind": "choice"}, "needs a list"},
+		{"count too high", map[string]interface{}{"kind": "uuid", "count": 1000}, "count must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := random(t, tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRandomTool_Uncacheable(t *testing.T) {
+	if cacheable(NewRandomTool()) {
+		t.Error("expected random results never to be cached")
+	}
+}
@@ -1,17 +1,33 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/chat-cli/chat-cli/utils"
 )
 
-// ReadFileTool is the one built-in tool shipped with tool-use support: a
-// read-only, working-directory-confined file reader.
+// maxReadFileBytes caps how much of a file a single read_file call
+// returns. Larger files are returned as a head and tail around a
+// truncation marker; the model can page through the rest with offset and
+// limit.
+const maxReadFileBytes = 64 * 1024
+
+// binarySniffLen is how much of a file is inspected for NUL bytes when
+// deciding whether it's binary, matching git's heuristic.
+const binarySniffLen = 8000
+
+// ReadFileTool is a read-only, working-directory-confined file reader.
+// Files excluded by .gitignore or .chatcliignore can't be read.
 type ReadFileTool struct{}
 
 // NewReadFileTool creates a ReadFileTool.
@@ -24,7 +40,7 @@ func (t *ReadFileTool) Name() string {
 }
 
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a text file within the current working directory."
+	return fmt.Sprintf("Read the contents of a text file within the current working directory. Files over %d KB are truncated to their beginning and end; pass offset and limit to read a specific range of lines. Binary files are refused.", maxReadFileBytes/1024)
 }
 
 func (t *ReadFileTool) InputSchema() document.Interface {
@@ -35,19 +51,29 @@ func (t *ReadFileTool) InputSchema() document.Interface {
 				"type":        "string",
 				"description": "Path to the file, relative to the current working directory.",
 			},
+			"offset": map[string]interface{}{
+				"type":        "integer",
+				"description": "1-based line number to start reading from.",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of lines to return.",
+			},
 		},
 		"required": []interface{}{"path"},
 	})
 }
 
 type readFileInput struct {
-	Path string `json:"path"`
+	Path   string `json:"path"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
 }
 
 func (t *ReadFileTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
 	var params readFileInput
 	if err := json.Unmarshal(input, &params); err != nil {
-		return "", fmt.Errorf("invalid tool input: %w", err)
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
 	}
 
 	fullPath, err := utils.ValidateLocalPath(params.Path)
@@ -55,14 +81,167 @@ func (t *ReadFileTool) Execute(_ context.Context, input json.RawMessage) (string
 		return "", err
 	}
 
-	data, err := os.ReadFile(fullPath) // #nosec G304 - path is validated above
+	if err := checkNotIgnored(fullPath, false); err != nil {
+		return "", err
+	}
+
+	if params.Offset < 0 || params.Limit < 0 {
+		return "", toolErrorf(ErrorKindInvalidInput, "offset and limit must not be negative")
+	}
+
+	f, err := os.Open(fullPath) // #nosec G304 - path is validated above
 	if err != nil {
 		return "", fmt.Errorf("unable to read file: %w", err)
 	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+	if info.IsDir() {
+		return "", toolErrorf(ErrorKindInvalidInput, "%s is a directory; use list_files to see what's in it", params.Path)
+	}
+
+	// one byte past the sniff lets isBinary tell a rune cut off at the end
+	// of the sniff from invalid UTF-8
+	sniff := make([]byte, binarySniffLen+1)
+	n, err := io.ReadFull(f, sniff)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+	if isBinary(sniff[:n]) {
+		return "", toolErrorf(ErrorKindUnsupported, "%s appears to be a binary file (%d bytes); read_file only returns text", params.Path, info.Size())
+	}
+
+	if info.Size() > maxReadFileBytes && params.Offset == 0 && params.Limit == 0 {
+		return truncateHeadTail(f, info.Size(), maxReadFileBytes)
+	}
 
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+	if params.Offset > 0 || params.Limit > 0 {
+		return readLineRange(f, params.Offset, params.Limit)
+	}
+
+	// the size can be wrong (files under /proc report 0), so the read is
+	// capped too
+	data, err := io.ReadAll(io.LimitReader(f, maxReadFileBytes))
+	if err != nil {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
 	return string(data), nil
 }
 
+// isBinary reports whether data looks like a binary file: a NUL byte near
+// the start, or content that isn't valid UTF-8.
+func isBinary(data []byte) bool {
+	sniff := data
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+
+	// a multi-byte rune may be split at the sniff boundary, so allow up to
+	// UTFMax-1 trailing bytes of an incomplete rune when data was cut short
+	valid := utf8.Valid(sniff)
+	for i := 1; !valid && len(sniff) < len(data) && i < utf8.UTFMax; i++ {
+		valid = utf8.Valid(sniff[:len(sniff)-i])
+	}
+
+	return !valid
+}
+
+// readLineRange returns up to limit lines of r starting at the 1-based
+// line offset (0 means from the start, limit 0 means to the end), prefixed
+// with a header locating them. Reading stops once the lines are found or
+// would pass maxReadFileBytes, in which case the header says more follow.
+func readLineRange(r io.Reader, offset, limit int) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReadFileBytes)
+	scanner.Split(scanLinesWithEndings)
+
+	start := max(offset, 1)
+	var body strings.Builder
+	line, end := 0, 0
+	more := false
+	for scanner.Scan() {
+		line++
+		if line < start {
+			continue
+		}
+		if (limit > 0 && line >= start+limit) || (end > 0 && body.Len()+len(scanner.Bytes()) > maxReadFileBytes) {
+			more = true
+			break
+		}
+		body.Write(scanner.Bytes())
+		end = line
+	}
+	if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+		return "", toolErrorf(ErrorKindUnsupported, "line %d is longer than %d KB; read the file without offset and limit instead", line+1, maxReadFileBytes/1024)
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+
+	if end == 0 {
+		return "", toolErrorf(ErrorKindInvalidInput, "offset %d is past the end of the file (%d lines)", offset, line)
+	}
+
+	header := fmt.Sprintf("[lines %d-%d of %d]\n", start, end, line)
+	if more {
+		header = fmt.Sprintf("[lines %d-%d; more follow]\n", start, end)
+	}
+	return header + body.String(), nil
+}
+
+// scanLinesWithEndings is bufio.ScanLines keeping each line's ending, so
+// the lines join back into the file's text.
+func scanLinesWithEndings(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// truncateHeadTail returns the first and last halves of maxBytes of r,
+// which is size bytes long, joined by a marker saying how much was
+// omitted. Only those halves are read. Cuts are moved to line boundaries
+// where possible so the model isn't handed half a line.
+func truncateHeadTail(r io.ReaderAt, size int64, maxBytes int) (string, error) {
+	half := maxBytes / 2
+
+	head := make([]byte, half)
+	if _, err := r.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+	if i := bytes.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	}
+
+	tail := make([]byte, half)
+	n, err := r.ReadAt(tail, size-int64(half))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("unable to read file: %w", err)
+	}
+	tail = tail[:n]
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+
+	omitted := size - int64(len(head)) - int64(len(tail))
+	marker := fmt.Sprintf("\n... [truncated %d bytes after line %d - use offset and limit to read the omitted section] ...\n\n", omitted, bytes.Count(head, []byte("\n")))
+
+	return string(head) + marker + string(tail), nil
+}
+
 func (t *ReadFileTool) RequiresConfirmation() bool {
 	return false
 }
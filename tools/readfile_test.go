@@ -2,7 +2,10 @@ package tools
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -31,6 +34,12 @@ func TestReadFileTool_Execute(t *testing.T) {
 	if err := os.WriteFile("readable.txt", []byte("file contents here"), 0644); err != nil {
 		t.Fatal(err)
 	}
+	if err := os.WriteFile(ChatCliIgnoreFile, []byte("*.secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("api.secret", []byte("hunter2"), 0644); err != nil {
+		t.Fatal(err)
+	}
 
 	tool := NewReadFileTool()
 
@@ -51,6 +60,13 @@ func TestReadFileTool_Execute(t *testing.T) {
 		}
 	})
 
+	t.Run("path excluded by .chatcliignore", func(t *testing.T) {
+		_, err := tool.Execute(context.Background(), []byte(`{"path":"api.secret"}`))
+		if err == nil {
+			t.Error("expected an error for an ignored file, got none")
+		}
+	})
+
 	t.Run("nonexistent path", func(t *testing.T) {
 		_, err := tool.Execute(context.Background(), []byte(`{"path":"does-not-exist.txt"}`))
 		if err == nil {
@@ -72,3 +88,149 @@ func TestReadFileTool_RequiresConfirmation(t *testing.T) {
 		t.Error("expected read_file to not require confirmation - it's read-only")
 	}
 }
+
+func TestReadFileTool_LargeAndBinaryFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(originalWd); err != nil {
+			t.Errorf("failed to change back to original directory: %v", err)
+		}
+	}()
+
+	var big strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&big, "line %d of the big file\n", i)
+	}
+	if err := os.WriteFile("big.txt", []byte(big.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("image.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadFileTool()
+
+	t.Run("large file is truncated to head and tail", func(t *testing.T) {
+		result, err := tool.Execute(context.Background(), []byte(`{"path":"big.txt"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result) > maxReadFileBytes+500 {
+			t.Errorf("expected result near %d bytes, got %d", maxReadFileBytes, len(result))
+		}
+		if !strings.HasPrefix(result, "line 1 of the big file\n") {
+			t.Errorf("expected the head of the file first, got %q", result[:40])
+		}
+		if !strings.HasSuffix(result, "line 5000 of the big file\n") {
+			t.Error("expected the tail of the file last")
+		}
+		head, _, ok := strings.Cut(result, "\n... [truncated ")
+		if !ok {
+			t.Fatal("expected a truncation marker")
+		}
+		if want := fmt.Sprintf("after line %d ", strings.Count(head, "\n")); !strings.Contains(result, want) {
+			t.Errorf("expected the marker to say where the head ends (%q)", want)
+		}
+	})
+
+	t.Run("offset and limit page through lines", func(t *testing.T) {
+		result, err := tool.Execute(context.Background(), []byte(`{"path":"big.txt","offset":2500,"limit":2}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "[lines 2500-2501; more follow]\nline 2500 of the big file\nline 2501 of the big file\n"
+		if result != want {
+			t.Errorf("expected %q, got %q", want, result)
+		}
+	})
+
+	t.Run("a range reaching the end gives the line count", func(t *testing.T) {
+		result, err := tool.Execute(context.Background(), []byte(`{"path":"big.txt","offset":4999}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "[lines 4999-5000 of 5000]\nline 4999 of the big file\nline 5000 of the big file\n"
+		if result != want {
+			t.Errorf("expected %q, got %q", want, result)
+		}
+	})
+
+	t.Run("a range stops at the size cap", func(t *testing.T) {
+		result, err := tool.Execute(context.Background(), []byte(`{"path":"big.txt","offset":1}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result) > maxReadFileBytes+100 || !strings.HasPrefix(result, "[lines 1-") || !strings.Contains(result, "; more follow]") {
+			t.Errorf("expected the lines that fit in %d bytes, got %d bytes starting %q", maxReadFileBytes, len(result), result[:40])
+		}
+	})
+
+	t.Run("offset past end of file", func(t *testing.T) {
+		if _, err := tool.Execute(context.Background(), []byte(`{"path":"big.txt","offset":9000}`)); err == nil {
+			t.Error("expected an error for an offset past the end, got none")
+		}
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		if _, err := tool.Execute(context.Background(), []byte(`{"path":"big.txt","limit":-1}`)); err == nil {
+			t.Error("expected an error for a negative limit, got none")
+		}
+	})
+
+	t.Run("binary file is refused", func(t *testing.T) {
+		_, err := tool.Execute(context.Background(), []byte(`{"path":"image.png"}`))
+		if err == nil || !strings.Contains(err.Error(), "binary") {
+			t.Errorf("expected a binary file error, got %v", err)
+		}
+	})
+}
+
+// countingReaderAt counts the bytes read through it.
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read += n
+	return n, err
+}
+
+func TestTruncateHeadTail(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef\n", 1<<16) // 1 MB
+	r := &countingReaderAt{r: strings.NewReader(content)}
+
+	result, err := truncateHeadTail(r, int64(len(content)), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.read > 1024 {
+		t.Errorf("expected only the head and tail to be read, read %d bytes", r.read)
+	}
+	if !strings.HasPrefix(result, "0123456789abcdef\n") || !strings.HasSuffix(result, "0123456789abcdef\n") {
+		t.Errorf("expected whole lines at both ends, got %q", result)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	t.Run("multi-byte rune split at the sniff boundary", func(t *testing.T) {
+		data := []byte(strings.Repeat("a", binarySniffLen-1) + "é and more text")
+		if isBinary(data) {
+			t.Error("expected valid UTF-8 text cut mid-rune to not be binary")
+		}
+	})
+
+	t.Run("invalid UTF-8", func(t *testing.T) {
+		if !isBinary([]byte{0xff, 0xfe, 'a', 'b'}) {
+			t.Error("expected invalid UTF-8 to be treated as binary")
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
@@ -22,20 +23,57 @@ type ToolCall struct {
 	InputParseErr error
 }
 
+// Limits bounds a single tool call. Dispatch enforces them around Execute,
+// so a tool that hangs or produces enormous output can't stall or flood a
+// chat session even if the tool itself doesn't guard against it.
+type Limits struct {
+	// Timeout cancels the call's context and abandons it with an error
+	// once exceeded.
+	Timeout time.Duration
+	// MaxOutputBytes truncates a successful result beyond this size.
+	MaxOutputBytes int
+}
+
+// DefaultLimits apply to any tool registered without its own Limits, and
+// fill in any zero field of Limits passed to RegisterWithLimits.
+var DefaultLimits = Limits{
+	Timeout:        2 * time.Minute,
+	MaxOutputBytes: 100 * 1024,
+}
+
 // Registry holds the set of tools available to a chat session and mediates
 // between Bedrock's tool-use protocol and concrete Tool implementations.
 type Registry struct {
-	tools map[string]Tool
+	tools  map[string]Tool
+	limits map[string]Limits
 }
 
 // NewRegistry creates an empty Registry.
 func NewRegistry() *Registry {
-	return &Registry{tools: make(map[string]Tool)}
+	return &Registry{
+		tools:  make(map[string]Tool),
+		limits: make(map[string]Limits),
+	}
 }
 
-// Register adds a tool to the registry, keyed by its Name().
+// Register adds a tool to the registry, keyed by its Name(), with
+// DefaultLimits.
 func (r *Registry) Register(tool Tool) {
+	r.RegisterWithLimits(tool, Limits{})
+}
+
+// RegisterWithLimits adds a tool to the registry with its own execution
+// limits. Zero fields fall back to DefaultLimits.
+func (r *Registry) RegisterWithLimits(tool Tool, limits Limits) {
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultLimits.Timeout
+	}
+	if limits.MaxOutputBytes <= 0 {
+		limits.MaxOutputBytes = DefaultLimits.MaxOutputBytes
+	}
+
 	r.tools[tool.Name()] = tool
+	r.limits[tool.Name()] = limits
 }
 
 // ToolConfiguration builds the Bedrock ToolConfiguration for this registry's
@@ -72,29 +110,53 @@ func (r *Registry) ToolConfiguration() *types.ToolConfiguration {
 // requires confirmation - Dispatch never dereferences a nil gate for a
 // non-destructive tool.
 func (r *Registry) Dispatch(ctx context.Context, call ToolCall, gate PermissionGate) types.ToolResultBlock {
+	return r.DispatchCached(ctx, call, gate, nil)
+}
+
+// DispatchCached is Dispatch with a ResultCache: a read-only call
+// identical to one already answered from cache is served from it (with a
+// note saying so), successful read-only results are stored unless the
+// tool is Uncacheable, and any destructive call that gets past the gate
+// clears it. cache may be nil, which disables caching.
+func (r *Registry) DispatchCached(ctx context.Context, call ToolCall, gate PermissionGate, cache *ResultCache) types.ToolResultBlock {
 	tool, ok := r.tools[call.Name]
 	if !ok {
-		return errorResult(call.ToolUseID, fmt.Sprintf("unknown tool: %s", call.Name))
+		return errorResult(call.ToolUseID, toolErrorf(ErrorKindNotFound, "unknown tool: %s", call.Name))
 	}
 
 	if tool.RequiresConfirmation() {
 		summary, patternKey, err := tool.ConfirmationSummary(call.Input)
 		if err != nil {
-			return errorResult(call.ToolUseID, fmt.Sprintf("invalid tool input: %s", err.Error()))
+			return errorResult(call.ToolUseID, toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err))
 		}
 
 		if gate.Check(tool.Name(), patternKey, summary) == DecisionDeny {
-			return errorResult(call.ToolUseID, "user declined this action")
+			return errorResult(call.ToolUseID, toolErrorf(ErrorKindPermission, "user declined this action"))
+		}
+
+		// even a failed destructive call may have partially changed things
+		cache.clear()
+	} else if cacheable(tool) {
+		if cached, ok := cache.get(tool.Name(), call.Input); ok {
+			return successResult(call.ToolUseID, cachedResultNote+cached)
 		}
 	}
 
-	output, err := tool.Execute(ctx, call.Input)
+	output, err := r.execute(ctx, tool, call.Input)
 	if err != nil {
-		return errorResult(call.ToolUseID, err.Error())
+		return errorResult(call.ToolUseID, err)
+	}
+
+	if cacheable(tool) {
+		cache.put(tool.Name(), call.Input, output)
 	}
 
+	return successResult(call.ToolUseID, output)
+}
+
+func successResult(toolUseID, output string) types.ToolResultBlock {
 	return types.ToolResultBlock{
-		ToolUseId: aws.String(call.ToolUseID),
+		ToolUseId: aws.String(toolUseID),
 		Status:    types.ToolResultStatusSuccess,
 		Content: []types.ToolResultContentBlock{
 			&types.ToolResultContentBlockMemberText{Value: output},
@@ -102,12 +164,56 @@ func (r *Registry) Dispatch(ctx context.Context, call ToolCall, gate PermissionG
 	}
 }
 
-func errorResult(toolUseID, message string) types.ToolResultBlock {
+// execute runs tool.Execute under the tool's Limits. Execute runs on its
+// own goroutine so a tool that ignores context cancellation is abandoned at
+// the deadline instead of blocking the session; its eventual result is
+// discarded.
+func (r *Registry) execute(ctx context.Context, tool Tool, input json.RawMessage) (string, error) {
+	limits := r.limits[tool.Name()]
+
+	execCtx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	defer cancel()
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := tool.Execute(execCtx, input)
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case <-execCtx.Done():
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("tool call canceled: %w", ctx.Err())
+		}
+		return "", toolErrorf(ErrorKindTimeout, "tool %s timed out after %s", tool.Name(), limits.Timeout)
+
+	case res := <-done:
+		if res.err != nil {
+			return "", res.err
+		}
+		return truncateToolOutput(res.output, limits.MaxOutputBytes), nil
+	}
+}
+
+// truncateToolOutput cuts output to maxBytes, noting how much was dropped
+// so the model knows it's seeing a partial result.
+func truncateToolOutput(output string, maxBytes int) string {
+	if len(output) <= maxBytes {
+		return output
+	}
+	return output[:maxBytes] + fmt.Sprintf("\n... (output truncated: showing %d of %d bytes)", maxBytes, len(output))
+}
+
+func errorResult(toolUseID string, err error) types.ToolResultBlock {
 	return types.ToolResultBlock{
 		ToolUseId: aws.String(toolUseID),
 		Status:    types.ToolResultStatusError,
 		Content: []types.ToolResultContentBlock{
-			&types.ToolResultContentBlockMemberText{Value: message},
+			&types.ToolResultContentBlockMemberText{Value: formatToolError(err)},
 		},
 	}
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
@@ -33,6 +35,26 @@ func (f *fakeTool) ConfirmationSummary(_ json.RawMessage) (string, string, error
 	return "", "", nil
 }
 
+// hangingTool is a test double whose Execute never returns on its own and
+// ignores context cancellation, simulating a runaway tool.
+type hangingTool struct {
+	release chan struct{}
+}
+
+func (h *hangingTool) Name() string        { return "hanging_tool" }
+func (h *hangingTool) Description() string { return "a tool that never finishes" }
+func (h *hangingTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{"type": "object"})
+}
+func (h *hangingTool) Execute(_ context.Context, _ json.RawMessage) (string, error) {
+	<-h.release
+	return "too late", nil
+}
+func (h *hangingTool) RequiresConfirmation() bool { return false }
+func (h *hangingTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
 // fakeDestructiveTool is a test double for a destructive tool that requires
 // confirmation, with a controllable ConfirmationSummary outcome.
 type fakeDestructiveTool struct {
@@ -238,3 +260,75 @@ func TestRegistry_Dispatch_GateAllowRunsExecute(t *testing.T) {
 		}
 	}
 }
+
+func resultText(t *testing.T, result types.ToolResultBlock) string {
+	t.Helper()
+	if len(result.Content) != 1 {
+		t.Fatalf("expected 1 content block, got %d", len(result.Content))
+	}
+	textBlock, ok := result.Content[0].(*types.ToolResultContentBlockMemberText)
+	if !ok {
+		t.Fatalf("expected text content block, got %T", result.Content[0])
+	}
+	return textBlock.Value
+}
+
+func TestRegistry_Dispatch_TimeoutAbandonsHungTool(t *testing.T) {
+	tool := &hangingTool{release: make(chan struct{})}
+	defer close(tool.release)
+
+	r := NewRegistry()
+	r.RegisterWithLimits(tool, Limits{Timeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	result := r.Dispatch(context.Background(), ToolCall{
+		Name:      "hanging_tool",
+		ToolUseID: "call-1",
+		Input:     []byte(`{}`),
+	}, nil)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected Dispatch to return at the timeout, took %s", elapsed)
+	}
+	if result.Status != types.ToolResultStatusError {
+		t.Errorf("expected error status, got %v", result.Status)
+	}
+	if text := resultText(t, result); !strings.Contains(text, "timed out") {
+		t.Errorf("expected a timeout message, got %q", text)
+	}
+}
+
+func TestRegistry_Dispatch_TruncatesLargeOutput(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterWithLimits(&fakeTool{name: "fake_tool", result: strings.Repeat("x", 100)}, Limits{MaxOutputBytes: 10})
+
+	result := r.Dispatch(context.Background(), ToolCall{
+		Name:      "fake_tool",
+		ToolUseID: "call-1",
+		Input:     []byte(`{}`),
+	}, nil)
+
+	if result.Status != types.ToolResultStatusSuccess {
+		t.Errorf("expected success status, got %v", result.Status)
+	}
+	text := resultText(t, result)
+	if !strings.HasPrefix(text, strings.Repeat("x", 10)+"\n") {
+		t.Errorf("expected output cut at 10 bytes, got %q", text)
+	}
+	if !strings.Contains(text, "showing 10 of 100 bytes") {
+		t.Errorf("expected a truncation note, got %q", text)
+	}
+}
+
+func TestRegistry_RegisterWithLimits_ZeroFieldsUseDefaults(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterWithLimits(&fakeTool{name: "fake_tool"}, Limits{MaxOutputBytes: 10})
+
+	got := r.limits["fake_tool"]
+	if got.Timeout != DefaultLimits.Timeout {
+		t.Errorf("expected default timeout %s, got %s", DefaultLimits.Timeout, got.Timeout)
+	}
+	if got.MaxOutputBytes != 10 {
+		t.Errorf("expected explicit output limit to be kept, got %d", got.MaxOutputBytes)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+
+	"github.com/chat-cli/chat-cli/utils"
 )
 
 // defaultRunShellTimeout bounds how long a run_shell command may execute
@@ -62,7 +64,7 @@ type runShellInput struct {
 func (t *RunShellTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
 	var params runShellInput
 	if err := json.Unmarshal(input, &params); err != nil {
-		return "", fmt.Errorf("invalid tool input: %w", err)
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
 	}
 
 	timeout := t.timeout
@@ -82,7 +84,7 @@ func (t *RunShellTool) Execute(ctx context.Context, input json.RawMessage) (stri
 	// shell itself - killing only the shell leaves such children running
 	// and holding the output pipe open, which would otherwise block
 	// CombinedOutput() below well past the intended timeout.
-	setProcessGroup(cmd)
+	utils.SetProcessGroup(cmd)
 
 	type result struct {
 		output []byte
@@ -96,9 +98,13 @@ func (t *RunShellTool) Execute(ctx context.Context, input json.RawMessage) (stri
 
 	select {
 	case <-runCtx.Done():
-		killProcessGroup(cmd)
+		utils.KillProcessGroup(cmd)
 		<-done // wait for the goroutine to unblock now that the group is dead
-		return "", fmt.Errorf("command timed out after %s", timeout)
+		if err := ctx.Err(); err != nil {
+			// the turn was canceled, not the command too slow
+			return "", fmt.Errorf("command canceled: %w", err)
+		}
+		return "", toolErrorf(ErrorKindTimeout, "command timed out after %s", timeout)
 
 	case res := <-done:
 		output := truncateShellOutput(string(res.output))
@@ -131,7 +137,7 @@ func (t *RunShellTool) RequiresConfirmation() bool {
 func (t *RunShellTool) ConfirmationSummary(input json.RawMessage) (string, string, error) {
 	var params runShellInput
 	if err := json.Unmarshal(input, &params); err != nil {
-		return "", "", fmt.Errorf("invalid tool input: %w", err)
+		return "", "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
 	}
 
 	summary := "Run: " + params.Command
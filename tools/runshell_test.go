@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -80,6 +81,20 @@ func TestRunShellTool_Execute(t *testing.T) {
 		}
 	})
 
+	t.Run("canceling the caller's context isn't reported as a timeout", func(t *testing.T) {
+		tool := NewRunShellTool()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		_, err := tool.Execute(ctx, []byte(`{"command":"sleep 5"}`))
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected a canceled error, got %v", err)
+		}
+		if kind := ErrorKindOf(err); kind == ErrorKindTimeout {
+			t.Errorf("expected cancellation not to be classified as a timeout")
+		}
+	})
+
 	t.Run("output over 32KB is truncated", func(t *testing.T) {
 		tool := NewRunShellTool()
 		output, err := tool.Execute(context.Background(), []byte(`{"command":"yes | head -c 40000"}`))
@@ -0,0 +1,326 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+)
+
+const (
+	// defaultSQLRows and maxSQLRows bound how many rows one sql_query call
+	// returns.
+	defaultSQLRows = 100
+	maxSQLRows     = 1000
+	// maxSQLCellRunes cuts long values, such as whole messages, so a few
+	// rows can't fill the context window.
+	maxSQLCellRunes = 300
+)
+
+// forbiddenSQLKeywords can't appear outside string literals in a query,
+// even one that starts with SELECT or WITH: SQLite allows a WITH clause
+// before an INSERT, UPDATE, or DELETE, and ATTACH could open another file.
+var forbiddenSQLKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "DROP": true, "ALTER": true,
+	"CREATE": true, "ATTACH": true, "DETACH": true, "PRAGMA": true, "VACUUM": true,
+	"REINDEX": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true,
+}
+
+// SQLQueryTool is a read-only built-in tool that runs SELECT queries
+// against chat-cli's own history database, so a model can answer questions
+// about past chats, token usage, and feedback. Queries that aren't a
+// single SELECT are refused before they reach the database; db should
+// also be opened read-only (see sqlite.OpenReadOnly) as a second line of
+// defence. NewDataQueryTool offers the same over a data file loaded for
+// chat-cli analyze.
+type SQLQueryTool struct {
+	db          *sql.DB
+	name        string
+	description string
+}
+
+// sqlQueryLimits is appended to every SQLQueryTool description.
+const sqlQueryLimits = " Use ? placeholders with params for values. " +
+	"Returns at most 100 rows unless limit says otherwise (up to 1000); long values are shortened."
+
+// NewSQLQueryTool creates a SQLQueryTool over db, the chat history.
+func NewSQLQueryTool(db *sql.DB) *SQLQueryTool {
+	return &SQLQueryTool{
+		db:   db,
+		name: "sql_query",
+		description: "Run a read-only SQL SELECT against the user's local chat-cli history database (SQLite). " +
+			"Main tables: chats (one row per message: id, chat_id, persona 'User' or 'Assistant', message, title, " +
+			"stop_reason, superseded_at, created_at), token_usage (chat_id, model_id, input_tokens, output_tokens, " +
+			"cost, created_at), feedback (chat_id, model_id, rating, comment, prompt, response, created_at), " +
+			"annotations (chat_id, message_id, note) and chat_names (workspace, name, chat_id). " +
+			"Query sqlite_schema for the full schema." + sqlQueryLimits,
+	}
+}
+
+// NewDataQueryTool creates a SQLQueryTool named query_data over db, which
+// holds a data file loaded into SQLite. schema describes its table for the
+// model.
+func NewDataQueryTool(db *sql.DB, schema string) *SQLQueryTool {
+	return &SQLQueryTool{
+		db:   db,
+		name: "query_data",
+		description: "Run a read-only SQL SELECT (SQLite dialect) over the user's data file to count, filter, " +
+			"group, or aggregate it exactly. " + schema + sqlQueryLimits,
+	}
+}
+
+func (t *SQLQueryTool) Name() string {
+	return t.name
+}
+
+func (t *SQLQueryTool) Description() string {
+	return t.description
+}
+
+func (t *SQLQueryTool) InputSchema() document.Interface {
+	return document.NewLazyDocument(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "A single SELECT (or WITH ... SELECT) statement.",
+			},
+			"params": map[string]interface{}{
+				"type":        "array",
+				"description": "Values for the query's ? placeholders, in order.",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Most rows to return; defaults to 100.",
+			},
+		},
+		"required": []string{"query"},
+	})
+}
+
+type sqlQueryInput struct {
+	Query  string        `json:"query"`
+	Params []interface{} `json:"params"`
+	Limit  int           `json:"limit"`
+}
+
+func (t *SQLQueryTool) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+	var params sqlQueryInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
+	}
+	if params.Limit < 0 || params.Limit > maxSQLRows {
+		return "", toolErrorf(ErrorKindInvalidInput, "limit must be from 1 to %d", maxSQLRows)
+	}
+	return t.Query(ctx, params.Query, params.Limit, params.Params...)
+}
+
+func (t *SQLQueryTool) RequiresConfirmation() bool {
+	return false
+}
+
+func (t *SQLQueryTool) ConfirmationSummary(_ json.RawMessage) (string, string, error) {
+	return "", "", nil
+}
+
+// Query runs query with args bound to its placeholders and returns the
+// rows as a table, at most limit of them (0 means defaultSQLRows).
+func (t *SQLQueryTool) Query(ctx context.Context, query string, limit int, args ...interface{}) (string, error) {
+	if err := checkReadOnlyQuery(query); err != nil {
+		return "", err
+	}
+	if limit == 0 {
+		limit = defaultSQLRows
+	}
+
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "query failed: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("query failed: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(columns, " | "))
+	b.WriteString("\n")
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	count, more := 0, false
+	for rows.Next() {
+		if count == limit {
+			more = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return "", fmt.Errorf("reading row: %w", err)
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = formatSQLValue(v)
+		}
+		b.WriteString(strings.Join(cells, " | "))
+		b.WriteString("\n")
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", toolErrorf(ErrorKindInvalidInput, "query failed: %w", err)
+	}
+
+	switch {
+	case more:
+		fmt.Fprintf(&b, "(first %d rows; there are more - narrow the query or aggregate)", count)
+	case count == 1:
+		b.WriteString("(1 row)")
+	default:
+		fmt.Fprintf(&b, "(%d rows)", count)
+	}
+	return b.String(), nil
+}
+
+// Schema lists the database's tables with their columns, one per line.
+func (t *SQLQueryTool) Schema(ctx context.Context) (string, error) {
+	rows, err := t.db.QueryContext(ctx, `
+		SELECT m.name, group_concat(p.name, ', ')
+		FROM sqlite_schema m, pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		GROUP BY m.name
+		ORDER BY m.name`)
+	if err != nil {
+		return "", fmt.Errorf("reading schema: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var b strings.Builder
+	for rows.Next() {
+		var table, columns string
+		if err := rows.Scan(&table, &columns); err != nil {
+			return "", fmt.Errorf("reading schema: %w", err)
+		}
+		fmt.Fprintf(&b, "%s (%s)\n", table, columns)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("reading schema: %w", err)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func formatSQLValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if !utf8.Valid(v) {
+			return fmt.Sprintf("<%d bytes>", len(v))
+		}
+		s = string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		s = fmt.Sprint(v)
+	}
+	s = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "|", `\|`).Replace(s)
+	if utf8.RuneCountInString(s) > maxSQLCellRunes {
+		s = string([]rune(s)[:maxSQLCellRunes]) + "…"
+	}
+	return s
+}
+
+// checkReadOnlyQuery refuses anything but a single SELECT statement,
+// optionally led by a WITH clause. String literals, quoted identifiers, and
+// comments are skipped, so a keyword or semicolon inside them is allowed.
+func checkReadOnlyQuery(query string) error {
+	var words []string
+	statementEnded := false
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end < 0 {
+				return toolErrorf(ErrorKindInvalidInput, "unterminated comment")
+			}
+			i += 2 + utf8.RuneCountInString(string(runes[i+2:])[:end]) + 2
+		case r == '\'' || r == '"' || r == '`' || r == '[':
+			closer := r
+			if r == '[' {
+				closer = ']'
+			}
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == closer {
+					// a doubled quote is an escaped one
+					if closer != ']' && j+1 < len(runes) && runes[j+1] == closer {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(runes) {
+				return toolErrorf(ErrorKindInvalidInput, "unterminated quote")
+			}
+			if statementEnded {
+				return toolErrorf(ErrorKindInvalidInput, "only one statement can be run at a time")
+			}
+			i = j + 1
+		case r == ';':
+			statementEnded = true
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '$') {
+				j++
+			}
+			if statementEnded {
+				return toolErrorf(ErrorKindInvalidInput, "only one statement can be run at a time")
+			}
+			words = append(words, strings.ToUpper(string(runes[i:j])))
+			i = j
+		default:
+			if statementEnded {
+				return toolErrorf(ErrorKindInvalidInput, "only one statement can be run at a time")
+			}
+			i++
+		}
+	}
+
+	if len(words) == 0 {
+		return toolErrorf(ErrorKindInvalidInput, "query is empty")
+	}
+	if words[0] != "SELECT" && words[0] != "WITH" {
+		return toolErrorf(ErrorKindPermission, "only SELECT queries are allowed")
+	}
+	for _, word := range words {
+		if forbiddenSQLKeywords[word] {
+			return toolErrorf(ErrorKindPermission, "only SELECT queries are allowed (found %s)", word)
+		}
+	}
+	return nil
+}
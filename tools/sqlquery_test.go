@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chat-cli/chat-cli/db/sqlite"
+)
+
+// newTestSQLQueryTool creates a small chats table in a temporary database
+// and returns a tool over a read-only handle on it.
+func newTestSQLQueryTool(t *testing.T) *SQLQueryTool {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.db")
+
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rw.Exec(`
+		CREATE TABLE chats (id INTEGER PRIMARY KEY, chat_id TEXT, persona TEXT, message TEXT, title TEXT);
+		INSERT INTO chats (chat_id, persona, message, title) VALUES
+			('a', 'User', 'hello', NULL),
+			('a', 'Assistant', 'hi | there' || char(10) || 'friend', 'Greetings'),
+			('b', 'User', 'what is 2+2?', NULL);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := sqlite.OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ro.Close() })
+	return NewSQLQueryTool(ro)
+}
+
+func sqlQuery(t *testing.T, tool *SQLQueryTool, input map[string]interface{}) (string, error) {
+	t.Helper()
+	raw, _ := json.Marshal(input)
+	return tool.Execute(context.Background(), raw)
+}
+
+func TestSQLQueryTool_Execute(t *testing.T) {
+	tool := newTestSQLQueryTool(t)
+
+	t.Run("select", func(t *testing.T) {
+		got, err := sqlQuery(t, tool, map[string]interface{}{
+			"query": "SELECT persona, message, title FROM chats WHERE chat_id = 'a' ORDER BY id",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "persona | message | title\n" +
+			"User | hello | NULL\n" +
+			`Assistant | hi \| there\nfriend | Greetings` + "\n" +
+			"(2 rows)"
+		if got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("parameters", func(t *testing.T) {
+		got, err := sqlQuery(t, tool, map[string]interface{}{
+			"query":  "SELECT COUNT(*) AS n FROM chats WHERE chat_id = ? AND persona = ?",
+			"params": []interface{}{"a", "User"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "n\n1\n(1 row)" {
+			t.Errorf("unexpected result %q", got)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		got, err := sqlQuery(t, tool, map[string]interface{}{
+			"query": "WITH ids AS (SELECT id FROM chats) SELECT id FROM ids ORDER BY id",
+			"limit": 2,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(got, "id\n1\n2\n(first 2 rows;") {
+			t.Errorf("expected the result to stop at 2 rows, got %q", got)
+		}
+	})
+
+	t.Run("keywords in strings and comments are allowed", func(t *testing.T) {
+		_, err := sqlQuery(t, tool, map[string]interface{}{
+			"query": "SELECT 'drop table; delete' AS s -- update\n/* insert; */",
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestSQLQueryTool_RefusesWrites(t *testing.T) {
+	tool := newTestSQLQueryTool(t)
+
+	for _, query := range []string{
+		"DELETE FROM chats",
+		"UPDATE chats SET message = ''",
+		"WITH x AS (SELECT 1) DELETE FROM chats",
+		"SELECT 1; DROP TABLE chats",
+		"SELECT 1; SELECT 2",
+		"ATTACH DATABASE 'other.db' AS other",
+		"PRAGMA table_info(chats)",
+		"  -- nothing but a comment",
+		"SELECT 'unterminated",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := sqlQuery(t, tool, map[string]interface{}{"query": query}); err == nil {
+				t.Errorf("expected %q to be refused", query)
+			}
+		})
+	}
+
+	got, err := sqlQuery(t, tool, map[string]interface{}{"query": "SELECT COUNT(*) FROM chats;"})
+	if err != nil || !strings.Contains(got, "\n3\n") {
+		t.Errorf("expected a trailing semicolon to be fine and the rows untouched, got %q (%v)", got, err)
+	}
+}
+
+func TestSQLQueryTool_Schema(t *testing.T) {
+	got, err := newTestSQLQueryTool(t).Schema(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "chats (id, chat_id, persona, message, title)" {
+		t.Errorf("unexpected schema %q", got)
+	}
+}
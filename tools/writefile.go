@@ -59,7 +59,7 @@ type writeFileInput struct {
 func (t *WriteFileTool) Execute(_ context.Context, input json.RawMessage) (string, error) {
 	var params writeFileInput
 	if err := json.Unmarshal(input, &params); err != nil {
-		return "", fmt.Errorf("invalid tool input: %w", err)
+		return "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
 	}
 
 	fullPath, err := utils.ValidateLocalPathForWrite(params.Path)
@@ -81,7 +81,7 @@ func (t *WriteFileTool) RequiresConfirmation() bool {
 func (t *WriteFileTool) ConfirmationSummary(input json.RawMessage) (string, string, error) {
 	var params writeFileInput
 	if err := json.Unmarshal(input, &params); err != nil {
-		return "", "", fmt.Errorf("invalid tool input: %w", err)
+		return "", "", toolErrorf(ErrorKindInvalidInput, "invalid tool input: %w", err)
 	}
 
 	fullPath, err := utils.ValidateLocalPathForWrite(params.Path)
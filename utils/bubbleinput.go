@@ -1,3 +1,5 @@
+//go:build !lite
+
 package utils
 
 import (
@@ -11,6 +13,10 @@ import (
 	"golang.org/x/term"
 )
 
+// LiteBuild reports whether this binary was built with the lite tag, which
+// leaves out the Bubble Tea input box.
+const LiteBuild = false
+
 // InputField model for handling text input using BubbleTea
 type InputField struct {
 	textarea  textarea.Model
@@ -0,0 +1,15 @@
+//go:build lite
+
+package utils
+
+// The lite build leaves out the Bubble Tea input box and its dependencies,
+// so a terminal gets the same plain line input as piped stdin.
+
+// LiteBuild reports whether this binary was built with the lite tag.
+const LiteBuild = true
+
+// BubbleInput isn't available in the lite build; StringPrompt reads a
+// plain line instead.
+func BubbleInput() (string, bool) {
+	return "", false
+}
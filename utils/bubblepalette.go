@@ -0,0 +1,128 @@
+//go:build !lite
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxPaletteRows is how many choices the palette shows at once.
+const maxPaletteRows = 10
+
+var (
+	paletteTitleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("62"))
+	paletteSelectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("15")).Background(lipgloss.Color("62"))
+	paletteDimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+)
+
+// paletteModel is a search box over a list of choices, filtered and
+// ranked with FuzzyFilter as the search changes.
+type paletteModel struct {
+	title    string
+	items    []PaletteItem
+	search   textinput.Model
+	matches  []int
+	cursor   int
+	chosen   int
+	quitting bool
+}
+
+func newPaletteModel(title string, items []PaletteItem) *paletteModel {
+	search := textinput.New()
+	search.Placeholder = "Type to search..."
+	search.Prompt = "> "
+	search.Focus()
+
+	return &paletteModel{
+		title:   title,
+		items:   items,
+		search:  search,
+		matches: FuzzyFilter("", items),
+		chosen:  -1,
+	}
+}
+
+func (m *paletteModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m *paletteModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.Type {
+		case tea.KeyEsc, tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyEnter:
+			if len(m.matches) > 0 {
+				m.chosen = m.matches[m.cursor]
+			}
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyUp, tea.KeyCtrlP, tea.KeyShiftTab:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			return m, nil
+		case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+			if m.cursor < len(m.matches)-1 {
+				m.cursor++
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	previous := m.search.Value()
+	m.search, cmd = m.search.Update(msg)
+	if m.search.Value() != previous {
+		m.matches = FuzzyFilter(m.search.Value(), m.items)
+		m.cursor = 0
+	}
+	return m, cmd
+}
+
+func (m *paletteModel) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(paletteTitleStyle.Render(m.title) + "\n")
+	b.WriteString(m.search.View() + "\n\n")
+
+	if len(m.matches) == 0 {
+		b.WriteString(paletteDimStyle.Render("  Nothing matches.") + "\n")
+	}
+	// keep the cursor in view when there are more matches than rows
+	first := max(0, m.cursor-maxPaletteRows+1)
+	for row, index := range m.matches[first:min(len(m.matches), first+maxPaletteRows)] {
+		item := m.items[index]
+		line := "  " + item.Title
+		if first+row == m.cursor {
+			line = paletteSelectedStyle.Render("> " + item.Title)
+		}
+		if item.Description != "" {
+			line += "  " + paletteDimStyle.Render(item.Description)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n" + paletteDimStyle.Render(fmt.Sprintf("%d of %d · ↑/↓ to move · enter to choose · esc to cancel", len(m.matches), len(m.items))) + "\n")
+	return b.String()
+}
+
+// BubblePalette shows a Bubble Tea command palette over items and returns
+// the index of the one chosen, or false if the user cancelled. The error is
+// set when the palette couldn't run, such as on a terminal it can't drive.
+func BubblePalette(title string, items []PaletteItem) (int, bool, error) {
+	m := newPaletteModel(title, items)
+	if _, err := tea.NewProgram(m).Run(); err != nil {
+		return -1, false, err
+	}
+	return m.chosen, m.chosen >= 0, nil
+}
@@ -0,0 +1,11 @@
+//go:build lite
+
+package utils
+
+import "errors"
+
+// BubblePalette isn't available in the lite build; Choose reads a plain
+// line instead.
+func BubblePalette(title string, items []PaletteItem) (int, bool, error) {
+	return -1, false, errors.New("the palette isn't available in the lite build")
+}
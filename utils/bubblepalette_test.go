@@ -0,0 +1,48 @@
+//go:build !lite
+
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPaletteModel(t *testing.T) {
+	m := newPaletteModel("What now?", paletteItems)
+	if m.Init() == nil {
+		t.Error("Init should return a command")
+	}
+
+	for _, r := range "usage" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if len(m.matches) != 1 || m.matches[0] != 3 {
+		t.Fatalf("expected the search to narrow to View usage, got %v", m.matches)
+	}
+	if view := m.View(); !strings.Contains(view, "View usage") || !strings.Contains(view, "1 of 4") {
+		t.Errorf("unexpected view\n%s", view)
+	}
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd == nil || m.chosen != 3 {
+		t.Errorf("expected enter to choose View usage and quit, got %d", m.chosen)
+	}
+}
+
+func TestPaletteModelNavigation(t *testing.T) {
+	m := newPaletteModel("What now?", paletteItems)
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.chosen != 1 {
+		t.Errorf("expected the second item, got %d", m.chosen)
+	}
+
+	m = newPaletteModel("What now?", paletteItems)
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.chosen != -1 || m.View() != "" {
+		t.Errorf("expected esc to cancel, got %d", m.chosen)
+	}
+}
@@ -0,0 +1,390 @@
+//go:build !lite
+
+package utils
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxChatInputLines is how tall the chat view's input box grows before it
+// scrolls.
+const maxChatInputLines = 8
+
+// waitingPlaceholder fills the input box while the chat loop is busy, such
+// as while a reply streams in.
+const waitingPlaceholder = "Waiting for the reply... (Ctrl+C to cancel)"
+
+var (
+	chatViewStatusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("62"))
+	chatViewHintStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("250")).Background(lipgloss.Color("62"))
+)
+
+// ChatView is the full-screen chat: the conversation so far in a viewport
+// that scrolls back with PgUp and PgDn, a multi-line input box under it,
+// and a status bar at the bottom. The chat loop runs on its own goroutine
+// and drives it: everything it writes is added to the conversation, and it
+// takes each message from NextInput.
+type ChatView struct {
+	program *tea.Program
+	model   *chatViewModel
+	inputs  chan string
+	done    chan struct{}
+	err     error
+
+	// pending is what's left of a line taken by Read.
+	mu      sync.Mutex
+	pending []byte
+}
+
+// chatOutputMsg is text written to the view.
+type chatOutputMsg string
+
+// chatStatusMsg replaces the status bar's text.
+type chatStatusMsg string
+
+// chatWaitMsg says the chat loop is waiting for input, and whether an
+// empty line is an answer.
+type chatWaitMsg struct {
+	allowEmpty bool
+}
+
+type chatViewModel struct {
+	viewport viewport.Model
+	input    textarea.Model
+	status   string
+	width    int
+	height   int
+	ready    bool
+
+	// raw is the conversation's finished lines as written; wrapped is the
+	// same lines wrapped to the view's width. partial is the line still
+	// being written.
+	raw     strings.Builder
+	wrapped strings.Builder
+	partial strings.Builder
+
+	// waiting is set while the chat loop waits for input; the input box
+	// keeps what's typed until then.
+	waiting    bool
+	allowEmpty bool
+	inputs     chan<- string
+
+	// interrupt cancels the reply in progress, reporting whether there
+	// was one.
+	interrupt func() bool
+	quitting  bool
+}
+
+func newChatViewModel(inputs chan<- string, interrupt func() bool) *chatViewModel {
+	ta := textarea.New()
+	ta.Placeholder = waitingPlaceholder
+	ta.Prompt = "> "
+	ta.ShowLineNumbers = false
+	ta.SetHeight(1)
+	ta.Focus()
+	// Enter sends; terminals report Shift+Enter as Enter unless set to
+	// send it as Alt+Enter, so that and Ctrl+J start a new line
+	ta.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.FocusedStyle.Base = lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62"))
+
+	return &chatViewModel{
+		viewport:  viewport.New(0, 0),
+		input:     ta,
+		inputs:    inputs,
+		interrupt: interrupt,
+	}
+}
+
+func (m *chatViewModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *chatViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.ready = true
+		m.rewrap()
+		m.layout()
+		return m, nil
+
+	case chatOutputMsg:
+		m.write(string(msg))
+		return m, nil
+
+	case chatStatusMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case chatWaitMsg:
+		m.waiting, m.allowEmpty = true, msg.allowEmpty
+		m.input.Placeholder = "Type your message..."
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			if m.interrupt != nil && m.interrupt() {
+				return m, nil
+			}
+			if m.input.Value() != "" {
+				m.input.Reset()
+				m.layout()
+				return m, nil
+			}
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyEsc:
+			if m.input.Value() != "" {
+				m.input.Reset()
+				m.layout()
+				return m, nil
+			}
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyCtrlD:
+			if m.input.Value() == "" {
+				m.quitting = true
+				return m, tea.Quit
+			}
+		case tea.KeyPgUp:
+			m.viewport.PageUp()
+			return m, nil
+		case tea.KeyPgDown:
+			m.viewport.PageDown()
+			return m, nil
+		case tea.KeyEnter:
+			if !msg.Alt {
+				m.submit()
+				return m, nil
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	m.layout()
+	return m, cmd
+}
+
+// submit hands what's in the input box to the chat loop, if it's waiting
+// for it.
+func (m *chatViewModel) submit() {
+	value := m.input.Value()
+	if !m.waiting || (strings.TrimSpace(value) == "" && !m.allowEmpty) {
+		return
+	}
+	m.waiting = false
+	m.input.Placeholder = waitingPlaceholder
+	m.input.Reset()
+	m.layout()
+	m.inputs <- value
+}
+
+// write adds text to the conversation. Only color escapes are kept; a
+// carriage return starts its line again, as a spinner expects.
+func (m *chatViewModel) write(text string) {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\n':
+			line := m.partial.String()
+			m.raw.WriteString(line + "\n")
+			m.wrapped.WriteString(m.wrap(line) + "\n")
+			m.partial.Reset()
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				continue
+			}
+			m.partial.Reset()
+		case 0x1b:
+			n, keep := escapeSequence(text[i:])
+			if keep {
+				m.partial.WriteString(text[i : i+n])
+			}
+			i += n - 1
+		default:
+			m.partial.WriteByte(c)
+		}
+	}
+	m.refresh()
+}
+
+// escapeSequence returns the length of the escape sequence s starts with,
+// and whether it only sets colors.
+func escapeSequence(s string) (int, bool) {
+	if len(s) < 2 || s[1] != '[' {
+		return min(len(s), 2), false
+	}
+	for j := 2; j < len(s); j++ {
+		if s[j] >= 0x40 && s[j] <= 0x7e {
+			return j + 1, s[j] == 'm'
+		}
+	}
+	return len(s), false
+}
+
+func (m *chatViewModel) wrap(line string) string {
+	if m.width <= 0 {
+		return line
+	}
+	return lipgloss.NewStyle().Width(m.width).Render(line)
+}
+
+// rewrap wraps the conversation again for a new width.
+func (m *chatViewModel) rewrap() {
+	m.wrapped.Reset()
+	lines := strings.Split(m.raw.String(), "\n")
+	// raw ends with a newline, so the last of lines is empty
+	for _, line := range lines[:len(lines)-1] {
+		m.wrapped.WriteString(m.wrap(line) + "\n")
+	}
+	m.refresh()
+}
+
+// refresh shows the conversation in the viewport, following the end of it
+// unless the user has scrolled back.
+func (m *chatViewModel) refresh() {
+	following := m.viewport.AtBottom()
+	m.viewport.SetContent(m.wrapped.String() + m.wrap(m.partial.String()))
+	if following {
+		m.viewport.GotoBottom()
+	}
+}
+
+// layout sizes the input box to what's in it, up to maxChatInputLines,
+// and gives the viewport the rest of the screen.
+func (m *chatViewModel) layout() {
+	if !m.ready {
+		return
+	}
+	m.input.SetWidth(max(m.width-2, 1))
+	m.input.SetHeight(min(max(m.input.LineCount(), 1), maxChatInputLines))
+
+	following := m.viewport.AtBottom()
+	m.viewport.Width = m.width
+	// the input box's border takes two lines and the status bar one
+	m.viewport.Height = max(m.height-m.input.Height()-3, 1)
+	if following {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *chatViewModel) View() string {
+	if !m.ready || m.quitting {
+		return ""
+	}
+
+	hint := " enter send · alt+enter newline · pgup/pgdn scroll "
+	status := " " + m.status
+	if gap := m.width - lipgloss.Width(status) - lipgloss.Width(hint); gap > 0 {
+		status += strings.Repeat(" ", gap)
+	} else {
+		hint = ""
+	}
+	bar := lipgloss.NewStyle().MaxWidth(m.width).Render(chatViewStatusStyle.Render(status) + chatViewHintStyle.Render(hint))
+
+	return m.viewport.View() + "\n" + m.input.View() + "\n" + bar
+}
+
+// transcript is the whole conversation as written, without wrapping.
+func (m *chatViewModel) transcript() string {
+	return m.raw.String() + m.partial.String()
+}
+
+// ErrChatViewUnavailable is returned by StartChatView when stdin isn't a
+// terminal the view can draw on.
+var ErrChatViewUnavailable = errors.New("the chat view needs an interactive terminal")
+
+// StartChatView takes over the terminal with a ChatView. interrupt is
+// called on Ctrl+C to cancel the reply in progress; when it reports there
+// was none, Ctrl+C on an empty input box ends the chat.
+func StartChatView(interrupt func() bool) (*ChatView, error) {
+	if !fancyInput() {
+		return nil, ErrChatViewUnavailable
+	}
+
+	inputs := make(chan string, 1)
+	v := &ChatView{
+		model:  newChatViewModel(inputs, interrupt),
+		inputs: inputs,
+		done:   make(chan struct{}),
+	}
+	v.program = tea.NewProgram(v.model, tea.WithAltScreen())
+	go func() {
+		defer close(v.done)
+		_, v.err = v.program.Run()
+	}()
+	return v, nil
+}
+
+// Write adds p to the conversation.
+func (v *ChatView) Write(p []byte) (int, error) {
+	v.program.Send(chatOutputMsg(p))
+	return len(p), nil
+}
+
+// SetStatus replaces the text of the status bar.
+func (v *ChatView) SetStatus(status string) {
+	v.program.Send(chatStatusMsg(status))
+}
+
+// NextInput waits for the user to send a message, returning false once
+// they've left the view.
+func (v *ChatView) NextInput() (string, bool) {
+	return v.next(false)
+}
+
+func (v *ChatView) next(allowEmpty bool) (string, bool) {
+	v.program.Send(chatWaitMsg{allowEmpty: allowEmpty})
+	select {
+	case input := <-v.inputs:
+		return input, true
+	case <-v.done:
+		return "", false
+	}
+}
+
+// Read reads an answer typed in the input box, a line at a time, for
+// prompts such as permission checks. The answer is added to the
+// conversation after the question.
+func (v *ChatView) Read(p []byte) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.pending) == 0 {
+		answer, ok := v.next(true)
+		if !ok {
+			return 0, io.EOF
+		}
+		_, _ = v.Write([]byte(answer + "\n"))
+		v.pending = []byte(answer + "\n")
+	}
+	n := copy(p, v.pending)
+	v.pending = v.pending[n:]
+	return n, nil
+}
+
+// Close gives the terminal back and returns the conversation shown, so it
+// can be printed where it stays in the terminal's scrollback.
+func (v *ChatView) Close() (string, error) {
+	v.program.Quit()
+	<-v.done
+	return v.model.transcript(), v.err
+}
+
+// ChatViewAvailable reports whether StartChatView can take over the
+// terminal: the same terminals the input box is used on.
+func ChatViewAvailable() bool {
+	return fancyInput()
+}
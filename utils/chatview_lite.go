@@ -0,0 +1,32 @@
+//go:build lite
+
+package utils
+
+import "errors"
+
+// ErrChatViewUnavailable is returned by StartChatView, since the lite
+// build has no chat view; chat reads plain lines instead.
+var ErrChatViewUnavailable = errors.New("the chat view isn't available in the lite build")
+
+// ChatView isn't available in the lite build.
+type ChatView struct{}
+
+// StartChatView always fails in the lite build.
+func StartChatView(interrupt func() bool) (*ChatView, error) {
+	return nil, ErrChatViewUnavailable
+}
+
+func (v *ChatView) Write(p []byte) (int, error) { return len(p), nil }
+
+func (v *ChatView) SetStatus(status string) {}
+
+func (v *ChatView) NextInput() (string, bool) { return "", false }
+
+func (v *ChatView) Read(p []byte) (int, error) { return 0, errors.New("no chat view") }
+
+func (v *ChatView) Close() (string, error) { return "", nil }
+
+// ChatViewAvailable is always false in the lite build.
+func ChatViewAvailable() bool {
+	return false
+}
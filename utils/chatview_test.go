@@ -0,0 +1,127 @@
+//go:build !lite
+
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestChatView(t *testing.T, interrupt func() bool) (*chatViewModel, chan string) {
+	t.Helper()
+	inputs := make(chan string, 1)
+	m := newChatViewModel(inputs, interrupt)
+	m.Update(tea.WindowSizeMsg{Width: 40, Height: 12})
+	return m, inputs
+}
+
+func typeInto(m *chatViewModel, text string) {
+	for _, r := range text {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func TestChatViewWrite(t *testing.T) {
+	m, _ := newTestChatView(t, nil)
+
+	m.Update(chatOutputMsg("Hi there.\r\n"))
+	m.Update(chatOutputMsg("\033[33m⠋ throttled; retrying in 1.0s\033[0m"))
+	m.Update(chatOutputMsg("\r\033[K"))
+	m.Update(chatOutputMsg("\033[1AThe \033[90manswer\033[0m"))
+	m.Update(chatOutputMsg(" is 42.\n"))
+
+	want := "Hi there.\nThe \033[90manswer\033[0m is 42.\n"
+	if got := m.transcript(); got != want {
+		t.Errorf("transcript = %q, want %q", got, want)
+	}
+	if view := m.View(); !strings.Contains(view, "answer") || strings.Contains(view, "throttled") {
+		t.Errorf("unexpected view\n%s", view)
+	}
+}
+
+func TestChatViewWraps(t *testing.T) {
+	m, _ := newTestChatView(t, nil)
+	m.Update(chatOutputMsg(strings.Repeat("word ", 20) + "\n"))
+	if lines := strings.Count(m.wrapped.String(), "\n"); lines < 3 {
+		t.Errorf("expected a 100 character line to wrap at 40, got %d lines", lines)
+	}
+
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 12})
+	if lines := strings.Count(m.wrapped.String(), "\n"); lines != 1 {
+		t.Errorf("expected one line at 120 wide, got %d", lines)
+	}
+}
+
+func TestChatViewSubmit(t *testing.T) {
+	m, inputs := newTestChatView(t, nil)
+
+	// typed ahead of the prompt, the message waits in the box
+	typeInto(m, "first line")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(inputs) != 0 {
+		t.Fatal("expected nothing to be sent before the chat asks for input")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	typeInto(m, "second line")
+	if m.input.Height() != 2 {
+		t.Errorf("expected the input box to grow to 2 lines, got %d", m.input.Height())
+	}
+
+	m.Update(chatWaitMsg{})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := <-inputs; got != "first line\nsecond line" {
+		t.Errorf("sent %q", got)
+	}
+	if m.input.Value() != "" || m.waiting {
+		t.Error("expected the input box to be cleared after sending")
+	}
+
+	// an empty message isn't sent, but an empty answer is
+	m.Update(chatWaitMsg{})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if len(inputs) != 0 {
+		t.Error("expected an empty message not to be sent")
+	}
+	m.Update(chatWaitMsg{allowEmpty: true})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := <-inputs; got != "" {
+		t.Errorf("sent %q", got)
+	}
+}
+
+func TestChatViewCtrlC(t *testing.T) {
+	replying := true
+	m, _ := newTestChatView(t, func() bool {
+		cancelled := replying
+		replying = false
+		return cancelled
+	})
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); cmd != nil || m.quitting {
+		t.Fatal("expected Ctrl+C to cancel the reply, not quit")
+	}
+
+	typeInto(m, "never mind")
+	if m.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); m.quitting || m.input.Value() != "" {
+		t.Fatal("expected Ctrl+C to clear the input box first")
+	}
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); cmd == nil || !m.quitting {
+		t.Error("expected Ctrl+C on an empty box to quit")
+	}
+}
+
+func TestChatViewStatus(t *testing.T) {
+	m, _ := newTestChatView(t, nil)
+	m.Update(chatStatusMsg("claude · chat abc"))
+	view := m.View()
+	if !strings.Contains(view, "claude · chat abc") {
+		t.Errorf("expected the status bar in the view\n%s", view)
+	}
+	if lines := strings.Count(view, "\n") + 1; lines != 12 {
+		t.Errorf("expected the view to fill the 12 line screen, got %d lines", lines)
+	}
+}
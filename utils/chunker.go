@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Chunk is a contiguous, line-aligned slice of a source file produced by
+// ChunkSource.
+type Chunk struct {
+	// StartLine and EndLine are 1-based and inclusive.
+	StartLine int
+	EndLine   int
+	// Symbols lists the top-level declarations that begin in this chunk,
+	// in source order.
+	Symbols []string
+	Content string
+}
+
+// segment is a run of lines that should stay together where possible: a
+// top-level declaration with its leading comments, or a blank-line
+// separated paragraph for unrecognized languages.
+type segment struct {
+	start   int // 0-based index of first line
+	end     int // 0-based index one past the last line
+	symbols []string
+}
+
+// ChunkSource splits content into chunks of at most maxTokens (estimated
+// at ~4 characters per token), breaking on function/class boundaries for
+// languages ExtractSymbols recognizes and on blank lines otherwise, so each
+// chunk is a coherent unit a model can reason about on its own. A single
+// declaration larger than the budget is split further on line boundaries.
+// Chunks cover every line of content exactly once, in order.
+func ChunkSource(filename string, content []byte, maxTokens int) []Chunk {
+	if len(content) == 0 {
+		return nil
+	}
+
+	maxChars := maxTokens * 4
+	if maxChars <= 0 {
+		maxChars = 1
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var segments []segment
+	if patterns, ok := symbolPatterns[strings.ToLower(filepath.Ext(filename))]; ok {
+		segments = declarationSegments(lines, patterns)
+	} else {
+		segments = paragraphSegments(lines)
+	}
+
+	var chunks []Chunk
+	var current *Chunk
+	currentChars := 0
+
+	flush := func() {
+		if current != nil {
+			current.Content = strings.Join(lines[current.StartLine-1:current.EndLine], "")
+			chunks = append(chunks, *current)
+			current = nil
+			currentChars = 0
+		}
+	}
+
+	appendLines := func(start, end int, symbols []string) {
+		if current == nil {
+			current = &Chunk{StartLine: start + 1}
+		}
+		for i := start; i < end; i++ {
+			currentChars += len(lines[i])
+		}
+		current.EndLine = end
+		current.Symbols = append(current.Symbols, symbols...)
+	}
+
+	for _, seg := range segments {
+		segChars := 0
+		for i := seg.start; i < seg.end; i++ {
+			segChars += len(lines[i])
+		}
+
+		if current != nil && currentChars+segChars > maxChars {
+			flush()
+		}
+
+		if segChars <= maxChars {
+			appendLines(seg.start, seg.end, seg.symbols)
+			continue
+		}
+
+		// an oversized declaration: split it line by line, keeping its
+		// symbols on the first piece
+		symbols := seg.symbols
+		for i := seg.start; i < seg.end; i++ {
+			if current != nil && currentChars+len(lines[i]) > maxChars {
+				flush()
+			}
+			appendLines(i, i+1, symbols)
+			symbols = nil
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// declarationSegments groups lines into segments that each start at a
+// top-level declaration, pulling any directly preceding comment or
+// decorator lines along with it. Lines before the first declaration
+// (package clauses, imports) form their own leading segment.
+func declarationSegments(lines []string, patterns []*regexp.Regexp) []segment {
+	var starts []int
+	var symbols [][]string
+
+	for i, line := range lines {
+		symbol, ok := matchSymbol(patterns, strings.TrimRight(line, "\r\n"))
+		if !ok {
+			continue
+		}
+
+		start := i
+		for start > 0 && isLeadingCommentLine(lines[start-1]) {
+			start--
+		}
+		if len(starts) > 0 && start <= starts[len(starts)-1] {
+			// a declaration directly following another (e.g. one-line
+			// funcs) without a blank line stays where it is
+			start = i
+		}
+
+		starts = append(starts, start)
+		symbols = append(symbols, []string{symbol})
+	}
+
+	var segments []segment
+	if len(starts) == 0 || starts[0] > 0 {
+		end := len(lines)
+		if len(starts) > 0 {
+			end = starts[0]
+		}
+		segments = append(segments, segment{start: 0, end: end})
+	}
+
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		segments = append(segments, segment{start: start, end: end, symbols: symbols[i]})
+	}
+
+	return segments
+}
+
+// paragraphSegments groups lines into blank-line separated paragraphs, with
+// each blank line kept at the end of the paragraph before it.
+func paragraphSegments(lines []string) []segment {
+	var segments []segment
+	start := 0
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" && (i+1 == len(lines) || strings.TrimSpace(lines[i+1]) != "") {
+			segments = append(segments, segment{start: start, end: i + 1})
+			start = i + 1
+		}
+	}
+
+	if start < len(lines) {
+		segments = append(segments, segment{start: start, end: len(lines)})
+	}
+
+	return segments
+}
+
+// isLeadingCommentLine reports whether line is a doc comment or decorator
+// that belongs to the declaration following it.
+func isLeadingCommentLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return false
+	}
+
+	for _, prefix := range []string{"//", "#", "/*", "*", "@"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const chunkerGoFixture = `package main
+
+import "fmt"
+
+// Server serves things.
+type Server struct{}
+
+// Start starts the server.
+func (s *Server) Start() error {
+	return nil
+}
+
+func main() {
+	fmt.Println("hi")
+}
+`
+
+func TestChunkSource(t *testing.T) {
+	t.Run("splits go source on declaration boundaries", func(t *testing.T) {
+		// too small for any two declarations to share a chunk
+		chunks := ChunkSource("main.go", []byte(chunkerGoFixture), 25)
+
+		var symbols [][]string
+		for _, c := range chunks {
+			symbols = append(symbols, c.Symbols)
+		}
+		want := [][]string{{"Server"}, {"Server.Start"}, {"main"}}
+		if !reflect.DeepEqual(symbols, want) {
+			t.Fatalf("chunk symbols = %v, want %v", symbols, want)
+		}
+
+		if !strings.HasPrefix(chunks[1].Content, "// Start starts the server.\nfunc (s *Server) Start()") {
+			t.Errorf("expected doc comment to stay with its method, got %q", chunks[1].Content)
+		}
+		if chunks[1].StartLine != 8 || chunks[1].EndLine != 12 {
+			t.Errorf("expected method chunk to span lines 8-12, got %d-%d", chunks[1].StartLine, chunks[1].EndLine)
+		}
+	})
+
+	t.Run("packs small declarations together", func(t *testing.T) {
+		chunks := ChunkSource("main.go", []byte(chunkerGoFixture), 10000)
+		if len(chunks) != 1 {
+			t.Fatalf("expected a single chunk, got %d", len(chunks))
+		}
+		if chunks[0].Content != chunkerGoFixture {
+			t.Error("expected the single chunk to reproduce the file")
+		}
+		if !reflect.DeepEqual(chunks[0].Symbols, []string{"Server", "Server.Start", "main"}) {
+			t.Errorf("unexpected symbols %v", chunks[0].Symbols)
+		}
+	})
+
+	t.Run("splits an oversized declaration on lines", func(t *testing.T) {
+		var b strings.Builder
+		b.WriteString("def big():\n")
+		for i := 0; i < 20; i++ {
+			b.WriteString("    x = 1\n")
+		}
+		chunks := ChunkSource("big.py", []byte(b.String()), 10)
+		if len(chunks) < 2 {
+			t.Fatalf("expected the function to be split, got %d chunk(s)", len(chunks))
+		}
+		if !reflect.DeepEqual(chunks[0].Symbols, []string{"big"}) {
+			t.Errorf("expected the first piece to carry the symbol, got %v", chunks[0].Symbols)
+		}
+		for _, c := range chunks[1:] {
+			if len(c.Symbols) != 0 {
+				t.Errorf("expected continuation pieces to have no symbols, got %v", c.Symbols)
+			}
+		}
+	})
+
+	t.Run("falls back to paragraphs for unknown languages", func(t *testing.T) {
+		content := "first paragraph\nstill first\n\nsecond paragraph\n"
+		chunks := ChunkSource("notes.txt", []byte(content), 8)
+		if len(chunks) != 2 {
+			t.Fatalf("expected 2 chunks, got %d", len(chunks))
+		}
+		if chunks[1].Content != "second paragraph\n" || chunks[1].StartLine != 4 {
+			t.Errorf("unexpected second chunk %+v", chunks[1])
+		}
+	})
+
+	t.Run("chunks cover the whole file in order", func(t *testing.T) {
+		for _, budget := range []int{1, 5, 12, 40} {
+			chunks := ChunkSource("main.go", []byte(chunkerGoFixture), budget)
+			var b strings.Builder
+			next := 1
+			for _, c := range chunks {
+				if c.StartLine != next {
+					t.Fatalf("budget %d: chunk starts at line %d, want %d", budget, c.StartLine, next)
+				}
+				next = c.EndLine + 1
+				b.WriteString(c.Content)
+			}
+			if b.String() != chunkerGoFixture {
+				t.Errorf("budget %d: chunks do not reassemble the original file", budget)
+			}
+		}
+	})
+
+	t.Run("empty content", func(t *testing.T) {
+		if chunks := ChunkSource("main.go", nil, 100); chunks != nil {
+			t.Errorf("expected no chunks, got %v", chunks)
+		}
+	})
+}
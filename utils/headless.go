@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ciEnvVars are set by CI systems that don't set CI itself.
+var ciEnvVars = []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "JENKINS_URL", "CODEBUILD_BUILD_ID", "TF_BUILD"}
+
+// containerMarkers are files container runtimes create in the root of a
+// container: /.dockerenv for Docker and /run/.containerenv for Podman.
+var containerMarkers = []string{"/.dockerenv", "/run/.containerenv"}
+
+// InContainer reports whether chat-cli looks to be running in a container.
+func InContainer() bool {
+	if os.Getenv("container") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, marker := range containerMarkers {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// InCI reports whether chat-cli is running in a CI job.
+func InCI() bool {
+	if ci := os.Getenv("CI"); ci != "" {
+		on, err := strconv.ParseBool(ci)
+		return err != nil || on
+	}
+	for _, name := range ciEnvVars {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Headless reports whether chat-cli is running unattended, in a CI job or
+// in a container without a terminal, where it uses plain input, output
+// without color, and timestamped log lines. CHAT_CLI_HEADLESS set to true
+// or false overrides the detection.
+func Headless() bool {
+	if value := os.Getenv("CHAT_CLI_HEADLESS"); value != "" {
+		if on, err := strconv.ParseBool(value); err == nil {
+			return on
+		}
+	}
+	if InCI() {
+		return true
+	}
+	return InContainer() && !isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// ColorEnabled reports whether output to f may use color: f must be a
+// terminal, and NO_COLOR, TERM=dumb, and headless runs turn it off.
+func ColorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || Headless() {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// ansiEscape matches the color and cursor escape sequences chat-cli writes.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// StripANSI returns s without terminal escape sequences.
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// plainWriter writes to w without terminal escape sequences.
+type plainWriter struct {
+	w io.Writer
+}
+
+// NewPlainWriter returns a writer that strips terminal escape sequences
+// from what's written to it before writing it to w. Each sequence must be
+// in a single write, as they are when written with fmt.
+func NewPlainWriter(w io.Writer) io.Writer {
+	return plainWriter{w: w}
+}
+
+func (p plainWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(p.w, StripANSI(string(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
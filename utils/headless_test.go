@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestInCI(t *testing.T) {
+	for _, name := range append([]string{"CI"}, ciEnvVars...) {
+		t.Setenv(name, "")
+	}
+	if InCI() {
+		t.Fatal("expected no CI without any CI variables")
+	}
+
+	t.Setenv("CI", "false")
+	if InCI() {
+		t.Error("expected CI=false not to count as CI")
+	}
+	t.Setenv("CI", "woodpecker")
+	if !InCI() {
+		t.Error("expected any CI value other than false to count")
+	}
+
+	t.Setenv("CI", "")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if !InCI() {
+		t.Error("expected GITHUB_ACTIONS to count as CI")
+	}
+}
+
+func TestHeadlessOverride(t *testing.T) {
+	t.Setenv("CHAT_CLI_HEADLESS", "true")
+	if !Headless() {
+		t.Error("expected CHAT_CLI_HEADLESS=true to make the run headless")
+	}
+
+	t.Setenv("CHAT_CLI_HEADLESS", "false")
+	t.Setenv("CI", "true")
+	if Headless() {
+		t.Error("expected CHAT_CLI_HEADLESS=false to win over CI")
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("CHAT_CLI_HEADLESS", "false")
+
+	t.Setenv("NO_COLOR", "1")
+	if ColorEnabled(os.Stdout) {
+		t.Error("expected NO_COLOR to turn color off")
+	}
+
+	t.Setenv("NO_COLOR", "")
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ColorEnabled(f) {
+		t.Error("expected no color in a file")
+	}
+}
+
+func TestPlainWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPlainWriter(&buf)
+
+	in := "\033[90mUsing files: a.go\033[0m\n\033[1A\033[2Kdone"
+	n, err := w.Write([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(in) {
+		t.Errorf("wrote %d bytes; want %d", n, len(in))
+	}
+	if got, want := buf.String(), "Using files: a.go\ndone"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one parsed line of a .gitignore-style file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// IgnoreMatcher decides whether paths under a root directory are excluded
+// by .gitignore-style rules. It supports the commonly used subset of the
+// gitignore format: comments, blank lines, "!" negation, trailing "/" for
+// directory-only patterns, leading or embedded "/" for anchored patterns,
+// and "*", "?", "[...]" and "**" wildcards. Rules are read from the root
+// directory only; nested ignore files aren't consulted.
+type IgnoreMatcher struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreMatcher reads each of filenames (e.g. ".gitignore") from root,
+// in order, and returns a matcher for their combined rules. Missing or
+// unreadable files are skipped - an absent ignore file just means nothing
+// extra is ignored. The .git directory itself is always ignored.
+func LoadIgnoreMatcher(root string, filenames ...string) *IgnoreMatcher {
+	m := NewIgnoreMatcher([]string{".git/"})
+
+	for _, name := range filenames {
+		f, err := os.Open(filepath.Join(root, name)) // #nosec G304 - ignore files are read from a fixed name under root
+		if err != nil {
+			continue
+		}
+
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		_ = f.Close()
+
+		m.rules = append(m.rules, NewIgnoreMatcher(lines).rules...)
+	}
+
+	return m
+}
+
+// NewIgnoreMatcher builds a matcher from raw gitignore-format lines.
+func NewIgnoreMatcher(lines []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// "\#" and "\!" escape a literal leading character
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+
+	return m
+}
+
+// Ignored reports whether relPath (slash- or OS-separated, relative to the
+// matcher's root) is excluded. A path is also excluded when any of its
+// parent directories is, matching git's behavior where a file inside an
+// ignored directory can't be re-included.
+func (m *IgnoreMatcher) Ignored(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+
+	relPath = strings.Trim(filepath.ToSlash(filepath.Clean(relPath)), "/")
+	if relPath == "" || relPath == "." {
+		return false
+	}
+
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return m.match(relPath, isDir)
+}
+
+// match applies the rules to a single path, last matching rule winning.
+func (m *IgnoreMatcher) match(relPath string, isDir bool) bool {
+	ignored := false
+	base := path.Base(relPath)
+
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		var matched bool
+		if rule.anchored {
+			matched = matchGlobPath(rule.pattern, relPath)
+		} else {
+			matched, _ = path.Match(rule.pattern, base)
+		}
+
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// matchGlobPath matches a slash-separated pattern against a slash-separated
+// path segment by segment, where a "**" segment matches zero or more
+// whole segments.
+func matchGlobPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher_Ignored(t *testing.T) {
+	m := NewIgnoreMatcher([]string{
+		"# comment",
+		"",
+		"node_modules/",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"docs/**/*.tmp",
+		"secrets/",
+	})
+
+	tests := []struct {
+		name  string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"dir-only pattern matches directory", "node_modules", true, true},
+		{"dir-only pattern matches nested directory", "web/node_modules", true, true},
+		{"file inside ignored directory", "node_modules/pkg/index.js", false, true},
+		{"dir-only pattern does not match file", "node_modules", false, false},
+		{"basename glob at any depth", "logs/app.log", false, true},
+		{"negation re-includes file", "keep.log", false, false},
+		{"anchored pattern matches at root", "build", true, true},
+		{"anchored pattern does not match nested", "src/build", true, false},
+		{"double star matches zero dirs", "docs/a.tmp", false, true},
+		{"double star matches several dirs", "docs/x/y/a.tmp", false, true},
+		{"double star pattern is anchored", "other/docs/a.tmp", false, false},
+		{"unrelated file", "main.go", false, false},
+		{"secrets directory contents", "secrets/key.pem", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("dist/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".extraignore"), []byte("*.secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := LoadIgnoreMatcher(root, ".gitignore", ".extraignore", ".missing")
+
+	if !m.Ignored("dist", true) {
+		t.Error("expected rule from .gitignore to apply")
+	}
+	if !m.Ignored("config/prod.secret", false) {
+		t.Error("expected rule from second ignore file to apply")
+	}
+	if !m.Ignored(".git", true) {
+		t.Error("expected .git to always be ignored")
+	}
+	if m.Ignored("main.go", false) {
+		t.Error("expected unmatched file to not be ignored")
+	}
+}
+
+func TestIgnoreMatcher_NilIsPermissive(t *testing.T) {
+	var m *IgnoreMatcher
+	if m.Ignored("anything", false) {
+		t.Error("expected nil matcher to ignore nothing")
+	}
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PaletteItem is one choice offered by Choose.
+type PaletteItem struct {
+	Title       string
+	Description string
+}
+
+// text is what a search is matched against.
+func (i PaletteItem) text() string {
+	return i.Title + " " + i.Description
+}
+
+// Choose asks the user to pick one of items, narrowing them with a fuzzy
+// search as they type. It returns the index of the choice, or false if the
+// user cancelled. In a terminal it uses a Bubble Tea palette; otherwise,
+// or in the lite build, it reads a number or search from a plain line.
+func Choose(title string, items []PaletteItem) (int, bool) {
+	if fancyInput() {
+		if index, ok, err := BubblePalette(title, items); err == nil {
+			return index, ok
+		}
+	}
+	return plainChoose(os.Stdin, os.Stderr, title, items)
+}
+
+// plainChoose lists items with numbers and reads lines from in until one
+// is a number in range or a search that matches something, taking the best
+// match. An empty line or the end of input cancels.
+func plainChoose(in io.Reader, out io.Writer, title string, items []PaletteItem) (int, bool) {
+	_, _ = fmt.Fprintln(out, title)
+	for i, item := range items {
+		_, _ = fmt.Fprintf(out, "  %d. %s", i+1, item.Title)
+		if item.Description != "" {
+			_, _ = fmt.Fprintf(out, " - %s", item.Description)
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		_, _ = fmt.Fprint(out, "Choose a number or search (empty to cancel): ")
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return -1, false
+		}
+
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(items) {
+			return n - 1, true
+		}
+		if matches := FuzzyFilter(line, items); len(matches) > 0 {
+			return matches[0], true
+		}
+		_, _ = fmt.Fprintf(out, "Nothing matches %q.\n", line)
+		if err != nil {
+			return -1, false
+		}
+	}
+}
+
+// FuzzyFilter returns the indexes of the items whose title or description
+// fuzzily matches query, best match first. An empty query keeps every item
+// in order.
+func FuzzyFilter(query string, items []PaletteItem) []int {
+	type match struct{ index, score int }
+	var matches []match
+	for i, item := range items {
+		if score, ok := FuzzyScore(query, item.text()); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+
+	indexes := make([]int, len(matches))
+	for i, m := range matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
+
+// FuzzyScore reports whether the characters of query appear in text in
+// order, ignoring case and spaces in query, and scores the match so that
+// runs of consecutive characters and characters starting a word count
+// most.
+func FuzzyScore(query, text string) (int, bool) {
+	query = strings.ToLower(strings.ReplaceAll(query, " ", ""))
+	target := []rune(strings.ToLower(text))
+	if query == "" {
+		return 0, true
+	}
+
+	score, next, prev := 0, 0, -2
+	for _, q := range query {
+		found := false
+		for ; next < len(target); next++ {
+			if target[next] != q {
+				continue
+			}
+			switch {
+			case next == prev+1:
+				score += 4
+			case next == 0 || !unicode.IsLetter(target[next-1]) && !unicode.IsDigit(target[next-1]):
+				score += 3
+			default:
+				score++
+			}
+			prev = next
+			next++
+			found = true
+			break
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return score, true
+}
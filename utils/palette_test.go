@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	for _, tt := range []struct {
+		query, text string
+		want        bool
+	}{
+		{"", "anything", true},
+		{"nc", "New chat", true},
+		{"RESUME", "Resume last chat", true},
+		{"pick mod", "Pick a model", true},
+		{"zz", "New chat", false},
+		{"tahc", "chat", false},
+	} {
+		if _, got := FuzzyScore(tt.query, tt.text); got != tt.want {
+			t.Errorf("FuzzyScore(%q, %q) matched = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+	}
+
+	consecutive, _ := FuzzyScore("chat", "New chat")
+	scattered, _ := FuzzyScore("chat", "Check what a task does")
+	if consecutive <= scattered {
+		t.Errorf("expected a consecutive match (%d) to beat a scattered one (%d)", consecutive, scattered)
+	}
+}
+
+var paletteItems = []PaletteItem{
+	{Title: "New chat", Description: "start a conversation"},
+	{Title: "Resume last chat", Description: "pick up the most recent conversation"},
+	{Title: "Pick a model", Description: "choose the default model"},
+	{Title: "View usage", Description: "tokens and estimated cost"},
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	if got := FuzzyFilter("", paletteItems); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("expected every item in order, got %v", got)
+	}
+	if got := FuzzyFilter("model", paletteItems); len(got) == 0 || got[0] != 2 {
+		t.Errorf("expected Pick a model first, got %v", got)
+	}
+	if got := FuzzyFilter("cost", paletteItems); len(got) == 0 || got[0] != 3 {
+		t.Errorf("expected descriptions searched, got %v", got)
+	}
+}
+
+func TestPlainChoose(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantIndex int
+		wantOK    bool
+	}{
+		{"2\n", 1, true},
+		{"usage\n", 3, true},
+		{"zzz\n4\n", 3, true},
+		{"9\n", -1, false},
+		{"\n", -1, false},
+		{"", -1, false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		index, ok := plainChoose(strings.NewReader(tt.input), &out, "What now?", paletteItems)
+		if index != tt.wantIndex || ok != tt.wantOK {
+			t.Errorf("input %q: got %d, %v, want %d, %v", tt.input, index, ok, tt.wantIndex, tt.wantOK)
+		}
+		if !strings.Contains(out.String(), "  3. Pick a model - choose the default model") {
+			t.Errorf("expected a numbered list, got\n%s", out.String())
+		}
+	}
+}
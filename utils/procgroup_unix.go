@@ -1,21 +1,21 @@
 //go:build !windows
 
-package tools
+package utils
 
 import (
 	"os/exec"
 	"syscall"
 )
 
-// setProcessGroup puts cmd in its own process group so killProcessGroup can
+// SetProcessGroup puts cmd in its own process group so KillProcessGroup can
 // terminate it along with any children (e.g. a grandchild like `sleep`
 // spawned by `sh -c`) rather than just the shell itself.
-func setProcessGroup(cmd *exec.Cmd) {
+func SetProcessGroup(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
 
-// killProcessGroup kills the process group started by setProcessGroup.
-func killProcessGroup(cmd *exec.Cmd) {
+// KillProcessGroup kills the process group started by SetProcessGroup.
+func KillProcessGroup(cmd *exec.Cmd) {
 	if cmd.Process != nil {
 		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
 	}
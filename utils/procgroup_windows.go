@@ -0,0 +1,17 @@
+//go:build windows
+
+package utils
+
+import "os/exec"
+
+// SetProcessGroup is a no-op on Windows; process groups are set up
+// differently there and aren't needed for the shell commands chat-cli runs.
+func SetProcessGroup(cmd *exec.Cmd) {}
+
+// KillProcessGroup kills the process directly, since Unix-style process
+// groups aren't available on Windows.
+func KillProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// SkippedEvents counts the stream events a reader passed over because it
+// can't handle them: members of a union the AWS SDK doesn't know yet
+// (UnknownUnionMember), and members the SDK knows but chat-cli doesn't use.
+// Counting them lets a stream report them once, when it ends, instead of
+// as each one arrives, and keeps a newer Bedrock or SDK from breaking the
+// stream.
+type SkippedEvents struct {
+	counts map[string]int
+}
+
+// Record counts member, a union value from the stream.
+func (s *SkippedEvents) Record(member interface{}) {
+	if s.counts == nil {
+		s.counts = map[string]int{}
+	}
+	s.counts[unionMemberName(member)]++
+}
+
+// Names returns the kinds of event skipped, sorted.
+func (s *SkippedEvents) Names() []string {
+	names := make([]string, 0, len(s.counts))
+	for name := range s.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unionMemberName names a union member for a warning: the tag Bedrock
+// sent for one the SDK doesn't know, otherwise the union and member
+// names, e.g. ContentBlockDelta.Citation.
+func unionMemberName(member interface{}) string {
+	if unknown, ok := member.(*types.UnknownUnionMember); ok {
+		return unknown.Tag
+	}
+	name := fmt.Sprintf("%T", member)
+	name = name[strings.LastIndex(name, ".")+1:]
+	if union, field, found := strings.Cut(name, "Member"); found {
+		return union + "." + field
+	}
+	return name
+}
+
+var (
+	warnedMu sync.Mutex
+	// warned holds the kinds of event already warned about, so a chat
+	// doesn't repeat the warning every turn.
+	warned = map[string]bool{}
+)
+
+// Warn writes a single line to w summarizing the skipped events that
+// haven't been warned about before in this process. It writes nothing if
+// there are none.
+func (s *SkippedEvents) Warn(w io.Writer) {
+	warnedMu.Lock()
+	defer warnedMu.Unlock()
+
+	var parts []string
+	total := 0
+	for _, name := range s.Names() {
+		if warned[name] {
+			continue
+		}
+		warned[name] = true
+		total += s.counts[name]
+		parts = append(parts, fmt.Sprintf("%s ×%d", name, s.counts[name]))
+	}
+	if total == 0 {
+		return
+	}
+
+	noun := "events"
+	if total == 1 {
+		noun = "event"
+	}
+	fmt.Fprintf(w, "warning: skipped %d stream %s this version of chat-cli doesn't handle (%s); upgrading may add support\n",
+		total, noun, strings.Join(parts, ", "))
+}
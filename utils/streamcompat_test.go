@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestSkippedEventsWarn(t *testing.T) {
+	warned = map[string]bool{}
+	defer func() { warned = map[string]bool{} }()
+
+	var skipped SkippedEvents
+	skipped.Record(&types.UnknownUnionMember{Tag: "newDelta"})
+	skipped.Record(&types.UnknownUnionMember{Tag: "newDelta"})
+	skipped.Record(&types.ContentBlockDeltaMemberCitation{})
+
+	var out bytes.Buffer
+	skipped.Warn(&out)
+	want := "warning: skipped 3 stream events this version of chat-cli doesn't handle (ContentBlockDelta.Citation ×1, newDelta ×2); upgrading may add support\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	// a later stream only warns about what's new
+	var next SkippedEvents
+	next.Record(&types.UnknownUnionMember{Tag: "newDelta"})
+	next.Record(&types.UnknownUnionMember{Tag: "otherEvent"})
+	out.Reset()
+	next.Warn(&out)
+	if !strings.Contains(out.String(), "skipped 1 stream event ") || !strings.Contains(out.String(), "(otherEvent ×1)") {
+		t.Errorf("expected only the new event, got %q", out.String())
+	}
+
+	out.Reset()
+	next.Warn(&out)
+	if out.Len() != 0 {
+		t.Errorf("expected no repeated warning, got %q", out.String())
+	}
+
+	var none SkippedEvents
+	none.Warn(&out)
+	if out.Len() != 0 {
+		t.Errorf("expected nothing for a clean stream, got %q", out.String())
+	}
+}
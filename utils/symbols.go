@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// symbolPatterns maps a lowercase file extension to regular expressions
+// matching a top-level declaration on a single line. The last capture
+// group is the symbol name; when a pattern has two groups (e.g. a Go
+// method's receiver type and name), both are joined with ".".
+//
+// This is deliberately regex-based rather than a real parser: it only
+// needs to be good enough to sketch a file's outline for the model, and
+// it keeps chat-cli free of per-language parser dependencies.
+var symbolPatterns = map[string][]*regexp.Regexp{
+	".go": {
+		regexp.MustCompile(`^func\s+\(\s*(?:\w+\s+)?\*?(\w+)(?:\[[^\]]*\])?\s*\)\s*(\w+)`),
+		regexp.MustCompile(`^func\s+(\w+)`),
+		regexp.MustCompile(`^type\s+(\w+)`),
+	},
+	".py": {
+		regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`),
+		regexp.MustCompile(`^class\s+(\w+)`),
+	},
+	".js":  jsSymbolPatterns,
+	".jsx": jsSymbolPatterns,
+	".mjs": jsSymbolPatterns,
+	".ts":  jsSymbolPatterns,
+	".tsx": jsSymbolPatterns,
+	".rs": {
+		regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:unsafe\s+)?(?:fn|struct|enum|trait|mod|impl)\s+(\w+)`),
+	},
+	".java": jvmSymbolPatterns,
+	".kt":   jvmSymbolPatterns,
+	".cs":   jvmSymbolPatterns,
+	".rb": {
+		regexp.MustCompile(`^(?:def|class|module)\s+([\w.?!]+)`),
+	},
+}
+
+var jsSymbolPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+(\w+)`),
+	regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`),
+	regexp.MustCompile(`^(?:export\s+)?(?:interface|type|enum)\s+(\w+)`),
+	regexp.MustCompile(`^(?:export\s+)?(?:const|let)\s+(\w+)\s*=\s*(?:async\s*)?(?:\([^)]*\)|\w+)\s*=>`),
+}
+
+var jvmSymbolPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?:(?:public|private|protected|internal|abstract|final|static|sealed|data|open|partial)\s+)*(?:class|interface|enum|record|object)\s+(\w+)`),
+	regexp.MustCompile(`^(?:(?:public|private|protected|internal|override|suspend)\s+)*fun\s+(\w+)`),
+}
+
+// HasSymbolPatterns reports whether filename's language is one
+// ExtractSymbols (and boundary-aware chunking) understands.
+func HasSymbolPatterns(filename string) bool {
+	_, ok := symbolPatterns[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// matchSymbol returns the symbol declared on line, if any, using the
+// patterns for filename's extension.
+func matchSymbol(patterns []*regexp.Regexp, line string) (string, bool) {
+	for _, re := range patterns {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		var parts []string
+		for _, group := range m[1:] {
+			if group != "" {
+				parts = append(parts, group)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		return strings.Join(parts, "."), true
+	}
+	return "", false
+}
+
+// ExtractSymbols returns the names of top-level declarations in content,
+// in source order, using filename's extension to choose a language. Files
+// in unrecognized languages yield no symbols.
+func ExtractSymbols(filename string, content []byte) []string {
+	patterns, ok := symbolPatterns[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil
+	}
+
+	var symbols []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if symbol, found := matchSymbol(patterns, line); found {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractSymbols(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     []string
+	}{
+		{
+			name:     "go functions, methods and types",
+			filename: "main.go",
+			content: `package main
+
+type Server struct{}
+
+func (s *Server) Start() error {
+	return nil
+}
+
+func main() {
+	inner := func() {}
+	_ = inner
+}
+`,
+			want: []string{"Server", "Server.Start", "main"},
+		},
+		{
+			name:     "python defs and classes, top-level only",
+			filename: "app.py",
+			content: `class Handler:
+    def method(self):
+        pass
+
+async def fetch():
+    pass
+`,
+			want: []string{"Handler", "fetch"},
+		},
+		{
+			name:     "typescript exports",
+			filename: "index.TS",
+			content: `export function render() {}
+export default class App {}
+export interface Props {}
+export const handler = async (event) => {}
+`,
+			want: []string{"render", "App", "Props", "handler"},
+		},
+		{
+			name:     "unknown language",
+			filename: "notes.txt",
+			content:  "func looksLikeCode() {}",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractSymbols(tt.filename, []byte(tt.content))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractSymbols() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasSymbolPatterns(t *testing.T) {
+	if !HasSymbolPatterns("cmd/chat.go") {
+		t.Error("expected .go to be recognized")
+	}
+	if HasSymbolPatterns("README") {
+		t.Error("expected a file with no extension to be unrecognized")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,12 +21,15 @@ type StreamingOutputHandler func(ctx context.Context, part string) error
 // ProcessStreamingOutput drains a Bedrock ConverseStream, invoking handler
 // for each text delta and reasoningHandler for each reasoning-content delta
 // (pass a no-op handler if the caller doesn't support reasoning mode).
-func ProcessStreamingOutput(output *bedrockruntime.ConverseStreamOutput, handler, reasoningHandler StreamingOutputHandler) (types.Message, error) {
+func ProcessStreamingOutput(ctx context.Context, output *bedrockruntime.ConverseStreamOutput, handler, reasoningHandler StreamingOutputHandler) (types.Message, error) {
 
 	var combinedResult string
 
 	msg := types.Message{}
 
+	var skipped SkippedEvents
+	defer skipped.Warn(os.Stderr)
+
 	for event := range output.GetStream().Events() {
 		switch v := event.(type) {
 		case *types.ConverseStreamOutputMemberMessageStart:
@@ -36,25 +40,39 @@ func ProcessStreamingOutput(output *bedrockruntime.ConverseStreamOutput, handler
 
 			switch delta := v.Value.Delta.(type) {
 			case *types.ContentBlockDeltaMemberText:
-				if err := handler(context.Background(), delta.Value); err != nil {
+				if err := handler(ctx, delta.Value); err != nil {
 					return msg, fmt.Errorf("handler error: %w", err)
 				}
 				combinedResult += delta.Value
 
 			case *types.ContentBlockDeltaMemberReasoningContent:
-				if textDelta, ok := delta.Value.(*types.ReasoningContentBlockDeltaMemberText); ok {
-					if err := reasoningHandler(context.Background(), textDelta.Value); err != nil {
+				switch reasoningDelta := delta.Value.(type) {
+				case *types.ReasoningContentBlockDeltaMemberText:
+					if err := reasoningHandler(ctx, reasoningDelta.Value); err != nil {
 						return msg, fmt.Errorf("handler error: %w", err)
 					}
+				case *types.UnknownUnionMember:
+					skipped.Record(reasoningDelta)
 				}
 				// Signature and redacted-content deltas aren't rendered as
 				// visible text; prompt is one-shot so there's no next turn
 				// to preserve them for (Functional Design Decision 3,
 				// unit-5-extended-thinking).
+
+			default:
+				skipped.Record(delta)
 			}
 
-		case *types.UnknownUnionMember:
-			fmt.Println("unknown tag:", v.Tag)
+		case *types.ConverseStreamOutputMemberContentBlockStart,
+			*types.ConverseStreamOutputMemberContentBlockStop,
+			*types.ConverseStreamOutputMemberMessageStop,
+			*types.ConverseStreamOutputMemberMetadata:
+			// nothing to collect for a text-only response
+
+		default:
+			// includes *types.UnknownUnionMember, for events newer than
+			// the SDK
+			skipped.Record(event)
 		}
 	}
 
@@ -67,6 +85,22 @@ func ProcessStreamingOutput(output *bedrockruntime.ConverseStreamOutput, handler
 	return msg, nil
 }
 
+// pathError is returned by the path validation helpers. It keeps their
+// plain messages while letting callers classify the failure with
+// errors.Is(err, fs.ErrNotExist) or errors.Is(err, fs.ErrPermission).
+type pathError struct {
+	msg  string
+	kind error
+}
+
+func (e *pathError) Error() string {
+	return e.msg
+}
+
+func (e *pathError) Unwrap() error {
+	return e.kind
+}
+
 // confineToWorkingDir resolves filename against the current working
 // directory and returns the resulting absolute path, or an error if
 // filename would escape it. Shared by ValidateLocalPath (which additionally
@@ -85,7 +119,7 @@ func confineToWorkingDir(filename string) (string, error) {
 	// Ensure the full path is within the base directory
 	relPath, err := filepath.Rel(baseDir, fullPath)
 	if err != nil || strings.HasPrefix(relPath, "..") || strings.HasPrefix(relPath, string(filepath.Separator)) {
-		return "", fmt.Errorf("access denied: %s is outside of the allowed directory", filename)
+		return "", &pathError{msg: fmt.Sprintf("access denied: %s is outside of the allowed directory", filename), kind: fs.ErrPermission}
 	}
 
 	return fullPath, nil
@@ -103,7 +137,7 @@ func ValidateLocalPath(filename string) (string, error) {
 
 	// Check if the file exists
 	if _, statErr := os.Stat(fullPath); os.IsNotExist(statErr) {
-		return "", fmt.Errorf("file does not exist: %s", filename)
+		return "", &pathError{msg: fmt.Sprintf("file does not exist: %s", filename), kind: fs.ErrNotExist}
 	}
 
 	return fullPath, nil
@@ -122,9 +156,49 @@ func ValidateLocalPathForWrite(filename string) (string, error) {
 // while still blocking relative path traversal outside the working directory.
 func resolveUserPath(filename string) (string, error) {
 	if filename == "" {
-		return "", fmt.Errorf("file does not exist: %s", filename)
+		return "", &pathError{msg: fmt.Sprintf("file does not exist: %s", filename), kind: fs.ErrNotExist}
+	}
+
+	fullPath, err := expandUserPath(filename)
+	if err != nil {
+		return "", err
+	}
+
+	if _, statErr := os.Stat(fullPath); os.IsNotExist(statErr) {
+		return "", &pathError{msg: fmt.Sprintf("file does not exist: %s", filename), kind: fs.ErrNotExist}
+	}
+
+	return fullPath, nil
+}
+
+// ValidateOutputPath resolves a user-supplied path to write to, such as
+// the file chat's /save writes. Like the paths given to --document, it can
+// be absolute or start with ~, but a relative path can't climb out of the
+// working directory. The file needn't exist, but its directory must, and
+// the path can't name a directory.
+func ValidateOutputPath(filename string) (string, error) {
+	if filename == "" {
+		return "", fmt.Errorf("no file given")
+	}
+
+	fullPath, err := expandUserPath(filename)
+	if err != nil {
+		return "", err
+	}
+
+	if info, statErr := os.Stat(filepath.Dir(fullPath)); statErr != nil || !info.IsDir() {
+		return "", &pathError{msg: fmt.Sprintf("directory does not exist: %s", filepath.Dir(fullPath)), kind: fs.ErrNotExist}
+	}
+	if info, statErr := os.Stat(fullPath); statErr == nil && info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", filename)
 	}
 
+	return fullPath, nil
+}
+
+// expandUserPath expands a leading ~ in filename and makes it absolute,
+// rejecting a relative path that leads outside the working directory.
+func expandUserPath(filename string) (string, error) {
 	expanded := filename
 	if strings.HasPrefix(filename, "~/") {
 		home, err := os.UserHomeDir()
@@ -154,14 +228,10 @@ func resolveUserPath(filename string) (string, error) {
 
 		relPath, err := filepath.Rel(baseDir, fullPath)
 		if err != nil || strings.HasPrefix(relPath, "..") {
-			return "", fmt.Errorf("access denied: %s is outside of the allowed directory", filename)
+			return "", &pathError{msg: fmt.Sprintf("access denied: %s is outside of the allowed directory", filename), kind: fs.ErrPermission}
 		}
 	}
 
-	if _, statErr := os.Stat(fullPath); os.IsNotExist(statErr) {
-		return "", fmt.Errorf("file does not exist: %s", filename)
-	}
-
 	return fullPath, nil
 }
 
@@ -235,10 +305,14 @@ func ReadDocument(filename string) (data []byte, format string, err error) {
 
 func StringPrompt(label string) string {
 	// Check if we're in a TTY - if so, use the fancy bubble input
-	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+	if fancyInput() {
 		// We don't print the prompt here anymore since it's inside the input box
-		input, _ := BubbleInput()
-		return input
+		if input, ok := BubbleInput(); ok {
+			return input
+		}
+		// the input box couldn't start, e.g. on a terminal it can't
+		// drive, so read a plain line instead
+		label = ">"
 	}
 
 	// Fallback to simple input for non-interactive use
@@ -258,6 +332,20 @@ func StringPrompt(label string) string {
 	return s
 }
 
+// fancyInput reports whether StringPrompt should use the input box: only
+// in a build that has it, reading from a terminal that can draw it, and
+// not in a headless run. Set CHAT_CLI_PLAIN_INPUT to always read plain
+// lines.
+func fancyInput() bool {
+	if LiteBuild || os.Getenv("CHAT_CLI_PLAIN_INPUT") != "" || Headless() {
+		return false
+	}
+	if term := os.Getenv("TERM"); term == "dumb" {
+		return false
+	}
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
+
 func DecodeImage(base64Image string) ([]byte, error) {
 	decoded, err := base64.StdEncoding.DecodeString(base64Image)
 	if err != nil {
@@ -267,19 +355,9 @@ func DecodeImage(base64Image string) ([]byte, error) {
 }
 
 func LoadDocument() (string, error) {
-
-	// read a document from stdin
-	var document string
-
-	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
-		// do nothing
-	} else {
-		stdin, err := io.ReadAll(os.Stdin)
-
-		if err != nil {
-			return "", err
-		}
-		document = string(stdin)
+	document, err := ReadPipedStdin()
+	if err != nil {
+		return "", err
 	}
 
 	if document != "" {
@@ -289,6 +367,20 @@ func LoadDocument() (string, error) {
 	return document, nil
 }
 
+// ReadPipedStdin returns everything piped into stdin, or "" when stdin is
+// a terminal.
+func ReadPipedStdin() (string, error) {
+	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return "", nil
+	}
+
+	stdin, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(stdin), nil
+}
+
 // maxGitBoundaryWalkLevels bounds FindGitBoundary's upward search as a
 // defensive cap against a pathologically deep dir with no repo above it.
 const maxGitBoundaryWalkLevels = 64
@@ -457,6 +457,56 @@ func TestResolveUserPath(t *testing.T) {
 	})
 }
 
+func TestValidateOutputPath(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(originalWd); err != nil {
+			t.Errorf("Failed to change back to original directory: %v", err)
+		}
+	}()
+
+	t.Run("a new file in the working directory", func(t *testing.T) {
+		got, err := ValidateOutputPath("reply.md")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if filepath.Base(got) != "reply.md" || !filepath.IsAbs(got) {
+			t.Fatalf("expected an absolute path to reply.md, got %q", got)
+		}
+	})
+
+	t.Run("an absolute path elsewhere", func(t *testing.T) {
+		want := filepath.Join(t.TempDir(), "reply.md")
+		got, err := ValidateOutputPath(want)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	})
+
+	for name, path := range map[string]string{
+		"relative path traversal is blocked": "../../../tmp/reply.md",
+		"a missing directory is rejected":    filepath.Join(tempDir, "missing", "reply.md"),
+		"a directory is rejected":            tempDir,
+		"an empty path is rejected":          "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ValidateOutputPath(path); err == nil {
+				t.Fatalf("expected %q to be rejected", path)
+			}
+		})
+	}
+}
+
 func TestStringPrompt(t *testing.T) {
 	// StringPrompt reads from stdin, so we can't easily test it in unit tests
 	// We could test it with dependency injection or mocking, but for now
@@ -503,3 +553,16 @@ func TestFindGitBoundary(t *testing.T) {
 		}
 	})
 }
+
+func TestFancyInputFallsBackToPlainInput(t *testing.T) {
+	t.Setenv("CHAT_CLI_PLAIN_INPUT", "1")
+	if fancyInput() {
+		t.Error("expected CHAT_CLI_PLAIN_INPUT to turn the input box off")
+	}
+
+	t.Setenv("CHAT_CLI_PLAIN_INPUT", "")
+	t.Setenv("TERM", "dumb")
+	if fancyInput() {
+		t.Error("expected a dumb terminal to get plain input")
+	}
+}
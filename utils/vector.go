@@ -0,0 +1,23 @@
+package utils
+
+import "math"
+
+// CosineSimilarity returns the cosine of the angle between a and b, from
+// -1 (opposite) to 1 (the same direction). Vectors of different lengths,
+// or with no magnitude, have a similarity of 0.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}